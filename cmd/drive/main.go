@@ -26,33 +26,74 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/odeke-em/command"
 	"github.com/odeke-em/drive/config"
 	"github.com/odeke-em/drive/gen"
 	"github.com/odeke-em/drive/src"
-	"github.com/odeke-em/drive/src/dcrypto"
 )
 
 var context *config.Context
 
 type errorer func() error
 
+// boundCommands are the commands by the names, aliases included, that they are run by.
+var boundCommands = map[string]command.Cmd{}
+
 func bindCommandWithAliases(key, description string, cmd command.Cmd, requiredFlags []string) {
 	command.On(key, description, cmd, requiredFlags)
+	boundCommands[key] = cmd
 	aliases, ok := drive.Aliases[key]
 	if ok {
 		for _, alias := range aliases {
 			command.On(alias, description, cmd, requiredFlags)
+			boundCommands[alias] = cmd
 		}
 	}
 }
 
+// injectFlagsFromEnv inserts right after the command the flags of the
+// command that are set in the environment e.g DRIVE_QUIET=true for -quiet.
+// Flags given on the commandline come after them, thus taking precedence.
+func injectFlagsFromEnv(args []string) []string {
+	if len(args) < 2 {
+		return args
+	}
+	cmd, ok := boundCommands[args[1]]
+	if !ok {
+		return args
+	}
+
+	var names []string
+	cmd.Flags(flag.NewFlagSet(args[1], flag.ContinueOnError)).VisitAll(func(f *flag.Flag) {
+		names = append(names, f.Name)
+	})
+
+	envFlags := drive.FlagsFromEnv(names...)
+	if len(envFlags) < 1 {
+		return args
+	}
+
+	injected := append([]string{}, args[:2]...)
+	injected = append(injected, envFlags...)
+	return append(injected, args[2:]...)
+}
+
+// flagAliases maps the flags that are other spellings of a flag to the
+// flag, and its .driverc key, that they set.
+var flagAliases = map[string]string{
+	drive.CLIOptionDirsOnly: drive.CLIOptionDirectories,
+}
+
 func translateKeyChecks(definedFlags map[string]*flag.Flag) map[string]bool {
 	keysOnly := map[string]bool{}
 
 	for k, _ := range definedFlags {
 		keysOnly[k] = true
+		if original, ok := flagAliases[k]; ok {
+			keysOnly[original] = true
+		}
 	}
 
 	return keysOnly
@@ -83,6 +124,25 @@ func main() {
 	}
 	runtime.GOMAXPROCS(int(maxProcs))
 
+	os.Args = extractGlobalOptions(os.Args)
+	if gdName := os.Getenv(drive.GDNameEnvKey); gdName != "" {
+		exitWithError(config.UseGDDirName(gdName))
+	}
+	exitWithError(drive.UseTransport(drive.TransportOptions{
+		Proxy:              os.Getenv(drive.ProxyEnvKey),
+		CABundle:           os.Getenv(drive.CABundleEnvKey),
+		TLSMinVersion:      os.Getenv(drive.TLSMinVersionEnvKey),
+		InsecureSkipVerify: os.Getenv(drive.InsecureSkipVerifyEnvKey) != "",
+		BindAddress:        os.Getenv(drive.BindAddressEnvKey),
+		PreferIPv4:         os.Getenv(drive.PreferIPv4EnvKey) != "",
+	}))
+	if os.Getenv(drive.InsecureSkipVerifyEnvKey) != "" {
+		drive.FprintfShadow(os.Stderr, "warning: TLS certificates aren't being verified, use --%s for debugging only\n", drive.CLIOptionInsecureSkipVerify)
+	}
+	exitWithError(drive.UseMetadataCache(os.Getenv(drive.MetadataTTLEnvKey), os.Getenv(drive.MetadataCacheDiskEnvKey) != ""))
+	exitWithError(drive.UseObjectCache(os.Getenv(drive.ObjectCacheEnvKey), os.Getenv(drive.ObjectCacheSizeEnvKey)))
+	exitWithError(useOutput(os.Args))
+
 	bindCommandWithAliases(drive.AboutKey, drive.DescAbout, &aboutCmd{}, []string{})
 	bindCommandWithAliases(drive.CopyKey, drive.DescCopy, &copyCmd{}, []string{})
 	bindCommandWithAliases(drive.DiffKey, drive.DescDiff, &diffCmd{}, []string{})
@@ -90,14 +150,28 @@ func main() {
 	bindCommandWithAliases(drive.FeaturesKey, drive.DescFeatures, &featuresCmd{}, []string{})
 	bindCommandWithAliases(drive.InitKey, drive.DescInit, &initCmd{}, []string{})
 	bindCommandWithAliases(drive.DeInitKey, drive.DescDeInit, &deInitCmd{}, []string{})
+	bindCommandWithAliases(drive.DeAuthKey, drive.DescDeAuth, &deAuthCmd{}, []string{})
 	bindCommandWithAliases(drive.HelpKey, drive.DescHelp, &helpCmd{}, []string{})
 
 	bindCommandWithAliases(drive.ListKey, drive.DescList, &listCmd{}, []string{})
 	bindCommandWithAliases(drive.MoveKey, drive.DescMove, &moveCmd{}, []string{})
 	bindCommandWithAliases(drive.PullKey, drive.DescPull, &pullCmd{}, []string{})
 	bindCommandWithAliases(drive.PushKey, drive.DescPush, &pushCmd{}, []string{})
+	bindCommandWithAliases(drive.BackupKey, drive.DescBackup, &backupCmd{}, []string{})
 	bindCommandWithAliases(drive.PubKey, drive.DescPublish, &publishCmd{}, []string{})
 	bindCommandWithAliases(drive.RenameKey, drive.DescRename, &renameCmd{}, []string{})
+	bindCommandWithAliases(drive.RelocateKey, drive.DescRelocate, &relocateCmd{}, []string{})
+	bindCommandWithAliases(drive.ContextsKey, drive.DescContexts, &contextsCmd{}, []string{})
+	bindCommandWithAliases(drive.CredentialsKey, drive.DescCredentials, &credentialsCmd{}, []string{})
+	bindCommandWithAliases(drive.CryptKey, drive.DescCrypt, &cryptCmd{}, []string{})
+	bindCommandWithAliases(drive.MigrateKey, drive.DescMigrate, &migrateCmd{}, []string{})
+	bindCommandWithAliases(drive.ConfigKey, drive.DescConfig, &configCmd{}, []string{})
+	bindCommandWithAliases(drive.CmpKey, drive.DescCmp, &cmpCmd{}, []string{})
+	bindCommandWithAliases(drive.ChecksumKey, drive.DescChecksum, &checksumCmd{}, []string{})
+	bindCommandWithAliases(drive.VerifyKey, drive.DescVerify, &verifyCmd{}, []string{})
+	bindCommandWithAliases(drive.FlushKey, drive.DescFlush, &flushCmd{}, []string{})
+	bindCommandWithAliases(drive.StatusKey, drive.DescStatus, &statusCmd{}, []string{})
+	bindCommandWithAliases(drive.TransferKey, drive.DescTransfer, &transferCmd{}, []string{})
 	bindCommandWithAliases(drive.QuotaKey, drive.DescQuota, &quotaCmd{}, []string{})
 	bindCommandWithAliases(drive.ShareKey, drive.DescShare, &shareCmd{}, []string{})
 	bindCommandWithAliases(drive.StatKey, drive.DescStat, &statCmd{}, []string{})
@@ -118,12 +192,40 @@ func main() {
 	bindCommandWithAliases(drive.DuKey, drive.DescDu, &duCmd{}, []string{})
 	bindCommandWithAliases(drive.StarKey, drive.DescStar, &starCmd{}, []string{})
 	bindCommandWithAliases(drive.UnStarKey, drive.DescUnStar, &unstarCmd{}, []string{})
+	bindCommandWithAliases(drive.LockKey, drive.DescLock, &lockCmd{}, []string{})
+	bindCommandWithAliases(drive.UnlockKey, drive.DescUnlock, &unlockCmd{}, []string{})
 	bindCommandWithAliases(drive.ClashesKey, drive.DescFixClashes, &clashesCmd{}, []string{})
 	bindCommandWithAliases(drive.IdKey, drive.DescId, &idCmd{}, []string{})
 	bindCommandWithAliases(drive.ReportIssueKey, drive.DescReportIssue, &issueCmd{}, []string{})
 
 	command.DefineHelp(&helpCmd{})
+	os.Args = injectFlagsFromEnv(os.Args)
 	command.ParseAndRun()
+	exitWithError(drive.SaveMetadataCaches())
+	drive.PrintSummary(nil, 0)
+}
+
+// useOutput applies the output picked by --output for the command in args.
+// With nobody to see them, prompts are turned off unless asked for.
+func useOutput(args []string) error {
+	output := os.Getenv(drive.OutputEnvKey)
+	if output == "" {
+		return nil
+	}
+
+	var commandName string
+	if len(args) >= 2 {
+		commandName = args[1]
+	}
+	if err := drive.UseOutput(output, commandName); err != nil {
+		return err
+	}
+
+	noPromptEnvKey := drive.FlagEnvKey(drive.NoPromptKey)
+	if _, set := os.LookupEnv(noPromptEnvKey); !set {
+		os.Setenv(noPromptEnvKey, "true")
+	}
+	return nil
 }
 
 type helpCmd struct {
@@ -166,20 +268,108 @@ func (cmd *versionCmd) Run(args []string, definedFlags map[string]*flag.Flag) {
 
 type initCmd struct {
 	ServiceAccountJSONFile *string `json:"-"`
+	RemoteFolderId         *string `json:"-"`
+	RemoteName             *string `json:"-"`
+	Device                 *bool   `json:"-"`
+	ReadOnly               *bool   `json:"-"`
+	Scope                  *string `json:"-"`
+	Manual                 *bool   `json:"-"`
+	Gcloud                 *bool   `json:"-"`
+	Merge                  *bool   `json:"-"`
+	RequireEmpty           *bool   `json:"-"`
+	Adopt                  *bool   `json:"-"`
 }
 
 func (cmd *initCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
 	cmd.ServiceAccountJSONFile = fs.String(drive.ServiceAccountJSONFileKey, "", "points the Google Service Account JSON file")
+	cmd.RemoteFolderId = fs.String(drive.CLIOptionRemoteFolderId, "", drive.DescRemoteFolderId)
+	cmd.RemoteName = fs.String(drive.CLIOptionRemoteName, "", drive.DescRemoteName)
+	cmd.Device = fs.Bool(drive.CLIOptionDevice, false, drive.DescDevice)
+	cmd.ReadOnly = fs.Bool(drive.CLIOptionReadOnly, false, drive.DescReadOnly)
+	cmd.Scope = fs.String(drive.CLIOptionScope, "", drive.DescScope)
+	cmd.Manual = fs.Bool(drive.CLIOptionManual, false, drive.DescManual)
+	cmd.Gcloud = fs.Bool(drive.CLIOptionGcloud, false, drive.DescGcloud)
+	cmd.Merge = fs.Bool(drive.CLIOptionMerge, false, drive.DescMerge)
+	cmd.RequireEmpty = fs.Bool(drive.CLIOptionRequireEmpty, false, drive.DescRequireEmpty)
+	cmd.Adopt = fs.Bool(drive.CLIOptionAdopt, false, drive.DescAdopt)
 	return fs
 }
 
 func (cmd *initCmd) Run(args []string, definedFlags map[string]*flag.Flag) {
-	comm := drive.New(initContext(args), nil)
+	remoteFolderId := strings.TrimSpace(*cmd.RemoteFolderId)
+	remoteName := strings.TrimSpace(*cmd.RemoteName)
+	if remoteFolderId != "" && remoteName != "" {
+		exitWithError(fmt.Errorf("-%s can't be combined with -%s", drive.CLIOptionRemoteFolderId, drive.CLIOptionRemoteName))
+	}
 	gcsJSONFile := *cmd.ServiceAccountJSONFile
-	if gcsJSONFile == "" {
-		exitWithError(comm.Init())
-	} else {
+	if gcsJSONFile != "" && *cmd.Device {
+		exitWithError(fmt.Errorf("-%s can't be combined with -%s", drive.ServiceAccountJSONFileKey, drive.CLIOptionDevice))
+	}
+	subject := impersonatedSubject()
+	if subject != "" && gcsJSONFile == "" {
+		exitWithError(fmt.Errorf("--%s needs a service account, pass in -%s", drive.CLIOptionImpersonate, drive.ServiceAccountJSONFileKey))
+	}
+	if *cmd.Manual && (gcsJSONFile != "" || *cmd.Device) {
+		exitWithError(fmt.Errorf("-%s can't be combined with -%s or -%s", drive.CLIOptionManual, drive.ServiceAccountJSONFileKey, drive.CLIOptionDevice))
+	}
+	if *cmd.Gcloud && (gcsJSONFile != "" || *cmd.Device || *cmd.Manual) {
+		exitWithError(fmt.Errorf("-%s can't be combined with -%s, -%s or -%s", drive.CLIOptionGcloud, drive.ServiceAccountJSONFileKey, drive.CLIOptionDevice, drive.CLIOptionManual))
+	}
+	var initOverlap string
+	for option, set := range map[string]bool{
+		drive.CLIOptionMerge:        *cmd.Merge,
+		drive.CLIOptionRequireEmpty: *cmd.RequireEmpty,
+		drive.CLIOptionAdopt:        *cmd.Adopt,
+	} {
+		if !set {
+			continue
+		}
+		if initOverlap != "" {
+			exitWithError(fmt.Errorf("-%s, -%s and -%s can't be combined", drive.CLIOptionMerge, drive.CLIOptionRequireEmpty, drive.CLIOptionAdopt))
+		}
+		initOverlap = option
+	}
+
+	context := initContext(args)
+	context.RemoteRootId = remoteFolderId
+	context.RemoteRootPath = remoteName
+	context.Subject = subject
+	scope := ""
+	if *cmd.ReadOnly {
+		scope = drive.DriveReadOnlyScope
+	}
+	if *cmd.Scope != "" {
+		if *cmd.ReadOnly {
+			exitWithError(fmt.Errorf("-%s can't be combined with -%s", drive.CLIOptionScope, drive.CLIOptionReadOnly))
+		}
+		resolved, err := drive.ResolveScope(*cmd.Scope)
+		exitWithError(err)
+		scope = resolved
+	}
+	if *cmd.Device {
+		resolved, err := drive.DeviceFlowScope(scope)
+		exitWithError(err)
+		scope = resolved
+	}
+	context.Scope = ""
+	if scope != drive.DriveScope {
+		context.Scope = scope
+	}
+	if context.Scope == drive.DriveAppDataScope && remoteFolderId == "" && remoteName == "" {
+		context.RemoteRootId = drive.AppDataFolderId
+	}
+	comm := drive.New(context, &drive.Options{InitOverlap: initOverlap})
+	switch {
+	case gcsJSONFile != "":
 		exitWithError(comm.InitWithServiceAccount(gcsJSONFile))
+	case *cmd.Device:
+		exitWithError(comm.InitWithDeviceFlow())
+	case *cmd.Manual:
+		exitWithError(comm.InitWithManualCode())
+	case *cmd.Gcloud:
+		exitWithError(comm.InitWithGcloudCredentials())
+	default:
+		exitWithError(comm.Init())
 	}
 }
 
@@ -202,6 +392,265 @@ func (cmd *deInitCmd) Run(args []string, definedFlags map[string]*flag.Flag) {
 	exitWithError(drive.New(context, opts).DeInit())
 }
 
+type deAuthCmd struct {
+	noPrompt *bool
+}
+
+func (cmd *deAuthCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.noPrompt = fs.Bool(drive.NoPromptKey, false, "disables the prompt")
+	return fs
+}
+
+func (cmd *deAuthCmd) Run(args []string, definedFlags map[string]*flag.Flag) {
+	context, path := discoverContext(args)
+
+	exitWithError(drive.New(context, &drive.Options{
+		NoPrompt: *cmd.noPrompt,
+		Path:     path,
+	}).DeAuth())
+}
+
+type relocateCmd struct{}
+
+func (cmd *relocateCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	return fs
+}
+
+func (cmd *relocateCmd) Run(args []string, definedFlags map[string]*flag.Flag) {
+	context, path := discoverContext(args)
+
+	exitWithError(drive.New(context, &drive.Options{
+		Path: path,
+	}).Relocate())
+}
+
+type contextsCmd struct {
+	Track *bool `json:"track"`
+}
+
+func (cmd *contextsCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.Track = fs.Bool(drive.CLIOptionTrack, false, drive.DescTrackContext)
+	return fs
+}
+
+func (cmd *contextsCmd) Run(args []string, definedFlags map[string]*flag.Flag) {
+	if !*cmd.Track {
+		// Listing doesn't need to be within a context.
+		exitWithError(drive.ListContexts())
+		return
+	}
+
+	context, path := discoverContext(args)
+	exitWithError(drive.New(context, &drive.Options{
+		Path: path,
+	}).Contexts(true))
+}
+
+type credentialsCmd struct {
+	Encrypt *bool   `json:"encrypt"`
+	Decrypt *bool   `json:"decrypt"`
+	Store   *string `json:"store"`
+}
+
+func (cmd *credentialsCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.Encrypt = fs.Bool(drive.CLIOptionEncrypt, false, drive.DescEncryptCredentials)
+	cmd.Decrypt = fs.Bool(drive.CLIOptionDecrypt, false, drive.DescDecryptCredentials)
+	cmd.Store = fs.String(drive.CLIOptionCredentialStore, "", drive.DescCredentialStore)
+	return fs
+}
+
+func (cmd *credentialsCmd) Run(args []string, definedFlags map[string]*flag.Flag) {
+	store := strings.TrimSpace(*cmd.Store)
+	chosen := 0
+	for _, set := range []bool{*cmd.Encrypt, *cmd.Decrypt, store != ""} {
+		if set {
+			chosen++
+		}
+	}
+	if chosen != 1 {
+		exitWithError(fmt.Errorf("credentials: expecting exactly one of -%s, -%s or -%s", drive.CLIOptionEncrypt, drive.CLIOptionDecrypt, drive.CLIOptionCredentialStore))
+	}
+
+	context, path := discoverContext(args)
+	g := drive.New(context, &drive.Options{
+		Path: path,
+	})
+	if store != "" {
+		exitWithError(g.StoreCredentials(store))
+		return
+	}
+	exitWithError(g.EncryptCredentials(*cmd.Decrypt))
+}
+
+type cryptCmd struct {
+	Rotate      *bool   `json:"rotate"`
+	OldPassword *string `json:"-"`
+	NewPassword *string `json:"-"`
+}
+
+func (cmd *cryptCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.Rotate = fs.Bool(drive.CLIOptionRotate, false, drive.DescCryptRotate)
+	cmd.OldPassword = fs.String(drive.CLIOptionOldPassword, "", drive.DescCryptOldPassword)
+	cmd.NewPassword = fs.String(drive.CLIOptionNewPassword, "", drive.DescCryptNewPassword)
+	return fs
+}
+
+func (cmd *cryptCmd) Run(args []string, definedFlags map[string]*flag.Flag) {
+	if len(args) < 1 {
+		exitWithError(fmt.Errorf("crypt: expecting %q or %q", drive.CryptRekeyKey, drive.CryptStatusKey))
+	}
+
+	context, path := discoverContext(args[1:])
+	g := drive.New(context, &drive.Options{
+		Path: path,
+	})
+
+	switch args[0] {
+	case drive.CryptRekeyKey:
+		exitWithError(g.CryptRekey([]byte(*cmd.OldPassword), []byte(*cmd.NewPassword), *cmd.Rotate))
+	case drive.CryptStatusKey:
+		exitWithError(g.CryptStatus())
+	default:
+		exitWithError(fmt.Errorf("crypt: unknown %q, expecting %q or %q", args[0], drive.CryptRekeyKey, drive.CryptStatusKey))
+	}
+}
+
+type migrateCmd struct {
+	From   *string `json:"from"`
+	To     *string `json:"to"`
+	Quiet  *bool   `json:"quiet"`
+	Layout *bool   `json:"-"`
+}
+
+func (cmd *migrateCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.From = fs.String(drive.CLIOptionFrom, "", drive.DescMigrateFrom)
+	cmd.To = fs.String(drive.CLIOptionTo, "", drive.DescMigrateTo)
+	cmd.Quiet = fs.Bool(drive.QuietKey, false, "if set, do not log anything but errors")
+	cmd.Layout = fs.Bool(drive.CLIOptionLayout, false, drive.DescMigrateLayout)
+	return fs
+}
+
+func (cmd *migrateCmd) Run(args []string, definedFlags map[string]*flag.Flag) {
+	if *cmd.Layout {
+		_, from := definedFlags[drive.CLIOptionFrom]
+		_, to := definedFlags[drive.CLIOptionTo]
+		if from || to {
+			exitWithError(fmt.Errorf("migrate: -%s can't be combined with -%s or -%s", drive.CLIOptionLayout, drive.CLIOptionFrom, drive.CLIOptionTo))
+		}
+		context, _ := discoverContext(args)
+		exitWithError(drive.New(context, &drive.Options{
+			Quiet: *cmd.Quiet,
+		}).MigrateLayout())
+		return
+	}
+
+	if _, ok := definedFlags[drive.CLIOptionTo]; !ok {
+		exitWithError(fmt.Errorf("migrate: expecting -%s <account>, or -%s to upgrade the context's layout", drive.CLIOptionTo, drive.CLIOptionLayout))
+	}
+
+	context, path := discoverContext(args)
+	if _, ok := definedFlags[drive.CLIOptionFrom]; ok {
+		exitWithError(context.UseAccount(*cmd.From))
+	}
+
+	// The destination is the same context under another account.
+	var destContext *config.Context
+	var err error
+	if gdDir := gdDirFromEnv(); gdDir != "" {
+		destContext, err = config.DiscoverWithGDDir(gdDir, context.AbsPath)
+	} else {
+		destContext, err = config.DiscoverOuter(context.AbsPath, outerFromEnv())
+	}
+	exitWithError(err)
+	exitWithError(destContext.UseAccount(*cmd.To))
+
+	exitWithError(drive.New(context, &drive.Options{
+		Path:  path,
+		Quiet: *cmd.Quiet,
+	}).Migrate(destContext))
+}
+
+type configCmd struct {
+	Global *bool `json:"-"`
+	Repair *bool `json:"-"`
+}
+
+func (cmd *configCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.Global = fs.Bool(drive.CLIOptionGlobal, false, drive.DescConfigGlobal)
+	cmd.Repair = fs.Bool(drive.CLIOptionRepair, false, drive.DescConfigRepair)
+	return fs
+}
+
+func (cmd *configCmd) Run(args []string, definedFlags map[string]*flag.Flag) {
+	if len(args) >= 1 && args[0] == drive.ConfigValidateKey {
+		cmd.validate(args[1:])
+		return
+	}
+
+	usage := fmt.Errorf("config: expecting `%s <key>`, `%s <key> <value>` or `%s`", drive.ConfigGetKey, drive.ConfigSetKey, drive.ConfigValidateKey)
+	if len(args) < 2 {
+		exitWithError(usage)
+	}
+
+	argc := 2
+	if args[0] == drive.ConfigSetKey {
+		argc = 3
+	}
+	if len(args) < argc {
+		exitWithError(usage)
+	}
+
+	rcDir := ""
+	if !*cmd.Global {
+		context, _ := discoverContext(args[argc:])
+		rcDir = context.AbsPath
+	}
+
+	key := args[1]
+	switch args[0] {
+	case drive.ConfigGetKey:
+		value, set, err := drive.ConfigGet(rcDir, key)
+		exitWithError(err)
+		if !set {
+			exitWithError(fmt.Errorf("config: %s is not set", key))
+		}
+		fmt.Println(value)
+	case drive.ConfigSetKey:
+		exitWithError(drive.ConfigSet(rcDir, key, args[2]))
+	default:
+		exitWithError(usage)
+	}
+}
+
+// validate reports the problems of the context's files, which unlike
+// other commands it finds without reading them first.
+func (cmd *configCmd) validate(args []string) {
+	var context *config.Context
+	if gdDir := gdDirFromEnv(); gdDir != "" {
+		context = &config.Context{GDDir: gdDir}
+	} else {
+		var err error
+		context, err = config.Locate(getContextPath(args), outerFromEnv())
+		exitWithError(err)
+	}
+
+	problems := drive.ValidateConfig(context, *cmd.Repair)
+	unrepaired := 0
+	for _, problem := range problems {
+		fmt.Println(problem)
+		if !problem.Repaired {
+			unrepaired++
+		}
+	}
+
+	if unrepaired >= 1 {
+		exitWithError(fmt.Errorf("config: %d problems found", unrepaired))
+	}
+	if len(problems) < 1 {
+		fmt.Println("config: no problems found")
+	}
+}
+
 type quotaCmd struct{}
 
 func (cmd *quotaCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
@@ -320,28 +769,32 @@ func (cmd *urlCmd) Run(args []string, definedArgs map[string]*flag.Flag) {
 }
 
 type listCmd struct {
-	ById         *bool   `json:"by-id"`
-	Hidden       *bool   `json:"hidden"`
-	Recursive    *bool   `json:"recursive"`
-	Files        *bool   `json:"files"`
-	Directories  *bool   `json:"directories"`
-	Depth        *int    `json:"depth"`
-	PageSize     *int64  `json:"page-size"`
-	LongFmt      *bool   `json:"long"`
-	NoPrompt     *bool   `json:"no-prompt"`
-	Shared       *bool   `json:"shared"`
-	InTrash      *bool   `json:"trashed"`
-	Version      *bool   `json:"version"`
-	Matches      *bool   `json:"matches"`
-	Owners       *bool   `json:"owners"`
-	Quiet        *bool   `json:"quiet"`
-	SkipMimeKey  *string `json:"skip-mime"`
-	MatchMimeKey *string `json:"match-mime"`
-	ExactTitle   *string `json:"exact-title"`
-	MatchOwner   *string `json:"match-owner"`
-	ExactOwner   *string `json:"exact-owner"`
-	NotOwner     *string `json:"not-owner"`
-	Sort         *string `json:"sort"`
+	ById           *bool   `json:"by-id"`
+	Hidden         *bool   `json:"hidden"`
+	Recursive      *bool   `json:"recursive"`
+	Files          *bool   `json:"files"`
+	Directories    *bool   `json:"directories"`
+	Depth          *int    `json:"depth"`
+	PageSize       *int64  `json:"page-size"`
+	LongFmt        *bool   `json:"long"`
+	NoPrompt       *bool   `json:"no-prompt"`
+	Shared         *bool   `json:"shared"`
+	InTrash        *bool   `json:"trashed"`
+	Version        *bool   `json:"version"`
+	Matches        *bool   `json:"matches"`
+	Owners         *bool   `json:"owners"`
+	Quiet          *bool   `json:"quiet"`
+	SkipMimeKey    *string `json:"skip-mime"`
+	MatchMimeKey   *string `json:"match-mime"`
+	ExactTitle     *string `json:"exact-title"`
+	MatchOwner     *string `json:"match-owner"`
+	ExactOwner     *string `json:"exact-owner"`
+	NotOwner       *string `json:"not-owner"`
+	NamePattern    *string `json:"name"`
+	ModifiedAfter  *string `json:"modified-after"`
+	ModifiedBefore *string `json:"modified-before"`
+	Sort           *string `json:"sort"`
+	Collate        *string `json:"collate"`
 }
 
 func (cmd *listCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
@@ -358,6 +811,7 @@ func (cmd *listCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
 	cmd.Owners = fs.Bool("owners", false, "shows the owner names per file")
 	cmd.Recursive = fs.Bool(drive.RecursiveKey, false, "recursively list subdirectories")
 	cmd.Sort = fs.String(drive.SortKey, "", drive.DescSort)
+	cmd.Collate = fs.String(drive.CLIOptionCollate, "", drive.DescCollate)
 	cmd.Matches = fs.Bool(drive.MatchesKey, false, "list by prefix")
 	cmd.Quiet = fs.Bool(drive.QuietKey, false, "if set, do not log anything but errors")
 	cmd.SkipMimeKey = fs.String(drive.CLIOptionSkipMime, "", drive.DescSkipMime)
@@ -366,12 +820,15 @@ func (cmd *listCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
 	cmd.MatchOwner = fs.String(drive.CLIOptionMatchOwner, "", drive.DescMatchOwner)
 	cmd.ExactOwner = fs.String(drive.CLIOptionExactOwner, "", drive.DescExactOwner)
 	cmd.NotOwner = fs.String(drive.CLIOptionNotOwner, "", drive.DescNotOwner)
+	cmd.NamePattern = fs.String(drive.CLIOptionNamePattern, "", drive.DescNamePattern)
+	cmd.ModifiedAfter = fs.String(drive.CLIOptionModifiedAfter, "", drive.DescModifiedAfter)
+	cmd.ModifiedBefore = fs.String(drive.CLIOptionModifiedBefore, "", drive.DescModifiedBefore)
 	cmd.ById = fs.Bool(drive.CLIOptionId, false, "list by id instead of path")
 
 	return fs
 }
 
-func (lCmd *listCmd) _run(args []string, definedFlags map[string]*flag.Flag, diskUsageSubset bool) error {
+func (lCmd *listCmd) _run(args []string, definedFlags map[string]*flag.Flag, du *duCmd) error {
 	sources, context, path := preprocessArgsByToggle(args, (*lCmd.ById || *lCmd.Matches))
 	cmd := listCmd{}
 	df := defaultsFiller{
@@ -385,6 +842,8 @@ func (lCmd *listCmd) _run(args []string, definedFlags map[string]*flag.Flag, dis
 		exitWithError(err)
 	}
 
+	exitWithError(drive.ValidateCollation(*cmd.Collate))
+
 	typeMask := 0
 	if *cmd.Directories {
 		typeMask |= drive.Folder
@@ -405,7 +864,7 @@ func (lCmd *listCmd) _run(args []string, definedFlags map[string]*flag.Flag, dis
 		typeMask |= drive.InTrash
 	}
 
-	if diskUsageSubset {
+	if du != nil {
 		typeMask |= drive.DiskUsageOnly
 	}
 
@@ -419,13 +878,17 @@ func (lCmd *listCmd) _run(args []string, definedFlags map[string]*flag.Flag, dis
 	}
 
 	meta := map[string][]string{
-		drive.SortKey:         drive.NonEmptyTrimmedStrings(*cmd.Sort),
-		drive.SkipMimeKeyKey:  drive.NonEmptyTrimmedStrings(strings.Split(*cmd.SkipMimeKey, ",")...),
-		drive.MatchMimeKeyKey: drive.NonEmptyTrimmedStrings(strings.Split(*cmd.MatchMimeKey, ",")...),
-		drive.ExactTitleKey:   drive.NonEmptyTrimmedStrings(strings.Split(*cmd.ExactTitle, ",")...),
-		drive.MatchOwnerKey:   drive.NonEmptyTrimmedStrings(strings.Split(*cmd.MatchOwner, ",")...),
-		drive.ExactOwnerKey:   drive.NonEmptyTrimmedStrings(strings.Split(*cmd.ExactOwner, ",")...),
-		drive.NotOwnerKey:     drive.NonEmptyTrimmedStrings(strings.Split(*cmd.NotOwner, ",")...),
+		drive.SortKey:           drive.NonEmptyTrimmedStrings(*cmd.Sort),
+		drive.SkipMimeKeyKey:    drive.NonEmptyTrimmedStrings(strings.Split(*cmd.SkipMimeKey, ",")...),
+		drive.MatchMimeKeyKey:   drive.NonEmptyTrimmedStrings(strings.Split(*cmd.MatchMimeKey, ",")...),
+		drive.ExactTitleKey:     drive.NonEmptyTrimmedStrings(strings.Split(*cmd.ExactTitle, ",")...),
+		drive.MatchOwnerKey:     drive.NonEmptyTrimmedStrings(strings.Split(*cmd.MatchOwner, ",")...),
+		drive.ExactOwnerKey:     drive.NonEmptyTrimmedStrings(strings.Split(*cmd.ExactOwner, ",")...),
+		drive.NotOwnerKey:       drive.NonEmptyTrimmedStrings(strings.Split(*cmd.NotOwner, ",")...),
+		drive.NamePatternKey:    drive.NonEmptyTrimmedStrings(strings.Split(*cmd.NamePattern, ",")...),
+		drive.ModifiedAfterKey:  drive.NonEmptyTrimmedStrings(*cmd.ModifiedAfter),
+		drive.ModifiedBeforeKey: drive.NonEmptyTrimmedStrings(*cmd.ModifiedBefore),
+		drive.CollateKey:        drive.NonEmptyTrimmedStrings(*cmd.Collate),
 	}
 
 	opts := &drive.Options{
@@ -443,6 +906,16 @@ func (lCmd *listCmd) _run(args []string, definedFlags map[string]*flag.Flag, dis
 		Match:     *cmd.Matches,
 	}
 
+	if du != nil {
+		opts.ByType = *du.ByType
+		opts.Top = *du.Top
+		opts.Local = *du.Local
+		if opts.ByType && opts.Top > 0 {
+			return fmt.Errorf("-%s can't be combined with -%s", drive.CLIOptionByType, drive.CLIOptionTop)
+		}
+		opts.Depth = du.depth(depth, definedFlags)
+	}
+
 	if *cmd.Shared {
 		return drive.New(context, opts).ListShared()
 	} else if *cmd.Matches {
@@ -456,14 +929,37 @@ func (lCmd *listCmd) _run(args []string, definedFlags map[string]*flag.Flag, dis
 
 type duCmd struct {
 	listCmd
+	ByType *bool `json:"-"`
+	Top    *int  `json:"-"`
+	Local  *bool `json:"-"`
+}
+
+func (cmd *duCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.listCmd.Flags(fs)
+	cmd.ByType = fs.Bool(drive.CLIOptionByType, false, drive.DescByType)
+	cmd.Top = fs.Int(drive.CLIOptionTop, 0, drive.DescTop)
+	cmd.Local = fs.Bool(drive.CLIOptionLocal, false, drive.DescDuLocal)
+	return fs
+}
+
+// depth is how deep du traverses: -by-type and -top aggregate
+// the whole tree unless -depth is given.
+func (cmd *duCmd) depth(depth int, definedFlags map[string]*flag.Flag) int {
+	if !*cmd.ByType && *cmd.Top < 1 {
+		return depth
+	}
+	if _, ok := definedFlags[drive.DepthKey]; ok {
+		return depth
+	}
+	return drive.InfiniteDepth
 }
 
 func (lCmd *listCmd) Run(args []string, definedFlags map[string]*flag.Flag) {
-	exitWithError(lCmd._run(args, definedFlags, false))
+	exitWithError(lCmd._run(args, definedFlags, nil))
 }
 
 func (dCmd *duCmd) Run(args []string, definedFlags map[string]*flag.Flag) {
-	exitWithError(dCmd._run(args, definedFlags, true))
+	exitWithError(dCmd._run(args, definedFlags, dCmd))
 }
 
 type md5SumCmd struct {
@@ -594,6 +1090,7 @@ type indexCmd struct {
 	Prune             *bool   `json:"prune"`
 	AllOps            *bool   `json:"all-ops"`
 	Matches           *bool   `json:"matches"`
+	ImportMd5Manifest *string `json:"-"`
 }
 
 func (cmd *indexCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
@@ -612,11 +1109,23 @@ func (cmd *indexCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
 	cmd.Prune = fs.Bool(drive.CLIOptionPruneIndices, false, drive.DescPruneIndices)
 	cmd.AllOps = fs.Bool(drive.CLIOptionAllIndexOperations, false, drive.DescAllIndexOperations)
 	cmd.Matches = fs.Bool(drive.MatchesKey, false, "search by prefix")
+	cmd.ImportMd5Manifest = fs.String(drive.CLIOptionImportMd5Manifest, "", drive.DescImportMd5Manifest)
 
 	return fs
 }
 
 func (icmd *indexCmd) Run(args []string, definedFlags map[string]*flag.Flag) {
+	if len(args) >= 1 && args[0] == drive.ImportKey {
+		exitWithError(icmd.importChecksums(args[1:]))
+		return
+	}
+
+	// -import is an alias of `index import -md5`.
+	if *icmd.ImportMd5Manifest != "" {
+		exitWithError(icmd.importChecksumsFrom(*icmd.ImportMd5Manifest, args))
+		return
+	}
+
 	byId := *icmd.ById
 	byMatches := *icmd.Matches
 	sources, context, path := preprocessArgsByToggle(args, byMatches || byId)
@@ -672,10 +1181,43 @@ func (icmd *indexCmd) Run(args []string, definedFlags map[string]*flag.Flag) {
 	}
 }
 
+func (icmd *indexCmd) importChecksums(args []string) error {
+	md5Manifest, rest, err := parseIndexImportArgs(args)
+	if err != nil {
+		return err
+	}
+	return icmd.importChecksumsFrom(md5Manifest, rest)
+}
+
+func (icmd *indexCmd) importChecksumsFrom(md5Manifest string, args []string) error {
+	context, path := discoverContext(args)
+	return drive.New(context, &drive.Options{
+		Path:  path,
+		Quiet: *icmd.Quiet,
+	}).ImportChecksums(md5Manifest)
+}
+
+// parseIndexImportArgs parses the arguments of `index import`,
+// returning the manifest and the arguments left after the flags.
+func parseIndexImportArgs(args []string) (string, []string, error) {
+	fs := flag.NewFlagSet(drive.ImportKey, flag.ContinueOnError)
+	md5Manifest := fs.String(drive.CLIOptionMd5Manifest, "", drive.DescImportMd5Manifest)
+	if err := fs.Parse(args); err != nil {
+		return "", nil, err
+	}
+
+	if *md5Manifest == "" {
+		return "", nil, fmt.Errorf("%s: expecting -%s <manifest>", drive.ImportKey, drive.CLIOptionMd5Manifest)
+	}
+	return *md5Manifest, fs.Args(), nil
+}
+
 type pullCmd struct {
 	ById    *bool   `json:"by-id"`
 	Files   *bool   `json:"files"`
 	Piped   *bool   `json:"piped"`
+	Tar     *bool   `json:"tar"`
+	Zip     *bool   `json:"zip"`
 	Quiet   *bool   `json:"quiet"`
 	Force   *bool   `json:"force"`
 	Depth   *int    `json:"depth"`
@@ -693,8 +1235,13 @@ type pullCmd struct {
 	Recursive *bool `json:"recursive"`
 
 	AllStarred  *bool   `json:"all-starred"`
+	Virtual     *bool   `json:"-"`
 	FixClashes  *bool   `json:"fix-clashes"`
 	Directories *bool   `json:"directories"`
+	FilesFrom   *string `json:"-"`
+	IncludeFrom *string `json:"-"`
+	IncludeOnly *string `json:"-"`
+	Remote      *string `json:"-"`
 	ExportsDir  *string `json:"exports-dir"`
 	ExcludeOps  *string `json:"exclude-ops"`
 	SkipMimeKey *string `json:"skip-mime"`
@@ -703,6 +1250,7 @@ type pullCmd struct {
 	IgnoreConflict    *bool `json:"ignore-conflict"`
 	ExplicitlyExport  *bool `json:"explicitly-export"`
 	IgnoreNameClashes *bool `json:"ignore-name-clashes"`
+	AllowSyncOverlap  *bool `json:"allow-sync-overlap"`
 
 	DecryptionPassword *string `json:"decryption-password"`
 
@@ -710,10 +1258,31 @@ type pullCmd struct {
 	ExportsDumpToSameDirectory   *bool `json:"same-exports-dir"`
 
 	AllowURLLinkedFiles *bool `json:"desktop-links"`
+	Placeholders        *bool `json:"placeholders"`
+	Background          *bool `json:"background"`
+
+	MaxAPICalls  *int    `json:"max-api-calls"`
+	APIRate      *int    `json:"api-rate"`
+	MaxBytes     *string `json:"max-bytes"`
+	Strict       *bool   `json:"strict"`
+	Sparse       *bool   `json:"sparse"`
+	LargeFiles   *int    `json:"large-files"`
+	LargeSize    *string `json:"large-size"`
+	ConfirmLarge *bool   `json:"-"`
+	Order        *string `json:"order"`
+
+	Heartbeat     *string `json:"heartbeat"`
+	HeartbeatFile *string `json:"heartbeat-file"`
+	Shard         *string `json:"shard"`
+	StallTimeout  *string `json:"stall-timeout"`
+	Watch         *string `json:"-"`
+
+	ItemizeChanges *bool `json:"itemize-changes"`
 }
 
 func (cmd *pullCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
 	cmd.AllStarred = fs.Bool(drive.CLIOptionAllStarred, false, drive.DescAllStarred)
+	cmd.Virtual = fs.Bool(drive.CLIOptionVirtual, false, drive.DescVirtualStarred)
 	cmd.NoClobber = fs.Bool(drive.CLIOptionNoClobber, false, "prevents overwriting of old content")
 	cmd.Export = fs.String(
 		drive.ExportsKey, "", "comma separated list of formats to export your docs + sheets files")
@@ -731,6 +1300,8 @@ func (cmd *pullCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
 
 	cmd.Matches = fs.Bool(drive.MatchesKey, false, "search by prefix")
 	cmd.Piped = fs.Bool(drive.CLIOptionPiped, false, drive.DescPiped)
+	cmd.Tar = fs.Bool(drive.CLIOptionTar, false, drive.DescPullTar)
+	cmd.Zip = fs.Bool(drive.CLIOptionZip, false, drive.DescPullZip)
 	cmd.Quiet = fs.Bool(drive.QuietKey, false, "if set, do not log anything but errors")
 	cmd.ExcludeOps = fs.String(drive.CLIOptionExcludeOperations, "", drive.DescExcludeOps)
 	cmd.ById = fs.Bool(drive.CLIOptionId, false, "pull by id instead of path")
@@ -745,13 +1316,50 @@ func (cmd *pullCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
 	cmd.DecryptionPassword = fs.String(drive.CLIDecryptionPassword, "", drive.DescDecryptionPassword)
 
 	cmd.Files = fs.Bool(drive.CLIOptionFiles, false, "pull only files")
-	cmd.Directories = fs.Bool(drive.CLIOptionDirectories, false, "pull only directories")
+	cmd.Directories = fs.Bool(drive.CLIOptionDirectories, false, drive.DescPullDirectories)
+	fs.BoolVar(cmd.Directories, drive.CLIOptionDirsOnly, false, drive.DescDirsOnly)
+	cmd.FilesFrom = fs.String(drive.CLIOptionFilesFrom, "", drive.DescFilesFrom)
+	cmd.IncludeFrom = fs.String(drive.CLIOptionIncludeFrom, "", drive.DescIncludeFrom)
+	cmd.IncludeOnly = fs.String(drive.CLIOptionIncludeOnly, "", drive.DescIncludeOnly)
+	cmd.AllowSyncOverlap = fs.Bool(drive.CLIOptionAllowSyncOverlap, false, drive.DescAllowSyncOverlap)
+	cmd.Remote = fs.String(drive.CLIOptionNamedRemote, "", drive.DescNamedRemote)
 	cmd.AllowURLLinkedFiles = fs.Bool(drive.CLIOptionDesktopLinks, true, drive.DescAllowDesktopLinks)
+	cmd.Placeholders = fs.Bool(drive.CLIOptionPlaceholders, true, drive.DescPlaceholders)
+	cmd.Background = fs.Bool(drive.CLIOptionBackground, false, drive.DescBackground)
+	cmd.MaxAPICalls = fs.Int(drive.CLIOptionMaxAPICalls, 0, drive.DescMaxAPICalls)
+	cmd.APIRate = fs.Int(drive.CLIOptionAPIRate, 0, drive.DescAPIRate)
+	cmd.MaxBytes = fs.String(drive.CLIOptionMaxBytes, "", drive.DescMaxBytes)
+	cmd.Strict = fs.Bool(drive.CLIOptionStrict, false, drive.DescStrict)
+	cmd.Sparse = fs.Bool(drive.CLIOptionSparse, false, drive.DescSparse)
+	cmd.LargeFiles = fs.Int(drive.CLIOptionLargeFiles, drive.DefaultLargePullFiles, drive.DescLargeFiles)
+	cmd.LargeSize = fs.String(drive.CLIOptionLargeSize, drive.DefaultLargePullSize, drive.DescLargeSize)
+	cmd.ConfirmLarge = fs.Bool(drive.CLIOptionConfirmLarge, false, drive.DescConfirmLarge)
+	cmd.Order = fs.String(drive.CLIOptionOrder, "", drive.DescOrder)
+	cmd.Heartbeat = fs.String(drive.CLIOptionHeartbeat, "", drive.DescHeartbeat)
+	cmd.HeartbeatFile = fs.String(drive.CLIOptionHeartbeatFile, "", drive.DescHeartbeatFile)
+	cmd.Shard = fs.String(drive.CLIOptionShard, "", drive.DescShard)
+	cmd.StallTimeout = fs.String(drive.CLIOptionStallTimeout, "", drive.DescStallTimeout)
+	cmd.Watch = fs.String(drive.CLIOptionWatch, "", drive.DescWatch)
+	cmd.ItemizeChanges = fs.Bool(drive.CLIOptionItemizeChanges, false, drive.DescItemizeChanges)
 
 	return fs
 }
 
 func (pCmd *pullCmd) Run(args []string, definedFlags map[string]*flag.Flag) {
+	useNamedRemote(*pCmd.Remote)
+
+	zipPath := ""
+	if *pCmd.Zip {
+		if len(args) < 2 {
+			exitWithError(fmt.Errorf("expecting <remote paths...> <zip path>"))
+		}
+		absZipPath, err := filepath.Abs(args[len(args)-1])
+		exitWithError(err)
+		zipPath, args = absZipPath, args[:len(args)-1]
+	}
+
+	args = appendFilesFrom(args, *pCmd.FilesFrom)
+
 	sources, context, path := preprocessArgsByToggle(args, (*pCmd.ById || *pCmd.Matches || *pCmd.Starred))
 	cmd := pullCmd{}
 	df := defaultsFiller{
@@ -787,10 +1395,9 @@ func (pCmd *pullCmd) Run(args []string, definedFlags map[string]*flag.Flag) {
 	if cmd.DecryptionPassword != nil {
 		passStr := *(cmd.DecryptionPassword)
 		if passStr != "" {
-			passwordAsBytes := []byte(passStr)
-			decryptFn = func(r io.Reader) (io.ReadCloser, error) {
-				return dcrypto.NewDecrypter(r, passwordAsBytes)
-			}
+			var err error
+			decryptFn, err = drive.New(context, &drive.Options{}).CryptDecrypter([]byte(passStr))
+			exitWithError(err)
 		}
 	}
 
@@ -809,6 +1416,31 @@ func (pCmd *pullCmd) Run(args []string, definedFlags map[string]*flag.Flag) {
 		exitWithError(fmt.Errorf("Unknown fix mode: %s", *cmd.FixMode))
 	}
 
+	maxBytes, err := drive.ParseByteSize(*cmd.MaxBytes)
+	exitWithError(err)
+
+	largeBytes, err := drive.ParseByteSize(*cmd.LargeSize)
+	exitWithError(err)
+
+	pathFilter, err := readIncludeFrom(*pCmd.IncludeFrom)
+	exitWithError(err)
+
+	includeOnly, err := parseIncludeOnly(*pCmd.IncludeOnly)
+	exitWithError(err)
+	exitWithError(drive.ValidateTransferOrder(*cmd.Order))
+
+	heartbeat, err := drive.ParseHeartbeatInterval(*cmd.Heartbeat)
+	exitWithError(err)
+
+	stallTimeout, err := drive.ParseStallTimeout(*cmd.StallTimeout)
+	exitWithError(err)
+
+	watch, err := drive.ParseWatchInterval(*pCmd.Watch)
+	exitWithError(err)
+	if watch > 0 && (*cmd.Matches || *cmd.Starred || *cmd.Tar || *cmd.Zip || *cmd.Piped || *cmd.ById) {
+		exitWithError(fmt.Errorf("-%s only watches pulls by path", drive.CLIOptionWatch))
+	}
+
 	options := &drive.Options{
 		Path:       path,
 		Sources:    sources,
@@ -817,11 +1449,11 @@ func (pCmd *pullCmd) Run(args []string, definedFlags map[string]*flag.Flag) {
 
 		Force:      *cmd.Force,
 		Hidden:     *cmd.Hidden,
-		NoPrompt:   *cmd.NoPrompt,
+		NoPrompt:   *cmd.NoPrompt || watch > 0, // Nobody is around to answer while watching
 		NoClobber:  *cmd.NoClobber,
 		Recursive:  *cmd.Recursive,
 		Piped:      *cmd.Piped,
-		Quiet:      *cmd.Quiet,
+		Quiet:      *cmd.Quiet || *cmd.Tar, // Only errors may share stdout with the archive
 		Meta:       &meta,
 		Verbose:    *cmd.Verbose,
 		Depth:      *cmd.Depth,
@@ -840,8 +1472,28 @@ func (pCmd *pullCmd) Run(args []string, definedFlags map[string]*flag.Flag) {
 		IgnoreNameClashes: *cmd.IgnoreNameClashes,
 
 		AllowURLLinkedFiles:          *cmd.AllowURLLinkedFiles,
+		Placeholders:                 *cmd.Placeholders,
+		Background:                   *cmd.Background,
 		ExportsDumpToSameDirectory:   *cmd.ExportsDumpToSameDirectory,
 		ExponentialBackoffRetryCount: retryCount,
+		MaxAPICalls:                  int64(*cmd.MaxAPICalls),
+		APIRate:                      *cmd.APIRate,
+		MaxBytes:                     maxBytes,
+		Strict:                       *cmd.Strict,
+		Sparse:                       *cmd.Sparse,
+		VirtualStarred:               *pCmd.Virtual,
+		LargePullFiles:               *cmd.LargeFiles,
+		LargePullBytes:               largeBytes,
+		ConfirmLarge:                 *pCmd.ConfirmLarge,
+		TransferOrder:                *cmd.Order,
+		PathFilter:                   pathFilter,
+		IncludeOnly:                  includeOnly,
+		AllowSyncOverlap:             *cmd.AllowSyncOverlap,
+		Heartbeat:                    heartbeat,
+		HeartbeatFile:                *cmd.HeartbeatFile,
+		Shards:                       drive.NonEmptyTrimmedStrings(strings.Split(*cmd.Shard, ",")...),
+		StallTimeout:                 stallTimeout,
+		ItemizeChanges:               *cmd.ItemizeChanges,
 	}
 
 	if *cmd.Matches || *cmd.Starred {
@@ -850,10 +1502,16 @@ func (pCmd *pullCmd) Run(args []string, definedFlags map[string]*flag.Flag) {
 		} else {
 			exitWithError(drive.New(context, options).PullMatchLike())
 		}
+	} else if *cmd.Tar {
+		exitWithError(drive.New(context, options).PullTar(*cmd.ById))
+	} else if *cmd.Zip {
+		exitWithError(drive.New(context, options).PullZip(zipPath, *cmd.ById))
 	} else if *cmd.Piped {
 		exitWithError(drive.New(context, options).PullPiped(*cmd.ById))
 	} else if *cmd.ById {
 		exitWithError(drive.New(context, options).PullById())
+	} else if watch > 0 {
+		exitWithError(drive.New(context, options).WatchPull(watch))
 	} else {
 		exitWithError(drive.New(context, options).Pull())
 	}
@@ -888,10 +1546,40 @@ type pushCmd struct {
 	ExponentialBackoffRetryCount *int    `json:"retry-count"`
 	EncryptionPassword           *string `json:"encryption-password"`
 
-	Files           *bool `json:"files"`
-	Directories     *bool `json:"directories"`
-	UploadChunkSize *int  `json:"upload-chunk-size"`
-	UploadRateLimit *int  `json:"upload-rate-limit"`
+	Files           *bool   `json:"files"`
+	Directories     *bool   `json:"directories"`
+	FilesFrom       *string `json:"-"`
+	IncludeFrom     *string `json:"-"`
+	IncludeOnly     *string `json:"-"`
+	UploadChunkSize *int    `json:"upload-chunk-size"`
+	UploadRateLimit *int    `json:"upload-rate-limit"`
+
+	UploadRateSchedule *string `json:"upload-rate-schedule"`
+	AllowSyncOverlap   *bool   `json:"allow-sync-overlap"`
+	Background         *bool   `json:"background"`
+	Queue              *bool   `json:"-"`
+	As                 *string `json:"-"`
+	MediaPath          *string `json:"-"`
+	Remote             *string `json:"-"`
+	DryRun             *bool   `json:"-"`
+	Against            *string `json:"-"`
+	Snapshot           *string `json:"-"`
+
+	MaxAPICalls *int    `json:"max-api-calls"`
+	APIRate     *int    `json:"api-rate"`
+	MaxBytes    *string `json:"max-bytes"`
+	SplitSize   *string `json:"split-size"`
+	Strict      *bool   `json:"strict"`
+	Order       *string `json:"order"`
+
+	Heartbeat      *string `json:"heartbeat"`
+	HeartbeatFile  *string `json:"heartbeat-file"`
+	SnapshotToTemp *bool   `json:"snapshot-to-temp"`
+	LockedRetries  *int    `json:"locked-retries"`
+	ShadowCopy     *bool   `json:"shadow-copy"`
+	Shard          *string `json:"shard"`
+	StallTimeout   *string `json:"stall-timeout"`
+	ItemizeChanges *bool   `json:"itemize-changes"`
 }
 
 func (cmd *pushCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
@@ -919,19 +1607,64 @@ func (cmd *pushCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
 	cmd.ExponentialBackoffRetryCount = fs.Int(drive.CLIOptionRetryCount, drive.MaxFailedRetryCount, drive.DescExponentialBackoffRetryCount)
 	cmd.EncryptionPassword = fs.String(drive.CLIEncryptionPassword, "", drive.DescEncryptionPassword)
 	cmd.Files = fs.Bool(drive.CLIOptionFiles, false, "push only files")
-	cmd.Directories = fs.Bool(drive.CLIOptionDirectories, false, "push only directories")
+	cmd.Directories = fs.Bool(drive.CLIOptionDirectories, false, drive.DescPushDirectories)
+	fs.BoolVar(cmd.Directories, drive.CLIOptionDirsOnly, false, drive.DescDirsOnly)
+	cmd.FilesFrom = fs.String(drive.CLIOptionFilesFrom, "", drive.DescFilesFrom)
+	cmd.IncludeFrom = fs.String(drive.CLIOptionIncludeFrom, "", drive.DescIncludeFrom)
+	cmd.IncludeOnly = fs.String(drive.CLIOptionIncludeOnly, "", drive.DescIncludeOnly)
+	cmd.AllowSyncOverlap = fs.Bool(drive.CLIOptionAllowSyncOverlap, false, drive.DescAllowSyncOverlap)
+	cmd.Remote = fs.String(drive.CLIOptionNamedRemote, "", drive.DescNamedRemote)
 	cmd.UploadChunkSize = fs.Int(drive.CLIOptionUploadChunkSize, 0, "specifies the size of each data chunk to be uploaded. Only set it if you want a custom chunk size. Otherwise the default value of googleapi.DefaultUploadChunkSize ie 8MiB will be used. However it must be at least googleapi.MinUploadChunkSize ie 256KiB. See https://godoc.org/google.golang.org/api/googleapi#pkg-constants. If `-upload-chunk-size` is not set yet `-upload-rate-limit` is, `-upload-chunk-size` will be the same as `-upload-rate-limit`.")
 	cmd.UploadRateLimit = fs.Int(drive.CLIOptionUploadRateLimit, 0, "Limit the upload bandwidth to n KiB/s, default is unlimited.")
+	cmd.UploadRateSchedule = fs.String(drive.CLIOptionUploadRateSchedule, "", drive.DescUploadRateSchedule)
+	cmd.Background = fs.Bool(drive.CLIOptionBackground, false, drive.DescBackground)
+	cmd.MaxAPICalls = fs.Int(drive.CLIOptionMaxAPICalls, 0, drive.DescMaxAPICalls)
+	cmd.APIRate = fs.Int(drive.CLIOptionAPIRate, 0, drive.DescAPIRate)
+	cmd.MaxBytes = fs.String(drive.CLIOptionMaxBytes, "", drive.DescMaxBytes)
+	cmd.SplitSize = fs.String(drive.CLIOptionSplitSize, "", drive.DescSplitSize)
+	cmd.Strict = fs.Bool(drive.CLIOptionStrict, false, drive.DescStrict)
+	cmd.Order = fs.String(drive.CLIOptionOrder, "", drive.DescOrder)
+	cmd.Queue = fs.Bool(drive.CLIOptionQueue, false, drive.DescPushQueue)
+	cmd.As = fs.String(drive.CLIOptionAs, "", drive.DescPushAs)
+	cmd.MediaPath = fs.String(drive.CLIOptionMediaPath, "", drive.DescMediaPath)
+	cmd.DryRun = fs.Bool(drive.CLIOptionDryRun, false, drive.DescDryRun)
+	cmd.Against = fs.String(drive.CLIOptionAgainst, "", drive.DescAgainst)
+	cmd.Snapshot = fs.String(drive.CLIOptionSnapshot, "", drive.DescSnapshot)
+	cmd.Heartbeat = fs.String(drive.CLIOptionHeartbeat, "", drive.DescHeartbeat)
+	cmd.HeartbeatFile = fs.String(drive.CLIOptionHeartbeatFile, "", drive.DescHeartbeatFile)
+	cmd.SnapshotToTemp = fs.Bool(drive.CLIOptionSnapshotToTemp, false, drive.DescSnapshotToTemp)
+	cmd.LockedRetries = fs.Int(drive.CLIOptionLockedRetries, drive.DefaultLockedRetries, drive.DescLockedRetries)
+	cmd.ShadowCopy = fs.Bool(drive.CLIOptionShadowCopy, false, drive.DescShadowCopy)
+	cmd.Shard = fs.String(drive.CLIOptionShard, "", drive.DescShard)
+	cmd.StallTimeout = fs.String(drive.CLIOptionStallTimeout, "", drive.DescStallTimeout)
+	cmd.ItemizeChanges = fs.Bool(drive.CLIOptionItemizeChanges, false, drive.DescItemizeChanges)
 
 	return fs
 }
 
 func (cmd *pushCmd) Run(args []string, definedFlags map[string]*flag.Flag) {
+	useNamedRemote(*cmd.Remote)
+
+	if *cmd.MediaPath != "" {
+		exitWithError(cmd.pushMedia(args, definedFlags))
+		return
+	}
+
 	if *cmd.MountedPush {
 		exitWithError(cmd.pushMounted(args, definedFlags))
 		return
 	}
 
+	if *cmd.As != "" {
+		exitWithError(cmd.pushAs(args, definedFlags))
+		return
+	}
+
+	if *cmd.FilesFrom == "-" && *cmd.Piped {
+		exitWithError(fmt.Errorf("-%s cannot read from stdin while piping content in", drive.CLIOptionFilesFrom))
+	}
+	args = appendFilesFrom(args, *cmd.FilesFrom)
+
 	sources, context, path := preprocessArgs(args)
 
 	options, err := cmd.createPushOptions(context.AbsPathOf(path), definedFlags)
@@ -942,35 +1675,81 @@ func (cmd *pushCmd) Run(args []string, definedFlags map[string]*flag.Flag) {
 	options.Path = path
 	options.Sources = sources
 
-	if *cmd.Piped {
+	if options.DryRun && (*cmd.Queue || *cmd.Piped) {
+		exitWithError(fmt.Errorf("-%s can't be combined with -%s or -%s", drive.CLIOptionDryRun, drive.CLIOptionQueue, drive.CLIOptionPiped))
+	}
+
+	if *cmd.Queue {
+		if *cmd.Piped || options.Encrypter != nil {
+			exitWithError(fmt.Errorf("-%s can't be combined with -%s or -%s", drive.CLIOptionQueue, drive.CLIOptionPiped, drive.CLIEncryptionPassword))
+		}
+		exitWithError(drive.New(context, options).QueuePush())
+	} else if *cmd.Piped {
 		exitWithError(drive.New(context, options).PushPiped())
 	} else {
 		exitWithError(drive.New(context, options).Push())
 	}
 }
 
-type qrLinkCmd struct {
-	Address *string `json:"address"`
-	ById    *bool   `json:"by-id"`
-	Verbose *bool   `json:"verbose"`
+type backupCmd struct {
+	pushCmd
+	Dest        *string `json:"-"`
+	KeepDaily   *int    `json:"-"`
+	KeepWeekly  *int    `json:"-"`
+	KeepMonthly *int    `json:"-"`
 }
 
-func (cmd *qrLinkCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
-	cmd.Address = fs.String(drive.AddressKey, drive.DefaultQRShareServer, "address on which the QR code generator is running")
-	cmd.ById = fs.Bool(drive.CLIOptionId, false, "share by id instead of path")
-	cmd.Verbose = fs.Bool(drive.CLIOptionVerboseKey, true, drive.DescVerbose)
+func (cmd *backupCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.pushCmd.Flags(fs)
+	cmd.Dest = fs.String(drive.CLIOptionBackupDestination, "", drive.DescBackupDestination)
+	cmd.KeepDaily = fs.Int(drive.CLIOptionKeepDaily, 0, drive.DescKeepDaily)
+	cmd.KeepWeekly = fs.Int(drive.CLIOptionKeepWeekly, 0, drive.DescKeepWeekly)
+	cmd.KeepMonthly = fs.Int(drive.CLIOptionKeepMonthly, 0, drive.DescKeepMonthly)
 	return fs
 }
 
-func (qCmd *qrLinkCmd) Run(args []string, definedFlags map[string]*flag.Flag) {
-	sources, context, path := preprocessArgsByToggle(args, *qCmd.ById)
+func (cmd *backupCmd) Run(args []string, definedFlags map[string]*flag.Flag) {
+	sources, context, path := preprocessArgs(args)
 
-	cmd := qrLinkCmd{}
-	df := defaultsFiller{
-		command: drive.QRLinkKey,
-		from:    *qCmd, to: &cmd,
-		rcSourcePath: path,
-		definedFlags: definedFlags,
+	options, err := cmd.createPushOptions(context.AbsPathOf(path), definedFlags)
+	if err != nil {
+		exitWithError(err)
+	}
+
+	options.Path = path
+	options.Sources = sources
+	options.Destination = *cmd.Dest
+	options.Retention = &drive.RetentionPolicy{
+		Daily:   *cmd.KeepDaily,
+		Weekly:  *cmd.KeepWeekly,
+		Monthly: *cmd.KeepMonthly,
+	}
+
+	exitWithError(drive.New(context, options).Backup())
+}
+
+type qrLinkCmd struct {
+	Address *string `json:"address"`
+	ById    *bool   `json:"by-id"`
+	Verbose *bool   `json:"verbose"`
+}
+
+func (cmd *qrLinkCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.Address = fs.String(drive.AddressKey, drive.DefaultQRShareServer, "address on which the QR code generator is running")
+	cmd.ById = fs.Bool(drive.CLIOptionId, false, "share by id instead of path")
+	cmd.Verbose = fs.Bool(drive.CLIOptionVerboseKey, true, drive.DescVerbose)
+	return fs
+}
+
+func (qCmd *qrLinkCmd) Run(args []string, definedFlags map[string]*flag.Flag) {
+	sources, context, path := preprocessArgsByToggle(args, *qCmd.ById)
+
+	cmd := qrLinkCmd{}
+	df := defaultsFiller{
+		command: drive.QRLinkKey,
+		from:    *qCmd, to: &cmd,
+		rcSourcePath: path,
+		definedFlags: definedFlags,
 	}
 
 	if err := fillWithDefaults(df); err != nil {
@@ -1068,6 +1847,44 @@ func (tcmd *touchCmd) Run(args []string, definedFlags map[string]*flag.Flag) {
 	}
 }
 
+// readIncludeFrom reads the filter in includeFrom, if set.
+func readIncludeFrom(includeFrom string) (*drive.PathFilter, error) {
+	if includeFrom == "" {
+		return nil, nil
+	}
+	pathFilter, err := drive.ReadIncludeFrom(includeFrom)
+	if err != nil {
+		return nil, fmt.Errorf("-%s: %v", drive.CLIOptionIncludeFrom, err)
+	}
+	return pathFilter, nil
+}
+
+// parseIncludeOnly parses the comma separated patterns of includeOnly, if set.
+func parseIncludeOnly(includeOnly string) (*drive.IncludeOnly, error) {
+	if includeOnly == "" {
+		return nil, nil
+	}
+	inc, err := drive.ParseIncludeOnly(strings.Split(includeOnly, ",")...)
+	if err != nil {
+		return nil, fmt.Errorf("-%s: %v", drive.CLIOptionIncludeOnly, err)
+	}
+	return inc, nil
+}
+
+// appendFilesFrom appends the paths listed in filesFrom, if set, to args.
+func appendFilesFrom(args []string, filesFrom string) []string {
+	if filesFrom == "" {
+		return args
+	}
+
+	paths, err := drive.ReadFilesFrom(filesFrom)
+	exitWithError(err)
+	if len(paths) < 1 {
+		exitWithError(fmt.Errorf("-%s: %q lists no paths", drive.CLIOptionFilesFrom, filesFrom))
+	}
+	return append(args, paths...)
+}
+
 func exitIfIllogicalFileAndFolder(mask int) {
 	fileAndFolder := drive.NonFolder | drive.Folder
 	if (mask & fileAndFolder) == fileAndFolder {
@@ -1125,10 +1942,9 @@ func (pCmd *pushCmd) createPushOptions(absEntryPath string, definedFlags map[str
 	if cmd.EncryptionPassword != nil {
 		passStr := *(cmd.EncryptionPassword)
 		if passStr != "" {
-			passwordAsBytes := []byte(passStr)
-			encryptFn = func(r io.Reader) (io.Reader, error) {
-				return dcrypto.NewEncrypter(r, passwordAsBytes)
-			}
+			var err error
+			encryptFn, err = drive.New(context, &drive.Options{}).CryptEncrypter([]byte(passStr))
+			exitWithError(err)
 		}
 	}
 
@@ -1137,6 +1953,54 @@ func (pCmd *pushCmd) createPushOptions(absEntryPath string, definedFlags map[str
 		exitWithError(fmt.Errorf("Unknown fix mode: %s", *cmd.FixMode))
 	}
 
+	var rateSchedule *drive.RateSchedule
+	if spec := *cmd.UploadRateSchedule; spec != "" {
+		schedule, err := drive.ParseRateSchedule(spec, *cmd.UploadRateLimit)
+		if err != nil {
+			return nil, err
+		}
+		rateSchedule = schedule
+	}
+
+	maxBytes, err := drive.ParseByteSize(*cmd.MaxBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	splitSize, err := drive.ParseByteSize(*cmd.SplitSize)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := drive.ValidateTransferOrder(*cmd.Order); err != nil {
+		return nil, err
+	}
+
+	pathFilter, err := readIncludeFrom(*pCmd.IncludeFrom)
+	if err != nil {
+		return nil, err
+	}
+
+	includeOnly, err := parseIncludeOnly(*pCmd.IncludeOnly)
+	if err != nil {
+		return nil, err
+	}
+
+	heartbeat, err := drive.ParseHeartbeatInterval(*cmd.Heartbeat)
+	if err != nil {
+		return nil, err
+	}
+
+	stallTimeout, err := drive.ParseStallTimeout(*cmd.StallTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	destination, err := drive.ExpandRemotePath(*cmd.Destination, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
 	opts := &drive.Options{
 		Force:                        *cmd.Force,
 		Hidden:                       *cmd.Hidden,
@@ -1154,17 +2018,108 @@ func (pCmd *pushCmd) createPushOptions(absEntryPath string, definedFlags map[str
 		Verbose:                      *cmd.Verbose,
 		Depth:                        *cmd.Depth,
 		FixClashes:                   *cmd.FixClashes,
-		Destination:                  *cmd.Destination,
+		Destination:                  destination,
 		Encrypter:                    encryptFn,
 		ExponentialBackoffRetryCount: retryCount,
 		UploadChunkSize:              *cmd.UploadChunkSize,
 		UploadRateLimit:              *cmd.UploadRateLimit,
+		UploadRateSchedule:           rateSchedule,
+		Background:                   *cmd.Background,
 		FixClashesMode:               fixMode,
+		MaxAPICalls:                  int64(*cmd.MaxAPICalls),
+		APIRate:                      *cmd.APIRate,
+		MaxBytes:                     maxBytes,
+		Strict:                       *cmd.Strict,
+		SplitSize:                    splitSize,
+		TransferOrder:                *cmd.Order,
+		PathFilter:                   pathFilter,
+		IncludeOnly:                  includeOnly,
+		AllowSyncOverlap:             *cmd.AllowSyncOverlap,
+		DryRun:                       *pCmd.DryRun,
+		Against:                      *pCmd.Against,
+		Snapshot:                     *pCmd.Snapshot,
+		Heartbeat:                    heartbeat,
+		HeartbeatFile:                *cmd.HeartbeatFile,
+		SnapshotToTemp:               *cmd.SnapshotToTemp,
+		LockedRetries:                *cmd.LockedRetries,
+		ShadowCopy:                   *cmd.ShadowCopy,
+		Shards:                       drive.NonEmptyTrimmedStrings(strings.Split(*cmd.Shard, ",")...),
+		StallTimeout:                 stallTimeout,
+		ItemizeChanges:               *cmd.ItemizeChanges,
+	}
+
+	if opts.Against != "" && !opts.DryRun {
+		return nil, fmt.Errorf("-%s requires -%s", drive.CLIOptionAgainst, drive.CLIOptionDryRun)
 	}
 
 	return opts, nil
 }
 
+func (cmd *pushCmd) pushAs(args []string, definedFlags map[string]*flag.Flag) error {
+	if *cmd.Queue {
+		return fmt.Errorf("-%s can't be combined with -%s", drive.CLIOptionQueue, drive.CLIOptionAs)
+	}
+	if *cmd.Piped {
+		if len(args) > 0 {
+			return fmt.Errorf("-%s with -%s reads from stdin, yet got paths %v", drive.CLIOptionAs, drive.CLIOptionPiped, args)
+		}
+	} else if len(args) != 1 {
+		return fmt.Errorf("-%s expects exactly one local file", drive.CLIOptionAs)
+	}
+
+	target, err := drive.ExpandRemotePath(*cmd.As, time.Now())
+	if err != nil {
+		return err
+	}
+
+	sources, context, path := preprocessArgs([]string{target})
+	options, err := cmd.createPushOptions(context.AbsPathOf(path), definedFlags)
+	if err != nil {
+		return err
+	}
+
+	options.Path = path
+	options.Sources = sources
+
+	g := drive.New(context, options)
+	if *cmd.Piped {
+		return g.PushPiped()
+	}
+	return g.PushAs(args[0], sources[0])
+}
+
+// pushMedia pushes the media files at or under args into the
+// remote folders that -media-path expands to for each of them.
+func (cmd *pushCmd) pushMedia(args []string, definedFlags map[string]*flag.Flag) error {
+	if *cmd.MountedPush || *cmd.Queue || *cmd.Piped || *cmd.As != "" || *cmd.DryRun {
+		return fmt.Errorf("-%s can't be combined with -m, -%s, -%s, -%s or -%s",
+			drive.CLIOptionMediaPath, drive.CLIOptionQueue, drive.CLIOptionPiped, drive.CLIOptionAs, drive.CLIOptionDryRun)
+	}
+	if len(args) < 1 {
+		return fmt.Errorf("-%s expects the local media files or directories to push", drive.CLIOptionMediaPath)
+	}
+
+	var fsPaths []string
+	for _, arg := range args {
+		fsPath, err := filepath.Abs(arg)
+		if err != nil {
+			return err
+		}
+		fsPaths = append(fsPaths, fsPath)
+	}
+
+	// The media needn't be within the context e.g that of a camera's
+	// card, so the context is that of the working directory.
+	context, path := discoverContext(nil)
+	options, err := cmd.createPushOptions(context.AbsPathOf(path), definedFlags)
+	if err != nil {
+		return err
+	}
+	options.Path = path
+
+	return drive.New(context, options).PushMedia(*cmd.MediaPath, fsPaths)
+}
+
 func (cmd *pushCmd) pushMounted(args []string, definedFlags map[string]*flag.Flag) error {
 	argc := len(args)
 
@@ -1216,6 +2171,7 @@ type aboutCmd struct {
 	Filesize *bool `json:"filesize"`
 	Quiet    *bool `json:"quiet"`
 	Quota    *bool `json:"quota"`
+	Auth     *bool `json:"-"`
 }
 
 func (cmd *aboutCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
@@ -1223,6 +2179,7 @@ func (cmd *aboutCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
 	cmd.Filesize = fs.Bool("filesize", false, "prints out information about file sizes e.g the max upload size for a specific file size")
 	cmd.Quiet = fs.Bool(drive.QuietKey, false, "if set, do not log anything but errors")
 	cmd.Quota = fs.Bool("quota", false, "prints out quota information for this drive")
+	cmd.Auth = fs.Bool(drive.CLIOptionAuth, false, drive.DescAboutAuth)
 	return fs
 }
 
@@ -1252,6 +2209,9 @@ func (acmd *aboutCmd) Run(args []string, definedFlags map[string]*flag.Flag) {
 	if *cmd.Filesize {
 		mask |= drive.AboutFileSizes
 	}
+	if *acmd.Auth {
+		mask |= drive.AboutAuth
+	}
 
 	if mask == drive.AboutNone { // No option set
 		mask = drive.AboutQuota | drive.AboutFeatures | drive.AboutFileSizes
@@ -1273,6 +2233,7 @@ type diffCmd struct {
 	Unified           *bool `json:"unified"`
 	BaseLocal         *bool `json:"base-local"`
 	SkipContentCheck  *bool `json:"skip-content-check"`
+	Revisions         *bool `json:"-"`
 }
 
 func (cmd *diffCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
@@ -1286,11 +2247,26 @@ func (cmd *diffCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
 	cmd.Unified = fs.Bool(drive.CLIOptionUnifiedShortKey, true, drive.DescUnifiedDiff)
 	cmd.BaseLocal = fs.Bool(drive.CLIOptionDiffBaseLocal, true, drive.DescDiffBaseLocal)
 	cmd.SkipContentCheck = fs.Bool(drive.SkipContentCheckKey, false, drive.DescSkipContentCheck)
+	cmd.Revisions = fs.Bool(drive.CLIOptionRevisions, false, drive.DescDiffRevisions)
 
 	return fs
 }
 
 func (cmd *diffCmd) Run(args []string, definedFlags map[string]*flag.Flag) {
+	if *cmd.Revisions {
+		if len(args) < 1 {
+			exitWithError(fmt.Errorf("diff: expecting <path> [<revision> <revision>]"))
+		}
+
+		sources, context, path := preprocessArgs(args[:1])
+		exitWithError(drive.New(context, &drive.Options{
+			Path:    path,
+			Sources: sources,
+			Quiet:   *cmd.Quiet,
+		}).DiffRevisions(args[1:]))
+		return
+	}
+
 	sources, context, path := preprocessArgs(args)
 
 	mask := drive.DiffNone
@@ -1526,6 +2502,186 @@ func (cmd *copyCmd) Run(args []string, definedFlags map[string]*flag.Flag) {
 	}).Copy(*cmd.ById))
 }
 
+type cmpCmd struct {
+	Hidden *bool `json:"hidden"`
+}
+
+func (cmd *cmpCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.Hidden = fs.Bool(drive.HiddenKey, false, "compare hidden paths")
+	return fs
+}
+
+func (cmd *cmpCmd) Run(args []string, definedFlags map[string]*flag.Flag) {
+	if len(args) != 2 {
+		exitWithError(fmt.Errorf("cmp: expecting <remoteA> <remoteB>"))
+	}
+
+	sources, context, path := preprocessArgs(args)
+
+	exitWithError(drive.New(context, &drive.Options{
+		Path:    path,
+		Sources: sources,
+		Hidden:  *cmd.Hidden,
+	}).Cmp())
+}
+
+type checksumCmd struct {
+	Remote *bool `json:"remote"`
+	Hidden *bool `json:"hidden"`
+}
+
+func (cmd *checksumCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.Remote = fs.Bool(drive.CLIOptionRemote, false, drive.DescChecksumRemote)
+	cmd.Hidden = fs.Bool(drive.HiddenKey, false, "checksum hidden paths")
+	return fs
+}
+
+func (cmd *checksumCmd) Run(args []string, definedFlags map[string]*flag.Flag) {
+	sources, context, path := preprocessArgs(args)
+
+	exitWithError(drive.New(context, &drive.Options{
+		Path:    path,
+		Sources: sources,
+		Hidden:  *cmd.Hidden,
+	}).Checksum(*cmd.Remote))
+}
+
+type verifyCmd struct {
+	Sample *string `json:"sample"`
+	Hidden *bool   `json:"hidden"`
+}
+
+func (cmd *verifyCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.Sample = fs.String(drive.CLIOptionSample, "", drive.DescSample)
+	cmd.Hidden = fs.Bool(drive.HiddenKey, false, "verify hidden paths")
+	return fs
+}
+
+func (cmd *verifyCmd) Run(args []string, definedFlags map[string]*flag.Flag) {
+	sources, context, path := preprocessArgs(args)
+
+	exitWithError(drive.New(context, &drive.Options{
+		Path:    path,
+		Sources: sources,
+		Hidden:  *cmd.Hidden,
+	}).Verify(*cmd.Sample))
+}
+
+type statusCmd struct {
+	Depth             *int  `json:"depth"`
+	Hidden            *bool `json:"hidden"`
+	IgnoreChecksum    *bool `json:"ignore-checksum"`
+	IgnoreNameClashes *bool `json:"ignore-name-clashes"`
+	Quiet             *bool `json:"quiet"`
+	NoCache           *bool `json:"no-cache"`
+}
+
+func (cmd *statusCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.Depth = fs.Int(drive.DepthKey, drive.DefaultMaxTraversalDepth, "max traversal depth")
+	cmd.Hidden = fs.Bool(drive.HiddenKey, false, "report on hidden paths")
+	cmd.IgnoreChecksum = fs.Bool(drive.CLIOptionIgnoreChecksum, true, drive.DescIgnoreChecksum)
+	cmd.IgnoreNameClashes = fs.Bool(drive.CLIOptionIgnoreNameClashes, false, drive.DescIgnoreNameClashes)
+	cmd.Quiet = fs.Bool(drive.QuietKey, false, "if set, do not log anything but errors")
+	cmd.NoCache = fs.Bool(drive.CLIOptionNoCache, false, drive.DescNoCache)
+	return fs
+}
+
+func (scmd *statusCmd) Run(args []string, definedFlags map[string]*flag.Flag) {
+	sources, context, path := preprocessArgs(args)
+
+	cmd := new(statusCmd)
+	df := defaultsFiller{
+		command: drive.StatusKey,
+		from:    *scmd, to: cmd,
+		rcSourcePath: context.AbsPathOf(path),
+		definedFlags: definedFlags,
+	}
+
+	if err := fillWithDefaults(df); err != nil {
+		exitWithError(err)
+	}
+
+	exitWithError(drive.New(context, &drive.Options{
+		Path:              path,
+		Sources:           sources,
+		Depth:             *cmd.Depth,
+		Hidden:            *cmd.Hidden,
+		IgnoreChecksum:    *cmd.IgnoreChecksum,
+		IgnoreNameClashes: *cmd.IgnoreNameClashes,
+		Quiet:             *cmd.Quiet,
+		NoCache:           *cmd.NoCache,
+		Recursive:         true,
+	}).Status())
+}
+
+type flushCmd struct {
+	List     *bool `json:"-"`
+	NoPrompt *bool `json:"no-prompt"`
+	Quiet    *bool `json:"quiet"`
+	Verbose  *bool `json:"verbose"`
+}
+
+func (cmd *flushCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.List = fs.Bool(drive.ListKey, false, drive.DescFlushList)
+	cmd.NoPrompt = fs.Bool(drive.NoPromptKey, false, "shows no prompt before applying each push")
+	cmd.Quiet = fs.Bool(drive.QuietKey, false, "if set, do not log anything but errors")
+	cmd.Verbose = fs.Bool(drive.CLIOptionVerboseKey, false, drive.DescVerbose)
+	return fs
+}
+
+func (cmd *flushCmd) Run(args []string, definedFlags map[string]*flag.Flag) {
+	_, context, _ := preprocessArgs(args)
+
+	exitWithError(drive.New(context, &drive.Options{
+		NoPrompt:                     *cmd.NoPrompt,
+		Quiet:                        *cmd.Quiet,
+		Verbose:                      *cmd.Verbose,
+		ExponentialBackoffRetryCount: drive.MaxFailedRetryCount,
+	}).Flush(*cmd.List))
+}
+
+type transferCmd struct {
+	Move  *bool `json:"move"`
+	Quiet *bool `json:"quiet"`
+}
+
+func (cmd *transferCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.Move = fs.Bool(drive.CLIOptionMove, false, drive.DescTransferMove)
+	cmd.Quiet = fs.Bool(drive.QuietKey, false, "if set, do not log anything but errors")
+	return fs
+}
+
+func (cmd *transferCmd) Run(args []string, definedFlags map[string]*flag.Flag) {
+	if len(args) < 2 {
+		exitWithError(fmt.Errorf("transfer: expecting <src>... <destContextPath>:<destRemotePath>"))
+	}
+
+	end := len(args) - 1
+	dest := args[end]
+
+	sep := strings.LastIndex(dest, ":")
+	if sep < 0 {
+		exitWithError(fmt.Errorf("transfer: %q is not of the form <destContextPath>:<destRemotePath>", dest))
+	}
+	destContextPath, destPath := dest[:sep], dest[sep+1:]
+	if destPath == "" {
+		destPath = "/"
+	}
+
+	destAbsPath, err := filepath.Abs(destContextPath)
+	exitWithError(err)
+	destContext, err := config.Discover(destAbsPath)
+	exitWithError(err)
+
+	sources, context, path := preprocessArgs(args[:end])
+
+	exitWithError(drive.New(context, &drive.Options{
+		Path:    path,
+		Sources: sources,
+		Quiet:   *cmd.Quiet,
+	}).Transfer(destContext, destPath, *cmd.Move))
+}
+
 type untrashCmd struct {
 	Hidden  *bool `json:"hidden"`
 	Matches *bool `json:"matches"`
@@ -1592,6 +2748,8 @@ type shareCmd struct {
 	Quiet       *bool   `json:"quiet"`
 	Verbose     *bool   `json:"verbose"`
 	WithLink    *bool   `json:"with-link"`
+	Recursive   *bool   `json:"recursive"`
+	Rollback    *bool   `json:"rollback"`
 }
 
 func (cmd *shareCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
@@ -1605,6 +2763,8 @@ func (cmd *shareCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
 	cmd.Quiet = fs.Bool(drive.QuietKey, false, "if set, do not log anything but errors")
 	cmd.ById = fs.Bool(drive.CLIOptionId, false, "share by id instead of path")
 	cmd.Verbose = fs.Bool(drive.CLIOptionVerboseKey, true, drive.DescVerbose)
+	cmd.Recursive = fs.Bool(drive.RecursiveKey, false, drive.DescShareRecursive)
+	cmd.Rollback = fs.Bool(drive.CLIOptionRollback, false, drive.DescRollback)
 
 	return fs
 }
@@ -1628,13 +2788,15 @@ func (cmd *shareCmd) Run(args []string, definedFlags map[string]*flag.Flag) {
 	}
 
 	exitWithError(drive.New(context, &drive.Options{
-		Path:     path,
-		Sources:  sources,
-		Meta:     &meta,
-		TypeMask: mask,
-		NoPrompt: *cmd.NoPrompt,
-		Quiet:    *cmd.Quiet,
-		Verbose:  *cmd.Verbose,
+		Path:      path,
+		Sources:   sources,
+		Meta:      &meta,
+		TypeMask:  mask,
+		NoPrompt:  *cmd.NoPrompt,
+		Quiet:     *cmd.Quiet,
+		Verbose:   *cmd.Verbose,
+		Recursive: *cmd.Recursive,
+		Rollback:  *cmd.Rollback,
 	}).Share(*cmd.ById))
 }
 
@@ -1646,6 +2808,8 @@ type unshareCmd struct {
 	Emails      *string `json:"emails"`
 	NoPrompt    *bool   `json:"no-prompt"`
 	Verbose     *bool   `json:"verbose"`
+	Recursive   *bool   `json:"recursive"`
+	Rollback    *bool   `json:"rollback"`
 }
 
 func (cmd *unshareCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
@@ -1656,6 +2820,8 @@ func (cmd *unshareCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
 	cmd.Emails = fs.String(drive.EmailsKey, "", "emails to share the file to")
 	cmd.NoPrompt = fs.Bool(drive.NoPromptKey, false, "disables the prompt")
 	cmd.AccountType = fs.String(drive.TypeKey, "", "scope of account to revoke access to")
+	cmd.Recursive = fs.Bool(drive.RecursiveKey, false, drive.DescShareRecursive)
+	cmd.Rollback = fs.Bool(drive.CLIOptionRollback, false, drive.DescRollback)
 
 	return fs
 }
@@ -1670,25 +2836,31 @@ func (cmd *unshareCmd) Run(args []string, definedFlags map[string]*flag.Flag) {
 	}
 
 	exitWithError(drive.New(context, &drive.Options{
-		Meta:     &meta,
-		Path:     path,
-		Sources:  sources,
-		NoPrompt: *cmd.NoPrompt,
-		Quiet:    *cmd.Quiet,
-		Verbose:  *cmd.Verbose,
+		Meta:      &meta,
+		Path:      path,
+		Sources:   sources,
+		NoPrompt:  *cmd.NoPrompt,
+		Quiet:     *cmd.Quiet,
+		Verbose:   *cmd.Verbose,
+		Recursive: *cmd.Recursive,
+		Rollback:  *cmd.Rollback,
 	}).Unshare(*cmd.ById))
 }
 
 type moveCmd struct {
-	Quiet      *bool `json:"quiet"`
-	ById       *bool `json:"by-id"`
-	KeepParent *bool `json:"keep-parent"`
+	Quiet        *bool   `json:"quiet"`
+	ById         *bool   `json:"by-id"`
+	KeepParent   *bool   `json:"keep-parent"`
+	BackupSuffix *string `json:"backup-suffix"`
+	Overwrite    *bool   `json:"overwrite"`
 }
 
 func (cmd *moveCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
 	cmd.Quiet = fs.Bool(drive.QuietKey, false, "if set, do not log anything but errors")
 	cmd.ById = fs.Bool(drive.CLIOptionId, false, "move by id instead of path")
 	cmd.KeepParent = fs.Bool(drive.CLIOptionKeepParent, false, drive.DescKeepParent)
+	cmd.BackupSuffix = fs.String(drive.CLIOptionBackupSuffix, "", drive.DescBackupSuffix)
+	cmd.Overwrite = fs.Bool(drive.CLIOptionOverwrite, false, drive.DescOverwrite)
 	return fs
 }
 
@@ -1708,10 +2880,16 @@ func (cmd *moveCmd) Run(args []string, definedFlags map[string]*flag.Flag) {
 
 	sources = append(sources, destRels[0])
 
+	if *cmd.BackupSuffix != "" && *cmd.Overwrite {
+		exitWithError(fmt.Errorf("-%s can't be combined with -%s", drive.CLIOptionBackupSuffix, drive.CLIOptionOverwrite))
+	}
+
 	exitWithError(drive.New(context, &drive.Options{
-		Path:    path,
-		Sources: sources,
-		Quiet:   *cmd.Quiet,
+		Path:         path,
+		Sources:      sources,
+		Quiet:        *cmd.Quiet,
+		BackupSuffix: *cmd.BackupSuffix,
+		Overwrite:    *cmd.Overwrite,
 	}).Move(*cmd.ById, *cmd.KeepParent))
 }
 
@@ -1803,6 +2981,44 @@ func (cmd *unstarCmd) Run(args []string, definedFlags map[string]*flag.Flag) {
 	exitWithError(drive.New(context, opts).UnStar(*cmd.ById))
 }
 
+type lockCmd struct {
+	ById  *bool `json:"by-id"`
+	Quiet *bool `json:"quiet"`
+	Force *bool `json:"-"`
+}
+
+func (cmd *lockCmd) Flags(fs *flag.FlagSet) *flag.FlagSet {
+	cmd.ById = fs.Bool(drive.CLIOptionId, false, "lock by id instead of path")
+	cmd.Quiet = fs.Bool(drive.QuietKey, false, "if set, do not log anything but errors")
+	cmd.Force = fs.Bool(drive.ForceKey, false, "take over or release the locks of others")
+	return fs
+}
+
+func (cmd *lockCmd) options(args []string) (*config.Context, *drive.Options) {
+	sources, context, path := preprocessArgsByToggle(args, *cmd.ById)
+
+	return context, &drive.Options{
+		Path:    path,
+		Sources: sources,
+		Quiet:   *cmd.Quiet,
+		Force:   *cmd.Force,
+	}
+}
+
+func (cmd *lockCmd) Run(args []string, definedFlags map[string]*flag.Flag) {
+	context, opts := cmd.options(args)
+	exitWithError(drive.New(context, opts).Lock(*cmd.ById))
+}
+
+type unlockCmd struct {
+	lockCmd
+}
+
+func (cmd *unlockCmd) Run(args []string, definedFlags map[string]*flag.Flag) {
+	context, opts := cmd.options(args)
+	exitWithError(drive.New(context, opts).Unlock(*cmd.ById))
+}
+
 type idCmd struct {
 	Depth  *int  `json:"depth"`
 	Hidden *bool `json:"hidden"`
@@ -1948,7 +3164,15 @@ func initContext(args []string) *config.Context {
 	var gdPath string
 	var firstInit bool
 
-	gdPath, firstInit, context, err = config.Initialize(getContextPath(args))
+	if remote := os.Getenv(drive.RemoteEnvKey); remote != "" {
+		gdPath, firstInit, context, err = config.InitializeRemote(getContextPath(args), gdDirFromEnv(), remote, os.Getenv(drive.AccountEnvKey))
+	} else if account := os.Getenv(drive.AccountEnvKey); account != "" {
+		gdPath, firstInit, context, err = config.InitializeAccount(getContextPath(args), gdDirFromEnv(), account)
+	} else if gdDir := gdDirFromEnv(); gdDir != "" {
+		gdPath, firstInit, context, err = config.InitializeWithGDDir(getContextPath(args), gdDir)
+	} else {
+		gdPath, firstInit, context, err = config.Initialize(getContextPath(args))
+	}
 
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, os.Kill)
@@ -1969,9 +3193,36 @@ func initContext(args []string) *config.Context {
 func discoverContext(args []string) (*config.Context, string) {
 	var err error
 	ctxPath := getContextPath(args)
-	context, err = config.Discover(ctxPath)
+	if gdDir := gdDirFromEnv(); gdDir != "" {
+		context, err = config.DiscoverWithGDDir(gdDir, ctxPath)
+	} else {
+		context, err = config.DiscoverOuter(ctxPath, outerFromEnv())
+		// Without a context, e.g in CI pipelines that never ran
+		// init, fall back to the Application Default Credentials.
+		if err == config.ErrNoDriveContext && drive.HasDefaultCredentials() {
+			var cwd string
+			if cwd, err = os.Getwd(); err == nil {
+				context, err = config.ApplicationDefaultContext(cwd)
+			}
+		}
+	}
 	drive.DebugPrintf("contextPath: %q", ctxPath)
 	exitWithError(err)
+	if remote := os.Getenv(drive.RemoteEnvKey); remote != "" {
+		// The remote's own account is used, so another one can't be.
+		if os.Getenv(drive.AccountEnvKey) != "" {
+			exitWithError(fmt.Errorf("--%s can't be combined with --%s", drive.CLIOptionNamedRemote, drive.CLIOptionAccount))
+		}
+		exitWithError(context.UseRemote(remote))
+	} else {
+		exitWithError(context.UseAccount(os.Getenv(drive.AccountEnvKey)))
+	}
+	if subject := impersonatedSubject(); subject != "" {
+		if !context.IsServiceAccount() {
+			exitWithError(fmt.Errorf("--%s needs the context's credentials to be those of a service account", drive.CLIOptionImpersonate))
+		}
+		context.Impersonate = subject
+	}
 	relPath := ""
 	if len(args) > 0 {
 		var headAbsArg string
@@ -1998,6 +3249,158 @@ func getContextPath(args []string) (contextPath string) {
 	return
 }
 
+// globalOption is an option that every command accepts, exported as
+// envKey e.g GD_DIR for the command to pick it up.
+type globalOption struct {
+	option string
+	envKey string
+	isBool bool
+}
+
+var globalOptions = []globalOption{
+	{option: drive.CLIOptionGDDir, envKey: drive.GDDirEnvKey},
+	{option: drive.CLIOptionGDName, envKey: drive.GDNameEnvKey},
+	{option: drive.CLIOptionOuter, envKey: drive.OuterEnvKey},
+	{option: drive.CLIOptionAccount, envKey: drive.AccountEnvKey},
+	{option: drive.CLIOptionNamedRemote, envKey: drive.RemoteEnvKey},
+	{option: drive.CLIOptionImpersonate, envKey: drive.ImpersonateEnvKey},
+	{option: drive.CLIOptionProxy, envKey: drive.ProxyEnvKey},
+	{option: drive.CLIOptionMetadataTTL, envKey: drive.MetadataTTLEnvKey},
+	{option: drive.CLIOptionCABundle, envKey: drive.CABundleEnvKey},
+	{option: drive.CLIOptionTLSMinVersion, envKey: drive.TLSMinVersionEnvKey},
+	{option: drive.CLIOptionBindAddress, envKey: drive.BindAddressEnvKey},
+	{option: drive.CLIOptionObjectCache, envKey: drive.ObjectCacheEnvKey},
+	{option: drive.CLIOptionObjectCacheSize, envKey: drive.ObjectCacheSizeEnvKey},
+	{option: drive.CLIOptionOutput, envKey: drive.OutputEnvKey},
+	{option: drive.CLIOptionASCII, envKey: drive.ASCIIEnvKey, isBool: true},
+	{option: drive.CLIOptionMetadataCacheDisk, envKey: drive.MetadataCacheDiskEnvKey, isBool: true},
+	{option: drive.CLIOptionInsecureSkipVerify, envKey: drive.InsecureSkipVerifyEnvKey, isBool: true},
+	{option: drive.CLIOptionPreferIPv4, envKey: drive.PreferIPv4EnvKey, isBool: true},
+}
+
+// extractGlobalOptions strips the global options, in any order, that lead
+// the command e.g `drive --ascii --gd-dir path --account work pull` or that
+// follow it e.g `drive pull --gd-dir path`. Following the command, --remote
+// is left to the commands since some of them have a -remote of their own.
+func extractGlobalOptions(args []string) []string {
+	for extracted := true; extracted; {
+		args, extracted = extractGlobalOption(args, 1, true)
+	}
+
+	for i := 2; i < len(args) && args[i] != "--"; {
+		var extracted bool
+		if args, extracted = extractGlobalOption(args, i, false); !extracted {
+			i++
+		}
+	}
+	return args
+}
+
+// extractGlobalOption removes the global option at args[i], if any,
+// reporting whether it did. leading is set for options before the command.
+func extractGlobalOption(args []string, i int, leading bool) ([]string, bool) {
+	for _, g := range globalOptions {
+		if !leading && g.option == drive.CLIOptionNamedRemote {
+			continue
+		}
+
+		var extracted bool
+		if g.isBool {
+			args, extracted = extractGlobalBool(args, i, g.option, g.envKey)
+		} else {
+			args, extracted = extractGlobalValue(args, i, g.option, g.envKey)
+		}
+		if extracted {
+			return args, true
+		}
+	}
+	return args, false
+}
+
+// extractGlobalBool removes a global `--option` at args[i] e.g `--ascii`,
+// exporting it as envKey e.g DRIVE_ASCII so that every command picks it up.
+func extractGlobalBool(args []string, i int, option, envKey string) ([]string, bool) {
+	if len(args) <= i {
+		return args, false
+	}
+
+	head := strings.TrimLeft(args[i], "-")
+	if head == args[i] || head != option {
+		return args, false
+	}
+
+	os.Setenv(envKey, "true")
+	return append(args[:i], args[i+1:]...), true
+}
+
+// extractGlobalValue removes a global `--option value` or `--option=value`
+// at args[i] e.g `--gd-dir path`, exporting the value as envKey e.g GD_DIR
+// so that every command picks it up.
+func extractGlobalValue(args []string, i int, option, envKey string) ([]string, bool) {
+	if len(args) <= i {
+		return args, false
+	}
+
+	head := strings.TrimLeft(args[i], "-")
+	if head == args[i] {
+		return args, false
+	}
+
+	prefix := option + "="
+	switch {
+	case head == option && len(args) > i+1:
+		os.Setenv(envKey, args[i+1])
+		return append(args[:i], args[i+2:]...), true
+	case strings.HasPrefix(head, prefix):
+		os.Setenv(envKey, strings.TrimPrefix(head, prefix))
+		return append(args[:i], args[i+1:]...), true
+	}
+
+	return args, false
+}
+
+// impersonatedSubject returns the user that --impersonate or
+// DRIVE_IMPERSONATE names for a service account to act as.
+func impersonatedSubject() string {
+	subject := strings.TrimSpace(os.Getenv(drive.ImpersonateEnvKey))
+	if subject != "" && !strings.Contains(subject, "@") {
+		exitWithError(fmt.Errorf("--%s expects the email address of a user e.g user@example.com, got %q", drive.CLIOptionImpersonate, subject))
+	}
+	return subject
+}
+
+// outerFromEnv returns how many nested contexts --outer or DRIVE_OUTER
+// skips past to reach the one that the command should use.
+func outerFromEnv() int {
+	outer := strings.TrimSpace(os.Getenv(drive.OuterEnvKey))
+	if outer == "" {
+		return 0
+	}
+	n, err := strconv.Atoi(outer)
+	if err != nil || n < 0 {
+		exitWithError(fmt.Errorf("--%s expects how many enclosing contexts to go out by e.g 1, got %q", drive.CLIOptionOuter, outer))
+	}
+	return n
+}
+
+// useNamedRemote exports the remote that a command's -remote names, if
+// any, as --remote before the command would so that it is used throughout.
+func useNamedRemote(name string) {
+	if name = strings.TrimSpace(name); name != "" {
+		os.Setenv(drive.RemoteEnvKey, name)
+	}
+}
+
+func gdDirFromEnv() string {
+	gdDir := os.Getenv(drive.GDDirEnvKey)
+	if gdDir == "" {
+		return ""
+	}
+	absGDDir, err := filepath.Abs(gdDir)
+	exitWithError(err)
+	return absGDDir
+}
+
 func uniqOrderedStr(sources []string) []string {
 	cache := map[string]bool{}
 	var uniqPaths []string
@@ -2024,7 +3427,9 @@ func exitWithError(err error) {
 		code = codedErr.Code()
 	}
 
-	drive.FprintfShadow(os.Stderr, "%s\n", msg)
+	if !drive.PrintSummary(err, code) {
+		drive.FprintfShadow(drive.ASCIISafe(os.Stderr), "%s\n", msg)
+	}
 	os.Exit(code)
 }
 
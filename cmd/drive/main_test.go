@@ -0,0 +1,364 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/odeke-em/drive/config"
+	"github.com/odeke-em/drive/src"
+	gdrive "google.golang.org/api/drive/v2"
+)
+
+func TestParseIndexImportArgs(t *testing.T) {
+	testCases := []struct {
+		args     []string
+		manifest string
+		rest     []string
+		wantErr  bool
+	}{
+		{args: []string{"-md5", "sums.txt"}, manifest: "sums.txt", rest: []string{}},
+		{args: []string{"--md5", "sums.txt", "photos"}, manifest: "sums.txt", rest: []string{"photos"}},
+		{args: []string{"photos"}, wantErr: true},
+		{args: []string{}, wantErr: true},
+	}
+
+	for i, tc := range testCases {
+		manifest, rest, err := parseIndexImportArgs(tc.args)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("#%d: expected an error", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: %v", i, err)
+			continue
+		}
+		if manifest != tc.manifest {
+			t.Errorf("#%d: manifest: got %q want %q", i, manifest, tc.manifest)
+		}
+		if !reflect.DeepEqual(rest, tc.rest) {
+			t.Errorf("#%d: rest: got %q want %q", i, rest, tc.rest)
+		}
+	}
+}
+
+func TestDuDepth(t *testing.T) {
+	testCases := []struct {
+		args []string
+		want int
+	}{
+		{args: []string{}, want: 1},
+		{args: []string{"-by-type"}, want: drive.InfiniteDepth},
+		{args: []string{"-top", "50"}, want: drive.InfiniteDepth},
+		{args: []string{"-top", "50", "-depth", "2"}, want: 2},
+		{args: []string{"-by-type", "-depth", "1"}, want: 1},
+	}
+
+	for i, tc := range testCases {
+		cmd := &duCmd{}
+		fs := cmd.Flags(flag.NewFlagSet("du", flag.ContinueOnError))
+		if err := fs.Parse(tc.args); err != nil {
+			t.Fatalf("#%d: parse %v: %v", i, tc.args, err)
+		}
+		definedFlags := make(map[string]*flag.Flag)
+		fs.Visit(func(f *flag.Flag) { definedFlags[f.Name] = f })
+
+		if got := cmd.depth(*cmd.Depth, definedFlags); got != tc.want {
+			t.Errorf("#%d: %v: got depth %d want %d", i, tc.args, got, tc.want)
+		}
+	}
+}
+
+func TestDirsOnlyAlias(t *testing.T) {
+	defer restoreEnv(drive.XDGConfigHomeEnvKey)()
+	dir, err := ioutil.TempDir("", "drive-dirs-only")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.Setenv(drive.XDGConfigHomeEnvKey, dir)
+
+	rc := "[pull]\ndirectories=false\n[push]\ndirectories=false\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, drive.DriveResourceConfiguration), []byte(rc), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	pull, push := &pullCmd{}, &pushCmd{}
+	testCases := []struct {
+		name string
+		cmd  interface {
+			Flags(*flag.FlagSet) *flag.FlagSet
+		}
+		from        func() interface{}
+		directories func() *bool
+	}{
+		{name: "pull", cmd: pull, from: func() interface{} { return *pull }, directories: func() *bool { return pull.Directories }},
+		{name: "push", cmd: push, from: func() interface{} { return *push }, directories: func() *bool { return push.Directories }},
+	}
+
+	for _, tc := range testCases {
+		fs := tc.cmd.Flags(flag.NewFlagSet(tc.name, flag.ContinueOnError))
+		if err := fs.Parse([]string{"-dirs-only"}); err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if !*tc.directories() {
+			t.Errorf("%s: -dirs-only did not set -directories", tc.name)
+		}
+
+		definedFlags := make(map[string]*flag.Flag)
+		fs.Visit(func(f *flag.Flag) { definedFlags[f.Name] = f })
+		to := make(map[string]interface{})
+		df := defaultsFiller{
+			command:      tc.name,
+			from:         tc.from(),
+			to:           &to,
+			rcSourcePath: dir,
+			definedFlags: definedFlags,
+		}
+		if err := fillWithDefaults(df); err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		// The .driverc sets directories but the command line, by its alias,
+		// takes precedence.
+		if got := to[drive.CLIOptionDirectories]; got != true {
+			t.Errorf("%s: directories: got %v want true", tc.name, got)
+		}
+		if _, ok := to[drive.CLIOptionDirsOnly]; ok {
+			t.Errorf("%s: dirs-only should not be a key of its own", tc.name)
+		}
+	}
+}
+
+// restoreEnv returns a func restoring the given environment variables.
+func restoreEnv(keys ...string) func() {
+	prev := make(map[string]*string)
+	for _, key := range keys {
+		if value, ok := os.LookupEnv(key); ok {
+			prev[key] = &value
+		} else {
+			prev[key] = nil
+		}
+	}
+	return func() {
+		for key, value := range prev {
+			if value != nil {
+				os.Setenv(key, *value)
+			} else {
+				os.Unsetenv(key)
+			}
+		}
+	}
+}
+
+func TestExtractGlobalOptions(t *testing.T) {
+	var envKeys []string
+	for _, g := range globalOptions {
+		envKeys = append(envKeys, g.envKey)
+	}
+	defer restoreEnv(envKeys...)()
+
+	testCases := []struct {
+		args    []string
+		want    []string
+		wantEnv map[string]string
+	}{
+		{
+			args: []string{"drive", "--ascii", "--gd-dir", "/gd", "--account=work", "pull", "a"},
+			want: []string{"drive", "pull", "a"},
+			wantEnv: map[string]string{
+				drive.ASCIIEnvKey: "true", drive.GDDirEnvKey: "/gd", drive.AccountEnvKey: "work",
+			},
+		},
+		{
+			args:    []string{"drive", "pull", "a", "--gd-dir=/gd", "-ascii"},
+			want:    []string{"drive", "pull", "a"},
+			wantEnv: map[string]string{drive.GDDirEnvKey: "/gd", drive.ASCIIEnvKey: "true"},
+		},
+		{
+			args:    []string{"drive", "--remote", "backup", "pull"},
+			want:    []string{"drive", "pull"},
+			wantEnv: map[string]string{drive.RemoteEnvKey: "backup"},
+		},
+		{
+			// Following the command, -remote is the command's own.
+			args: []string{"drive", "pull", "-remote", "backup"},
+			want: []string{"drive", "pull", "-remote", "backup"},
+		},
+		{
+			args: []string{"drive", "push", "--", "--gd-dir", "a"},
+			want: []string{"drive", "push", "--", "--gd-dir", "a"},
+		},
+		{
+			// A value that is missing leaves the option alone.
+			args: []string{"drive", "pull", "--gd-dir"},
+			want: []string{"drive", "pull", "--gd-dir"},
+		},
+	}
+
+	for i, tc := range testCases {
+		for _, key := range envKeys {
+			os.Unsetenv(key)
+		}
+		got := extractGlobalOptions(append([]string{}, tc.args...))
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("#%d: %q: got %q want %q", i, tc.args, got, tc.want)
+		}
+		for _, key := range envKeys {
+			if got, want := os.Getenv(key), tc.wantEnv[key]; got != want {
+				t.Errorf("#%d: %q: %s got %q want %q", i, tc.args, key, got, want)
+			}
+		}
+	}
+}
+
+type runner interface {
+	Flags(*flag.FlagSet) *flag.FlagSet
+	Run([]string, map[string]*flag.Flag)
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (fn roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return fn(req)
+}
+
+// emptyDrive serves the requests of a run as an empty Drive would.
+func emptyDrive(t *testing.T) http.RoundTripper {
+	return roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		var body interface{}
+		switch p := strings.TrimPrefix(req.URL.Path, "/drive/v2/"); {
+		case p == "about":
+			body = &gdrive.About{Name: "test", RootFolderId: "root", QuotaBytesTotal: 1 << 30}
+		case p == "files":
+			body = &gdrive.FileList{}
+		case p == "changes":
+			body = &gdrive.ChangeList{}
+		case strings.HasPrefix(p, "files/"):
+			id := strings.TrimPrefix(p, "files/")
+			body = &gdrive.File{Id: id, Title: id, MimeType: drive.DriveFolderMimeType}
+		default:
+			t.Errorf("unexpected request %s %s", req.Method, req.URL)
+			return &http.Response{StatusCode: http.StatusNotFound, Body: ioutil.NopCloser(strings.NewReader("")), Request: req}, nil
+		}
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       ioutil.NopCloser(bytes.NewReader(data)),
+			Request:    req,
+		}, nil
+	})
+}
+
+// inContext runs fn in a fresh context, whose credentials hold an access
+// token that is still valid, against an empty Drive.
+func inContext(t *testing.T, fn func(root string)) {
+	dir, err := ioutil.TempDir("", "drive-run")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	root := filepath.Join(dir, "context")
+
+	env := map[string]string{
+		"HOME":            filepath.Join(dir, "home"),
+		"XDG_CACHE_HOME":  filepath.Join(dir, "cache"),
+		"XDG_CONFIG_HOME": filepath.Join(dir, "config"),
+	}
+	unset := []string{drive.AccountEnvKey, drive.RemoteEnvKey, drive.DriveClientIdEnvKey, drive.DriveClientSecretEnvKey}
+	for key := range env {
+		unset = append(unset, key)
+	}
+	defer restoreEnv(unset...)()
+	for _, key := range unset {
+		if value, ok := env[key]; ok {
+			os.Setenv(key, value)
+		} else {
+			os.Unsetenv(key)
+		}
+	}
+
+	context := &config.Context{AbsPath: root}
+	context.ClientId, context.ClientSecret = "client-id", "client-secret"
+	context.RefreshToken, context.AccessToken = "refresh-token", "access-token"
+	context.TokenExpiry = time.Now().Add(time.Hour).Unix()
+	if err := os.MkdirAll(context.GDPath(), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := context.Write(); err != nil {
+		t.Fatalf("writing the credentials: %v", err)
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(cwd)
+	if err := os.Chdir(root); err != nil {
+		t.Fatal(err)
+	}
+
+	prevTransport := http.DefaultTransport
+	defer func() {
+		http.DefaultTransport = prevTransport
+	}()
+	http.DefaultTransport = emptyDrive(t)
+
+	fn(root)
+}
+
+// run parses args by the flags of cmd and runs it, as the command line does.
+func run(t *testing.T, name string, cmd runner, args ...string) {
+	fs := cmd.Flags(flag.NewFlagSet(name, flag.ContinueOnError))
+	if err := fs.Parse(args); err != nil {
+		t.Fatalf("%s: parse %v: %v", name, args, err)
+	}
+	definedFlags := make(map[string]*flag.Flag)
+	fs.Visit(func(f *flag.Flag) { definedFlags[f.Name] = f })
+	cmd.Run(fs.Args(), definedFlags)
+}
+
+// TestRunPullVirtual runs pull with -virtual, which the .driverc can't
+// set and so is only read from the command line.
+func TestRunPullVirtual(t *testing.T) {
+	inContext(t, func(root string) {
+		run(t, "pull", &pullCmd{}, "-no-prompt", "-quiet")
+		run(t, "pull", &pullCmd{}, "-no-prompt", "-quiet", "-starred", "-all", "-virtual")
+	})
+}
+
+// TestRunAboutAuth runs about with -auth, which the .driverc can't
+// set and so is only read from the command line.
+func TestRunAboutAuth(t *testing.T) {
+	inContext(t, func(root string) {
+		run(t, "about", &aboutCmd{}, "-quiet")
+		run(t, "about", &aboutCmd{}, "-auth")
+	})
+}
@@ -0,0 +1,124 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+var accountNameRegexp = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// AccountNames returns the names of the context's accounts, sorted.
+func (c *Context) AccountNames() []string {
+	var names []string
+	for name := range c.Accounts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// UseAccount switches the context's credentials to those of the named
+// account, or back to the default credentials if name is empty.
+func (c *Context) UseAccount(name string) error {
+	if name == c.Account {
+		return nil
+	}
+	if name != "" && c.Accounts[name] == nil {
+		return fmt.Errorf("no account %q in this context; add it with `drive --account %s init`", name, name)
+	}
+
+	if c.Account != "" {
+		inUse := c.Credentials
+		c.Accounts[c.Account] = &inUse
+		c.Credentials, c.defaults = c.defaults, Credentials{}
+		c.Account = ""
+	}
+	if name != "" {
+		c.defaults, c.Credentials = c.Credentials, *c.Accounts[name]
+		c.Account = name
+	}
+	return nil
+}
+
+// CreateAccount switches the context to the named account,
+// starting it off with empty credentials if it doesn't exist yet.
+func (c *Context) CreateAccount(name string) error {
+	if !accountNameRegexp.MatchString(name) {
+		return fmt.Errorf("invalid account name %q, expecting letters, digits, '.', '_' or '-'", name)
+	}
+	if c.Accounts == nil {
+		c.Accounts = make(map[string]*Credentials)
+	}
+	if c.Accounts[name] == nil {
+		c.Accounts[name] = &Credentials{}
+	}
+	return c.UseAccount(name)
+}
+
+// Deauthorize drops the credentials of the account in use. A named
+// account is removed altogether, switching back to the default one,
+// as is the remote in use since it can't be used without them.
+func (c *Context) Deauthorize() error {
+	if c.InKeychain() && osKeychain != nil {
+		if err := osKeychain.delete(c.keychainItem(c.Account)); err != nil && err != errKeychainItemNotFound {
+			return fmt.Errorf("keychain: %v", err)
+		}
+	}
+
+	if c.Remote != "" {
+		delete(c.Remotes, c.Remote)
+		c.RemoteRootId, c.defaultRootId = c.defaultRootId, ""
+		c.Remote = ""
+	}
+
+	if c.Account == "" {
+		c.Credentials = Credentials{}
+		return nil
+	}
+	delete(c.Accounts, c.Account)
+	c.Credentials, c.defaults = c.defaults, Credentials{}
+	c.Account = ""
+	return nil
+}
+
+// persisted returns the context as it is stored, with the credentials
+// of the account in use, if any, filed back under its name and likewise
+// the remote root of the remote in use.
+func (c *Context) persisted() *Context {
+	if c.Account == "" && c.Remote == "" {
+		return c
+	}
+
+	persisted := *c
+	if c.Account != "" {
+		accounts := make(map[string]*Credentials, len(c.Accounts))
+		for name, creds := range c.Accounts {
+			accounts[name] = creds
+		}
+		inUse := c.Credentials
+		accounts[c.Account] = &inUse
+
+		persisted.Credentials = c.defaults
+		persisted.Accounts = accounts
+	}
+	if c.Remote != "" {
+		persisted.RemoteRootId = c.defaultRootId
+		persisted.Remotes = c.persistedRemotes()
+	}
+	return &persisted
+}
@@ -0,0 +1,73 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAccounts(t *testing.T) {
+	c, done := tempContext(t)
+	defer done()
+
+	c.Credentials = Credentials{ClientId: "id", RefreshToken: "default"}
+	if err := c.CreateAccount("not/valid"); err == nil {
+		t.Errorf("expected an invalid account name to be refused")
+	}
+	if err := c.CreateAccount("work"); err != nil {
+		t.Fatalf("create work: %v", err)
+	}
+	if c.Account != "work" || c.RefreshToken != "" {
+		t.Errorf("expected the new account to be in use with empty credentials, got %q %+v", c.Account, c.Credentials)
+	}
+	c.RefreshToken = "work"
+	if err := c.Write(); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	read := reread(t, c)
+	if read.RefreshToken != "default" {
+		t.Errorf("expected the default credentials in the file, got %+v", read.Credentials)
+	}
+	if got, want := read.AccountNames(), []string{"work"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("accounts: got %v want %v", got, want)
+	}
+	if err := read.UseAccount("personal"); err == nil {
+		t.Errorf("expected an unknown account to be refused")
+	}
+	if err := read.UseAccount("work"); err != nil {
+		t.Fatalf("use work: %v", err)
+	}
+	if read.RefreshToken != "work" {
+		t.Errorf("expected the work credentials, got %+v", read.Credentials)
+	}
+	if err := read.UseAccount(""); err != nil {
+		t.Fatalf("use default: %v", err)
+	}
+	if read.RefreshToken != "default" {
+		t.Errorf("expected the default credentials back, got %+v", read.Credentials)
+	}
+
+	if err := read.UseAccount("work"); err != nil {
+		t.Fatalf("use work: %v", err)
+	}
+	if err := read.Deauthorize(); err != nil {
+		t.Fatalf("deauthorize: %v", err)
+	}
+	if read.Account != "" || read.RefreshToken != "default" || len(read.Accounts) != 0 {
+		t.Errorf("expected the account removed and the default back, got %q %+v %v", read.Account, read.Credentials, read.Accounts)
+	}
+}
@@ -15,6 +15,8 @@
 package config
 
 import (
+	"bytes"
+	"crypto/sha1"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -29,8 +31,12 @@ import (
 	"github.com/boltdb/bolt"
 )
 
+// DefaultGDDirSuffix is the name of the metadata directories
+// unless another one is picked with UseGDDirName.
+const DefaultGDDirSuffix = ".gd"
+
 var (
-	GDDirSuffix   = ".gd"
+	GDDirSuffix   = DefaultGDDirSuffix
 	PathSeparator = fmt.Sprintf("%c", os.PathSeparator)
 
 	ErrNoDriveContext      = errors.New("no drive context found; run `drive init` or go into one of the directories (sub directories) that you performed `drive init`")
@@ -39,24 +45,127 @@ var (
 	ErrEmptyFileIdForIndex = errors.New("fileId for index must be non-empty")
 	ErrNoSuchDbKey         = errors.New("no such db key exists")
 	ErrNoSuchDbBucket      = errors.New("no such bucket exists")
+	ErrNoCacheHome         = errors.New("no cache directory: set XDG_CACHE_HOME or HOME")
+	ErrNoContextRoot       = errors.New("the metadata directory is not bound to any context root; run `drive init` with it")
+	ErrNoOuterContext      = errors.New("fewer drive contexts enclose the path than the outer ones asked for")
+
+	ErrNoServiceAccountConfig = errors.New("the credentials are for a service account but hold no service account config; run `drive init -service-account-file` again")
 )
 
 const (
-	IndicesKey = "indices"
-	DriveDb    = "drivedb"
+	IndicesKey      = "indices"
+	ChecksumsKey    = "checksums"
+	DriveDb         = "drivedb"
+	CredentialsJSON = "credentials.json"
 )
 
 const (
 	O_RWForAll = 0666
 )
 
-type Context struct {
-	GSAJWTConfig *jwt.Config `json:"gsa_jwt_config,omitempty"`
+// lockSuffix names the file whose lock guards that of the same name
+// without it, from concurrent drive processes.
+const lockSuffix = ".lock"
+
+// Credential types that a context can authenticate with.
+const (
+	CredentialTypeOAuth2         = "oauth2"
+	CredentialTypeServiceAccount = "service_account"
+	// CredentialTypeApplicationDefault authenticates with the Application
+	// Default Credentials instead of credentials of the context's own.
+	CredentialTypeApplicationDefault = "application_default"
+)
+
+// Credentials are a set of credentials that a context can authenticate with.
+type Credentials struct {
+	// CredentialType is how the credentials authenticate, either with an
+	// OAuth2 refresh token or with the JWTConfig of a service account.
+	CredentialType string      `json:"credential_type,omitempty"`
+	GSAJWTConfig   *jwt.Config `json:"gsa_jwt_config,omitempty"`
 
 	ClientId     string `json:"client_id"`
 	ClientSecret string `json:"client_secret"`
 	RefreshToken string `json:"refresh_token"`
-	AbsPath      string `json:"-"`
+
+	// AccessToken and TokenExpiry, in Unix seconds, are those of the
+	// access token that RefreshToken was last exchanged for, which is
+	// reused until shortly before it expires.
+	AccessToken string `json:"access_token,omitempty"`
+	TokenExpiry int64  `json:"token_expiry,omitempty"`
+
+	// Scope is the OAuth2 scope that the credentials were
+	// granted, the full Drive scope if empty.
+	Scope string `json:"scope,omitempty"`
+
+	// Subject when set is the user of a Google Workspace domain that
+	// the service account impersonates by domain-wide delegation.
+	Subject string `json:"subject,omitempty"`
+}
+
+type Context struct {
+	// Credentials are those of the account in use, by default
+	// the context's own and otherwise those of Account.
+	Credentials
+
+	// Accounts are further named credential sets e.g "work" and
+	// "personal" that commands can select in place of the default.
+	Accounts map[string]*Credentials `json:"accounts,omitempty"`
+
+	// Account is the name of the account in use, "" for the default.
+	Account string `json:"-"`
+
+	// Remotes are further named remotes e.g "backup" that commands can
+	// select in place of the default one, each with its own account
+	// and remote root.
+	Remotes map[string]*Remote `json:"remotes,omitempty"`
+
+	// Remote is the name of the remote in use, "" for the default.
+	Remote string `json:"-"`
+	// defaultRootId stashes the default RemoteRootId while Remote is in use.
+	defaultRootId string
+
+	// Impersonate when set is the user to impersonate for this run
+	// only, in place of the Subject of the credentials in use.
+	Impersonate string `json:"-"`
+	// defaults stashes the default credentials while Account is in use.
+	defaults Credentials
+
+	AbsPath string `json:"-"`
+
+	// GDDir when set is a metadata directory outside of the context,
+	// used instead of the .gd directory at the context's root.
+	GDDir string `json:"-"`
+
+	// LastKnownRoot is where the context was last bound to. It is only
+	// used to detect that the context's directory was moved or renamed,
+	// everything else is stored relative to the context.
+	LastKnownRoot string `json:"last_known_root,omitempty"`
+
+	// RemoteRootId when set is the id of the remote folder that the context
+	// is bound to, in place of the root of the Drive. Being an id, it keeps
+	// the context bound to the folder even if the folder is renamed.
+	RemoteRootId string `json:"remote_root_id,omitempty"`
+
+	// RemoteRootPath when set during init is the path, from the root of
+	// the Drive, of the remote folder to bind the context to. The folder
+	// is created if need be and then bound to by its RemoteRootId, the
+	// path being kept to describe the context as it was configured.
+	RemoteRootPath string `json:"remote_root_path,omitempty"`
+
+	// CredentialStore is where the refresh tokens are stored, either
+	// the credentials file if empty or the OS keychain, in which case
+	// KeychainId names the context's items in the keychain.
+	CredentialStore string `json:"credential_store,omitempty"`
+	KeychainId      string `json:"keychain_id,omitempty"`
+
+	// CacheId names the context's directory within CacheHome. It is kept
+	// with the context so that the cache follows the context when it is
+	// moved or its metadata directory is renamed.
+	CacheId string `json:"cache_id,omitempty"`
+
+	// Encrypted is set if the credentials are encrypted at rest with a passphrase.
+	Encrypted  bool `json:"-"`
+	passphrase []byte
 }
 
 type Index struct {
@@ -67,6 +176,20 @@ type Index struct {
 	ModTime     int64  `json:"mtime"`
 	Version     int64  `json:"version"`
 	IndexTime   int64  `json:"itime"`
+
+	// CreatedTime and Description are those of the remote file, kept
+	// so that pushing a new revision of it preserves its provenance.
+	CreatedTime int64  `json:"ctime,omitempty"`
+	Description string `json:"desc,omitempty"`
+}
+
+// Checksum is the md5 checksum of a local file as of its size and
+// modification time, so that the file need not be re-hashed unless
+// either of them changes.
+type Checksum struct {
+	Md5Checksum string `json:"md5"`
+	Size        int64  `json:"size"`
+	ModTime     int64  `json:"mtime"`
 }
 
 type MountPoint struct {
@@ -102,17 +225,225 @@ func (c *Context) AbsPathOf(fileOrDirPath string) string {
 	return path.Join(c.AbsPath, fileOrDirPath)
 }
 
+// Relocated reports whether the context's directory has moved
+// since it was last bound. Contexts that predate LastKnownRoot
+// are never considered relocated.
+func (c *Context) Relocated() bool {
+	return c.LastKnownRoot != "" && c.LastKnownRoot != c.AbsPath
+}
+
 func (c *Context) Cwd() string {
 	cwd, _ := os.Getwd()
 	return cwd
 }
 
+// GDPath is the path of the context's metadata directory.
+func (c *Context) GDPath() string {
+	if c.GDDir != "" {
+		return c.GDDir
+	}
+	return gdPath(c.AbsPath)
+}
+
+// UseGDDirName makes name instead of DefaultGDDirSuffix the name of the
+// metadata directories that contexts are discovered and initialized by,
+// e.g for trees that are synced somewhere that hidden directories can't be.
+func UseGDDirName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("%q can't name the metadata directory, it must be a single path element", name)
+	}
+	GDDirSuffix = name
+	return nil
+}
+
+// CacheHome is the directory holding the cache-like state of every
+// context, $XDG_CACHE_HOME/drive or else ~/.cache/drive. Without either,
+// it fails with ErrNoCacheHome rather than fall back to a directory that
+// is shared with other users.
+func CacheHome() (string, error) {
+	cacheDir := os.Getenv("XDG_CACHE_HOME")
+	if cacheDir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil || home == "" {
+			return "", ErrNoCacheHome
+		}
+		cacheDir = filepath.Join(home, ".cache")
+	}
+	return filepath.Join(cacheDir, "drive"), nil
+}
+
+// CachePath is the directory of the context's cache-like state e.g its
+// index and metadata cache. Unlike the credentials and settings kept in
+// GDPath, all of it can be rebuilt, so it is kept out of the synced tree
+// and of its backups.
+func (c *Context) CachePath() (string, error) {
+	cacheHome, err := CacheHome()
+	if err != nil {
+		return "", err
+	}
+	id := c.CacheId
+	if id == "" {
+		id = c.legacyCacheId()
+	}
+	return filepath.Join(cacheHome, id), nil
+}
+
+// legacyCacheId is the hash of the metadata directory's path that
+// contexts were cached by before CacheId. For a context that has since
+// moved, that is the path it was last bound at.
+func (c *Context) legacyCacheId() string {
+	pathGD := c.GDPath()
+	if c.GDDir == "" && c.LastKnownRoot != "" {
+		pathGD = gdPath(c.LastKnownRoot)
+	}
+	return fmt.Sprintf("%x", sha1.Sum([]byte(pathGD)))
+}
+
+// CacheFilePath is the path of the context's cache file name, within
+// CachePath, unless only GDPath has the file, where earlier versions kept
+// it and EnsureCachePath couldn't move it from, in which case it is used
+// in place. Without a CachePath, the file is looked up in GDPath too.
+func (c *Context) CacheFilePath(name string) string {
+	legacyPath := filepath.Join(c.GDPath(), name)
+	cachePath, err := c.CachePath()
+	if err != nil {
+		return legacyPath
+	}
+	cachedPath := filepath.Join(cachePath, name)
+	if _, err := os.Stat(cachedPath); err == nil {
+		return cachedPath
+	}
+	if _, err := os.Stat(legacyPath); err == nil {
+		return legacyPath
+	}
+	return cachedPath
+}
+
+// EnsureCachePath creates CachePath, for cache files to be written to, and
+// moves into it the files named by legacyNames that earlier versions kept
+// in GDPath. A file that CachePath already has is left behind as stale, as
+// is one that can't be renamed e.g across filesystems, which then stays in
+// use where it is.
+func (c *Context) EnsureCachePath(legacyNames ...string) error {
+	cachePath, err := c.CachePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(cachePath, 0700); err != nil {
+		return err
+	}
+	for _, name := range legacyNames {
+		legacyPath := filepath.Join(c.GDPath(), name)
+		cachedPath := filepath.Join(cachePath, name)
+		if _, err := os.Stat(legacyPath); err != nil {
+			continue
+		}
+		if _, err := os.Stat(cachedPath); err == nil {
+			continue
+		}
+		os.Rename(legacyPath, cachedPath)
+	}
+	return nil
+}
+
 func (c *Context) Read() error {
-	data, err := ioutil.ReadFile(credentialsPath(c.AbsPath))
+	data, err := c.CredentialsData()
 	if err != nil {
 		return err
 	}
-	return json.Unmarshal(data, c)
+
+	if err := json.Unmarshal(data, c); err != nil {
+		return err
+	}
+
+	if err := c.Credentials.normalize(); err != nil {
+		return err
+	}
+	if c.CacheId == "" {
+		c.CacheId = c.legacyCacheId()
+	}
+	for name, creds := range c.Accounts {
+		if creds == nil {
+			delete(c.Accounts, name)
+		} else if err := creds.normalize(); err != nil {
+			return fmt.Errorf("account %q: %v", name, err)
+		}
+	}
+	for name, remote := range c.Remotes {
+		if remote == nil {
+			delete(c.Remotes, name)
+		}
+	}
+
+	switch c.CredentialStore {
+	case "", CredentialStoreFile:
+		c.CredentialStore = ""
+	case CredentialStoreKeychain:
+		return c.readKeychainTokens()
+	default:
+		return fmt.Errorf("unknown credential store %q", c.CredentialStore)
+	}
+	return nil
+}
+
+// CredentialsData returns the JSON of the credentials file,
+// decrypted first if it is encrypted at rest.
+func (c *Context) CredentialsData() ([]byte, error) {
+	data, err := ioutil.ReadFile(path.Join(c.GDPath(), CredentialsJSON))
+	if err != nil {
+		return nil, err
+	}
+
+	if bytes.HasPrefix(data, encryptedCredentialsMagic) {
+		c.Encrypted = true
+		return c.decryptCredentials(data[len(encryptedCredentialsMagic):])
+	}
+	return data, nil
+}
+
+func (creds *Credentials) normalize() error {
+	// Credentials written before CredentialType existed are told
+	// apart by whether they hold a service account's config.
+	if creds.CredentialType == "" {
+		creds.CredentialType = CredentialTypeOAuth2
+		if creds.GSAJWTConfig != nil {
+			creds.CredentialType = CredentialTypeServiceAccount
+		}
+	}
+
+	switch creds.CredentialType {
+	case CredentialTypeOAuth2, CredentialTypeApplicationDefault:
+	case CredentialTypeServiceAccount:
+		if creds.GSAJWTConfig == nil {
+			return ErrNoServiceAccountConfig
+		}
+	default:
+		return fmt.Errorf("unknown credential type %q", creds.CredentialType)
+	}
+	return nil
+}
+
+// IsApplicationDefault reports whether the credentials
+// are the Application Default Credentials.
+func (creds *Credentials) IsApplicationDefault() bool {
+	return creds.CredentialType == CredentialTypeApplicationDefault
+}
+
+// ImpersonatedSubject returns the user that the service account acts
+// as, or "" if it acts as itself.
+func (c *Context) ImpersonatedSubject() string {
+	if c.Impersonate != "" {
+		return c.Impersonate
+	}
+	return c.Subject
+}
+
+// IsServiceAccount reports whether the credentials are those of a service account.
+func (creds *Credentials) IsServiceAccount() bool {
+	if creds.CredentialType == "" {
+		return creds.GSAJWTConfig != nil
+	}
+	return creds.CredentialType == CredentialTypeServiceAccount
 }
 
 func (c *Context) DeserializeIndex(key string) (*Index, error) {
@@ -120,12 +451,12 @@ func (c *Context) DeserializeIndex(key string) (*Index, error) {
 		return nil, creationErr
 	}
 
-	db, err := c.OpenDB()
+	db, release, err := c.OpenDB()
 	if err != nil {
 		return nil, err
 	}
 
-	defer db.Close()
+	defer release()
 
 	var data []byte
 
@@ -159,7 +490,7 @@ func (c *Context) ListKeys(dir, bucketName string) (chan string, error) {
 		return keysChan, err
 	}
 
-	db, err := c.OpenDB()
+	db, release, err := c.OpenDB()
 	if err != nil {
 		close(keysChan)
 		return keysChan, err
@@ -167,7 +498,7 @@ func (c *Context) ListKeys(dir, bucketName string) (chan string, error) {
 
 	go func() {
 		defer func() {
-			db.Close()
+			release()
 			close(keysChan)
 		}()
 
@@ -190,16 +521,56 @@ func (c *Context) ListKeys(dir, bucketName string) (chan string, error) {
 	return keysChan, nil
 }
 
+// ScanDbBucket calls fn with the raw key and value of each entry of
+// the bucket bucketName, which it is fine not to exist yet.
+func (c *Context) ScanDbBucket(bucketName string, fn func(key, value []byte) error) error {
+	db, release, err := c.OpenDB()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	return db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(byteify(bucketName))
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(fn)
+	})
+}
+
+// DeleteDbKeys removes keys from the bucket bucketName in one transaction.
+func (c *Context) DeleteDbKeys(bucketName string, keys ...string) error {
+	db, release, err := c.OpenDB()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	return db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(byteify(bucketName))
+		if bucket == nil {
+			return nil
+		}
+		for _, key := range keys {
+			if err := bucket.Delete(byteify(key)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
 func (c *Context) PopIndicesKey(key string) error {
 	return c.popDbKey(IndicesKey, key)
 }
 
 func (c *Context) popDbKey(bucketName, key string) error {
-	db, err := c.OpenDB()
+	db, release, err := c.OpenDB()
 	if err != nil {
 		return err
 	}
-	defer db.Close()
+	defer release()
 
 	return db.Update(func(tx *bolt.Tx) error {
 		bucket, err := tx.CreateBucketIfNotExists(byteify(IndicesKey))
@@ -222,11 +593,11 @@ func (c *Context) RemoveIndex(index *Index, p string) error {
 		return ErrEmptyFileIdForIndex
 	}
 
-	db, err := c.OpenDB()
+	db, release, err := c.OpenDB()
 	if err != nil {
 		return err
 	}
-	defer db.Close()
+	defer release()
 
 	return db.Update(func(tx *bolt.Tx) error {
 		bucket, err := tx.CreateBucketIfNotExists(byteify(IndicesKey))
@@ -241,11 +612,11 @@ func (c *Context) RemoveIndex(index *Index, p string) error {
 }
 
 func (c *Context) CreateIndicesBucket() error {
-	db, err := c.OpenDB()
+	db, release, err := c.OpenDB()
 	if err != nil {
 		return err
 	}
-	defer db.Close()
+	defer release()
 
 	return db.Update(func(tx *bolt.Tx) error {
 		bucket, err := tx.CreateBucketIfNotExists(byteify(IndicesKey))
@@ -265,11 +636,11 @@ func (c *Context) SerializeIndex(index *Index) error {
 		return err
 	}
 
-	db, err := c.OpenDB()
+	db, release, err := c.OpenDB()
 	if err != nil {
 		return err
 	}
-	defer db.Close()
+	defer release()
 
 	return db.Update(func(tx *bolt.Tx) error {
 		bucket, err := tx.CreateBucketIfNotExists(byteify(IndicesKey))
@@ -283,19 +654,122 @@ func (c *Context) SerializeIndex(index *Index) error {
 	})
 }
 
+// SerializeChecksums stores checksums keyed by their
+// context relative paths, all in one transaction.
+func (c *Context) SerializeChecksums(checksums map[string]*Checksum) error {
+	db, release, err := c.OpenDB()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	return db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(byteify(ChecksumsKey))
+		if err != nil {
+			return err
+		}
+		if bucket == nil {
+			return ErrNoSuchDbBucket
+		}
+
+		for relPath, checksum := range checksums {
+			data, err := json.Marshal(checksum)
+			if err != nil {
+				return err
+			}
+			if err := bucket.Put(byteify(relPath), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// DeserializeChecksums returns all the stored checksums
+// keyed by their context relative paths.
+func (c *Context) DeserializeChecksums() (map[string]*Checksum, error) {
+	db, release, err := c.OpenDB()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	checksums := make(map[string]*Checksum)
+	err = db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(byteify(ChecksumsKey))
+		if bucket == nil {
+			// Nothing has been imported yet.
+			return nil
+		}
+
+		return bucket.ForEach(func(key, value []byte) error {
+			checksum := &Checksum{}
+			if err := json.Unmarshal(value, checksum); err != nil {
+				return err
+			}
+			checksums[string(key)] = checksum
+			return nil
+		})
+	})
+
+	return checksums, err
+}
+
 func (c *Context) Write() error {
-	data, err := json.Marshal(c)
+	if c.CacheId == "" {
+		c.CacheId = c.legacyCacheId()
+	}
+	persisted := c.persisted()
+	if c.InKeychain() {
+		var err error
+		if persisted, err = c.keychainPersisted(persisted); err != nil {
+			return err
+		}
+	}
+
+	data, err := json.Marshal(persisted)
+	if err != nil {
+		return err
+	}
+
+	if c.Encrypted {
+		if data, err = c.encryptCredentials(data); err != nil {
+			return err
+		}
+	}
+
+	// Write then rename so that a run reading the credentials while a
+	// refreshed token is being recorded never sees them half written,
+	// under a lock so that concurrent runs take turns recording theirs.
+	credentialsPath := path.Join(c.GDPath(), CredentialsJSON)
+	unlock, err := lockPath(credentialsPath + lockSuffix)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	tmp, err := ioutil.TempFile(c.GDPath(), CredentialsJSON+".tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
 	if err != nil {
+		os.Remove(tmp.Name())
 		return err
 	}
-	return ioutil.WriteFile(credentialsPath(c.AbsPath), data, 0600)
+	return os.Rename(tmp.Name(), credentialsPath)
 }
 
 func (c *Context) DeInitialize(prompter func(...interface{}) bool, returnOnAnyError bool) error {
-	rootDir := c.AbsPathOf("")
 	pathsToRemove := []string{
-		credentialsPath(rootDir),
-		DbSuffixedPath(rootDir),
+		path.Join(c.GDPath(), CredentialsJSON),
+		c.CacheFilePath(DriveDb),
 	}
 
 	for _, p := range pathsToRemove {
@@ -304,6 +778,9 @@ func (c *Context) DeInitialize(prompter func(...interface{}) bool, returnOnAnyEr
 		}
 
 		rmErr := os.RemoveAll(p)
+		if rmErr == nil && p == pathsToRemove[0] {
+			rmErr = c.ForgetKeychainTokens()
+		}
 		if rmErr != nil {
 			if returnOnAnyError {
 				return rmErr
@@ -315,30 +792,70 @@ func (c *Context) DeInitialize(prompter func(...interface{}) bool, returnOnAnyEr
 	return nil
 }
 
-func (c *Context) OpenDB() (*bolt.DB, error) {
-	dbPath := DbSuffixedPath(c.AbsPathOf(""))
-	db, err := bolt.Open(dbPath, O_RWForAll, nil)
+// OpenDB opens the db under a lock that closeDB releases after closing it,
+// so that concurrent runs take turns updating the indices even where bolt
+// doesn't lock the db for itself, as on Windows.
+func (c *Context) OpenDB() (db *bolt.DB, closeDB func() error, err error) {
+	if err := c.EnsureCachePath(); err != nil {
+		return nil, nil, err
+	}
+	dbPath := c.CacheFilePath(DriveDb)
+	unlock, err := lockPath(dbPath + lockSuffix)
 	if err != nil {
-		return db, err
+		return nil, nil, err
 	}
 
-	if db == nil {
-		return db, ErrDerefNilDB
+	db, err = bolt.Open(dbPath, O_RWForAll, &bolt.Options{Timeout: lockTimeout})
+	if err == nil && db == nil {
+		err = ErrDerefNilDB
+	}
+	if err != nil {
+		unlock()
+		return nil, nil, err
 	}
 
-	return db, nil
+	return db, func() error {
+		err := db.Close()
+		if unlockErr := unlock(); err == nil {
+			err = unlockErr
+		}
+		return err
+	}, nil
 }
 
 // Discovers the gd directory, if no gd directory or credentials
 // could be found for the path, returns ErrNoContext.
 func Discover(currentAbsPath string) (*Context, error) {
+	return DiscoverOuter(currentAbsPath, 0)
+}
+
+// DiscoverOuter discovers the context that encloses the nearest one to
+// currentAbsPath outer levels up, for contexts nested within others.
+// DiscoverOuter(p, 0) is the nearest context, as Discover(p) is.
+func DiscoverOuter(currentAbsPath string, outer int) (*Context, error) {
+	context, err := Locate(currentAbsPath, outer)
+	if err != nil {
+		return nil, err
+	}
+	if err := context.Read(); err != nil {
+		return nil, err
+	}
+	return context, nil
+}
+
+// Locate finds the context that DiscoverOuter would, without reading its
+// credentials e.g to look into a context whose credentials are damaged.
+func Locate(currentAbsPath string, outer int) (*Context, error) {
 	p := currentAbsPath
-	found := false
+	found, skipped := false, 0
 	for {
 		info, e := os.Stat(gdPath(p))
 		if e == nil && info.IsDir() {
-			found = true
-			break
+			if skipped >= outer {
+				found = true
+				break
+			}
+			skipped++
 		}
 		newPath := filepath.Join(p, "..")
 		if p == newPath {
@@ -348,17 +865,98 @@ func Discover(currentAbsPath string) (*Context, error) {
 	}
 
 	if !found {
+		if skipped > 0 {
+			return nil, ErrNoOuterContext
+		}
 		return nil, ErrNoDriveContext
 	}
-	context := &Context{AbsPath: p}
+	return &Context{AbsPath: p}, nil
+}
+
+// DiscoverWithGDDir loads the context whose metadata lives in the
+// external directory gdDir, as set up by InitializeWithGDDir. The context
+// is rooted where it was last bound to, unless that is gone, in which case
+// the tree is taken to have moved to currentAbsPath for it to be relocated.
+func DiscoverWithGDDir(gdDir, currentAbsPath string) (*Context, error) {
+	context := &Context{GDDir: gdDir}
 	if err := context.Read(); err != nil {
 		return nil, err
 	}
+	if context.LastKnownRoot == "" {
+		return nil, ErrNoContextRoot
+	}
+	context.AbsPath = context.LastKnownRoot
+	if _, err := os.Stat(context.LastKnownRoot); os.IsNotExist(err) {
+		context.AbsPath = currentAbsPath
+	}
+	return context, nil
+}
+
+// ApplicationDefaultContext returns a context rooted at absPath that was
+// never initialized and authenticates with the Application Default
+// Credentials, e.g in CI pipelines. Having no .gd directory, its metadata
+// lives in a directory of its own within the user's CacheHome.
+func ApplicationDefaultContext(absPath string) (*Context, error) {
+	cacheHome, err := CacheHome()
+	if err != nil {
+		return nil, err
+	}
+	gdDir := filepath.Join(cacheHome, "application-default", fmt.Sprintf("%x", sha1.Sum([]byte(absPath))))
+	if err := os.MkdirAll(gdDir, 0700); err != nil {
+		return nil, err
+	}
+
+	context := &Context{
+		Credentials:   Credentials{CredentialType: CredentialTypeApplicationDefault},
+		AbsPath:       absPath,
+		GDDir:         gdDir,
+		LastKnownRoot: absPath,
+	}
 	return context, nil
 }
 
 func Initialize(absPath string) (pathGD string, firstInit bool, c *Context, err error) {
-	pathGD = gdPath(absPath)
+	return initialize(absPath, "", "", "")
+}
+
+// InitializeWithGDDir initializes a context at absPath
+// whose metadata lives in the external directory gdDir.
+func InitializeWithGDDir(absPath, gdDir string) (pathGD string, firstInit bool, c *Context, err error) {
+	return initialize(absPath, gdDir, "", "")
+}
+
+// InitializeAccount initializes the named account of the context at
+// absPath, keeping the rest of its credentials. gdDir is as for
+// InitializeWithGDDir and may be empty.
+func InitializeAccount(absPath, gdDir, account string) (pathGD string, firstInit bool, c *Context, err error) {
+	return initialize(absPath, gdDir, account, "")
+}
+
+// InitializeRemote initializes the named remote of the context at absPath,
+// keeping the rest of its credentials. The remote uses the credentials of
+// account, or of an account of the same name as the remote if it is empty.
+// gdDir is as for InitializeWithGDDir and may be empty.
+func InitializeRemote(absPath, gdDir, remote, account string) (pathGD string, firstInit bool, c *Context, err error) {
+	return initialize(absPath, gdDir, account, remote)
+}
+
+func initialize(absPath, gdDir, account, remote string) (pathGD string, firstInit bool, c *Context, err error) {
+	c = &Context{AbsPath: absPath, GDDir: gdDir, LastKnownRoot: absPath}
+	if account != "" || remote != "" {
+		if err = c.Read(); err != nil && !os.IsNotExist(err) {
+			return
+		}
+		c.AbsPath, c.LastKnownRoot = absPath, absPath
+	}
+	if remote != "" {
+		err = c.CreateRemote(remote, account)
+	} else if account != "" {
+		err = c.CreateAccount(account)
+	}
+	if err != nil {
+		return
+	}
+	pathGD = c.GDPath()
 	sInfo, sErr := os.Stat(pathGD)
 	if sErr != nil {
 		if os.IsNotExist(sErr) {
@@ -375,7 +973,6 @@ func Initialize(absPath string) (pathGD string, firstInit bool, c *Context, err
 	if err = os.MkdirAll(pathGD, 0755); err != nil {
 		return
 	}
-	c = &Context{AbsPath: absPath}
 	err = c.Write()
 	return
 }
@@ -385,7 +982,7 @@ func gdPath(absPath string) string {
 }
 
 func credentialsPath(absPath string) string {
-	return path.Join(gdPath(absPath), "credentials.json")
+	return path.Join(gdPath(absPath), CredentialsJSON)
 }
 
 func DbSuffixedPath(dir string) string {
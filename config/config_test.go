@@ -0,0 +1,348 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2/jwt"
+)
+
+const (
+	helperContextEnv = "DRIVE_TEST_HELPER_CONTEXT"
+	helperCacheEnv   = "DRIVE_TEST_HELPER_CACHE"
+
+	interleavedIndices = 20
+)
+
+// testContext returns a context rooted at a fresh directory, whose
+// cache is kept under cacheHome.
+func testContext(t *testing.T, root, cacheHome string) *Context {
+	os.Setenv("XDG_CACHE_HOME", cacheHome)
+	c := &Context{AbsPath: root, CacheId: "test"}
+	if err := os.MkdirAll(c.GDPath(), 0700); err != nil {
+		t.Fatalf("mkdir %s: %v", c.GDPath(), err)
+	}
+	return c
+}
+
+// restoreCacheHome returns a func restoring XDG_CACHE_HOME to its current value.
+func restoreCacheHome() func() {
+	prev, had := os.LookupEnv("XDG_CACHE_HOME")
+	return func() {
+		if had {
+			os.Setenv("XDG_CACHE_HOME", prev)
+		} else {
+			os.Unsetenv("XDG_CACHE_HOME")
+		}
+	}
+}
+
+// tempContext returns a context rooted at a fresh directory, with a
+// func removing it.
+func tempContext(t *testing.T) (*Context, func()) {
+	root, err := ioutil.TempDir("", "context")
+	if err != nil {
+		t.Fatalf("tempdir: %v", err)
+	}
+	restore := restoreCacheHome()
+	c := testContext(t, root, filepath.Join(root, "cache"))
+	return c, func() {
+		restore()
+		os.RemoveAll(root)
+	}
+}
+
+// reread returns the context as a later run reads it back.
+func reread(t *testing.T, c *Context) *Context {
+	read := &Context{AbsPath: c.AbsPath}
+	if err := read.Read(); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	return read
+}
+
+func serializeIndices(t *testing.T, c *Context, prefix string, from, to int) {
+	for i := from; i < to; i++ {
+		index := &Index{FileId: fmt.Sprintf("%s%d", prefix, i), Etag: prefix}
+		if err := c.SerializeIndex(index); err != nil {
+			t.Fatalf("serializeIndex %s: %v", index.FileId, err)
+		}
+	}
+}
+
+func waitForFile(p string, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if _, err := os.Stat(p); err == nil {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return false
+}
+
+// TestHelperIndexWriter is the other drive process of
+// TestIndexUpdatesInterleaveAcrossProcesses, run only as such.
+func TestHelperIndexWriter(t *testing.T) {
+	root := os.Getenv(helperContextEnv)
+	if root == "" {
+		t.Skip("only run by TestIndexUpdatesInterleaveAcrossProcesses")
+	}
+	c := testContext(t, root, os.Getenv(helperCacheEnv))
+
+	serializeIndices(t, c, "child", 0, 1)
+	ioutil.WriteFile(filepath.Join(root, "child-started"), nil, 0600)
+
+	// Halfway through its own updates, wait for the
+	// other process to have made all of its updates.
+	if !waitForFile(filepath.Join(root, "parent-done"), 30*time.Second) {
+		t.Fatalf("the other process never got to update the index")
+	}
+	serializeIndices(t, c, "child", 1, interleavedIndices)
+}
+
+func TestIndexUpdatesInterleaveAcrossProcesses(t *testing.T) {
+	root, err := ioutil.TempDir("", "interleave")
+	if err != nil {
+		t.Fatalf("tempdir: %v", err)
+	}
+	defer os.RemoveAll(root)
+	cacheHome := filepath.Join(root, "cache")
+
+	defer restoreCacheHome()()
+	c := testContext(t, root, cacheHome)
+
+	child := exec.Command(os.Args[0], "-test.run=^TestHelperIndexWriter$")
+	child.Env = append(os.Environ(), helperContextEnv+"="+root, helperCacheEnv+"="+cacheHome)
+	output := make(chan []byte, 1)
+	errs := make(chan error, 1)
+	go func() {
+		out, err := child.CombinedOutput()
+		output <- out
+		errs <- err
+	}()
+
+	if !waitForFile(filepath.Join(root, "child-started"), 30*time.Second) {
+		t.Fatalf("the other process didn't start: %s", <-output)
+	}
+
+	// The other process is midway through its updates, so this only
+	// gets through if it doesn't hold the db in between updates.
+	serializeIndices(t, c, "parent", 0, interleavedIndices)
+	ioutil.WriteFile(filepath.Join(root, "parent-done"), nil, 0600)
+
+	out := <-output
+	if err := <-errs; err != nil {
+		t.Fatalf("the other process: %v\n%s", err, out)
+	}
+
+	for _, prefix := range []string{"parent", "child"} {
+		for i := 0; i < interleavedIndices; i++ {
+			key := fmt.Sprintf("%s%d", prefix, i)
+			index, err := c.DeserializeIndex(key)
+			if err != nil || index == nil || index.Etag != prefix {
+				t.Errorf("index %s: got %v, %v", key, index, err)
+			}
+		}
+	}
+}
+
+func TestCacheFilePath(t *testing.T) {
+	root, err := ioutil.TempDir("", "cache-path")
+	if err != nil {
+		t.Fatalf("tempdir: %v", err)
+	}
+	defer os.RemoveAll(root)
+	defer restoreCacheHome()()
+	c := testContext(t, root, filepath.Join(root, "cache"))
+
+	cachePath, err := c.CachePath()
+	if err != nil {
+		t.Fatalf("CachePath: %v", err)
+	}
+	cachedPath := filepath.Join(cachePath, "status.json")
+	if got := c.CacheFilePath("status.json"); got != cachedPath {
+		t.Errorf("fresh: got %s want %s", got, cachedPath)
+	}
+	if _, err := os.Stat(cachePath); !os.IsNotExist(err) {
+		t.Errorf("expected CacheFilePath not to create %s: %v", cachePath, err)
+	}
+
+	legacyPath := filepath.Join(c.GDPath(), "status.json")
+	if err := ioutil.WriteFile(legacyPath, []byte("legacy"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.CacheFilePath("status.json"); got != legacyPath {
+		t.Errorf("legacy: got %s want %s used in place", got, legacyPath)
+	}
+	if _, err := os.Stat(legacyPath); err != nil {
+		t.Errorf("expected CacheFilePath not to move %s: %v", legacyPath, err)
+	}
+
+	if err := c.EnsureCachePath("status.json"); err != nil {
+		t.Fatalf("EnsureCachePath: %v", err)
+	}
+	if got := c.CacheFilePath("status.json"); got != cachedPath {
+		t.Errorf("migrated: got %s want %s", got, cachedPath)
+	}
+	if data, err := ioutil.ReadFile(cachedPath); err != nil || string(data) != "legacy" {
+		t.Errorf("expected the legacy file to be moved, got %q, %v", data, err)
+	}
+
+	// A legacy file that the cache already has is stale and left alone.
+	if err := ioutil.WriteFile(legacyPath, []byte("stale"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.EnsureCachePath("status.json"); err != nil {
+		t.Fatalf("EnsureCachePath: %v", err)
+	}
+	if data, err := ioutil.ReadFile(cachedPath); err != nil || string(data) != "legacy" {
+		t.Errorf("expected the cached file to be kept, got %q, %v", data, err)
+	}
+	if got := c.CacheFilePath("status.json"); got != cachedPath {
+		t.Errorf("stale: got %s want %s", got, cachedPath)
+	}
+}
+
+func TestCredentialTypes(t *testing.T) {
+	gsa := &jwt.Config{Email: "sa@example.iam.gserviceaccount.com"}
+	cases := []struct {
+		desc    string
+		creds   Credentials
+		want    string
+		service bool
+		err     error
+	}{
+		{"legacy oauth2", Credentials{RefreshToken: "r"}, CredentialTypeOAuth2, false, nil},
+		{"legacy service account", Credentials{GSAJWTConfig: gsa}, CredentialTypeServiceAccount, true, nil},
+		{"service account", Credentials{CredentialType: CredentialTypeServiceAccount, GSAJWTConfig: gsa}, CredentialTypeServiceAccount, true, nil},
+		{"service account without config", Credentials{CredentialType: CredentialTypeServiceAccount}, CredentialTypeServiceAccount, true, ErrNoServiceAccountConfig},
+		{"application default", Credentials{CredentialType: CredentialTypeApplicationDefault}, CredentialTypeApplicationDefault, false, nil},
+	}
+
+	for _, tc := range cases {
+		creds := tc.creds
+		if err := creds.normalize(); err != tc.err {
+			t.Errorf("%s: got err %v want %v", tc.desc, err, tc.err)
+			continue
+		}
+		if creds.CredentialType != tc.want {
+			t.Errorf("%s: got type %q want %q", tc.desc, creds.CredentialType, tc.want)
+		}
+		if got := creds.IsServiceAccount(); got != tc.service {
+			t.Errorf("%s: IsServiceAccount got %v want %v", tc.desc, got, tc.service)
+		}
+	}
+
+	unknown := Credentials{CredentialType: "password"}
+	if err := unknown.normalize(); err == nil {
+		t.Errorf("expected an unknown credential type to be refused")
+	}
+}
+
+func TestCacheHomeRequiresHome(t *testing.T) {
+	defer restoreCacheHome()()
+	prevHome, hadHome := os.LookupEnv("HOME")
+	defer func() {
+		if hadHome {
+			os.Setenv("HOME", prevHome)
+		}
+	}()
+	os.Unsetenv("XDG_CACHE_HOME")
+	os.Unsetenv("HOME")
+
+	if cacheHome, err := CacheHome(); err != ErrNoCacheHome {
+		t.Fatalf("got %q, %v want %v", cacheHome, err, ErrNoCacheHome)
+	}
+
+	c := &Context{AbsPath: "/tmp/context", CacheId: "test"}
+	if err := c.EnsureCachePath(DriveDb); err != ErrNoCacheHome {
+		t.Errorf("EnsureCachePath: got %v want %v", err, ErrNoCacheHome)
+	}
+	if got, want := c.CacheFilePath(DriveDb), filepath.Join(c.GDPath(), DriveDb); got != want {
+		t.Errorf("CacheFilePath: got %s want %s", got, want)
+	}
+}
+
+func TestImpersonatedSubject(t *testing.T) {
+	c := &Context{}
+	c.Subject = "user@example.com"
+	if got := c.ImpersonatedSubject(); got != "user@example.com" {
+		t.Errorf("got %q want the subject of the credentials", got)
+	}
+	c.Impersonate = "admin@example.com"
+	if got := c.ImpersonatedSubject(); got != "admin@example.com" {
+		t.Errorf("got %q want the user impersonated for the run", got)
+	}
+}
+
+func TestWriteIsAtomic(t *testing.T) {
+	c, done := tempContext(t)
+	defer done()
+	if err := c.Write(); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			w := &Context{AbsPath: c.AbsPath, CacheId: c.CacheId}
+			w.RefreshToken = string(make([]byte, 1<<12+i))
+			for j := 0; j < 20; j++ {
+				if err := w.Write(); err != nil {
+					t.Errorf("write: %v", err)
+					return
+				}
+			}
+		}(i)
+	}
+	go func() {
+		wg.Wait()
+		close(stop)
+	}()
+
+	credentialsPath := filepath.Join(c.GDPath(), CredentialsJSON)
+	for {
+		select {
+		case <-stop:
+			matches, _ := filepath.Glob(filepath.Join(c.GDPath(), CredentialsJSON+".tmp*"))
+			if len(matches) > 0 {
+				t.Errorf("expected no temporary files left behind, got %v", matches)
+			}
+			return
+		default:
+		}
+		data, err := ioutil.ReadFile(credentialsPath)
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		var read Context
+		if err := json.Unmarshal(data, &read); err != nil {
+			t.Fatalf("read the credentials half written: %v", err)
+		}
+	}
+}
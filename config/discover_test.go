@@ -0,0 +1,186 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDiscoverRelocated(t *testing.T) {
+	c, done := tempContext(t)
+	defer done()
+
+	before := filepath.Join(c.AbsPath, "tree")
+	if _, _, _, err := Initialize(before); err != nil {
+		t.Fatalf("init: %v", err)
+	}
+	discovered, err := Discover(before)
+	if err != nil {
+		t.Fatalf("discover: %v", err)
+	}
+	if discovered.Relocated() {
+		t.Errorf("expected the context not to be relocated where it was initialized")
+	}
+
+	after := filepath.Join(c.AbsPath, "moved")
+	if err := os.Rename(before, after); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(after, "sub"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	discovered, err = Discover(filepath.Join(after, "sub"))
+	if err != nil {
+		t.Fatalf("discover: %v", err)
+	}
+	if discovered.AbsPath != after || !discovered.Relocated() {
+		t.Errorf("expected the moved context at %s to be relocated, got %s last bound to %s", after, discovered.AbsPath, discovered.LastKnownRoot)
+	}
+
+	// Contexts that predate LastKnownRoot are never taken to have moved.
+	discovered.LastKnownRoot = ""
+	if discovered.Relocated() {
+		t.Errorf("expected a context without LastKnownRoot not to be relocated")
+	}
+}
+
+func TestDiscoverWithGDDir(t *testing.T) {
+	c, done := tempContext(t)
+	defer done()
+
+	tree := filepath.Join(c.AbsPath, "tree")
+	gdDir := filepath.Join(c.AbsPath, "metadata")
+	if err := os.MkdirAll(tree, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := InitializeWithGDDir(tree, gdDir); err != nil {
+		t.Fatalf("init: %v", err)
+	}
+	if _, err := os.Stat(gdPath(tree)); !os.IsNotExist(err) {
+		t.Errorf("expected no metadata directory within the tree: %v", err)
+	}
+
+	discovered, err := DiscoverWithGDDir(gdDir, "/elsewhere")
+	if err != nil {
+		t.Fatalf("discover: %v", err)
+	}
+	if discovered.AbsPath != tree || discovered.Relocated() {
+		t.Errorf("expected the context rooted where it was bound, got %s", discovered.AbsPath)
+	}
+
+	moved := filepath.Join(c.AbsPath, "moved")
+	if err := os.Rename(tree, moved); err != nil {
+		t.Fatal(err)
+	}
+	discovered, err = DiscoverWithGDDir(gdDir, moved)
+	if err != nil {
+		t.Fatalf("discover: %v", err)
+	}
+	if discovered.AbsPath != moved || !discovered.Relocated() {
+		t.Errorf("expected the moved tree at %s to be relocated, got %s", moved, discovered.AbsPath)
+	}
+
+	unbound := &Context{GDDir: filepath.Join(c.AbsPath, "unbound")}
+	if err := os.MkdirAll(unbound.GDDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := unbound.Write(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DiscoverWithGDDir(unbound.GDDir, moved); err != ErrNoContextRoot {
+		t.Errorf("without a root: got %v want %v", err, ErrNoContextRoot)
+	}
+}
+
+func TestUseGDDirName(t *testing.T) {
+	prev := GDDirSuffix
+	defer func() {
+		GDDirSuffix = prev
+	}()
+
+	for _, name := range []string{"", ".", "..", "a/b", `a\b`} {
+		if err := UseGDDirName(name); err == nil {
+			t.Errorf("expected %q to be refused", name)
+		}
+	}
+	if GDDirSuffix != prev {
+		t.Errorf("expected refused names to leave %q in place, got %q", prev, GDDirSuffix)
+	}
+
+	c, done := tempContext(t)
+	defer done()
+	if err := UseGDDirName("_gd"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := Initialize(c.AbsPath); err != nil {
+		t.Fatalf("init: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(c.AbsPath, "_gd", CredentialsJSON)); err != nil {
+		t.Errorf("expected the context initialized within _gd: %v", err)
+	}
+	if _, err := Discover(c.AbsPath); err != nil {
+		t.Errorf("expected the context discovered by _gd: %v", err)
+	}
+}
+
+func TestApplicationDefaultContext(t *testing.T) {
+	c, done := tempContext(t)
+	defer done()
+	cacheHome, err := CacheHome()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	adc, err := ApplicationDefaultContext("/srv/ci/checkout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !adc.IsApplicationDefault() || adc.IsServiceAccount() {
+		t.Errorf("expected the Application Default Credentials, got %q", adc.CredentialType)
+	}
+	if adc.Relocated() {
+		t.Errorf("expected the context not to be relocated")
+	}
+
+	// The metadata is kept where only the user can get at it.
+	if filepath.Dir(filepath.Dir(adc.GDDir)) != cacheHome {
+		t.Errorf("expected the metadata within %s, got %s", cacheHome, adc.GDDir)
+	}
+	info, err := os.Lstat(adc.GDDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.IsDir() || info.Mode().Perm() != 0700 {
+		t.Errorf("expected a directory private to the user, got %v", info.Mode())
+	}
+
+	other, err := ApplicationDefaultContext("/srv/ci/other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.GDDir == adc.GDDir {
+		t.Errorf("expected each tree to have metadata of its own, both got %s", adc.GDDir)
+	}
+
+	os.Unsetenv("XDG_CACHE_HOME")
+	prevHome := os.Getenv("HOME")
+	defer os.Setenv("HOME", prevHome)
+	os.Unsetenv("HOME")
+	if _, err := ApplicationDefaultContext(c.AbsPath); err != ErrNoCacheHome {
+		t.Errorf("without a home directory: got %v want %v", err, ErrNoCacheHome)
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// lockTimeout is how long to wait for another drive process to release
+// a lock before giving up.
+const lockTimeout = 2 * time.Minute
+
+const lockPollInterval = 100 * time.Millisecond
+
+var ErrLockTimeout = errors.New("timed out waiting for another drive process to finish")
+
+// lockPath takes an advisory lock on p, creating it if need be. If another
+// drive process holds it, lockPath says so and waits up to lockTimeout for
+// it to be released. unlock releases it.
+// The lock is only advisory: it keeps out other drive processes that
+// take it too, not anything else that touches the files it guards.
+func lockPath(p string) (unlock func() error, err error) {
+	f, err := os.OpenFile(p, os.O_RDWR|os.O_CREATE, O_RWForAll)
+	if err != nil {
+		return nil, err
+	}
+
+	locked, err := tryLockFile(f)
+	if err == nil && !locked {
+		fmt.Fprintf(os.Stderr, "waiting for another drive process to release %s\n", p)
+		deadline := time.Now().Add(lockTimeout)
+		for err == nil && !locked && time.Now().Before(deadline) {
+			time.Sleep(lockPollInterval)
+			locked, err = tryLockFile(f)
+		}
+		if err == nil && !locked {
+			err = ErrLockTimeout
+		}
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return func() error {
+		err := unlockFile(f)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		return err
+	}, nil
+}
@@ -0,0 +1,168 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// Credential stores that refresh tokens can be kept in.
+const (
+	CredentialStoreFile     = "file"
+	CredentialStoreKeychain = "keychain"
+)
+
+// keychainService is the service under which refresh tokens are stored.
+const keychainService = "drive"
+
+var (
+	ErrNoKeychain           = errors.New("no OS keychain is supported on this platform")
+	errKeychainItemNotFound = errors.New("keychain item not found")
+)
+
+// keychain is an OS credential store e.g macOS Keychain, GNOME Keyring
+// via libsecret or Windows Credential Manager.
+type keychain interface {
+	get(item string) (string, error)
+	set(item, secret string) error
+	delete(item string) error
+}
+
+// SetCredentialStore selects where the refresh tokens are stored from
+// the next Write on, either the credentials file or the OS keychain.
+func (c *Context) SetCredentialStore(store string) error {
+	switch store {
+	case CredentialStoreFile:
+		store = ""
+	case CredentialStoreKeychain:
+		if osKeychain == nil {
+			return ErrNoKeychain
+		}
+		if c.KeychainId == "" {
+			id := make([]byte, 16)
+			if _, err := rand.Read(id); err != nil {
+				return err
+			}
+			c.KeychainId = fmt.Sprintf("%x", id)
+		}
+	default:
+		return fmt.Errorf("unknown credential store %q, expecting %q or %q", store, CredentialStoreFile, CredentialStoreKeychain)
+	}
+	c.CredentialStore = store
+	return nil
+}
+
+// InKeychain reports whether the refresh tokens are stored in the OS keychain.
+func (c *Context) InKeychain() bool {
+	return c.CredentialStore == CredentialStoreKeychain
+}
+
+// keychainItem names the keychain item of the named account's
+// refresh token, "" being the default account.
+func (c *Context) keychainItem(account string) string {
+	if account == "" {
+		return c.KeychainId
+	}
+	return c.KeychainId + "/" + account
+}
+
+// keychainCredentials returns the credentials of each account, keyed
+// by account name with "" for the default, in the stored context p.
+func keychainCredentials(p *Context) map[string]*Credentials {
+	all := map[string]*Credentials{"": &p.Credentials}
+	for name, creds := range p.Accounts {
+		all[name] = creds
+	}
+	return all
+}
+
+// readKeychainTokens fills in the refresh tokens that were
+// stored in the keychain in place of the credentials file.
+func (c *Context) readKeychainTokens() error {
+	if osKeychain == nil {
+		return ErrNoKeychain
+	}
+	for name, creds := range keychainCredentials(c) {
+		if creds.IsServiceAccount() {
+			continue
+		}
+		token, err := osKeychain.get(c.keychainItem(name))
+		if err == errKeychainItemNotFound {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("keychain: %v", err)
+		}
+		creds.RefreshToken = token
+	}
+	return nil
+}
+
+// keychainPersisted stores the refresh tokens of the stored context p in
+// the keychain and returns a copy of p without them for the credentials file.
+func (c *Context) keychainPersisted(p *Context) (*Context, error) {
+	if osKeychain == nil {
+		return nil, ErrNoKeychain
+	}
+
+	stored := *p
+	if len(p.Accounts) > 0 {
+		stored.Accounts = make(map[string]*Credentials, len(p.Accounts))
+		for name, creds := range p.Accounts {
+			copied := *creds
+			stored.Accounts[name] = &copied
+		}
+	}
+
+	for name, creds := range keychainCredentials(&stored) {
+		item := c.keychainItem(name)
+		var err error
+		if creds.RefreshToken == "" {
+			if err = osKeychain.delete(item); err == errKeychainItemNotFound {
+				err = nil
+			}
+		} else {
+			err = osKeychain.set(item, creds.RefreshToken)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("keychain: %v", err)
+		}
+		creds.RefreshToken = ""
+		// Access tokens are short lived, so rather than also keeping
+		// them in the keychain, they just aren't kept at all.
+		creds.AccessToken, creds.TokenExpiry = "", 0
+	}
+	return &stored, nil
+}
+
+// ForgetKeychainTokens removes the context's refresh tokens from the
+// keychain, e.g once they have been moved back to the credentials file.
+func (c *Context) ForgetKeychainTokens() error {
+	if c.KeychainId == "" || osKeychain == nil {
+		return nil
+	}
+	names := []string{""}
+	for name := range c.Accounts {
+		names = append(names, name)
+	}
+	for _, name := range names {
+		if err := osKeychain.delete(c.keychainItem(name)); err != nil && err != errKeychainItemNotFound {
+			return fmt.Errorf("keychain: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// securityKeychain keeps items in the macOS Keychain through security(1).
+type securityKeychain struct{}
+
+var osKeychain keychain = securityKeychain{}
+
+// command is security(1) run with args, with stdin as its input if any.
+func (securityKeychain) command(stdin string, args ...string) *exec.Cmd {
+	cmd := exec.Command("security", args...)
+	if stdin != "" {
+		cmd.Stdin = strings.NewReader(stdin)
+	}
+	return cmd
+}
+
+func (k securityKeychain) run(stdin string, args ...string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := k.command(stdin, args...)
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		if strings.Contains(stderr.String(), "could not be found") {
+			return "", errKeychainItemNotFound
+		}
+		return "", fmt.Errorf("security %s: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimRight(string(output), "\n"), nil
+}
+
+func (k securityKeychain) get(item string) (string, error) {
+	return k.run("", "find-generic-password", "-s", keychainService, "-a", item, "-w")
+}
+
+// quoteSecurityArg quotes arg for a command line read by security -i,
+// which splits its input as a shell would.
+func quoteSecurityArg(arg string) string {
+	arg = strings.Replace(arg, `\`, `\\`, -1)
+	arg = strings.Replace(arg, `"`, `\"`, -1)
+	return `"` + arg + `"`
+}
+
+// setCommand adds or updates item. The secret is never passed as an
+// argument, where any process could read it: security -i reads the whole
+// add-generic-password command, the secret included, from stdin.
+func (k securityKeychain) setCommand(item, secret string) (*exec.Cmd, error) {
+	if strings.ContainsAny(secret, "\r\n") || strings.ContainsAny(item, "\r\n") {
+		return nil, fmt.Errorf("security add-generic-password: the item and secret must be a single line")
+	}
+	args := []string{"add-generic-password", "-U", "-s", keychainService, "-a", item, "-l", "drive refresh token", "-w", secret}
+	for i, arg := range args {
+		args[i] = quoteSecurityArg(arg)
+	}
+	return k.command(strings.Join(args, " ")+"\n", "-i"), nil
+}
+
+func (k securityKeychain) set(item, secret string) error {
+	cmd, err := k.setCommand(item, secret)
+	if err != nil {
+		return err
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("security add-generic-password: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	// security -i carries on past a failed command, so whatever it reports
+	// on stderr is the failure.
+	if stderr.Len() > 0 {
+		return fmt.Errorf("security add-generic-password: %s", strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+func (k securityKeychain) delete(item string) error {
+	_, err := k.run("", "delete-generic-password", "-s", keychainService, "-a", item)
+	return err
+}
@@ -0,0 +1,85 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"reflect"
+	"testing"
+)
+
+func TestSecurityKeychainSecretNotInArgs(t *testing.T) {
+	secret := `1/refresh-token-s3cret"\`
+	cmd, err := securityKeychain{}.setCommand("item", secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"security", "-i"}; !reflect.DeepEqual(cmd.Args, want) {
+		t.Errorf("args: got %q want %q", cmd.Args, want)
+	}
+
+	if cmd.Stdin == nil {
+		t.Fatalf("expected the command to be written to stdin")
+	}
+	input, err := ioutil.ReadAll(cmd.Stdin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `"add-generic-password" "-U" "-s" "drive" "-a" "item" "-l" "drive refresh token" "-w" "1/refresh-token-s3cret\"\\"` + "\n"
+	if string(input) != want {
+		t.Errorf("stdin: got %q want %q", input, want)
+	}
+
+	if _, err := (securityKeychain{}).setCommand("item", "two\nlines"); err == nil {
+		t.Errorf("expected a secret of more than a line to be refused")
+	}
+}
+
+func TestSecurityKeychainRoundTrip(t *testing.T) {
+	if testing.Short() {
+		t.Skip("writes to the login keychain")
+	}
+	if _, err := exec.LookPath("security"); err != nil {
+		t.Skip(err)
+	}
+
+	k := securityKeychain{}
+	item := fmt.Sprintf("drive-test-%d", os.Getpid())
+	defer k.delete(item)
+
+	for _, secret := range []string{`1//0g-refresh "token"\`, "rotated token"} {
+		if err := k.set(item, secret); err != nil {
+			t.Fatal(err)
+		}
+		got, err := k.get(item)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != secret {
+			t.Errorf("got %q want %q", got, secret)
+		}
+	}
+
+	if err := k.delete(item); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := k.get(item); err != errKeychainItemNotFound {
+		t.Errorf("after delete: got %v want %v", err, errKeychainItemNotFound)
+	}
+}
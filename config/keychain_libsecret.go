@@ -0,0 +1,65 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build linux dragonfly freebsd netbsd openbsd
+
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// secretToolKeychain keeps items in the Secret Service e.g GNOME Keyring
+// or KWallet through libsecret's secret-tool(1).
+type secretToolKeychain struct{}
+
+var osKeychain keychain = secretToolKeychain{}
+
+func (secretToolKeychain) run(stdin string, args ...string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("secret-tool", args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		// lookup and clear fail without a message for missing items.
+		if _, exited := err.(*exec.ExitError); exited && msg == "" {
+			return "", errKeychainItemNotFound
+		}
+		return "", fmt.Errorf("secret-tool %s: %v: %s", args[0], err, msg)
+	}
+	return string(output), nil
+}
+
+func (k secretToolKeychain) get(item string) (string, error) {
+	secret, err := k.run("", "lookup", "service", keychainService, "account", item)
+	if err == nil && secret == "" {
+		err = errKeychainItemNotFound
+	}
+	return secret, err
+}
+
+func (k secretToolKeychain) set(item, secret string) error {
+	_, err := k.run(secret, "store", "--label=drive refresh token", "service", keychainService, "account", item)
+	return err
+}
+
+func (k secretToolKeychain) delete(item string) error {
+	_, err := k.run("", "clear", "service", keychainService, "account", item)
+	return err
+}
@@ -0,0 +1,94 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSetCredentialStore(t *testing.T) {
+	defer withKeychain(nil)()
+	c := &Context{}
+	if err := c.SetCredentialStore(CredentialStoreKeychain); err != ErrNoKeychain {
+		t.Errorf("without a keychain: got %v want %v", err, ErrNoKeychain)
+	}
+	if err := c.SetCredentialStore("vault"); err == nil {
+		t.Errorf("expected an unknown store to be refused")
+	}
+
+	withKeychain(memKeychain{})
+	if err := c.SetCredentialStore(CredentialStoreKeychain); err != nil {
+		t.Fatalf("keychain: %v", err)
+	}
+	if !c.InKeychain() || c.KeychainId == "" {
+		t.Errorf("expected the keychain to be selected under an id, got %q %q", c.CredentialStore, c.KeychainId)
+	}
+	if err := c.SetCredentialStore(CredentialStoreFile); err != nil {
+		t.Fatalf("file: %v", err)
+	}
+	if c.InKeychain() || c.CredentialStore != "" {
+		t.Errorf("expected the credentials file to be selected, got %q", c.CredentialStore)
+	}
+}
+
+func TestKeychainKeepsRefreshTokens(t *testing.T) {
+	k := memKeychain{}
+	defer withKeychain(k)()
+	c, done := tempContext(t)
+	defer done()
+
+	c.Credentials = Credentials{ClientId: "id", RefreshToken: "default-token"}
+	if err := c.CreateAccount("work"); err != nil {
+		t.Fatal(err)
+	}
+	c.RefreshToken, c.AccessToken, c.TokenExpiry = "work-token", "access", 1
+	if err := c.SetCredentialStore(CredentialStoreKeychain); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Write(); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(c.GDPath(), CredentialsJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"default-token", "work-token", "access"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("expected %q to be kept out of the credentials file: %s", secret, data)
+		}
+	}
+	if k[c.keychainItem("")] != "default-token" || k[c.keychainItem("work")] != "work-token" {
+		t.Errorf("expected the refresh tokens in the keychain, got %v", k)
+	}
+	if c.RefreshToken != "work-token" || c.AccessToken != "access" {
+		t.Errorf("expected the context in use to keep its tokens, got %+v", c.Credentials)
+	}
+
+	read := reread(t, c)
+	if read.RefreshToken != "default-token" || read.Accounts["work"].RefreshToken != "work-token" {
+		t.Errorf("expected the refresh tokens read back from the keychain, got %+v %+v", read.Credentials, read.Accounts["work"])
+	}
+
+	if err := read.ForgetKeychainTokens(); err != nil {
+		t.Fatalf("forget: %v", err)
+	}
+	if len(k) != 0 {
+		t.Errorf("expected the keychain items removed, got %v", k)
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/boltdb/bolt"
+)
+
+// LegacyIndicesDir is where versions of drive that predate the db kept
+// each index in a file of its own, named by the file id of the index.
+func (c *Context) LegacyIndicesDir() string {
+	return filepath.Join(c.GDPath(), IndicesKey)
+}
+
+// ImportLegacyIndices moves the indices kept in LegacyIndicesDir into the
+// db, removing the directory once it is empty. An index that the db
+// already holds is newer and is kept. imported is how many of the files
+// were folded into the db, while those that aren't indices are left in
+// place and reported through skipped.
+func (c *Context) ImportLegacyIndices() (imported int, skipped []string, err error) {
+	dir := c.LegacyIndicesDir()
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return 0, nil, err
+	}
+
+	legacy := make(map[string][]byte)
+	for _, fi := range infos {
+		p := filepath.Join(dir, fi.Name())
+		if !fi.Mode().IsRegular() {
+			skipped = append(skipped, p)
+			continue
+		}
+		data, rErr := ioutil.ReadFile(p)
+		if rErr != nil {
+			return 0, skipped, rErr
+		}
+		var index Index
+		if json.Unmarshal(data, &index) != nil || index.FileId != fi.Name() {
+			skipped = append(skipped, p)
+			continue
+		}
+		legacy[fi.Name()] = data
+	}
+
+	db, release, err := c.OpenDB()
+	if err != nil {
+		return 0, skipped, err
+	}
+	defer release()
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(byteify(IndicesKey))
+		if err != nil {
+			return err
+		}
+		for fileId, data := range legacy {
+			if bucket.Get(byteify(fileId)) != nil {
+				continue
+			}
+			if err := bucket.Put(byteify(fileId), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, skipped, err
+	}
+
+	for fileId := range legacy {
+		if err := os.Remove(filepath.Join(dir, fileId)); err != nil {
+			return len(legacy), skipped, fmt.Errorf("imported %s but couldn't remove it: %v", fileId, err)
+		}
+	}
+	if len(skipped) < 1 {
+		err = os.Remove(dir)
+	}
+	return len(legacy), skipped, err
+}
@@ -0,0 +1,293 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh/terminal"
+
+	"github.com/odeke-em/drive/src/dcrypto"
+)
+
+const (
+	// CredentialsPassphraseEnvKey provides the passphrase non-interactively.
+	CredentialsPassphraseEnvKey = "DRIVE_CREDENTIALS_PASSPHRASE"
+
+	// CredentialsPassphraseCommandEnvKey is a shell command whose output is
+	// the passphrase, for passphrases kept by an agent e.g `pass show drive`.
+	CredentialsPassphraseCommandEnvKey = "DRIVE_CREDENTIALS_PASSPHRASE_COMMAND"
+
+	// PassphraseCacheTTL is how long a prompted passphrase is remembered.
+	PassphraseCacheTTL = 15 * time.Minute
+)
+
+var (
+	encryptedCredentialsMagic = []byte("drive-encrypted-credentials\n")
+
+	ErrPassphraseMismatch   = errors.New("passphrases do not match")
+	ErrEmptyPassphrase      = errors.New("passphrase must be non-empty")
+	ErrWrongPassphrase      = errors.New("could not decrypt the credentials, wrong passphrase?")
+	ErrNoPassphraseTerminal = errors.New("the credentials are encrypted but there is no terminal to prompt for the passphrase; set " + CredentialsPassphraseEnvKey)
+)
+
+// SetPassphrase encrypts the credentials with passphrase from the next
+// Write on. A nil passphrase stores the credentials in plain text.
+func (c *Context) SetPassphrase(passphrase []byte) {
+	c.passphrase = passphrase
+	c.Encrypted = len(passphrase) > 0
+}
+
+func (c *Context) encryptCredentials(plain []byte) ([]byte, error) {
+	passphrase, err := c.credentialsPassphrase(true)
+	if err != nil {
+		return nil, err
+	}
+
+	encReader, err := dcrypto.NewEncrypter(bytes.NewReader(plain), passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	cipher, err := ioutil.ReadAll(encReader)
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte{}, encryptedCredentialsMagic...), cipher...), nil
+}
+
+func (c *Context) decryptCredentials(cipher []byte) ([]byte, error) {
+	passphrase, err := c.credentialsPassphrase(false)
+	if err != nil {
+		return nil, err
+	}
+
+	decReader, err := dcrypto.NewDecrypter(bytes.NewReader(cipher), passphrase)
+	if err == nil {
+		var plain []byte
+		plain, err = ioutil.ReadAll(decReader)
+		decReader.Close()
+		if err == nil {
+			c.cachePassphrase(passphrase)
+			return plain, nil
+		}
+	}
+
+	// A stale cached passphrase shouldn't keep on failing.
+	c.forgetPassphrase()
+	return nil, ErrWrongPassphrase
+}
+
+// credentialsPassphrase looks for the passphrase in memory, the environment,
+// the passphrase command and the session cache, before prompting for it.
+func (c *Context) credentialsPassphrase(confirm bool) ([]byte, error) {
+	if len(c.passphrase) > 0 {
+		return c.passphrase, nil
+	}
+
+	passphrase, err := passphraseFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	if len(passphrase) < 1 {
+		passphrase = c.cachedPassphrase()
+	}
+	if len(passphrase) < 1 {
+		if passphrase, err = PromptPassphrase(fmt.Sprintf("Passphrase for %s: ", c.GDPath()), confirm); err != nil {
+			return nil, err
+		}
+	}
+
+	c.passphrase = passphrase
+	return passphrase, nil
+}
+
+func passphraseFromEnv() ([]byte, error) {
+	if passphrase := os.Getenv(CredentialsPassphraseEnvKey); passphrase != "" {
+		return []byte(passphrase), nil
+	}
+
+	command := os.Getenv(CredentialsPassphraseCommandEnvKey)
+	if command == "" {
+		return nil, nil
+	}
+
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stderr = os.Stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", CredentialsPassphraseCommandEnvKey, err)
+	}
+	return []byte(strings.TrimRight(string(output), "\r\n")), nil
+}
+
+// PromptPassphrase reads a passphrase from the terminal without echoing it.
+// If confirm is set, the passphrase has to be entered twice.
+func PromptPassphrase(prompt string, confirm bool) ([]byte, error) {
+	fd := int(os.Stdin.Fd())
+	if !terminal.IsTerminal(fd) {
+		return nil, ErrNoPassphraseTerminal
+	}
+
+	fmt.Fprint(os.Stderr, prompt)
+	passphrase, err := terminal.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return nil, err
+	}
+	if len(passphrase) < 1 {
+		return nil, ErrEmptyPassphrase
+	}
+
+	if !confirm {
+		return passphrase, nil
+	}
+
+	fmt.Fprint(os.Stderr, "Confirm passphrase: ")
+	again, err := terminal.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(passphrase, again) {
+		return nil, ErrPassphraseMismatch
+	}
+	return passphrase, nil
+}
+
+// passphraseKeychainItem names the keychain item that
+// a session's passphrase is remembered in.
+func (c *Context) passphraseKeychainItem() string {
+	return fmt.Sprintf("passphrase/%x", sha1.Sum([]byte(c.GDPath())))
+}
+
+// passphraseCachePath is where a session's passphrase is remembered if
+// there is no OS keychain to keep it in. It is only available within
+// XDG_RUNTIME_DIR, which is private to the user and cleared on logout,
+// and is usually kept in memory rather than on disk.
+func (c *Context) passphraseCachePath() string {
+	runtimeDir := os.Getenv("XDG_RUNTIME_DIR")
+	if runtimeDir == "" {
+		return ""
+	}
+	return filepath.Join(runtimeDir, "drive", fmt.Sprintf("%x", sha1.Sum([]byte(c.GDPath()))))
+}
+
+// cachedPassphrase returns the passphrase remembered for the session,
+// from the OS keychain or else the runtime directory, if it hasn't expired.
+func (c *Context) cachedPassphrase() []byte {
+	if osKeychain != nil {
+		if value, err := osKeychain.get(c.passphraseKeychainItem()); err == nil {
+			passphrase, expiry, err := parseCachedPassphrase(value)
+			if err == nil && time.Now().Before(expiry) {
+				return passphrase
+			}
+			osKeychain.delete(c.passphraseKeychainItem())
+		}
+	}
+
+	cachePath := c.passphraseCachePath()
+	if cachePath == "" {
+		return nil
+	}
+
+	fi, err := os.Stat(cachePath)
+	if err != nil {
+		return nil
+	}
+	// A cache that others can read, or that outlived its TTL, is dropped.
+	if fi.Mode().Perm()&0077 != 0 || time.Since(fi.ModTime()) > PassphraseCacheTTL {
+		os.Remove(cachePath)
+		return nil
+	}
+
+	passphrase, err := ioutil.ReadFile(cachePath)
+	if err != nil {
+		return nil
+	}
+	return passphrase
+}
+
+// cachePassphrase remembers the passphrase for PassphraseCacheTTL, in the
+// OS keychain if there is one and otherwise within the runtime directory.
+// Rewriting it also refreshes the TTL for as long as the session is active.
+func (c *Context) cachePassphrase(passphrase []byte) {
+	if osKeychain != nil {
+		value := formatCachedPassphrase(passphrase, time.Now().Add(PassphraseCacheTTL))
+		if err := osKeychain.set(c.passphraseKeychainItem(), value); err == nil {
+			if cachePath := c.passphraseCachePath(); cachePath != "" {
+				os.Remove(cachePath)
+			}
+			return
+		}
+	}
+
+	cachePath := c.passphraseCachePath()
+	if cachePath == "" {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0700); err != nil {
+		return
+	}
+	if err := ioutil.WriteFile(cachePath, passphrase, 0600); err != nil {
+		return
+	}
+	// WriteFile leaves the permissions of an existing file as they are.
+	os.Chmod(cachePath, 0600)
+}
+
+// forgetPassphrase drops the passphrase remembered for the session.
+func (c *Context) forgetPassphrase() {
+	c.passphrase = nil
+	if osKeychain != nil {
+		osKeychain.delete(c.passphraseKeychainItem())
+	}
+	if cachePath := c.passphraseCachePath(); cachePath != "" {
+		os.Remove(cachePath)
+	}
+}
+
+// formatCachedPassphrase encodes a passphrase remembered until expiry as
+// the keychain item's secret, hex encoded since keychains take text.
+func formatCachedPassphrase(passphrase []byte, expiry time.Time) string {
+	return fmt.Sprintf("%d:%x", expiry.Unix(), passphrase)
+}
+
+func parseCachedPassphrase(value string) ([]byte, time.Time, error) {
+	sep := strings.Index(value, ":")
+	if sep < 0 {
+		return nil, time.Time{}, fmt.Errorf("malformed cached passphrase")
+	}
+	unix, err := strconv.ParseInt(value[:sep], 10, 64)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	passphrase, err := hex.DecodeString(value[sep+1:])
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return passphrase, time.Unix(unix, 0), nil
+}
@@ -0,0 +1,150 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+// memKeychain is an in-memory keychain for tests.
+type memKeychain map[string]string
+
+func (m memKeychain) get(item string) (string, error) {
+	secret, ok := m[item]
+	if !ok {
+		return "", errKeychainItemNotFound
+	}
+	return secret, nil
+}
+
+func (m memKeychain) set(item, secret string) error {
+	m[item] = secret
+	return nil
+}
+
+func (m memKeychain) delete(item string) error {
+	if _, ok := m[item]; !ok {
+		return errKeychainItemNotFound
+	}
+	delete(m, item)
+	return nil
+}
+
+// withKeychain swaps the OS keychain for k until the returned func is called.
+func withKeychain(k keychain) func() {
+	prev := osKeychain
+	osKeychain = k
+	return func() { osKeychain = prev }
+}
+
+// withRuntimeDir points XDG_RUNTIME_DIR to a fresh
+// directory until the returned func is called.
+func withRuntimeDir(t *testing.T) func() {
+	dir, err := ioutil.TempDir("", "runtime")
+	if err != nil {
+		t.Fatalf("tempdir: %v", err)
+	}
+	prev, had := os.LookupEnv("XDG_RUNTIME_DIR")
+	os.Setenv("XDG_RUNTIME_DIR", dir)
+	return func() {
+		if had {
+			os.Setenv("XDG_RUNTIME_DIR", prev)
+		} else {
+			os.Unsetenv("XDG_RUNTIME_DIR")
+		}
+		os.RemoveAll(dir)
+	}
+}
+
+func TestPassphraseCacheKeychain(t *testing.T) {
+	defer withRuntimeDir(t)()
+	kc := memKeychain{}
+	defer withKeychain(kc)()
+
+	c := &Context{AbsPath: "/home/a/drive"}
+	c.cachePassphrase([]byte("s3cr:et\n"))
+
+	if _, err := os.Stat(c.passphraseCachePath()); !os.IsNotExist(err) {
+		t.Errorf("expected nothing cached on disk with a keychain, got %v", err)
+	}
+	if got := string(c.cachedPassphrase()); got != "s3cr:et\n" {
+		t.Errorf("got %q want %q", got, "s3cr:et\n")
+	}
+
+	// An expired passphrase is dropped from the keychain.
+	kc[c.passphraseKeychainItem()] = formatCachedPassphrase([]byte("old"), time.Now().Add(-time.Minute))
+	if got := c.cachedPassphrase(); got != nil {
+		t.Errorf("expected the expired passphrase to be ignored, got %q", got)
+	}
+	if _, ok := kc[c.passphraseKeychainItem()]; ok {
+		t.Errorf("expected the expired passphrase to be deleted")
+	}
+
+	c.cachePassphrase([]byte("again"))
+	c.forgetPassphrase()
+	if len(kc) != 0 {
+		t.Errorf("expected forgetPassphrase to empty the keychain, got %v", kc)
+	}
+}
+
+func TestPassphraseCacheRuntimeDir(t *testing.T) {
+	defer withRuntimeDir(t)()
+	defer withKeychain(nil)()
+
+	c := &Context{AbsPath: "/home/a/drive"}
+	c.cachePassphrase([]byte("secret"))
+
+	cachePath := c.passphraseCachePath()
+	fi, err := os.Stat(cachePath)
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	if perm := fi.Mode().Perm(); perm != 0600 {
+		t.Errorf("got permissions %v want 0600", perm)
+	}
+	if got := string(c.cachedPassphrase()); got != "secret" {
+		t.Errorf("got %q want %q", got, "secret")
+	}
+
+	// A cache that others can read is dropped rather than trusted.
+	if err := os.Chmod(cachePath, 0644); err != nil {
+		t.Fatalf("chmod: %v", err)
+	}
+	if got := c.cachedPassphrase(); got != nil {
+		t.Errorf("expected a world readable cache to be ignored, got %q", got)
+	}
+	if _, err := os.Stat(cachePath); !os.IsNotExist(err) {
+		t.Errorf("expected the world readable cache to be removed, got %v", err)
+	}
+
+	// So is one that outlived its TTL.
+	c.cachePassphrase([]byte("secret"))
+	stale := time.Now().Add(-PassphraseCacheTTL - time.Minute)
+	if err := os.Chtimes(cachePath, stale, stale); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+	if got := c.cachedPassphrase(); got != nil {
+		t.Errorf("expected an expired cache to be ignored, got %q", got)
+	}
+
+	os.Unsetenv("XDG_RUNTIME_DIR")
+	c.cachePassphrase([]byte("secret"))
+	if got := c.cachedPassphrase(); got != nil {
+		t.Errorf("expected nothing cached without XDG_RUNTIME_DIR, got %q", got)
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const (
+	RegistryJSON = "contexts.json"
+)
+
+// RegistryEntry records an initialized context in the registry.
+type RegistryEntry struct {
+	Path    string `json:"path"`
+	Account string `json:"account,omitempty"`
+	// RemoteRoot is the remote root as configured, the path from the root
+	// of the Drive of the folder that the context was bound to. Contexts
+	// that were bound by id have RemoteRootId set instead. Either is only
+	// resolved to a folder when needed.
+	RemoteRoot   string    `json:"remote_root,omitempty"`
+	RemoteRootId string    `json:"remote_root_id,omitempty"`
+	LastSync     time.Time `json:"last_sync,omitempty"`
+}
+
+type registryEntries []*RegistryEntry
+
+func (re registryEntries) Len() int           { return len(re) }
+func (re registryEntries) Less(i, j int) bool { return re[i].Path < re[j].Path }
+func (re registryEntries) Swap(i, j int)      { re[i], re[j] = re[j], re[i] }
+
+// RegistryPath is the path of the optional registry of contexts,
+// which lives in the .gd directory of the user's home directory.
+func RegistryPath() string {
+	return filepath.Join(os.Getenv("HOME"), DefaultGDDirSuffix, RegistryJSON)
+}
+
+// RegistryExists reports whether the user has opted into the registry.
+func RegistryExists() bool {
+	_, err := os.Stat(RegistryPath())
+	return err == nil
+}
+
+// ReadRegistry returns the registered contexts sorted by path.
+// A missing registry has no entries.
+func ReadRegistry() ([]*RegistryEntry, error) {
+	data, err := ioutil.ReadFile(RegistryPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var entries []*RegistryEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+	sort.Sort(registryEntries(entries))
+	return entries, nil
+}
+
+func writeRegistry(entries []*RegistryEntry) error {
+	registryPath := RegistryPath()
+	if err := os.MkdirAll(filepath.Dir(registryPath), 0755); err != nil {
+		return err
+	}
+
+	sort.Sort(registryEntries(entries))
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write then rename so that concurrent readers never see a partial registry.
+	tmpPath := registryPath + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, registryPath)
+}
+
+// UpdateRegistry applies update to the entry of the context at absPath,
+// adding it if it isn't yet registered. Unless create is set, nothing
+// happens if the user hasn't opted into the registry.
+func UpdateRegistry(absPath string, create bool, update func(*RegistryEntry)) error {
+	if !create && !RegistryExists() {
+		return nil
+	}
+
+	entries, err := ReadRegistry()
+	if err != nil {
+		return err
+	}
+
+	var entry *RegistryEntry
+	for _, e := range entries {
+		if e.Path == absPath {
+			entry = e
+			break
+		}
+	}
+	if entry == nil {
+		entry = &RegistryEntry{Path: absPath}
+		entries = append(entries, entry)
+	}
+
+	update(entry)
+	return writeRegistry(entries)
+}
+
+// Unregister removes the context at absPath from the registry.
+func Unregister(absPath string) error {
+	if !RegistryExists() {
+		return nil
+	}
+
+	entries, err := ReadRegistry()
+	if err != nil {
+		return err
+	}
+
+	kept := entries[:0]
+	for _, e := range entries {
+		if e.Path != absPath {
+			kept = append(kept, e)
+		}
+	}
+	return writeRegistry(kept)
+}
@@ -0,0 +1,117 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Remote is a further named remote e.g "backup" that a context can sync
+// with in place of its default one, with the credentials of an account
+// of its own and a remote folder of its own.
+type Remote struct {
+	// Account is the name of the account whose credentials the remote uses.
+	Account string `json:"account"`
+
+	// RootId when set is the id of the remote folder that the remote
+	// is bound to, in place of the root of the account's Drive.
+	RootId string `json:"root_id,omitempty"`
+}
+
+// RemoteNames returns the names of the context's remotes, sorted.
+func (c *Context) RemoteNames() []string {
+	var names []string
+	for name := range c.Remotes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// UseRemote switches the context's credentials and remote root to those
+// of the named remote, or back to the default ones if name is empty.
+func (c *Context) UseRemote(name string) error {
+	if name == c.Remote {
+		return nil
+	}
+	if name != "" && c.Remotes[name] == nil {
+		return fmt.Errorf("no remote %q in this context; add it with `drive --remote %s init`", name, name)
+	}
+
+	if c.Remote != "" {
+		c.Remotes[c.Remote].RootId = c.RemoteRootId
+		c.RemoteRootId, c.defaultRootId = c.defaultRootId, ""
+		c.Remote = ""
+	}
+
+	account := ""
+	if name != "" {
+		account = c.Remotes[name].Account
+	}
+	if err := c.UseAccount(account); err != nil {
+		return fmt.Errorf("remote %q: %v", name, err)
+	}
+	if name != "" {
+		c.defaultRootId, c.RemoteRootId = c.RemoteRootId, c.Remotes[name].RootId
+		c.Remote = name
+	}
+	return nil
+}
+
+// CreateRemote switches the context to the named remote, adding it if it
+// doesn't exist yet. The remote uses the credentials of account, or of an
+// account of the same name as the remote if account is empty, which is
+// started off with empty credentials if it doesn't exist yet either.
+func (c *Context) CreateRemote(name, account string) error {
+	if !accountNameRegexp.MatchString(name) {
+		return fmt.Errorf("invalid remote name %q, expecting letters, digits, '.', '_' or '-'", name)
+	}
+	if account == "" {
+		account = name
+	}
+
+	if err := c.UseRemote(""); err != nil {
+		return err
+	}
+	if err := c.CreateAccount(account); err != nil {
+		return err
+	}
+	if err := c.UseAccount(""); err != nil {
+		return err
+	}
+
+	if c.Remotes == nil {
+		c.Remotes = make(map[string]*Remote)
+	}
+	if c.Remotes[name] == nil {
+		c.Remotes[name] = &Remote{}
+	}
+	c.Remotes[name].Account = account
+	return c.UseRemote(name)
+}
+
+// persistedRemotes returns the remotes as they are stored, with the
+// remote root of the remote in use filed back under its name.
+func (c *Context) persistedRemotes() map[string]*Remote {
+	remotes := make(map[string]*Remote, len(c.Remotes))
+	for name, remote := range c.Remotes {
+		remotes[name] = remote
+	}
+	inUse := *c.Remotes[c.Remote]
+	inUse.RootId = c.RemoteRootId
+	remotes[c.Remote] = &inUse
+	return remotes
+}
@@ -36,6 +36,7 @@ const (
 	AboutQuota
 	AboutFileSizes
 	AboutFeatures
+	AboutAuth
 )
 
 func (g *Commands) About(mask int) (err error) {
@@ -43,6 +44,15 @@ func (g *Commands) About(mask int) (err error) {
 		return nil
 	}
 
+	if authRequested(mask) {
+		if err := g.AboutAuth(); err != nil {
+			return err
+		}
+		if mask &^= AboutAuth; mask == AboutNone {
+			return nil
+		}
+	}
+
 	about, err := g.rem.About()
 	if err != nil {
 		return err
@@ -60,6 +70,10 @@ func fileSizesRequested(mask int) bool {
 	return (mask & AboutFileSizes) != 0
 }
 
+func authRequested(mask int) bool {
+	return (mask & AboutAuth) != 0
+}
+
 func featuresRequested(mask int) bool {
 	return (mask & AboutFeatures) != 0
 }
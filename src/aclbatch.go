@@ -0,0 +1,133 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+
+	drive "google.golang.org/api/drive/v2"
+)
+
+// aclChange is one permission change of a share or unshare batch.
+type aclChange struct {
+	name string
+	perm *permission
+}
+
+// appliedACL records the permissions that an applied aclChange
+// inserted or deleted, so that the change can be undone.
+type appliedACL struct {
+	fileId   string
+	name     string
+	inserted *drive.Permission
+	deleted  []*drive.Permission
+}
+
+// applyACLChanges applies changes in order, returning those that went
+// through. A change with nothing to apply e.g revoking a role that isn't
+// held is neither applied nor failed. If stopOnFailure is set the batch
+// stops at the first failure, otherwise it applies what it can.
+func applyACLChanges(changes []*aclChange, apply func(*aclChange) (*appliedACL, error), stopOnFailure bool, progress func()) (applied []*appliedACL, failures int, err error) {
+	for _, change := range changes {
+		a, aErr := apply(change)
+		if progress != nil {
+			progress()
+		}
+		if a != nil {
+			applied = append(applied, a)
+		}
+		if aErr == nil {
+			continue
+		}
+
+		failures += 1
+		message := aErr.Error()
+		if !isContextualized(aErr) {
+			message = fmt.Sprintf("%s: %v", change.name, aErr)
+		}
+		err = reComposeError(err, message)
+		if stopOnFailure {
+			break
+		}
+	}
+	return applied, failures, err
+}
+
+// undoACLChanges undoes applied changes, latest first, carrying on
+// past failures so that as much as possible is reverted.
+func undoACLChanges(applied []*appliedACL, undo func(*appliedACL) error) (undone int, err error) {
+	for i := len(applied) - 1; i >= 0; i-- {
+		if uErr := undo(applied[i]); uErr != nil {
+			err = reComposeError(err, fmt.Sprintf("%s: %v", applied[i].name, uErr))
+			continue
+		}
+		undone += 1
+	}
+	return undone, err
+}
+
+func (r *Remote) undoACL(a *appliedACL) error {
+	if a.inserted != nil {
+		if err := r.deletePermission(a.fileId, a.inserted.Id); err != nil {
+			return err
+		}
+	}
+	for _, perm := range a.deleted {
+		if err := r.restorePermission(a.fileId, perm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// playACLBatch applies changes as a tracked batch with progress. If the
+// batch can't complete and rollback is set, it stops and reverts the
+// changes that it applied, leaving permissions as they were before.
+func (c *Commands) playACLBatch(verb string, changes []*aclChange, apply func(*aclChange) (*appliedACL, error)) error {
+	logged := func(change *aclChange) (*appliedACL, error) {
+		a, err := apply(change)
+		err = pathErr(verb, OpNone, change.name, change.perm.fileId, err)
+		if a != nil && c.opts.Verbose {
+			perm := change.perm
+			c.log.Logf("successful %s for %s with email %q, role %q accountType %q\n",
+				verb, change.name, perm.value, perm.role.String(), perm.accountType.String())
+		}
+		return a, err
+	}
+
+	c.taskStart(int64(len(changes)))
+	applied, failures, err := applyACLChanges(changes, logged, c.opts.Rollback, func() { c.taskAdd(1) })
+	c.taskFinish()
+
+	if err == nil {
+		if len(applied) < 1 {
+			return noMatchesFoundErr(fmt.Errorf("no matches found!"))
+		}
+		return nil
+	}
+
+	err = reComposeError(err, fmt.Sprintf("%s: %d of %d permission changes failed, %d were applied",
+		verb, failures, len(changes), len(applied)))
+	if !c.opts.Rollback {
+		return reComposeError(err, fmt.Sprintf("pass in -%s to undo the applied changes when a batch can't complete", CLIOptionRollback))
+	}
+
+	c.log.LogErrf("%s: rolling back %d applied permission changes\n", verb, len(applied))
+	undone, undoErr := undoACLChanges(applied, c.rem.undoACL)
+	if undoErr != nil {
+		return reComposeError(err, undoErr.Error(), fmt.Sprintf("rollback: only %d of %d changes were undone", undone, len(applied)))
+	}
+	return reComposeError(err, fmt.Sprintf("rollback: all %d applied changes were undone", undone))
+}
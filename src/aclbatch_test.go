@@ -0,0 +1,121 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	drive "google.golang.org/api/drive/v2"
+)
+
+func TestApplyACLChanges(t *testing.T) {
+	errFailed := errors.New("rate limited")
+
+	cases := []struct {
+		outcomes      []string
+		stopOnFailure bool
+		wantApplied   []string
+		wantFailures  int
+		wantProgress  int
+	}{
+		{
+			outcomes:     []string{"ok", "ok", "ok"},
+			wantApplied:  []string{"a", "b", "c"},
+			wantProgress: 3,
+		},
+		{
+			// Nothing to revoke is neither applied nor failed.
+			outcomes:     []string{"ok", "noop", "ok"},
+			wantApplied:  []string{"a", "c"},
+			wantProgress: 3,
+		},
+		{
+			outcomes:     []string{"ok", "fail", "ok"},
+			wantApplied:  []string{"a", "c"},
+			wantFailures: 1,
+			wantProgress: 3,
+		},
+		{
+			outcomes:      []string{"ok", "fail", "ok"},
+			stopOnFailure: true,
+			wantApplied:   []string{"a"},
+			wantFailures:  1,
+			wantProgress:  2,
+		},
+	}
+
+	names := []string{"a", "b", "c"}
+	for i, tc := range cases {
+		var changes []*aclChange
+		outcomes := map[string]string{}
+		for j, outcome := range tc.outcomes {
+			changes = append(changes, &aclChange{name: names[j], perm: &permission{fileId: names[j]}})
+			outcomes[names[j]] = outcome
+		}
+
+		apply := func(change *aclChange) (*appliedACL, error) {
+			switch outcomes[change.name] {
+			case "ok":
+				return &appliedACL{fileId: change.perm.fileId, name: change.name}, nil
+			case "fail":
+				return nil, errFailed
+			}
+			return nil, nil
+		}
+
+		progress := 0
+		applied, failures, err := applyACLChanges(changes, apply, tc.stopOnFailure, func() { progress += 1 })
+
+		var gotApplied []string
+		for _, a := range applied {
+			gotApplied = append(gotApplied, a.name)
+		}
+		if !reflect.DeepEqual(gotApplied, tc.wantApplied) {
+			t.Errorf("#%d: applied: got %v want %v", i, gotApplied, tc.wantApplied)
+		}
+		if failures != tc.wantFailures || (err != nil) != (tc.wantFailures > 0) {
+			t.Errorf("#%d: failures: got %d (%v) want %d", i, failures, err, tc.wantFailures)
+		}
+		if progress != tc.wantProgress {
+			t.Errorf("#%d: progress: got %d want %d", i, progress, tc.wantProgress)
+		}
+	}
+}
+
+func TestUndoACLChanges(t *testing.T) {
+	applied := []*appliedACL{
+		{name: "a", inserted: &drive.Permission{Id: "p1"}},
+		{name: "b", deleted: []*drive.Permission{{Id: "p2"}}},
+		{name: "c", inserted: &drive.Permission{Id: "p3"}},
+	}
+
+	var order []string
+	undone, err := undoACLChanges(applied, func(a *appliedACL) error {
+		order = append(order, a.name)
+		if a.name == "b" {
+			return errors.New("forbidden")
+		}
+		return nil
+	})
+
+	if want := []string{"c", "b", "a"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("undo order: got %v want %v", order, want)
+	}
+	if undone != 2 || err == nil {
+		t.Errorf("got %d undone (%v), want 2 undone and an error", undone, err)
+	}
+}
@@ -0,0 +1,590 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"compress/flate"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+)
+
+const (
+	ZipPartialSuffix = "part"
+)
+
+// archiver abstracts the archive formats that remote
+// content can be pulled into.
+type archiver interface {
+	mkdir(relPath string, f *File) error
+	// create starts a new entry. size is -1 for
+	// content whose length is unknown e.g exports.
+	create(relPath string, f *File, size int64) (io.Writer, error)
+	// sizedEntries reports whether each entry's
+	// size must be known before writing its content.
+	sizedEntries() bool
+	// done reports whether the entry named name was
+	// already added by an earlier attempt that is resumed.
+	done(name string) bool
+	Close() error
+}
+
+type tarArchiver struct {
+	tw *tar.Writer
+}
+
+func (ta *tarArchiver) mkdir(relPath string, f *File) error {
+	return ta.tw.WriteHeader(&tar.Header{
+		Name:     relPath + "/",
+		Mode:     0755,
+		ModTime:  f.ModTime,
+		Typeflag: tar.TypeDir,
+	})
+}
+
+func (ta *tarArchiver) create(relPath string, f *File, size int64) (io.Writer, error) {
+	if size < 0 {
+		return nil, illogicalStateErr(fmt.Errorf("tar: %s has an unknown size", customQuote(relPath)))
+	}
+
+	hdr := &tar.Header{
+		Name:     relPath,
+		Mode:     0644,
+		Size:     size,
+		ModTime:  f.ModTime,
+		Typeflag: tar.TypeReg,
+	}
+	if err := ta.tw.WriteHeader(hdr); err != nil {
+		return nil, err
+	}
+	return ta.tw, nil
+}
+
+func (ta *tarArchiver) sizedEntries() bool {
+	return true
+}
+
+func (ta *tarArchiver) done(name string) bool {
+	return false
+}
+
+func (ta *tarArchiver) Close() error {
+	return ta.tw.Close()
+}
+
+type zipArchiver struct {
+	zw *zip.Writer
+	// carried are the names of the entries
+	// carried over from an earlier attempt.
+	carried map[string]bool
+	// added is the number of entries added since.
+	added int
+}
+
+func newZipArchiver(w io.Writer) *zipArchiver {
+	return &zipArchiver{zw: zip.NewWriter(w), carried: make(map[string]bool)}
+}
+
+// carryOver copies the entries of the zip file at p, left by an earlier
+// attempt, so that they aren't pulled again. Only complete entries are
+// ever added so all of them can be carried over. An attempt that was cut
+// short before the zip's central directory got written, is salvaged up
+// to its last complete entry.
+func (za *zipArchiver) carryOver(p string) error {
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	zr, err := zip.NewReader(f, fi.Size())
+	if err != nil {
+		spans, salvageErr := salvageZip(f)
+		if salvageErr != nil || len(spans) < 1 {
+			return err
+		}
+		for _, span := range spans {
+			hdr := span.hdr
+			raw := io.NewSectionReader(f, span.offset, int64(hdr.CompressedSize64))
+			if err := za.carryRaw(&hdr, raw); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, zf := range zr.File {
+		hdr := zf.FileHeader
+		raw, err := zf.OpenRaw()
+		if err != nil {
+			return err
+		}
+		if err := za.carryRaw(&hdr, raw); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// carryRaw adds the entry of hdr with its compressed content as is.
+func (za *zipArchiver) carryRaw(hdr *zip.FileHeader, raw io.Reader) error {
+	// The writer adds the timestamp field back from Modified, so
+	// keeping the old one would grow the extra field on each resume.
+	if !hdr.Modified.IsZero() {
+		hdr.Extra = nil
+	}
+	w, err := za.zw.CreateRaw(hdr)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, raw); err != nil {
+		return err
+	}
+	za.carried[hdr.Name] = true
+	return nil
+}
+
+const (
+	zipLocalHeaderSignature    = 0x04034b50
+	zipDataDescriptorSignature = 0x08074b50
+	zipDataDescriptorFlag      = 0x8
+	zipLocalHeaderLen          = 30
+)
+
+// zipSpan is an entry salvaged from an unfinished zip file.
+type zipSpan struct {
+	hdr zip.FileHeader
+	// offset is where the entry's compressed content starts.
+	offset int64
+}
+
+// countingByteReader counts the bytes read through it. Being an
+// io.ByteReader keeps flate from reading past the compressed stream.
+type countingByteReader struct {
+	br *bufio.Reader
+	n  int64
+}
+
+func (cr *countingByteReader) Read(p []byte) (int, error) {
+	n, err := cr.br.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
+
+func (cr *countingByteReader) ReadByte() (byte, error) {
+	b, err := cr.br.ReadByte()
+	if err == nil {
+		cr.n += 1
+	}
+	return b, err
+}
+
+// salvageZip walks the local headers of a zip file whose central directory
+// is missing, e.g a write that was interrupted, returning the entries up to
+// the first one that is cut short or fails its checksum. Only the directory
+// and deflated entries that zipArchiver writes are understood.
+func salvageZip(r io.ReaderAt) (spans []*zipSpan, err error) {
+	cr := &countingByteReader{br: bufio.NewReader(io.NewSectionReader(r, 0, 1<<63-1))}
+	le := binary.LittleEndian
+
+	for {
+		var fixed [zipLocalHeaderLen]byte
+		if _, err := io.ReadFull(cr, fixed[:]); err != nil {
+			return spans, nil
+		}
+		if le.Uint32(fixed[0:]) != zipLocalHeaderSignature {
+			return spans, nil
+		}
+
+		hdr := zip.FileHeader{
+			ReaderVersion: le.Uint16(fixed[4:]),
+			Flags:         le.Uint16(fixed[6:]),
+			Method:        le.Uint16(fixed[8:]),
+			ModifiedTime:  le.Uint16(fixed[10:]),
+			ModifiedDate:  le.Uint16(fixed[12:]),
+			CRC32:         le.Uint32(fixed[14:]),
+		}
+		csize, usize := uint64(le.Uint32(fixed[18:])), uint64(le.Uint32(fixed[22:]))
+		name := make([]byte, le.Uint16(fixed[26:]))
+		extra := make([]byte, le.Uint16(fixed[28:]))
+		if _, err := io.ReadFull(cr, name); err != nil {
+			return spans, nil
+		}
+		if _, err := io.ReadFull(cr, extra); err != nil {
+			return spans, nil
+		}
+		hdr.Name = string(name)
+		hdr.Extra = extra
+		offset := cr.n
+
+		if strings.HasSuffix(hdr.Name, "/") && hdr.Flags&zipDataDescriptorFlag == 0 {
+			if csize != 0 {
+				return spans, nil
+			}
+			hdr.SetMode(os.ModeDir | 0755)
+			spans = append(spans, &zipSpan{hdr: hdr, offset: offset})
+			continue
+		}
+		if hdr.Method != zip.Deflate || hdr.Flags&zipDataDescriptorFlag == 0 {
+			return spans, nil
+		}
+
+		crc := crc32.NewIEEE()
+		n, err := io.Copy(crc, flate.NewReader(cr))
+		if err != nil {
+			return spans, nil
+		}
+		csize, usize = uint64(cr.n-offset), uint64(n)
+
+		// The data descriptor's signature is optional and its
+		// sizes are 8 bytes long for zip64 entries.
+		var desc [24]byte
+		descLen := 12
+		if usize >= 0xffffffff || csize >= 0xffffffff {
+			descLen = 20
+		}
+		if _, err := io.ReadFull(cr, desc[:4]); err != nil {
+			return spans, nil
+		}
+		if le.Uint32(desc[:4]) == zipDataDescriptorSignature {
+			if _, err := io.ReadFull(cr, desc[:4]); err != nil {
+				return spans, nil
+			}
+		}
+		if _, err := io.ReadFull(cr, desc[4:descLen]); err != nil {
+			return spans, nil
+		}
+		if le.Uint32(desc[:4]) != crc.Sum32() {
+			return spans, nil
+		}
+
+		hdr.SetMode(0644)
+		hdr.CRC32 = crc.Sum32()
+		hdr.CompressedSize64, hdr.UncompressedSize64 = csize, usize
+		spans = append(spans, &zipSpan{hdr: hdr, offset: offset})
+	}
+}
+
+func (za *zipArchiver) mkdir(relPath string, f *File) error {
+	hdr := &zip.FileHeader{Name: relPath + "/"}
+	hdr.SetModTime(f.ModTime)
+	hdr.SetMode(os.ModeDir | 0755)
+	_, err := za.zw.CreateHeader(hdr)
+	if err == nil {
+		za.added += 1
+	}
+	return err
+}
+
+func (za *zipArchiver) create(relPath string, f *File, size int64) (io.Writer, error) {
+	hdr := &zip.FileHeader{Name: relPath, Method: zip.Deflate}
+	hdr.SetModTime(f.ModTime)
+	hdr.SetMode(0644)
+	w, err := za.zw.CreateHeader(hdr)
+	if err == nil {
+		za.added += 1
+	}
+	return w, err
+}
+
+func (za *zipArchiver) sizedEntries() bool {
+	return false
+}
+
+func (za *zipArchiver) done(name string) bool {
+	return za.carried[name]
+}
+
+func (za *zipArchiver) Close() error {
+	return za.zw.Close()
+}
+
+// PullTar streams the content of each source as a single tar archive
+// to stdout. Nothing is written locally and the index is left untouched.
+func (g *Commands) PullTar(byId bool) error {
+	ta := &tarArchiver{tw: tar.NewWriter(os.Stdout)}
+	return g.pullArchive(ta, g.opts.Sources, byId)
+}
+
+// PullZip packages the remote sources into the zip file at zipPath,
+// exporting docs + sheets per the requested exports. Content is first
+// written to a ".part" file that is only renamed into place on success.
+// Entries are only added once downloaded in full, so a failed pull leaves
+// a ".part" of complete entries that re-running the pull resumes from. A
+// pull that was killed mid-write is resumed from its last complete entry.
+// Each attempt writes a fresh ".part.tmp", carrying over the entries of
+// the ".part" left untouched, which it replaces once done so that being
+// killed part way never loses what earlier attempts pulled.
+func (g *Commands) PullZip(zipPath string, byId bool) error {
+	partPath := sepJoin(".", zipPath, ZipPartialSuffix)
+	tmpPath := sepJoin(".", partPath, "tmp")
+
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+
+	za := newZipArchiver(f)
+	if err := za.carryOver(partPath); err != nil && !os.IsNotExist(err) {
+		g.log.LogErrf("can't resume from %s: %v, starting over\n", partPath, err)
+		if _, err := f.Seek(0, 0); err != nil {
+			f.Close()
+			os.Remove(tmpPath)
+			return err
+		}
+		if err := f.Truncate(0); err != nil {
+			f.Close()
+			os.Remove(tmpPath)
+			return err
+		}
+		za = newZipArchiver(f)
+	} else if len(za.carried) >= 1 {
+		g.log.Logf("Resuming with the %d entries of %s\n", len(za.carried), partPath)
+	}
+
+	err = g.pullArchive(za, g.opts.Sources, byId)
+
+	closeErr := f.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		// The ".part" is only replaced by an attempt that was
+		// written out in full and added entries to it.
+		if closeErr != nil || za.added < 1 {
+			os.Remove(tmpPath)
+		} else if rErr := os.Rename(tmpPath, partPath); rErr != nil {
+			os.Remove(tmpPath)
+			g.log.LogErrf("keeping %s: %v\n", partPath, rErr)
+		}
+		if _, sErr := os.Stat(partPath); sErr == nil {
+			g.log.LogErrf("re-run the pull to resume from %s\n", partPath)
+		}
+		return err
+	}
+
+	if err := os.Rename(tmpPath, zipPath); err != nil {
+		return err
+	}
+	os.Remove(partPath)
+	return nil
+}
+
+func (g *Commands) pullArchive(a archiver, sources []string, byId bool) (err error) {
+	resolver := g.rem.FindByPathM
+	if byId {
+		resolver = g.rem.FindByIdM
+	}
+
+	defer func() {
+		closeErr := a.Close()
+		if err == nil {
+			err = closeErr
+		}
+	}()
+
+	for _, arg := range sources {
+		if err = g.archivePerResolver(a, arg, resolver); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (g *Commands) archivePerResolver(a archiver, arg string, resolver func(string) *paginationPair) error {
+	pagePair := resolver(arg)
+	errsChan := pagePair.errsChan
+	matchesChan := pagePair.filesChan
+
+	var err error
+
+	working := true
+	for working {
+		select {
+		case pageErr := <-errsChan:
+			if pageErr != nil {
+				return pageErr
+			}
+		case rem, stillHasContent := <-matchesChan:
+			if !stillHasContent {
+				working = false
+				break
+			}
+			if rem == nil {
+				err = reComposeError(err, fmt.Sprintf("%s doesnot exist", customQuote(arg)))
+				continue
+			}
+
+			if aErr := g.archiveWalk(a, rem.Name, rem, g.opts.Depth); aErr != nil {
+				return aErr
+			}
+		}
+	}
+
+	return err
+}
+
+func (g *Commands) archiveWalk(a archiver, relPath string, rem *File, depth int) error {
+	if !rem.IsDir {
+		return g.archiveAddFile(a, relPath, rem)
+	}
+
+	if !a.done(relPath + "/") {
+		if err := a.mkdir(relPath, rem); err != nil {
+			return err
+		}
+	}
+
+	if depth == 0 {
+		return nil
+	}
+	if depth > 0 {
+		depth -= 1
+	}
+
+	pagePair := g.rem.FindByParentId(rem.Id, g.opts.Hidden)
+	errsChan := pagePair.errsChan
+	childrenChan := pagePair.filesChan
+
+	working := true
+	for working {
+		select {
+		case err := <-errsChan:
+			if err != nil {
+				return err
+			}
+		case child, stillHasContent := <-childrenChan:
+			if !stillHasContent {
+				working = false
+				break
+			}
+			if child == nil {
+				continue
+			}
+			if anyMatch(g.opts.Ignorer, child.Name) {
+				continue
+			}
+			if err := g.archiveWalk(a, path.Join(relPath, child.Name), child, depth); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (g *Commands) archiveAddFile(a archiver, relPath string, rem *File) error {
+	if hasExportLinks(rem) {
+		return g.archiveAddExports(a, relPath, rem)
+	}
+
+	return g.archiveEntry(a, relPath, rem, rem.Size, "")
+}
+
+func (g *Commands) archiveAddExports(a archiver, relPath string, rem *File) error {
+	// Exports only have a known size once downloaded.
+	if a.sizedEntries() || len(g.opts.Exports) < 1 {
+		g.log.LogErrf("skipping %s: GoogleDoc/Sheet documents can only be exported\n", customQuote(relPath))
+		return nil
+	}
+
+	for _, ext := range g.opts.Exports {
+		exportURL, ok := rem.ExportLinks[mimeTypeFromExt(ext)]
+		if !ok {
+			continue
+		}
+
+		exportPath := sepJoin(".", relPath, ext)
+		if err := g.archiveEntry(a, exportPath, rem, -1, exportURL); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// archiveEntry adds the content of rem, or its export at exportURL if set,
+// as the entry at relPath. Archives whose entries' sizes needn't be known
+// upfront, zips, only get the entry once it is downloaded in full, so that
+// a failed pull leaves no truncated entry behind to resume from.
+func (g *Commands) archiveEntry(a archiver, relPath string, rem *File, size int64, exportURL string) error {
+	if a.done(relPath) {
+		return nil
+	}
+
+	if a.sizedEntries() {
+		w, err := a.create(relPath, rem, size)
+		if err != nil || size == 0 {
+			return err
+		}
+		return g.archiveCopy(w, relPath, rem.Id, exportURL)
+	}
+
+	staged, err := ioutil.TempFile("", "drive-archive")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		staged.Close()
+		os.Remove(staged.Name())
+	}()
+
+	if size != 0 {
+		if err := g.archiveCopy(staged, relPath, rem.Id, exportURL); err != nil {
+			return err
+		}
+		if _, err := staged.Seek(0, 0); err != nil {
+			return err
+		}
+	}
+
+	w, err := a.create(relPath, rem, size)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, staged)
+	return err
+}
+
+func (g *Commands) archiveCopy(w io.Writer, relPath, id, exportURL string) error {
+	blobHandle, dlErr := g.rem.Download(id, exportURL)
+	if dlErr != nil {
+		return dlErr
+	}
+	if blobHandle == nil {
+		return illogicalStateErr(fmt.Errorf("%s: no content to archive", customQuote(relPath)))
+	}
+	defer blobHandle.Close()
+
+	if _, err := io.Copy(w, blobHandle); err != nil {
+		return downloadFailedErr(err)
+	}
+
+	return nil
+}
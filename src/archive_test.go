@@ -0,0 +1,398 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/odeke-em/log"
+	drive "google.golang.org/api/drive/v2"
+)
+
+func TestTarArchiver(t *testing.T) {
+	var buf bytes.Buffer
+	ta := &tarArchiver{tw: tar.NewWriter(&buf)}
+	f := &File{ModTime: time.Now()}
+
+	if err := ta.mkdir("dir", f); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	w, err := ta.create("dir/a.txt", f, 5)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := io.WriteString(w, "hello"); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if _, err := ta.create("dir/export.pdf", f, -1); err == nil {
+		t.Errorf("expected an error creating an entry of unknown size")
+	}
+	if err := ta.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	tr := tar.NewReader(&buf)
+	var names []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("next: %v", err)
+		}
+		names = append(names, hdr.Name)
+	}
+	if want := []string{"dir/", "dir/a.txt"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got entries %q want %q", names, want)
+	}
+}
+
+func TestPullArchiveTar(t *testing.T) {
+	files := map[string]*drive.File{
+		"root":   {Id: "root", Title: "root", MimeType: DriveFolderMimeType},
+		"photos": {Id: "photos", Title: "photos", MimeType: DriveFolderMimeType},
+		"a":      {Id: "a", Title: "a.txt", MimeType: "text/plain", FileSize: 5},
+		"doc": {Id: "doc", Title: "notes", MimeType: "application/vnd.google-apps.document",
+			ExportLinks: map[string]string{"application/pdf": "https://export.example/doc"}},
+	}
+	tree := fakeTreeTransport(t, files, map[string][]string{
+		"root":   {"photos"},
+		"photos": {"a", "doc"},
+	})
+	transport := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Query().Get("alt") != "media" {
+			return tree.RoundTrip(req)
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(bytes.NewReader([]byte("hello"))),
+			Request:    req,
+		}, nil
+	})
+	rem, err := remoteFromClient(&http.Client{Transport: transport})
+	if err != nil {
+		t.Fatalf("remoteFromClient: %v", err)
+	}
+	g := &Commands{
+		rem:  rem,
+		log:  log.New(nil, ioutil.Discard, ioutil.Discard),
+		opts: &Options{Sources: []string{"/photos"}, Depth: InfiniteDepth},
+	}
+
+	var buf bytes.Buffer
+	if err := g.pullArchive(&tarArchiver{tw: tar.NewWriter(&buf)}, g.opts.Sources, false); err != nil {
+		t.Fatalf("pull: %v", err)
+	}
+
+	// Docs can only be exported, which tars can't hold for lack of a size.
+	entries := map[string]string{}
+	tr := tar.NewReader(&buf)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("next: %v", err)
+		}
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			t.Fatalf("read %s: %v", hdr.Name, err)
+		}
+		entries[hdr.Name] = string(data)
+	}
+	if want := map[string]string{"photos/": "", "photos/a.txt": "hello"}; !reflect.DeepEqual(entries, want) {
+		t.Errorf("got entries %q want %q", entries, want)
+	}
+}
+
+// zipEntries returns the content of each entry of the zip file at p.
+func zipEntries(t *testing.T, p string) map[string]string {
+	zr, err := zip.OpenReader(p)
+	if err != nil {
+		t.Fatalf("open %s: %v", p, err)
+	}
+	defer zr.Close()
+
+	entries := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("open %s: %v", f.Name, err)
+		}
+		data, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("read %s: %v", f.Name, err)
+		}
+		entries[f.Name] = string(data)
+	}
+	return entries
+}
+
+func TestZipArchiverCarryOver(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archive")
+	if err != nil {
+		t.Fatalf("tempdir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	f := &File{ModTime: time.Now()}
+	addEntries := func(p string, carryFrom string, entries map[string]string) *zipArchiver {
+		out, err := os.Create(p)
+		if err != nil {
+			t.Fatalf("create: %v", err)
+		}
+		defer out.Close()
+
+		za := newZipArchiver(out)
+		if carryFrom != "" {
+			if err := za.carryOver(carryFrom); err != nil {
+				t.Fatalf("carryOver: %v", err)
+			}
+		}
+		if !za.done("dir/") {
+			if err := za.mkdir("dir", f); err != nil {
+				t.Fatalf("mkdir: %v", err)
+			}
+		}
+		for name, content := range entries {
+			w, err := za.create(name, f, -1)
+			if err != nil {
+				t.Fatalf("create %s: %v", name, err)
+			}
+			if _, err := io.WriteString(w, content); err != nil {
+				t.Fatalf("write %s: %v", name, err)
+			}
+		}
+		if err := za.Close(); err != nil {
+			t.Fatalf("close: %v", err)
+		}
+		return za
+	}
+
+	prev := filepath.Join(dir, "out.zip.part")
+	addEntries(prev, "", map[string]string{"dir/a.txt": "hello"})
+
+	part := filepath.Join(dir, "out.zip.part.tmp")
+	za := addEntries(part, prev, map[string]string{"dir/b.txt": "world"})
+
+	for _, name := range []string{"dir/", "dir/a.txt"} {
+		if !za.done(name) {
+			t.Errorf("%s: expected to be carried over", name)
+		}
+	}
+	if za.done("dir/b.txt") {
+		t.Errorf("dir/b.txt: not expected to be carried over")
+	}
+
+	want := map[string]string{"dir/": "", "dir/a.txt": "hello", "dir/b.txt": "world"}
+	if got := zipEntries(t, part); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q want %q", got, want)
+	}
+
+	if err := newZipArchiver(ioutil.Discard).carryOver(filepath.Join(dir, "missing")); !os.IsNotExist(err) {
+		t.Errorf("carrying over a missing file: got %v want a not exist error", err)
+	}
+}
+
+func TestZipArchiverCarryOverInterrupted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archive")
+	if err != nil {
+		t.Fatalf("tempdir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Write an attempt that dies half way through its third entry,
+	// leaving neither that entry nor the central directory behind.
+	var buf bytes.Buffer
+	f := &File{ModTime: time.Now()}
+	za := newZipArchiver(&buf)
+	if err := za.mkdir("dir", f); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	for _, name := range []string{"dir/a.txt", "dir/b.txt"} {
+		w, err := za.create(name, f, -1)
+		if err != nil {
+			t.Fatalf("create %s: %v", name, err)
+		}
+		if _, err := io.WriteString(w, name+" content"); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	if err := za.zw.Flush(); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	complete := buf.Len()
+	w, err := za.create("dir/c.txt", f, -1)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	noise := make([]byte, 1<<16)
+	rand.New(rand.NewSource(1)).Read(noise)
+	if _, err := w.Write(noise); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := za.zw.Flush(); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	if buf.Len() <= complete+100 {
+		t.Fatalf("expected part of dir/c.txt to be written")
+	}
+
+	prev := filepath.Join(dir, "out.zip.part")
+	if err := ioutil.WriteFile(prev, buf.Bytes()[:buf.Len()-10], 0644); err != nil {
+		t.Fatalf("write %s: %v", prev, err)
+	}
+
+	part := filepath.Join(dir, "out.zip.part.tmp")
+	out, err := os.Create(part)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	resumed := newZipArchiver(out)
+	if err := resumed.carryOver(prev); err != nil {
+		t.Fatalf("carryOver: %v", err)
+	}
+	if err := resumed.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	out.Close()
+
+	if resumed.done("dir/c.txt") {
+		t.Errorf("dir/c.txt: the truncated entry should not be carried over")
+	}
+	want := map[string]string{"dir/": "", "dir/a.txt": "dir/a.txt content", "dir/b.txt": "dir/b.txt content"}
+	if got := zipEntries(t, part); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q want %q", got, want)
+	}
+
+	// A tampered entry fails its checksum and ends the salvage there.
+	tampered := append([]byte(nil), buf.Bytes()[:complete]...)
+	at := bytes.Index(tampered, []byte("dir/b.txt")) + len("dir/b.txt") + 2
+	tampered[at] ^= 0xff
+	spans, err := salvageZip(bytes.NewReader(tampered))
+	if err != nil {
+		t.Fatalf("salvageZip: %v", err)
+	}
+	var names []string
+	for _, span := range spans {
+		names = append(names, span.hdr.Name)
+	}
+	if want := []string{"dir/", "dir/a.txt"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("salvaged %q want %q", names, want)
+	}
+}
+
+func TestPullZipKeepsPartUntilDone(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archive")
+	if err != nil {
+		t.Fatalf("tempdir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	zipPath := filepath.Join(dir, "out.zip")
+	partPath := zipPath + ".part"
+
+	files := map[string]*drive.File{
+		"root": {Id: "root", Title: "root", MimeType: DriveFolderMimeType},
+		"dir":  {Id: "dir", Title: "dir", MimeType: DriveFolderMimeType},
+		"a":    {Id: "a", Title: "a.txt", MimeType: "text/plain", FileSize: 5},
+		"b":    {Id: "b", Title: "b.txt", MimeType: "text/plain", FileSize: 5},
+		"c":    {Id: "c", Title: "c.txt", MimeType: "text/plain", FileSize: 5},
+	}
+	tree := fakeTreeTransport(t, files, map[string][]string{
+		"root": {"dir"},
+		"dir":  {"a", "b", "c"},
+	})
+
+	var partBefore []byte
+	failing := map[string]bool{}
+	transport := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Query().Get("alt") != "media" {
+			return tree.RoundTrip(req)
+		}
+		// Earlier progress is left as is while an attempt is under way.
+		if partNow, _ := ioutil.ReadFile(partPath); !bytes.Equal(partNow, partBefore) {
+			t.Errorf("expected %s untouched during the pull", partPath)
+		}
+		id := path.Base(req.URL.Path)
+		status := http.StatusOK
+		if failing[id] {
+			status = http.StatusNotFound
+		}
+		return &http.Response{
+			StatusCode: status,
+			Body:       ioutil.NopCloser(bytes.NewReader([]byte("file " + id))),
+			Request:    req,
+		}, nil
+	})
+	rem, err := remoteFromClient(&http.Client{Transport: transport})
+	if err != nil {
+		t.Fatalf("remoteFromClient: %v", err)
+	}
+	g := &Commands{
+		rem:  rem,
+		log:  log.New(nil, ioutil.Discard, ioutil.Discard),
+		opts: &Options{Sources: []string{"/dir"}, Depth: InfiniteDepth},
+	}
+
+	failing["b"] = true
+	if err := g.PullZip(zipPath, false); err == nil {
+		t.Fatalf("expected the pull to fail")
+	}
+	want := map[string]string{"dir/": "", "dir/a.txt": "file a"}
+	if got := zipEntries(t, partPath); !reflect.DeepEqual(got, want) {
+		t.Errorf("after the first attempt: got %q want %q", got, want)
+	}
+
+	// An attempt that adds nothing leaves the earlier progress alone.
+	if partBefore, err = ioutil.ReadFile(partPath); err != nil {
+		t.Fatal(err)
+	}
+	failing["b"] = true
+	if err := g.PullZip(zipPath, false); err == nil {
+		t.Fatalf("expected the pull to fail")
+	}
+	if got := zipEntries(t, partPath); !reflect.DeepEqual(got, want) {
+		t.Errorf("after the second attempt: got %q want %q", got, want)
+	}
+
+	failing["b"] = false
+	if err := g.PullZip(zipPath, false); err != nil {
+		t.Fatalf("resume: %v", err)
+	}
+	want = map[string]string{"dir/": "", "dir/a.txt": "file a", "dir/b.txt": "file b", "dir/c.txt": "file c"}
+	if got := zipEntries(t, zipPath); !reflect.DeepEqual(got, want) {
+		t.Errorf("resumed: got %q want %q", got, want)
+	}
+	for _, p := range []string{partPath, partPath + ".tmp"} {
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Errorf("expected %s removed: %v", p, err)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"unicode/utf8"
+)
+
+// asciiOutput reports whether console output should be kept to ASCII,
+// as requested by a leading `--ascii` or by setting DRIVE_ASCII.
+func asciiOutput() bool {
+	return os.Getenv(ASCIIEnvKey) != ""
+}
+
+// escapeASCII escapes every non-ASCII rune of b as \uXXXX or \UXXXXXXXX
+// and every invalid UTF-8 byte as \xNN, the way Go quotes strings, so that
+// names stay greppable and can be mapped back to the files they came from.
+func escapeASCII(b []byte) []byte {
+	var escaped []byte
+	for len(b) > 0 {
+		r, size := utf8.DecodeRune(b)
+		switch {
+		case r == utf8.RuneError && size <= 1:
+			escaped = append(escaped, fmt.Sprintf(`\x%02x`, b[0])...)
+		case r < utf8.RuneSelf:
+			escaped = append(escaped, b[0])
+		case r <= 0xffff:
+			escaped = append(escaped, fmt.Sprintf(`\u%04x`, r)...)
+		default:
+			escaped = append(escaped, fmt.Sprintf(`\U%08x`, r)...)
+		}
+		b = b[size:]
+	}
+	return escaped
+}
+
+// asciiWriter escapes whatever passes through it. A rune split across
+// writes is held back until the rest of it arrives.
+type asciiWriter struct {
+	w       io.Writer
+	pending []byte
+}
+
+func (aw *asciiWriter) Write(p []byte) (int, error) {
+	b := append(aw.pending, p...)
+	aw.pending = nil
+
+	// Hold back a trailing incomplete, but so far valid, rune.
+	for i := len(b) - 1; i >= 0 && i >= len(b)-utf8.UTFMax; i-- {
+		if !utf8.RuneStart(b[i]) {
+			continue
+		}
+		if !utf8.FullRune(b[i:]) {
+			aw.pending = append(aw.pending, b[i:]...)
+			b = b[:i]
+		}
+		break
+	}
+
+	if _, err := aw.w.Write(escapeASCII(b)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// ASCIISafe wraps w to escape non-ASCII output if ASCII output was
+// requested, otherwise it returns w as is.
+func ASCIISafe(w io.Writer) io.Writer {
+	if !asciiOutput() {
+		return w
+	}
+	return &asciiWriter{w: w}
+}
@@ -0,0 +1,61 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEscapeASCII(t *testing.T) {
+	testCases := []struct {
+		in, want string
+	}{
+		{in: "", want: ""},
+		{in: "plain/ascii.txt\n", want: "plain/ascii.txt\n"},
+		{in: "Ирина.doc", want: `\u0418\u0440\u0438\u043d\u0430.doc`},
+		{in: "café.jpg", want: `caf\u00e9.jpg`},
+		{in: "😀", want: `\U0001f600`},
+		{in: "bad\xffbyte", want: `bad\xffbyte`},
+	}
+
+	for _, tc := range testCases {
+		if got := string(escapeASCII([]byte(tc.in))); got != tc.want {
+			t.Errorf("%q: got %q want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestASCIIWriterSplitRunes(t *testing.T) {
+	in := []byte("résumé 😀 done\n")
+	want := string(escapeASCII(in))
+
+	// Write one byte at a time so that every multi-byte rune is split.
+	var buf bytes.Buffer
+	aw := &asciiWriter{w: &buf}
+	for i := range in {
+		n, err := aw.Write(in[i : i+1])
+		if err != nil || n != 1 {
+			t.Fatalf("#%d: n=%d err=%v", i, n, err)
+		}
+	}
+
+	if got := buf.String(); got != want {
+		t.Errorf("got %q want %q", got, want)
+	}
+	if len(aw.pending) != 0 {
+		t.Errorf("expected nothing pending, got %q", aw.pending)
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"io"
+	"runtime"
+	"time"
+
+	"google.golang.org/api/googleapi"
+)
+
+const (
+	// BackgroundMaxProcs caps the number of concurrent
+	// transfers and OS threads in background mode.
+	BackgroundMaxProcs = 1
+	// BackgroundNiceness is the CPU priority that background mode
+	// lowers the process to, where supported. 19 is the lowest.
+	BackgroundNiceness = 19
+	// BackgroundBufferSize is the most read in one go from
+	// local files during background transfers.
+	BackgroundBufferSize = 16 * 1024
+	// BackgroundPacingInterval is the pause after each
+	// read during background transfers.
+	BackgroundPacingInterval = 2 * time.Millisecond
+)
+
+// enterBackground makes the process as unobtrusive as it can:
+// lowest CPU priority, a single OS thread, the smallest upload
+// chunks and, via concurrency, a single transfer at a time.
+func (g *Commands) enterBackground() {
+	runtime.GOMAXPROCS(BackgroundMaxProcs)
+
+	if g.opts.UploadChunkSize == 0 {
+		g.opts.UploadChunkSize = googleapi.MinUploadChunkSize
+	}
+
+	if err := lowerProcessPriority(BackgroundNiceness); err != nil {
+		g.log.LogErrf("background: could not lower the process priority: %v\n", err)
+	}
+}
+
+// concurrency is the number of transfers that can run at once.
+func (g *Commands) concurrency() int {
+	if g.opts != nil && g.opts.Background {
+		return BackgroundMaxProcs
+	}
+	return maxProcs()
+}
+
+// pacedReader reads in small bursts with a pause after each so that
+// background transfers leave the disk mostly free for the foreground.
+type pacedReader struct {
+	r io.Reader
+}
+
+func newPacedReader(r io.Reader) io.Reader {
+	return &pacedReader{r: r}
+}
+
+func (pr *pacedReader) Read(p []byte) (int, error) {
+	if len(p) > BackgroundBufferSize {
+		p = p[:BackgroundBufferSize]
+	}
+	n, err := pr.r.Read(p)
+	time.Sleep(BackgroundPacingInterval)
+	return n, err
+}
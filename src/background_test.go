@@ -0,0 +1,72 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+// readSizes records the size of each read.
+type readSizes struct {
+	sizes []int
+}
+
+func (rs *readSizes) Read(p []byte) (int, error) {
+	rs.sizes = append(rs.sizes, len(p))
+	return len(p), nil
+}
+
+func TestPacedReaderReadsInBursts(t *testing.T) {
+	rs := &readSizes{}
+	pr := newPacedReader(rs)
+
+	n, err := pr.Read(make([]byte, 4*BackgroundBufferSize))
+	if err != nil || n != BackgroundBufferSize {
+		t.Errorf("got %d, %v want a read of %d", n, err, BackgroundBufferSize)
+	}
+	if n, _ := pr.Read(make([]byte, 10)); n != 10 {
+		t.Errorf("got %d want small reads left as is", n)
+	}
+
+	content := bytes.Repeat([]byte("x"), 3*BackgroundBufferSize+1)
+	got, err := ioutil.ReadAll(newPacedReader(bytes.NewReader(content)))
+	if err != nil || !bytes.Equal(got, content) {
+		t.Errorf("expected the content read whole, got %d bytes, %v", len(got), err)
+	}
+}
+
+func TestBackgroundConcurrency(t *testing.T) {
+	prev, had := os.LookupEnv(DriveGoMaxProcsKey)
+	defer func() {
+		if had {
+			os.Setenv(DriveGoMaxProcsKey, prev)
+		} else {
+			os.Unsetenv(DriveGoMaxProcsKey)
+		}
+	}()
+	os.Setenv(DriveGoMaxProcsKey, "8")
+
+	g := &Commands{opts: &Options{}}
+	if got := g.concurrency(); got != 8 {
+		t.Errorf("foreground: got %d want 8", got)
+	}
+	g.opts.Background = true
+	if got := g.concurrency(); got != BackgroundMaxProcs {
+		t.Errorf("background: got %d want %d", got, BackgroundMaxProcs)
+	}
+}
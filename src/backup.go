@@ -0,0 +1,347 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const (
+	// BackupDateLayout is the name format of each snapshot directory.
+	BackupDateLayout = "2006-01-02"
+)
+
+// RetentionPolicy decides which snapshots survive pruning. Each count
+// keeps the newest snapshot of that many distinct days, weeks or months.
+// A policy whose counts are all zero keeps every snapshot.
+type RetentionPolicy struct {
+	Daily   int
+	Weekly  int
+	Monthly int
+}
+
+func (rp *RetentionPolicy) enabled() bool {
+	return rp != nil && (rp.Daily > 0 || rp.Weekly > 0 || rp.Monthly > 0)
+}
+
+// retained returns the set of snapshot names that rp keeps.
+// Names that aren't in BackupDateLayout are always kept.
+func (rp *RetentionPolicy) retained(names []string) map[string]bool {
+	keep := map[string]bool{}
+
+	var dated []string
+	for _, name := range names {
+		if _, err := time.Parse(BackupDateLayout, name); err != nil {
+			keep[name] = true
+			continue
+		}
+		dated = append(dated, name)
+	}
+
+	// Newest first so that each bucket keeps its latest snapshot.
+	sort.Sort(sort.Reverse(sort.StringSlice(dated)))
+
+	bucketers := []struct {
+		count  int
+		bucket func(time.Time) string
+	}{
+		{count: rp.Daily, bucket: func(t time.Time) string { return t.Format(BackupDateLayout) }},
+		{count: rp.Weekly, bucket: func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%d-%d", year, week)
+		}},
+		{count: rp.Monthly, bucket: func(t time.Time) string { return t.Format("2006-01") }},
+	}
+
+	for _, b := range bucketers {
+		seen := map[string]bool{}
+		for _, name := range dated {
+			if len(seen) >= b.count {
+				break
+			}
+			t, _ := time.Parse(BackupDateLayout, name)
+			key := b.bucket(t)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			keep[name] = true
+		}
+	}
+
+	return keep
+}
+
+// Backup pushes the sources into a date stamped snapshot directory under
+// the destination e.g backups/2025-01-15/. Files unchanged since the
+// previous snapshot are copied server side instead of being re-uploaded,
+// so that each snapshot is complete yet cheap to make. A snapshot started
+// today that the push then fails or is declined for is trashed, so that
+// retention never counts an incomplete snapshot.
+func (g *Commands) Backup() error {
+	if err := g.refuseReadOnly("backup"); err != nil {
+		return err
+	}
+	dest := g.opts.Destination
+	if dest == "" {
+		return invalidArgumentsErr(fmt.Errorf("backup: a destination is required"))
+	}
+	if g.opts.DryRun {
+		return invalidArgumentsErr(fmt.Errorf("backup: -%s isn't supported since seeding a snapshot copies files remotely", CLIOptionDryRun))
+	}
+
+	today := time.Now().Format(BackupDateLayout)
+	snapshotPath := remotePathJoin(dest, today)
+
+	snapshots, err := g.snapshots(dest)
+	if err != nil {
+		return err
+	}
+
+	var prev *File
+	fresh := true
+	for _, snapshot := range snapshots {
+		if snapshot.Name > today {
+			break
+		}
+		if snapshot.Name == today {
+			// Today's snapshot was already seeded, the push
+			// will reconcile whatever changed since then.
+			prev, fresh = nil, false
+			break
+		}
+		prev = snapshot
+	}
+
+	if prev != nil {
+		g.log.Logf("Seeding %s from %s\n", customQuote(snapshotPath), customQuote(prev.Name))
+		if err := g.seedSnapshot(prev, snapshotPath); err != nil {
+			return g.discardSnapshot(snapshotPath, err)
+		}
+	}
+
+	g.opts.Destination = snapshotPath
+	if err := g.Push(); err != nil {
+		if fresh {
+			return g.discardSnapshot(snapshotPath, err)
+		}
+		return err
+	}
+
+	if !g.opts.Retention.enabled() {
+		return nil
+	}
+
+	// Re-list to include today's snapshot.
+	if snapshots, err = g.snapshots(dest); err != nil {
+		return err
+	}
+	return g.pruneSnapshots(dest, snapshots)
+}
+
+// discardSnapshot trashes the snapshot at snapshotPath, if it was made at
+// all, since cause kept the backup from completing it. cause is returned.
+func (g *Commands) discardSnapshot(snapshotPath string, cause error) error {
+	snapshot, err := g.rem.FindByPath(snapshotPath)
+	if err != nil || snapshot == nil {
+		if err != ErrPathNotExists {
+			g.log.LogErrf("backup: looking up the incomplete %s: %v\n", customQuote(snapshotPath), err)
+		}
+		return cause
+	}
+
+	if err := g.rem.Trash(snapshot.Id); err != nil {
+		g.log.LogErrf("backup: trashing the incomplete %s: %v\n", customQuote(snapshotPath), err)
+		return cause
+	}
+	g.log.LogErrf("Trashed the incomplete %s\n", customQuote(snapshotPath))
+	return cause
+}
+
+// pruneSnapshots trashes the snapshots that the retention policy doesn't keep.
+func (g *Commands) pruneSnapshots(dest string, snapshots []*File) (err error) {
+	names := make([]string, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		names = append(names, snapshot.Name)
+	}
+
+	keep := g.opts.Retention.retained(names)
+
+	for _, snapshot := range snapshots {
+		if keep[snapshot.Name] {
+			continue
+		}
+
+		snapshotPath := remotePathJoin(dest, snapshot.Name)
+		if tErr := g.rem.Trash(snapshot.Id); tErr != nil {
+			err = reComposeError(err, fmt.Sprintf("prune %s: %v", snapshotPath, tErr))
+			continue
+		}
+		g.log.Logf("Pruned %s\n", customQuote(snapshotPath))
+	}
+
+	return err
+}
+
+// snapshots returns the snapshot directories under dest, oldest first.
+func (g *Commands) snapshots(dest string) ([]*File, error) {
+	destFile, err := g.rem.FindByPath(dest)
+	if err == ErrPathNotExists {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if destFile == nil || !destFile.IsDir {
+		return nil, illogicalStateErr(fmt.Errorf("%s: %v", dest, ErrPathNotDir))
+	}
+
+	pagePair := g.rem.FindByParentId(destFile.Id, false)
+	errsChan := pagePair.errsChan
+	childrenChan := pagePair.filesChan
+
+	var snapshots []*File
+
+	working := true
+	for working {
+		select {
+		case err := <-errsChan:
+			if err != nil {
+				return nil, err
+			}
+		case child, stillHasContent := <-childrenChan:
+			if !stillHasContent {
+				working = false
+				break
+			}
+			if child == nil || !child.IsDir {
+				continue
+			}
+			if _, pErr := time.Parse(BackupDateLayout, child.Name); pErr != nil {
+				continue
+			}
+			snapshots = append(snapshots, child)
+		}
+	}
+
+	// BackupDateLayout sorts lexically in chronological order.
+	sort.Sort(nameFlist(snapshots))
+
+	return snapshots, nil
+}
+
+// remoteTree maps the relative paths of all the files under
+// dir e.g "/a/b.txt" to their remote counterparts.
+func (g *Commands) remoteTree(dir *File, relPath string, tree map[string]*File) error {
+	pagePair := g.rem.FindByParentId(dir.Id, true)
+	errsChan := pagePair.errsChan
+	childrenChan := pagePair.filesChan
+
+	working := true
+	for working {
+		select {
+		case err := <-errsChan:
+			if err != nil {
+				return err
+			}
+		case child, stillHasContent := <-childrenChan:
+			if !stillHasContent {
+				working = false
+				break
+			}
+			if child == nil {
+				continue
+			}
+
+			childRelPath := remotePathJoin(relPath, child.Name)
+			tree[childRelPath] = child
+
+			if !child.IsDir {
+				continue
+			}
+			if err := g.remoteTree(child, childRelPath, tree); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// seedSnapshot server side copies every local file that is unchanged
+// since the prev snapshot into the snapshot at snapshotPath.
+func (g *Commands) seedSnapshot(prev *File, snapshotPath string) error {
+	prevTree := map[string]*File{}
+	if err := g.remoteTree(prev, "", prevTree); err != nil {
+		return err
+	}
+
+	rootAbsPath := g.context.AbsPathOf("")
+
+	for _, relToRootPath := range g.opts.Sources {
+		walkErr := filepath.Walk(g.context.AbsPathOf(relToRootPath), func(p string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			rel, rErr := filepath.Rel(rootAbsPath, p)
+			if rErr != nil {
+				return rErr
+			}
+			if rel == "." {
+				return nil
+			}
+			relPath := remotePathJoin(filepath.ToSlash(rel))
+
+			if anyMatch(g.opts.Ignorer, relPath) || isHidden(fi.Name(), g.opts.Hidden) {
+				if fi.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			if fi.IsDir() {
+				return nil
+			}
+
+			prevFile, ok := prevTree[relPath]
+			if !ok || prevFile.IsDir || !prevFile.Copyable {
+				return nil
+			}
+
+			local := NewLocalFile(p, fi)
+			if fileDifferences(local, prevFile, g.opts.IgnoreChecksum) != DifferNone {
+				return nil
+			}
+
+			destPath := remotePathJoin(snapshotPath, relPath)
+			if _, cErr := g.copy(prevFile, destPath); cErr != nil {
+				// The push will upload it instead.
+				g.log.LogErrf("backup: copy %s: %v\n", destPath, cErr)
+			}
+			return nil
+		})
+
+		if walkErr != nil {
+			return walkErr
+		}
+	}
+
+	return nil
+}
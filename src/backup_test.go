@@ -0,0 +1,123 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/odeke-em/drive/config"
+	"github.com/odeke-em/log"
+	drive "google.golang.org/api/drive/v2"
+)
+
+func TestRetentionPolicyRetained(t *testing.T) {
+	snapshots := []string{
+		"2016-01-31", "2016-02-28", "2016-03-01",
+		"2016-03-06", "2016-03-07", "2016-03-08",
+		"notes",
+	}
+
+	cases := []struct {
+		policy RetentionPolicy
+		want   map[string]bool
+	}{
+		{
+			policy: RetentionPolicy{Daily: 2},
+			want:   map[string]bool{"2016-03-08": true, "2016-03-07": true, "notes": true},
+		},
+		{
+			// 2016-03-06 is a Sunday, the last day of its ISO week.
+			policy: RetentionPolicy{Weekly: 2},
+			want:   map[string]bool{"2016-03-08": true, "2016-03-06": true, "notes": true},
+		},
+		{
+			policy: RetentionPolicy{Daily: 1, Monthly: 3},
+			want: map[string]bool{
+				"2016-03-08": true, "2016-02-28": true, "2016-01-31": true, "notes": true,
+			},
+		},
+		{
+			policy: RetentionPolicy{},
+			want:   map[string]bool{"notes": true},
+		},
+	}
+
+	for i, tc := range cases {
+		got := tc.policy.retained(snapshots)
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("#%d: policy %+v: got %v want %v", i, tc.policy, got, tc.want)
+		}
+	}
+}
+
+func TestBackupRefusesReadOnlyBeforeSeeding(t *testing.T) {
+	rem, err := remoteFromClient(&http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		t.Fatalf("unexpected request %s %s", req.Method, req.URL)
+		return nil, nil
+	})})
+	if err != nil {
+		t.Fatalf("remoteFromClient: %v", err)
+	}
+
+	context := &config.Context{AbsPath: "/tmp/gdrive"}
+	context.Scope = DriveReadOnlyScope
+	g := &Commands{rem: rem, context: context, opts: &Options{Destination: "backups", Sources: []string{"/"}}}
+	err = g.Backup()
+	if e, ok := err.(*Error); !ok || e.code != StatusImmutableOperationAttempted {
+		t.Errorf("got %v want an immutable operation error", err)
+	}
+}
+
+func TestDiscardSnapshot(t *testing.T) {
+	files := map[string]*drive.File{
+		"root":    {Id: "root", Title: "root", MimeType: DriveFolderMimeType},
+		"backups": {Id: "backups", Title: "backups", MimeType: DriveFolderMimeType},
+		"today":   {Id: "today", Title: "2016-03-08", MimeType: DriveFolderMimeType},
+	}
+	tree := fakeTreeTransport(t, files, map[string][]string{
+		"root":    {"backups"},
+		"backups": {"today"},
+	})
+
+	var requests []string
+	rem, err := remoteFromClient(&http.Client{Transport: replyingTransport(t, tree, map[string]interface{}{
+		"POST /drive/v2/files/today/trash": files["today"],
+	}, &requests)})
+	if err != nil {
+		t.Fatalf("remoteFromClient: %v", err)
+	}
+	g := &Commands{rem: rem, log: log.New(nil, ioutil.Discard, ioutil.Discard), opts: &Options{}}
+
+	cause := errors.New("declined")
+	if err := g.discardSnapshot("/backups/2016-03-08", cause); err != cause {
+		t.Errorf("got %v want the cause returned", err)
+	}
+	if want := []string{"POST /drive/v2/files/today/trash"}; !reflect.DeepEqual(requests, want) {
+		t.Errorf("got requests %q want %q", requests, want)
+	}
+
+	// A snapshot that was never made is left at that.
+	requests = nil
+	if err := g.discardSnapshot("/backups/2016-03-09", cause); err != cause {
+		t.Errorf("got %v want the cause returned", err)
+	}
+	if len(requests) != 0 {
+		t.Errorf("expected nothing trashed, got %q", requests)
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+var ErrBudgetExhausted = errors.New("the budget for this run is exhausted, re-run to resume")
+
+// budget caps the API calls made and bytes transferred in a run.
+// Once either is spent, no new changes are started though those under
+// way complete, however many requests e.g the chunks of a resumable
+// upload that takes, so that nothing is left half transferred.
+type budget struct {
+	// maxCalls and maxBytes are unlimited when 0.
+	maxCalls int64
+	maxBytes int64
+
+	// calls and bytes are updated atomically.
+	calls int64
+	bytes int64
+}
+
+func (b *budget) exhausted() bool {
+	if b == nil {
+		return false
+	}
+	if b.maxCalls > 0 && atomic.LoadInt64(&b.calls) >= b.maxCalls {
+		return true
+	}
+	return b.maxBytes > 0 && atomic.LoadInt64(&b.bytes) >= b.maxBytes
+}
+
+func (b *budget) String() string {
+	return fmt.Sprintf("%d API calls, %s", atomic.LoadInt64(&b.calls), prettyBytes(atomic.LoadInt64(&b.bytes)))
+}
+
+type budgetTransport struct {
+	base   http.RoundTripper
+	budget *budget
+}
+
+// RoundTrip counts req and its bytes against the budget, which is only
+// checked before starting each change rather than refusing req, lest
+// that cut a change short.
+func (bt *budgetTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt64(&bt.budget.calls, 1)
+
+	if req.Body != nil {
+		// RoundTrippers mustn't modify the request, so count a shallow copy's body.
+		counted := *req
+		counted.Body = &countingReadCloser{ReadCloser: req.Body, count: &bt.budget.bytes}
+		req = &counted
+	}
+
+	res, err := bt.base.RoundTrip(req)
+	if res != nil && res.Body != nil {
+		res.Body = &countingReadCloser{ReadCloser: res.Body, count: &bt.budget.bytes}
+	}
+	return res, err
+}
+
+type countingReadCloser struct {
+	io.ReadCloser
+	count *int64
+}
+
+func (crc *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := crc.ReadCloser.Read(p)
+	atomic.AddInt64(crc.count, int64(n))
+	return n, err
+}
+
+// budgetCheck reports an exhausted budget after the changes that
+// fit within it were played, so that a re-run picks up the rest.
+func (g *Commands) budgetCheck(err error) error {
+	if !g.rem.budget.exhausted() {
+		return err
+	}
+	g.log.LogErrf("budget: stopped after %v\n", g.rem.budget)
+	return reComposeError(err, ErrBudgetExhausted.Error())
+}
+
+// setBudget routes all of the remote's requests through b.
+func (r *Remote) setBudget(b *budget) {
+	base := r.client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	r.client.Transport = &budgetTransport{base: base, budget: b}
+	r.budget = b
+}
+
+var byteSizeSuffixes = []struct {
+	suffix     string
+	multiplier int64
+}{
+	{"T", 1 << 40},
+	{"G", 1 << 30},
+	{"M", 1 << 20},
+	{"K", 1 << 10},
+	{"B", 1},
+}
+
+// ParseByteSize parses sizes such as "512", "100K", "1.5G" or "20MiB",
+// where the suffixes are powers of 1024. An empty size is 0.
+func ParseByteSize(s string) (int64, error) {
+	str := strings.ToUpper(strings.TrimSpace(s))
+	if str == "" {
+		return 0, nil
+	}
+	if strings.HasSuffix(str, "IB") {
+		str = strings.TrimSuffix(str, "IB")
+	} else if n := len(str); n > 1 && str[n-1] == 'B' && strings.ContainsRune("KMGT", rune(str[n-2])) {
+		str = str[:n-1]
+	}
+
+	multiplier := int64(1)
+	for _, bs := range byteSizeSuffixes {
+		if strings.HasSuffix(str, bs.suffix) {
+			str = strings.TrimSuffix(str, bs.suffix)
+			multiplier = bs.multiplier
+			break
+		}
+	}
+
+	f, err := strconv.ParseFloat(strings.TrimSpace(str), 64)
+	if err != nil || f < 0 {
+		return 0, invalidArgumentsErr(fmt.Errorf("%q is not a size e.g 500M", s))
+	}
+	return int64(f * float64(multiplier)), nil
+}
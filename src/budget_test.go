@@ -0,0 +1,115 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"testing"
+)
+
+func TestParseByteSize(t *testing.T) {
+	testCases := []struct {
+		specimen string
+		want     int64
+		wantErr  bool
+	}{
+		{specimen: "512", want: 512},
+		{specimen: "512B", want: 512},
+		{specimen: "100K", want: 100 * 1024},
+		{specimen: "100k", want: 100 * 1024},
+		{specimen: "20MiB", want: 20 * 1024 * 1024},
+		{specimen: "5KB", want: 5 * 1024},
+		{specimen: "1.5G", want: 3 * 512 * 1024 * 1024},
+		{specimen: " 2T ", want: 2 * 1024 * 1024 * 1024 * 1024},
+		{specimen: "", want: 0},
+		{specimen: "G", wantErr: true},
+		{specimen: "-1M", wantErr: true},
+		{specimen: "tenM", wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		got, err := ParseByteSize(tc.specimen)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("%q: expected an error, got %d", tc.specimen, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected err %v", tc.specimen, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("%q: got %d want %d", tc.specimen, got, tc.want)
+		}
+	}
+}
+
+func TestBudgetExhausted(t *testing.T) {
+	var nilBudget *budget
+	if nilBudget.exhausted() {
+		t.Errorf("a nil budget should never be exhausted")
+	}
+
+	testCases := []struct {
+		b    *budget
+		want bool
+	}{
+		{b: &budget{}, want: false},
+		{b: &budget{calls: 100, bytes: 1 << 30}, want: false},
+		{b: &budget{maxCalls: 10, calls: 9}, want: false},
+		{b: &budget{maxCalls: 10, calls: 10}, want: true},
+		{b: &budget{maxBytes: 1024, bytes: 1023, calls: 1000}, want: false},
+		{b: &budget{maxBytes: 1024, bytes: 2048}, want: true},
+	}
+
+	for i, tc := range testCases {
+		if got := tc.b.exhausted(); got != tc.want {
+			t.Errorf("#%d: got %v want %v", i, got, tc.want)
+		}
+	}
+}
+
+func TestBudgetTransportFinishesChangesUnderWay(t *testing.T) {
+	base := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if _, err := ioutil.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewBufferString("hello"))}, nil
+	})
+
+	// Spent, as it would be midway through the chunks of an upload.
+	b := &budget{maxBytes: 4, bytes: 4, calls: 1}
+	bt := &budgetTransport{base: base, budget: b}
+
+	req, _ := http.NewRequest("PUT", "https://www.googleapis.com/upload/drive/v2/files/abc", bytes.NewBufferString("chunk"))
+	res, err := bt.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("the next chunk should still be sent, got %v", err)
+	}
+	if _, err := ioutil.ReadAll(res.Body); err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	if b.calls != 2 || b.bytes != 4+5+5 {
+		t.Errorf("got %d calls and %d bytes want 2 calls and 14 bytes", b.calls, b.bytes)
+	}
+	if !b.exhausted() {
+		t.Errorf("the budget should still be reported exhausted")
+	}
+}
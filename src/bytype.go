@@ -0,0 +1,188 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/odeke-em/log"
+)
+
+const (
+	CategoryImage    = "image"
+	CategoryVideo    = "video"
+	CategoryAudio    = "audio"
+	CategoryDocument = "document"
+	CategoryArchive  = "archive"
+	CategoryOther    = "other"
+)
+
+// noExtension labels files whose names have no extension.
+const noExtension = "(none)"
+
+var documentMimeTypes = []string{
+	"application/pdf",
+	"application/rtf",
+	"application/msword",
+	"application/vnd.ms-",
+	"application/vnd.oasis.opendocument.",
+	"application/vnd.openxmlformats-officedocument.",
+	"application/vnd.google-apps.",
+}
+
+var archiveMimeTypes = []string{
+	"application/zip",
+	"application/gzip",
+	"application/x-gzip",
+	"application/x-tar",
+	"application/x-compressed",
+	"application/x-bzip2",
+	"application/x-7z-compressed",
+	"application/x-rar-compressed",
+	"application/vnd.android.package-archive",
+}
+
+// fileExtension returns the lower cased extension of name without
+// its leading dot, or noExtension if it has none.
+func fileExtension(name string) string {
+	ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(name)), ".")
+	if ext == "" {
+		return noExtension
+	}
+	return ext
+}
+
+// fileCategory buckets a file by its mimeType, falling back to a
+// guess from its extension when Drive only knows it as a binary blob.
+func fileCategory(mimeType, name string) string {
+	if mimeType == "" || mimeType == "application/octet-stream" {
+		if ext := fileExtension(name); ext != noExtension {
+			if guessed := guessMimeType(ext); guessed != "" {
+				mimeType = guessed
+			}
+		}
+	}
+
+	switch {
+	case strings.HasPrefix(mimeType, "image/"):
+		return CategoryImage
+	case strings.HasPrefix(mimeType, "video/"):
+		return CategoryVideo
+	case strings.HasPrefix(mimeType, "audio/"):
+		return CategoryAudio
+	case strings.HasPrefix(mimeType, "text/"), hasAnyPrefix(mimeType, documentMimeTypes...):
+		return CategoryDocument
+	case hasAnyPrefix(mimeType, archiveMimeTypes...):
+		return CategoryArchive
+	}
+	return CategoryOther
+}
+
+type typeUsage struct {
+	name  string
+	count int64
+	size  int64
+}
+
+type typeUsageList []*typeUsage
+
+func (tl typeUsageList) Len() int      { return len(tl) }
+func (tl typeUsageList) Swap(i, j int) { tl[i], tl[j] = tl[j], tl[i] }
+func (tl typeUsageList) Less(i, j int) bool {
+	if tl[i].size != tl[j].size {
+		return tl[i].size > tl[j].size
+	}
+	return tl[i].name < tl[j].name
+}
+
+// typeTally aggregates the sizes of files by category and by extension.
+type typeTally struct {
+	mu         sync.Mutex
+	count      int64
+	size       int64
+	categories map[string]*typeUsage
+	extensions map[string]*typeUsage
+}
+
+func newTypeTally() *typeTally {
+	return &typeTally{
+		categories: make(map[string]*typeUsage),
+		extensions: make(map[string]*typeUsage),
+	}
+}
+
+func tallyInto(usages map[string]*typeUsage, key string, size int64) {
+	usage, ok := usages[key]
+	if !ok {
+		usage = &typeUsage{name: key}
+		usages[key] = usage
+	}
+	usage.count += 1
+	usage.size += size
+}
+
+func (tt *typeTally) add(f *File) {
+	if f == nil || f.IsDir {
+		return
+	}
+
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+
+	tt.count += 1
+	tt.size += f.Size
+	tallyInto(tt.categories, fileCategory(f.MimeType, f.Name), f.Size)
+	tallyInto(tt.extensions, fileExtension(f.Name), f.Size)
+}
+
+func sortedUsages(usages map[string]*typeUsage) typeUsageList {
+	var tl typeUsageList
+	for _, usage := range usages {
+		tl = append(tl, usage)
+	}
+	sort.Sort(tl)
+	return tl
+}
+
+func (tt *typeTally) percentOf(size int64) float64 {
+	if tt.size < 1 {
+		return 0
+	}
+	return 100 * float64(size) / float64(tt.size)
+}
+
+func (tt *typeTally) report(logy *log.Logger) {
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+
+	row := func(usage *typeUsage) {
+		logy.Logf("%-12s %8d %12s %6.2f%%\n", usage.name, usage.count, prettyBytes(usage.size), tt.percentOf(usage.size))
+	}
+
+	logy.Logf("%-12s %8s %12s %7s\n", "type", "files", "size", "share")
+	for _, usage := range sortedUsages(tt.categories) {
+		row(usage)
+	}
+
+	logy.Logf("\n%-12s %8s %12s %7s\n", "extension", "files", "size", "share")
+	for _, usage := range sortedUsages(tt.extensions) {
+		row(usage)
+	}
+
+	logy.Logf("\n%d files, %s total\n", tt.count, prettyBytes(tt.size))
+}
@@ -0,0 +1,95 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"testing"
+)
+
+func TestFileCategory(t *testing.T) {
+	testCases := []struct {
+		mimeType string
+		name     string
+		want     string
+	}{
+		{mimeType: "image/jpeg", name: "beach.jpg", want: CategoryImage},
+		{mimeType: "video/mp4", name: "talk.mp4", want: CategoryVideo},
+		{mimeType: "audio/mpeg", name: "song.mp3", want: CategoryAudio},
+		{mimeType: "application/pdf", name: "paper.pdf", want: CategoryDocument},
+		{mimeType: "text/plain", name: "notes", want: CategoryDocument},
+		{mimeType: "application/vnd.google-apps.spreadsheet", name: "budget", want: CategoryDocument},
+		{mimeType: "application/vnd.openxmlformats-officedocument.wordprocessingml.document", name: "cv.docx", want: CategoryDocument},
+		{mimeType: "application/zip", name: "photos.zip", want: CategoryArchive},
+		{mimeType: "application/octet-stream", name: "scan.png", want: CategoryImage},
+		{mimeType: "", name: "readme.txt", want: CategoryDocument},
+		{mimeType: "application/octet-stream", name: "blob", want: CategoryOther},
+		{mimeType: "application/x-sqlite3", name: "index.db", want: CategoryOther},
+	}
+
+	for i, tc := range testCases {
+		if got := fileCategory(tc.mimeType, tc.name); got != tc.want {
+			t.Errorf("#%d: fileCategory(%q, %q) = %q want %q", i, tc.mimeType, tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestTypeTally(t *testing.T) {
+	tt := newTypeTally()
+	files := []*File{
+		{Name: "a.jpg", MimeType: "image/jpeg", Size: 300},
+		{Name: "b.JPG", MimeType: "image/jpeg", Size: 200},
+		{Name: "c.mp4", MimeType: "video/mp4", Size: 1000},
+		{Name: "Makefile", MimeType: "text/plain", Size: 10},
+		{Name: "dir", MimeType: DriveFolderMimeType, IsDir: true},
+		nil,
+	}
+	for _, f := range files {
+		tt.add(f)
+	}
+
+	if tt.count != 4 || tt.size != 1510 {
+		t.Fatalf("totals: got %d files, %d bytes want 4 files, 1510 bytes", tt.count, tt.size)
+	}
+
+	wantCategories := []typeUsage{
+		{name: CategoryVideo, count: 1, size: 1000},
+		{name: CategoryImage, count: 2, size: 500},
+		{name: CategoryDocument, count: 1, size: 10},
+	}
+	gotCategories := sortedUsages(tt.categories)
+	if len(gotCategories) != len(wantCategories) {
+		t.Fatalf("categories: got %d want %d", len(gotCategories), len(wantCategories))
+	}
+	for i, want := range wantCategories {
+		if got := *gotCategories[i]; got != want {
+			t.Errorf("category #%d: got %+v want %+v", i, got, want)
+		}
+	}
+
+	wantExtensions := []typeUsage{
+		{name: "mp4", count: 1, size: 1000},
+		{name: "jpg", count: 2, size: 500},
+		{name: noExtension, count: 1, size: 10},
+	}
+	gotExtensions := sortedUsages(tt.extensions)
+	if len(gotExtensions) != len(wantExtensions) {
+		t.Fatalf("extensions: got %d want %d", len(gotExtensions), len(wantExtensions))
+	}
+	for i, want := range wantExtensions {
+		if got := *gotExtensions[i]; got != want {
+			t.Errorf("extension #%d: got %+v want %+v", i, got, want)
+		}
+	}
+}
@@ -156,7 +156,7 @@ func (g *Commands) byRemoteResolve(relToRoot, fsPath string, r *File, push bool)
 }
 
 func (g *Commands) changeListResolve(relToRoot, fsPath string, push bool) (cl, clashes []*Change, err error) {
-	pagePair := g.rem.FindByPathM(relToRoot)
+	pagePair := g.findByPathM(relToRoot)
 	iterCount := uint64(0)
 	noClashThreshold := uint64(1)
 
@@ -337,10 +337,51 @@ func (g *Commands) resolveChangeListRecv(clr *changeListResolve) (cl, clashes []
 		matchChecks = append(matchChecks, r.Name)
 	}
 
+	// Directory-only patterns can't tell a folder that only exists remotely without a hint.
+	if (l != nil && l.IsDir) || (r != nil && r.IsDir) {
+		matchChecks = append(matchChecks, clr.localBase+"/")
+	}
+
 	if anyMatch(g.opts.Ignorer, matchChecks...) {
 		return
 	}
 
+	// Excluded folders are pruned along with everything in them.
+	if g.opts.PathFilter != nil {
+		isDir := (l != nil && l.IsDir) || (r != nil && r.IsDir)
+		if !g.opts.PathFilter.admits(filepath.ToSlash(clr.localBase), isDir) {
+			return
+		}
+	}
+
+	// Folders outside of an include-only whitelist are still walked for
+	// the matching files in them, but aren't transferred themselves.
+	included := true
+	if g.opts.IncludeOnly != nil {
+		isDir := (l != nil && l.IsDir) || (r != nil && r.IsDir)
+		if !g.opts.IncludeOnly.includes(filepath.ToSlash(clr.localBase), isDir) {
+			if !isDir {
+				return
+			}
+			included = false
+		}
+	}
+
+	if g.isVirtualStarred(clr.localBase) || clr.remoteBase == SplitPartsFolderPath {
+		return
+	}
+
+	if ((l != nil && l.IsDir) || (r != nil && r.IsDir)) && g.isNestedContext(clr.localBase) {
+		return
+	}
+
+	if l != nil && l.IsDir && g.skipSyncTree(clr.localBase) {
+		return
+	}
+
+	g.seedCachedChecksum(l, clr.localBase)
+	g.noteRemotePath(r, clr.remoteBase)
+
 	explicitlyRequested := g.opts.ExplicitlyExport && hasExportLinks(r) && len(g.opts.Exports) >= 1
 
 	if clr.push {
@@ -377,7 +418,7 @@ func (g *Commands) resolveChangeListRecv(clr *changeListResolve) (cl, clashes []
 		return cl, clashes, nil
 	}
 
-	if change.Op() != OpNone {
+	if included && change.Op() != OpNone {
 		subject := directionalComplement(l, r, clr.push)
 		if clr.filter == nil || clr.filter(subject) {
 			cl = append(cl, change)
@@ -425,6 +466,7 @@ func (g *Commands) resolveChangeListRecv(clr *changeListResolve) (cl, clashes []
 			hidden:  g.opts.Hidden,
 			depth:   originalDepth, // local listing needs to start from original depth
 			ignore:  g.opts.Ignorer,
+			skip:    g.skip,
 		}
 
 		var lErr error
@@ -438,7 +480,7 @@ func (g *Commands) resolveChangeListRecv(clr *changeListResolve) (cl, clashes []
 	var pagePair *paginationPair
 
 	if r != nil {
-		pagePair = g.rem.FindByParentId(r.Id, g.opts.Hidden)
+		pagePair = g.shardedChildren(r.Id, clr.remoteBase)
 	} else {
 		// TODO: Figure out if the condition
 		// file == nil && err == nil
@@ -542,9 +584,20 @@ func (g *Commands) changeSlice(cslArg *changeSliceArg) {
 		localBase := remotePathJoin(cslArg.localParent, l.Name())
 		remoteBase := remotePathJoin(cslArg.remoteParent, l.Name())
 
+		if !push && l.remote != nil {
+			if reason := unrepresentableLocally(l.remote.Name); reason != "" {
+				g.skip(remoteBase, reason)
+				continue
+			}
+		}
+
 		nonDirRemote := l.remote != nil && !l.remote.IsDir
 		if nonDirRemote && g.opts.CryptoEnabled() {
-			l.remote.Size -= int64(dcrypto.Overhead)
+			localSize := int64(-1)
+			if l.local != nil {
+				localSize = l.local.Size
+			}
+			l.remote.Size = dcrypto.PlaintextSize(l.remote.Size, localSize)
 		}
 
 		clr := &changeListResolve{
@@ -570,8 +623,7 @@ func (g *Commands) changeSlice(cslArg *changeSliceArg) {
 			clashesMap[id] = childClashes
 			continue
 		} else if cErr != ErrPathNotExists {
-			g.log.LogErrf("%s: %v\n", localBase, cErr)
-			break
+			g.skip(localBase, cErr.Error())
 		}
 	}
 }
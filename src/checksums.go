@@ -0,0 +1,411 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/odeke-em/drive/config"
+)
+
+const (
+	hashdeepHeaderPrefix  = "%%%%"
+	hashdeepCommentPrefix = "##"
+	hashdeepSizeColumn    = "size"
+	hashdeepMd5Column     = "md5"
+	hashdeepPathColumn    = "filename"
+)
+
+type manifestEntry struct {
+	path string
+	md5  string
+	// size is -1 when the manifest doesn't record it.
+	size int64
+}
+
+// parseChecksumManifest reads the entries of either an md5sum(1) manifest
+// i.e lines of "<md5>  <path>" or a hashdeep manifest that has an md5 column.
+func parseChecksumManifest(r io.Reader) (entries []*manifestEntry, err error) {
+	var columns []string
+
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, hashdeepCommentPrefix) {
+			continue
+		}
+
+		if strings.HasPrefix(line, hashdeepHeaderPrefix) {
+			header := strings.TrimSpace(strings.TrimPrefix(line, hashdeepHeaderPrefix))
+			if strings.Contains(header, ",") {
+				columns = strings.Split(header, ",")
+			}
+			continue
+		}
+
+		var entry *manifestEntry
+		if columns != nil {
+			entry, err = hashdeepEntry(line, columns)
+		} else {
+			entry, err = md5sumEntry(line)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNo, err)
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, scanner.Err()
+}
+
+func md5sumEntry(line string) (*manifestEntry, error) {
+	splits := strings.SplitN(line, " ", 2)
+	if len(splits) != 2 || len(splits[0]) != 32 || len(splits[1]) < 2 {
+		return nil, fmt.Errorf("expecting \"<md5>  <path>\" got %q", line)
+	}
+
+	// The second separator is either ' ' for text
+	// mode or '*' for binary mode, both are alike here.
+	return &manifestEntry{md5: strings.ToLower(splits[0]), path: splits[1][1:], size: -1}, nil
+}
+
+func hashdeepEntry(line string, columns []string) (*manifestEntry, error) {
+	// The filename comes last and may itself contain commas.
+	values := strings.SplitN(line, ",", len(columns))
+	if len(values) != len(columns) {
+		return nil, fmt.Errorf("expecting %d columns got %q", len(columns), line)
+	}
+
+	entry := &manifestEntry{size: -1}
+	for i, column := range columns {
+		switch column {
+		case hashdeepSizeColumn:
+			size, err := strconv.ParseInt(values[i], 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			entry.size = size
+		case hashdeepMd5Column:
+			entry.md5 = strings.ToLower(values[i])
+		case hashdeepPathColumn:
+			entry.path = values[i]
+		}
+	}
+
+	if entry.md5 == "" || entry.path == "" {
+		return nil, fmt.Errorf("expecting md5 and filename columns in %v", columns)
+	}
+	return entry, nil
+}
+
+func checksumKey(relToRoot string) string {
+	return path.Clean(path.Join("/", filepath.ToSlash(relToRoot)))
+}
+
+// ImportChecksums seeds the local checksum cache from an md5sum or hashdeep
+// manifest so that files hashed elsewhere aren't hashed again. Relative paths
+// in the manifest are resolved from the current working directory.
+// Files whose sizes differ from those in the manifest are skipped.
+func (g *Commands) ImportChecksums(manifestPath string) error {
+	f, err := os.Open(manifestPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	entries, err := parseChecksumManifest(f)
+	if err != nil {
+		return invalidArgumentsErr(fmt.Errorf("%s: %v", manifestPath, err))
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	rootAbsPath := g.context.AbsPathOf("")
+	checksums := make(map[string]*config.Checksum)
+
+	skipped := 0
+	for _, entry := range entries {
+		absPath := entry.path
+		if !filepath.IsAbs(absPath) {
+			absPath = filepath.Join(cwd, absPath)
+		}
+
+		rel, rErr := filepath.Rel(rootAbsPath, absPath)
+		if rErr != nil || strings.HasPrefix(rel, "..") {
+			skipped += 1
+			continue
+		}
+
+		fi, statErr := os.Stat(absPath)
+		if statErr != nil || fi.IsDir() {
+			skipped += 1
+			continue
+		}
+
+		if entry.size >= 0 && entry.size != fi.Size() {
+			g.log.LogErrf("%s: size differs from that in the manifest, skipping\n", customQuote(rel))
+			skipped += 1
+			continue
+		}
+
+		checksums[checksumKey(rel)] = &config.Checksum{
+			Md5Checksum: entry.md5,
+			Size:        fi.Size(),
+			ModTime:     fi.ModTime().Round(time.Second).Unix(),
+		}
+	}
+
+	if err := g.context.SerializeChecksums(checksums); err != nil {
+		return err
+	}
+
+	g.log.Logf("Imported %d checksums, skipped %d\n", len(checksums), skipped)
+	return nil
+}
+
+// seedCachedChecksum fills in local's checksum from the
+// checksum cache if local hasn't changed since it was cached.
+func (g *Commands) seedCachedChecksum(local *File, relToRoot string) {
+	if local == nil || local.IsDir || local.Md5Checksum != "" {
+		return
+	}
+
+	g.checksumsOnce.Do(func() {
+		checksums, err := g.context.DeserializeChecksums()
+		if err != nil {
+			g.log.LogErrf("checksum cache: %v\n", err)
+		}
+		g.checksums = checksums
+	})
+
+	cached, ok := g.checksums[checksumKey(relToRoot)]
+	if !ok || cached.Size != local.Size || cached.ModTime != local.ModTime.Unix() {
+		return
+	}
+
+	local.Md5Checksum = cached.Md5Checksum
+}
+
+// cacheChecksum records local's checksum in the checksum cache, e.g once it
+// is uploaded, so that the next run needn't hash it again while unchanged.
+func (g *Commands) cacheChecksum(local *File, md5Checksum string) {
+	if local == nil || local.IsDir || local.BlobAt == "" || md5Checksum == "" {
+		return
+	}
+
+	relToRoot, err := filepath.Rel(g.context.AbsPathOf(""), local.BlobAt)
+	if err != nil || strings.HasPrefix(relToRoot, "..") {
+		return
+	}
+
+	checksums := map[string]*config.Checksum{
+		checksumKey(relToRoot): {
+			Md5Checksum: md5Checksum,
+			Size:        local.Size,
+			ModTime:     local.ModTime.Unix(),
+		},
+	}
+	if err := g.context.SerializeChecksums(checksums); err != nil {
+		g.log.LogErrf("checksum cache: %v\n", err)
+	}
+}
+
+// Checksum prints a hashdeep manifest of the sizes and md5 checksums of the
+// files under each source. Paths are relative to each source so that the
+// manifests of different folders or accounts can be compared with diff(1).
+// If remote is set, checksums come from the remote metadata and nothing
+// is downloaded. Docs and Sheets have no checksums and are left out.
+func (g *Commands) Checksum(remote bool) (err error) {
+	g.log.Logf("%s HASHDEEP-1.0\n", hashdeepHeaderPrefix)
+	g.log.Logf("%s %s,%s,%s\n", hashdeepHeaderPrefix, hashdeepSizeColumn, hashdeepMd5Column, hashdeepPathColumn)
+
+	for _, relToRootPath := range g.opts.Sources {
+		var entries []*manifestEntry
+		var cErr error
+		if remote {
+			entries, cErr = g.remoteChecksums(relToRootPath)
+		} else {
+			entries, cErr = g.localChecksums(relToRootPath)
+		}
+		if cErr != nil {
+			err = reComposeError(err, fmt.Sprintf("checksum: %s: %v", relToRootPath, cErr))
+			continue
+		}
+
+		for _, entry := range entries {
+			g.log.Logf("%d,%s,%s\n", entry.size, entry.md5, entry.path)
+		}
+	}
+
+	return err
+}
+
+func (g *Commands) remoteChecksums(relToRootPath string) ([]*manifestEntry, error) {
+	root, err := g.rem.FindByPath(relToRootPath)
+	if err != nil {
+		return nil, err
+	}
+	if root == nil {
+		return nil, ErrPathNotExists
+	}
+
+	tree, err := g.remoteChecksumTree(root)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*manifestEntry
+	for relPath, f := range tree {
+		if f.Md5Checksum == "" {
+			continue
+		}
+		entries = append(entries, &manifestEntry{path: relPath, md5: f.Md5Checksum, size: f.Size})
+	}
+
+	sort.Sort(manifestEntriesByPath(entries))
+	return entries, nil
+}
+
+// remoteChecksumTree maps the paths relative to root of every file under
+// it, honoring the hidden and ignore options. Folders are left out.
+// If root is a file, it is mapped by its name.
+func (g *Commands) remoteChecksumTree(root *File) (map[string]*File, error) {
+	tree := map[string]*File{}
+	if !root.IsDir {
+		tree[root.Name] = root
+		return tree, nil
+	}
+
+	var walk func(dir *File, relPath string) error
+	walk = func(dir *File, relPath string) error {
+		pagePair := g.rem.FindByParentId(dir.Id, g.opts.Hidden)
+		errsChan := pagePair.errsChan
+		childrenChan := pagePair.filesChan
+
+		var dirs []*File
+
+		working := true
+		for working {
+			select {
+			case err := <-errsChan:
+				if err != nil {
+					return err
+				}
+			case child, stillHasContent := <-childrenChan:
+				if !stillHasContent {
+					working = false
+					break
+				}
+				if child == nil || anyMatch(g.opts.Ignorer, child.Name) {
+					continue
+				}
+				if child.IsDir {
+					dirs = append(dirs, child)
+					continue
+				}
+				tree[path.Join(relPath, child.Name)] = child
+			}
+		}
+
+		for _, child := range dirs {
+			if err := walk(child, path.Join(relPath, child.Name)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return tree, walk(root, "")
+}
+
+func (g *Commands) localChecksums(relToRootPath string) ([]*manifestEntry, error) {
+	rootAbsPath := g.context.AbsPathOf("")
+	srcAbsPath := g.context.AbsPathOf(relToRootPath)
+
+	srcInfo, err := os.Stat(srcAbsPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// A lone file is listed by its name just like its remote counterpart.
+	base := srcAbsPath
+	if !srcInfo.IsDir() {
+		base = filepath.Dir(srcAbsPath)
+	}
+
+	var entries []*manifestEntry
+	walkErr := filepath.Walk(srcAbsPath, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == srcAbsPath && fi.IsDir() {
+			return nil
+		}
+
+		if anyMatch(g.opts.Ignorer, fi.Name()) || isHidden(fi.Name(), g.opts.Hidden) {
+			if fi.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if fi.IsDir() || !fi.Mode().IsRegular() {
+			return nil
+		}
+
+		relToRoot, rErr := filepath.Rel(rootAbsPath, p)
+		if rErr != nil {
+			return rErr
+		}
+		relToBase, rErr := filepath.Rel(base, p)
+		if rErr != nil {
+			return rErr
+		}
+
+		local := NewLocalFile(p, fi)
+		g.seedCachedChecksum(local, relToRoot)
+		checksum := md5Checksum(local)
+		if checksum == "" {
+			return fmt.Errorf("%s: could not be checksummed", customQuote(relToRoot))
+		}
+
+		entries = append(entries, &manifestEntry{path: filepath.ToSlash(relToBase), md5: checksum, size: fi.Size()})
+		return nil
+	})
+
+	if walkErr != nil {
+		return nil, walkErr
+	}
+
+	sort.Sort(manifestEntriesByPath(entries))
+	return entries, nil
+}
+
+type manifestEntriesByPath []*manifestEntry
+
+func (me manifestEntriesByPath) Len() int           { return len(me) }
+func (me manifestEntriesByPath) Less(i, j int) bool { return me[i].path < me[j].path }
+func (me manifestEntriesByPath) Swap(i, j int)      { me[i], me[j] = me[j], me[i] }
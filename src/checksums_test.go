@@ -0,0 +1,67 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseChecksumManifest(t *testing.T) {
+	cases := []struct {
+		manifest string
+		want     []*manifestEntry
+		wantErr  bool
+	}{
+		{
+			manifest: "d41d8cd98f00b204e9800998ecf8427e  empty.txt\n" +
+				"B1946AC92492D2347C6235B4D2611184 *bin/hello world\n",
+			want: []*manifestEntry{
+				{md5: "d41d8cd98f00b204e9800998ecf8427e", path: "empty.txt", size: -1},
+				{md5: "b1946ac92492d2347c6235b4d2611184", path: "bin/hello world", size: -1},
+			},
+		},
+		{
+			manifest: "%%%% HASHDEEP-1.0\n" +
+				"%%%% size,md5,sha256,filename\n" +
+				"## Invoked from: /home/user\n" +
+				"##\n" +
+				"6,b1946ac92492d2347c6235b4d2611184,5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03,/home/user/a,b.txt\n",
+			want: []*manifestEntry{
+				{md5: "b1946ac92492d2347c6235b4d2611184", path: "/home/user/a,b.txt", size: 6},
+			},
+		},
+		{manifest: "not-a-checksum path\n", wantErr: true},
+		{manifest: "%%%% size,sha256,filename\n6,abc,a.txt\n", wantErr: true},
+	}
+
+	for i, tc := range cases {
+		got, err := parseChecksumManifest(strings.NewReader(tc.manifest))
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("#%d: expected an error", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: unexpected err: %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("#%d: got %v want %v", i, got, tc.want)
+		}
+	}
+}
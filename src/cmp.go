@@ -0,0 +1,114 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"sort"
+)
+
+type treeComparison struct {
+	onlyInA   []string
+	onlyInB   []string
+	differing []string
+}
+
+func (tc *treeComparison) identical() bool {
+	return len(tc.onlyInA) < 1 && len(tc.onlyInB) < 1 && len(tc.differing) < 1
+}
+
+// sameContent reports whether a and b have the same content as far as their
+// metadata can tell. Docs and Sheets have no checksums so they can only be
+// told apart by their types.
+func sameContent(a, b *File) bool {
+	if a.Md5Checksum == "" || b.Md5Checksum == "" {
+		return a.Md5Checksum == b.Md5Checksum && a.MimeType == b.MimeType
+	}
+	return a.Size == b.Size && a.Md5Checksum == b.Md5Checksum
+}
+
+func compareTrees(a, b map[string]*File) *treeComparison {
+	tc := &treeComparison{}
+
+	for relPath, fa := range a {
+		fb, ok := b[relPath]
+		if !ok {
+			tc.onlyInA = append(tc.onlyInA, relPath)
+			continue
+		}
+		if !sameContent(fa, fb) {
+			tc.differing = append(tc.differing, relPath)
+		}
+	}
+
+	for relPath := range b {
+		if _, ok := a[relPath]; !ok {
+			tc.onlyInB = append(tc.onlyInB, relPath)
+		}
+	}
+
+	sort.Strings(tc.onlyInA)
+	sort.Strings(tc.onlyInB)
+	sort.Strings(tc.differing)
+
+	return tc
+}
+
+// Cmp compares the two remote trees in the sources by name, size and md5
+// checksum, entirely from their metadata. It reports the files that are
+// only in either tree and those that differ.
+func (g *Commands) Cmp() error {
+	if len(g.opts.Sources) != 2 {
+		return invalidArgumentsErr(fmt.Errorf("cmp: expecting exactly two remote paths"))
+	}
+
+	var trees []map[string]*File
+	for _, relToRootPath := range g.opts.Sources {
+		root, err := g.rem.FindByPath(relToRootPath)
+		if err != nil && err != ErrPathNotExists {
+			return err
+		}
+		if root == nil {
+			return nonExistantRemoteErr(fmt.Errorf("cmp: %s doesnot exist", customQuote(relToRootPath)))
+		}
+
+		tree, err := g.remoteChecksumTree(root)
+		if err != nil {
+			return err
+		}
+		trees = append(trees, tree)
+	}
+
+	pathA, pathB := g.opts.Sources[0], g.opts.Sources[1]
+	tc := compareTrees(trees[0], trees[1])
+
+	for _, relPath := range tc.onlyInA {
+		g.log.Logf("only in %s: %s\n", pathA, relPath)
+	}
+	for _, relPath := range tc.onlyInB {
+		g.log.Logf("only in %s: %s\n", pathB, relPath)
+	}
+	for _, relPath := range tc.differing {
+		g.log.Logf("differs: %s\n", relPath)
+	}
+
+	if tc.identical() {
+		g.log.Logf("%s and %s are identical\n", customQuote(pathA), customQuote(pathB))
+		return nil
+	}
+
+	return treesDifferErr(fmt.Errorf("%d only in %s, %d only in %s, %d differing",
+		len(tc.onlyInA), pathA, len(tc.onlyInB), pathB, len(tc.differing)))
+}
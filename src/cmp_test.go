@@ -0,0 +1,56 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompareTrees(t *testing.T) {
+	a := map[string]*File{
+		"same.txt":     {Size: 3, Md5Checksum: "abc"},
+		"changed.txt":  {Size: 3, Md5Checksum: "abc"},
+		"resized.txt":  {Size: 3, Md5Checksum: "abc"},
+		"only-a.txt":   {Size: 1, Md5Checksum: "a"},
+		"dir/doc":      {MimeType: "application/vnd.google-apps.document"},
+		"dir/was-doc":  {MimeType: "application/vnd.google-apps.document"},
+		"dir/nested/x": {Size: 1, Md5Checksum: "x"},
+	}
+	b := map[string]*File{
+		"same.txt":     {Size: 3, Md5Checksum: "abc"},
+		"changed.txt":  {Size: 3, Md5Checksum: "def"},
+		"resized.txt":  {Size: 4, Md5Checksum: "abc"},
+		"only-b.txt":   {Size: 1, Md5Checksum: "b"},
+		"dir/doc":      {MimeType: "application/vnd.google-apps.document"},
+		"dir/was-doc":  {Size: 10, Md5Checksum: "pdf", MimeType: "application/pdf"},
+		"dir/nested/x": {Size: 1, Md5Checksum: "x"},
+	}
+
+	got := compareTrees(a, b)
+	want := &treeComparison{
+		onlyInA:   []string{"only-a.txt"},
+		onlyInB:   []string{"only-b.txt"},
+		differing: []string{"changed.txt", "dir/was-doc", "resized.txt"},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v want %+v", got, want)
+	}
+
+	if tc := compareTrees(a, a); !tc.identical() {
+		t.Errorf("a tree should be identical to itself, got %+v", tc)
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+// CollateLocale orders names the way the user's locale and most file
+// managers do, comparing runs of digits by their numeric value so
+// that e.g "file2" comes before "file10".
+const CollateLocale = "locale"
+
+// ValidateCollation reports whether collation is a known collation.
+func ValidateCollation(collation string) error {
+	switch strings.ToLower(strings.TrimSpace(collation)) {
+	case "", CollateLocale:
+		return nil
+	}
+	return invalidArgumentsErr(fmt.Errorf("unknown collation %q, only %q is supported", collation, CollateLocale))
+}
+
+// collationLocale returns the BCP 47 form of the locale that the
+// environment picks for collation e.g "en-GB" for LANG=en_GB.UTF-8,
+// or "" for the C and POSIX locales.
+func collationLocale(getenv func(string) string) string {
+	var locale string
+	for _, key := range []string{"LC_ALL", "LC_COLLATE", "LANG"} {
+		if locale = strings.TrimSpace(getenv(key)); locale != "" {
+			break
+		}
+	}
+
+	if i := strings.IndexAny(locale, ".@"); i >= 0 {
+		locale = locale[:i]
+	}
+	if locale == "C" || locale == "POSIX" {
+		return ""
+	}
+	return strings.Replace(locale, "_", "-", -1)
+}
+
+func localeCollated(opts *Options) bool {
+	if opts == nil || opts.Meta == nil {
+		return false
+	}
+	for _, collation := range (*opts.Meta)[CollateKey] {
+		if strings.EqualFold(collation, CollateLocale) {
+			return true
+		}
+	}
+	return false
+}
+
+type localeNameFlist struct {
+	fl       fileList
+	collator *collate.Collator
+}
+
+func newLocaleNameFlist(fl []*File) *localeNameFlist {
+	tag := language.Make(collationLocale(os.Getenv))
+	return &localeNameFlist{fl: fl, collator: collate.New(tag, collate.Numeric)}
+}
+
+func (lf *localeNameFlist) Less(i, j int) bool {
+	return nilCmpOrProceed(func(l, r *File) bool {
+		return lf.collator.CompareString(l.Name, r.Name) < 0
+	})(lf.fl[i], lf.fl[j])
+}
+
+func (lf *localeNameFlist) Len() int {
+	return len(lf.fl)
+}
+
+func (lf *localeNameFlist) Swap(i, j int) {
+	lf.fl[i], lf.fl[j] = lf.fl[j], lf.fl[i]
+}
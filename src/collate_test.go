@@ -0,0 +1,78 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+func TestCollationLocale(t *testing.T) {
+	tests := []struct {
+		env  map[string]string
+		want string
+	}{
+		{env: nil, want: ""},
+		{env: map[string]string{"LANG": "C"}, want: ""},
+		{env: map[string]string{"LANG": "POSIX"}, want: ""},
+		{env: map[string]string{"LANG": "en_GB.UTF-8"}, want: "en-GB"},
+		{env: map[string]string{"LANG": "de_DE@euro"}, want: "de-DE"},
+		{env: map[string]string{"LANG": "en_US.UTF-8", "LC_COLLATE": "sv_SE.UTF-8"}, want: "sv-SE"},
+		{env: map[string]string{"LANG": "en_US.UTF-8", "LC_COLLATE": "sv_SE", "LC_ALL": "C.UTF-8"}, want: ""},
+	}
+
+	for i, tt := range tests {
+		getenv := func(key string) string { return tt.env[key] }
+		if got := collationLocale(getenv); got != tt.want {
+			t.Errorf("#%d: got %q want %q", i, got, tt.want)
+		}
+	}
+}
+
+func TestValidateCollation(t *testing.T) {
+	for _, collation := range []string{"", "locale", " Locale "} {
+		if err := ValidateCollation(collation); err != nil {
+			t.Errorf("%q: unexpected error %v", collation, err)
+		}
+	}
+	for _, collation := range []string{"natural", "bytes"} {
+		if err := ValidateCollation(collation); err == nil {
+			t.Errorf("%q: expected an error", collation)
+		}
+	}
+}
+
+func TestLocaleNameFlist(t *testing.T) {
+	var fl []*File
+	for _, name := range []string{"file10.txt", "File2.txt", "file1.txt", "éclair", "banana", "Apple"} {
+		fl = append(fl, &File{Name: name})
+	}
+
+	lf := &localeNameFlist{fl: fl, collator: collate.New(language.English, collate.Numeric)}
+	sort.Stable(lf)
+
+	var got []string
+	for _, f := range fl {
+		got = append(got, f.Name)
+	}
+	want := []string{"Apple", "banana", "éclair", "file1.txt", "File2.txt", "file10.txt"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v want %v", got, want)
+	}
+}
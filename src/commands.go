@@ -20,7 +20,8 @@ import (
 	"io"
 	"os"
 	"path"
-	"path/filepath"
+	"sync"
+	"time"
 
 	"github.com/cheggaaa/pb"
 	"github.com/mattn/go-isatty"
@@ -110,6 +111,10 @@ type Options struct {
 	// See issue #697.
 	AllowURLLinkedFiles bool
 
+	// Placeholders when set makes a pull write a .gdoc, .gsheet etc
+	// placeholder file for each Google Doc that isn't exported.
+	Placeholders bool
+
 	// Chunksize is the size per block of data uploaded.
 	// If not set, the default value from googleapi.DefaultUploadChunkSize
 	// is used instead.
@@ -118,6 +123,134 @@ type Options struct {
 
 	// Limit the upload bandwidth to n KiB/s.
 	UploadRateLimit int
+
+	// Background when set keeps transfers from competing with
+	// foreground work: lowest CPU priority, one transfer at a
+	// time and paced local reads.
+	Background bool
+
+	// UploadRateSchedule when set varies the upload bandwidth limit
+	// by the time of day, falling back to UploadRateLimit outside of it.
+	UploadRateSchedule *RateSchedule
+
+	// Retention when set prunes old backup snapshots after each successful backup.
+	Retention *RetentionPolicy
+
+	// MaxAPICalls and MaxBytes when set cap the API calls made and the
+	// bytes transferred in this run, after which the run stops cleanly.
+	MaxAPICalls int64
+	MaxBytes    int64
+
+	// APIRate when set paces the API requests of the context to that
+	// many per second, shared by all of its runs within this process.
+	APIRate int
+
+	// Strict when set fails a push or pull that skipped any path e.g
+	// an unsupported file type or an unreadable directory.
+	Strict bool
+
+	// ByType when set makes du aggregate usage by file type and
+	// extension instead of listing each file.
+	ByType bool
+
+	// Top when set makes du report only the Top largest files, and
+	// Local also reports the largest files in the local tree.
+	Top   int
+	Local bool
+
+	// NoCache when set makes status recompute instead of reusing
+	// the status cached while nothing changed.
+	NoCache bool
+
+	// VirtualStarred when set makes a pull of all starred files
+	// gather them by name into the local StarredFolder.
+	VirtualStarred bool
+
+	// SplitSize when set pushes files larger than it in parts of at
+	// most SplitSize, which pull then reassembles.
+	SplitSize int64
+
+	// Sparse when set leaves blocks of zeros in downloads as holes.
+	Sparse bool
+
+	// PathFilter when set picks the paths to transfer by the rules
+	// of an rsync-style include file, see ReadIncludeFrom.
+	PathFilter *PathFilter
+
+	// IncludeOnly when set restricts push and pull to the paths that
+	// match its patterns. Unset, those of the context's .driveinclude
+	// are used if there is one.
+	IncludeOnly *IncludeOnly
+
+	// TransferOrder when set is the order e.g OrderSmallestFirst that
+	// changes of the same operation are played in.
+	TransferOrder string
+
+	// LargePullFiles and LargePullBytes when set are the number of files
+	// and bytes that a pull may download before requiring ConfirmLarge.
+	LargePullFiles int
+	LargePullBytes int64
+	ConfirmLarge   bool
+
+	// AllowSyncOverlap when set only warns of a context within the tree
+	// of another sync tool e.g Dropbox, and syncs such trees within it.
+	AllowSyncOverlap bool
+
+	// InitOverlap when set is how init binds to a remote folder that has
+	// files or that another context is bound to, one of CLIOptionMerge,
+	// CLIOptionAdopt and CLIOptionRequireEmpty. Unset, init asks.
+	InitOverlap string
+
+	// DryRun when set previews the changes a push would make without
+	// making them, relative to the snapshot Against if set else the remote.
+	DryRun  bool
+	Against string
+
+	// Snapshot when set records the local tree under that name
+	// after a successful push, for later use with Against.
+	Snapshot string
+
+	// Heartbeat when set logs a heartbeat line at that interval while
+	// transfers are active, and HeartbeatFile when set is touched on
+	// every beat, so that supervisors can tell a quiet transfer from a
+	// hung one. A systemd watchdog is kept alive regardless.
+	Heartbeat     time.Duration
+	HeartbeatFile string
+
+	// SnapshotToTemp when set copies each file before uploading it, so
+	// that files that keep changing are still uploaded consistently.
+	// Without it, files that change while being uploaded are requeued.
+	SnapshotToTemp bool
+
+	// LockedRetries is how many more times a local file that another
+	// process holds locked is opened before it is skipped, and
+	// ShadowCopy when set reads such files from a shadow copy instead.
+	LockedRetries int
+	ShadowCopy    bool
+
+	// Rollback when set undoes the permission changes that a share
+	// or unshare applied if the rest of its batch can't be applied.
+	Rollback bool
+
+	// BackupSuffix when set, or else Overwrite, lets a move onto an
+	// existing remote file go ahead: the existing file is renamed with
+	// BackupSuffix appended, or trashed if Overwrite is set.
+	BackupSuffix string
+	Overwrite    bool
+
+	// StallTimeout when set is how long a request, upload or download
+	// may make no progress for before it is cancelled and its change
+	// retried on a fresh connection.
+	StallTimeout time.Duration
+
+	// ItemizeChanges when set prints a line per applied change in
+	// the format of rsync's --itemize-changes, see itemize.
+	ItemizeChanges bool
+
+	// Shards are the remote folders whose files are fanned out into
+	// subfolders named by a hash of their names, see shardFor, while
+	// being listed, pushed and pulled as if those folders were flat.
+	Shards []string
 }
 
 func (opts *Options) CryptoEnabled() bool {
@@ -133,6 +266,37 @@ type Commands struct {
 
 	progress      *pb.ProgressBar
 	mkdirAllCache *expirableCache.OperationCache
+
+	// checksums is the lazily loaded local checksum cache.
+	checksums     map[string]*config.Checksum
+	checksumsOnce sync.Once
+
+	// skipped are the paths left out of the run, see skip.
+	skipped   []*skippedPath
+	skippedMu sync.Mutex
+
+	heartbeat *heartbeat
+	// shadows are the shadow copies that locked files are read from.
+	shadows *shadowCopies
+
+	// syncOverlapChecked is set once guardSyncOverlap has run.
+	syncOverlapChecked bool
+
+	// shardsSeen are the remote folders found to hold shard folders,
+	// which are sharded whether or not Options.Shards lists them.
+	shardsSeen   map[string]bool
+	shardsSeenMu sync.Mutex
+
+	// cryptPushed are the paths pushed encrypted under the current key,
+	// saved to the keyring once the push is done, see recordCryptKey.
+	cryptPushed   []string
+	cryptPushedMu sync.Mutex
+
+	// remotePaths are the paths that remote files were last seen at by
+	// id, kept by WatchPull so that a file moved away from a folder
+	// invalidates that folder too. Nil when not watching.
+	remotePaths   map[string][]string
+	remotePathsMu sync.Mutex
 }
 
 func (opts *Options) canPrompt() bool {
@@ -175,16 +339,21 @@ func rcPathChecker(absDir string) (string, error) {
 	return p, nil
 }
 
-func (opts *Options) rcPath() (string, error) {
+// rcPaths returns the .driverc files that apply to opts.Path, nearest first.
+// The search stops at the root of the drive context i.e the directory
+// holding the .gd directory, and falls back to the .driverc in the home
+// directory if none was found on the way.
+func (opts *Options) rcPaths() ([]string, error) {
+	var rcPaths []string
 	lastCurPath := ""
 	for curPath := opts.Path; curPath != ""; curPath = path.Dir(curPath) {
 		localRCP, err := rcPathChecker(curPath)
 		if err == nil && localRCP != "" {
-			return localRCP, nil
+			rcPaths = append(rcPaths, localRCP)
 		}
 
-		if false && err != nil && !os.IsNotExist(err) {
-			return "", err
+		if _, err := os.Stat(path.Join(curPath, config.GDDirSuffix)); err == nil {
+			break
 		}
 
 		if lastCurPath == curPath { // Avoid getting a stalemate incase path.Dir cannot progress
@@ -194,29 +363,46 @@ func (opts *Options) rcPath() (string, error) {
 		lastCurPath = curPath
 	}
 
-	return rcPathChecker(FsHomeDir)
-}
+	if len(rcPaths) >= 1 {
+		return rcPaths, nil
+	}
 
-func New(context *config.Context, opts *Options) *Commands {
-	var rem *Remote
-	var err error
+	homeRCP, err := rcPathChecker(FsHomeDir)
+	if err != nil {
+		return nil, err
+	}
+	return []string{homeRCP}, nil
+}
 
-	if context.GSAJWTConfig != nil {
-		rem, err = NewRemoteContextFromServiceAccount(context.GSAJWTConfig)
+func remoteForContext(context *config.Context) (rem *Remote, err error) {
+	if context.IsServiceAccount() {
+		rem, err = NewRemoteContextFromServiceAccount(impersonating(context.GSAJWTConfig, context.ImpersonatedSubject()))
+	} else if context.IsApplicationDefault() {
+		rem, err = NewRemoteContextFromDefaultCredentials()
 	} else {
 		rem, err = NewRemoteContext(context)
 	}
+	if err != nil {
+		return nil, err
+	}
 
+	rem.rootId = context.RemoteRootId
+	return rem, nil
+}
+
+func New(context *config.Context, opts *Options) *Commands {
+	rem, err := remoteForContext(context)
 	if err != nil {
 		panic(fmt.Errorf("failed to initialize remoteContext: %v", err))
 	}
+	rem.setQuotaUser(quotaUserFor(context))
 
 	stdin, stdout, stderr := os.Stdin, os.Stdout, os.Stderr
 
 	var logger *log.Logger = nil
 
 	if opts == nil {
-		logger = log.New(stdin, stdout, stderr)
+		logger = log.New(stdin, ASCIISafe(stdout), ASCIISafe(stderr))
 	} else {
 		if opts.Quiet {
 			stdout = nil
@@ -230,35 +416,83 @@ func New(context *config.Context, opts *Options) *Commands {
 			panic("piped requires stdout to be non-nil")
 		}
 
-		logger = log.New(stdin, stdout, stderr)
+		logger = log.New(stdin, ASCIISafe(stdout), ASCIISafe(stderr))
 
 		// should always start with /
 		opts.Path = path.Clean(path.Join("/", opts.Path))
 
 		if !opts.Force {
-			ignoresPath := filepath.Join(context.AbsPath, DriveIgnoreSuffix)
-			ignorer, regErr := combineIgnores(ignoresPath)
+			var profileClauses []string
+			profiles, rcErr := contextIgnoreProfiles(context.AbsPath)
+			if rcErr == nil {
+				profileClauses, rcErr = ignoreProfileClauses(profiles)
+			}
+			if rcErr != nil {
+				logger.LogErrf("ignore profiles: %v\n", rcErr)
+			}
+
+			ignorer, regErr := combineIgnores(context.AbsPath, profileClauses...)
 
 			if regErr != nil {
-				logger.LogErrf("combining ignores from path %s and internally: %v\n", ignoresPath, regErr)
+				logger.LogErrf("combining ignores from path %s and internally: %v\n", context.AbsPath, regErr)
 			}
 
 			opts.Ignorer = ignorer
 		}
 
+		if opts.IncludeOnly == nil {
+			includeOnly, incErr := readDriveInclude(context.AbsPath)
+			if incErr != nil {
+				logger.LogErrf("%s: %v\n", DriveIncludeSuffix, incErr)
+			}
+			opts.IncludeOnly = includeOnly
+		}
+
 		if opts.UploadChunkSize == 0 {
 			// UploadRateLimit is in KiB/s
 			opts.UploadChunkSize = opts.UploadRateLimit * 1024
 		}
 	}
 
-	return &Commands{
+	g := &Commands{
 		context:       context,
 		rem:           rem,
 		opts:          opts,
 		log:           logger,
 		mkdirAllCache: expirableCache.New(),
 	}
+
+	if context.Relocated() {
+		logger.LogErrf("this context moved from %s to %s, run `drive %s` to rebind it\n",
+			customQuote(context.LastKnownRoot), customQuote(context.AbsPath), RelocateKey)
+	}
+
+	if opts != nil && opts.Background {
+		g.enterBackground()
+	}
+
+	if opts != nil && opts.StallTimeout > 0 {
+		rem.setStallTimeout(opts.StallTimeout)
+	}
+
+	if opts != nil && opts.APIRate > 0 {
+		rem.setRateLimit(tokenBucketFor(context.AbsPath, opts.APIRate))
+	}
+
+	if opts != nil && (opts.MaxAPICalls > 0 || opts.MaxBytes > 0) {
+		rem.setBudget(&budget{maxCalls: opts.MaxAPICalls, maxBytes: opts.MaxBytes})
+	}
+
+	if err := context.EnsureCachePath(legacyCacheFiles...); err != nil {
+		logger.LogErrf("cache directory: %v\n", err)
+	}
+
+	// Cached responses are looked up first so that they don't count against the budget.
+	if mc := metaCacheFor(context); mc != nil {
+		rem.setMetaCache(mc)
+	}
+
+	return g
 }
 
 func (g *Commands) taskStart(tasks int64) {
@@ -277,6 +511,7 @@ func (g *Commands) taskAdd(n int64) {
 	if g.progress != nil {
 		g.progress.Add64(n)
 	}
+	g.heartbeat.add(n)
 }
 
 func (g *Commands) taskFinish() {
@@ -0,0 +1,210 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+)
+
+// splitConfigKey splits a key of the form [section.]name e.g "pull.export"
+// or "hidden", the latter being in the GlobalConfigSection, and checks
+// that the section is that of a command and that name is a known option.
+func splitConfigKey(key string) (section, name string, err error) {
+	section, name = GlobalConfigSection, strings.ToLower(strings.TrimSpace(key))
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		section, name = name[:i], name[i+1:]
+	}
+
+	if _, isCommand := docMap[section]; !isCommand && section != GlobalConfigSection {
+		return "", "", invalidArgumentsErr(fmt.Errorf("%q: unknown section %q, expecting %q or a command", key, section, GlobalConfigSection))
+	}
+	if _, known := rcKeyResolver(name); !known {
+		return "", "", invalidArgumentsErr(fmt.Errorf("%q: unknown option %q", key, name))
+	}
+	return section, name, nil
+}
+
+// ConfigGet returns the value that the option at key takes by default,
+// as set by the .driverc files of rcDir over the global configuration,
+// or by the global configuration alone if rcDir is empty.
+func ConfigGet(rcDir, key string) (value interface{}, set bool, err error) {
+	section, name, err := splitConfigKey(key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	globalMappings, err := GlobalConfigMappings(globalConfigPath())
+	if err != nil && !NotExist(err) {
+		return nil, false, err
+	}
+	values := mergeNamespaces(globalMappings, section)
+
+	if rcDir != "" {
+		rcMappings, err := ResourceMappings(rcDir)
+		if err != nil && !NotExist(err) {
+			return nil, false, err
+		}
+		copyAndOverWriteNs(mergeNamespaces(rcMappings, section), values)
+	}
+
+	value, set = values[name]
+	return value, set, nil
+}
+
+// ConfigSet validates value for the option at key and then records it in
+// the .driverc of rcDir, or in the global configuration if rcDir is empty.
+func ConfigSet(rcDir, key, value string) error {
+	section, name, err := splitConfigKey(key)
+	if err != nil {
+		return err
+	}
+
+	resolver, _ := rcKeyResolver(name)
+	typed, err := resolver(name, value)
+	if err != nil {
+		return invalidArgumentsErr(err)
+	}
+
+	if rcDir == "" {
+		return setGlobalConfigValue(globalConfigPath(), section, name, typed)
+	}
+
+	rcFile := rcPath(rcDir)
+	data, err := ioutil.ReadFile(rcFile)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	lines := setRCValue(strings.Split(string(data), "\n"), section, name, value)
+	return writeFileAtomically(rcFile, []byte(strings.Join(lines, "\n")), 0644)
+}
+
+func setGlobalConfigValue(configPath, section, name string, value interface{}) error {
+	sections := make(map[string]map[string]interface{})
+	data, err := ioutil.ReadFile(configPath)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, &sections); err != nil {
+			return fmt.Errorf("%s: %v", configPath, err)
+		}
+	case !os.IsNotExist(err):
+		return err
+	}
+
+	if sections[section] == nil {
+		sections[section] = make(map[string]interface{})
+	}
+	sections[section][name] = value
+
+	if data, err = json.MarshalIndent(sections, "", "  "); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(path.Dir(configPath), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomically(configPath, append(data, '\n'), 0644)
+}
+
+// writeFileAtomically writes then renames so that readers never see a partial file.
+func writeFileAtomically(p string, data []byte, perm os.FileMode) error {
+	tmpPath := p + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, data, perm); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, p)
+}
+
+// rcSectionHeader returns the sections that a .driverc line of the
+// form "[push/pull]" starts, reporting whether it is such a line.
+func rcSectionHeader(line string) ([]string, bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "[") || !strings.HasSuffix(line, "]") {
+		return nil, false
+	}
+
+	var sections []string
+	for _, section := range strings.Split(line[1:len(line)-1], "/") {
+		sections = append(sections, strings.ToLower(strings.TrimSpace(section)))
+	}
+	return sections, true
+}
+
+// setRCValue sets name to value in section of the .driverc lines. The last
+// value seen for a section is the one that counts, so an existing line is
+// only updated in place if it is the last one for section and isn't shared
+// with other sections, otherwise the line is added to section's last block
+// that comes after it, or to a new block at the end.
+func setRCValue(lines []string, section, name, value string) []string {
+	line := fmt.Sprintf("%s=%s", name, value)
+
+	// Lines before any header are in the global section.
+	applies, exclusive := section == GlobalConfigSection, section == GlobalConfigSection
+	lastSet, lastSetExclusive := -1, false
+	blockEnd := -1
+	if exclusive {
+		blockEnd = 0
+	}
+
+	for i, l := range lines {
+		if sections, isHeader := rcSectionHeader(l); isHeader {
+			applies, exclusive = false, len(sections) == 1 && sections[0] == section
+			for _, s := range sections {
+				applies = applies || s == section
+			}
+			if exclusive {
+				blockEnd = i + 1
+			}
+			continue
+		}
+
+		trimmed := strings.TrimSpace(l)
+		if !applies || trimmed == "" || strings.HasPrefix(trimmed, CommentStr) {
+			continue
+		}
+		if exclusive {
+			blockEnd = i + 1
+		}
+
+		key := strings.ToLower(strings.TrimSpace(strings.SplitN(trimmed, "=", 2)[0]))
+		if key == name {
+			lastSet, lastSetExclusive = i, exclusive
+		}
+	}
+
+	if lastSet >= 0 && lastSetExclusive {
+		lines[lastSet] = line
+		return lines
+	}
+
+	if blockEnd > lastSet && blockEnd >= 0 {
+		updated := append([]string{}, lines[:blockEnd]...)
+		updated = append(updated, line)
+		return append(updated, lines[blockEnd:]...)
+	}
+
+	// Drop the trailing blank lines before adding a block of our own.
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) > 0 {
+		lines = append(lines, "")
+	}
+	return append(lines, fmt.Sprintf("[%s]", section), line, "")
+}
@@ -0,0 +1,168 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSplitConfigKey(t *testing.T) {
+	tests := [...]struct {
+		key     string
+		section string
+		name    string
+		wantErr bool
+	}{
+		0: {key: "hidden", section: GlobalConfigSection, name: HiddenKey},
+		1: {key: "pull.export", section: PullKey, name: ExportsKey},
+		2: {key: "Push.No-Clobber", section: PushKey, name: NoClobberKey},
+		3: {key: "global.depth", section: GlobalConfigSection, name: DepthKey},
+		4: {key: "pull.no-such-option", wantErr: true},
+		5: {key: "no-such-command.hidden", wantErr: true},
+		6: {key: "", wantErr: true},
+	}
+
+	for i, tt := range tests {
+		section, name, err := splitConfigKey(tt.key)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d: err=nil", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: err=%v", i, err)
+			continue
+		}
+		if section != tt.section || name != tt.name {
+			t.Errorf("#%d: have (%q, %q) want (%q, %q)", i, section, name, tt.section, tt.name)
+		}
+	}
+}
+
+func TestSetRCValue(t *testing.T) {
+	tests := [...]struct {
+		rc      string
+		section string
+		want    string
+	}{
+		// A new file.
+		0: {rc: "", section: "global", want: "depth=3\n"},
+		1: {rc: "", section: "pull", want: "[pull]\ndepth=3\n"},
+
+		// Updated in place.
+		2: {rc: "depth=1\n[pull]\ndepth=2\n", section: "global", want: "depth=3\n[pull]\ndepth=2\n"},
+		3: {rc: "depth=1\n[pull]\ndepth=2\n", section: "pull", want: "depth=1\n[pull]\ndepth=3\n"},
+
+		// Added to the existing block of the section.
+		4: {
+			rc:      "# comment\nhidden=true\n\n[pull]\nforce=true\n\n[push]\nforce=true\n",
+			section: "pull",
+			want:    "# comment\nhidden=true\n\n[pull]\nforce=true\ndepth=3\n\n[push]\nforce=true\n",
+		},
+		5: {
+			rc:      "hidden=true\n\n[pull]\nforce=true\n",
+			section: "global",
+			want:    "hidden=true\ndepth=3\n\n[pull]\nforce=true\n",
+		},
+
+		// A later block shared with other sections would override an update
+		// of the section's own block, so a new block goes after it.
+		6: {
+			rc:      "[pull]\ndepth=2\n\n[push/pull]\ndepth=1\n",
+			section: "pull",
+			want:    "[pull]\ndepth=2\n\n[push/pull]\ndepth=1\n\n[pull]\ndepth=3\n",
+		},
+		7: {
+			rc:      "[push/pull]\ndepth=1\n\n[pull]\nforce=true\n",
+			section: "pull",
+			want:    "[push/pull]\ndepth=1\n\n[pull]\nforce=true\ndepth=3\n",
+		},
+	}
+
+	for i, tt := range tests {
+		got := strings.Join(setRCValue(strings.Split(tt.rc, "\n"), tt.section, DepthKey, "3"), "\n")
+		if got != tt.want {
+			t.Errorf("#%d:\nhave %q\nwant %q", i, got, tt.want)
+		}
+	}
+}
+
+func TestConfigGetSet(t *testing.T) {
+	dir, err := ioutil.TempDir("", "drive-config-keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	prevConfigHome := os.Getenv(XDGConfigHomeEnvKey)
+	os.Setenv(XDGConfigHomeEnvKey, filepath.Join(dir, "config"))
+	defer os.Setenv(XDGConfigHomeEnvKey, prevConfigHome)
+
+	contextDir := filepath.Join(dir, "context")
+	if err := os.MkdirAll(filepath.Join(contextDir, ".gd"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, set, err := ConfigGet(contextDir, "pull.depth"); err != nil || set {
+		t.Fatalf("unset: have set=%v err=%v", set, err)
+	}
+
+	if err := ConfigSet(contextDir, "depth", "not a number"); err == nil {
+		t.Errorf("depth=\"not a number\": err=nil")
+	}
+	if err := ConfigSet("", "no-such-option", "1"); err == nil {
+		t.Errorf("no-such-option: err=nil")
+	}
+
+	// The .driverc of the context takes precedence over the global config.json.
+	steps := []struct {
+		rcDir, key, value string
+	}{
+		{"", "depth", "5"},
+		{"", "pull.hidden", "true"},
+		{contextDir, "pull.depth", "2"},
+	}
+	for _, step := range steps {
+		if err := ConfigSet(step.rcDir, step.key, step.value); err != nil {
+			t.Fatalf("set %s=%s: %v", step.key, step.value, err)
+		}
+	}
+
+	gets := []struct {
+		rcDir, key string
+		want       interface{}
+	}{
+		{"", "pull.depth", 5},
+		{"", "pull.hidden", true},
+		{contextDir, "pull.depth", 2},
+		{contextDir, "pull.hidden", true},
+		{contextDir, "push.depth", 5},
+	}
+	for i, get := range gets {
+		value, set, err := ConfigGet(get.rcDir, get.key)
+		if err != nil || !set {
+			t.Errorf("#%d: %s: set=%v err=%v", i, get.key, set, err)
+			continue
+		}
+		if value != get.want {
+			t.Errorf("#%d: %s: have %v want %v", i, get.key, value, get.want)
+		}
+	}
+}
@@ -0,0 +1,445 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/odeke-em/drive/config"
+	"github.com/odeke-em/namespace"
+)
+
+// ConfigProblem is a problem found in one of the files of a context.
+type ConfigProblem struct {
+	Path    string
+	Problem string
+	// Repairable problems can be fixed without losing anything that
+	// can't be recovered, e.g by dropping unknown keys or cached entries.
+	Repairable bool
+	Repaired   bool
+}
+
+func (cp *ConfigProblem) String() string {
+	status := ""
+	switch {
+	case cp.Repaired:
+		status = " (repaired)"
+	case cp.Repairable:
+		status = " (repairable with -" + CLIOptionRepair + ")"
+	}
+	return fmt.Sprintf("%s: %s%s", cp.Path, cp.Problem, status)
+}
+
+// ValidateConfig checks the credentials, settings and index of context,
+// which needn't have been read, for damaged or truncated JSON, unknown
+// keys and invalid values. With repair, the repairable problems are fixed.
+func ValidateConfig(context *config.Context, repair bool) []*ConfigProblem {
+	var problems []*ConfigProblem
+	problems = append(problems, validateCredentials(context, repair)...)
+	problems = append(problems, validateSettings(context)...)
+	problems = append(problems, validateIndices(context, repair)...)
+	return problems
+}
+
+func validateCredentials(context *config.Context, repair bool) []*ConfigProblem {
+	credentialsPath := filepath.Join(context.GDPath(), config.CredentialsJSON)
+	data, err := context.CredentialsData()
+	if err != nil {
+		return []*ConfigProblem{{
+			Path:    credentialsPath,
+			Problem: fmt.Sprintf("%v, `drive %s` recreates the credentials", err, InitKey),
+		}}
+	}
+
+	problems := credentialsProblems(credentialsPath, data)
+	if !repair || len(problems) < 1 {
+		return problems
+	}
+	for _, problem := range problems {
+		if !problem.Repairable {
+			return problems
+		}
+	}
+
+	// Reading then writing the credentials back drops the unknown keys.
+	if err := context.Read(); err == nil {
+		err = context.Write()
+	}
+	if err != nil {
+		return append(problems, &ConfigProblem{Path: credentialsPath, Problem: fmt.Sprintf("repairing: %v", err)})
+	}
+	for _, problem := range problems {
+		problem.Repaired = true
+	}
+	return problems
+}
+
+// credentialsProblems returns the problems of data, the JSON of the
+// credentials file at credentialsPath.
+func credentialsProblems(credentialsPath string, data []byte) []*ConfigProblem {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return []*ConfigProblem{jsonProblem(credentialsPath, err)}
+	}
+
+	var problems []*ConfigProblem
+	problem := func(repairable bool, format string, args ...interface{}) {
+		problems = append(problems, &ConfigProblem{
+			Path:       credentialsPath,
+			Problem:    fmt.Sprintf(format, args...),
+			Repairable: repairable,
+		})
+	}
+
+	contextKeys := jsonKeys(reflect.TypeOf(config.Context{}))
+	for _, key := range sortedRawKeys(raw) {
+		if !contextKeys[key] {
+			problem(true, "unknown key %q", key)
+		}
+	}
+
+	var rawAccounts map[string]map[string]json.RawMessage
+	if accounts, ok := raw["accounts"]; ok && json.Unmarshal(accounts, &rawAccounts) == nil {
+		credentialsKeys := jsonKeys(reflect.TypeOf(config.Credentials{}))
+		names := make([]string, 0, len(rawAccounts))
+		for name := range rawAccounts {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			for _, key := range sortedRawKeys(rawAccounts[name]) {
+				if !credentialsKeys[key] {
+					problem(true, "account %q: unknown key %q", name, key)
+				}
+			}
+		}
+	}
+
+	var context config.Context
+	if err := json.Unmarshal(data, &context); err != nil {
+		if tErr, ok := err.(*json.UnmarshalTypeError); ok {
+			problem(false, "%s: expecting a %v, got %s", tErr.Field, tErr.Type, tErr.Value)
+		} else {
+			problem(false, "%v", err)
+		}
+		return problems
+	}
+
+	switch context.CredentialStore {
+	case "", config.CredentialStoreFile, config.CredentialStoreKeychain:
+	default:
+		problem(false, "unknown credential_store %q", context.CredentialStore)
+	}
+
+	inKeychain := context.CredentialStore == config.CredentialStoreKeychain
+	for _, p := range credentialsSchemaProblems(&context.Credentials, inKeychain) {
+		problem(false, "%s", p)
+	}
+	names := make([]string, 0, len(context.Accounts))
+	for name := range context.Accounts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		creds := context.Accounts[name]
+		if creds == nil {
+			continue
+		}
+		for _, p := range credentialsSchemaProblems(creds, inKeychain) {
+			problem(false, "account %q: %s", name, p)
+		}
+	}
+	for _, name := range context.RemoteNames() {
+		remote := context.Remotes[name]
+		if remote != nil && context.Accounts[remote.Account] == nil {
+			problem(false, "remote %q: no account %q", name, remote.Account)
+		}
+	}
+	return problems
+}
+
+// credentialsSchemaProblems describes what creds lack for their type.
+func credentialsSchemaProblems(creds *config.Credentials, inKeychain bool) (problems []string) {
+	switch creds.CredentialType {
+	case "", config.CredentialTypeOAuth2:
+		// Credentials without a type predate it, and hold a service
+		// account's config if they are those of a service account.
+		if creds.CredentialType == "" && creds.GSAJWTConfig != nil {
+			return nil
+		}
+		if creds.ClientId == "" {
+			problems = append(problems, "client_id is missing")
+		}
+		if creds.ClientSecret == "" {
+			problems = append(problems, "client_secret is missing")
+		}
+		// Tokens kept in the keychain are blanked out of the file.
+		if creds.RefreshToken == "" && !inKeychain {
+			problems = append(problems, "refresh_token is missing")
+		}
+	case config.CredentialTypeServiceAccount:
+		if creds.GSAJWTConfig == nil {
+			problems = append(problems, "gsa_jwt_config is missing")
+		}
+	case config.CredentialTypeApplicationDefault:
+	default:
+		problems = append(problems, fmt.Sprintf("unknown credential_type %q", creds.CredentialType))
+	}
+	return problems
+}
+
+// jsonProblem describes err, an error unmarshaling the file at p.
+func jsonProblem(p string, err error) *ConfigProblem {
+	problem := &ConfigProblem{Path: p}
+	switch jErr := err.(type) {
+	case *json.SyntaxError:
+		if strings.Contains(jErr.Error(), "unexpected end of JSON input") {
+			problem.Problem = fmt.Sprintf("truncated JSON, it ends after %d bytes", jErr.Offset)
+		} else {
+			problem.Problem = fmt.Sprintf("invalid JSON at byte %d: %v", jErr.Offset, jErr)
+		}
+	case *json.UnmarshalTypeError:
+		problem.Problem = fmt.Sprintf("expecting a JSON object, got %s", jErr.Value)
+	default:
+		problem.Problem = err.Error()
+	}
+	return problem
+}
+
+// jsonKeys returns the names of the fields of struct type t in JSON,
+// including those of its embedded structs.
+func jsonKeys(t reflect.Type) map[string]bool {
+	keys := make(map[string]bool)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := strings.Split(field.Tag.Get("json"), ",")[0]
+		switch {
+		case tag == "-":
+		case field.Anonymous && tag == "" && field.Type.Kind() == reflect.Struct:
+			for key := range jsonKeys(field.Type) {
+				keys[key] = true
+			}
+		case field.PkgPath != "":
+			// Unexported fields aren't marshaled.
+		case tag != "":
+			keys[tag] = true
+		default:
+			keys[field.Name] = true
+		}
+	}
+	return keys
+}
+
+func sortedRawKeys(raw map[string]json.RawMessage) []string {
+	keys := make([]string, 0, len(raw))
+	for key := range raw {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// validateSettings checks the .driverc files that apply to the root of
+// context and the global config.json. They are written by hand, so
+// their problems are left to be fixed by hand too.
+func validateSettings(context *config.Context) (problems []*ConfigProblem) {
+	if context.AbsPath != "" {
+		opts := Options{Path: context.AbsPath}
+		rcPaths, err := opts.rcPaths()
+		if err != nil && !os.IsNotExist(err) {
+			problems = append(problems, &ConfigProblem{Path: context.AbsPath, Problem: err.Error()})
+		}
+		for _, rcPath := range rcPaths {
+			nsMap, err := kvifyCommentedFile(rcPath, CommentStr)
+			if err != nil {
+				problems = append(problems, &ConfigProblem{Path: rcPath, Problem: err.Error()})
+				continue
+			}
+			problems = append(problems, settingsProblems(rcPath, nsMap)...)
+		}
+	}
+
+	configPath := globalConfigPath()
+	data, err := ioutil.ReadFile(configPath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			problems = append(problems, &ConfigProblem{Path: configPath, Problem: err.Error()})
+		}
+		return problems
+	}
+
+	var sections map[string]map[string]interface{}
+	if err := json.Unmarshal(data, &sections); err != nil {
+		return append(problems, jsonProblem(configPath, err))
+	}
+
+	nsMap := make(map[string]map[string]string)
+	for section, values := range sections {
+		kvMap := make(map[string]string)
+		for key, value := range values {
+			str, err := stringifyConfigValue(value)
+			if err != nil {
+				problems = append(problems, &ConfigProblem{Path: configPath, Problem: fmt.Sprintf("%s.%s: %v", section, key, err)})
+				continue
+			}
+			kvMap[key] = str
+		}
+		nsMap[section] = kvMap
+	}
+	return append(problems, settingsProblems(configPath, nsMap)...)
+}
+
+// settingsProblems returns the unknown sections and options, and the
+// invalid values, of the settings of the file at p by section.
+func settingsProblems(p string, nsMap map[string]map[string]string) (problems []*ConfigProblem) {
+	problem := func(format string, args ...interface{}) {
+		problems = append(problems, &ConfigProblem{Path: p, Problem: fmt.Sprintf(format, args...)})
+	}
+
+	sections := make([]string, 0, len(nsMap))
+	for section := range nsMap {
+		sections = append(sections, section)
+	}
+	sort.Strings(sections)
+
+	for _, section := range sections {
+		for _, name := range strings.Split(section, "/") {
+			name = strings.TrimSpace(name)
+			if _, isCommand := docMap[name]; !isCommand && name != GlobalConfigSection && name != namespace.GlobalNamespaceKey {
+				problem("unknown section %q, expecting %q or a command", name, GlobalConfigSection)
+			}
+		}
+
+		values := nsMap[section]
+		keys := make([]string, 0, len(values))
+		for key := range values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			resolver, known := rcKeyResolver(key)
+			if !known {
+				problem("%s: unknown option %q", sectionName(section), key)
+				continue
+			}
+			if _, err := resolver(strings.ToLower(key), values[key]); err != nil {
+				problem("%s: %s: %v", sectionName(section), key, err)
+			}
+		}
+	}
+	return problems
+}
+
+func sectionName(section string) string {
+	if section == namespace.GlobalNamespaceKey {
+		return GlobalConfigSection
+	}
+	return section
+}
+
+// validateIndices checks the entries of the index and of the checksum
+// cache. Both can be rebuilt, so damaged entries are repaired by
+// dropping them and a database that can't be opened by moving it aside.
+func validateIndices(context *config.Context, repair bool) (problems []*ConfigProblem) {
+	dbPath := context.CacheFilePath(config.DriveDb)
+	if _, err := os.Stat(dbPath); err != nil {
+		// No index has been made yet.
+		return nil
+	}
+
+	checks := []struct {
+		bucket string
+		check  func(key, value []byte) error
+	}{
+		{
+			bucket: config.IndicesKey,
+			check: func(key, value []byte) error {
+				var index config.Index
+				if err := json.Unmarshal(value, &index); err != nil {
+					return err
+				}
+				if index.FileId != string(key) {
+					return fmt.Errorf("holds the index of %q", index.FileId)
+				}
+				return nil
+			},
+		},
+		{
+			bucket: config.ChecksumsKey,
+			check: func(key, value []byte) error {
+				var checksum config.Checksum
+				return json.Unmarshal(value, &checksum)
+			},
+		},
+	}
+
+	for _, c := range checks {
+		var damaged []string
+		var bucketProblems []*ConfigProblem
+		err := context.ScanDbBucket(c.bucket, func(key, value []byte) error {
+			if checkErr := c.check(key, value); checkErr != nil {
+				damaged = append(damaged, string(key))
+				bucketProblems = append(bucketProblems, &ConfigProblem{
+					Path:       dbPath,
+					Problem:    fmt.Sprintf("%s entry %q: %v", c.bucket, key, checkErr),
+					Repairable: true,
+				})
+			}
+			return nil
+		})
+
+		if err != nil {
+			return append(problems, repairDb(dbPath, err, repair))
+		}
+
+		if repair && len(damaged) >= 1 {
+			if err := context.DeleteDbKeys(c.bucket, damaged...); err != nil {
+				bucketProblems = append(bucketProblems, &ConfigProblem{Path: dbPath, Problem: fmt.Sprintf("repairing: %v", err)})
+			} else {
+				for _, problem := range bucketProblems {
+					problem.Repaired = true
+				}
+			}
+		}
+		problems = append(problems, bucketProblems...)
+	}
+	return problems
+}
+
+// repairDb describes openErr, the error opening the database at dbPath,
+// moving the database aside for it to be rebuilt if repair is set.
+func repairDb(dbPath string, openErr error, repair bool) *ConfigProblem {
+	problem := &ConfigProblem{
+		Path:       dbPath,
+		Problem:    fmt.Sprintf("can't be read: %v, `drive %s` rebuilds it", openErr, IndexKey),
+		Repairable: true,
+	}
+	if repair {
+		if err := os.Rename(dbPath, dbPath+".damaged"); err != nil {
+			problem.Problem = fmt.Sprintf("%s, moving it aside: %v", problem.Problem, err)
+		} else {
+			problem.Repaired = true
+		}
+	}
+	return problem
+}
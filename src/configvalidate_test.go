@@ -0,0 +1,192 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/odeke-em/drive/config"
+	"github.com/odeke-em/namespace"
+)
+
+func problemStrings(problems []*ConfigProblem) []string {
+	var strs []string
+	for _, problem := range problems {
+		strs = append(strs, problem.String())
+	}
+	return strs
+}
+
+func TestCredentialsProblems(t *testing.T) {
+	tests := []struct {
+		data string
+		want []string
+	}{
+		{
+			data: `{"client_id":"id","client_secret":"secret","refresh_token":"token"}`,
+		},
+		{
+			data: `{"client_id":"id","client_secret":"secret","refresh_to`,
+			want: []string{"creds: truncated JSON, it ends after 54 bytes"},
+		},
+		{
+			data: `{"client_id":"id",}`,
+			want: []string{"creds: invalid JSON at byte 19: invalid character '}' looking for beginning of object key string"},
+		},
+		{
+			data: `["client_id"]`,
+			want: []string{"creds: expecting a JSON object, got array"},
+		},
+		{
+			data: `{"client_id":"id","client_secret":"secret","refresh_token":"token","colour":"red",` +
+				`"accounts":{"work":{"client_id":"id","client_secret":"s","refresh_token":"t","extra":1}}}`,
+			want: []string{
+				`creds: unknown key "colour" (repairable with -repair)`,
+				`creds: account "work": unknown key "extra" (repairable with -repair)`,
+			},
+		},
+		{
+			data: `{"client_id":"id","refresh_token":""}`,
+			want: []string{
+				"creds: client_secret is missing",
+				"creds: refresh_token is missing",
+			},
+		},
+		{
+			// Tokens kept in the keychain are blanked out of the file.
+			data: `{"client_id":"id","client_secret":"secret","credential_store":"keychain","keychain_id":"k"}`,
+		},
+		{
+			data: `{"credential_type":"service_account"}`,
+			want: []string{"creds: gsa_jwt_config is missing"},
+		},
+		{
+			data: `{"credential_type":"magic","credential_store":"vault"}`,
+			want: []string{
+				`creds: unknown credential_store "vault"`,
+				`creds: unknown credential_type "magic"`,
+			},
+		},
+		{
+			data: `{"client_id":"id","client_secret":"secret","refresh_token":"token",` +
+				`"accounts":{"work":{"client_id":"id","client_secret":"s","refresh_token":"t"}},` +
+				`"remotes":{"backup":{"account":"gone"},"office":{"account":"work","root_id":"r"}}}`,
+			want: []string{`creds: remote "backup": no account "gone"`},
+		},
+		{
+			data: `{"client_id":1}`,
+			want: []string{"creds: client_id: expecting a string, got number"},
+		},
+	}
+
+	for i, tt := range tests {
+		got := problemStrings(credentialsProblems("creds", []byte(tt.data)))
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("#%d: got %q want %q", i, got, tt.want)
+		}
+	}
+}
+
+func TestSettingsProblems(t *testing.T) {
+	nsMap := map[string]map[string]string{
+		namespace.GlobalNamespaceKey: {"hidden": "true", "colour": "red"},
+		"pull/bogus":                 {"depth": "two"},
+		"push":                       {"no-prompt": "true"},
+	}
+
+	got := problemStrings(settingsProblems(".driverc", nsMap))
+	want := []string{
+		`.driverc: global: unknown option "colour"`,
+		`.driverc: unknown section "bogus", expecting "global" or a command`,
+	}
+	if len(got) != 3 || !reflect.DeepEqual(got[:2], want) {
+		t.Fatalf("got %q want %q and an invalid depth", got, want)
+	}
+	if !strings.HasPrefix(got[2], ".driverc: pull/bogus: depth: ") {
+		t.Errorf("got %q want an invalid depth", got[2])
+	}
+}
+
+func TestValidateIndices(t *testing.T) {
+	dir, err := ioutil.TempDir("", "configvalidate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	prevCacheHome := os.Getenv("XDG_CACHE_HOME")
+	os.Setenv("XDG_CACHE_HOME", filepath.Join(dir, "cache"))
+	defer os.Setenv("XDG_CACHE_HOME", prevCacheHome)
+
+	context := &config.Context{GDDir: filepath.Join(dir, "gd")}
+	if got := validateIndices(context, false); len(got) != 0 {
+		t.Fatalf("without an index: got %q", problemStrings(got))
+	}
+
+	if err := context.SerializeIndex(&config.Index{FileId: "good"}); err != nil {
+		t.Fatal(err)
+	}
+	db, closeDB, err := context.OpenDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		indices := tx.Bucket([]byte(config.IndicesKey))
+		if err := indices.Put([]byte("truncated"), []byte(`{"id":"trunc`)); err != nil {
+			return err
+		}
+		if err := indices.Put([]byte("misfiled"), []byte(`{"id":"other"}`)); err != nil {
+			return err
+		}
+		checksums, err := tx.CreateBucketIfNotExists([]byte(config.ChecksumsKey))
+		if err != nil {
+			return err
+		}
+		return checksums.Put([]byte("a.txt"), []byte(`{"md5":`))
+	})
+	closeDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	problems := validateIndices(context, false)
+	if len(problems) != 3 {
+		t.Fatalf("got %q want 3 problems", problemStrings(problems))
+	}
+	for _, problem := range problems {
+		if !problem.Repairable || problem.Repaired {
+			t.Errorf("%v: expected to be repairable but not repaired", problem)
+		}
+	}
+
+	for _, problem := range validateIndices(context, true) {
+		if !problem.Repaired {
+			t.Errorf("%v: expected to be repaired", problem)
+		}
+	}
+
+	if got := validateIndices(context, false); len(got) != 0 {
+		t.Errorf("after repairing: got %q", problemStrings(got))
+	}
+	if _, err := context.DeserializeIndex("good"); err != nil {
+		t.Errorf("the intact index was lost: %v", err)
+	}
+}
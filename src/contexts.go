@@ -0,0 +1,152 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/odeke-em/drive/config"
+	"github.com/odeke-em/log"
+)
+
+// registerContext records the context's account and remote root in the
+// registry. Unless create is set, this is a no-op for users that haven't
+// opted into the registry. Failures are only logged since the registry
+// is a convenience that shouldn't get in the way of the actual command.
+func (g *Commands) registerContext(create bool) {
+	// The registry describes the context by its default remote.
+	if g.context.Remote != "" {
+		return
+	}
+	// Users who never opted into the registry are spared the lookup.
+	if !create && !config.RegistryExists() {
+		return
+	}
+
+	// The remote that g was created with may predate the
+	// credentials that were just written e.g during init.
+	rem, err := remoteForContext(g.context)
+	if err != nil {
+		g.log.LogErrf("contexts: %v\n", err)
+		return
+	}
+
+	about, aboutErr := rem.About()
+	if aboutErr != nil {
+		g.log.LogErrf("contexts: looking up the account: %v\n", aboutErr)
+	}
+
+	err = config.UpdateRegistry(g.context.AbsPath, create, func(entry *config.RegistryEntry) {
+		entry.RemoteRoot, entry.RemoteRootId = configuredRemoteRoot(g.context)
+		if about != nil && about.User != nil {
+			entry.Account = about.User.EmailAddress
+		}
+	})
+	if err != nil {
+		g.log.LogErrf("contexts: %v\n", err)
+	}
+}
+
+// configuredRemoteRoot returns the remote root of the context as it was
+// configured, the path of its folder or, if it was bound by id, the id.
+func configuredRemoteRoot(context *config.Context) (p, id string) {
+	if context.RemoteRootPath != "" {
+		return path.Clean(RemoteSeparator + context.RemoteRootPath), ""
+	}
+	if context.RemoteRootId != "" {
+		return "", context.RemoteRootId
+	}
+	return RemoteSeparator, ""
+}
+
+// markSynced stamps the context's last sync time in the registry.
+func (g *Commands) markSynced() {
+	err := config.UpdateRegistry(g.context.AbsPath, false, func(entry *config.RegistryEntry) {
+		entry.LastSync = time.Now()
+	})
+	if err != nil {
+		g.log.LogErrf("contexts: %v\n", err)
+	}
+}
+
+// Contexts adds the current context to the registry, creating the
+// registry if need be, and then lists every registered context.
+func (g *Commands) Contexts(track bool) error {
+	if track {
+		g.registerContext(true)
+	}
+	return listContexts(g.log)
+}
+
+// ListContexts lists every registered context. Unlike
+// the other commands, it needn't be run within a context.
+func ListContexts() error {
+	return listContexts(log.New(os.Stdin, ASCIISafe(os.Stdout), ASCIISafe(os.Stderr)))
+}
+
+func listContexts(logy *log.Logger) error {
+	entries, err := config.ReadRegistry()
+	if err != nil {
+		return err
+	}
+	if len(entries) < 1 {
+		logy.Logf("no contexts registered in %s, use `drive %s -%s` in a context to add it\n",
+			customQuote(config.RegistryPath()), ContextsKey, CLIOptionTrack)
+		return nil
+	}
+
+	logy.Logf("%-40s %-30s %-30s %s\n", "Path", "Account", "Remote root", "Last sync")
+	for _, entry := range entries {
+		lastSync := "never"
+		if !entry.LastSync.IsZero() {
+			lastSync = entry.LastSync.Local().Format(time.RFC1123)
+		}
+		remoteRoot := entry.RemoteRoot
+		if remoteRoot == "" {
+			remoteRoot = entry.RemoteRootId
+		}
+		logy.Logf("%-40s %-30s %-30s %s\n", entry.Path, orDash(entry.Account), orDash(remoteRoot), lastSync)
+	}
+
+	return nil
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// isNestedContext reports whether relToRoot, within the context, is
+// the root of another context that was initialized in it. Nested
+// contexts are synced on their own, so the enclosing context leaves
+// them alone unless they were explicitly asked for.
+func (g *Commands) isNestedContext(relToRoot string) bool {
+	absPath := g.context.AbsPathOf(relToRoot)
+	if absPath == g.context.AbsPath {
+		return false
+	}
+	for _, source := range g.opts.Sources {
+		if absPath == g.context.AbsPathOf(source) {
+			return false
+		}
+	}
+	info, err := os.Stat(filepath.Join(absPath, config.GDDirSuffix))
+	return err == nil && info.IsDir()
+}
@@ -0,0 +1,58 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/odeke-em/drive/config"
+)
+
+func TestIsNestedContext(t *testing.T) {
+	root, err := ioutil.TempDir("", "nested")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	for _, dir := range []string{config.GDDirSuffix, "inner/" + config.GDDirSuffix, "plain"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testCases := []struct {
+		relToRoot string
+		sources   []string
+		want      bool
+	}{
+		{relToRoot: "/", want: false},
+		{relToRoot: "/inner", want: true},
+		{relToRoot: "/plain", want: false},
+		{relToRoot: "/missing", want: false},
+		{relToRoot: "/inner", sources: []string{"/inner"}, want: false},
+		{relToRoot: "/inner", sources: []string{"/plain"}, want: true},
+	}
+
+	for _, tc := range testCases {
+		g := &Commands{context: &config.Context{AbsPath: root}, opts: &Options{Sources: tc.sources}}
+		if got := g.isNestedContext(tc.relToRoot); got != tc.want {
+			t.Errorf("%q with sources %q: got %v want %v", tc.relToRoot, tc.sources, got, tc.want)
+		}
+	}
+}
@@ -69,7 +69,7 @@ func (g *Commands) Copy(byId bool) error {
 	for _, srcPath := range sources {
 		srcFile, srcErr := srcResolver(srcPath)
 		if srcErr != nil {
-			g.log.LogErrf("%s: %v\n", srcPath, srcErr)
+			g.log.LogErrln(pathErr("copy", OpNone, srcPath, "", srcErr))
 			continue
 		}
 
@@ -78,7 +78,7 @@ func (g *Commands) Copy(byId bool) error {
 		go func(fromPath, toPath string, fromFile *File) {
 			_, copyErr := g.copy(fromFile, toPath)
 			if copyErr != nil {
-				g.log.LogErrf("%s: %v\n", fromPath, copyErr)
+				g.log.LogErrln(pathErr("copy", OpNone, fromPath, fromFile.Id, copyErr))
 			}
 			done <- true
 		}(srcPath, dest, srcFile)
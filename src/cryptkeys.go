@@ -0,0 +1,497 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/odeke-em/drive/config"
+	"github.com/odeke-em/drive/src/dcrypto"
+
+	drive "google.golang.org/api/drive/v2"
+)
+
+const (
+	// CryptKeyringJSON holds the content keys of a context, each wrapped
+	// i.e encrypted under the passphrase, along with the key version that
+	// each pushed file was encrypted under.
+	CryptKeyringJSON = "crypt-keyring.json"
+
+	// cryptKeyringRemoteName is the copy of the keyring kept at the remote
+	// root, so that the keys aren't lost along with the .gd directory.
+	// The keys in it are only ever wrapped under the passphrase.
+	cryptKeyringRemoteName = ".drive-crypt-keyring.json"
+
+	// cryptKeySize is the size of the random content keys.
+	cryptKeySize = 32
+)
+
+// cryptKeyringMu serializes the updates to the keyring by concurrent uploads.
+var cryptKeyringMu sync.Mutex
+
+type wrappedCryptKey struct {
+	Version   int       `json:"version"`
+	Wrapped   []byte    `json:"wrapped"`
+	CreatedAt time.Time `json:"created_at"`
+	// Legacy is set for the passphrase that content was encrypted under
+	// directly before the keyring existed, wrapped as key version 0 so
+	// that such content can still be decrypted once it is changed.
+	Legacy bool `json:"legacy,omitempty"`
+}
+
+// cryptKeyring implements envelope encryption: files are encrypted with
+// content keys and only those are encrypted under the passphrase, so
+// changing the passphrase or adding a new key only rewraps the content
+// keys and never needs the files to be uploaded again.
+type cryptKeyring struct {
+	Keys []*wrappedCryptKey `json:"keys"`
+
+	// Files maps the paths of pushed files to the key version they were encrypted under.
+	Files map[string]int `json:"files,omitempty"`
+
+	path string
+}
+
+func cryptKeyringPath(gdPath string) string {
+	return filepath.Join(gdPath, CryptKeyringJSON)
+}
+
+// loadCryptKeyring reads the keyring of the context at gdPath, which
+// is nil if the context doesn't have one.
+func loadCryptKeyring(gdPath string) (*cryptKeyring, error) {
+	p := cryptKeyringPath(gdPath)
+	data, err := ioutil.ReadFile(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return nil, err
+	}
+
+	kr := &cryptKeyring{path: p}
+	if err := json.Unmarshal(data, kr); err != nil {
+		return nil, fmt.Errorf("%s: %v", p, err)
+	}
+	kr.path = p
+	return kr, nil
+}
+
+func (kr *cryptKeyring) save() error {
+	data, err := json.MarshalIndent(kr, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmpPath := kr.path + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, kr.path)
+}
+
+// current returns the newest key, the one that files are encrypted under.
+func (kr *cryptKeyring) current() *wrappedCryptKey {
+	var latest *wrappedCryptKey
+	for _, key := range kr.Keys {
+		if key.Legacy {
+			continue
+		}
+		if latest == nil || key.Version > latest.Version {
+			latest = key
+		}
+	}
+	return latest
+}
+
+// legacy returns the key wrapping the passphrase from before the keyring, if any.
+func (kr *cryptKeyring) legacy() *wrappedCryptKey {
+	for _, key := range kr.Keys {
+		if key.Legacy {
+			return key
+		}
+	}
+	return nil
+}
+
+// unwrap decrypts the content keys with the passphrase, by version.
+func (kr *cryptKeyring) unwrap(passphrase []byte) (map[int][]byte, error) {
+	keys := make(map[int][]byte)
+	for _, key := range kr.Keys {
+		decReader, err := dcrypto.NewDecrypter(bytes.NewReader(key.Wrapped), passphrase)
+		var plain []byte
+		if err == nil {
+			plain, err = ioutil.ReadAll(decReader)
+			decReader.Close()
+		}
+		if err != nil || (!key.Legacy && len(plain) != cryptKeySize) {
+			return nil, invalidArgumentsErr(fmt.Errorf("cannot unwrap key version %d, wrong passphrase?", key.Version))
+		}
+		keys[key.Version] = plain
+	}
+	return keys, nil
+}
+
+func wrapCryptKey(key, passphrase []byte) ([]byte, error) {
+	encReader, err := dcrypto.NewEncrypter(bytes.NewReader(key), passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(encReader)
+}
+
+// rekey rewraps every key under newPassphrase, first adding a new key
+// that files will from then on be encrypted under if rotate is set or
+// if there are no keys yet. Without keys, oldPassphrase is the one that
+// content was encrypted under so far, which is kept as the legacy key.
+func (kr *cryptKeyring) rekey(oldPassphrase, newPassphrase []byte, rotate bool, now time.Time) error {
+	if len(oldPassphrase) < 1 {
+		return invalidArgumentsErr(fmt.Errorf("the current passphrase is needed to rekey"))
+	}
+
+	keys, err := kr.unwrap(oldPassphrase)
+	if err != nil {
+		return err
+	}
+
+	if len(kr.Keys) < 1 {
+		keys[0] = oldPassphrase
+		kr.Keys = append(kr.Keys, &wrappedCryptKey{Version: 0, CreatedAt: now, Legacy: true})
+	}
+
+	if rotate || kr.current() == nil {
+		version := 1
+		if latest := kr.current(); latest != nil {
+			version = latest.Version + 1
+		}
+		key := make([]byte, cryptKeySize)
+		if _, err := rand.Read(key); err != nil {
+			return err
+		}
+		keys[version] = key
+		kr.Keys = append(kr.Keys, &wrappedCryptKey{Version: version, CreatedAt: now})
+	}
+
+	for _, key := range kr.Keys {
+		wrapped, err := wrapCryptKey(keys[key.Version], newPassphrase)
+		if err != nil {
+			return err
+		}
+		key.Wrapped = wrapped
+	}
+	return nil
+}
+
+// contentPassword is what a content key is handed to dcrypto as.
+func contentPassword(key []byte) []byte {
+	return []byte(hex.EncodeToString(key))
+}
+
+// CryptEncrypter returns the encrypter for the context. With a keyring,
+// content is encrypted under its current key, otherwise directly under
+// the password. A keyring lost along with the .gd directory is restored
+// from its remote copy first, so that content isn't encrypted under the
+// password alone while a keyring exists.
+func (g *Commands) CryptEncrypter(password []byte) (func(io.Reader) (io.Reader, error), error) {
+	kr, err := g.loadOrRestoreCryptKeyring(g.context.GDPath())
+	if err != nil {
+		return nil, err
+	}
+	return cryptEncrypter(kr, password)
+}
+
+// cryptEncrypter returns the encrypter for the keyring kr, which may be nil.
+func cryptEncrypter(kr *cryptKeyring, password []byte) (func(io.Reader) (io.Reader, error), error) {
+	contentPass := password
+	if kr != nil && kr.current() != nil {
+		keys, err := kr.unwrap(password)
+		if err != nil {
+			return nil, err
+		}
+		contentPass = contentPassword(keys[kr.current().Version])
+	}
+
+	return func(r io.Reader) (io.Reader, error) {
+		return dcrypto.NewEncrypter(r, contentPass)
+	}, nil
+}
+
+// CryptDecrypter returns the decrypter for the context. Content encrypted
+// under any of the keys of its keyring, or directly under the passphrase
+// from before the keyring, is decrypted: the beginning of the content
+// tells which. As for CryptEncrypter, a lost keyring is restored first.
+func (g *Commands) CryptDecrypter(password []byte) (func(io.Reader) (io.ReadCloser, error), error) {
+	kr, err := g.loadOrRestoreCryptKeyring(g.context.GDPath())
+	if err != nil {
+		return nil, err
+	}
+	return cryptDecrypter(kr, password)
+}
+
+// cryptDecrypter returns the decrypter for the keyring kr, which may be nil.
+func cryptDecrypter(kr *cryptKeyring, password []byte) (func(io.Reader) (io.ReadCloser, error), error) {
+	candidates := [][]byte{}
+	legacyPass := password
+	if kr != nil {
+		keys, err := kr.unwrap(password)
+		if err != nil {
+			return nil, err
+		}
+		if legacy := kr.legacy(); legacy != nil {
+			legacyPass = keys[legacy.Version]
+			delete(keys, legacy.Version)
+		}
+		versions := make([]int, 0, len(keys))
+		for version := range keys {
+			versions = append(versions, version)
+		}
+		// The newest keys are the likeliest.
+		sort.Sort(sort.Reverse(sort.IntSlice(versions)))
+		for _, version := range versions {
+			candidates = append(candidates, contentPassword(keys[version]))
+		}
+	}
+
+	return func(r io.Reader) (io.ReadCloser, error) {
+		if len(candidates) < 1 {
+			return dcrypto.NewDecrypter(r, legacyPass)
+		}
+
+		br := bufio.NewReaderSize(r, dcrypto.VerifiablePrefixSize)
+		prefix, err := br.Peek(dcrypto.VerifiablePrefixSize)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		for _, candidate := range candidates {
+			// Past the header, a chunk was verified under the candidate.
+			verified, _, vErr := dcrypto.VerifyPrefix(bytes.NewReader(prefix), candidate)
+			if vErr == nil && verified > int64(dcrypto.MaxHeaderSize) {
+				return dcrypto.NewDecrypter(br, candidate)
+			}
+		}
+		// Content that a key can't be verified against was encrypted directly under the passphrase.
+		return dcrypto.NewDecrypter(br, legacyPass)
+	}, nil
+}
+
+// recordCryptKey notes that the file pushed to relToRoot was encrypted
+// under the current key, for `crypt status`. The keyring is only written
+// by saveCryptKeyRecords, once for all the files of a push.
+func (g *Commands) recordCryptKey(relToRoot string) {
+	g.cryptPushedMu.Lock()
+	defer g.cryptPushedMu.Unlock()
+
+	g.cryptPushed = append(g.cryptPushed, relToRoot)
+}
+
+// saveCryptKeyRecords saves the key versions noted by recordCryptKey.
+func (g *Commands) saveCryptKeyRecords() {
+	g.cryptPushedMu.Lock()
+	pushed := g.cryptPushed
+	g.cryptPushed = nil
+	g.cryptPushedMu.Unlock()
+
+	if len(pushed) < 1 {
+		return
+	}
+
+	cryptKeyringMu.Lock()
+	defer cryptKeyringMu.Unlock()
+
+	kr, err := loadCryptKeyring(g.context.GDPath())
+	if err != nil {
+		g.log.LogErrf("crypt keyring: %v\n", err)
+	}
+	if kr == nil || kr.current() == nil {
+		return
+	}
+
+	if kr.Files == nil {
+		kr.Files = make(map[string]int)
+	}
+	for _, relToRoot := range pushed {
+		kr.Files[relToRoot] = kr.current().Version
+	}
+	if err := kr.save(); err != nil {
+		g.log.LogErrf("crypt keyring: %v\n", err)
+	}
+}
+
+// CryptRekey rewraps the content keys of the context under newPassphrase,
+// creating its keyring on first use. If rotate is set, a new content key
+// is added that files pushed from then on are encrypted under, while
+// those already pushed remain readable. No content is uploaded again.
+// Passphrases that aren't given are prompted for.
+func (g *Commands) CryptRekey(oldPassphrase, newPassphrase []byte, rotate bool) error {
+	cryptKeyringMu.Lock()
+	defer cryptKeyringMu.Unlock()
+
+	gdPath := g.context.GDPath()
+	kr, err := g.loadOrRestoreCryptKeyring(gdPath)
+	if err != nil {
+		return err
+	}
+	if kr == nil {
+		kr = &cryptKeyring{path: cryptKeyringPath(gdPath)}
+	}
+
+	if len(oldPassphrase) < 1 {
+		promptText := "Current passphrase: "
+		if len(kr.Keys) < 1 {
+			// Content pushed so far is encrypted directly under it.
+			promptText = "Passphrase that files were encrypted under so far: "
+		}
+		if oldPassphrase, err = config.PromptPassphrase(promptText, false); err != nil {
+			return err
+		}
+	}
+	if len(newPassphrase) < 1 {
+		if newPassphrase, err = config.PromptPassphrase("New passphrase: ", true); err != nil {
+			return err
+		}
+	}
+
+	if err := kr.rekey(oldPassphrase, newPassphrase, rotate, time.Now().UTC()); err != nil {
+		return err
+	}
+	if err := kr.save(); err != nil {
+		return err
+	}
+	if err := g.pushCryptKeyringCopy(kr); err != nil {
+		return remoteLookupErr(fmt.Errorf("the keyring was rekeyed locally but its copy at %s couldn't be updated, run rekey again: %v",
+			customQuote("/"+cryptKeyringRemoteName), err))
+	}
+
+	g.log.Logf("%d key(s) now wrapped under the new passphrase, files are encrypted under key version %d\n",
+		len(kr.Keys), kr.current().Version)
+	return nil
+}
+
+// CryptStatus prints the keys of the context and the files pushed under each.
+func (g *Commands) CryptStatus() error {
+	kr, err := g.loadOrRestoreCryptKeyring(g.context.GDPath())
+	if err != nil {
+		return err
+	}
+	if kr == nil || len(kr.Keys) < 1 {
+		g.log.Logf("no keyring, files are encrypted directly under the passphrase; run `drive %s %s` to create one\n",
+			CryptKey, CryptRekeyKey)
+		return nil
+	}
+
+	byVersion := make(map[int][]string)
+	for relToRoot, version := range kr.Files {
+		byVersion[version] = append(byVersion[version], relToRoot)
+	}
+
+	current := kr.current().Version
+	for _, key := range kr.Keys {
+		if key.Legacy {
+			g.log.Logf("key version %d (the passphrase from before the keyring) added %s\n", key.Version, key.CreatedAt.Format(time.RFC3339))
+			continue
+		}
+		marker := ""
+		if key.Version == current {
+			marker = " (current)"
+		}
+		g.log.Logf("key version %d%s created %s: %d file(s)\n", key.Version, marker, key.CreatedAt.Format(time.RFC3339), len(byVersion[key.Version]))
+		g.logCryptFiles(byVersion[key.Version])
+	}
+	return nil
+}
+
+func (g *Commands) logCryptFiles(paths []string) {
+	sort.Strings(paths)
+	for _, p := range paths {
+		g.log.Logf("\t%s\n", p)
+	}
+}
+
+// pushCryptKeyringCopy uploads kr to the remote root, over its earlier copy if any.
+func (g *Commands) pushCryptKeyringCopy(kr *cryptKeyring) error {
+	data, err := json.MarshalIndent(kr, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	existing, err := g.rem.FindByPath("/" + cryptKeyringRemoteName)
+	if err != nil && err != ErrPathNotExists {
+		return err
+	}
+
+	body := bytes.NewReader(data)
+	if existing != nil {
+		_, err = g.rem.service.Files.Update(existing.Id, &drive.File{}).Media(body).Do()
+		return err
+	}
+
+	f := &drive.File{
+		Title:    cryptKeyringRemoteName,
+		MimeType: "application/json",
+		Parents:  []*drive.ParentReference{{Id: g.rem.root()}},
+	}
+	_, err = g.rem.service.Files.Insert(f).Media(body).Do()
+	return err
+}
+
+// loadOrRestoreCryptKeyring is loadCryptKeyring that, if the context has
+// no keyring, restores it from the copy at the remote root if there is one.
+func (g *Commands) loadOrRestoreCryptKeyring(gdPath string) (*cryptKeyring, error) {
+	kr, err := loadCryptKeyring(gdPath)
+	if err != nil || kr != nil {
+		return kr, err
+	}
+
+	remoteCopy, err := g.rem.FindByPath("/" + cryptKeyringRemoteName)
+	if err == ErrPathNotExists || remoteCopy == nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := g.rem.Download(remoteCopy.Id, "")
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+
+	kr = &cryptKeyring{}
+	if err := json.Unmarshal(data, kr); err != nil {
+		return nil, fmt.Errorf("%s: %v", cryptKeyringRemoteName, err)
+	}
+	kr.path = cryptKeyringPath(gdPath)
+	if err := kr.save(); err != nil {
+		return nil, err
+	}
+
+	g.log.Logf("Restored the keyring from its copy at %s\n", customQuote("/"+cryptKeyringRemoteName))
+	return kr, nil
+}
@@ -0,0 +1,281 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/odeke-em/drive/config"
+	"github.com/odeke-em/drive/src/dcrypto"
+	"github.com/odeke-em/log"
+	drive "google.golang.org/api/drive/v2"
+)
+
+func cryptRoundTrip(t *testing.T, gdPath string, password, plain []byte) []byte {
+	kr, err := loadCryptKeyring(gdPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypter, err := cryptEncrypter(kr, password)
+	if err != nil {
+		t.Fatalf("cryptEncrypter: %v", err)
+	}
+	encReader, err := encrypter(bytes.NewReader(plain))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cipher, err := ioutil.ReadAll(encReader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cipher
+}
+
+func cryptDecrypt(gdPath string, password, cipher []byte) ([]byte, error) {
+	kr, err := loadCryptKeyring(gdPath)
+	if err != nil {
+		return nil, err
+	}
+	decrypter, err := cryptDecrypter(kr, password)
+	if err != nil {
+		return nil, err
+	}
+	decReader, err := decrypter(bytes.NewReader(cipher))
+	if err != nil {
+		return nil, err
+	}
+	defer decReader.Close()
+	return ioutil.ReadAll(decReader)
+}
+
+func TestCryptKeyringRekey(t *testing.T) {
+	gdPath, err := ioutil.TempDir("", "crypt-keyring")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(gdPath)
+
+	old, first, second := []byte("old"), []byte("first"), []byte("second")
+	plain := []byte("content that is never uploaded again")
+	now := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Content pushed before the keyring existed is under the passphrase itself.
+	legacy := cryptRoundTrip(t, gdPath, old, plain)
+
+	kr := &cryptKeyring{path: cryptKeyringPath(gdPath)}
+	if err := kr.rekey(nil, first, false, now); err == nil {
+		t.Errorf("creating the keyring without the passphrase from before it must fail")
+	}
+	if err := kr.rekey(old, first, false, now); err != nil {
+		t.Fatal(err)
+	}
+	if err := kr.save(); err != nil {
+		t.Fatal(err)
+	}
+	if got := kr.current().Version; got != 1 {
+		t.Errorf("current version: got %d want 1", got)
+	}
+
+	underFirst := cryptRoundTrip(t, gdPath, first, plain)
+	for _, cipher := range [][]byte{legacy, underFirst} {
+		if got, err := cryptDecrypt(gdPath, first, cipher); err != nil || !bytes.Equal(got, plain) {
+			t.Errorf("decrypting under the first passphrase: got %q, %v", got, err)
+		}
+	}
+	if decReader, err := dcrypto.NewDecrypter(bytes.NewReader(underFirst), first); err == nil {
+		if _, err = ioutil.ReadAll(decReader); err == nil {
+			t.Errorf("content must be encrypted under the content key, not the passphrase")
+		}
+	}
+
+	if err := kr.rekey(second, second, true, now); err == nil {
+		t.Errorf("rekeying with the wrong passphrase must fail")
+	}
+	if err := kr.rekey(first, second, true, now); err != nil {
+		t.Fatal(err)
+	}
+	if err := kr.save(); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(kr.Keys); got != 3 {
+		t.Errorf("keys: got %d want 3 including the legacy one", got)
+	}
+
+	if _, err := cryptDecrypter(kr, first); err == nil {
+		t.Errorf("the first passphrase must no longer unwrap the keys")
+	}
+	underSecond := cryptRoundTrip(t, gdPath, second, plain)
+	for _, cipher := range [][]byte{legacy, underFirst, underSecond} {
+		if got, err := cryptDecrypt(gdPath, second, cipher); err != nil || !bytes.Equal(got, plain) {
+			t.Errorf("decrypting under the second passphrase: got %q, %v", got, err)
+		}
+	}
+
+	reloaded, err := loadCryptKeyring(gdPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reloaded.unwrap(second); err != nil {
+		t.Errorf("reloaded keyring: %v", err)
+	}
+}
+
+func TestLoadCryptKeyringMissing(t *testing.T) {
+	gdPath, err := ioutil.TempDir("", "crypt-keyring")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(gdPath)
+
+	kr, err := loadCryptKeyring(gdPath)
+	if err != nil || kr != nil {
+		t.Errorf("got %v, %v; want nil, nil", kr, err)
+	}
+}
+
+// keyringCommands returns Commands for a fresh context whose remote root
+// holds remoteCopy as the copy of the keyring, unless it is nil.
+func keyringCommands(t *testing.T, root string, remoteCopy []byte) *Commands {
+	files := map[string]*drive.File{
+		"keyring": {Id: "keyring", Title: cryptKeyringRemoteName, MimeType: "application/json"},
+	}
+	children := map[string][]string{}
+	if remoteCopy != nil {
+		children["root"] = []string{"keyring"}
+	}
+	tree := fakeTreeTransport(t, files, children)
+	transport := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Query().Get("alt") != "media" {
+			return tree.RoundTrip(req)
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(bytes.NewReader(remoteCopy)),
+			Request:    req,
+		}, nil
+	})
+	rem, err := remoteFromClient(&http.Client{Transport: transport})
+	if err != nil {
+		t.Fatalf("remoteFromClient: %v", err)
+	}
+
+	context := &config.Context{AbsPath: root}
+	if err := os.MkdirAll(context.GDPath(), 0700); err != nil {
+		t.Fatal(err)
+	}
+	return &Commands{rem: rem, context: context, opts: &Options{}, log: log.New(nil, ioutil.Discard, ioutil.Discard)}
+}
+
+func TestCryptEncrypterRestoresKeyring(t *testing.T) {
+	root, err := ioutil.TempDir("", "crypt-keyring")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	old, passphrase := []byte("old"), []byte("passphrase")
+	kr := &cryptKeyring{}
+	if err := kr.rekey(old, passphrase, false, time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+	remoteCopy, err := json.Marshal(kr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The .gd directory and the keyring in it were lost.
+	g := keyringCommands(t, root, remoteCopy)
+	encrypter, err := g.CryptEncrypter(passphrase)
+	if err != nil {
+		t.Fatalf("CryptEncrypter: %v", err)
+	}
+	if _, err := os.Stat(cryptKeyringPath(g.context.GDPath())); err != nil {
+		t.Errorf("expected the keyring to be restored: %v", err)
+	}
+
+	plain := []byte("content")
+	encReader, err := encrypter(bytes.NewReader(plain))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cipher, err := ioutil.ReadAll(encReader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Under the content key of the keyring, not the passphrase alone.
+	if got, err := cryptDecrypt(g.context.GDPath(), passphrase, cipher); err != nil || !bytes.Equal(got, plain) {
+		t.Errorf("decrypting with the restored keyring: got %q, %v", got, err)
+	}
+	if decReader, err := dcrypto.NewDecrypter(bytes.NewReader(cipher), passphrase); err == nil {
+		if _, err = ioutil.ReadAll(decReader); err == nil {
+			t.Errorf("content must be encrypted under the restored content key, not the passphrase")
+		}
+	}
+
+	decrypter, err := keyringCommands(t, filepath.Join(root, "other"), remoteCopy).CryptDecrypter(passphrase)
+	if err != nil {
+		t.Fatalf("CryptDecrypter: %v", err)
+	}
+	decReader, err := decrypter(bytes.NewReader(cipher))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := ioutil.ReadAll(decReader); err != nil || !bytes.Equal(got, plain) {
+		t.Errorf("decrypting after restoring: got %q, %v", got, err)
+	}
+}
+
+func TestCryptKeyRecordsSavedOncePerPush(t *testing.T) {
+	root, err := ioutil.TempDir("", "crypt-keyring")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	g := keyringCommands(t, root, nil)
+	gdPath := g.context.GDPath()
+	kr := &cryptKeyring{path: cryptKeyringPath(gdPath)}
+	if err := kr.rekey([]byte("old"), []byte("new"), false, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if err := kr.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	g.recordCryptKey("/a")
+	g.recordCryptKey("/b")
+	reloaded, err := loadCryptKeyring(gdPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reloaded.Files) != 0 {
+		t.Errorf("expected nothing to be saved before the push is done, got %v", reloaded.Files)
+	}
+
+	g.saveCryptKeyRecords()
+	if reloaded, err = loadCryptKeyring(gdPath); err != nil {
+		t.Fatal(err)
+	}
+	if len(reloaded.Files) != 2 || reloaded.Files["/a"] != 1 || reloaded.Files["/b"] != 1 {
+		t.Errorf("files: got %v want /a and /b under key version 1", reloaded.Files)
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"path"
+)
+
+// changeDependencies returns, for each change, the indices of the changes
+// that must be played before it, so that the rest can run concurrently.
+// A change waits for its nearest ancestor folder being added or modified.
+// A deletion at a path that another change replaces e.g a remote file being
+// swapped for a local folder of the same name is played before that change.
+// Any other deletion waits for every non deletion, so that nothing is
+// removed before its replacement is in place.
+func changeDependencies(cl []*Change) [][]int {
+	ops := make([]Operation, len(cl))
+	folderChanges := make(map[string]int)
+	nonDeletionPaths := make(map[string]bool)
+	for i, c := range cl {
+		if c == nil {
+			continue
+		}
+		if ops[i] = c.Op(); ops[i] == OpDelete {
+			continue
+		}
+		nonDeletionPaths[c.Path] = true
+		if c.Src != nil && c.Src.IsDir {
+			folderChanges[c.Path] = i
+		}
+	}
+
+	replacingDeletions := make(map[string]int)
+	var nonDeletions []int
+	for i, c := range cl {
+		if c == nil {
+			continue
+		}
+		if ops[i] != OpDelete {
+			nonDeletions = append(nonDeletions, i)
+		} else if nonDeletionPaths[c.Path] {
+			replacingDeletions[c.Path] = i
+		}
+	}
+
+	deps := make([][]int, len(cl))
+	for i, c := range cl {
+		if c == nil {
+			continue
+		}
+
+		if ops[i] == OpDelete {
+			if !nonDeletionPaths[c.Path] {
+				deps[i] = nonDeletions
+			}
+			continue
+		}
+
+		if j, ok := replacingDeletions[c.Path]; ok {
+			deps[i] = append(deps[i], j)
+		}
+
+		for dir := path.Dir(c.Path); ; dir = path.Dir(dir) {
+			if j, ok := folderChanges[dir]; ok && j != i {
+				deps[i] = append(deps[i], j)
+				break
+			}
+			if dir == "/" || dir == "." {
+				break
+			}
+		}
+	}
+
+	return deps
+}
+
+// scheduleDependencies dispatches each of the n = len(deps) jobs once the
+// jobs it depends on are done, in the order that they become ready.
+// dispatch must arrange for done to be invoked once job i finishes, or
+// return false if it skipped the job, which then counts as done.
+func scheduleDependencies(deps [][]int, dispatch func(i int, done func()) bool) {
+	n := len(deps)
+	pending := make([]int, n)
+	dependents := make([][]int, n)
+	for i, ds := range deps {
+		pending[i] = len(ds)
+		for _, d := range ds {
+			dependents[d] = append(dependents[d], i)
+		}
+	}
+
+	var ready []int
+	for i := 0; i < n; i++ {
+		if pending[i] < 1 {
+			ready = append(ready, i)
+		}
+	}
+
+	// Buffered so that finishing jobs never block while a dispatch is.
+	doneChan := make(chan int, n)
+
+	for remaining := n; remaining > 0; remaining-- {
+		for len(ready) > 0 {
+			i := ready[0]
+			ready = ready[1:]
+			if !dispatch(i, func() { doneChan <- i }) {
+				doneChan <- i
+			}
+		}
+
+		i := <-doneChan
+		for _, j := range dependents[i] {
+			pending[j]--
+			if pending[j] < 1 {
+				ready = append(ready, j)
+			}
+		}
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestChangeDependencies(t *testing.T) {
+	folder := func(name string) *File { return &File{Name: name, IsDir: true} }
+	file := func(name string) *File { return &File{Name: name} }
+
+	cl := []*Change{
+		{Path: "/old.txt", Dest: file("old.txt")},        // 0: deletion
+		{Path: "/a", Src: folder("a")},                   // 1: new folder
+		{Path: "/a/b", Src: folder("b")},                 // 2: new folder in 1
+		{Path: "/a/b/c.txt", Src: file("c.txt")},         // 3: new file in 2
+		{Path: "/a/d.txt", Src: file("d.txt")},           // 4: new file in 1
+		{Path: "/e.txt", Src: file("e.txt")},             // 5: new file at the root
+		{Path: "/swapped", Dest: file("swapped")},        // 6: deletion replaced by 7
+		{Path: "/swapped", Src: folder("swapped")},       // 7: new folder
+		{Path: "/swapped/f.txt", Src: file("f.txt")},     // 8: new file in 7
+		{Path: "/", Src: folder("/"), Dest: folder("/")}, // 9: the root itself
+		nil,
+	}
+
+	want := [][]int{
+		0:  {1, 2, 3, 4, 5, 7, 8, 9},
+		1:  {9},
+		2:  {1},
+		3:  {2},
+		4:  {1},
+		5:  {9},
+		6:  nil,
+		7:  {6, 9},
+		8:  {7},
+		9:  nil,
+		10: nil,
+	}
+
+	got := changeDependencies(cl)
+	for i := range want {
+		if !reflect.DeepEqual(got[i], want[i]) {
+			t.Errorf("#%d %v: got dependencies %v want %v", i, cl[i], got[i], want[i])
+		}
+	}
+}
+
+func TestScheduleDependencies(t *testing.T) {
+	deps := [][]int{
+		0: {1, 2, 3, 4},
+		1: nil,
+		2: {1},
+		3: {2},
+		4: {1},
+		5: nil,
+		6: {5},
+	}
+
+	var mu sync.Mutex
+	done := make(map[int]bool)
+	var wg sync.WaitGroup
+
+	scheduleDependencies(deps, func(i int, finish func()) bool {
+		mu.Lock()
+		for _, d := range deps[i] {
+			if !done[d] {
+				t.Errorf("#%d was dispatched before its dependency #%d was done", i, d)
+			}
+		}
+		mu.Unlock()
+
+		// Skipped jobs count as done.
+		if i == 5 {
+			mu.Lock()
+			done[i] = true
+			mu.Unlock()
+			return false
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			mu.Lock()
+			done[i] = true
+			mu.Unlock()
+			finish()
+		}()
+		return true
+	})
+	wg.Wait()
+
+	if len(done) != len(deps) {
+		t.Errorf("got %d jobs done want %d", len(done), len(deps))
+	}
+}
@@ -24,6 +24,7 @@ import (
 	"io"
 
 	"github.com/odeke-em/drive/src/dcrypto/v1"
+	"github.com/odeke-em/drive/src/dcrypto/v2"
 )
 
 // Version is the version of the en/decryption library used.
@@ -41,10 +42,11 @@ type hasher func(io.Reader, io.Reader, []byte, hash.Hash) ([]byte, error)
 // These are the different versions of the en/decryption library.
 const (
 	V1 Version = iota
+	V2
 )
 
 // PreferedVersion is the preferred version of encryption.
-const PreferedVersion = V1
+const PreferedVersion = V2
 
 var encrypters map[Version]encrypter
 var decrypters map[Version]decrypter
@@ -53,25 +55,79 @@ var hashers map[Version]hasher
 // MaxHeaderSize is the maximum header size of all versions.
 // This many bytes at the beginning of a file should be enough to compute
 // a hash of a local file.
-var MaxHeaderSize = v1.HeaderSize + 4
+var MaxHeaderSize = maxInt(v1.HeaderSize, v2.HeaderSize) + 4
 
-// Overhead is the overhead added by the preferred encryption library plus the version.
-var Overhead = v1.Overhead + 4
+// versionSize is the size of the version that prefixes every ciphertext.
+const versionSize = 4
+
+// VerifiablePrefixSize is how much of the beginning of a ciphertext
+// VerifyPrefix needs to tell whether a password is the right one.
+var VerifiablePrefixSize = v2.FirstChunkSpan + versionSize
 
 func init() {
 	decrypters = map[Version]decrypter{
 		V1: v1.NewDecryptReader,
+		V2: v2.NewDecryptReader,
 	}
 
 	encrypters = map[Version]encrypter{
 		V1: v1.NewEncryptReader,
+		V2: v2.NewEncryptReader,
 	}
 	hashers = map[Version]hasher{
 		V1: v1.Hash,
+		V2: v2.Hash,
+	}
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// PlaintextSize returns the size of the plaintext of a ciphertext of
+// ciphertextSize bytes. Since the overhead depends on the version, that
+// isn't known without reading the ciphertext, localSize e.g the size of
+// the local copy is returned if any version maps ciphertextSize to it.
+// Otherwise the size as of the PreferedVersion is returned when valid.
+func PlaintextSize(ciphertextSize, localSize int64) int64 {
+	v2Size := v2.PlaintextSize(ciphertextSize - versionSize)
+	v1Size := ciphertextSize - int64(v1.Overhead) - versionSize
+	if localSize >= 0 && (localSize == v1Size || localSize == v2Size) {
+		return localSize
+	}
+	if v2Size >= 0 {
+		return v2Size
+	}
+	return v1Size
+}
+
+// VerifyPrefix verifies a prefix of a ciphertext e.g the part of an
+// upload already sent, returning how many of its bytes verified and
+// whether it is complete. Only versions that authenticate chunks can
+// verify a prefix, for the others nothing is reported as verified.
+func VerifyPrefix(r io.Reader, password []byte) (verified int64, complete bool, err error) {
+	version, err := readVersion(r)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
 	}
+	if version != V2 {
+		return 0, false, nil
+	}
+	verified, complete, err = v2.VerifyPrefix(r, password)
+	if verified > 0 {
+		verified += versionSize
+	}
+	return verified, complete, err
 }
 
 // NewEncrypter returns an encrypting reader using the PreferedVersion.
+// The reader is also a PrefixVerifier.
 func NewEncrypter(r io.Reader, password []byte) (io.Reader, error) {
 	v, err := writeVersion(PreferedVersion)
 	if err != nil {
@@ -85,7 +141,24 @@ func NewEncrypter(r io.Reader, password []byte) (io.Reader, error) {
 	if err != nil {
 		return nil, err
 	}
-	return io.MultiReader(bytes.NewReader(v), encReader), nil
+	return &verifyingEncrypter{Reader: io.MultiReader(bytes.NewReader(v), encReader), password: password}, nil
+}
+
+// PrefixVerifier is implemented by the readers that NewEncrypter returns,
+// to verify a prefix of what they read, as VerifyPrefix does, without
+// being handed the password again.
+type PrefixVerifier interface {
+	VerifyPrefix(r io.Reader) (verified int64, complete bool, err error)
+}
+
+// verifyingEncrypter is the reader that NewEncrypter returns.
+type verifyingEncrypter struct {
+	io.Reader
+	password []byte
+}
+
+func (e *verifyingEncrypter) VerifyPrefix(r io.Reader) (verified int64, complete bool, err error) {
+	return VerifyPrefix(r, e.password)
 }
 
 // NewDecrypter returns a decrypting reader based on the version used to encrypt.
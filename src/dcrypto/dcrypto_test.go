@@ -23,6 +23,8 @@ import (
 	"testing"
 
 	"github.com/odeke-em/drive/src/dcrypto"
+	"github.com/odeke-em/drive/src/dcrypto/v1"
+	"github.com/odeke-em/drive/src/dcrypto/v2"
 )
 
 // randBytes returns random bytes in a byte slice of size.
@@ -117,3 +119,65 @@ func TestHash(t *testing.T) {
 	}
 
 }
+
+func TestPlaintextSize(t *testing.T) {
+	v1Size := func(size int64) int64 { return size + int64(v1.Overhead) + 4 }
+	v2Size := func(size int64) int64 { return v2.CiphertextSize(size) + 4 }
+
+	testCases := []struct {
+		ciphertextSize int64
+		localSize      int64
+		want           int64
+	}{
+		{ciphertextSize: v2Size(0), localSize: -1, want: 0},
+		{ciphertextSize: v2Size(1337), localSize: -1, want: 1337},
+		{ciphertextSize: v2Size(1337), localSize: 1337, want: 1337},
+		{ciphertextSize: v2Size(v2.ChunkSize * 3), localSize: 10, want: v2.ChunkSize * 3},
+		{ciphertextSize: v1Size(1337), localSize: 1337, want: 1337},
+		{ciphertextSize: v1Size(2), localSize: -1, want: 2},
+	}
+
+	for _, tc := range testCases {
+		if got := dcrypto.PlaintextSize(tc.ciphertextSize, tc.localSize); got != tc.want {
+			t.Errorf("PlaintextSize(%d, %d) = %d; want %d", tc.ciphertextSize, tc.localSize, got, tc.want)
+		}
+	}
+}
+
+func TestEncrypterVerifiesPrefix(t *testing.T) {
+	password := []byte("test")
+	b, err := randBytes(v2.ChunkSize + 1337)
+	if err != nil {
+		t.Fatalf("randBytes() => %q; want nil", err)
+	}
+	encReader, err := dcrypto.NewEncrypter(bytes.NewReader(b), password)
+	if err != nil {
+		t.Fatalf("NewEncrypter() => %q; want nil", err)
+	}
+	verifier, ok := encReader.(dcrypto.PrefixVerifier)
+	if !ok {
+		t.Fatalf("NewEncrypter() => %T; want a PrefixVerifier", encReader)
+	}
+	cipher, err := ioutil.ReadAll(encReader)
+	if err != nil {
+		t.Fatalf("ioutil.ReadAll(*Encrypter) => %q; want nil", err)
+	}
+
+	verified, complete, err := verifier.VerifyPrefix(bytes.NewReader(cipher))
+	if err != nil || !complete || verified != int64(len(cipher)) {
+		t.Errorf("VerifyPrefix(ciphertext) => %d, %v, %v; want %d, true, nil", verified, complete, err, len(cipher))
+	}
+
+	// A prefix cut short within the second chunk verifies up to the first.
+	truncated := cipher[:dcrypto.VerifiablePrefixSize+10]
+	verified, complete, err = verifier.VerifyPrefix(bytes.NewReader(truncated))
+	if err != nil || complete || verified != int64(dcrypto.VerifiablePrefixSize) {
+		t.Errorf("VerifyPrefix(truncated) => %d, %v, %v; want %d, false, nil", verified, complete, err, dcrypto.VerifiablePrefixSize)
+	}
+
+	tampered := append([]byte{}, cipher...)
+	tampered[dcrypto.MaxHeaderSize+1] ^= 0x01
+	if _, _, err := verifier.VerifyPrefix(bytes.NewReader(tampered)); err != v2.DecryptErr {
+		t.Errorf("VerifyPrefix(tampered) => %v; want %v", err, v2.DecryptErr)
+	}
+}
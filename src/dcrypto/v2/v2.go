@@ -0,0 +1,457 @@
+// Copyright 2016 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package v2 implements the second version of encryption for drive.
+// Like v1 it uses AES-256 in CTR mode for encryption, with keys derived
+// by scrypt, but it authenticates the ciphertext in chunks, each with an
+// HMAC using SHA-512 that covers the header, the index of the chunk and
+// whether it is the last one. Decryption can then verify and release the
+// plaintext chunk by chunk, detecting tampering or truncation as soon as
+// the affected chunk is read, and a prefix of a ciphertext e.g the part
+// of an upload already sent can be verified on its own.
+//
+// This package should always be able to decrypt files that were encrypted
+// using this package. If there is a change that needs to be made that would
+// prevent decryption of old files, it should be done in a new version.
+
+package v2
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"io"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	// The size of the HMAC sum of each chunk.
+	hmacSize = sha512.Size
+
+	// The size of the HMAC key.
+	hmacKeySize = 32 // 256 bits
+
+	// The size of the random salt.
+	saltSize = 32 // 256 bits
+
+	// The size of the AES key.
+	aesKeySize = 32 // 256 bits
+
+	// The size of the AES block.
+	blockSize = aes.BlockSize
+
+	// The number of iterations to use in for key generation
+	// See N value in https://godoc.org/golang.org/x/crypto/scrypt#Key
+	// Must be a power of 2.
+	scryptIterations int32 = 262144 // 2^18
+
+	// The most iterations that a header may ask for, so that a corrupt
+	// or crafted header can't make key derivation take all the memory.
+	maxScryptIterations int32 = 1 << 20
+
+	// ChunkSize is the size of the plaintext of every chunk but the last.
+	ChunkSize = 1 << 20 // 1MiB
+)
+
+var (
+	// The underlying hash function to use for HMAC.
+	hashFunc = sha512.New
+
+	// The amount of key material we need.
+	keySize = hmacKeySize + aesKeySize
+
+	// The size of the Header: the scrypt iterations, the salt, the IV
+	// and the chunk size.
+	HeaderSize = 4 + saltSize + blockSize + 4
+
+	// FirstChunkSpan is the size of the header and the first chunk, enough
+	// for VerifyPrefix to tell whether a password is the right one.
+	FirstChunkSpan = HeaderSize + ChunkSize + hmacSize
+)
+
+var (
+	DecryptErr   = errors.New("message corrupt or incorrect password")
+	TruncatedErr = errors.New("message truncated")
+)
+
+// randBytes returns random bytes in a byte slice of size.
+func randBytes(size int) ([]byte, error) {
+	b := make([]byte, size)
+	_, err := rand.Read(b)
+	return b, err
+}
+
+// keys derives AES and HMAC keys from a password and salt.
+func keys(pass, salt []byte, iterations int) (aesKey, hmacKey []byte, err error) {
+	key, err := scrypt.Key(pass, salt, iterations, 8, 1, keySize)
+	if err != nil {
+		return nil, nil, err
+	}
+	aesKey = append(aesKey, key[:aesKeySize]...)
+	hmacKey = append(hmacKey, key[aesKeySize:keySize]...)
+	return aesKey, hmacKey, nil
+}
+
+// CiphertextSize returns the size of the ciphertext of plaintextSize bytes.
+func CiphertextSize(plaintextSize int64) int64 {
+	chunks := (plaintextSize + ChunkSize - 1) / ChunkSize
+	if chunks < 1 {
+		chunks = 1
+	}
+	return int64(HeaderSize) + plaintextSize + chunks*hmacSize
+}
+
+// PlaintextSize returns the size of the plaintext of a ciphertext of
+// ciphertextSize bytes, or -1 if no ciphertext has that size.
+func PlaintextSize(ciphertextSize int64) int64 {
+	body := ciphertextSize - int64(HeaderSize)
+	if body < hmacSize {
+		return -1
+	}
+	chunks := (body + ChunkSize + hmacSize - 1) / (ChunkSize + hmacSize)
+	plaintextSize := body - chunks*hmacSize
+	if plaintextSize < 0 || CiphertextSize(plaintextSize) != ciphertextSize {
+		return -1
+	}
+	return plaintextSize
+}
+
+// params are what the header of a ciphertext decodes to.
+type params struct {
+	aesKey    []byte
+	hmacKey   []byte
+	iv        []byte
+	chunkSize int
+	header    []byte
+}
+
+// chunkMAC returns the HMAC of the ciphertext of the chunk at index.
+func (p *params) chunkMAC(index uint64, final bool, ciphertext []byte) []byte {
+	h := hmac.New(hashFunc, p.hmacKey)
+	h.Write(p.header)
+	var meta [9]byte
+	binary.LittleEndian.PutUint64(meta[:8], index)
+	if final {
+		meta[8] = 1
+	}
+	h.Write(meta[:])
+	h.Write(ciphertext)
+	return h.Sum(nil)
+}
+
+func (p *params) stream() (cipher.Stream, error) {
+	b, err := aes.NewCipher(p.aesKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewCTR(b, p.iv), nil
+}
+
+// NewEncryptReader returns an io.Reader wrapping the provided io.Reader.
+// It uses a user provided password and a random salt to derive keys.
+// If the key is provided interactively, it should be verified since there
+// is no recovery.
+func NewEncryptReader(r io.Reader, pass []byte) (io.Reader, error) {
+	salt, err := randBytes(saltSize)
+	if err != nil {
+		return nil, err
+	}
+	return encryptReaderWith(r, pass, salt, scryptIterations, ChunkSize)
+}
+
+// encryptReaderWith is NewEncryptReader with the salt, iterations and chunk size given.
+func encryptReaderWith(r io.Reader, pass, salt []byte, iterations int32, chunkSize int) (*encryptReader, error) {
+	iv, err := randBytes(blockSize)
+	if err != nil {
+		return nil, err
+	}
+	aesKey, hmacKey, err := keys(pass, salt, int(iterations))
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 4, HeaderSize)
+	binary.LittleEndian.PutUint32(header, uint32(iterations))
+	header = append(header, salt...)
+	header = append(header, iv...)
+	var size [4]byte
+	binary.LittleEndian.PutUint32(size[:], uint32(chunkSize))
+	header = append(header, size[:]...)
+
+	p := &params{aesKey: aesKey, hmacKey: hmacKey, iv: iv, chunkSize: chunkSize, header: header}
+	return newEncryptReader(r, p)
+}
+
+// encryptReader emits the header and then the ciphertext of each chunk followed by its HMAC.
+type encryptReader struct {
+	src    *bufio.Reader
+	params *params
+	stream cipher.Stream
+	index  uint64
+	out    bytes.Buffer
+	done   bool
+}
+
+func newEncryptReader(r io.Reader, p *params) (*encryptReader, error) {
+	stream, err := p.stream()
+	if err != nil {
+		return nil, err
+	}
+	er := &encryptReader{src: bufio.NewReaderSize(r, p.chunkSize), params: p, stream: stream}
+	er.out.Write(p.header)
+	return er, nil
+}
+
+func (er *encryptReader) Read(b []byte) (int, error) {
+	for er.out.Len() < 1 {
+		if er.done {
+			return 0, io.EOF
+		}
+		if err := er.sealChunk(); err != nil {
+			return 0, err
+		}
+	}
+	return er.out.Read(b)
+}
+
+func (er *encryptReader) sealChunk() error {
+	chunk := make([]byte, er.params.chunkSize)
+	n, err := io.ReadFull(er.src, chunk)
+	switch err {
+	case nil:
+		// A full chunk is the last one if nothing follows it.
+		if _, pErr := er.src.Peek(1); pErr == io.EOF {
+			er.done = true
+		} else if pErr != nil {
+			return pErr
+		}
+	case io.EOF, io.ErrUnexpectedEOF:
+		er.done = true
+	default:
+		return err
+	}
+
+	chunk = chunk[:n]
+	er.stream.XORKeyStream(chunk, chunk)
+	er.out.Write(chunk)
+	er.out.Write(er.params.chunkMAC(er.index, er.done, chunk))
+	er.index++
+	return nil
+}
+
+// decodeHeader decodes the header of the reader, deriving the keys from the password.
+func decodeHeader(r io.Reader, password []byte) (*params, error) {
+	header := make([]byte, HeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, TruncatedErr
+		}
+		return nil, err
+	}
+
+	iterations := int32(binary.LittleEndian.Uint32(header[:4]))
+	if iterations < 2 || iterations > maxScryptIterations || iterations&(iterations-1) != 0 {
+		return nil, DecryptErr
+	}
+	salt := header[4 : 4+saltSize]
+	iv := header[4+saltSize : 4+saltSize+blockSize]
+	chunkSize := binary.LittleEndian.Uint32(header[4+saltSize+blockSize:])
+	if chunkSize < 1 || chunkSize > 1<<30 {
+		return nil, DecryptErr
+	}
+
+	aesKey, hmacKey, err := keys(password, salt, int(iterations))
+	if err != nil {
+		return nil, err
+	}
+	return &params{aesKey: aesKey, hmacKey: hmacKey, iv: iv, chunkSize: int(chunkSize), header: header}, nil
+}
+
+// chunkReader reads the sealed chunks that follow a header, verifying each.
+type chunkReader struct {
+	src    *bufio.Reader
+	params *params
+	index  uint64
+	done   bool
+}
+
+func newChunkReader(r io.Reader, p *params) *chunkReader {
+	return &chunkReader{src: bufio.NewReaderSize(r, p.chunkSize+hmacSize), params: p}
+}
+
+// next returns the verified ciphertext of the next chunk, and io.EOF
+// once the last chunk was read. If the input ends without a last chunk,
+// TruncatedErr is returned.
+func (cr *chunkReader) next() ([]byte, error) {
+	if cr.done {
+		return nil, io.EOF
+	}
+
+	sealed := make([]byte, cr.params.chunkSize+hmacSize)
+	n, err := io.ReadFull(cr.src, sealed)
+	final := false
+	switch err {
+	case nil:
+		if _, pErr := cr.src.Peek(1); pErr == io.EOF {
+			final = true
+		} else if pErr != nil {
+			return nil, pErr
+		}
+	case io.EOF:
+		return nil, TruncatedErr
+	case io.ErrUnexpectedEOF:
+		final = true
+	default:
+		return nil, err
+	}
+
+	sealed = sealed[:n]
+	if n < hmacSize {
+		return nil, TruncatedErr
+	}
+	ciphertext, mac := sealed[:n-hmacSize], sealed[n-hmacSize:]
+	if !hmac.Equal(mac, cr.params.chunkMAC(cr.index, final, ciphertext)) {
+		// The chunk of a stream cut short at a chunk boundary was sealed as not being the last.
+		if final && hmac.Equal(mac, cr.params.chunkMAC(cr.index, false, ciphertext)) {
+			return nil, TruncatedErr
+		}
+		return nil, DecryptErr
+	}
+
+	cr.index++
+	cr.done = final
+	return ciphertext, nil
+}
+
+// decryptReader decrypts each chunk once it is verified.
+type decryptReader struct {
+	chunks *chunkReader
+	stream cipher.Stream
+	out    bytes.Reader
+	err    error
+}
+
+// NewDecryptReader creates an io.ReadCloser wrapping an io.Reader. Unlike
+// v1, it doesn't need to read the entire io.Reader first: each chunk is
+// verified before its plaintext is returned, and reading fails with
+// DecryptErr or TruncatedErr as soon as a chunk doesn't verify.
+func NewDecryptReader(r io.Reader, pass []byte) (io.ReadCloser, error) {
+	p, err := decodeHeader(r, pass)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := p.stream()
+	if err != nil {
+		return nil, err
+	}
+	return &decryptReader{chunks: newChunkReader(r, p), stream: stream}, nil
+}
+
+// Read implements io.Reader.
+func (d *decryptReader) Read(b []byte) (int, error) {
+	for d.out.Len() < 1 {
+		if d.err != nil {
+			return 0, d.err
+		}
+		ciphertext, err := d.chunks.next()
+		if err != nil {
+			d.err = err
+			continue
+		}
+		d.stream.XORKeyStream(ciphertext, ciphertext)
+		d.out.Reset(ciphertext)
+	}
+	return d.out.Read(b)
+}
+
+// Close implements io.Closer.
+func (d *decryptReader) Close() error {
+	return nil
+}
+
+// VerifyPrefix verifies the chunks of a prefix of a ciphertext, e.g the
+// part of an upload that was already sent, returning how many of its
+// bytes are verified, header included, and whether it holds the last
+// chunk. A trailing partial chunk isn't counted, so that an upload can
+// resume from the returned offset. Chunks that don't verify fail with
+// DecryptErr.
+func VerifyPrefix(r io.Reader, pass []byte) (verified int64, complete bool, err error) {
+	p, err := decodeHeader(r, pass)
+	if err == TruncatedErr {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	verified = int64(HeaderSize)
+
+	src := bufio.NewReaderSize(r, p.chunkSize+hmacSize)
+	for index := uint64(0); ; index++ {
+		sealed := make([]byte, p.chunkSize+hmacSize)
+		n, rErr := io.ReadFull(src, sealed)
+		if rErr != nil && rErr != io.ErrUnexpectedEOF {
+			if rErr == io.EOF {
+				rErr = nil
+			}
+			return verified, false, rErr
+		}
+
+		sealed = sealed[:n]
+		if n >= hmacSize {
+			ciphertext, mac := sealed[:n-hmacSize], sealed[n-hmacSize:]
+			final := hmac.Equal(mac, p.chunkMAC(index, true, ciphertext))
+			if final || hmac.Equal(mac, p.chunkMAC(index, false, ciphertext)) {
+				verified += int64(n)
+				if final {
+					return verified, true, nil
+				}
+				if rErr == nil {
+					continue
+				}
+				// Only the last chunk of a ciphertext can be short.
+				return verified, false, DecryptErr
+			}
+		}
+
+		if rErr == io.ErrUnexpectedEOF {
+			// A partial chunk, yet to be sent in full.
+			return verified, false, nil
+		}
+		return verified, false, DecryptErr
+	}
+}
+
+// Hash hashes the plaintext based on the header of the encrypted file and returns the hash Sum.
+func Hash(plainTextR io.Reader, headerR io.Reader, password []byte, h hash.Hash) ([]byte, error) {
+	p, err := decodeHeader(headerR, password)
+	if err != nil {
+		return nil, err
+	}
+	encReader, err := newEncryptReader(plainTextR, p)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(h, encReader); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
@@ -0,0 +1,244 @@
+// Copyright 2016 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v2
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+var password = []byte("test")
+var salt = make([]byte, saltSize)
+
+const testChunkSize = 1024
+
+func encrypt(t *testing.T, plain []byte) []byte {
+	encReader, err := encryptReaderWith(bytes.NewReader(plain), password, salt, 1024, testChunkSize)
+	if err != nil {
+		t.Fatalf("encryptReaderWith() => %q; want nil", err)
+	}
+	cipher, err := ioutil.ReadAll(encReader)
+	if err != nil {
+		t.Fatalf("ioutil.ReadAll(*encryptReader) => %q; want nil", err)
+	}
+	return cipher
+}
+
+func decrypt(cipher []byte) ([]byte, error) {
+	decReader, err := NewDecryptReader(bytes.NewReader(cipher), password)
+	if err != nil {
+		return nil, err
+	}
+	defer decReader.Close()
+	return ioutil.ReadAll(decReader)
+}
+
+// sealedChunk returns the offsets of the chunk at index in a ciphertext.
+func sealedChunk(index int) (start, end int) {
+	start = HeaderSize + index*(testChunkSize+hmacSize)
+	return start, start + testChunkSize + hmacSize
+}
+
+// TestRoundTrip tests several sizes of data, around the chunk size, going
+// through the encrypt/decrypt to make sure they come out the same.
+func TestRoundTrip(t *testing.T) {
+	sizes := []int{0, 1, 24, 1023, 1024, 1025, 2048, 4000, 15872, 16384}
+	for _, size := range sizes {
+		b, err := randBytes(size)
+		if err != nil {
+			t.Errorf("randBytes(%d) => %q; want nil", size, err)
+			continue
+		}
+		cipher := encrypt(t, b)
+		chunks := (size + testChunkSize - 1) / testChunkSize
+		if chunks < 1 {
+			chunks = 1
+		}
+		if want := HeaderSize + size + chunks*hmacSize; len(cipher) != want {
+			t.Errorf("size %d: len(cipher) = %d; want %d", size, len(cipher), want)
+		}
+		plain, err := decrypt(cipher)
+		if err != nil {
+			t.Errorf("size %d: decrypt() => %q; want nil", size, err)
+			continue
+		}
+		if !bytes.Equal(b, plain) {
+			t.Errorf("Encrypt/Decrypt of file size %d, resulted in different values", size)
+		}
+	}
+}
+
+func TestTampering(t *testing.T) {
+	b, err := randBytes(4 * testChunkSize)
+	if err != nil {
+		t.Fatalf("randBytes() => %q; want nil", err)
+	}
+	cipher := encrypt(t, b)
+	secondStart, secondEnd := sealedChunk(1)
+	_, lastEnd := sealedChunk(3)
+
+	flipped := append([]byte{}, cipher...)
+	flipped[secondStart+10] ^= 0x01
+
+	reordered := append([]byte{}, cipher[:secondStart]...)
+	reordered = append(reordered, cipher[secondEnd:lastEnd-testChunkSize-hmacSize]...)
+	reordered = append(reordered, cipher[secondStart:secondEnd]...)
+	reordered = append(reordered, cipher[lastEnd-testChunkSize-hmacSize:]...)
+
+	wrongPassword := func(cipher []byte) ([]byte, error) {
+		decReader, err := NewDecryptReader(bytes.NewReader(cipher), []byte("not test"))
+		if err != nil {
+			return nil, err
+		}
+		return ioutil.ReadAll(decReader)
+	}
+
+	testCases := []struct {
+		name    string
+		cipher  []byte
+		decrypt func([]byte) ([]byte, error)
+		want    error
+	}{
+		{name: "flipped bit", cipher: flipped, decrypt: decrypt, want: DecryptErr},
+		{name: "reordered chunks", cipher: reordered, decrypt: decrypt, want: DecryptErr},
+		{name: "truncated at a chunk", cipher: cipher[:secondEnd], decrypt: decrypt, want: TruncatedErr},
+		{name: "truncated after the header", cipher: cipher[:HeaderSize], decrypt: decrypt, want: TruncatedErr},
+		{name: "truncated header", cipher: cipher[:HeaderSize-1], decrypt: decrypt, want: TruncatedErr},
+		{name: "truncated in a chunk", cipher: cipher[:secondEnd+100], decrypt: decrypt, want: DecryptErr},
+		{name: "wrong password", cipher: cipher, decrypt: wrongPassword, want: DecryptErr},
+	}
+
+	for _, tc := range testCases {
+		plain, err := tc.decrypt(tc.cipher)
+		if err != tc.want {
+			t.Errorf("%s: err = %v; want %v", tc.name, err, tc.want)
+		}
+		// Nothing past the bad chunk may have been released.
+		if len(plain) > 0 && !bytes.HasPrefix(b, plain) {
+			t.Errorf("%s: released plaintext that doesn't match", tc.name)
+		}
+	}
+}
+
+func TestVerifyPrefix(t *testing.T) {
+	b, err := randBytes(3*testChunkSize + 100)
+	if err != nil {
+		t.Fatalf("randBytes() => %q; want nil", err)
+	}
+	cipher := encrypt(t, b)
+	_, firstEnd := sealedChunk(0)
+	_, secondEnd := sealedChunk(1)
+
+	flipped := append([]byte{}, cipher[:secondEnd]...)
+	flipped[secondEnd-1] ^= 0x01
+
+	testCases := []struct {
+		name         string
+		prefix       []byte
+		wantVerified int64
+		wantComplete bool
+		wantErr      error
+	}{
+		{name: "empty", prefix: nil},
+		{name: "partial header", prefix: cipher[:10]},
+		{name: "header", prefix: cipher[:HeaderSize], wantVerified: int64(HeaderSize)},
+		{name: "partial chunk", prefix: cipher[:firstEnd-1], wantVerified: int64(HeaderSize)},
+		{name: "one chunk", prefix: cipher[:firstEnd], wantVerified: int64(firstEnd)},
+		{name: "chunk and a half", prefix: cipher[:firstEnd+600], wantVerified: int64(firstEnd)},
+		{name: "two chunks", prefix: cipher[:secondEnd], wantVerified: int64(secondEnd)},
+		{name: "complete", prefix: cipher, wantVerified: int64(len(cipher)), wantComplete: true},
+		{name: "tampered", prefix: flipped, wantVerified: int64(firstEnd), wantErr: DecryptErr},
+	}
+
+	for _, tc := range testCases {
+		verified, complete, err := VerifyPrefix(bytes.NewReader(tc.prefix), password)
+		if err != tc.wantErr {
+			t.Errorf("%s: err = %v; want %v", tc.name, err, tc.wantErr)
+		}
+		if verified != tc.wantVerified {
+			t.Errorf("%s: verified = %d; want %d", tc.name, verified, tc.wantVerified)
+		}
+		if complete != tc.wantComplete {
+			t.Errorf("%s: complete = %v; want %v", tc.name, complete, tc.wantComplete)
+		}
+	}
+}
+
+func TestHeaderIterations(t *testing.T) {
+	cipher := encrypt(t, []byte("content"))
+	for _, iterations := range []uint32{0, 1, 1000, uint32(maxScryptIterations) << 1, 1 << 31} {
+		crafted := append([]byte{}, cipher...)
+		binary.LittleEndian.PutUint32(crafted[:4], iterations)
+		if _, err := decrypt(crafted); err != DecryptErr {
+			t.Errorf("decrypt with %d iterations: err = %v; want %v", iterations, err, DecryptErr)
+		}
+		if _, _, err := VerifyPrefix(bytes.NewReader(crafted), password); err != DecryptErr {
+			t.Errorf("VerifyPrefix with %d iterations: err = %v; want %v", iterations, err, DecryptErr)
+		}
+	}
+}
+
+func TestSizes(t *testing.T) {
+	sizes := []int64{0, 1, ChunkSize - 1, ChunkSize, ChunkSize + 1, 3 * ChunkSize, 1 << 32}
+	for _, size := range sizes {
+		cipherSize := CiphertextSize(size)
+		if got := PlaintextSize(cipherSize); got != size {
+			t.Errorf("PlaintextSize(CiphertextSize(%d)) = %d; want %d", size, got, size)
+		}
+	}
+
+	invalid := []int64{0, int64(HeaderSize), int64(HeaderSize + hmacSize - 1), CiphertextSize(ChunkSize) + 1}
+	for _, cipherSize := range invalid {
+		if got := PlaintextSize(cipherSize); got != -1 {
+			t.Errorf("PlaintextSize(%d) = %d; want -1", cipherSize, got)
+		}
+	}
+}
+
+func TestHash(t *testing.T) {
+	sizes := []int{0, 24, 1024, 15872, 16384, 16394}
+	for _, size := range sizes {
+		h := sha256.New()
+		b, err := randBytes(size)
+		if err != nil {
+			t.Errorf("randBytes(%d) => %q; want nil", size, err)
+			continue
+		}
+		encReader, err := encryptReaderWith(bytes.NewReader(b), password, salt, 1024, testChunkSize)
+		if err != nil {
+			t.Errorf("encryptReaderWith() => %q; want nil", err)
+			continue
+		}
+		cipher, err := ioutil.ReadAll(io.TeeReader(encReader, h))
+		if err != nil {
+			t.Errorf("ioutil.ReadAll(*encryptReader) => %q; want nil", err)
+			continue
+		}
+		want := h.Sum(nil)
+		h.Reset()
+		got, err := Hash(bytes.NewReader(b), bytes.NewReader(cipher[0:HeaderSize]), password, h)
+		if err != nil {
+			t.Errorf("Hash() => err = %q; want nil", err)
+			continue
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("Hash() => %v; want %v", got, want)
+		}
+	}
+}
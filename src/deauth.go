@@ -0,0 +1,107 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// RevokeURL is Google's OAuth 2.0 token revocation endpoint.
+var RevokeURL = "https://oauth2.googleapis.com/revoke"
+
+// revokeToken revokes token, and with it the access that it granted.
+// A token that is already invalid e.g revoked from the web UI is
+// reported through alreadyInvalid rather than as an error.
+func revokeToken(client *http.Client, token string) (alreadyInvalid bool, err error) {
+	res, err := client.PostForm(RevokeURL, url.Values{"token": {token}})
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusOK {
+		return false, nil
+	}
+
+	body, _ := ioutil.ReadAll(res.Body)
+	var revokeErr struct {
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if json.Unmarshal(body, &revokeErr) == nil && revokeErr.Error == "invalid_token" {
+		return true, nil
+	}
+
+	msg := strings.TrimSpace(revokeErr.ErrorDescription)
+	if msg == "" {
+		msg = strings.TrimSpace(string(body))
+	}
+	return false, fmt.Errorf("revoke: %s: %s", res.Status, msg)
+}
+
+// DeAuth revokes the refresh token of the account in use and removes its
+// credentials from the context, keeping the context's files and index.
+// Service accounts have nothing to revoke, their keys are deleted in the
+// Google API console, so only their credentials are removed.
+func (g *Commands) DeAuth() error {
+	creds := g.context.Credentials
+	if creds.IsApplicationDefault() {
+		return illogicalStateErr(fmt.Errorf("the Application Default Credentials aren't the context's own, there is nothing to deauthorize"))
+	}
+
+	account := "the default account"
+	if g.context.Remote != "" {
+		account = fmt.Sprintf("remote %q, with its account %q,", g.context.Remote, g.context.Account)
+	} else if g.context.Account != "" {
+		account = fmt.Sprintf("account %q", g.context.Account)
+	}
+	if g.opts.canPrompt() {
+		status := promptForChanges(fmt.Sprintf("Revoke and remove the credentials of %s? [Y/n]: ", account))
+		if !accepted(status) {
+			return status.Error()
+		}
+	}
+
+	switch {
+	case creds.IsServiceAccount():
+		g.log.Logln("Service account keys can't be revoked here, delete them in the Google API console if need be")
+	case creds.RefreshToken == "":
+		g.log.LogErrf("%s holds no refresh token to revoke\n", account)
+	default:
+		alreadyInvalid, err := revokeToken(http.DefaultClient, creds.RefreshToken)
+		if err != nil {
+			return err
+		}
+		if alreadyInvalid {
+			g.log.Logln("The refresh token was already revoked or expired")
+		} else {
+			g.log.Logln("Revoked the refresh token")
+		}
+	}
+
+	if err := g.context.Deauthorize(); err != nil {
+		return err
+	}
+	if err := g.context.Write(); err != nil {
+		return err
+	}
+	g.log.Logf("Removed the credentials of %s\n", account)
+	return nil
+}
@@ -0,0 +1,60 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRevokeToken(t *testing.T) {
+	cases := []struct {
+		status         int
+		body           string
+		alreadyInvalid bool
+		wantErr        bool
+	}{
+		{status: http.StatusOK, body: "{}"},
+		{status: http.StatusBadRequest, body: `{"error": "invalid_token", "error_description": "Token expired or revoked"}`, alreadyInvalid: true},
+		{status: http.StatusBadRequest, body: `{"error": "invalid_request", "error_description": "Bad Request"}`, wantErr: true},
+		{status: http.StatusServiceUnavailable, body: "unavailable", wantErr: true},
+	}
+
+	defer func(u string) { RevokeURL = u }(RevokeURL)
+
+	for i, tc := range cases {
+		var gotToken string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			gotToken = r.PostFormValue("token")
+			w.WriteHeader(tc.status)
+			w.Write([]byte(tc.body))
+		}))
+		RevokeURL = server.URL
+
+		alreadyInvalid, err := revokeToken(http.DefaultClient, "1/refresh-token")
+		server.Close()
+
+		if gotToken != "1/refresh-token" {
+			t.Errorf("#%d: revoked token %q", i, gotToken)
+		}
+		if tc.wantErr != (err != nil) {
+			t.Errorf("#%d: wantErr %v got %v", i, tc.wantErr, err)
+		}
+		if alreadyInvalid != tc.alreadyInvalid {
+			t.Errorf("#%d: alreadyInvalid got %v want %v", i, alreadyInvalid, tc.alreadyInvalid)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"path/filepath"
+	"sync"
+)
+
+// uploadDedup uploads content that appears at several paths of a push
+// once, and then copies it server side to the rest of the paths.
+type uploadDedup struct {
+	// duplicates maps the index of each duplicate addition
+	// to that of the addition whose upload it copies.
+	duplicates map[int]int
+	primaries  map[int]bool
+
+	mu       sync.Mutex
+	uploaded map[int]*File
+}
+
+func dedupable(c *Change) bool {
+	return c != nil && c.Dest == nil && c.Src != nil && !c.Src.IsDir &&
+		c.Src.Size > 0 && c.Src.BlobAt != "" && c.Op() == OpAdd
+}
+
+// newUploadDedup finds the file additions with identical content,
+// checksummed from the checksum cache when it is fresh.
+func (g *Commands) newUploadDedup(cl []*Change) *uploadDedup {
+	rootAbsPath := g.context.AbsPathOf("")
+	checksum := func(src *File) string {
+		if relToRoot, err := filepath.Rel(rootAbsPath, src.BlobAt); err == nil {
+			g.seedCachedChecksum(src, relToRoot)
+		}
+		return md5Checksum(src)
+	}
+
+	ud := &uploadDedup{
+		duplicates: duplicateAdditions(cl, checksum),
+		primaries:  make(map[int]bool),
+		uploaded:   make(map[int]*File),
+	}
+	// Copies of a split file's manifest would share its parts, so
+	// split files are always uploaded. Duplicates have the same size
+	// so either both or neither of a pair is split.
+	for dup := range ud.duplicates {
+		if g.shouldSplit(cl[dup]) {
+			delete(ud.duplicates, dup)
+		}
+	}
+	for _, primary := range ud.duplicates {
+		ud.primaries[primary] = true
+	}
+	return ud
+}
+
+// duplicateAdditions maps the index of each file addition whose content is
+// that of an earlier addition to the index of that addition. Only additions
+// whose sizes collide are checksummed. Names must also share an extension
+// since it determines the mimeType of an upload.
+func duplicateAdditions(cl []*Change, checksum func(*File) string) map[int]int {
+	var sizes []int64
+	bySize := make(map[int64][]int)
+	for i, c := range cl {
+		if !dedupable(c) {
+			continue
+		}
+		size := c.Src.Size
+		if _, ok := bySize[size]; !ok {
+			sizes = append(sizes, size)
+		}
+		bySize[size] = append(bySize[size], i)
+	}
+
+	duplicates := make(map[int]int)
+	for _, size := range sizes {
+		indices := bySize[size]
+		if len(indices) < 2 {
+			continue
+		}
+
+		firsts := make(map[string]int)
+		for _, i := range indices {
+			src := cl[i].Src
+			sum := checksum(src)
+			if sum == "" {
+				continue
+			}
+
+			key := sum + filepath.Ext(src.Name)
+			if first, ok := firsts[key]; ok {
+				duplicates[i] = first
+			} else {
+				firsts[key] = i
+			}
+		}
+	}
+
+	return duplicates
+}
+
+// dependencies makes each duplicate wait for the upload that it copies.
+func (ud *uploadDedup) dependencies(deps [][]int) [][]int {
+	for i, primary := range ud.duplicates {
+		deps[i] = append(deps[i], primary)
+	}
+	return deps
+}
+
+// changer returns the function that plays the ith change, given fn which
+// would otherwise play it.
+func (ud *uploadDedup) changer(g *Commands, i int, fn func(*Change) error) func(*Change) error {
+	if primary, ok := ud.duplicates[i]; ok {
+		return func(c *Change) error {
+			ud.mu.Lock()
+			uploaded := ud.uploaded[primary]
+			ud.mu.Unlock()
+
+			if uploaded == nil {
+				// The upload failed, so upload this one itself.
+				return fn(c)
+			}
+			return g.remoteCopyOf(c, uploaded)
+		}
+	}
+
+	if ud.primaries[i] {
+		return func(c *Change) error {
+			c.uploaded = func(rem *File) {
+				ud.mu.Lock()
+				ud.uploaded[i] = rem
+				ud.mu.Unlock()
+			}
+			return fn(c)
+		}
+	}
+
+	return fn
+}
+
+// remoteCopyOf adds change's file by copying uploaded, whose content
+// is identical, instead of uploading it.
+func (g *Commands) remoteCopyOf(change *Change, uploaded *File) error {
+	defer g.taskAdd(change.Src.Size)
+
+	parent, parentPath, err := g.remoteMkdirParent(change.Path)
+	if err != nil {
+		g.log.LogErrf("remoteCopyOf/remoteMkdirAll: `%s` got %v\n", parentPath, err)
+		return err
+	}
+	if parent == nil {
+		return errCannotMkdirAll(parentPath)
+	}
+
+	// Keep the local modTime so that the copy isn't seen as modified.
+	src := *uploaded
+	src.ModTime = change.Src.ModTime
+
+	rem, err := g.rem.copy(change.Src.Name, parent.Id, &src)
+	if err != nil {
+		g.log.LogErrf("%s: %v\n", change.Path, err)
+		return err
+	}
+
+	if wErr := g.context.SerializeIndex(rem.ToIndex()); wErr != nil {
+		g.log.LogErrf("serializeIndex %s: %v\n", rem.Name, wErr)
+	}
+	// The copy has the content of uploaded, encrypted under the same key.
+	g.notePushed(change, rem)
+	return nil
+}
@@ -0,0 +1,92 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"path"
+	"reflect"
+	"testing"
+)
+
+func TestDuplicateAdditions(t *testing.T) {
+	local := func(p string, size int64, md5 string) *File {
+		return &File{Name: path.Base(p), Size: size, BlobAt: "/ctx" + p, Md5Checksum: md5}
+	}
+
+	cl := []*Change{
+		{Path: "/a/lib.so", Src: local("/a/lib.so", 10, "aaa")},                // 0
+		{Path: "/b/lib.so", Src: local("/b/lib.so", 10, "aaa")},                // 1: copies 0
+		{Path: "/c/lib.so", Src: local("/c/lib.so", 10, "bbb")},                // 2: same size, other content
+		{Path: "/d/lib.bin", Src: local("/d/lib.bin", 10, "aaa")},              // 3: same content, other extension
+		{Path: "/e/lib.so", Src: local("/e/lib.so", 10, "aaa")},                // 4: copies 0
+		{Path: "/f/unique.txt", Src: local("/f/unique.txt", 20, "ccc")},        // 5: unique size
+		{Path: "/g/empty.txt", Src: local("/g/empty.txt", 0, "d41d8")},         // 6: empty
+		{Path: "/h/empty.txt", Src: local("/h/empty.txt", 0, "d41d8")},         // 7: empty
+		{Path: "/i/lib.so", Src: local("/i/lib.so", 10, "aaa"), Dest: &File{}}, // 8: a modification
+		{Path: "/j", Src: &File{Name: "j", IsDir: true}},                       // 9: a folder
+		{Path: "/k/lib.bin", Src: local("/k/lib.bin", 10, "aaa")},              // 10: copies 3
+		{Path: "/l/unhashable.so", Src: local("/l/unhashable.so", 10, "")},     // 11
+		{Path: "/m/unhashable.so", Src: local("/m/unhashable.so", 10, "")},     // 12
+		nil,
+	}
+
+	checksummed := make(map[string]int)
+	checksum := func(f *File) string {
+		checksummed[f.BlobAt]++
+		return f.Md5Checksum
+	}
+
+	want := map[int]int{1: 0, 4: 0, 10: 3}
+	if got := duplicateAdditions(cl, checksum); !reflect.DeepEqual(got, want) {
+		t.Errorf("got duplicates %v want %v", got, want)
+	}
+
+	if n := checksummed["/ctx/f/unique.txt"]; n != 0 {
+		t.Errorf("unique sizes shouldn't be checksummed, got %d checksums", n)
+	}
+	for p, n := range checksummed {
+		if n > 1 {
+			t.Errorf("%s: checksummed %d times", p, n)
+		}
+	}
+}
+
+func TestUploadDedupPrimaryPlaysThroughFn(t *testing.T) {
+	ud := &uploadDedup{
+		duplicates: map[int]int{1: 0},
+		primaries:  map[int]bool{0: true},
+		uploaded:   make(map[int]*File),
+	}
+
+	rem := &File{Id: "remote-id"}
+	played := 0
+	fn := func(c *Change) error {
+		played++
+		if c.uploaded != nil {
+			c.uploaded(rem)
+		}
+		return nil
+	}
+
+	if err := ud.changer(nil, 0, fn)(&Change{Path: "/a/lib.so"}); err != nil {
+		t.Fatalf("primary: %v", err)
+	}
+	if played != 1 {
+		t.Errorf("primary should be played through fn, played %d times", played)
+	}
+	if got := ud.uploaded[0]; got != rem {
+		t.Errorf("primary upload not recorded, got %v", got)
+	}
+}
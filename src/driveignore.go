@@ -0,0 +1,267 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const (
+	// DriveIgnoreSyntaxPrefix starts the lines of a .driveignore that
+	// switch the syntax of the clauses after them, as `syntax: gitignore`
+	// or `syntax: regexp`.
+	DriveIgnoreSyntaxPrefix = "syntax:"
+
+	ignoreSyntaxRegexp    = "regexp"
+	ignoreSyntaxGitignore = "gitignore"
+)
+
+// gitignoreRule is a single gitignore pattern of a .driveignore.
+type gitignoreRule struct {
+	re *regexp.Regexp
+	// negate re-includes what earlier rules excluded.
+	negate bool
+	// dirOnly rules, ending in "/", only match directories.
+	dirOnly bool
+	// anchored rules contain a "/" and match the path relative to the
+	// directory of their .driveignore, others match the base name.
+	anchored bool
+}
+
+func (gr *gitignoreRule) match(relPath string, isDir func() bool) bool {
+	target := relPath
+	if !gr.anchored {
+		target = path.Base(relPath)
+	}
+	if !gr.re.MatchString(target) {
+		return false
+	}
+	return !gr.dirOnly || isDir()
+}
+
+// splitIgnoreSyntax splits the clauses of a .driveignore into those
+// written as regular expressions and those written as gitignore patterns,
+// starting off with defaultSyntax until a `syntax:` line switches it.
+func splitIgnoreSyntax(clauses []string, defaultSyntax string) (regexps, gitignores []string, err error) {
+	syntax := defaultSyntax
+	for _, clause := range clauses {
+		if strings.HasPrefix(clause, DriveIgnoreSyntaxPrefix) {
+			syntax = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(clause, DriveIgnoreSyntaxPrefix)))
+			if syntax != ignoreSyntaxRegexp && syntax != ignoreSyntaxGitignore {
+				return nil, nil, invalidArgumentsErr(
+					fmt.Errorf("unknown .driveignore syntax %q, expecting %q or %q", syntax, ignoreSyntaxRegexp, ignoreSyntaxGitignore))
+			}
+			continue
+		}
+
+		if syntax == ignoreSyntaxGitignore {
+			gitignores = append(gitignores, clause)
+		} else {
+			regexps = append(regexps, clause)
+		}
+	}
+	return regexps, gitignores, nil
+}
+
+// parseGitignoreRules compiles gitignore patterns into rules.
+func parseGitignoreRules(patterns []string) (rules []*gitignoreRule, err error) {
+	for _, pattern := range patterns {
+		rule := &gitignoreRule{}
+		if strings.HasPrefix(pattern, "!") {
+			rule.negate = true
+			pattern = pattern[1:]
+		} else if strings.HasPrefix(pattern, "\\!") || strings.HasPrefix(pattern, "\\#") {
+			pattern = pattern[1:]
+		}
+
+		if strings.HasSuffix(pattern, "/") {
+			rule.dirOnly = true
+			pattern = strings.TrimRight(pattern, "/")
+		}
+		if pattern == "" {
+			continue
+		}
+
+		if strings.Contains(pattern, "/") {
+			rule.anchored = true
+			pattern = strings.TrimPrefix(pattern, "/")
+		}
+
+		re, reErr := regexp.Compile("^" + gitignoreGlobToRegexp(pattern) + "$")
+		if reErr != nil {
+			err = combineErrors(err, makeErrorWithStatus("gitignoreErr", reErr, StatusIllogicalState))
+			continue
+		}
+		rule.re = re
+		rules = append(rules, rule)
+	}
+	return rules, err
+}
+
+// gitignoreGlobToRegexp translates a gitignore glob into a regular expression:
+// "*" and "?" don't match "/", while "**" spans directories.
+func gitignoreGlobToRegexp(glob string) string {
+	var buf strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/") && (i == 0 || glob[i-1] == '/'):
+			buf.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**") && i+2 == len(glob) && (i == 0 || glob[i-1] == '/'):
+			buf.WriteString(".*")
+			i++
+		case c == '*':
+			buf.WriteString("[^/]*")
+		case c == '?':
+			buf.WriteString("[^/]")
+		case c == '\\' && i+1 < len(glob):
+			i++
+			buf.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				buf.WriteString("\\[")
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			buf.WriteString("[" + strings.Replace(class, "\\", "\\\\", -1) + "]")
+			i += end + 1
+		default:
+			buf.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return buf.String()
+}
+
+// driveIgnore applies the gitignore patterns of the .driveignore files of
+// a context, at its root and in any directory below it, the deepest file
+// taking precedence. The nested files are read as they're first needed.
+type driveIgnore struct {
+	root string
+
+	mu    sync.Mutex
+	rules map[string][]*gitignoreRule
+}
+
+func newDriveIgnore(root string, rootRules []*gitignoreRule) *driveIgnore {
+	return &driveIgnore{
+		root:  root,
+		rules: map[string][]*gitignoreRule{"": rootRules},
+	}
+}
+
+// rulesOf returns the rules of the .driveignore in the directory relDir.
+// Unlike the root one, nested files only ever hold gitignore patterns.
+func (di *driveIgnore) rulesOf(relDir string) []*gitignoreRule {
+	di.mu.Lock()
+	defer di.mu.Unlock()
+
+	if rules, ok := di.rules[relDir]; ok {
+		return rules
+	}
+
+	ignoresPath := filepath.Join(di.root, filepath.FromSlash(relDir), DriveIgnoreSuffix)
+	clauses, err := readCommentedFile(ignoresPath, "#")
+	if err != nil && !os.IsNotExist(err) {
+		DebugPrintf("reading %s: %v", ignoresPath, err)
+	}
+	_, patterns, err := splitIgnoreSyntax(clauses, ignoreSyntaxGitignore)
+	if err == nil {
+		var rules []*gitignoreRule
+		rules, err = parseGitignoreRules(patterns)
+		di.rules[relDir] = rules
+	}
+	if err != nil {
+		DebugPrintf("%s: %v", ignoresPath, err)
+	}
+	return di.rules[relDir]
+}
+
+// relPath resolves what an ignorer is asked about into a slash separated
+// path relative to the root. Paths are either absolute or relative to
+// the root and a trailing "/" marks a directory. A bare name has no
+// known location, reported by ok being false.
+func (di *driveIgnore) relPath(p string) (rel string, isDir, ok bool) {
+	p = filepath.ToSlash(p)
+	isDir = strings.HasSuffix(p, "/") && len(p) > 1
+	p = strings.TrimRight(p, "/")
+
+	root := filepath.ToSlash(di.root)
+	if root != "" && (p == root || strings.HasPrefix(p, root+"/")) {
+		p = strings.TrimPrefix(p, root)
+	} else if !strings.Contains(p, "/") {
+		return p, isDir, false
+	}
+	return strings.Trim(path.Clean(p), "/"), isDir, true
+}
+
+// ignored reports whether p is excluded. As with git, once a directory
+// is excluded nothing within it can be re-included.
+func (di *driveIgnore) ignored(p string) bool {
+	rel, hintedDir, ok := di.relPath(p)
+	if !ok || rel == "" || rel == "." {
+		// Without a location, a nested .driveignore could re-include it.
+		return false
+	}
+
+	isDirOf := func(relPath string, hinted bool) func() bool {
+		return func() bool {
+			if hinted {
+				return true
+			}
+			fi, err := os.Stat(filepath.Join(di.root, filepath.FromSlash(relPath)))
+			return err == nil && fi.IsDir()
+		}
+	}
+
+	segments := strings.Split(rel, "/")
+	for i := range segments {
+		subPath := strings.Join(segments[:i+1], "/")
+		last := i == len(segments)-1
+		isDir := isDirOf(subPath, !last || hintedDir)
+
+		excluded := false
+		for depth := 0; depth <= i; depth++ {
+			relDir := strings.Join(segments[:depth], "/")
+			relToDir := strings.Join(segments[depth:i+1], "/")
+			excluded = matchRules(di.rulesOf(relDir), relToDir, isDir, excluded)
+		}
+		if excluded {
+			return true
+		}
+	}
+	return false
+}
+
+// matchRules applies rules in order to relPath, the last match deciding
+// whether it is excluded, starting from excluded.
+func matchRules(rules []*gitignoreRule, relPath string, isDir func() bool, excluded bool) bool {
+	for _, rule := range rules {
+		if rule.match(relPath, isDir) {
+			excluded = !rule.negate
+		}
+	}
+	return excluded
+}
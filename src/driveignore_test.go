@@ -0,0 +1,156 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGitignoreRules(t *testing.T) {
+	testCases := []struct {
+		pattern    string
+		ignored    []string
+		notIgnored []string
+	}{
+		{pattern: "*.o", ignored: []string{"a.o", "src/b.o"}, notIgnored: []string{"a.oo", "o", "a.o.c"}},
+		{pattern: "node_modules/", ignored: []string{"node_modules/", "web/node_modules/"}, notIgnored: []string{"node_modules", "web/node_modules.txt"}},
+		{pattern: "/build", ignored: []string{"build", "build/"}, notIgnored: []string{"src/build", "builds"}},
+		{pattern: "doc/*.txt", ignored: []string{"doc/a.txt"}, notIgnored: []string{"doc/sub/a.txt", "x/doc/a.txt"}},
+		{pattern: "**/logs", ignored: []string{"logs", "a/b/logs"}, notIgnored: []string{"logs2"}},
+		{pattern: "a/**/b", ignored: []string{"a/b", "a/x/b", "a/x/y/b"}, notIgnored: []string{"a/x/c", "b"}},
+		{pattern: "cache/**", ignored: []string{"cache/x", "cache/x/y"}, notIgnored: []string{"cache", "cached/x"}},
+		{pattern: "*~", ignored: []string{"notes.txt~", "a/.b~"}, notIgnored: []string{"notes.txt"}},
+		{pattern: ".*.sw[op]", ignored: []string{".a.swp", "x/.b.swo"}, notIgnored: []string{".a.swx", "a.swp"}},
+		{pattern: "file?.[!c]", ignored: []string{"file1.h"}, notIgnored: []string{"file1.c", "file12.h", "file/.h"}},
+		{pattern: "\\#notes", ignored: []string{"#notes"}, notIgnored: []string{"notes"}},
+	}
+
+	for _, tc := range testCases {
+		rules, err := parseGitignoreRules([]string{tc.pattern})
+		if err != nil {
+			t.Errorf("%q: err %v", tc.pattern, err)
+			continue
+		}
+
+		check := func(p string) bool {
+			isDir := func() bool { return strings.HasSuffix(p, "/") }
+			return matchRules(rules, strings.TrimRight(p, "/"), isDir, false)
+		}
+		for _, p := range tc.ignored {
+			if !check(p) {
+				t.Errorf("%q: %q must be ignored", tc.pattern, p)
+			}
+		}
+		for _, p := range tc.notIgnored {
+			if check(p) {
+				t.Errorf("%q: %q must not be ignored", tc.pattern, p)
+			}
+		}
+	}
+}
+
+func TestSplitIgnoreSyntax(t *testing.T) {
+	clauses := []string{"\\.swp$", "syntax: gitignore", "node_modules/", "*.o", "syntax:regexp", "_export$"}
+	regexps, gitignores, err := splitIgnoreSyntax(clauses, ignoreSyntaxRegexp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(regexps, " "), "\\.swp$ _export$"; got != want {
+		t.Errorf("regexps: got %q want %q", got, want)
+	}
+	if got, want := strings.Join(gitignores, " "), "node_modules/ *.o"; got != want {
+		t.Errorf("gitignores: got %q want %q", got, want)
+	}
+
+	if _, _, err := splitIgnoreSyntax([]string{"syntax: glob"}, ignoreSyntaxRegexp); err == nil {
+		t.Errorf("expected an error for an unknown syntax")
+	}
+}
+
+func TestCombineIgnoresNested(t *testing.T) {
+	root, err := ioutil.TempDir("", "driveignore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	files := map[string]string{
+		DriveIgnoreSuffix:                          "\\.swp$\nsyntax: gitignore\nnode_modules/\n*.o\n/dist\n",
+		filepath.Join("src", DriveIgnoreSuffix):    "!keep.o\ngenerated/\n",
+		filepath.Join("src", "keep.o"):             "",
+		filepath.Join("src", "main.o"):             "",
+		filepath.Join("src", "node_modules", "x"):  "",
+		filepath.Join("src", "generated", "a.go"):  "",
+		filepath.Join("lib", "dist", "index.js"):   "",
+		filepath.Join("dist", "index.js"):          "",
+		filepath.Join("dist", DriveIgnoreSuffix):   "!index.js\n",
+		filepath.Join("web", "node_modules", "y"):  "",
+		filepath.Join("web", DriveIgnoreSuffix):    "!node_modules/\n",
+		filepath.Join("notes", "draft.txt.swp"):    "",
+		filepath.Join("notes", "node_modules.txt"): "",
+	}
+	for relPath, content := range files {
+		p := filepath.Join(root, relPath)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ignorer, err := combineIgnores(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ignored := []string{
+		"/src/main.o",
+		"/src/node_modules",
+		"/src/node_modules/x",
+		filepath.Join(root, "src", "generated"),
+		"/src/generated/a.go",
+		"/dist",
+		// An excluded directory can't have anything in it re-included.
+		"/dist/index.js",
+		"/notes/draft.txt.swp",
+		"/remote-only/node_modules/",
+	}
+	notIgnored := []string{
+		"/src",
+		"/src/keep.o",
+		filepath.Join(root, "src", "keep.o"),
+		"/lib/dist/index.js",
+		"/notes/node_modules.txt",
+		"/web/node_modules/y",
+		"/remote-only/node_modules",
+		"main.o",
+	}
+
+	for _, p := range ignored {
+		if !ignorer(p) {
+			t.Errorf("%q must be ignored", p)
+		}
+	}
+	for _, p := range notIgnored {
+		if ignorer(p) {
+			t.Errorf("%q must not be ignored", p)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/odeke-em/drive/src/dcrypto"
+)
+
+var ErrCiphertextNotVerified = errors.New("the encrypted content already sent doesn't verify, not resending it")
+
+// ciphertextSpool encrypts the content of an upload once, keeping what
+// was read of the ciphertext in a temporary file. An upload retried after
+// part of it was sent then resends that very ciphertext and goes on from
+// where the encryption stopped, rather than encrypting anew what is left
+// of the plaintext. Before it is resent, the spooled ciphertext is checked
+// to be whole and, for encrypters that can, verified chunk by chunk.
+type ciphertextSpool struct {
+	encrypted io.Reader
+	verifier  dcrypto.PrefixVerifier
+	file      *os.File
+	size      int64
+}
+
+// newCiphertextSpool spools the content read from encrypted into a
+// temporary file in dir. Removing the file is up to close.
+func newCiphertextSpool(encrypted io.Reader, dir string) (*ciphertextSpool, error) {
+	file, err := ioutil.TempFile(dir, "upload-ciphertext")
+	if err != nil {
+		return nil, err
+	}
+	cs := &ciphertextSpool{encrypted: encrypted, file: file}
+	cs.verifier, _ = encrypted.(dcrypto.PrefixVerifier)
+	return cs, nil
+}
+
+// Write appends what was read of the ciphertext to the spool.
+func (cs *ciphertextSpool) Write(p []byte) (int, error) {
+	n, err := cs.file.WriteAt(p, cs.size)
+	cs.size += int64(n)
+	return n, err
+}
+
+// reader returns what an attempt at the upload reads: the ciphertext
+// spooled by earlier attempts, once verified, then the rest of it.
+func (cs *ciphertextSpool) reader() (io.Reader, error) {
+	if err := cs.verify(); err != nil {
+		return nil, err
+	}
+	return io.MultiReader(io.NewSectionReader(cs.file, 0, cs.size), io.TeeReader(cs.encrypted, cs)), nil
+}
+
+// verify fails with ErrCiphertextNotVerified if the spool was truncated,
+// added to or tampered with since it was written.
+func (cs *ciphertextSpool) verify() error {
+	fi, err := cs.file.Stat()
+	if err != nil {
+		return err
+	}
+	if fi.Size() != cs.size {
+		return ErrCiphertextNotVerified
+	}
+	if cs.verifier == nil || cs.size < 1 {
+		return nil
+	}
+
+	verified, complete, err := cs.verifier.VerifyPrefix(io.NewSectionReader(cs.file, 0, cs.size))
+	if err != nil {
+		return ErrCiphertextNotVerified
+	}
+	// All but the chunk that was being read when the attempt failed must verify.
+	if !complete && cs.size-verified >= int64(dcrypto.VerifiablePrefixSize) {
+		return ErrCiphertextNotVerified
+	}
+	return nil
+}
+
+func (cs *ciphertextSpool) close() error {
+	defer os.Remove(cs.file.Name())
+	return cs.file.Close()
+}
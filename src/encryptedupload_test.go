@@ -0,0 +1,129 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/odeke-em/drive/src/dcrypto"
+)
+
+var spoolPassword = []byte("spool")
+
+// partlySentSpool returns a spool of the encryption of plain from which
+// a failed attempt at the upload read sent bytes.
+func partlySentSpool(t *testing.T, dir string, plain []byte, sent int) *ciphertextSpool {
+	encR, err := dcrypto.NewEncrypter(bytes.NewReader(plain), spoolPassword)
+	if err != nil {
+		t.Fatalf("encrypter: %v", err)
+	}
+	spool, err := newCiphertextSpool(encR, dir)
+	if err != nil {
+		t.Fatalf("spool: %v", err)
+	}
+	content, err := spool.reader()
+	if err != nil {
+		t.Fatalf("first attempt: %v", err)
+	}
+	if _, err := io.ReadFull(content, make([]byte, sent)); err != nil {
+		t.Fatalf("first attempt: %v", err)
+	}
+	return spool
+}
+
+func TestCiphertextSpoolResumes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		t.Fatalf("tempdir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	plain := make([]byte, 2*dcrypto.VerifiablePrefixSize)
+	rand.Read(plain)
+	sent := dcrypto.VerifiablePrefixSize + 100
+
+	spool := partlySentSpool(t, dir, plain, sent)
+	defer spool.close()
+
+	content, err := spool.reader()
+	if err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	ciphertext, err := ioutil.ReadAll(content)
+	if err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	decR, err := dcrypto.NewDecrypter(bytes.NewReader(ciphertext), spoolPassword)
+	if err != nil {
+		t.Fatalf("decrypter: %v", err)
+	}
+	got, err := ioutil.ReadAll(decR)
+	if err != nil || !bytes.Equal(got, plain) {
+		t.Errorf("the retried upload doesn't decrypt to the content: %v", err)
+	}
+}
+
+func TestCiphertextSpoolRefusesCorruptPrefix(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		t.Fatalf("tempdir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	plain := make([]byte, 2*dcrypto.VerifiablePrefixSize)
+	rand.Read(plain)
+	sent := dcrypto.VerifiablePrefixSize + 100
+
+	testCases := []struct {
+		desc    string
+		corrupt func(*os.File) error
+	}{
+		{
+			desc: "truncated",
+			corrupt: func(f *os.File) error {
+				return f.Truncate(int64(sent - 10))
+			},
+		},
+		{
+			desc: "tampered",
+			corrupt: func(f *os.File) error {
+				b := make([]byte, 1)
+				offset := int64(dcrypto.MaxHeaderSize + 1)
+				if _, err := f.ReadAt(b, offset); err != nil {
+					return err
+				}
+				b[0] ^= 0x01
+				_, err := f.WriteAt(b, offset)
+				return err
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		spool := partlySentSpool(t, dir, plain, sent)
+		if err := tc.corrupt(spool.file); err != nil {
+			t.Fatalf("%s: %v", tc.desc, err)
+		}
+		if _, err := spool.reader(); err != ErrCiphertextNotVerified {
+			t.Errorf("%s: got %v want %v", tc.desc, err, ErrCiphertextNotVerified)
+		}
+		spool.close()
+	}
+}
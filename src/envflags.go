@@ -0,0 +1,93 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"os"
+	"strings"
+
+	"github.com/odeke-em/drive/config"
+)
+
+// FlagEnvPrefix prefixes the environment variables that set command flags.
+const FlagEnvPrefix = "DRIVE_"
+
+// reservedEnvKeys are read by drive in their own right, so
+// they never set a flag even if one happened to map to them.
+var reservedEnvKeys = map[string]bool{
+	DriveClientIdEnvKey:      true,
+	DriveClientSecretEnvKey:  true,
+	DriveGoMaxProcsKey:       true,
+	QuotaUserEnvKey:          true,
+	GDNameEnvKey:             true,
+	ASCIIEnvKey:              true,
+	OutputEnvKey:             true,
+	AccountEnvKey:            true,
+	RemoteEnvKey:             true,
+	ImpersonateEnvKey:        true,
+	ProxyEnvKey:              true,
+	MetadataTTLEnvKey:        true,
+	MetadataCacheDiskEnvKey:  true,
+	CABundleEnvKey:           true,
+	TLSMinVersionEnvKey:      true,
+	InsecureSkipVerifyEnvKey: true,
+	BindAddressEnvKey:        true,
+	PreferIPv4EnvKey:         true,
+	ObjectCacheEnvKey:        true,
+	ObjectCacheSizeEnvKey:    true,
+	OuterEnvKey:              true,
+
+	// The passphrase of the credentials.
+	config.CredentialsPassphraseEnvKey:        true,
+	config.CredentialsPassphraseCommandEnvKey: true,
+}
+
+// FlagEnvKey returns the environment variable that sets the flag
+// named name, e.g DRIVE_PAGE_SIZE for -page-size, or "" if name
+// maps to a variable that drive reads for another purpose.
+func FlagEnvKey(name string) string {
+	mapped := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+
+	envKey := FlagEnvPrefix + mapped
+	if reservedEnvKeys[envKey] {
+		return ""
+	}
+	return envKey
+}
+
+// FlagsFromEnv returns the flags among names that are set in the
+// environment, as arguments of the form -name=value.
+func FlagsFromEnv(names ...string) []string {
+	var args []string
+	for _, name := range names {
+		envKey := FlagEnvKey(name)
+		if envKey == "" {
+			continue
+		}
+		if value, ok := os.LookupEnv(envKey); ok {
+			args = append(args, "-"+name+"="+value)
+		}
+	}
+	return args
+}
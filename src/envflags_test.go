@@ -0,0 +1,71 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestFlagEnvKey(t *testing.T) {
+	tests := [...]struct {
+		name string
+		want string
+	}{
+		0: {name: QuietKey, want: "DRIVE_QUIET"},
+		1: {name: "page-size", want: "DRIVE_PAGE_SIZE"},
+		2: {name: CLIOptionExportsDumpToSameDirectory, want: "DRIVE_SAME_EXPORTS_DIR"},
+		3: {name: "Link", want: "DRIVE_LINK"},
+		4: {name: "upload.rate", want: "DRIVE_UPLOAD_RATE"},
+
+		// Already read by drive for its global options.
+		5: {name: "account", want: ""},
+		6: {name: "quota-user", want: ""},
+	}
+
+	for i, tt := range tests {
+		if got := FlagEnvKey(tt.name); got != tt.want {
+			t.Errorf("#%d: %q: have %q want %q", i, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFlagsFromEnv(t *testing.T) {
+	env := map[string]string{
+		"DRIVE_QUIET":     "true",
+		"DRIVE_EXPORT":    "pdf,docx",
+		"DRIVE_PAGE_SIZE": "",
+		"DRIVE_ACCOUNT":   "work",
+	}
+	for key, value := range env {
+		prev, wasSet := os.LookupEnv(key)
+		os.Setenv(key, value)
+		defer func(key, prev string, wasSet bool) {
+			if wasSet {
+				os.Setenv(key, prev)
+			} else {
+				os.Unsetenv(key)
+			}
+		}(key, prev, wasSet)
+	}
+	os.Unsetenv("DRIVE_DEPTH")
+
+	got := FlagsFromEnv(QuietKey, ExportsKey, "page-size", DepthKey, "account")
+	want := []string{"-quiet=true", "-export=pdf,docx", "-page-size="}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("have %q want %q", got, want)
+	}
+}
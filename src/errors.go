@@ -14,6 +14,11 @@
 
 package drive
 
+import (
+	"fmt"
+	"strings"
+)
+
 type ErrorStatus int
 
 const (
@@ -42,6 +47,9 @@ const (
 	StatusContentTooLarge             ErrorStatus = 23
 	StatusClashesFixed                ErrorStatus = 24
 	StatusSecurityException           ErrorStatus = 25
+	StatusTreesDiffer                 ErrorStatus = 26
+	StatusPathsSkipped                ErrorStatus = 27
+	StatusInsufficientScope           ErrorStatus = 28
 )
 
 type Error struct {
@@ -65,6 +73,70 @@ func (e Error) Code() int {
 	return int(e.code)
 }
 
+// changeError annotates an error with the operation, the context
+// relative path and the id of the remote file of the change that
+// it was encountered playing.
+type changeError struct {
+	verb   string
+	op     Operation
+	path   string
+	fileId string
+	err    error
+}
+
+func (ce *changeError) Error() string {
+	_, info := ce.op.description()
+	joins := []string{ce.verb}
+	if info != "" {
+		joins = append(joins, strings.ToLower(info))
+	}
+	joins = append(joins, fmt.Sprintf("%q", ce.path))
+	if ce.fileId != "" {
+		joins = append(joins, fmt.Sprintf("(id %s)", ce.fileId))
+	}
+	return fmt.Sprintf("%s: %v", sepJoin(" ", joins...), ce.err)
+}
+
+// pathErr wraps err, if non-nil, with the context of verb e.g "pull"
+// acting on the context relative path. Coded errors keep their code.
+// Errors already wrapped are left as is.
+func pathErr(verb string, op Operation, relToRootPath, fileId string, err error) error {
+	if err == nil || isContextualized(err) {
+		return err
+	}
+
+	ce := &changeError{verb: verb, op: op, path: relToRootPath, fileId: fileId, err: err}
+	if coded, ok := err.(*Error); ok {
+		return makeError(ce, coded.code)
+	}
+	return ce
+}
+
+// changeErr wraps err, if non-nil, with the operation, path and
+// remote file id of change c played by verb.
+func changeErr(verb string, c *Change, err error) error {
+	if c == nil {
+		return err
+	}
+
+	fileId := ""
+	if c.Src != nil && c.Src.Id != "" {
+		fileId = c.Src.Id
+	} else if c.Dest != nil {
+		fileId = c.Dest.Id
+	}
+
+	return pathErr(verb, c.Op(), c.Path, fileId, err)
+}
+
+func isContextualized(err error) bool {
+	if coded, ok := err.(*Error); ok {
+		err = coded.err
+	}
+	_, ok := err.(*changeError)
+	return ok
+}
+
 func makeError(err error, code ErrorStatus) *Error {
 	return &Error{
 		code: code,
@@ -157,3 +229,15 @@ func contentTooLargeErr(err error) *Error {
 func clashesFixedErr(err error) *Error {
 	return makeError(err, StatusClashesFixed)
 }
+
+func treesDifferErr(err error) *Error {
+	return makeError(err, StatusTreesDiffer)
+}
+
+func pathsSkippedErr(err error) *Error {
+	return makeError(err, StatusPathsSkipped)
+}
+
+func insufficientScopeErr(err error) *Error {
+	return makeError(err, StatusInsufficientScope)
+}
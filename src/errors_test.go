@@ -75,3 +75,72 @@ func TestErrors(t *testing.T) {
 		}
 	}
 }
+
+func TestChangeErr(t *testing.T) {
+	errRaw := fmt.Errorf("unexpected EOF")
+	remote := &File{Id: "0Bremote", Name: "b.txt"}
+
+	testCases := [...]struct {
+		verb          string
+		change        *Change
+		err           error
+		wantErrString string
+		wantCode      int
+	}{
+		0: {
+			verb:   "pull",
+			change: &Change{Path: "/a/b.txt", Src: remote},
+		},
+		1: {
+			verb:          "pull",
+			change:        &Change{Path: "/a/b.txt", Src: remote},
+			err:           errRaw,
+			wantErrString: `pull addition "/a/b.txt" (id 0Bremote): unexpected EOF`,
+			wantCode:      -1,
+		},
+		2: {
+			verb:          "push",
+			change:        &Change{Path: "/a/b.txt", Src: &File{Name: "b.txt"}},
+			err:           errRaw,
+			wantErrString: `push addition "/a/b.txt": unexpected EOF`,
+			wantCode:      -1,
+		},
+		3: {
+			verb:          "push",
+			change:        &Change{Path: "/old.txt", Dest: remote},
+			err:           downloadFailedErr(errRaw),
+			wantErrString: `push deletion "/old.txt" (id 0Bremote): unexpected EOF`,
+			wantCode:      int(StatusDownloadFailed),
+		},
+		4: {
+			// Already wrapped errors aren't wrapped again.
+			verb:          "pull",
+			change:        &Change{Path: "/other.txt", Src: remote},
+			err:           pathErr("pull", OpNone, "/a", "", errRaw),
+			wantErrString: `pull "/a": unexpected EOF`,
+			wantCode:      -1,
+		},
+	}
+
+	for i, tc := range testCases {
+		err := changeErr(tc.verb, tc.change, tc.err)
+		if tc.err == nil {
+			if err != nil {
+				t.Errorf("#%d: got %v want nil", i, err)
+			}
+			continue
+		}
+
+		if got := err.Error(); got != tc.wantErrString {
+			t.Errorf("#%d: got=%q want=%q", i, got, tc.wantErrString)
+		}
+
+		gotCode := -1
+		if coded, ok := err.(*Error); ok {
+			gotCode = coded.Code()
+		}
+		if gotCode != tc.wantCode {
+			t.Errorf("#%d code: got=%v want=%v", i, gotCode, tc.wantCode)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// ExifDateLayout is the layout of the dates in EXIF metadata,
+// which are in the local time of the camera.
+const ExifDateLayout = "2006:01:02 15:04:05"
+
+// Tags and types of the TIFF structure of EXIF metadata.
+const (
+	tiffTagDateTime          = 0x0132
+	tiffTagExifIFD           = 0x8769
+	exifTagDateTimeOriginal  = 0x9003
+	exifTagDateTimeDigitized = 0x9004
+
+	tiffTypeASCII = 2
+	tiffTypeLong  = 4
+	tiffTypeIFD   = 13
+)
+
+var errNoExifDate = errors.New("no EXIF date")
+
+// exifDateOf returns when the photo at p was taken according to its EXIF
+// metadata, which is read from JPEG files and from the TIFF based RAW
+// files of most cameras e.g .cr2, .nef, .arw and .dng.
+func exifDateOf(p string) (time.Time, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer f.Close()
+
+	return exifDate(f)
+}
+
+func exifDate(r io.ReaderAt) (time.Time, error) {
+	var magic [4]byte
+	if _, err := r.ReadAt(magic[:], 0); err != nil {
+		return time.Time{}, errNoExifDate
+	}
+
+	switch string(magic[:]) {
+	case "II*\x00", "MM\x00*":
+		return tiffDate(r)
+	}
+	if magic[0] == 0xFF && magic[1] == 0xD8 {
+		return jpegExifDate(r)
+	}
+	return time.Time{}, errNoExifDate
+}
+
+// jpegExifDate reads the date of the EXIF metadata in the APP1 segment
+// of a JPEG file, which is among the segments that precede its image.
+func jpegExifDate(r io.ReaderAt) (time.Time, error) {
+	// Each segment starts with 0xFF, its marker and its big endian length.
+	var hdr [4]byte
+	for off := int64(2); ; {
+		if _, err := r.ReadAt(hdr[:], off); err != nil || hdr[0] != 0xFF {
+			return time.Time{}, errNoExifDate
+		}
+
+		marker := hdr[1]
+		switch {
+		case marker == 0xFF:
+			// Markers may be preceded by any number of fill bytes.
+			off++
+			continue
+		case marker == 0xDA || marker == 0xD9:
+			// The image data or its end was reached.
+			return time.Time{}, errNoExifDate
+		}
+
+		segmentLen := int64(binary.BigEndian.Uint16(hdr[2:]))
+		if segmentLen < 2 {
+			return time.Time{}, errNoExifDate
+		}
+		if marker == 0xE1 && segmentLen >= 8 {
+			var exifHdr [6]byte
+			if _, err := r.ReadAt(exifHdr[:], off+4); err == nil && string(exifHdr[:]) == "Exif\x00\x00" {
+				return tiffDate(io.NewSectionReader(r, off+10, segmentLen-8))
+			}
+		}
+		off += 2 + segmentLen
+	}
+}
+
+// ifdEntry is an entry of a TIFF image file directory, whose value
+// is kept in place if it fits in 4 bytes and else is at an offset.
+type ifdEntry struct {
+	typ   uint16
+	count uint32
+	value [4]byte
+}
+
+type tiffReader struct {
+	r     io.ReaderAt
+	order binary.ByteOrder
+}
+
+// tiffDate reads when the photo was taken from the TIFF structure
+// of r, preferring the original date of its EXIF directory.
+func tiffDate(r io.ReaderAt) (time.Time, error) {
+	var hdr [8]byte
+	if _, err := r.ReadAt(hdr[:], 0); err != nil {
+		return time.Time{}, errNoExifDate
+	}
+
+	tr := &tiffReader{r: r}
+	switch string(hdr[:2]) {
+	case "II":
+		tr.order = binary.LittleEndian
+	case "MM":
+		tr.order = binary.BigEndian
+	default:
+		return time.Time{}, errNoExifDate
+	}
+
+	ifd0, err := tr.ifd(tr.order.Uint32(hdr[4:]))
+	if err != nil {
+		return time.Time{}, errNoExifDate
+	}
+
+	if pointer, ok := ifd0[tiffTagExifIFD]; ok && (pointer.typ == tiffTypeLong || pointer.typ == tiffTypeIFD) {
+		if exifIFD, err := tr.ifd(tr.order.Uint32(pointer.value[:])); err == nil {
+			for _, tag := range []uint16{exifTagDateTimeOriginal, exifTagDateTimeDigitized} {
+				if t, ok := tr.date(exifIFD[tag]); ok {
+					return t, nil
+				}
+			}
+		}
+	}
+	if t, ok := tr.date(ifd0[tiffTagDateTime]); ok {
+		return t, nil
+	}
+	return time.Time{}, errNoExifDate
+}
+
+// ifd reads the entries of the image file directory at offset.
+func (tr *tiffReader) ifd(offset uint32) (map[uint16]ifdEntry, error) {
+	var countBytes [2]byte
+	if _, err := tr.r.ReadAt(countBytes[:], int64(offset)); err != nil {
+		return nil, err
+	}
+	count := int(tr.order.Uint16(countBytes[:]))
+
+	// Each entry is 12 bytes: its tag, type, count and value.
+	raw := make([]byte, count*12)
+	if _, err := tr.r.ReadAt(raw, int64(offset)+2); err != nil {
+		return nil, err
+	}
+
+	entries := make(map[uint16]ifdEntry, count)
+	for i := 0; i < count; i++ {
+		b := raw[i*12:]
+		e := ifdEntry{typ: tr.order.Uint16(b[2:]), count: tr.order.Uint32(b[4:])}
+		copy(e.value[:], b[8:12])
+		entries[tr.order.Uint16(b)] = e
+	}
+	return entries, nil
+}
+
+// date parses the ASCII date of entry e, if it is one.
+func (tr *tiffReader) date(e ifdEntry) (time.Time, bool) {
+	// Dates are 20 bytes long, leaving room for cameras that pad them.
+	if e.typ != tiffTypeASCII || e.count < 1 || e.count > 64 {
+		return time.Time{}, false
+	}
+
+	value := e.value[:]
+	if e.count <= uint32(len(e.value)) {
+		value = value[:e.count]
+	} else {
+		value = make([]byte, e.count)
+		if _, err := tr.r.ReadAt(value, int64(tr.order.Uint32(e.value[:]))); err != nil {
+			return time.Time{}, false
+		}
+	}
+
+	s := strings.TrimRight(string(value), "\x00 ")
+	t, err := time.ParseInLocation(ExifDateLayout, s, time.Local)
+	if err != nil {
+		// Cameras whose clock was never set write zeroed dates.
+		return time.Time{}, false
+	}
+	return t, true
+}
@@ -0,0 +1,144 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+// tiffWithDates returns a TIFF structure whose first directory holds
+// dateTime and whose EXIF directory holds original, either if set.
+func tiffWithDates(order binary.ByteOrder, dateTime, original string) []byte {
+	type entry struct {
+		tag   uint16
+		typ   uint16
+		count uint32
+		value uint32
+	}
+
+	var ifd0, exif []entry
+	var data bytes.Buffer
+	n0 := 0
+	if dateTime != "" {
+		n0++
+	}
+	if original != "" {
+		n0++
+	}
+	ifd0Len := 2 + 12*n0 + 4
+	exifOff := uint32(8 + ifd0Len)
+	dataOff := exifOff
+	if original != "" {
+		dataOff += 2 + 12 + 4
+	}
+
+	addString := func(s string) (uint32, uint32) {
+		off := dataOff + uint32(data.Len())
+		data.WriteString(s)
+		data.WriteByte(0)
+		return uint32(len(s) + 1), off
+	}
+	if dateTime != "" {
+		count, off := addString(dateTime)
+		ifd0 = append(ifd0, entry{tiffTagDateTime, tiffTypeASCII, count, off})
+	}
+	if original != "" {
+		ifd0 = append(ifd0, entry{tiffTagExifIFD, tiffTypeLong, 1, exifOff})
+		count, off := addString(original)
+		exif = append(exif, entry{exifTagDateTimeOriginal, tiffTypeASCII, count, off})
+	}
+
+	var buf bytes.Buffer
+	if order == binary.LittleEndian {
+		buf.WriteString("II")
+	} else {
+		buf.WriteString("MM")
+	}
+	binary.Write(&buf, order, uint16(42))
+	binary.Write(&buf, order, uint32(8))
+	for _, ifd := range [][]entry{ifd0, exif} {
+		if len(ifd) < 1 {
+			continue
+		}
+		binary.Write(&buf, order, uint16(len(ifd)))
+		for _, e := range ifd {
+			binary.Write(&buf, order, e)
+		}
+		binary.Write(&buf, order, uint32(0))
+	}
+	buf.Write(data.Bytes())
+	return buf.Bytes()
+}
+
+// jpegWithExif wraps tiff in the APP1 segment of a JPEG file.
+func jpegWithExif(tiff []byte) []byte {
+	var buf bytes.Buffer
+	buf.Write([]byte{0xFF, 0xD8})
+	// A JFIF APP0 segment comes first in many files.
+	buf.Write([]byte{0xFF, 0xE0, 0x00, 0x10})
+	buf.WriteString("JFIF\x00\x01\x01\x00\x00\x01\x00\x01\x00\x00")
+	if tiff != nil {
+		buf.Write([]byte{0xFF, 0xE1})
+		binary.Write(&buf, binary.BigEndian, uint16(2+6+len(tiff)))
+		buf.WriteString("Exif\x00\x00")
+		buf.Write(tiff)
+	}
+	buf.Write([]byte{0xFF, 0xDA, 0x00, 0x02, 0x01, 0x02, 0xFF, 0xD9})
+	return buf.Bytes()
+}
+
+func TestExifDate(t *testing.T) {
+	original := time.Date(2016, time.March, 9, 17, 4, 5, 0, time.Local)
+	modified := time.Date(2016, time.April, 1, 8, 0, 0, 0, time.Local)
+
+	leTIFF := tiffWithDates(binary.LittleEndian, "2016:04:01 08:00:00", "2016:03:09 17:04:05")
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    time.Time
+		wantErr bool
+	}{
+		{name: "little endian tiff", data: leTIFF, want: original},
+		{name: "big endian tiff", data: tiffWithDates(binary.BigEndian, "", "2016:03:09 17:04:05"), want: original},
+		{name: "no original date", data: tiffWithDates(binary.BigEndian, "2016:04:01 08:00:00", ""), want: modified},
+		{name: "zeroed original date", data: tiffWithDates(binary.LittleEndian, "2016:04:01 08:00:00", "0000:00:00 00:00:00"), want: modified},
+		{name: "jpeg", data: jpegWithExif(leTIFF), want: original},
+		{name: "jpeg without exif", data: jpegWithExif(nil), wantErr: true},
+		{name: "truncated tiff", data: leTIFF[:20], wantErr: true},
+		{name: "not an image", data: []byte("hello, world"), wantErr: true},
+		{name: "empty", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := exifDate(bytes.NewReader(tt.data))
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %v", tt.name, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected err %v", tt.name, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("%s: got %v want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/mattn/go-isatty"
+
+	"github.com/odeke-em/drive/config"
+)
+
+const (
+	// CloudSDKConfigEnvKey when set is the Cloud SDK's configuration directory.
+	CloudSDKConfigEnvKey = "CLOUDSDK_CONFIG"
+
+	gcloudCredentialsFile = "application_default_credentials.json"
+	cloudPlatformScope    = "https://www.googleapis.com/auth/cloud-platform"
+)
+
+// gcloudLoginHint tells how to have gcloud grant its credentials access to Drive,
+// which `gcloud auth application-default login` doesn't ask for by default.
+var gcloudLoginHint = fmt.Sprintf("run `gcloud auth application-default login --scopes=openid,%s,%s`", DriveScope, cloudPlatformScope)
+
+// gcloudCredentialsPath returns where `gcloud auth application-default login`
+// stores its credentials on goos, with getenv looking up the environment.
+func gcloudCredentialsPath(getenv func(string) string, goos string) string {
+	dir := getenv(CloudSDKConfigEnvKey)
+	if dir == "" {
+		if goos == "windows" {
+			dir = filepath.Join(getenv("APPDATA"), "gcloud")
+		} else {
+			dir = filepath.Join(getenv(HomeEnvKey), ".config", "gcloud")
+		}
+	}
+	return filepath.Join(dir, gcloudCredentialsFile)
+}
+
+// gcloudCredentials returns the path of the gcloud Application Default
+// Credentials, with ok unset if there aren't any.
+func gcloudCredentials() (p string, ok bool) {
+	p = gcloudCredentialsPath(os.Getenv, runtime.GOOS)
+	fi, err := os.Stat(p)
+	return p, err == nil && fi.Mode().IsRegular()
+}
+
+// offerGcloudCredentials asks, if there are gcloud credentials and
+// someone at the terminal to ask, whether to use those for init.
+func offerGcloudCredentials() bool {
+	p, ok := gcloudCredentials()
+	if !ok || !isatty.IsTerminal(os.Stdin.Fd()) || !isatty.IsTerminal(os.Stdout.Fd()) {
+		return false
+	}
+
+	status := promptForChanges(fmt.Sprintf("Found the gcloud Application Default Credentials at %s\n"+
+		"Use them instead of authorizing drive in the browser? [Y/n]: ", p))
+	return accepted(status)
+}
+
+// InitWithGcloudCredentials initializes the context to authenticate with
+// the Application Default Credentials e.g those that `gcloud auth
+// application-default login` stores, so that drive needs no OAuth client.
+func (g *Commands) InitWithGcloudCredentials() error {
+	if _, ok := gcloudCredentials(); !ok && !HasDefaultCredentials() {
+		return illogicalStateErr(fmt.Errorf("no Application Default Credentials found, %s first", gcloudLoginHint))
+	}
+
+	g.context.CredentialType = config.CredentialTypeApplicationDefault
+	g.context.ClientId = ""
+	g.context.ClientSecret = ""
+	g.context.RefreshToken = ""
+	g.context.AccessToken, g.context.TokenExpiry = "", 0
+	g.context.GSAJWTConfig = nil
+	g.context.LastKnownRoot = g.context.AbsPath
+
+	// The credentials only reach Drive if gcloud was asked for its
+	// scope, which is best found out now rather than on the first pull.
+	rem, err := remoteForContext(g.context)
+	if err != nil {
+		return err
+	}
+	if _, err := rem.FindById("root"); err != nil {
+		return fmt.Errorf("the Application Default Credentials can't access Drive: %v\n%s", err, gcloudLoginHint)
+	}
+
+	if err := g.resolveRemoteRoot(); err != nil {
+		return err
+	}
+	if err := g.context.Write(); err != nil {
+		return err
+	}
+
+	g.registerContext(false)
+	return nil
+}
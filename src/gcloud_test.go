@@ -0,0 +1,56 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestGcloudCredentialsPath(t *testing.T) {
+	cases := []struct {
+		env  map[string]string
+		goos string
+		want string
+	}{
+		{
+			env:  map[string]string{HomeEnvKey: "/home/a"},
+			goos: "linux",
+			want: filepath.Join("/home/a", ".config", "gcloud", gcloudCredentialsFile),
+		},
+		{
+			env:  map[string]string{HomeEnvKey: "/Users/a"},
+			goos: "darwin",
+			want: filepath.Join("/Users/a", ".config", "gcloud", gcloudCredentialsFile),
+		},
+		{
+			env:  map[string]string{"APPDATA": "C:/Users/a/AppData/Roaming", HomeEnvKey: "/home/a"},
+			goos: "windows",
+			want: filepath.Join("C:/Users/a/AppData/Roaming", "gcloud", gcloudCredentialsFile),
+		},
+		{
+			env:  map[string]string{CloudSDKConfigEnvKey: "/etc/gcloud", HomeEnvKey: "/home/a"},
+			goos: "linux",
+			want: filepath.Join("/etc/gcloud", gcloudCredentialsFile),
+		},
+	}
+
+	for i, tc := range cases {
+		getenv := func(key string) string { return tc.env[key] }
+		if got := gcloudCredentialsPath(getenv, tc.goos); got != tc.want {
+			t.Errorf("#%d: got %q want %q", i, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/odeke-em/namespace"
+)
+
+const (
+	// GlobalConfigJSON is the file that holds the per-command defaults shared by all contexts.
+	GlobalConfigJSON = "config.json"
+
+	// GlobalConfigSection is the section of the GlobalConfigJSON that applies to every command.
+	GlobalConfigSection = "global"
+)
+
+// globalConfigPath returns the path of the GlobalConfigJSON, under
+// $XDG_CONFIG_HOME/drive or ~/.config/drive if that isn't set.
+func globalConfigPath() string {
+	configHome := os.Getenv(XDGConfigHomeEnvKey)
+	if configHome == "" {
+		configHome = path.Join(FsHomeDir, ".config")
+	}
+	return path.Join(configHome, "drive", GlobalConfigJSON)
+}
+
+// GlobalConfigMappings reads the per-command defaults of the global
+// configuration at configPath, which maps sections, named after commands
+// or joined by "/" as with .driverc, to the values of their flags.
+// The values are then resolved exactly as .driverc values are.
+func GlobalConfigMappings(configPath string) (map[string]map[string]interface{}, error) {
+	data, err := ioutil.ReadFile(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	sections := make(map[string]map[string]interface{})
+	if err := json.Unmarshal(data, &sections); err != nil {
+		return nil, fmt.Errorf("%s: %v", configPath, err)
+	}
+
+	nsMap := make(map[string]map[string]string)
+	for section, values := range sections {
+		kvMap := make(map[string]string)
+		for key, value := range values {
+			str, err := stringifyConfigValue(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s.%s: %v", configPath, section, key, err)
+			}
+			kvMap[strings.ToLower(key)] = str
+		}
+
+		for _, name := range strings.Split(section, "/") {
+			name = strings.TrimSpace(name)
+			if name == GlobalConfigSection {
+				name = namespace.GlobalNamespaceKey
+			}
+			if nsMap[name] == nil {
+				nsMap[name] = make(map[string]string)
+			}
+			for key, value := range kvMap {
+				nsMap[name][key] = value
+			}
+		}
+	}
+
+	grouped := make(map[string]map[string]interface{})
+	for key, ns := range nsMap {
+		parsed, err := parseRCValues(ns)
+		if err != nil {
+			return nil, err
+		}
+		grouped[key] = parsed
+	}
+
+	DebugPrintf("parsedContent from %q\n%v", configPath, grouped)
+	return grouped, nil
+}
+
+// stringifyConfigValue converts a JSON value into the form it would have in a .driverc.
+func stringifyConfigValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return os.ExpandEnv(v), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case []interface{}:
+		strs := make([]string, 0, len(v))
+		for _, elem := range v {
+			str, err := stringifyConfigValue(elem)
+			if err != nil {
+				return "", err
+			}
+			strs = append(strs, str)
+		}
+		return strings.Join(strs, ","), nil
+	default:
+		return "", fmt.Errorf("unsupported value %v", value)
+	}
+}
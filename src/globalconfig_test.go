@@ -0,0 +1,143 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/odeke-em/namespace"
+)
+
+func TestGlobalConfigMappings(t *testing.T) {
+	dir, err := ioutil.TempDir("", "drive-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	testCases := []struct {
+		config  string
+		want    map[string]map[string]interface{}
+		wantErr bool
+	}{
+		{
+			config: `{"global": {"hidden": true, "depth": 3}, "pull": {"No-Clobber": true, "export": ["pdf", "docx"]}}`,
+			want: map[string]map[string]interface{}{
+				namespace.GlobalNamespaceKey: {HiddenKey: true, DepthKey: 3},
+				"pull":                       {NoClobberKey: true, ExportsKey: "pdf,docx"},
+			},
+		},
+		{
+			config: `{"pull/push": {"no-clobber": true}, "push": {"depth": -1}}`,
+			want: map[string]map[string]interface{}{
+				"pull": {NoClobberKey: true},
+				"push": {NoClobberKey: true, DepthKey: -1},
+			},
+		},
+		{config: `{"pull": {"no-clobber": {"nested": true}}}`, wantErr: true},
+		{config: `{"pull": true}`, wantErr: true},
+		{config: `not json`, wantErr: true},
+	}
+
+	configPath := filepath.Join(dir, GlobalConfigJSON)
+	for i, tc := range testCases {
+		if err := ioutil.WriteFile(configPath, []byte(tc.config), 0600); err != nil {
+			t.Fatal(err)
+		}
+		got, err := GlobalConfigMappings(configPath)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("#%d: expected an error", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: err %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("#%d: got %v want %v", i, got, tc.want)
+		}
+	}
+}
+
+func TestGlobalConfigPrecedence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "drive-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	prevConfigHome := os.Getenv(XDGConfigHomeEnvKey)
+	defer os.Setenv(XDGConfigHomeEnvKey, prevConfigHome)
+	os.Setenv(XDGConfigHomeEnvKey, dir)
+
+	if err := os.MkdirAll(filepath.Join(dir, "drive"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	config := `{"global": {"depth": 1, "hidden": true}, "pull": {"no-clobber": true, "export": "pdf"}}`
+	if err := ioutil.WriteFile(globalConfigPath(), []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	mount := filepath.Join(dir, "mount")
+	if err := os.MkdirAll(mount, 0700); err != nil {
+		t.Fatal(err)
+	}
+	rc := "depth=2\n[pull]\nexport=docx\n"
+	if err := ioutil.WriteFile(rcPath(mount), []byte(rc), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	type pullFlags struct {
+		Depth     *int    `json:"depth"`
+		Hidden    *bool   `json:"hidden"`
+		NoClobber *bool   `json:"no-clobber"`
+		Exports   *string `json:"export"`
+	}
+
+	depth, noClobber := 5, false
+	from := pullFlags{Depth: &depth, NoClobber: &noClobber}
+	defined := map[string]bool{NoClobberKey: true}
+
+	stringified, err := JSONStringifySiftedCLITags(from, mount, defined, "pull")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got pullFlags
+	if err := json.Unmarshal([]byte(stringified), &got); err != nil {
+		t.Fatalf("%s: %v", stringified, err)
+	}
+
+	// depth and export come from the .driverc, hidden from the global
+	// configuration while no-clobber was set on the command line.
+	if got.Depth == nil || *got.Depth != 2 {
+		t.Errorf("depth: got %v want 2", got.Depth)
+	}
+	if got.Exports == nil || *got.Exports != "docx" {
+		t.Errorf("export: got %v want docx", got.Exports)
+	}
+	if got.Hidden == nil || !*got.Hidden {
+		t.Errorf("hidden: got %v want true", got.Hidden)
+	}
+	if got.NoClobber == nil || *got.NoClobber {
+		t.Errorf("no-clobber: got %v want false", got.NoClobber)
+	}
+}
@@ -0,0 +1,186 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultHeartbeatInterval is how often the heartbeat file is touched
+// when no heartbeat interval is set.
+const DefaultHeartbeatInterval = time.Minute
+
+// heartbeat shows supervisors e.g a systemd watchdog that a long
+// running transfer is healthy even while it produces no output.
+type heartbeat struct {
+	// transferred is first for the 64-bit alignment of its atomic ops.
+	transferred int64
+	total       int64
+	start       time.Time
+
+	// lines when set logs a line on every beat.
+	lines bool
+	file  string
+	// notifySocket when set is the socket that systemd
+	// expects the watchdog keep-alives on.
+	notifySocket string
+
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// ParseHeartbeatInterval parses a heartbeat interval e.g "30s" or "5m",
+// where "" means no heartbeat lines.
+func ParseHeartbeatInterval(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, invalidArgumentsErr(fmt.Errorf("heartbeat %q: expecting a positive duration e.g 30s or 5m", s))
+	}
+	return d, nil
+}
+
+// watchdogInterval is half of the timeout of the systemd watchdog
+// that supervises this process, or 0 if there is none.
+func watchdogInterval(getenv func(string) string, pid int) time.Duration {
+	if getenv("NOTIFY_SOCKET") == "" {
+		return 0
+	}
+	usec, err := strconv.ParseInt(getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if watchdogPid := getenv("WATCHDOG_PID"); watchdogPid != "" && watchdogPid != strconv.Itoa(pid) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond / 2
+}
+
+// heartbeatInterval picks the most frequent of the beats asked for.
+func heartbeatInterval(lines, watchdog time.Duration, file string) time.Duration {
+	interval := lines
+	if watchdog > 0 && (interval <= 0 || watchdog < interval) {
+		interval = watchdog
+	}
+	if interval <= 0 && file != "" {
+		interval = DefaultHeartbeatInterval
+	}
+	return interval
+}
+
+// startHeartbeat beats until stop is called while total bytes are
+// transferred, if any heartbeat is asked for or expected by systemd.
+func (g *Commands) startHeartbeat(total int64) (stop func()) {
+	watchdog := watchdogInterval(os.Getenv, os.Getpid())
+	interval := heartbeatInterval(g.opts.Heartbeat, watchdog, g.opts.HeartbeatFile)
+	if interval <= 0 {
+		return func() {}
+	}
+
+	hb := &heartbeat{
+		total:   total,
+		start:   time.Now(),
+		lines:   g.opts.Heartbeat > 0,
+		file:    g.opts.HeartbeatFile,
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	if watchdog > 0 {
+		hb.notifySocket = os.Getenv("NOTIFY_SOCKET")
+	}
+	g.heartbeat = hb
+
+	go func() {
+		defer close(hb.stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-hb.done:
+				return
+			case now := <-ticker.C:
+				g.beat(hb, now)
+			}
+		}
+	}()
+
+	return func() {
+		close(hb.done)
+		<-hb.stopped
+	}
+}
+
+func (hb *heartbeat) add(n int64) {
+	if hb != nil {
+		atomic.AddInt64(&hb.transferred, n)
+	}
+}
+
+func (g *Commands) beat(hb *heartbeat, now time.Time) {
+	if hb.lines {
+		elapsed := now.Sub(hb.start)
+		g.log.LogErrf("heartbeat: %s of %s transferred, %v elapsed\n",
+			prettyBytes(atomic.LoadInt64(&hb.transferred)), prettyBytes(hb.total), elapsed-elapsed%time.Second)
+	}
+	if hb.file != "" {
+		if err := touchHeartbeatFile(hb.file, now); err != nil {
+			g.log.LogErrf("heartbeat: %v\n", err)
+		}
+	}
+	if hb.notifySocket != "" {
+		if err := sdNotify(hb.notifySocket, "WATCHDOG=1"); err != nil {
+			g.log.LogErrf("heartbeat: watchdog: %v\n", err)
+		}
+	}
+}
+
+// touchHeartbeatFile sets the modification time of the
+// heartbeat file to now, creating it if need be.
+func touchHeartbeatFile(p string, now time.Time) error {
+	f, err := os.OpenFile(p, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Chtimes(p, now, now)
+}
+
+// sdNotify sends state e.g "WATCHDOG=1" to systemd's notification socket.
+func sdNotify(socket, state string) error {
+	// A leading '@' stands for the abstract namespace.
+	if strings.HasPrefix(socket, "@") {
+		socket = "\x00" + socket[1:]
+	}
+	conn, err := net.Dial("unixgram", socket)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(state))
+	return err
+}
@@ -0,0 +1,145 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseHeartbeatInterval(t *testing.T) {
+	cases := []struct {
+		spec    string
+		want    time.Duration
+		wantErr bool
+	}{
+		{spec: "", want: 0},
+		{spec: "30s", want: 30 * time.Second},
+		{spec: " 5m ", want: 5 * time.Minute},
+		{spec: "0s", wantErr: true},
+		{spec: "-1m", wantErr: true},
+		{spec: "often", wantErr: true},
+	}
+
+	for _, tc := range cases {
+		got, err := ParseHeartbeatInterval(tc.spec)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("%q: expected an error", tc.spec)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("%q: got (%v, %v) want %v", tc.spec, got, err, tc.want)
+		}
+	}
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	cases := []struct {
+		env  map[string]string
+		want time.Duration
+	}{
+		{env: map[string]string{}, want: 0},
+		{env: map[string]string{"WATCHDOG_USEC": "60000000"}, want: 0},
+		{env: map[string]string{"NOTIFY_SOCKET": "/run/notify"}, want: 0},
+		{env: map[string]string{"NOTIFY_SOCKET": "/run/notify", "WATCHDOG_USEC": "60000000"}, want: 30 * time.Second},
+		{env: map[string]string{"NOTIFY_SOCKET": "/run/notify", "WATCHDOG_USEC": "60000000", "WATCHDOG_PID": "42"}, want: 30 * time.Second},
+		{env: map[string]string{"NOTIFY_SOCKET": "/run/notify", "WATCHDOG_USEC": "60000000", "WATCHDOG_PID": "7"}, want: 0},
+		{env: map[string]string{"NOTIFY_SOCKET": "/run/notify", "WATCHDOG_USEC": "soon"}, want: 0},
+	}
+
+	for i, tc := range cases {
+		getenv := func(key string) string { return tc.env[key] }
+		if got := watchdogInterval(getenv, 42); got != tc.want {
+			t.Errorf("#%d: got %v want %v", i, got, tc.want)
+		}
+	}
+}
+
+func TestHeartbeatInterval(t *testing.T) {
+	cases := []struct {
+		lines, watchdog time.Duration
+		file            string
+		want            time.Duration
+	}{
+		{want: 0},
+		{file: "beat", want: DefaultHeartbeatInterval},
+		{lines: 5 * time.Minute, file: "beat", want: 5 * time.Minute},
+		{lines: 5 * time.Minute, watchdog: 30 * time.Second, want: 30 * time.Second},
+		{lines: 10 * time.Second, watchdog: 30 * time.Second, want: 10 * time.Second},
+		{watchdog: 30 * time.Second, want: 30 * time.Second},
+	}
+
+	for i, tc := range cases {
+		if got := heartbeatInterval(tc.lines, tc.watchdog, tc.file); got != tc.want {
+			t.Errorf("#%d: got %v want %v", i, got, tc.want)
+		}
+	}
+}
+
+func TestTouchHeartbeatFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "heartbeat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	p := filepath.Join(dir, "beat")
+	for _, now := range []time.Time{time.Unix(1500000000, 0), time.Unix(1500000060, 0)} {
+		if err := touchHeartbeatFile(p, now); err != nil {
+			t.Fatal(err)
+		}
+		fi, err := os.Stat(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !fi.ModTime().Equal(now) {
+			t.Errorf("got mtime %v want %v", fi.ModTime(), now)
+		}
+	}
+}
+
+func TestSdNotify(t *testing.T) {
+	dir, err := ioutil.TempDir("", "heartbeat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, "notify")
+	conn, err := net.ListenPacket("unixgram", socket)
+	if err != nil {
+		t.Skipf("unixgram sockets unavailable: %v", err)
+	}
+	defer conn.Close()
+
+	if err := sdNotify(socket, "WATCHDOG=1"); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 64)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(buf[:n]); got != "WATCHDOG=1" {
+		t.Errorf("got %q want %q", got, "WATCHDOG=1")
+	}
+}
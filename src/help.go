@@ -21,15 +21,18 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/odeke-em/drive/config"
 	prettywords "github.com/odeke-em/pretty-words"
 )
 
 const (
 	AboutKey                  = "about"
 	AllKey                    = "all"
+	BackupKey                 = "backup"
 	CopyKey                   = "copy"
 	DeleteKey                 = "delete"
 	DeInitKey                 = "deinit"
+	DeAuthKey                 = "deauth"
 	EditDescriptionKey        = "edit-description"
 	EditDescriptionShortKey   = "edit-desc"
 	ServiceAccountJSONFileKey = "service-account-file"
@@ -53,6 +56,15 @@ const (
 	PubKey                    = "pub"
 	QRLinkKey                 = "qr"
 	RenameKey                 = "rename"
+	RelocateKey               = "relocate"
+	ContextsKey               = "contexts"
+	CredentialsKey            = "credentials"
+	TransferKey               = "transfer"
+	ChecksumKey               = "checksum"
+	CmpKey                    = "cmp"
+	VerifyKey                 = "verify"
+	FlushKey                  = "flush"
+	StatusKey                 = "status"
 	QuotaKey                  = "quota"
 	ShareKey                  = "share"
 	StatKey                   = "stat"
@@ -65,8 +77,11 @@ const (
 	NewKey                    = "new"
 	IndexKey                  = "index"
 	PruneKey                  = "prune"
+	ImportKey                 = "import"
 	StarKey                   = "star"
 	UnStarKey                 = "unstar"
+	LockKey                   = "lock"
+	UnlockKey                 = "unlock"
 
 	CoercedMimeKeyKey        = "coerced-mime"
 	ExportsKey               = "export"
@@ -108,7 +123,11 @@ const (
 	MatchOwnerKey            = "match-owner"
 	ExactOwnerKey            = "exact-owner"
 	NotOwnerKey              = "skip-owner"
+	NamePatternKey           = "name"
+	ModifiedAfterKey         = "modified-after"
+	ModifiedBeforeKey        = "modified-before"
 	SortKey                  = "sort"
+	CollateKey               = "collate"
 	FolderKey                = "folder"
 	MimeKey                  = "mime-key"
 	PageSizeKey              = "pagesize"
@@ -121,12 +140,21 @@ const (
 	TouchModTimeKey          = "time"
 	TouchTimeFmtSpecifierKey = "format"
 	TouchOffsetDurationKey   = "duration"
+	CryptKey                 = "crypt"
+	CryptRekeyKey            = "rekey"
+	CryptStatusKey           = "status"
+	MigrateKey               = "migrate"
+	ConfigKey                = "config"
+	ConfigGetKey             = "get"
+	ConfigSetKey             = "set"
+	ConfigValidateKey        = "validate"
 )
 
 const (
 	DescAbout                 = "print out information about your Google drive"
 	DescAll                   = "print out the entire help section"
 	DescAllStarred            = "all the starred files"
+	DescBackup                = "push local content into a dated snapshot directory on the remote"
 	DescCopy                  = "copy remote paths to a destination"
 	DescDelete                = "deletes the items permanently. This operation is irreversible"
 	DescDiff                  = "compares local files with their remote equivalent"
@@ -138,18 +166,30 @@ const (
 	DescHelp                  = "Get help for a topic"
 	DescInit                  = "initializes a directory and authenticates user"
 	DescDeInit                = "removes the user's credentials and initialized files"
+	DescDeAuth                = "revokes the refresh token and removes the credentials from the context"
 	DescList                  = "lists the contents of remote path"
 	DescMove                  = "move files/folders"
 	DescPiped                 = "get content in from standard input (stdin)"
 	DescQuota                 = "prints out information related to your quota space"
 	DescPublish               = "publishes a file and prints its publicly available url"
 	DescRename                = "renames a file/folder"
+	DescRelocate              = "rebinds a context whose directory was moved or renamed"
+	DescContexts              = "lists the registered contexts with their accounts, remote roots and last sync times"
+	DescCredentials           = "encrypts or decrypts the credentials at rest with a passphrase, or moves the refresh tokens to the OS keychain"
+	DescCmp                   = "compares two remote folders by name, size and md5 checksum without downloading anything"
+	DescChecksum              = "prints a hashdeep manifest of the sizes and md5 checksums of local or remote files"
+	DescVerify                = "verifies local content, or a random sample of it, against the remote md5 checksums"
+	DescTransfer              = "copies or moves remote content into another context, possibly of another account"
+	DescStatus                = "reports the paths that are only local, only remote, modified or conflicting without transferring anything, and the pushes queued with `push -queue`"
+	DescFlush                 = "plays the pushes queued with `push -queue` in the order they were queued"
 	DescPull                  = "pulls remote changes from Google Drive"
 	DescPruneIndices          = "remove stale indices"
 	DescPush                  = "push local changes to Google Drive"
 	DescShare                 = "share files with specific emails giving the specified users specifies roles and permissions"
 	DescStar                  = "star files"
 	DescUnStar                = "unstar files"
+	DescLock                  = "takes advisory locks on remote files so that others don't push over them"
+	DescUnlock                = "releases advisory locks on remote files"
 	DescStat                  = "display information about a file"
 	DescTouch                 = "updates a remote file's modification time to that currently on the server"
 	DescTrash                 = "moves files to trash"
@@ -175,6 +215,10 @@ const (
 	DescMatchOwner                   = "elements with matching owners"
 	DescExactOwner                   = "elements with the exact owner"
 	DescNotOwner                     = "ignore elements owned by these users"
+	DescNamePattern                  = "get elements whose names match these comma separated glob patterns e.g `report*,*.pdf`"
+	DescModifiedAfter                = "get elements modified after this date e.g 2016-01-02, 2016-01-02T15:04:05Z or a duration from now e.g -48h"
+	DescModifiedBefore               = "get elements modified before this date e.g 2016-01-02, 2016-01-02T15:04:05Z or a duration from now e.g -48h"
+	DescCollate                      = "set to \"locale\" to sort names as your locale does, with numbers in names compared by value e.g file2 before file10"
 	DescNew                          = "create a new file/folder"
 	DescAllIndexOperations           = "perform all the index related operations"
 	DescOpen                         = "open a file in the appropriate filemanager or default browser"
@@ -200,7 +244,90 @@ const (
 	DescDecryptionPassword           = "decryption password"
 	DescWithLink                     = "turn off file indexing so that only those with the link can view it"
 	DescAllowDesktopLinks            = "allows docs + sheets to be pulled as .desktop files or URL linked files"
+	DescPlaceholders                 = "write .gdoc, .gsheet etc placeholder files with the id and URL of docs + sheets that aren't exported"
+	DescPullTar                      = "stream the remote content as a tar archive to stdout"
+	DescPullZip                      = "package the remote content into a zip file, the last argument"
+	DescBackupDestination            = "the remote directory under which dated snapshots are kept"
+	DescUploadRateSchedule           = "comma separated times of day with their own upload limits in KiB/s, 0 for unlimited e.g 01:00-07:00=0,09:00-17:00=1024. -upload-rate-limit applies at all other times"
+	DescBackground                   = "run at the lowest priority with paced I/O and one transfer at a time, to stay out of the way of other work"
+	DescPullDirectories              = "replicate only the folder structure locally, without any file contents"
+	DescPushDirectories              = "replicate only the folder structure remotely e.g to pre-create a hierarchy before selectively filling it"
+	DescDirsOnly                     = "alias of -directories"
+	DescIncludeFrom                  = "transfer only the paths picked by the rsync-style include and exclude patterns in this file"
+	DescIncludeOnly                  = "comma separated patterns e.g '*.pdf,*.docx' to transfer only the matching paths, instead of those of .driveinclude"
+	DescFilesFrom                    = "read the paths to transfer from this file, one per line, or from stdin if it is -"
+	DescMaxAPICalls                  = "stop cleanly after this many API requests, 0 for no limit. Re-run to resume"
+	DescAPIRate                      = "pace this context's API requests to n per second, 0 for no limit"
+	DescByType                       = "aggregate usage by file type e.g images, videos and documents and by extension instead of listing each file, of the whole tree unless -depth is given"
+	DescTop                          = "only report the N largest files, largest first, with their fileIds, of the whole tree unless -depth is given"
+	DescDuLocal                      = "with -top, also report the largest files in the local tree"
+	DescVirtualStarred               = "with -starred -all, gather the starred files into the local `starred` folder wherever they live remotely, removing those since unstarred. Refused if a real `starred` folder exists"
+	DescOrder                        = "order transfers by smallest-first, largest-first or newest-first"
+	DescHeartbeat                    = "log a heartbeat line at this interval e.g 5m while transfers are active"
+	DescHeartbeatFile                = "touch this file on every heartbeat for supervisors to watch"
+	DescSnapshotToTemp               = "copy each file to a temporary file before uploading it, for files that keep changing"
+	DescLockedRetries                = "number of times to retry opening a file locked by another process before skipping it"
+	DescShadowCopy                   = "on Windows, read files that stay locked from a Volume Shadow Copy, requires administrator rights"
+	DescSparse                       = "leave blocks of zeros in downloaded files as holes e.g for disk images, on filesystems that support sparse files"
+	DescSplitSize                    = "push files larger than this size in parts of at most this size e.g 4G, which pull then reassembles"
+	DescRemoteFolderId               = "bind the context to the existing remote folder with this id instead of the root of the Drive"
+	DescRemoteName                   = "bind the context to the remote folder at this path e.g \"Laptop Backups/ThinkPad\", creating it if need be"
+	DescDevice                       = "authorize from another device by entering a short code, for machines without a browser. Only the drive.file and drive.appdata scopes are allowed, by default drive.file"
+	DescManual                       = "authorize by pasting the code shown in the browser instead of capturing it on a local port"
+	DescGcloud                       = "authenticate with the Application Default Credentials of `gcloud auth application-default login`"
+	DescMerge                        = "bind the context to a remote folder that already has files, merging them with the local ones on the next syncs"
+	DescRequireEmpty                 = "fail if the remote folder that the context is bound to has files or is that of another context"
+	DescAdopt                        = "bind the empty context to a remote folder that already has files, for the next pull to fetch them"
+	DescScope                        = "OAuth 2.0 scope to request, one of drive, drive.file, drive.readonly or drive.appdata, by default drive"
+	DescReadOnly                     = "request only read access to the Drive, for contexts that push, trash and delete must never modify"
+	DescLargeFiles                   = "pulls that would download more than this many files need -confirm-large, 0 for no limit"
+	DescLargeSize                    = "pulls that would download more than this many bytes e.g 500G need -confirm-large, empty for no limit"
+	DescConfirmLarge                 = "go ahead with a pull over the -large-files or -large-size thresholds"
+	DescNamedRemote                  = "named remote e.g backup to sync with in place of the context's default one"
+	DescAllowSyncOverlap             = "only warn of a context within a tree that another tool e.g Dropbox syncs, and sync such trees within the context"
+	DescDryRun                       = "preview the changes without making them"
+	DescAgainst                      = "with -dry-run, preview the changes relative to this snapshot saved by -snapshot instead of the remote, without network access"
+	DescSnapshot                     = "after a successful push, save the state of the local tree under this name for use with -against"
+	DescNoCache                      = "recompute the status even if nothing changed since it was last cached"
+	DescStrict                       = "exit with a non-zero status if any path was skipped e.g an unsupported file type or an unreadable directory"
+	DescMaxBytes                     = "stop cleanly after transferring this many bytes e.g 500M or 2G, empty for no limit. Re-run to resume"
+	DescDiffRevisions                = "compare two revisions of a remote file, exporting Docs to text and Sheets to CSV"
+	DescSample                       = "verify only a random sample of the files e.g 5% or 200"
+	DescRollback                     = "if the batch of permission changes can't complete, undo the changes that were applied"
+	DescAboutAuth                    = "print how the context authenticates, including the OAuth2 client in use and where it was set"
+	DescStallTimeout                 = "cancel a transfer that makes no progress for this long e.g 60s, and retry it on a fresh connection"
+	DescItemizeChanges               = "print a line per transferred file in the format of rsync's --itemize-changes e.g >f.st...... a.txt"
+	DescWatch                        = "keep pulling, checking for remote changes this often e.g 1m and pulling only the folders that changed"
+	DescShard                        = "comma separated remote folders whose files are kept in hashed subfolders, yet listed flat locally"
+	DescShareRecursive               = "also apply the permission changes to everything under the given folders"
+	DescChecksumRemote               = "checksum the remote files from their metadata, without downloading anything"
+	DescTransferMove                 = "trash the sources once they are copied"
+	DescEncryptCredentials           = "encrypt the credentials with a passphrase"
+	DescCredentialStore              = "store the refresh tokens in the \"keychain\" of the OS or back in the credentials \"file\""
+	DescPushQueue                    = "record the push in the context's queue instead of playing it e.g while offline"
+	DescPushAs                       = "upload the one local file, or stdin if piped, to exactly this remote path, which may hold templates"
+	DescMediaPath                    = "upload the local photos and videos into the remote folder that this template e.g 'photos/{{exif.Year}}/{{exif.Month}}' names for each by when it was taken"
+	DescFlushList                    = "list the queued pushes without playing them"
+	DescDecryptCredentials           = "store the credentials back in plain text"
+	DescTrackContext                 = "add the current context to the registry, creating the registry if need be"
+	DescImportMd5Manifest            = "md5sum or hashdeep manifest whose checksums seed the local checksum cache"
+	DescKeepDaily                    = "after a backup, keep only the newest snapshots of the last n days"
+	DescKeepWeekly                   = "after a backup, keep only the newest snapshots of the last n weeks"
+	DescKeepMonthly                  = "after a backup, keep only the newest snapshots of the last n months"
 	DescKeepParent                   = "ensures that when moving a file into a destination, that we also retain its original parent so that it will exist in more than one folder"
+	DescBackupSuffix                 = "when moving onto an existing file, first rename that file with this suffix appended e.g .bak"
+	DescOverwrite                    = "when moving onto an existing file, first move that file to the trash"
+	DescCrypt                        = "manage the keys that pushed content is encrypted under, see `crypt rekey` and `crypt status`"
+	DescMigrate                      = "copies the remote tree of the context from one of its accounts to another"
+	DescMigrateFrom                  = "account to migrate from, by default the one in use"
+	DescMigrateTo                    = "account to migrate to, empty for the default credentials of the context"
+	DescMigrateLayout                = "upgrade the context's .gd layout written by an earlier version of drive in place, instead of migrating files"
+	DescConfig                       = "get or set the default of an option in the context's .driverc or the global config.json, see `config get` and `config set`, or check the context's files with `config validate`"
+	DescConfigGlobal                 = "operate on the global config.json shared by all contexts instead of the context's .driverc"
+	DescConfigRepair                 = "with `config validate`, fix the problems that can be fixed without losing anything"
+	DescCryptRotate                  = "also add a new key that files pushed from then on are encrypted under"
+	DescCryptOldPassword             = "the current passphrase of the keyring, or the one files were encrypted under before it, prompted for if not given"
+	DescCryptNewPassword             = "the passphrase to wrap the keys under, prompted for if not given"
 
 	DescTouchTimeStr          = "the time each file's modification time should be set to"
 	DescTouchOffsetDuration   = "the duration offset from now that each file's modification time should be set to e.g -32h\nSee https://golang.org/pkg/time/#ParseDuration"
@@ -225,6 +352,10 @@ const (
 	CLIOptionExactOwner         = "exact-owner"
 	CLIOptionMatchOwner         = "match-owner"
 	CLIOptionNotOwner           = "skip-owner"
+	CLIOptionNamePattern        = "name"
+	CLIOptionModifiedAfter      = "modified-after"
+	CLIOptionModifiedBefore     = "modified-before"
+	CLIOptionCollate            = "collate"
 	CLIOptionPruneIndices       = "prune"
 	CLIOptionAllIndexOperations = "all-ops"
 	CLIOptionVerboseKey         = "verbose"
@@ -233,6 +364,44 @@ const (
 	CLIOptionWebBrowser         = "web-browser"
 	CLIOptionFileBrowser        = "file-browser"
 	CLIOptionDirectories        = "directories"
+	CLIOptionDirsOnly           = "dirs-only" // alias of CLIOptionDirectories
+	CLIOptionFilesFrom          = "files-from"
+	CLIOptionSample             = "sample"
+	CLIOptionRollback           = "rollback"
+	CLIOptionIncludeFrom        = "include-from"
+	CLIOptionIncludeOnly        = "include"
+	CLIOptionMaxAPICalls        = "max-api-calls"
+	CLIOptionAPIRate            = "api-rate"
+	CLIOptionClientId           = "client-id"
+	CLIOptionClientSecret       = "client-secret"
+	CLIOptionMaxBytes           = "max-bytes"
+	CLIOptionQueue              = "queue"
+	CLIOptionAs                 = "as"
+	CLIOptionMediaPath          = "media-path"
+	CLIOptionStrict             = "strict"
+	CLIOptionByType             = "by-type"
+	CLIOptionTop                = "top"
+	CLIOptionLocal              = "local"
+	CLIOptionNoCache            = "no-cache"
+	CLIOptionVirtual            = "virtual"
+	CLIOptionSplitSize          = "split-size"
+	CLIOptionSparse             = "sparse"
+	CLIOptionOrder              = "order"
+	CLIOptionHeartbeat          = "heartbeat"
+	CLIOptionHeartbeatFile      = "heartbeat-file"
+	CLIOptionSnapshotToTemp     = "snapshot-to-temp"
+	CLIOptionLockedRetries      = "locked-retries"
+	CLIOptionShadowCopy         = "shadow-copy"
+	CLIOptionShard              = "shard"
+	CLIOptionStallTimeout       = "stall-timeout"
+	CLIOptionWatch              = "watch"
+	CLIOptionItemizeChanges     = "itemize-changes"
+	CLIOptionAuth               = "auth"
+	CLIOptionLargeFiles         = "large-files"
+	CLIOptionLargeSize          = "large-size"
+	CLIOptionConfirmLarge       = "confirm-large"
+	CLIOptionAllowSyncOverlap   = "allow-sync-overlap"
+	CLIOptionIgnoreProfiles     = "ignore-profiles"
 	CLIOptionFiles              = "files"
 	CLIOptionLongFmt            = "long"
 	CLIOptionFixClashesKey      = "fix-clashes"
@@ -253,11 +422,71 @@ const (
 	CLIDecryptionPassword       = "decryption-password"
 	CLIOptionWithLink           = "with-link"
 	CLIOptionDesktopLinks       = "desktop-links"
+	CLIOptionPlaceholders       = "placeholders"
 	CLIOptionKeepParent         = "keep-parent"
+	CLIOptionBackupSuffix       = "backup-suffix"
+	CLIOptionOverwrite          = "overwrite"
+	CLIOptionTar                = "tar"
+	CLIOptionZip                = "zip"
+	CLIOptionBackupDestination  = "dest"
+	CLIOptionBackground         = "background"
+	CLIOptionImportMd5Manifest  = "import"
+	CLIOptionMd5Manifest        = "md5"
+	CLIOptionKeepDaily          = "keep-daily"
+	CLIOptionKeepWeekly         = "keep-weekly"
+	CLIOptionKeepMonthly        = "keep-monthly"
 
 	CLIOptionUploadChunkSize = "upload-chunk-size"
 	CLIOptionUploadRateLimit = "upload-rate-limit"
 
+	CLIOptionUploadRateSchedule = "upload-rate-schedule"
+	CLIOptionGDDir              = "gd-dir"
+	CLIOptionGDName             = "gd-name"
+	CLIOptionOuter              = "outer"
+	CLIOptionASCII              = "ascii"
+	CLIOptionOutput             = "output"
+	CLIOptionAccount            = "account"
+	CLIOptionNamedRemote        = "remote"
+	CLIOptionImpersonate        = "impersonate"
+	CLIOptionProxy              = "proxy"
+	CLIOptionMetadataTTL        = "metadata-ttl"
+	CLIOptionMetadataCacheDisk  = "metadata-cache-disk"
+	CLIOptionCABundle           = "ca-bundle"
+	CLIOptionTLSMinVersion      = "tls-min-version"
+	CLIOptionInsecureSkipVerify = "insecure-skip-verify"
+	CLIOptionBindAddress        = "bind-address"
+	CLIOptionPreferIPv4         = "prefer-ipv4"
+	CLIOptionObjectCache        = "object-cache"
+	CLIOptionObjectCacheSize    = "object-cache-size"
+	CLIOptionRemoteFolderId     = "remote-folder-id"
+	CLIOptionRemoteName         = "remote-name"
+	CLIOptionDevice             = "device"
+	CLIOptionReadOnly           = "readonly"
+	CLIOptionScope              = "scope"
+	CLIOptionManual             = "manual"
+	CLIOptionGcloud             = "gcloud"
+	CLIOptionMerge              = "merge"
+	CLIOptionRequireEmpty       = "require-empty"
+	CLIOptionAdopt              = "adopt"
+	CLIOptionDryRun             = "dry-run"
+	CLIOptionAgainst            = "against"
+	CLIOptionSnapshot           = "snapshot"
+	CLIOptionTrack              = "track"
+	CLIOptionEncrypt            = "encrypt"
+	CLIOptionCredentialStore    = "store"
+	CLIOptionMove               = "move"
+	CLIOptionRemote             = "remote"
+	CLIOptionRevisions          = "revisions"
+	CLIOptionDecrypt            = "decrypt"
+	CLIOptionRotate             = "rotate"
+	CLIOptionOldPassword        = "old-password"
+	CLIOptionNewPassword        = "new-password"
+	CLIOptionFrom               = "from"
+	CLIOptionTo                 = "to"
+	CLIOptionGlobal             = "global"
+	CLIOptionRepair             = "repair"
+	CLIOptionLayout             = "layout"
+
 	CLIOptionExportsDumpToSameDirectory = "same-exports-dir"
 
 	CLIOptionTrashed = TrashedKey
@@ -265,13 +494,38 @@ const (
 
 const (
 	DefaultMaxTraversalDepth = -1
+
+	DefaultLargePullFiles = 500000
+	DefaultLargePullSize  = "500G"
 )
 
 const (
 	GoogleApiClientIdEnvKey     = "GOOGLE_API_CLIENT_ID"
 	GoogleApiClientSecretEnvKey = "GOOGLE_API_CLIENT_SECRET"
+	DriveClientIdEnvKey         = "DRIVE_CLIENT_ID"
+	DriveClientSecretEnvKey     = "DRIVE_CLIENT_SECRET"
 	DriveGoMaxProcsKey          = "DRIVE_GOMAXPROCS"
 	GoMaxProcsKey               = "GOMAXPROCS"
+	GDDirEnvKey                 = "GD_DIR"
+	GDNameEnvKey                = "DRIVE_GD_NAME"
+	OuterEnvKey                 = "DRIVE_OUTER"
+	QuotaUserEnvKey             = "DRIVE_QUOTA_USER"
+	ASCIIEnvKey                 = "DRIVE_ASCII"
+	OutputEnvKey                = "DRIVE_OUTPUT"
+	AccountEnvKey               = "DRIVE_ACCOUNT"
+	RemoteEnvKey                = "DRIVE_REMOTE"
+	ImpersonateEnvKey           = "DRIVE_IMPERSONATE"
+	ProxyEnvKey                 = "DRIVE_PROXY"
+	MetadataTTLEnvKey           = "DRIVE_METADATA_TTL"
+	MetadataCacheDiskEnvKey     = "DRIVE_METADATA_CACHE_DISK"
+	CABundleEnvKey              = "DRIVE_CA_BUNDLE"
+	TLSMinVersionEnvKey         = "DRIVE_TLS_MIN_VERSION"
+	InsecureSkipVerifyEnvKey    = "DRIVE_INSECURE_SKIP_VERIFY"
+	BindAddressEnvKey           = "DRIVE_BIND_ADDRESS"
+	PreferIPv4EnvKey            = "DRIVE_PREFER_IPV4"
+	XDGConfigHomeEnvKey         = "XDG_CONFIG_HOME"
+	ObjectCacheEnvKey           = "DRIVE_OBJECT_CACHE"
+	ObjectCacheSizeEnvKey       = "DRIVE_OBJECT_CACHE_SIZE"
 )
 
 const (
@@ -291,6 +545,16 @@ var (
 var docMap = map[string][]string{
 	AboutKey: []string{
 		DescAbout,
+		fmt.Sprintf("Pass in `-%s` to print how this context authenticates, including the OAuth2 client in use", CLIOptionAuth),
+		fmt.Sprintf("The client of the credentials file is overridden by setting both %s and %s", DriveClientIdEnvKey, DriveClientSecretEnvKey),
+	},
+	BackupKey: []string{
+		DescBackup, "Each run pushes into a directory named after the day e.g",
+		"\n\t$ drive backup -dest backups docs",
+		"\nmakes backups/2025-01-15/docs. Files unchanged since the previous",
+		"snapshot are copied on the server instead of being uploaded again",
+		fmt.Sprintf("\nSnapshots are pruned after each successful run if any of `-%s`, `-%s` or `-%s` are set",
+			CLIOptionKeepDaily, CLIOptionKeepWeekly, CLIOptionKeepMonthly),
 	},
 	CopyKey: []string{
 		DescCopy,
@@ -301,6 +565,8 @@ var docMap = map[string][]string{
 	DiffKey: []string{
 		DescDiff, "Accepts multiple remote paths for line by line comparison",
 		skipChecksumNote,
+		fmt.Sprintf("`drive diff -%s <path> <revision> <revision>` compares two revisions of a remote file", CLIOptionRevisions),
+		fmt.Sprintf("`drive diff -%s <path>` lists the file's revisions", CLIOptionRevisions),
 	},
 	EditDescriptionShortKey: []string{
 		DescEdit, "Accepts multiple remote paths as well as ids",
@@ -311,14 +577,70 @@ var docMap = map[string][]string{
 	FeaturesKey: []string{
 		DescFeatures,
 	},
+	IndexKey: []string{
+		DescIndex,
+		fmt.Sprintf("`drive index %s -%s checksums.txt` seeds the local checksum cache", ImportKey, CLIOptionMd5Manifest),
+		"from an md5sum or hashdeep manifest so already hashed files aren't hashed again",
+		fmt.Sprintf("`drive index -%s checksums.txt` does the same", CLIOptionImportMd5Manifest),
+	},
 	InitKey: []string{
 		DescInit, "Requests for access to your Google Drive",
 		"Creating a folder that contains your credentials",
 		"Note: `init` in an already initialized drive will erase the old credentials",
+		fmt.Sprintf("Pass in `--%s path` before or after the command, or set %s, to keep the metadata outside of", CLIOptionGDDir, GDDirEnvKey),
+		"the synced tree e.g `drive --gd-dir ~/meta/photos.gd init /mnt/ro/photos`",
+		fmt.Sprintf("Pass in `--%s name` before any command, or set %s, to name the metadata directory other than %s", CLIOptionGDName, GDNameEnvKey, config.DefaultGDDirSuffix),
+		"e.g `drive --gd-name _gd init` where hidden directories can't be created. Later commands need the same name",
+		"A sub-directory can be initialized as a context of its own, which commands within it then use, and which the",
+		fmt.Sprintf("enclosing context leaves alone. Pass in `--%s 1` before the command, or set %s, to use the enclosing context", CLIOptionOuter, OuterEnvKey),
+		fmt.Sprintf("Pass in `--%s name` before the command, or set %s, to add a named account e.g work to", CLIOptionAccount, AccountEnvKey),
+		"an initialized context, keeping its other credentials. Other commands then use it with the same option",
+		fmt.Sprintf("Pass in `--%s name` before the command, or set %s, to add a named remote e.g backup with its own", CLIOptionNamedRemote, RemoteEnvKey),
+		fmt.Sprintf("credentials, under the account of the same name unless `--%s` is also passed in, and its own remote folder.", CLIOptionAccount),
+		fmt.Sprintf("Other commands then sync with it with the same option, as do push and pull with `-%s name`", CLIOptionNamedRemote),
+		fmt.Sprintf("Pass in `--%s user@domain` before the command, or set %s, with `-%s` for a service account", CLIOptionImpersonate, ImpersonateEnvKey, ServiceAccountJSONFileKey),
+		"with domain-wide delegation to act as that user. The user is kept with the context's credentials",
+		fmt.Sprintf("Pass in `--%s url` before any command, or set %s, to go through an HTTP or SOCKS5 proxy", CLIOptionProxy, ProxyEnvKey),
+		"e.g `drive --proxy socks5://127.0.0.1:1080 init`. HTTP_PROXY, HTTPS_PROXY and NO_PROXY are otherwise respected",
+		fmt.Sprintf("Behind a TLS-intercepting proxy, pass in `--%s file.pem` and optionally `--%s 1.2` before any command,", CLIOptionCABundle, CLIOptionTLSMinVersion),
+		fmt.Sprintf("or set %s and %s. `--%s` turns off verification, for debugging only", CABundleEnvKey, TLSMinVersionEnvKey, CLIOptionInsecureSkipVerify),
+		fmt.Sprintf("Pass in `--%s eth0`, an interface or an IP address, and `--%s` before any command, or set %s and %s,", CLIOptionBindAddress, CLIOptionPreferIPv4, BindAddressEnvKey, PreferIPv4EnvKey),
+		"to pick the network path that connections take",
+		fmt.Sprintf("Pass in `--%s 2m` before any command, or set %s, to reuse the metadata of remote files for that long,", CLIOptionMetadataTTL, MetadataTTLEnvKey),
+		fmt.Sprintf("and also `--%s`, or set %s, to reuse it in the runs that follow e.g `drive status` then `drive push`", CLIOptionMetadataCacheDisk, MetadataCacheDiskEnvKey),
+		fmt.Sprintf("Pass in `--%s dir` before any command, or set %s, to keep downloaded content in dir by checksum,", CLIOptionObjectCache, ObjectCacheEnvKey),
+		fmt.Sprintf("so that pulling it again in any context copies it instead. `--%s`, or %s, caps it, by default at %s", CLIOptionObjectCacheSize, ObjectCacheSizeEnvKey, DefaultObjectCacheSize),
+		fmt.Sprintf("Pass in `--%s %s` before any command, or set %s, to silence its output and only print a single", CLIOptionOutput, OutputJSONSummary, OutputEnvKey),
+		"line JSON summary of the run, with the changes that it played and its errors, when it exits",
+		fmt.Sprintf("Every flag of every command can also be set with %s<FLAG> e.g %sQUIET=true or %sEXPORT=pdf,docx,", FlagEnvPrefix, FlagEnvPrefix, FlagEnvPrefix),
+		"the flag uppercased with its dashes turned into underscores. Flags on the commandline take precedence",
+		fmt.Sprintf("Pass in `-%s id` to bind the context to that remote folder, whatever it is named,", CLIOptionRemoteFolderId),
+		"instead of the root of the Drive. The binding is by id so it survives renames of the folder",
+		fmt.Sprintf("or `-%s \"Laptop Backups/ThinkPad\"` to bind it to the folder at that path, which is created if missing", CLIOptionRemoteName),
+		"If that folder already has files, or another registered context is bound to it, init asks before binding to it.",
+		fmt.Sprintf("Pass in `-%s` to bind to it anyway, merging its files with the local ones on the next push or pull,", CLIOptionMerge),
+		fmt.Sprintf("`-%s` for the local directory, which must be empty, to take its files as they are on the next pull,", CLIOptionAdopt),
+		fmt.Sprintf("or `-%s` to fail instead e.g in scripts that must only ever bind fresh folders", CLIOptionRequireEmpty),
+		"The browser is opened to grant access and redirected back to drive on a short-lived local port",
+		fmt.Sprintf("Pass in `-%s` to instead paste the authorization code that the browser shows", CLIOptionManual),
+		fmt.Sprintf("With the Cloud SDK installed, pass in `-%s` to reuse the credentials of `gcloud auth application-default login`,", CLIOptionGcloud),
+		"which init otherwise offers to do whenever it finds them",
+		fmt.Sprintf("On machines without a browser, pass in `-%s` to authorize by entering a short code from another device", CLIOptionDevice),
+		fmt.Sprintf("Pass in `-%s` to request a narrower scope than the whole Drive: drive.file, the least privileged, only reaches", CLIOptionScope),
+		"the files that drive created or was used to open, drive.appdata binds the context to the hidden application data folder",
+		fmt.Sprintf("and drive.readonly, which `-%s` also requests, is for e.g backup verification or audit machines,", CLIOptionReadOnly),
+		"in which case push, trash and delete refuse to run in the context",
+		"The scope is recorded in the context, and commands that it doesn't allow fail with an insufficient scope error",
 	},
 	PullKey: []string{
 		DescPull, "Downloads content from the remote drive or modifies",
 		" local content to match that on your Google Drive",
+		fmt.Sprintf("Pass in `-%s` to stream content as a tar archive e.g `drive pull -%s dir > dir.tar`", CLIOptionTar, CLIOptionTar),
+		fmt.Sprintf("Pass in `-%s` to package content into a zip file e.g `drive pull -%s dir out.zip`", CLIOptionZip, CLIOptionZip),
+		fmt.Sprintf("A failed zip pull leaves out.zip.%s behind, which re-running the pull resumes from", ZipPartialSuffix),
+		fmt.Sprintf("Pass in `-%s -%s -%s` to keep all your starred files in the local %s folder, a working set that push leaves alone", CLIOptionStarred, CLIOptionAllStarred, CLIOptionVirtual, StarredFolder),
+		fmt.Sprintf("Folders pushed with `-%s` must be pulled with the same `-%s` for them to be seen flat", CLIOptionShard, CLIOptionShard),
+		fmt.Sprintf("`-%s 1m` keeps pulling without prompting, only pulling again the folders that the changes feed reports changes in", CLIOptionWatch),
 		skipChecksumNote,
 	},
 	PushKey: []string{
@@ -326,16 +648,29 @@ var docMap = map[string][]string{
 		"Push comes in a couple of flavors",
 		"\t* Ordinary push: `drive push path1 path2 path3`",
 		"\t* Mounted push: `drive push -m path1 [path2 path3] drive_context_path`",
+		fmt.Sprintf("\t* Queued push: `drive push -%s path1 path2`, played later by `drive %s`", CLIOptionQueue, FlushKey),
+		fmt.Sprintf("\t* Push as: `drive push -%s 'logs/{{hostname}}/{{date \"2006/01/02\"}}/syslog.gz' /var/log/syslog.gz`", CLIOptionAs),
+		fmt.Sprintf("\t* Media push: `drive push -%s 'photos/{{exif.Year}}/{{exif.Month}}' /media/card/DCIM`, by the EXIF date of each photo", CLIOptionMediaPath),
+		"\t  else its modification time. Sidecars e.g IMG_1.xmp go with their RAW file, and content already in its folder is skipped",
+		fmt.Sprintf("`-%s good` records a known-good point after pushing, `-%s -%s good` later previews offline what changed locally since", CLIOptionSnapshot, CLIOptionDryRun, CLIOptionAgainst),
+		fmt.Sprintf("Files over Drive's size limit can be pushed in parts with e.g `-%s 100G`, kept under %s", CLIOptionSplitSize, SplitPartsFolderPath),
+		fmt.Sprintf("Folders of very many files can be fanned out remotely into 256 hashed subfolders with e.g `-%s photos/raw`", CLIOptionShard),
+		fmt.Sprintf("The -%s and -%s paths may hold the templates {{hostname}}, {{date}} with an optional Go time layout, and {{env \"NAME\"}}", CLIOptionAs, CLIOptionPushDestination),
 		skipChecksumNote,
 	},
 	ListKey: []string{
 		DescList,
 		"List the information of a remote path not necessarily present locally",
 		"Allows printing of long options and by default does minimal printing",
+		fmt.Sprintf("Filters e.g -%s, -%s, -%s and -%s are sent to Drive as part of the query where possible,", CLIOptionMatchMime, CLIOptionNamePattern, CLIOptionModifiedAfter, CLIOptionModifiedBefore),
+		"so that filtered listings of large folders only retrieve what matches",
+		fmt.Sprintf("Use -%s %s to order names like your file manager, it sorts by name unless -%s is also set", CLIOptionCollate, CollateLocale, SortKey),
 	},
 	MoveKey: []string{
 		DescMove,
 		"Moves files/folders between folders",
+		"If the destination already has a file of the same name, the move aborts unless",
+		fmt.Sprintf("`-%s .bak` renames that file out of the way first, or `-%s` trashes it", CLIOptionBackupSuffix, CLIOptionOverwrite),
 	},
 	OpenKey: []string{
 		DescOpen, fmt.Sprintf("toggle between %q=bool and %q=bool",
@@ -344,6 +679,90 @@ var docMap = map[string][]string{
 	PubKey: []string{
 		DescPublish, "Accepts multiple paths",
 	},
+	ContextsKey: []string{
+		DescContexts,
+		fmt.Sprintf("The registry is opt-in: `drive %s -%s` in a context creates it and adds that context,", ContextsKey, CLIOptionTrack),
+		"after which contexts are added on init, dropped on deinit and stamped on every pull and push",
+	},
+	CredentialsKey: []string{
+		DescCredentials,
+		fmt.Sprintf("`drive %s -%s` encrypts the refresh token so that a copied .gd directory can't be used", CredentialsKey, CLIOptionEncrypt),
+		fmt.Sprintf("The passphrase is read from %s, from the output of the command in %s,", config.CredentialsPassphraseEnvKey, config.CredentialsPassphraseCommandEnvKey),
+		"or is otherwise prompted for, and then remembered for 15 minutes in the OS keychain or, without one, within $XDG_RUNTIME_DIR",
+		fmt.Sprintf("`drive %s -%s %s` keeps the refresh tokens in macOS Keychain, the Secret Service", CredentialsKey, CLIOptionCredentialStore, config.CredentialStoreKeychain),
+		"e.g GNOME Keyring via libsecret's secret-tool, or Windows Credential Manager instead of credentials.json",
+	},
+	CryptKey: []string{
+		DescCrypt,
+		fmt.Sprintf("`drive %s %s` wraps the content keys of the context under a new passphrase, creating them on first use,", CryptKey, CryptRekeyKey),
+		fmt.Sprintf("without uploading anything again. With `-%s` a new key is added that files are pushed under from then on", CLIOptionRotate),
+		fmt.Sprintf("`drive %s %s` lists the key versions and the files pushed under each", CryptKey, CryptStatusKey),
+		fmt.Sprintf("A copy of the keyring is kept at %s of the remote root, and restored from if the local one is missing", cryptKeyringRemoteName),
+	},
+	MigrateKey: []string{
+		DescMigrate,
+		fmt.Sprintf("e.g `drive %s -%s personal -%s work [path]` with accounts added by `drive --account <name> init`", MigrateKey, CLIOptionFrom, CLIOptionTo),
+		"Files are copied server side while shared with the destination account, and streamed through this machine otherwise",
+		"Interrupted migrations resume where they stopped, and every copy is verified against its source once done",
+		"Copies that don't verify are trashed and copied again on the next run",
+		fmt.Sprintf("`drive %s -%s` instead upgrades a context written by an earlier version of drive to the current layout", MigrateKey, CLIOptionLayout),
+		"in place: its cache files are moved out of .gd, its indices kept in files of their own are moved into the index",
+		"and its credentials are rewritten in the current format, keeping the refresh tokens, so nothing is pulled afresh",
+	},
+	ConfigKey: []string{
+		DescConfig,
+		fmt.Sprintf("Keys are of the form [<command>.]<option> e.g `drive %s %s pull.export` or `drive %s %s hidden true`,", ConfigKey, ConfigGetKey, ConfigKey, ConfigSetKey),
+		fmt.Sprintf("options without a command being in the %q section that applies to every command", GlobalConfigSection),
+		"Unknown options and values of the wrong type are rejected",
+		fmt.Sprintf("`%s` prints the value that commands run in the context default to, which `-%s` limits to the global config.json", ConfigGetKey, CLIOptionGlobal),
+		fmt.Sprintf("`%s` checks the credentials, settings and index of the context for truncated JSON, unknown keys and", ConfigValidateKey),
+		fmt.Sprintf("invalid values. `-%s` drops unknown credential keys and damaged index entries, which are rebuilt later", CLIOptionRepair),
+	},
+	CmpKey: []string{
+		DescCmp,
+		"Accepts <remoteA> <remoteB> and reports the files only in either and those that differ",
+		"Exits with a non-zero status if they differ e.g to verify a migration between folders",
+	},
+	ChecksumKey: []string{
+		DescChecksum,
+		"Paths are relative to each argument, so the manifests of two folders can be compared with diff(1)",
+		fmt.Sprintf("e.g `diff <(drive %s -%s photos) <(drive %s photos)`", ChecksumKey, CLIOptionRemote, ChecksumKey),
+		fmt.Sprintf("The manifests can also be fed to `drive %s %s -%s`", IndexKey, ImportKey, CLIOptionMd5Manifest),
+	},
+	VerifyKey: []string{
+		DescVerify,
+		"Local files are hashed afresh, bypassing the checksum cache, to catch corruption e.g bit rot",
+		fmt.Sprintf("With e.g `-%s 5%%` each run verifies a different random sample, covering everything over time", CLIOptionSample),
+		"Exits with a non-zero status if any file doesn't match its remote checksum",
+	},
+	FlushKey: []string{
+		DescFlush,
+		"Each push is dequeued once it succeeds, so a failed flush can simply be re-run",
+		fmt.Sprintf("Pass in `-%s` to see what is queued", ListKey),
+	},
+	StatusKey: []string{
+		DescStatus,
+		"Conflicting paths changed on both sides since they were last synced, modified ones on only one side",
+		fmt.Sprintf("Only sizes and modification times are compared unless -%s=false is passed in", CLIOptionIgnoreChecksum),
+		"The status of each subtree is cached until a local file or the remote changes",
+	},
+	TransferKey: []string{
+		DescTransfer,
+		"Accepts <src>... <destContextPath>:<destRemotePath> e.g `drive transfer photos ~/work:/archive/photos`",
+		"Copies are made server side, temporarily sharing the sources when the accounts differ",
+		fmt.Sprintf("Pass in `-%s` to trash the sources once copied", CLIOptionMove),
+	},
+	DeAuthKey: []string{
+		DescDeAuth,
+		"Revokes the access granted to drive at Google instead of only forgetting the credentials locally,",
+		"then removes the credentials of the account in use, keeping the context's files and index",
+		fmt.Sprintf("Select a named account with `drive --account <name> %s`, which also removes that account", DeAuthKey),
+		fmt.Sprintf("Run `drive %s` again to authorize the context anew", InitKey),
+	},
+	RelocateKey: []string{
+		DescRelocate, "Run it from anywhere within the context's new location",
+		fmt.Sprintf("or, for a context whose metadata lives elsewhere with `--%s`, from its new root", CLIOptionGDDir),
+	},
 	RenameKey: []string{
 		DescRename, "Accepts <src> <newName>",
 	},
@@ -353,6 +772,8 @@ var docMap = map[string][]string{
 		"Specify the emails to share with as well as the message to send them on notification",
 		"Accepted values for:\n+ accountType: ",
 		DescAccountTypes, "\n+ roles:", DescRoles,
+		fmt.Sprintf("Pass in `-%s` to share everything under folders too. Changes are applied as a batch with progress,", RecursiveKey),
+		fmt.Sprintf("and with `-%s` a batch that can't complete is reverted instead of leaving the tree partly shared", CLIOptionRollback),
 	},
 	StatKey: []string{
 		DescStat, "provides detailed information about a remote file",
@@ -365,9 +786,19 @@ var docMap = map[string][]string{
 	TrashKey: []string{
 		DescTrash, "Sends a list of remote files to trash",
 	},
+	LockKey: []string{
+		DescLock, "Records you and the time on each file, which anyone's push then refuses to overwrite or delete",
+		fmt.Sprintf("unless it is passed `-%s`. A file locked by someone else can only be locked with `-%s`", ForceKey, ForceKey),
+		"Locks are advisory: they are only honored by drive, not by Google Drive itself",
+	},
+	UnlockKey: []string{
+		DescUnlock, fmt.Sprintf("A file locked by someone else can only be unlocked with `-%s`", ForceKey),
+	},
 	UnshareKey: []string{
 		DescUnshare, "Accepts multiple paths",
 		"Accepted values for accountTypes::", DescAccountTypes,
+		fmt.Sprintf("Pass in `-%s` to unshare everything under folders too, and `-%s` to restore", RecursiveKey, CLIOptionRollback),
+		"the revoked permissions if the batch can't complete",
 	},
 	UntrashKey: []string{
 		DescUntrash, "takes remote files out of the trash",
@@ -0,0 +1,114 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ignoreProfiles are named sets of .driveignore clauses that a context can
+// enable with the `ignore-profiles` key in its .driverc. They are layered
+// under the context's own .driveignore, so an include clause e.g
+// `!node_modules` there still wins over a profile's exclusion.
+var ignoreProfiles = map[string][]string{
+	"node": {
+		"(^|/)node_modules$",
+		"(^|/)bower_components$",
+		"(^|/)\\.npm$",
+		"(^|/)npm-debug\\.log",
+		"(^|/)yarn-(debug|error)\\.log",
+	},
+	"python": {
+		"(^|/)__pycache__$",
+		"\\.py[cod]$",
+		"(^|/)\\.?venv$",
+		"(^|/)\\.tox$",
+		"(^|/)\\.pytest_cache$",
+		"\\.egg-info$",
+	},
+	"macos": {
+		"(^|/)\\.DS_Store$",
+		"(^|/)\\._",
+		"(^|/)\\.AppleDouble$",
+		"(^|/)\\.Spotlight-V100$",
+		"(^|/)\\.Trashes$",
+		"(^|/)\\.fseventsd$",
+	},
+	"windows": {
+		"(^|/)(?i:thumbs\\.db)$",
+		"(^|/)(?i:ehthumbs\\.db)$",
+		"(^|/)(?i:desktop\\.ini)$",
+		"(^|/)\\$RECYCLE\\.BIN$",
+		"\\.lnk$",
+	},
+	"media-raw": {
+		"(?i:\\.(cr2|cr3|nef|arw|orf|rw2|raf|dng|srw|pef))$",
+	},
+}
+
+func ignoreProfileNames() []string {
+	var names []string
+	for name := range ignoreProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ignoreProfileClauses resolves the comma separated profile names
+// e.g "node,macos" into the clauses of those profiles.
+func ignoreProfileClauses(profiles string) (clauses []string, err error) {
+	seen := map[string]bool{}
+	var unknown []string
+
+	for _, name := range strings.Split(profiles, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		profileClauses, ok := ignoreProfiles[name]
+		if !ok {
+			unknown = append(unknown, name)
+			continue
+		}
+		clauses = append(clauses, profileClauses...)
+	}
+
+	if len(unknown) >= 1 {
+		err = invalidArgumentsErr(fmt.Errorf("unknown ignore profiles %q, available profiles are %q", unknown, ignoreProfileNames()))
+	}
+
+	return clauses, err
+}
+
+// contextIgnoreProfiles retrieves the ignore profiles enabled in the
+// global namespace of the .driverc that applies to the context at absPath.
+func contextIgnoreProfiles(absPath string) (string, error) {
+	rcMappings, err := ResourceMappings(absPath)
+	if err != nil {
+		if NotExist(err) {
+			err = nil
+		}
+		return "", err
+	}
+
+	merged := mergeNamespaces(rcMappings)
+	profiles, _ := merged[strings.ToLower(CLIOptionIgnoreProfiles)].(string)
+	return profiles, nil
+}
@@ -0,0 +1,145 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIgnoreProfiles(t *testing.T) {
+	testCases := []struct {
+		profiles         string
+		custom           []string
+		mustErr          bool
+		mustBeIgnored    []string
+		mustNotBeIgnored []string
+		comment          string
+	}{
+		{
+			comment:          "no profiles",
+			profiles:         "",
+			mustNotBeIgnored: []string{"node_modules", ".DS_Store", "a.pyc"},
+		},
+		{
+			comment:          "node and macos",
+			profiles:         "node, macos",
+			mustBeIgnored:    []string{"node_modules", "web/node_modules", ".DS_Store", "photos/._IMG_01.jpg"},
+			mustNotBeIgnored: []string{"node_modules_notes.txt", "a.pyc", "Thumbs.db"},
+		},
+		{
+			comment:          "names are case insensitive and repeats are fine",
+			profiles:         "Python,python,WINDOWS",
+			mustBeIgnored:    []string{"__pycache__", "lib/a.pyc", ".venv", "Thumbs.db", "Desktop.ini"},
+			mustNotBeIgnored: []string{"a.py", "venvs", "thumbs.db.txt"},
+		},
+		{
+			comment:          "media-raw",
+			profiles:         "media-raw",
+			mustBeIgnored:    []string{"IMG_0001.CR2", "dsc.nef", "a/b/c.dng"},
+			mustNotBeIgnored: []string{"IMG_0001.jpg", "dng", "a.nefx"},
+		},
+		{
+			comment:          "custom clauses are layered over the profiles",
+			profiles:         "node",
+			custom:           []string{"\\.log$", "!^node_modules$"},
+			mustBeIgnored:    []string{"web/node_modules", "server.log"},
+			mustNotBeIgnored: []string{"node_modules"},
+		},
+		{
+			comment:  "unknown profiles",
+			profiles: "node,cobol",
+			mustErr:  true,
+		},
+	}
+
+	for _, tc := range testCases {
+		clauses, err := ignoreProfileClauses(tc.profiles)
+		if tc.mustErr {
+			if err == nil {
+				t.Errorf("%s: expected an error", tc.comment)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: err %v", tc.comment, err)
+			continue
+		}
+
+		ignorer, err := combineIgnoresWith(t, tc.custom, clauses...)
+		if err != nil {
+			t.Errorf("%s: err %v", tc.comment, err)
+			continue
+		}
+
+		for _, p := range tc.mustBeIgnored {
+			if !anyMatch(ignorer, p) {
+				t.Errorf("%s: expected %q to be ignored", tc.comment, p)
+			}
+		}
+		for _, p := range tc.mustNotBeIgnored {
+			if anyMatch(ignorer, p) {
+				t.Errorf("%s: expected %q to not be ignored", tc.comment, p)
+			}
+		}
+	}
+}
+
+// combineIgnoresWith is combineIgnores for a context whose
+// .driveignore holds the custom clauses.
+func combineIgnoresWith(t *testing.T, custom []string, profileClauses ...string) (func(string) bool, error) {
+	root, err := ioutil.TempDir("", "profiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	content := strings.Join(custom, "\n")
+	if err := ioutil.WriteFile(filepath.Join(root, DriveIgnoreSuffix), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return combineIgnores(root, profileClauses...)
+}
+
+func TestIgnoreProfilesPrecedence(t *testing.T) {
+	profileClauses := []string{"\\.tmp$", "!^build/keep\\.tmp$"}
+	custom := []string{"^build/", "!^scratch\\.tmp$"}
+
+	ignorer, err := combineIgnoresWith(t, custom, profileClauses...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		p       string
+		ignored bool
+		comment string
+	}{
+		{p: "a.tmp", ignored: true, comment: "ignored by a profile"},
+		{p: "scratch.tmp", ignored: false, comment: "a custom exception keeps what a profile ignores"},
+		{p: "build/keep.tmp", ignored: true, comment: "a profile exception doesn't keep what a custom clause ignores"},
+		{p: "build/a.go", ignored: true, comment: "ignored by a custom clause"},
+		{p: "a.go", ignored: false, comment: "ignored by neither"},
+	}
+
+	for _, tc := range testCases {
+		if got := ignorer(tc.p); got != tc.ignored {
+			t.Errorf("%s: %q ignored: got %v want %v", tc.comment, tc.p, got, tc.ignored)
+		}
+	}
+}
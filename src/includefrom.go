@@ -0,0 +1,181 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// filterRule is a rule of an rsync-style filter file, which includes
+// or excludes the paths that its pattern matches.
+type filterRule struct {
+	include bool
+	dirOnly bool
+	re      *regexp.Regexp
+}
+
+// PathFilter picks the paths to transfer by rsync-style include and
+// exclude rules, the first rule matching a path deciding whether it
+// is transferred. Paths that no rule matches are transferred.
+//
+// As with rsync, a pattern starting with "/" is anchored at the root of
+// the context, any other pattern matches the end of a path e.g "*.log"
+// matches the names of files at any depth, and a trailing "/" restricts
+// a pattern to directories. "*" and "?" match within a path segment,
+// "**" also across segments and a trailing "/***" matches a directory
+// and everything under it. Excluded directories are pruned whole.
+type PathFilter struct {
+	rules []*filterRule
+}
+
+// ReadIncludeFrom reads the rules of a PathFilter from the file at p,
+// one per line. Each line is an include pattern, unless it starts with
+// "- " to exclude or with "+ " to explicitly include. Blank lines and
+// lines starting with "#" or ";" are skipped.
+func ReadIncludeFrom(p string) (*PathFilter, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return parsePathFilter(f)
+}
+
+func parsePathFilter(r io.Reader) (*PathFilter, error) {
+	pf := &PathFilter{}
+	scanner := bufio.NewScanner(r)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+
+		rule, err := parseFilterRule(line)
+		if err != nil {
+			return nil, invalidArgumentsErr(fmt.Errorf("line %d: %q: %v", lineNumber, line, err))
+		}
+		pf.rules = append(pf.rules, rule)
+	}
+	return pf, scanner.Err()
+}
+
+func parseFilterRule(line string) (*filterRule, error) {
+	rule := &filterRule{include: true}
+	switch {
+	case strings.HasPrefix(line, "+ "):
+		line = line[2:]
+	case strings.HasPrefix(line, "- "):
+		rule.include = false
+		line = line[2:]
+	}
+
+	pattern := line
+	if pattern == "" || pattern == "/" {
+		return nil, fmt.Errorf("empty pattern")
+	}
+
+	anchored := strings.HasPrefix(pattern, "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+
+	// "dir/***" matches dir itself as well as everything under it.
+	var suffix string
+	if strings.HasSuffix(pattern, "/***") {
+		pattern = strings.TrimSuffix(pattern, "/***")
+		suffix = "(/.*)?"
+	} else if strings.HasSuffix(pattern, "/") {
+		rule.dirOnly = true
+		pattern = strings.TrimSuffix(pattern, "/")
+	}
+
+	prefix := "(^|/)"
+	if anchored {
+		prefix = "^"
+	}
+
+	re, err := regexp.Compile(prefix + globToRegexp(pattern) + suffix + "$")
+	if err != nil {
+		return nil, err
+	}
+	rule.re = re
+	return rule, nil
+}
+
+func globToRegexp(glob string) string {
+	var buf []string
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				buf = append(buf, ".*")
+				for i+1 < len(glob) && glob[i+1] == '*' {
+					i++
+				}
+			} else {
+				buf = append(buf, "[^/]*")
+			}
+		case '?':
+			buf = append(buf, "[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				buf = append(buf, regexp.QuoteMeta(glob[i:i+1]))
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			buf = append(buf, "["+class+"]")
+			i += end + 1
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+			}
+			buf = append(buf, regexp.QuoteMeta(glob[i:i+1]))
+		default:
+			buf = append(buf, regexp.QuoteMeta(glob[i:i+1]))
+		}
+	}
+	return strings.Join(buf, "")
+}
+
+// admits reports whether the path relToRoot e.g "/docs/a.txt" passes
+// the filter. A nil filter admits every path.
+func (pf *PathFilter) admits(relToRoot string, isDir bool) bool {
+	if pf == nil {
+		return true
+	}
+
+	p := strings.Trim(relToRoot, "/")
+	if p == "" || p == "." {
+		return true
+	}
+
+	for _, rule := range pf.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		if rule.re.MatchString(p) {
+			return rule.include
+		}
+	}
+	return true
+}
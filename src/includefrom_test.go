@@ -0,0 +1,84 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPathFilterAdmits(t *testing.T) {
+	rules := strings.Join([]string{
+		"# back up documents and photos, but no caches or logs",
+		"- *.log",
+		"- cache/",
+		"/Documents/***",
+		"+ /Photos/",
+		"+ /Photos/20[12][0-9]/***",
+		"- /Photos/*",
+		"+ /Music/**/*.flac",
+		"+ /Music/**/",
+		"- *",
+	}, "\n")
+
+	pf, err := parsePathFilter(strings.NewReader(rules))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{path: "/", isDir: true, want: true},
+		{path: "/Documents", isDir: true, want: true},
+		{path: "/Documents/taxes/2016.pdf", want: true},
+		{path: "/Documents/build.log", want: false},
+		{path: "/Documents/cache", isDir: true, want: false},
+		// A file named cache isn't matched by the directory only rule.
+		{path: "/Documents/cache", want: true},
+		{path: "/Photos", isDir: true, want: true},
+		{path: "/Photos/2015", isDir: true, want: true},
+		{path: "/Photos/2015/beach/1.jpg", want: true},
+		{path: "/Photos/1999", isDir: true, want: false},
+		{path: "/Photos/thumbs.db", want: false},
+		{path: "/Music", isDir: true, want: false},
+		{path: "/Music/album", isDir: true, want: true},
+		{path: "/Music/album/01.flac", want: true},
+		{path: "/Music/album/01.mp3", want: false},
+		{path: "/Videos", isDir: true, want: false},
+		{path: "notes.txt", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := pf.admits(tt.path, tt.isDir); got != tt.want {
+			t.Errorf("%q (dir %v): got %v want %v", tt.path, tt.isDir, got, tt.want)
+		}
+	}
+
+	var nilFilter *PathFilter
+	if !nilFilter.admits("/anything", false) {
+		t.Errorf("a nil filter should admit every path")
+	}
+}
+
+func TestParsePathFilterErrors(t *testing.T) {
+	for _, rules := range []string{"- ", "/", "+ /"} {
+		if _, err := parsePathFilter(strings.NewReader(rules)); err == nil {
+			t.Errorf("%q: expected an error", rules)
+		}
+	}
+}
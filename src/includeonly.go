@@ -0,0 +1,110 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// IncludeOnly restricts push and pull to the paths matching any of its
+// patterns, as an alternative to enumerating everything to ignore.
+//
+// Patterns are those of an include file, see PathFilter, so "*.pdf"
+// matches PDFs at any depth and "/Reports/" the top level Reports folder
+// along with everything under it. Folders that don't match are still
+// walked for matching files in them, but aren't transferred themselves.
+type IncludeOnly struct {
+	rules []*filterRule
+}
+
+// ParseIncludeOnly compiles the patterns of an IncludeOnly.
+func ParseIncludeOnly(patterns ...string) (*IncludeOnly, error) {
+	inc := &IncludeOnly{}
+	for _, pattern := range patterns {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if strings.HasPrefix(pattern, "- ") || strings.HasPrefix(pattern, "+ ") {
+			return nil, invalidArgumentsErr(fmt.Errorf("%q: include-only patterns can't be prefixed by \"+ \" or \"- \"", pattern))
+		}
+		rule, err := parseFilterRule(pattern)
+		if err != nil {
+			return nil, invalidArgumentsErr(fmt.Errorf("%q: %v", pattern, err))
+		}
+		inc.rules = append(inc.rules, rule)
+	}
+	if len(inc.rules) < 1 {
+		return nil, invalidArgumentsErr(fmt.Errorf("no include-only patterns"))
+	}
+	return inc, nil
+}
+
+// readDriveInclude reads the IncludeOnly of the .driveinclude file of
+// the context at root, one pattern per line. It returns nil if there is
+// no such file.
+func readDriveInclude(root string) (*IncludeOnly, error) {
+	patterns, err := readCommentedFile(filepath.Join(root, DriveIncludeSuffix), "#")
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if len(patterns) < 1 {
+		return nil, nil
+	}
+	return ParseIncludeOnly(patterns...)
+}
+
+// includes reports whether the path relToRoot e.g "/docs/a.pdf" or any
+// of the folders that it is in matches a pattern. A nil IncludeOnly
+// includes every path.
+func (inc *IncludeOnly) includes(relToRoot string, isDir bool) bool {
+	if inc == nil {
+		return true
+	}
+
+	p := strings.Trim(relToRoot, "/")
+	if p == "" || p == "." {
+		return true
+	}
+
+	if inc.matches(p, isDir) {
+		return true
+	}
+	for i := strings.LastIndex(p, "/"); i > 0; i = strings.LastIndex(p, "/") {
+		p = p[:i]
+		if inc.matches(p, true) {
+			return true
+		}
+	}
+	return false
+}
+
+func (inc *IncludeOnly) matches(p string, isDir bool) bool {
+	for _, rule := range inc.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		if rule.re.MatchString(p) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIncludeOnlyIncludes(t *testing.T) {
+	inc, err := ParseIncludeOnly("*.pdf", " *.docx", "/Reports/", "")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{path: "/", isDir: true, want: true},
+		{path: "/a.pdf", want: true},
+		{path: "/deep/down/b.docx", want: true},
+		{path: "/deep/down/b.doc", want: false},
+		{path: "/deep", isDir: true, want: false},
+		{path: "/Reports", isDir: true, want: true},
+		{path: "/Reports/2016/q1.xlsx", want: true},
+		// A file named Reports isn't matched by the directory only pattern.
+		{path: "/Reports", want: false},
+		{path: "/old/Reports/q1.xlsx", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := inc.includes(tt.path, tt.isDir); got != tt.want {
+			t.Errorf("%q (dir %v): got %v want %v", tt.path, tt.isDir, got, tt.want)
+		}
+	}
+
+	var nilInclude *IncludeOnly
+	if !nilInclude.includes("/anything", false) {
+		t.Errorf("a nil IncludeOnly should include every path")
+	}
+}
+
+func TestParseIncludeOnlyErrors(t *testing.T) {
+	for _, patterns := range [][]string{nil, {"", " "}, {"- *.log"}, {"+ *.pdf"}, {"/"}} {
+		if _, err := ParseIncludeOnly(patterns...); err == nil {
+			t.Errorf("%q: expected an error", patterns)
+		}
+	}
+}
+
+func TestReadDriveInclude(t *testing.T) {
+	root, err := ioutil.TempDir("", "driveinclude")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	inc, err := readDriveInclude(root)
+	if err != nil || inc != nil {
+		t.Fatalf("without a .driveinclude: got %v, %v want nil, nil", inc, err)
+	}
+
+	contents := "# documents only\n*.pdf\n\n/Reports/\n"
+	if err := ioutil.WriteFile(filepath.Join(root, DriveIncludeSuffix), []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	inc, err = readDriveInclude(root)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if !inc.includes("/x/a.pdf", false) || !inc.includes("/Reports/a.txt", false) || inc.includes("/a.txt", false) {
+		t.Errorf("unexpected matches from %q", contents)
+	}
+}
@@ -15,29 +15,70 @@
 package drive
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
+	"strings"
+	"time"
 
 	"golang.org/x/net/context"
+	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
+
+	"github.com/odeke-em/drive/config"
 )
 
 func (g *Commands) Init() error {
-	g.context.ClientId = os.Getenv(GoogleApiClientIdEnvKey)
-	g.context.ClientSecret = os.Getenv(GoogleApiClientSecretEnvKey)
-	if g.context.ClientId == "" || g.context.ClientSecret == "" {
-		g.context.ClientId = "354790962074-7rrlnuanmamgg1i4feed12dpuq871bvd.apps.googleusercontent.com"
-		g.context.ClientSecret = "RHjKdah8RrHFwu6fcc0uEVCw"
+	if offerGcloudCredentials() {
+		return g.InitWithGcloudCredentials()
 	}
+	return g.initOAuth2(RetrieveRefreshTokenByLoopback)
+}
+
+// InitWithManualCode initializes the context by pasting the authorization
+// code shown in the browser, for when the loopback flow can't be used.
+func (g *Commands) InitWithManualCode() error {
+	return g.initOAuth2(RetrieveRefreshToken)
+}
+
+// InitWithDeviceFlow initializes the context by the OAuth 2.0 device
+// flow, which doesn't need a browser on the machine being initialized.
+func (g *Commands) InitWithDeviceFlow() error {
+	return g.initOAuth2(RetrieveRefreshTokenByDevice)
+}
+
+func (g *Commands) initOAuth2(retrieveRefreshToken func(context.Context, *config.Context) (*oauth2.Token, error)) error {
+	client := initClient(g.context.AbsPath, os.Getenv)
+	g.context.ClientId = client.id
+	g.context.ClientSecret = client.secret
 
 	ctx := context.Background()
-	refreshToken, err := RetrieveRefreshToken(ctx, g.context)
+	token, err := retrieveRefreshToken(ctx, g.context)
 	if err != nil {
 		return err
 	}
 
-	g.context.RefreshToken = refreshToken
-	return g.context.Write()
+	// The consent screen lets the user withhold scopes, so make sure
+	// that the scope recorded for the context is the one granted.
+	granted, _ := token.Extra("scope").(string)
+	if err := checkGrantedScope(scopeFor(g.context), granted); err != nil {
+		return err
+	}
+
+	g.context.CredentialType = config.CredentialTypeOAuth2
+	g.context.RefreshToken = token.RefreshToken
+	g.context.AccessToken, g.context.TokenExpiry = "", 0
+	g.context.GSAJWTConfig = nil
+	g.context.LastKnownRoot = g.context.AbsPath
+	if err := g.resolveRemoteRoot(); err != nil {
+		return err
+	}
+	if err := g.context.Write(); err != nil {
+		return err
+	}
+
+	g.registerContext(false)
+	return nil
 }
 
 // We don't need to perform an OAuth2.0 exchange
@@ -58,17 +99,197 @@ func (g *Commands) InitWithServiceAccount(gsaFilepath string) error {
 		return err
 	}
 
-	jwtConfig, err := google.JWTConfigFromJSON(blob, DriveScope)
+	jwtConfig, err := google.JWTConfigFromJSON(blob, scopeFor(g.context))
 	if err != nil {
 		return err
 	}
 
 	// Next we'll just transfer the attributes directly
 	// by means of JSON marshaling the already vetted JWTConfig
+	g.context.CredentialType = config.CredentialTypeServiceAccount
 	g.context.GSAJWTConfig = jwtConfig
+	g.context.RefreshToken = ""
+	g.context.AccessToken, g.context.TokenExpiry = "", 0
+	g.context.LastKnownRoot = g.context.AbsPath
+	if err := g.resolveRemoteRoot(); err != nil {
+		return err
+	}
+	if g.context.Subject != "" {
+		g.log.Logf("Impersonating %s by domain-wide delegation\n", g.context.Subject)
+	}
 
 	// Since it validates alright, let's now write it to disk
-	return g.context.Write()
+	if err := g.context.Write(); err != nil {
+		return err
+	}
+
+	g.registerContext(false)
+	return nil
+}
+
+// resolveRemoteRoot checks that the remote folder that the context is
+// being bound to, if any, is an accessible folder since every remote
+// path will resolve from it. A folder given by path is created if need be.
+// Binding to a folder that has files or is another context's is guarded.
+func (g *Commands) resolveRemoteRoot() error {
+	rem, err := remoteForContext(g.context)
+	if err != nil {
+		return err
+	}
+
+	id, p := g.context.RemoteRootId, g.context.RemoteRootPath
+	if id == "" && p == "" {
+		return g.guardInitOverlap(rem, nil)
+	}
+
+	var f *File
+	if id != "" {
+		f, err = rem.FindById(id)
+	} else {
+		f, err = ensureRemoteFolder(rem, p)
+		id = p
+	}
+	if err != nil {
+		return remoteLookupErr(fmt.Errorf("remote folder %q: %v", id, err))
+	}
+	if !f.IsDir {
+		return invalidArgumentsErr(fmt.Errorf("%q (%s) is not a folder", f.Name, id))
+	}
+	if f.Labels != nil && f.Labels.Trashed {
+		return invalidArgumentsErr(fmt.Errorf("%q (%s) is in the trash", f.Name, id))
+	}
+
+	if err := g.guardInitOverlap(rem, f); err != nil {
+		return err
+	}
+
+	g.context.RemoteRootId = f.Id
+	g.log.Logf("Binding to remote folder %s (%s)\n", customQuote(f.Name), f.Id)
+	return nil
+}
+
+// ensureRemoteFolder resolves the folder at the remote path p from the
+// root of the Drive, creating whichever of its folders don't exist yet.
+func ensureRemoteFolder(rem *Remote, p string) (*File, error) {
+	segments := NonEmptyTrimmedStrings(strings.Split(p, RemoteSeparator)...)
+	if len(segments) < 1 {
+		return nil, invalidArgumentsErr(fmt.Errorf("%q doesn't name a folder under the root", p))
+	}
+
+	parent, err := rem.FindById("root")
+	if err != nil {
+		return nil, err
+	}
+
+	created := false
+	for _, segment := range segments {
+		var child *File
+		if !created {
+			child, err = rem.findByPathRecv(parent.Id, []string{segment})
+			if err != nil && err != ErrPathNotExists {
+				return nil, err
+			}
+		}
+
+		if child == nil {
+			args := upsertOpt{
+				parentId:   parent.Id,
+				src:        &File{IsDir: true, Name: segment, ModTime: time.Now()},
+				retryCount: MaxFailedRetryCount,
+			}
+			if child, err = rem.UpsertByComparison(&args); err != nil {
+				return nil, err
+			}
+			if child == nil {
+				return nil, ErrPathNotExists
+			}
+			// Nothing can exist under a folder that was just created.
+			created = true
+		}
+
+		parent = child
+	}
+
+	return parent, nil
+}
+
+// Relocate rebinds a context whose directory was moved or renamed.
+func (g *Commands) Relocate() error {
+	if !g.context.Relocated() {
+		g.log.Logf("%s is already bound to its current location\n", customQuote(g.context.AbsPath))
+		return nil
+	}
+
+	from := g.context.LastKnownRoot
+	g.context.LastKnownRoot = g.context.AbsPath
+	if err := g.context.Write(); err != nil {
+		return err
+	}
+
+	g.log.Logf("Rebound context from %s to %s\n", customQuote(from), customQuote(g.context.AbsPath))
+
+	if config.RegistryExists() {
+		if err := config.Unregister(from); err != nil {
+			g.log.LogErrf("contexts: %v\n", err)
+		}
+		g.registerContext(false)
+	}
+	return nil
+}
+
+// EncryptCredentials encrypts the credentials at rest with a newly
+// prompted passphrase, or stores them back in plain text if decrypt is set.
+func (g *Commands) EncryptCredentials(decrypt bool) error {
+	if decrypt {
+		if !g.context.Encrypted {
+			return illogicalStateErr(fmt.Errorf("credentials are not encrypted"))
+		}
+		g.context.SetPassphrase(nil)
+		if err := g.context.Write(); err != nil {
+			return err
+		}
+		g.log.Logln("Credentials are now stored in plain text")
+		return nil
+	}
+
+	passphrase := []byte(os.Getenv(config.CredentialsPassphraseEnvKey))
+	if len(passphrase) < 1 {
+		var err error
+		if passphrase, err = config.PromptPassphrase("New passphrase: ", true); err != nil {
+			return err
+		}
+	}
+
+	g.context.SetPassphrase(passphrase)
+	if err := g.context.Write(); err != nil {
+		return err
+	}
+	g.log.Logln("Credentials are now encrypted")
+	return nil
+}
+
+// StoreCredentials moves the refresh tokens to the given credential
+// store, either the credentials file or the OS keychain.
+func (g *Commands) StoreCredentials(store string) error {
+	wasInKeychain := g.context.InKeychain()
+	if err := g.context.SetCredentialStore(store); err != nil {
+		return invalidArgumentsErr(err)
+	}
+	if err := g.context.Write(); err != nil {
+		return err
+	}
+
+	if !g.context.InKeychain() {
+		if wasInKeychain {
+			if err := g.context.ForgetKeychainTokens(); err != nil {
+				return err
+			}
+		}
+		g.log.Logln("Refresh tokens are now stored in the credentials file")
+		return nil
+	}
+	g.log.Logln("Refresh tokens are now stored in the OS keychain")
+	return nil
 }
 
 func (g *Commands) DeInit() error {
@@ -81,5 +302,12 @@ func (g *Commands) DeInit() error {
 		return accepted(status)
 	}
 
-	return g.context.DeInitialize(prompt, true)
+	if err := g.context.DeInitialize(prompt, true); err != nil {
+		return err
+	}
+
+	if err := config.Unregister(g.context.AbsPath); err != nil {
+		g.log.LogErrf("contexts: %v\n", err)
+	}
+	return nil
 }
@@ -0,0 +1,89 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"testing"
+
+	drive "google.golang.org/api/drive/v2"
+)
+
+func TestEnsureRemoteFolder(t *testing.T) {
+	files := map[string]*drive.File{
+		"root": {Id: "root", Title: "root", MimeType: DriveFolderMimeType},
+		"a":    {Id: "a", Title: "a", MimeType: DriveFolderMimeType},
+	}
+	tree := fakeTreeTransport(t, files, map[string][]string{"root": {"a"}})
+
+	var created []string
+	transport := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if req.Method == "GET" {
+			return tree.RoundTrip(req)
+		}
+		var f drive.File
+		if err := json.NewDecoder(req.Body).Decode(&f); err != nil {
+			t.Fatalf("%s %s: %v", req.Method, req.URL, err)
+		}
+		if len(f.Parents) != 1 {
+			t.Fatalf("expected %q created in one folder, got %v", f.Title, f.Parents)
+		}
+		created = append(created, f.Parents[0].Id+"/"+f.Title)
+		f.Id = f.Title
+		data, err := json.Marshal(&f)
+		if err != nil {
+			return nil, err
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       ioutil.NopCloser(bytes.NewReader(data)),
+			Request:    req,
+		}, nil
+	})
+	rem, err := remoteFromClient(&http.Client{Transport: transport})
+	if err != nil {
+		t.Fatalf("remoteFromClient: %v", err)
+	}
+
+	// Only the folders that don't exist yet are created, and nothing is
+	// looked up under a folder that was just created.
+	f, err := ensureRemoteFolder(rem, "/a/b/c/")
+	if err != nil {
+		t.Fatalf("ensure: %v", err)
+	}
+	if f.Id != "c" || !f.IsDir {
+		t.Errorf("got %+v want the folder c", f)
+	}
+	if want := []string{"a/b", "b/c"}; !reflect.DeepEqual(created, want) {
+		t.Errorf("created %q want %q", created, want)
+	}
+
+	created = nil
+	if f, err = ensureRemoteFolder(rem, "a"); err != nil || f.Id != "a" {
+		t.Errorf("existing: got %v, %v want the folder a", f, err)
+	}
+	if len(created) != 0 {
+		t.Errorf("expected nothing created for an existing folder, got %q", created)
+	}
+
+	if _, err := ensureRemoteFolder(rem, "/"); err == nil {
+		t.Errorf("expected the root itself to be refused")
+	}
+}
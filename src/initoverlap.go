@@ -0,0 +1,163 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/odeke-em/drive/config"
+	drive "google.golang.org/api/drive/v2"
+)
+
+// otherContextsBoundTo returns the paths of the registered contexts,
+// other than that at absPath, that are bound to the remote folder rootId.
+// resolve looks up the id of the folder of an entry's remote root.
+func otherContextsBoundTo(entries []*config.RegistryEntry, absPath, rootId string, resolve func(*config.RegistryEntry) string) (paths []string) {
+	for _, entry := range entries {
+		if entry.Path != absPath && resolve(entry) == rootId {
+			paths = append(paths, entry.Path)
+		}
+	}
+	return paths
+}
+
+// registryRootResolver resolves the remote roots of the registry's
+// entries, as they were configured, to the ids of their folders in the
+// Drive of rem whose details are in about. Entries of other accounts and
+// paths that no longer resolve have no id.
+func registryRootResolver(rem *Remote, about *drive.About) func(*config.RegistryEntry) string {
+	account := ""
+	if about.User != nil {
+		account = about.User.EmailAddress
+	}
+
+	return func(entry *config.RegistryEntry) string {
+		if entry.Account != "" && account != "" && entry.Account != account {
+			return ""
+		}
+		if entry.RemoteRootId != "" {
+			return entry.RemoteRootId
+		}
+		if entry.RemoteRoot == "" || rootLike(entry.RemoteRoot) {
+			return about.RootFolderId
+		}
+		// The path is from the root of the Drive rather than from
+		// the remote root that rem may already be bound to.
+		segments := NonEmptyTrimmedStrings(strings.Split(entry.RemoteRoot, RemoteSeparator)...)
+		f, err := rem.findByPathRecv(about.RootFolderId, segments)
+		if err != nil || f == nil {
+			return ""
+		}
+		return f.Id
+	}
+}
+
+// hasLocalFiles reports whether dir has anything in it but gdPath,
+// the metadata directory of the context at dir.
+func hasLocalFiles(dir, gdPath string) (bool, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	for _, info := range infos {
+		if filepath.Join(dir, info.Name()) != filepath.Clean(gdPath) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// guardInitOverlap stops init from binding the context to folder, or to
+// the root of the Drive if folder is nil, if it already has files or is
+// the remote folder of another registered context, unless confirmed or
+// allowed by InitOverlap. The root of the Drive having files is expected.
+func (g *Commands) guardInitOverlap(rem *Remote, folder *File) error {
+	var problems []string
+	name, rootId := "the root of the Drive", ""
+	if folder != nil {
+		name, rootId = customQuote(folder.Name), folder.Id
+		hasFiles, err := rem.hasChildren(folder.Id)
+		if err != nil {
+			return remoteLookupErr(fmt.Errorf("listing %s: %v", name, err))
+		}
+		if hasFiles {
+			problems = append(problems, "already has files")
+		}
+	}
+
+	entries, err := config.ReadRegistry()
+	if err != nil {
+		g.log.LogErrf("contexts: %v\n", err)
+	}
+	if len(entries) >= 1 {
+		about, err := rem.About()
+		if err != nil {
+			return remoteLookupErr(fmt.Errorf("looking up the root of the Drive: %v", err))
+		}
+		if rootId == "" {
+			rootId = about.RootFolderId
+		}
+		resolve := registryRootResolver(rem, about)
+		for _, p := range otherContextsBoundTo(entries, g.context.AbsPath, rootId, resolve) {
+			problems = append(problems, fmt.Sprintf("is the remote folder of %s too", p))
+		}
+	}
+
+	if len(problems) < 1 {
+		return nil
+	}
+
+	mode := ""
+	if g.opts != nil {
+		mode = g.opts.InitOverlap
+	}
+
+	msg := fmt.Sprintf("%s %s", name, strings.Join(problems, " and "))
+	switch mode {
+	case CLIOptionRequireEmpty:
+		return illogicalStateErr(fmt.Errorf("%s, yet -%s is set", msg, CLIOptionRequireEmpty))
+	case CLIOptionMerge:
+		g.log.LogErrf("warning: %s, its files will be merged with the local ones\n", msg)
+		return nil
+	case CLIOptionAdopt:
+		hasFiles, err := hasLocalFiles(g.context.AbsPath, g.context.GDPath())
+		if err != nil {
+			return err
+		}
+		if hasFiles {
+			return invalidArgumentsErr(fmt.Errorf("-%s needs %s to be empty, pass in -%s to merge its files instead",
+				CLIOptionAdopt, g.context.AbsPath, CLIOptionMerge))
+		}
+		g.log.LogErrf("warning: %s, run `drive %s` to fetch its files\n", msg, PullKey)
+		return nil
+	}
+
+	if !g.opts.canPrompt() {
+		return cannotPromptErr(fmt.Errorf("%s; pass in -%s, -%s or -%s", msg, CLIOptionMerge, CLIOptionAdopt, CLIOptionRequireEmpty))
+	}
+
+	g.log.LogErrf("warning: %s\n", msg)
+	if status := promptForChanges("Bind to it anyway, merging its files with the local ones? [Y/n]: "); !accepted(status) {
+		return status.Error()
+	}
+	return nil
+}
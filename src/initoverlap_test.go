@@ -0,0 +1,124 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/odeke-em/drive/config"
+)
+
+func TestOtherContextsBoundTo(t *testing.T) {
+	entries := []*config.RegistryEntry{
+		{Path: "/home/a/photos", RemoteRoot: "/Photos"},
+		{Path: "/home/a/docs", RemoteRoot: "/"},
+		{Path: "/home/a/backup", RemoteRootId: "folder"},
+		{Path: "/home/a/new", RemoteRoot: "/New"},
+		{Path: "/home/a/moved", RemoteRoot: "/Gone"},
+	}
+
+	// The remote roots are resolved as configured, at the time of use.
+	folders := map[string]string{"/Photos": "folder", "/": "root", "/New": "folder"}
+	resolve := func(entry *config.RegistryEntry) string {
+		if entry.RemoteRootId != "" {
+			return entry.RemoteRootId
+		}
+		return folders[entry.RemoteRoot]
+	}
+
+	tests := []struct {
+		absPath string
+		rootId  string
+		want    []string
+	}{
+		{absPath: "/home/a/new", rootId: "folder", want: []string{"/home/a/photos", "/home/a/backup"}},
+		{absPath: "/home/a/new", rootId: "root", want: []string{"/home/a/docs"}},
+		{absPath: "/home/a/docs", rootId: "root"},
+		{absPath: "/home/a/new", rootId: "other"},
+	}
+
+	for _, tt := range tests {
+		if got := otherContextsBoundTo(entries, tt.absPath, tt.rootId, resolve); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q bound to %q: got %q want %q", tt.absPath, tt.rootId, got, tt.want)
+		}
+	}
+}
+
+func TestConfiguredRemoteRoot(t *testing.T) {
+	tests := []struct {
+		context *config.Context
+		path    string
+		id      string
+	}{
+		{context: &config.Context{}, path: "/"},
+		{context: &config.Context{RemoteRootPath: "Backups/photos/", RemoteRootId: "resolved"}, path: "/Backups/photos"},
+		{context: &config.Context{RemoteRootId: "folder"}, id: "folder"},
+	}
+
+	for i, tt := range tests {
+		p, id := configuredRemoteRoot(tt.context)
+		if p != tt.path || id != tt.id {
+			t.Errorf("#%d: got (%q, %q) want (%q, %q)", i, p, id, tt.path, tt.id)
+		}
+	}
+}
+
+func TestHasLocalFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "initoverlap")
+	if err != nil {
+		t.Fatalf("tempdir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	empty := filepath.Join(dir, "empty")
+	onlyGD := filepath.Join(dir, "onlygd")
+	withFiles := filepath.Join(dir, "withfiles")
+	for _, p := range []string{empty, filepath.Join(onlyGD, ".gd"), filepath.Join(withFiles, ".gd")} {
+		if err := os.MkdirAll(p, 0755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(withFiles, "a.txt"), nil, 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	tests := []struct {
+		dir    string
+		gdPath string
+		want   bool
+	}{
+		{dir: empty, gdPath: filepath.Join(empty, ".gd"), want: false},
+		{dir: onlyGD, gdPath: filepath.Join(onlyGD, ".gd"), want: false},
+		// With its metadata kept elsewhere, the .gd directory is a file of its own.
+		{dir: onlyGD, gdPath: filepath.Join(dir, "meta.gd"), want: true},
+		{dir: withFiles, gdPath: filepath.Join(withFiles, ".gd"), want: true},
+		{dir: filepath.Join(dir, "missing"), gdPath: filepath.Join(dir, "missing", ".gd"), want: false},
+	}
+
+	for _, tt := range tests {
+		got, err := hasLocalFiles(tt.dir, tt.gdPath)
+		if err != nil {
+			t.Errorf("%q: %v", tt.dir, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q with %q: got %v want %v", tt.dir, tt.gdPath, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// itemizeAttrCount is the number of attribute columns of an
+// rsync --itemize-changes line, in the order c s t p o g u a x.
+const itemizeAttrCount = 9
+
+// itemize describes change the way that rsync's --itemize-changes does
+// e.g ">f.st...... photos/a.jpg" for a file whose size and modification
+// time changed, or "*deleting   photos/b.jpg". Sent is set for changes
+// that go to the remote. Changes that transfer nothing yield "".
+func itemize(change *Change, sent bool) string {
+	op := change.Op()
+	if op == OpNone || op == OpIndexAddition {
+		return ""
+	}
+
+	subject := change.Src
+	if op == OpDelete {
+		subject = change.Dest
+	}
+	if subject == nil {
+		return ""
+	}
+
+	name := strings.TrimPrefix(change.Path, "/")
+	fileType := "f"
+	if subject.IsDir {
+		fileType = "d"
+		name += "/"
+	}
+
+	if op == OpDelete {
+		return fmt.Sprintf("%-11s %s", "*deleting", name)
+	}
+
+	updateType := "<"
+	if !sent {
+		updateType = ">"
+	}
+	// Folders carry no content, creating one is a local change
+	// and otherwise only their attributes are updated.
+	if subject.IsDir {
+		updateType = "."
+		if change.Dest == nil {
+			updateType = "c"
+		}
+	}
+
+	attrs := []byte(strings.Repeat(".", itemizeAttrCount))
+	if change.Dest == nil {
+		attrs = []byte(strings.Repeat("+", itemizeAttrCount))
+	} else {
+		mask := fileDifferences(change.Src, change.Dest, change.IgnoreChecksum)
+		if checksumDiffers(mask) && !change.IgnoreChecksum && !subject.IsDir {
+			attrs[0] = 'c'
+		}
+		if sizeDiffers(mask) && !subject.IsDir {
+			attrs[1] = 's'
+		}
+		if modTimeDiffers(mask) {
+			attrs[2] = 't'
+		}
+	}
+
+	return fmt.Sprintf("%s%s%s %s", updateType, fileType, attrs, name)
+}
+
+// itemizing wraps fn to print the itemized line of each change that it
+// applies successfully, if itemized changes were asked for. The lines go
+// to stdout even with -quiet, so that scripts parsing them can silence
+// everything else.
+func (g *Commands) itemizing(sent bool, fn func(*Change) error) func(*Change) error {
+	if !g.opts.ItemizeChanges {
+		return fn
+	}
+	return func(change *Change) error {
+		// The line is worked out before fn updates what change refers to.
+		line := itemize(change, sent)
+		err := fn(change)
+		if err == nil && line != "" {
+			fmt.Fprintln(os.Stdout, line)
+		}
+		return err
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"testing"
+	"time"
+)
+
+func TestItemize(t *testing.T) {
+	now := time.Now()
+	file := func(size int64, modTime time.Time, md5 string) *File {
+		return &File{Size: size, ModTime: modTime, Md5Checksum: md5}
+	}
+	dir := &File{IsDir: true, ModTime: now}
+
+	testCases := []struct {
+		desc   string
+		change *Change
+		sent   bool
+		want   string
+	}{
+		{
+			desc:   "new file pushed",
+			change: &Change{Path: "/a/b.txt", Src: file(3, now, "x")},
+			sent:   true,
+			want:   "<f+++++++++ a/b.txt",
+		},
+		{
+			desc:   "new folder pulled",
+			change: &Change{Path: "/photos", Src: dir},
+			want:   "cd+++++++++ photos/",
+		},
+		{
+			desc:   "content changed",
+			change: &Change{Path: "/a.txt", Src: file(4, now, "x"), Dest: file(3, now.Add(-time.Hour), "y"), IgnoreConflict: true},
+			want:   ">fcst...... a.txt",
+		},
+		{
+			desc:   "only the modification time changed",
+			change: &Change{Path: "/a.txt", Src: file(3, now, "x"), Dest: file(3, now.Add(-time.Hour), "x")},
+			want:   ">f..t...... a.txt",
+		},
+		{
+			desc:   "checksums ignored",
+			change: &Change{Path: "/a.txt", Src: file(4, now, "x"), Dest: file(3, now, "y"), IgnoreChecksum: true, IgnoreConflict: true},
+			sent:   true,
+			want:   "<f.s....... a.txt",
+		},
+		{
+			desc:   "forced though unchanged",
+			change: &Change{Path: "/a.txt", Src: file(3, now, "x"), Dest: file(3, now, "x"), Force: true},
+			sent:   true,
+			want:   "<f......... a.txt",
+		},
+		{
+			desc:   "file deleted",
+			change: &Change{Path: "/old.txt", Dest: file(3, now, "x")},
+			want:   "*deleting   old.txt",
+		},
+		{
+			desc:   "folder deleted",
+			change: &Change{Path: "/old", Dest: dir},
+			sent:   true,
+			want:   "*deleting   old/",
+		},
+		{
+			desc:   "unchanged",
+			change: &Change{Path: "/a.txt", Src: file(3, now, "x"), Dest: file(3, now, "x")},
+			want:   "",
+		},
+	}
+
+	for _, tc := range testCases {
+		if got := itemize(tc.change, tc.sent); got != tc.want {
+			t.Errorf("%s: got %q want %q", tc.desc, got, tc.want)
+		}
+	}
+}
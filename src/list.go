@@ -16,7 +16,9 @@ package drive
 
 import (
 	"fmt"
+	"path"
 	"strings"
+	"time"
 
 	"github.com/odeke-em/log"
 )
@@ -37,6 +39,8 @@ type traversalSt struct {
 	explicitNoPrompt bool
 	sorters          []string
 	matchQuery       *matchQuery
+	// visit when set is handed each file in place of printing it.
+	visit func(parent string, f *File)
 }
 
 func sorters(opts *Options) []string {
@@ -46,7 +50,11 @@ func sorters(opts *Options) []string {
 
 	meta := *(opts.Meta)
 	retr, ok := meta[SortKey]
-	if !ok {
+	if !ok || len(retr) < 1 {
+		// Collating by locale is only meaningful when sorting by name.
+		if localeCollated(opts) {
+			return []string{NameKey}
+		}
 		return nil
 	}
 
@@ -69,7 +77,10 @@ func (g *Commands) ListMatches() error {
 
 	inTrash := trashed(g.opts.TypeMask)
 
-	mq := g.createMatchQuery(false)
+	mq, err := g.createMatchQuery(false)
+	if err != nil {
+		return err
+	}
 
 	mq.titleSearches = append(mq.titleSearches, fuzzyStringsValuePair{
 		fuzzyLevel: Like, values: g.opts.Sources, inTrash: inTrash, joiner: Or,
@@ -98,7 +109,7 @@ func (g *Commands) ListMatches() error {
 				working = false
 				break
 			}
-			if match == nil {
+			if match == nil || !mq.admits(match) {
 				continue
 			}
 
@@ -126,12 +137,15 @@ func (g *Commands) ListMatches() error {
 	return nil
 }
 
-func (g *Commands) createMatchQuery(exactMatch bool) *matchQuery {
+func (g *Commands) createMatchQuery(exactMatch bool) (*matchQuery, error) {
 
 	mimeQuerySearches := []fuzzyStringsValuePair{}
 	titleSearches := []fuzzyStringsValuePair{}
 	ownerSearches := []fuzzyStringsValuePair{}
 
+	var namePatterns []string
+	var modifiedAfter, modifiedBefore *time.Time
+
 	if g.opts.Meta != nil {
 		meta := *(g.opts.Meta)
 		skipMimes, sOk := meta[SkipMimeKeyKey]
@@ -175,6 +189,25 @@ func (g *Commands) createMatchQuery(exactMatch bool) *matchQuery {
 				fuzzyLevel: NotIn, values: notOwner, joiner: And,
 			})
 		}
+
+		for _, pattern := range meta[NamePatternKey] {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, invalidArgumentsErr(fmt.Errorf("name pattern %q: %v", pattern, err))
+			}
+			namePatterns = append(namePatterns, pattern)
+		}
+
+		var err error
+		if after := meta[ModifiedAfterKey]; len(after) >= 1 {
+			if modifiedAfter, err = parseTimeFilter(after[0]); err != nil {
+				return nil, err
+			}
+		}
+		if before := meta[ModifiedBeforeKey]; len(before) >= 1 {
+			if modifiedBefore, err = parseTimeFilter(before[0]); err != nil {
+				return nil, err
+			}
+		}
 	}
 
 	mq := matchQuery{
@@ -183,9 +216,12 @@ func (g *Commands) createMatchQuery(exactMatch bool) *matchQuery {
 		mimeQuerySearches: mimeQuerySearches,
 		titleSearches:     titleSearches,
 		ownerSearches:     ownerSearches,
+		namePatterns:      namePatterns,
+		modifiedAfter:     modifiedAfter,
+		modifiedBefore:    modifiedBefore,
 	}
 
-	return &mq
+	return &mq, nil
 }
 
 func (g *Commands) List(byId bool) error {
@@ -196,7 +232,22 @@ func (g *Commands) List(byId bool) error {
 		resolver = g.rem.FindById
 	}
 
-	mq := g.createMatchQuery(true)
+	mq, err := g.createMatchQuery(true)
+	if err != nil {
+		return err
+	}
+
+	var visit func(string, *File)
+	var report func()
+	if g.opts.ByType {
+		tally := newTypeTally()
+		visit = func(_ string, f *File) { tally.add(f) }
+		report = func() { tally.report(g.log) }
+	} else if g.opts.Top > 0 {
+		top := newTopFiles(g.opts.Top)
+		visit = func(parent string, f *File) { top.add(remoteTopEntry(parent, f)) }
+		report = func() { top.report(g.log, "remote") }
+	}
 
 	for i, relPath := range g.opts.Sources {
 		r, rErr := resolver(relPath)
@@ -220,7 +271,8 @@ func (g *Commands) List(byId bool) error {
 		if remoteRootLike(parentPath) {
 			parentPath = ""
 		}
-		if remoteRootLike(r.Name) {
+		if remoteRootLike(r.Name) || (!byId && rootLike(relPath)) {
+			// Including the root of a context bound to some other folder.
 			r.Name = ""
 		}
 		if rootLike(parentPath) {
@@ -245,6 +297,9 @@ func (g *Commands) List(byId bool) error {
 			mask:       g.opts.TypeMask,
 			sorters:    sorters(g.opts),
 			matchQuery: mq,
+			visit:      visit,
+			// Aggregates only get reported once the traversal is done.
+			explicitNoPrompt: visit != nil,
 		}
 
 		if !g.breadthFirst(travSt, spin) {
@@ -253,10 +308,20 @@ func (g *Commands) List(byId bool) error {
 	}
 	spin.stop()
 
+	if report != nil {
+		report()
+	}
+	if g.opts.Top > 0 && g.opts.Local {
+		return g.topLocal()
+	}
 	return nil
 }
 
 func (g *Commands) listSharedPerPath(relToRootPath string) ([]*keyValue, error) {
+	if err := g.requireWholeDrive("list"); err != nil {
+		return nil, err
+	}
+
 	pagePair := g.rem.FindByPathShared(relToRootPath)
 	errsChan := pagePair.errsChan
 	sharedRemotes := pagePair.filesChan
@@ -395,7 +460,11 @@ func (g *Commands) breadthFirst(travSt traversalSt, spin *playable) bool {
 
 	f := travSt.file
 	if !f.IsDir {
-		f.pretty(g.log, opt)
+		if travSt.visit != nil {
+			travSt.visit(opt.parent, f)
+		} else {
+			f.pretty(g.log, opt)
+		}
 		return true
 	}
 
@@ -416,6 +485,12 @@ func (g *Commands) breadthFirst(travSt traversalSt, spin *playable) bool {
 
 	if travSt.matchQuery != nil {
 		exprExtra := travSt.matchQuery.Stringer()
+		// Folders have to be retrieved even if they don't match for traversal to reach
+		// their matching descendants, as long as they can then be filtered out locally.
+		willDescend := travSt.depth != 0 && !travSt.inTrash && !g.opts.InTrash
+		if exprExtra != "" && willDescend && travSt.matchQuery.widenable() {
+			exprExtra = fmt.Sprintf("(%s or mimeType = '%s')", exprExtra, DriveFolderMimeType)
+		}
 		expr = sepJoinNonEmpty(" and ", fmt.Sprintf("(%s)", expr), exprExtra)
 	}
 
@@ -486,7 +561,14 @@ func (g *Commands) breadthFirst(travSt traversalSt, spin *playable) bool {
 		if onlyFiles && file.IsDir {
 			continue
 		}
-		file.pretty(g.log, opt)
+		if !travSt.matchQuery.admits(file) {
+			continue
+		}
+		if travSt.visit != nil {
+			travSt.visit(opt.parent, file)
+		} else {
+			file.pretty(g.log, opt)
+		}
 		iterCount += 1
 	}
 
@@ -510,6 +592,7 @@ func (g *Commands) breadthFirst(travSt traversalSt, spin *playable) bool {
 				explicitNoPrompt: travSt.explicitNoPrompt,
 				sorters:          travSt.sorters,
 				matchQuery:       travSt.matchQuery,
+				visit:            travSt.visit,
 			}
 
 			if !g.breadthFirst(childSt, spin) {
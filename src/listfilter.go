@@ -0,0 +1,173 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+)
+
+const globMetaChars = "*?[\\"
+
+// parseTimeFilter accepts either a date e.g 2016-01-02 or 2016-01-02T15:04:05Z
+// or a duration offset from now e.g -48h.
+func parseTimeFilter(s string) (*time.Time, error) {
+	t, err := parseDate(s, time.RFC3339, "2006-01-02T15:04", "2006-01-02")
+	if err != nil {
+		t, err = parseDurationOffsetFromNow(s)
+	}
+	if err != nil {
+		return nil, invalidArgumentsErr(fmt.Errorf("%q is neither a date nor a duration from now", s))
+	}
+
+	// Drive only keeps modification times to the second.
+	truncated := t.Truncate(time.Second)
+	return &truncated, nil
+}
+
+// namePatternQuery translates a glob into the narrowest title clause
+// Drive can evaluate. Since Drive's title `contains` only matches prefixes,
+// only a glob's literal prefix can be sent along, the rest of the pattern is
+// checked locally. ok is false if nothing of the glob can be sent.
+func namePatternQuery(pattern string) (query string, ok bool) {
+	i := strings.IndexAny(pattern, globMetaChars)
+	if i < 0 {
+		return fmt.Sprintf("(title = %s)", customQuote(pattern)), true
+	}
+
+	prefix := pattern[:i]
+	if prefix == "" {
+		return "", false
+	}
+	return fmt.Sprintf("(title contains %s)", customQuote(prefix)), true
+}
+
+func namePatternsQuery(patterns []string) string {
+	var queries []string
+	for _, pattern := range patterns {
+		query, ok := namePatternQuery(pattern)
+		if !ok {
+			// A single unrestricted pattern widens the disjunction to everything.
+			return ""
+		}
+		queries = append(queries, query)
+	}
+
+	return strings.Join(queries, " or ")
+}
+
+func modifiedRangeQuery(after, before *time.Time) string {
+	var queries []string
+	if after != nil {
+		queries = append(queries, fmt.Sprintf("(modifiedDate > %s)", customQuote(toUTCString(*after))))
+	}
+	if before != nil {
+		queries = append(queries, fmt.Sprintf("(modifiedDate < %s)", customQuote(toUTCString(*before))))
+	}
+
+	return strings.Join(queries, " and ")
+}
+
+func fuzzyAdmits(fz *fuzzyStringsValuePair, value string, resolve func(string) string) bool {
+	if len(fz.values) < 1 {
+		return true
+	}
+
+	admitted := fz.joiner == And
+	for _, want := range fz.values {
+		if resolve != nil {
+			if resolved := resolve(want); resolved != "" {
+				want = resolved
+			}
+		}
+
+		var match bool
+		switch fz.fuzzyLevel {
+		case Not, NotIn:
+			match = value != want
+		case Like:
+			match = strings.Contains(strings.ToLower(value), strings.ToLower(want))
+		default:
+			match = value == want
+		}
+
+		if fz.joiner == And {
+			admitted = admitted && match
+		} else {
+			admitted = admitted || match
+		}
+	}
+
+	return admitted
+}
+
+// widenable reports whether every filter of the query can also be checked
+// locally, in which case folders can be retrieved regardless of the filters
+// so that traversal doesn't stop at folders that don't match them.
+func (mq *matchQuery) widenable() bool {
+	if mq == nil || mq.starred || len(mq.ownerSearches) >= 1 || len(mq.keywordSearches) >= 1 {
+		return false
+	}
+
+	return len(mq.mimeQuerySearches) >= 1 || len(mq.titleSearches) >= 1 ||
+		len(mq.namePatterns) >= 1 || mq.modifiedAfter != nil || mq.modifiedBefore != nil
+}
+
+// admits checks f locally against the filters that can be, for those that
+// Drive could only narrow down e.g name patterns and for folders retrieved
+// by a widened query.
+func (mq *matchQuery) admits(f *File) bool {
+	if mq == nil || f == nil {
+		return true
+	}
+
+	if mq.modifiedAfter != nil && !f.ModTime.After(*mq.modifiedAfter) {
+		return false
+	}
+	if mq.modifiedBefore != nil && !f.ModTime.Before(*mq.modifiedBefore) {
+		return false
+	}
+
+	if len(mq.namePatterns) >= 1 {
+		matched := false
+		for _, pattern := range mq.namePatterns {
+			if ok, _ := path.Match(pattern, f.Name); ok {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	for i := range mq.mimeQuerySearches {
+		if !fuzzyAdmits(&mq.mimeQuerySearches[i], f.MimeType, mimeTypeFromQuery) {
+			return false
+		}
+	}
+	for i := range mq.titleSearches {
+		if mq.titleSearches[i].starred {
+			continue
+		}
+		if !fuzzyAdmits(&mq.titleSearches[i], f.Name, nil) {
+			return false
+		}
+	}
+
+	return true
+}
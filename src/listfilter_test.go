@@ -0,0 +1,123 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNamePatternsQuery(t *testing.T) {
+	testCases := []struct {
+		patterns []string
+		want     string
+	}{
+		{patterns: nil, want: ""},
+		{patterns: []string{"notes.txt"}, want: `(title = "notes.txt")`},
+		{patterns: []string{"report*"}, want: `(title contains "report")`},
+		{patterns: []string{"report-201?.pdf", "notes"}, want: `(title contains "report-201") or (title = "notes")`},
+		{patterns: []string{"report*", "*.pdf"}, want: ""},
+		{patterns: []string{"Résumé\tfinal"}, want: "(title = \"Résumé\tfinal\")"},
+		{patterns: []string{`say "hi"*`}, want: `(title contains "say \"hi\"")`},
+	}
+
+	for i, tc := range testCases {
+		if got := namePatternsQuery(tc.patterns); got != tc.want {
+			t.Errorf("#%d: got %q want %q", i, got, tc.want)
+		}
+	}
+}
+
+func TestParseTimeFilter(t *testing.T) {
+	testCases := []struct {
+		value   string
+		mustErr bool
+		want    time.Time
+	}{
+		{value: "2016-01-02", want: time.Date(2016, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{value: "2016-01-02T15:04:05Z", want: time.Date(2016, 1, 2, 15, 4, 5, 0, time.UTC)},
+		{value: "yesterday", mustErr: true},
+		{value: "", mustErr: true},
+	}
+
+	for _, tc := range testCases {
+		got, err := parseTimeFilter(tc.value)
+		if tc.mustErr {
+			if err == nil {
+				t.Errorf("%q: expected an error", tc.value)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: err %v", tc.value, err)
+			continue
+		}
+		if !got.Equal(tc.want) {
+			t.Errorf("%q: got %v want %v", tc.value, got, tc.want)
+		}
+	}
+
+	before := time.Now().Add(-48 * time.Hour).Add(-time.Second)
+	got, err := parseTimeFilter("-48h")
+	if err != nil || got.Before(before) || got.After(time.Now()) {
+		t.Errorf("-48h: got %v err %v", got, err)
+	}
+}
+
+func TestMatchQueryAdmits(t *testing.T) {
+	after := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	before := time.Date(2016, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	mq := &matchQuery{
+		namePatterns:   []string{"report*", "*.pdf"},
+		modifiedAfter:  &after,
+		modifiedBefore: &before,
+	}
+
+	if !mq.widenable() {
+		t.Errorf("name and modified filters can be checked locally")
+	}
+
+	expr := mq.Stringer()
+	wantExpr := `((modifiedDate > "2016-01-01T00:00:00.000Z") and (modifiedDate < "2016-06-01T00:00:00.000Z"))`
+	if expr != wantExpr {
+		t.Errorf("expr: got %q want %q", expr, wantExpr)
+	}
+
+	inRange := time.Date(2016, 3, 1, 0, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		f    *File
+		want bool
+	}{
+		{f: &File{Name: "report.doc", ModTime: inRange}, want: true},
+		{f: &File{Name: "scan.pdf", ModTime: inRange}, want: true},
+		{f: &File{Name: "scan.png", ModTime: inRange}, want: false},
+		{f: &File{Name: "report.doc", ModTime: after}, want: false},
+		{f: &File{Name: "report.doc", ModTime: before.Add(time.Hour)}, want: false},
+	}
+
+	for _, tc := range testCases {
+		if got := mq.admits(tc.f); got != tc.want {
+			t.Errorf("%q %v: got %v want %v", tc.f.Name, tc.f.ModTime, got, tc.want)
+		}
+	}
+
+	owned := &matchQuery{
+		ownerSearches: []fuzzyStringsValuePair{{fuzzyLevel: Is, values: []string{"me"}, joiner: Or}},
+	}
+	if owned.widenable() {
+		t.Errorf("owners can't be checked locally")
+	}
+}
@@ -0,0 +1,262 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/odeke-em/drive/config"
+)
+
+const (
+	LocalSnapshotsDir = "snapshots"
+)
+
+// localSnapshotEntry is the state of a local path when a snapshot was taken.
+type localSnapshotEntry struct {
+	Size        int64     `json:"size"`
+	ModTime     time.Time `json:"mod_time"`
+	IsDir       bool      `json:"is_dir,omitempty"`
+	Md5Checksum string    `json:"md5,omitempty"`
+}
+
+// localSnapshot records the local tree under Sources, as of a known-good
+// push, so that later pushes can be previewed against it offline.
+type localSnapshot struct {
+	TakenAt time.Time                      `json:"taken_at"`
+	Sources []string                       `json:"sources"`
+	Entries map[string]*localSnapshotEntry `json:"entries"`
+}
+
+func (e *localSnapshotEntry) toFile(relPath string) *File {
+	return &File{
+		Name:        filepath.Base(relPath),
+		Size:        e.Size,
+		ModTime:     e.ModTime,
+		IsDir:       e.IsDir,
+		Md5Checksum: e.Md5Checksum,
+	}
+}
+
+func validLocalSnapshotName(name string) error {
+	if name == "" || strings.HasPrefix(name, ".") || strings.ContainsAny(name, `/\`) {
+		return invalidArgumentsErr(fmt.Errorf("snapshot name %q must be non-empty, not start with '.' nor contain separators", name))
+	}
+	return nil
+}
+
+func (g *Commands) localSnapshotPath(name string) string {
+	return filepath.Join(g.context.GDPath(), LocalSnapshotsDir, name+".json")
+}
+
+// underSources reports whether relPath lies within any of the sources.
+func underSources(relPath string, sources []string) bool {
+	for _, source := range sources {
+		if rootLike(source) || relPath == source || strings.HasPrefix(relPath, source+RemoteSeparator) {
+			return true
+		}
+	}
+	return false
+}
+
+// localTree walks the sources the way push does, skipping ignored and
+// hidden paths, and returns the files by their paths relative to the root.
+func (g *Commands) localTree() (map[string]*File, error) {
+	tree := map[string]*File{}
+	rootAbsPath := g.context.AbsPathOf("")
+
+	for _, relToRootPath := range g.opts.Sources {
+		walkErr := filepath.Walk(g.context.AbsPathOf(relToRootPath), func(p string, fi os.FileInfo, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+
+			rel, rErr := filepath.Rel(rootAbsPath, p)
+			if rErr != nil {
+				return rErr
+			}
+			if rel == "." {
+				return nil
+			}
+			relPath := remotePathJoin(filepath.ToSlash(rel))
+
+			skip := fi.IsDir() && fi.Name() == config.GDDirSuffix
+			if skip || anyMatch(g.opts.Ignorer, relPath) || isHidden(fi.Name(), g.opts.Hidden) {
+				if fi.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			tree[relPath] = NewLocalFile(p, fi)
+			return nil
+		})
+		if walkErr != nil {
+			return nil, walkErr
+		}
+	}
+
+	return tree, nil
+}
+
+// saveLocalSnapshot records the local tree under the sources as name.
+func (g *Commands) saveLocalSnapshot(name string) error {
+	if err := validLocalSnapshotName(name); err != nil {
+		return err
+	}
+
+	tree, err := g.localTree()
+	if err != nil {
+		return err
+	}
+
+	ls := localSnapshot{
+		TakenAt: time.Now(),
+		Sources: g.opts.Sources,
+		Entries: make(map[string]*localSnapshotEntry),
+	}
+	for relPath, f := range tree {
+		entry := &localSnapshotEntry{Size: f.Size, ModTime: f.ModTime, IsDir: f.IsDir}
+		if !f.IsDir && !g.opts.IgnoreChecksum {
+			entry.Md5Checksum = md5Checksum(f)
+		}
+		ls.Entries[relPath] = entry
+	}
+
+	data, err := json.MarshalIndent(ls, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	p := g.localSnapshotPath(name)
+	if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+		return err
+	}
+	tmpPath := p + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, data, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, p); err != nil {
+		return err
+	}
+
+	g.log.Logf("Snapshot %s saved with %d entries\n", customQuote(name), len(ls.Entries))
+	return nil
+}
+
+func (g *Commands) readLocalSnapshot(name string) (*localSnapshot, error) {
+	if err := validLocalSnapshotName(name); err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadFile(g.localSnapshotPath(name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, invalidArgumentsErr(fmt.Errorf("no such snapshot %q, save one with `push -%s %s`", name, CLIOptionSnapshot, name))
+		}
+		return nil, err
+	}
+
+	ls := &localSnapshot{}
+	if err := json.Unmarshal(data, ls); err != nil {
+		return nil, fmt.Errorf("snapshot %q: %v", name, err)
+	}
+	return ls, nil
+}
+
+// localSnapshotChanges pairs the local tree with a snapshot of it, local
+// files being the sources and the snapshot's the destinations as in a push.
+func (g *Commands) localSnapshotChanges(ls *localSnapshot, tree map[string]*File) []*Change {
+	var relPaths []string
+	for relPath := range tree {
+		relPaths = append(relPaths, relPath)
+	}
+	for relPath := range ls.Entries {
+		if _, ok := tree[relPath]; !ok && underSources(relPath, g.opts.Sources) {
+			relPaths = append(relPaths, relPath)
+		}
+	}
+	sort.Strings(relPaths)
+
+	var cl []*Change
+	for _, relPath := range relPaths {
+		c := &Change{
+			Path:      relPath,
+			Src:       tree[relPath],
+			NoClobber: g.opts.NoClobber,
+			Force:     g.opts.Force,
+			// A snapshot has no remote edits to conflict with.
+			IgnoreConflict: true,
+			IgnoreChecksum: g.opts.IgnoreChecksum,
+		}
+		if entry, ok := ls.Entries[relPath]; ok {
+			c.Dest = entry.toFile(relPath)
+			if !entry.IsDir && entry.Md5Checksum == "" {
+				// Checksums weren't recorded so can't be compared.
+				c.IgnoreChecksum = true
+			}
+		}
+
+		if c.Op() != OpNone {
+			cl = append(cl, c)
+		}
+	}
+
+	return cl
+}
+
+// pushDryRunAgainst previews what a push would change relative to the
+// snapshot name instead of the remote, without any network access.
+func (g *Commands) pushDryRunAgainst(name string) error {
+	ls, err := g.readLocalSnapshot(name)
+	if err != nil {
+		return err
+	}
+
+	for _, source := range g.opts.Sources {
+		if !underSources(source, ls.Sources) {
+			return invalidArgumentsErr(fmt.Errorf("snapshot %q doesn't cover %q, it was taken of %v", name, source, ls.Sources))
+		}
+	}
+
+	tree, err := g.localTree()
+	if err != nil {
+		return err
+	}
+
+	g.log.Logf("Against snapshot %s taken %s\n", customQuote(name), ls.TakenAt.Format(time.RFC3339))
+	g.previewDryRun(g.localSnapshotChanges(ls, tree))
+	return nil
+}
+
+// previewDryRun prints the changes a push would make without making them.
+func (g *Commands) previewDryRun(cl []*Change) {
+	clArg := changeListArg{logy: g.log, changes: cl}
+	if len(cl) < 1 {
+		g.log.Logln("Everything is up-to-date.")
+		return
+	}
+	previewChanges(&clArg, true, opChangeCount(cl))
+}
@@ -0,0 +1,128 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestLocalSnapshotChanges(t *testing.T) {
+	then := time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC)
+	later := then.Add(time.Hour)
+
+	ls := &localSnapshot{
+		Sources: []string{"/"},
+		Entries: map[string]*localSnapshotEntry{
+			"/docs":           {IsDir: true, ModTime: then},
+			"/docs/a.txt":     {Size: 10, ModTime: then, Md5Checksum: "aaa"},
+			"/docs/b.txt":     {Size: 20, ModTime: then, Md5Checksum: "bbb"},
+			"/docs/gone.txt":  {Size: 5, ModTime: then, Md5Checksum: "ggg"},
+			"/docs/touched":   {Size: 7, ModTime: then, Md5Checksum: "ttt"},
+			"/photos/old.jpg": {Size: 9, ModTime: then},
+		},
+	}
+
+	tree := map[string]*File{
+		"/docs":           {Name: "docs", IsDir: true, ModTime: later},
+		"/docs/a.txt":     {Name: "a.txt", Size: 10, ModTime: then, Md5Checksum: "aaa"},
+		"/docs/b.txt":     {Name: "b.txt", Size: 21, ModTime: later, Md5Checksum: "bbc"},
+		"/docs/new.txt":   {Name: "new.txt", Size: 3, ModTime: later, Md5Checksum: "nnn"},
+		"/docs/touched":   {Name: "touched", Size: 7, ModTime: later, Md5Checksum: "ttt"},
+		"/photos":         {Name: "photos", IsDir: true, ModTime: then},
+		"/photos/old.jpg": {Name: "old.jpg", Size: 9, ModTime: then, Md5Checksum: "ooo"},
+	}
+
+	testCases := []struct {
+		sources        []string
+		ignoreChecksum bool
+		want           map[string]Operation
+	}{
+		{
+			sources: []string{"/"},
+			want: map[string]Operation{
+				"/docs/b.txt":    OpMod,
+				"/docs/gone.txt": OpDelete,
+				"/docs/new.txt":  OpAdd,
+				"/docs/touched":  OpMod,
+				"/photos":        OpAdd,
+			},
+		},
+		{
+			sources:        []string{"/docs"},
+			ignoreChecksum: true,
+			want: map[string]Operation{
+				"/docs/b.txt":    OpMod,
+				"/docs/gone.txt": OpDelete,
+				"/docs/new.txt":  OpAdd,
+				"/docs/touched":  OpMod,
+			},
+		},
+	}
+
+	for i, tc := range testCases {
+		g := &Commands{opts: &Options{Sources: tc.sources, IgnoreChecksum: tc.ignoreChecksum}}
+
+		scoped := map[string]*File{}
+		for relPath, f := range tree {
+			if underSources(relPath, tc.sources) {
+				scoped[relPath] = f
+			}
+		}
+
+		cl := g.localSnapshotChanges(ls, scoped)
+		got := map[string]Operation{}
+		var paths []string
+		for _, c := range cl {
+			got[c.Path] = c.Op()
+			paths = append(paths, c.Path)
+		}
+
+		if !sort.StringsAreSorted(paths) {
+			t.Errorf("#%d: expected the changes sorted by path, got %v", i, paths)
+		}
+		if len(got) != len(tc.want) {
+			t.Errorf("#%d: got %v want %v", i, got, tc.want)
+			continue
+		}
+		for relPath, op := range tc.want {
+			if got[relPath] != op {
+				t.Errorf("#%d: %q got op %v want %v", i, relPath, got[relPath], op)
+			}
+		}
+	}
+}
+
+func TestValidLocalSnapshotName(t *testing.T) {
+	testCases := []struct {
+		name    string
+		mustErr bool
+	}{
+		{name: "good"},
+		{name: "2016-01-02"},
+		{name: "", mustErr: true},
+		{name: ".hidden", mustErr: true},
+		{name: "../escape", mustErr: true},
+		{name: `a\b`, mustErr: true},
+	}
+
+	for _, tc := range testCases {
+		err := validLocalSnapshotName(tc.name)
+		if tc.mustErr != (err != nil) {
+			t.Errorf("%q: mustErr %v got err %v", tc.name, tc.mustErr, err)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/odeke-em/log"
+)
+
+// DefaultLockedRetries is how many more times a local file that another
+// process holds locked is opened for its upload before it is skipped.
+const DefaultLockedRetries = 3
+
+// lockedRetryDelay is the delay before the first retry, doubled after each.
+var lockedRetryDelay = time.Second
+
+var errShadowCopyUnsupported = errors.New("shadow copies are only supported on Windows")
+
+// lockedFileErr is returned for a local file that stayed locked
+// by another process, with why it couldn't be read from a shadow
+// copy of its volume instead if one was asked for.
+type lockedFileErr struct {
+	path      string
+	shadowErr error
+}
+
+func (e *lockedFileErr) Error() string {
+	return fmt.Sprintf("%s: %s", e.path, e.reason())
+}
+
+func (e *lockedFileErr) reason() string {
+	if e.shadowErr != nil {
+		return fmt.Sprintf("locked by another process, and no shadow copy: %v", e.shadowErr)
+	}
+	return "locked by another process"
+}
+
+// openLocal opens the local file at p for its upload, retrying with
+// backoff while another process holds it locked. If it stays locked
+// and shadows is set, it is opened in a shadow copy of its volume.
+func openLocal(p string, retries int, shadows *shadowCopies) (*os.File, error) {
+	f, err := os.Open(p)
+	delay := lockedRetryDelay
+	for i := 0; i < retries && isLockedFileErr(err); i++ {
+		time.Sleep(delay)
+		delay *= 2
+		f, err = os.Open(p)
+	}
+	if !isLockedFileErr(err) {
+		return f, err
+	}
+
+	if shadows == nil {
+		return nil, &lockedFileErr{path: p}
+	}
+	shadowPath, err := shadows.path(p)
+	if err == nil {
+		f, err = os.Open(shadowPath)
+	}
+	if err != nil {
+		return nil, &lockedFileErr{path: p, shadowErr: err}
+	}
+	return f, nil
+}
+
+type shadowCopy struct {
+	id     string
+	device string
+	err    error
+}
+
+// shadowCopies are the shadow copies of volumes, each created the first
+// time that a file in it stays locked and kept until release.
+type shadowCopies struct {
+	mu      sync.Mutex
+	volumes map[string]*shadowCopy
+}
+
+func newShadowCopies() *shadowCopies {
+	return &shadowCopies{volumes: make(map[string]*shadowCopy)}
+}
+
+// path is where the file at p is in the shadow copy of its volume.
+func (sc *shadowCopies) path(p string) (string, error) {
+	volume := filepath.VolumeName(p)
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	shadow, ok := sc.volumes[volume]
+	if !ok {
+		shadow = &shadowCopy{}
+		shadow.id, shadow.device, shadow.err = createShadowCopy(volume + string(os.PathSeparator))
+		sc.volumes[volume] = shadow
+	}
+	if shadow.err != nil {
+		return "", shadow.err
+	}
+	return shadow.device + strings.TrimPrefix(p, volume), nil
+}
+
+// release deletes the shadow copies that were created.
+func (sc *shadowCopies) release(logger *log.Logger) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	for volume, shadow := range sc.volumes {
+		if shadow.err == nil {
+			if err := deleteShadowCopy(shadow.id); err != nil {
+				logger.LogErrf("shadow copy of %s: %v\n", volume, err)
+			}
+		}
+		delete(sc.volumes, volume)
+	}
+}
+
+// reportLocked lists the files that were skipped because
+// other processes held them locked throughout the run.
+func (g *Commands) reportLocked() {
+	g.skippedMu.Lock()
+	var locked []*skippedPath
+	for _, sp := range g.skipped {
+		if sp.locked {
+			locked = append(locked, sp)
+		}
+	}
+	g.skippedMu.Unlock()
+
+	if len(locked) < 1 {
+		return
+	}
+	g.log.LogErrf("%d files were skipped because other processes held them locked:\n", len(locked))
+	for _, sp := range locked {
+		g.log.LogErrf("  %s\n", sp.path)
+	}
+}
@@ -0,0 +1,31 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build !windows
+
+package drive
+
+// isLockedFileErr reports whether err is that of opening a file that
+// another process holds locked, which only Windows enforces on reads.
+func isLockedFileErr(err error) bool {
+	return false
+}
+
+func createShadowCopy(volume string) (id, device string, err error) {
+	return "", "", errShadowCopyUnsupported
+}
+
+func deleteShadowCopy(id string) error {
+	return errShadowCopyUnsupported
+}
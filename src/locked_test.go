@@ -0,0 +1,88 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenLocal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "locked")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	p := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(p, []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := openLocal(p, DefaultLockedRetries, newShadowCopies())
+	if err != nil {
+		t.Fatalf("opening an unlocked file: %v", err)
+	}
+	f.Close()
+
+	// Errors other than locking are neither retried nor turned into skips.
+	_, err = openLocal(filepath.Join(dir, "missing"), DefaultLockedRetries, nil)
+	if !os.IsNotExist(err) {
+		t.Errorf("got %v want a not exist error", err)
+	}
+}
+
+func TestLockedFileErr(t *testing.T) {
+	cases := []struct {
+		err  *lockedFileErr
+		want string
+	}{
+		{
+			err:  &lockedFileErr{path: `C:\Users\a\mail.pst`},
+			want: `C:\Users\a\mail.pst: locked by another process`,
+		},
+		{
+			err:  &lockedFileErr{path: `C:\Users\a\mail.pst`, shadowErr: errors.New("access denied")},
+			want: `C:\Users\a\mail.pst: locked by another process, and no shadow copy: access denied`,
+		},
+	}
+
+	for _, tc := range cases {
+		if got := tc.err.Error(); got != tc.want {
+			t.Errorf("got %q want %q", got, tc.want)
+		}
+	}
+}
+
+func TestShadowCopiesPath(t *testing.T) {
+	sc := newShadowCopies()
+	sc.volumes[""] = &shadowCopy{id: "{1}", device: `\\?\GLOBALROOT\Device\HarddiskVolumeShadowCopy1`}
+
+	got, err := sc.path(`\Users\a\mail.pst`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `\\?\GLOBALROOT\Device\HarddiskVolumeShadowCopy1\Users\a\mail.pst`; got != want {
+		t.Errorf("got %q want %q", got, want)
+	}
+
+	sc.volumes[""] = &shadowCopy{err: errShadowCopyUnsupported}
+	if _, err := sc.path("/home/a/mail.pst"); err != errShadowCopyUnsupported {
+		t.Errorf("got %v want %v", err, errShadowCopyUnsupported)
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+const (
+	errorSharingViolation = syscall.Errno(32)
+	errorLockViolation    = syscall.Errno(33)
+)
+
+// isLockedFileErr reports whether err is that of opening
+// a file that another process holds locked.
+func isLockedFileErr(err error) bool {
+	if pErr, ok := err.(*os.PathError); ok {
+		err = pErr.Err
+	}
+	return err == errorSharingViolation || err == errorLockViolation
+}
+
+func powershell(script string) (string, error) {
+	output, err := exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", script).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("%v: %s", err, strings.TrimSpace(string(output)))
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// createShadowCopy creates a shadow copy of volume e.g `C:\` through the
+// Volume Shadow Copy Service, which requires administrator rights.
+func createShadowCopy(volume string) (id, device string, err error) {
+	script := fmt.Sprintf(`$r = (Get-WmiObject -List Win32_ShadowCopy).Create('%s', 'ClientAccessible')
+if ($r.ReturnValue -ne 0) { Write-Output "Win32_ShadowCopy.Create returned $($r.ReturnValue)"; exit 1 }
+$s = Get-WmiObject Win32_ShadowCopy -Filter "ID='$($r.ShadowID)'"
+Write-Output $r.ShadowID
+Write-Output $s.DeviceObject`, volume)
+
+	output, err := powershell(script)
+	if err != nil {
+		return "", "", err
+	}
+	lines := strings.Fields(output)
+	if len(lines) != 2 {
+		return "", "", fmt.Errorf("unexpected shadow copy output %q", output)
+	}
+	return lines[0], lines[1], nil
+}
+
+func deleteShadowCopy(id string) error {
+	_, err := powershell(fmt.Sprintf(`Get-WmiObject Win32_ShadowCopy -Filter "ID='%s'" | ForEach-Object { $_.Delete() }`, id))
+	return err
+}
@@ -0,0 +1,140 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"html"
+	"net"
+	"net/http"
+	"time"
+
+	"golang.org/x/net/context"
+	"golang.org/x/oauth2"
+
+	"github.com/odeke-em/drive/config"
+	"github.com/skratchdot/open-golang/open"
+)
+
+// LoopbackTimeout is how long the loopback flow waits for the
+// browser to be redirected back with the authorization code.
+var LoopbackTimeout = 5 * time.Minute
+
+var (
+	errLoopbackNoCode  = errors.New("the redirect carried no authorization code")
+	errLoopbackTimeout = errors.New("timed out waiting for the browser to be redirected back")
+)
+
+const (
+	loopbackGrantedPage = "<html><body>drive is now authorized, you can close this window.</body></html>"
+	loopbackFailedPage  = "<html><body>drive was not authorized: %s</body></html>"
+)
+
+// RetrieveRefreshTokenByLoopback retrieves a refresh token by the OAuth 2.0
+// loopback flow: the browser is opened at the consent page, which redirects
+// back to a short-lived server on localhost that captures the code.
+func RetrieveRefreshTokenByLoopback(ctx context.Context, context *config.Context) (*oauth2.Token, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("loopback flow: %v; pass in -%s to paste the authorization code instead", err, CLIOptionManual)
+	}
+	defer ln.Close()
+
+	config := newAuthConfig(context)
+	config.RedirectURL = "http://" + ln.Addr().String()
+
+	state, err := loopbackState()
+	if err != nil {
+		return nil, err
+	}
+	url := config.AuthCodeURL(state, oauth2.AccessTypeOffline)
+
+	fmt.Printf("Opening your browser to authorize drive. If it doesn't open, visit this URL\n%s\n", url)
+	if err := open.Start(url); err != nil {
+		fmt.Printf("Could not open the browser: %v\n", err)
+	}
+
+	code, err := awaitLoopbackCode(ln, state, LoopbackTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("loopback flow: %v; pass in -%s to paste the authorization code instead", err, CLIOptionManual)
+	}
+
+	token, err := config.Exchange(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// loopbackState returns an unguessable state, by which the redirect
+// is told apart from requests forged by anything else on the machine.
+func loopbackState() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", b), nil
+}
+
+type loopbackResult struct {
+	code string
+	err  error
+}
+
+// awaitLoopbackCode serves on ln until a redirect with the given state
+// arrives, returning its authorization code, or until timeout elapses.
+// Requests without the state e.g for a favicon are turned away.
+func awaitLoopbackCode(ln net.Listener, state string, timeout time.Duration) (string, error) {
+	results := make(chan loopbackResult, 1)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("state") != state {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+
+		res := loopbackResult{code: query.Get("code")}
+		if reason := query.Get("error"); reason != "" {
+			res.err = fmt.Errorf("authorization was denied: %s", reason)
+		} else if res.code == "" {
+			res.err = errLoopbackNoCode
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if res.err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, loopbackFailedPage, html.EscapeString(res.err.Error()))
+		} else {
+			fmt.Fprint(w, loopbackGrantedPage)
+		}
+
+		select {
+		case results <- res:
+		default:
+		}
+	})
+
+	go http.Serve(ln, handler)
+
+	select {
+	case res := <-results:
+		return res.code, res.err
+	case <-time.After(timeout):
+		return "", errLoopbackTimeout
+	}
+}
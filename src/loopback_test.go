@@ -0,0 +1,104 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestAwaitLoopbackCode(t *testing.T) {
+	cases := []struct {
+		queries  []url.Values
+		wantCode string
+		wantErr  bool
+	}{
+		{
+			queries:  []url.Values{{"state": {"s3"}, "code": {"4/abc"}}},
+			wantCode: "4/abc",
+		},
+		{
+			// A request without the state e.g for a favicon is ignored.
+			queries: []url.Values{
+				{},
+				{"state": {"forged"}, "code": {"evil"}},
+				{"state": {"s3"}, "code": {"4/xyz"}},
+			},
+			wantCode: "4/xyz",
+		},
+		{
+			queries: []url.Values{{"state": {"s3"}, "error": {"access_denied"}}},
+			wantErr: true,
+		},
+		{
+			queries: []url.Values{{"state": {"s3"}}},
+			wantErr: true,
+		},
+	}
+
+	for i, tc := range cases {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		go func(queries []url.Values) {
+			for _, q := range queries {
+				res, err := http.Get("http://" + ln.Addr().String() + "/?" + q.Encode())
+				if err == nil {
+					res.Body.Close()
+				}
+			}
+		}(tc.queries)
+
+		code, err := awaitLoopbackCode(ln, "s3", 5*time.Second)
+		ln.Close()
+		if (err != nil) != tc.wantErr {
+			t.Errorf("#%d: err: got %v wantErr %v", i, err, tc.wantErr)
+		}
+		if code != tc.wantCode {
+			t.Errorf("#%d: code: got %q want %q", i, code, tc.wantCode)
+		}
+	}
+}
+
+func TestAwaitLoopbackCodeTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	if _, err := awaitLoopbackCode(ln, "s3", 10*time.Millisecond); err != errLoopbackTimeout {
+		t.Errorf("got %v want %v", err, errLoopbackTimeout)
+	}
+}
+
+func TestLoopbackState(t *testing.T) {
+	a, err := loopbackState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := loopbackState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a) != 32 || a == b {
+		t.Errorf("got %q and %q, want distinct 32 hex digit states", a, b)
+	}
+}
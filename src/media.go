@@ -0,0 +1,343 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// sidecarExts are the extensions of sidecar files e.g the .xmp files in
+// which Lightroom and darktable keep the edits of a RAW file. A sidecar
+// goes wherever the file that it describes goes.
+var sidecarExts = map[string]bool{
+	".xmp": true,
+}
+
+func isSidecar(p string) bool {
+	return sidecarExts[strings.ToLower(filepath.Ext(p))]
+}
+
+// mediaItem is a media file that is pushed along with its sidecars.
+type mediaItem struct {
+	path     string
+	sidecars []string
+}
+
+// pairSidecars groups paths into media items, each sidecar going with the
+// file that it describes in the same directory: IMG_1.CR2 with IMG_1.xmp
+// or IMG_1.CR2.xmp. Of the files that share a stem e.g IMG_1.CR2 and
+// IMG_1.JPG, a sidecar goes with the first. A sidecar without its file
+// among paths is an item of its own.
+func pairSidecars(paths []string) []*mediaItem {
+	sorted := append([]string{}, paths...)
+	sort.Strings(sorted)
+
+	var items []*mediaItem
+	byPath := make(map[string]*mediaItem)
+	byStem := make(map[string]*mediaItem)
+	for _, p := range sorted {
+		if isSidecar(p) {
+			continue
+		}
+		item := &mediaItem{path: p}
+		items = append(items, item)
+		byPath[p] = item
+		stem := strings.TrimSuffix(p, filepath.Ext(p))
+		if _, ok := byStem[stem]; !ok {
+			byStem[stem] = item
+		}
+	}
+
+	for _, p := range sorted {
+		if !isSidecar(p) {
+			continue
+		}
+		described := strings.TrimSuffix(p, filepath.Ext(p))
+		item := byPath[described]
+		if item == nil {
+			item = byStem[described]
+		}
+		if item == nil {
+			items = append(items, &mediaItem{path: p})
+			continue
+		}
+		item.sidecars = append(item.sidecars, p)
+	}
+
+	return items
+}
+
+// sidecarName is the name of the sidecar at p once the file at
+// describedPath that it describes is named described.
+func sidecarName(p, describedPath, described string) string {
+	name, describedName := filepath.Base(p), filepath.Base(describedPath)
+	if strings.HasPrefix(name, describedName) {
+		return described + strings.TrimPrefix(name, describedName)
+	}
+	stem := strings.TrimSuffix(describedName, filepath.Ext(describedName))
+	return strings.TrimSuffix(described, filepath.Ext(described)) + strings.TrimPrefix(name, stem)
+}
+
+// exifFields are the fields of the exif function of media path templates
+// e.g `photos/{{exif.Year}}/{{exif.Month}}`, those of when the photo was taken.
+type exifFields struct {
+	Year, Month, Day, Hour, Minute, Second string
+}
+
+// expandMediaPath expands the media path template tmpl for a photo taken
+// at taken. Besides exif, tmpl may use whatever ExpandRemotePath supports,
+// date then formatting taken.
+func expandMediaPath(tmpl string, taken time.Time) (string, error) {
+	fields := exifFields{
+		Year:   taken.Format("2006"),
+		Month:  taken.Format("01"),
+		Day:    taken.Format("02"),
+		Hour:   taken.Format("15"),
+		Minute: taken.Format("04"),
+		Second: taken.Format("05"),
+	}
+	expanded, err := expandRemotePath(tmpl, taken, template.FuncMap{
+		"exif": func() exifFields { return fields },
+	})
+	if err != nil {
+		return "", err
+	}
+	return path.Join(RemoteSeparator, expanded), nil
+}
+
+// mediaFolder is what a remote folder that media is pushed into
+// holds, the checksums of its files by name and their names by checksum.
+type mediaFolder struct {
+	sums  map[string]string
+	names map[string]string
+}
+
+func newMediaFolder() *mediaFolder {
+	return &mediaFolder{
+		sums:  make(map[string]string),
+		names: make(map[string]string),
+	}
+}
+
+func (mf *mediaFolder) add(name, sum string) {
+	mf.sums[name] = sum
+	if _, ok := mf.names[sum]; !ok && sum != "" {
+		mf.names[sum] = name
+	}
+}
+
+// place returns the name that a file named name whose checksum is sum
+// takes in the folder, and whether the folder already holds its content
+// under that name. Duplicates are told by content alone, never by name
+// nor by when they were taken, so that the frames of a burst, shot within
+// the same second and by cameras that reuse names, are all kept. A frame
+// whose name is taken by other content is renamed with a _N suffix as
+// when fixing clashes.
+func (mf *mediaFolder) place(name, sum string) (placed string, duplicate bool) {
+	if existing, ok := mf.names[sum]; ok && sum != "" {
+		return existing, true
+	}
+
+	ext := filepath.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	placed = name
+	for i := 1; ; i++ {
+		if _, taken := mf.sums[placed]; !taken {
+			break
+		}
+		placed = fmt.Sprintf("%s_%d%s", stem, i, ext)
+	}
+	mf.add(placed, sum)
+	return placed, false
+}
+
+// mediaFolder returns what the remote folder at dir holds, which is
+// listed once per push.
+func (g *Commands) mediaFolder(dir string, folders map[string]*mediaFolder) (*mediaFolder, error) {
+	if mf, ok := folders[dir]; ok {
+		return mf, nil
+	}
+
+	mf := newMediaFolder()
+	remDir, err := g.rem.FindByPath(dir)
+	if err == ErrPathNotExists {
+		folders[dir] = mf
+		return mf, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if remDir == nil || !remDir.IsDir {
+		return nil, illogicalStateErr(fmt.Errorf("%s: %v", dir, ErrPathNotDir))
+	}
+
+	pagePair := g.rem.FindByParentId(remDir.Id, true)
+	errsChan := pagePair.errsChan
+	childrenChan := pagePair.filesChan
+
+	working := true
+	for working {
+		select {
+		case err := <-errsChan:
+			if err != nil {
+				return nil, err
+			}
+		case child, stillHasContent := <-childrenChan:
+			if !stillHasContent {
+				working = false
+				break
+			}
+			if child == nil || child.IsDir {
+				continue
+			}
+			mf.add(child.Name, child.Md5Checksum)
+		}
+	}
+
+	folders[dir] = mf
+	return mf, nil
+}
+
+// PushMedia pushes the media files at or under fsPaths, which needn't be
+// within the context e.g those of a camera's card, into the remote folders
+// that the media path template tmpl expands to for each by when it was
+// taken, see expandMediaPath. The date is that of the file's EXIF metadata,
+// or else its modification time. Sidecars go along with the files that
+// they describe, and files whose content is already in their folder are
+// skipped, so that pushing the same card again only pushes what is new.
+func (g *Commands) PushMedia(tmpl string, fsPaths []string) error {
+	if err := g.refuseReadOnly("push"); err != nil {
+		return err
+	}
+	if _, err := expandMediaPath(tmpl, time.Now()); err != nil {
+		return err
+	}
+
+	g.rem.encrypter = g.opts.Encrypter
+	g.rem.decrypter = g.opts.Decrypter
+
+	var paths []string
+	for _, fsPath := range fsPaths {
+		err := filepath.Walk(fsPath, func(p string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if p != fsPath && isHidden(fi.Name(), g.opts.Hidden) {
+				if fi.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if fi.Mode().IsRegular() {
+				paths = append(paths, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	folders := make(map[string]*mediaFolder)
+	pushed, duplicates := 0, 0
+	var composedErr error
+	for _, item := range pairSidecars(paths) {
+		n, dups, err := g.pushMediaItem(tmpl, item, folders)
+		pushed += n
+		duplicates += dups
+		if err != nil {
+			g.log.LogErrf("%s: %v\n", item.path, err)
+			composedErr = reComposeError(composedErr, fmt.Sprintf("%s: %v", item.path, err))
+		}
+	}
+
+	g.log.Logf("Pushed %d files, skipped %d already pushed\n", pushed, duplicates)
+	return composedErr
+}
+
+// pushMediaItem pushes item and its sidecars into their remote folder,
+// returning how many files it pushed and how many it skipped as duplicates.
+func (g *Commands) pushMediaItem(tmpl string, item *mediaItem, folders map[string]*mediaFolder) (pushed, duplicates int, err error) {
+	fi, err := os.Stat(item.path)
+	if err != nil {
+		return 0, 0, err
+	}
+	taken, err := exifDateOf(item.path)
+	if err != nil {
+		taken = fi.ModTime()
+	}
+	dir, err := expandMediaPath(tmpl, taken)
+	if err != nil {
+		return 0, 0, err
+	}
+	mf, err := g.mediaFolder(dir, folders)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	upload := func(p, name string, overwrite bool) error {
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		remotePath := remotePathJoin(dir, name)
+		if g.opts.Verbose {
+			g.log.Logf("%s -> %s\n", p, remotePath)
+		}
+		return g.upsertFromReader(remotePath, f, overwrite)
+	}
+
+	local := NewLocalFile(item.path, fi)
+	name, duplicate := mf.place(local.Name, md5Checksum(local))
+	if duplicate {
+		duplicates++
+	} else {
+		if err := upload(item.path, name, false); err != nil {
+			return pushed, duplicates, err
+		}
+		pushed++
+	}
+
+	for _, p := range item.sidecars {
+		sfi, err := os.Stat(p)
+		if err != nil {
+			return pushed, duplicates, err
+		}
+		sum := md5Checksum(NewLocalFile(p, sfi))
+		sidecar := sidecarName(p, item.path, name)
+		if mf.sums[sidecar] == sum {
+			duplicates++
+			continue
+		}
+		// A sidecar that differs holds newer edits of its file, so it
+		// replaces the one that is there.
+		_, overwrite := mf.sums[sidecar]
+		if err := upload(p, sidecar, overwrite); err != nil {
+			return pushed, duplicates, err
+		}
+		mf.add(sidecar, sum)
+		pushed++
+	}
+	return pushed, duplicates, nil
+}
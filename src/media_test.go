@@ -0,0 +1,128 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPairSidecars(t *testing.T) {
+	paths := []string{
+		"/card/IMG_2.CR2.xmp",
+		"/card/IMG_1.xmp",
+		"/card/IMG_1.CR2",
+		"/card/IMG_1.JPG",
+		"/card/IMG_2.CR2",
+		"/card/IMG_3.XMP",
+		"/other/IMG_1.xmp",
+		"/card/MVI_4.MOV",
+	}
+
+	got := make(map[string][]string)
+	for _, item := range pairSidecars(paths) {
+		got[item.path] = item.sidecars
+	}
+
+	want := map[string][]string{
+		"/card/IMG_1.CR2": {"/card/IMG_1.xmp"},
+		"/card/IMG_1.JPG": nil,
+		"/card/IMG_2.CR2": {"/card/IMG_2.CR2.xmp"},
+		"/card/MVI_4.MOV": nil,
+		// Sidecars without their files are pushed on their own.
+		"/card/IMG_3.XMP":  nil,
+		"/other/IMG_1.xmp": nil,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v want %v", got, want)
+	}
+}
+
+func TestSidecarName(t *testing.T) {
+	tests := []struct {
+		p, describedPath, described string
+		want                        string
+	}{
+		{p: "/c/IMG_1.xmp", describedPath: "/c/IMG_1.CR2", described: "IMG_1.CR2", want: "IMG_1.xmp"},
+		{p: "/c/IMG_1.xmp", describedPath: "/c/IMG_1.CR2", described: "IMG_1_2.CR2", want: "IMG_1_2.xmp"},
+		{p: "/c/IMG_1.CR2.xmp", describedPath: "/c/IMG_1.CR2", described: "IMG_1_2.CR2", want: "IMG_1_2.CR2.xmp"},
+	}
+
+	for _, tt := range tests {
+		if got := sidecarName(tt.p, tt.describedPath, tt.described); got != tt.want {
+			t.Errorf("%q for %q: got %q want %q", tt.p, tt.described, got, tt.want)
+		}
+	}
+}
+
+func TestMediaFolderPlace(t *testing.T) {
+	mf := newMediaFolder()
+	mf.add("IMG_1.JPG", "aaa")
+
+	tests := []struct {
+		name, sum     string
+		want          string
+		wantDuplicate bool
+	}{
+		// The same content is a duplicate even under another name.
+		{name: "IMG_9.JPG", sum: "aaa", want: "IMG_1.JPG", wantDuplicate: true},
+		// Frames of a burst whose names clash are all kept.
+		{name: "IMG_1.JPG", sum: "bbb", want: "IMG_1_1.JPG"},
+		{name: "IMG_1.JPG", sum: "ccc", want: "IMG_1_2.JPG"},
+		{name: "IMG_1.JPG", sum: "bbb", want: "IMG_1_1.JPG", wantDuplicate: true},
+		{name: "IMG_2.JPG", sum: "ddd", want: "IMG_2.JPG"},
+	}
+
+	for i, tt := range tests {
+		got, duplicate := mf.place(tt.name, tt.sum)
+		if got != tt.want || duplicate != tt.wantDuplicate {
+			t.Errorf("#%d: got (%q, %v) want (%q, %v)", i, got, duplicate, tt.want, tt.wantDuplicate)
+		}
+	}
+}
+
+func TestExpandMediaPath(t *testing.T) {
+	taken := time.Date(2016, time.March, 9, 7, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		tmpl    string
+		want    string
+		wantErr bool
+	}{
+		{tmpl: "photos/{{exif.Year}}/{{exif.Month}}/", want: "/photos/2016/03"},
+		{tmpl: "{{exif.Year}}-{{exif.Month}}-{{exif.Day}} {{exif.Hour}}{{exif.Minute}}{{exif.Second}}", want: "/2016-03-09 070405"},
+		{tmpl: `photos/{{date "2006/Jan"}}`, want: "/photos/2016/Mar"},
+		{tmpl: "photos", want: "/photos"},
+		{tmpl: "{{exif.Lens}}", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := expandMediaPath(tt.tmpl, taken)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%q: expected an error, got %q", tt.tmpl, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected err %v", tt.tmpl, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q: got %q want %q", tt.tmpl, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,307 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/odeke-em/drive/config"
+)
+
+const (
+	MetadataCacheJSON = "metadata-cache.json"
+)
+
+var (
+	// metadataTTL when set is how long remote metadata is reused for,
+	// and metadataOnDisk when set keeps it across runs, see UseMetadataCache.
+	metadataTTL    time.Duration
+	metadataOnDisk bool
+
+	metaCachesMu sync.Mutex
+	metaCaches   = map[string]*metaCache{}
+)
+
+// UseMetadataCache makes the metadata that the remote returns for files
+// and folders be reused for ttl e.g `2m` instead of being looked up again,
+// and if onDisk is set also by the runs that follow within ttl. Any change
+// made to the remote drops the cache. An empty ttl leaves it disabled.
+func UseMetadataCache(ttl string, onDisk bool) error {
+	if strings.TrimSpace(ttl) == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(ttl))
+	if err != nil {
+		return invalidArgumentsErr(fmt.Errorf("metadata ttl: %v", err))
+	}
+	if d < 0 {
+		return invalidArgumentsErr(fmt.Errorf("metadata ttl: %v is negative", d))
+	}
+	metadataTTL, metadataOnDisk = d, onDisk
+	return nil
+}
+
+// SaveMetadataCaches writes the metadata cached during this run to disk
+// for the runs that follow, if UseMetadataCache was asked to.
+func SaveMetadataCaches() error {
+	metaCachesMu.Lock()
+	defer metaCachesMu.Unlock()
+
+	var err error
+	for _, mc := range metaCaches {
+		if sErr := mc.save(); sErr != nil {
+			err = combineErrors(err, sErr)
+		}
+	}
+	return err
+}
+
+// metaCacheFor returns the cache shared by the remotes of the context's
+// account in use, nil if caching is disabled. Each account has its own
+// since the same URL e.g that of the root lists another Drive for each.
+func metaCacheFor(context *config.Context) *metaCache {
+	if metadataTTL <= 0 {
+		return nil
+	}
+
+	metaCachesMu.Lock()
+	defer metaCachesMu.Unlock()
+
+	name := MetadataCacheJSON
+	if context.Account != "" {
+		name = fmt.Sprintf("metadata-cache-%s.json", context.Account)
+	}
+	key := filepath.Join(context.GDPath(), name)
+	if mc, ok := metaCaches[key]; ok {
+		return mc
+	}
+	p := ""
+	if metadataOnDisk {
+		p = context.CacheFilePath(name)
+	}
+	mc := newMetaCache(metadataTTL, p, time.Now)
+	metaCaches[key] = mc
+	return mc
+}
+
+type metaCacheEntry struct {
+	At          time.Time `json:"at"`
+	ContentType string    `json:"content_type,omitempty"`
+	Body        []byte    `json:"body"`
+}
+
+// metaCache holds the responses to metadata requests by URL for ttl.
+type metaCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	path    string
+	now     func() time.Time
+	entries map[string]*metaCacheEntry
+	dirty   bool
+}
+
+// newMetaCache returns a cache for ttl, seeded with the entries saved at
+// path that haven't expired yet. An empty path keeps it in memory only.
+func newMetaCache(ttl time.Duration, path string, now func() time.Time) *metaCache {
+	mc := &metaCache{ttl: ttl, path: path, now: now, entries: map[string]*metaCacheEntry{}}
+	if path == "" {
+		return mc
+	}
+
+	// A missing or unreadable cache is empty since it can always be looked up again.
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return mc
+	}
+	saved := map[string]*metaCacheEntry{}
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return mc
+	}
+	for key, entry := range saved {
+		if entry != nil && mc.fresh(entry) {
+			mc.entries[key] = entry
+		}
+	}
+	return mc
+}
+
+func (mc *metaCache) fresh(entry *metaCacheEntry) bool {
+	age := mc.now().Sub(entry.At)
+	return age >= 0 && age < mc.ttl
+}
+
+func (mc *metaCache) get(key string) (*metaCacheEntry, bool) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	entry, ok := mc.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if !mc.fresh(entry) {
+		delete(mc.entries, key)
+		return nil, false
+	}
+	return entry, true
+}
+
+func (mc *metaCache) put(key, contentType string, body []byte) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	mc.entries[key] = &metaCacheEntry{At: mc.now(), ContentType: contentType, Body: body}
+	mc.dirty = true
+}
+
+// forget drops everything cached, including what was saved to disk so
+// that a run that fails before saving doesn't leave stale metadata behind.
+func (mc *metaCache) forget() {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	if len(mc.entries) < 1 && !mc.dirty {
+		return
+	}
+	mc.entries = map[string]*metaCacheEntry{}
+	mc.dirty = false
+	if mc.path != "" {
+		os.Remove(mc.path)
+	}
+}
+
+func (mc *metaCache) save() error {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	if mc.path == "" || !mc.dirty {
+		return nil
+	}
+
+	data, err := json.Marshal(mc.entries)
+	if err != nil {
+		return err
+	}
+	tmpPath := mc.path + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, data, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, mc.path); err != nil {
+		return err
+	}
+	mc.dirty = false
+	return nil
+}
+
+// cacheableRequest reports whether req only looks up the metadata of
+// files or folders, whose response can then be reused. Downloads and
+// the changes, which status relies on being current, are never cached.
+func cacheableRequest(req *http.Request) bool {
+	if req.Method != "GET" {
+		return false
+	}
+	if !strings.Contains(req.URL.Path, "/drive/v2/files") {
+		return false
+	}
+	return req.URL.Query().Get("alt") == ""
+}
+
+// mutatingRequest reports whether req could change the remote.
+func mutatingRequest(req *http.Request) bool {
+	switch req.Method {
+	case "GET", "HEAD", "OPTIONS":
+		return false
+	}
+	return true
+}
+
+type metaCacheTransport struct {
+	base  http.RoundTripper
+	cache *metaCache
+}
+
+func (mt *metaCacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if mutatingRequest(req) {
+		mt.cache.forget()
+		return mt.base.RoundTrip(req)
+	}
+	if !cacheableRequest(req) {
+		return mt.base.RoundTrip(req)
+	}
+
+	key := req.URL.String()
+	if entry, ok := mt.cache.get(key); ok {
+		return cachedResponse(req, entry), nil
+	}
+
+	res, err := mt.base.RoundTrip(req)
+	if err != nil || res.StatusCode != http.StatusOK {
+		return res, err
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	contentType := res.Header.Get("Content-Type")
+	mt.cache.put(key, contentType, body)
+	res.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return res, nil
+}
+
+func cachedResponse(req *http.Request, entry *metaCacheEntry) *http.Response {
+	header := http.Header{}
+	if entry.ContentType != "" {
+		header.Set("Content-Type", entry.ContentType)
+	}
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(entry.Body)),
+		ContentLength: int64(len(entry.Body)),
+		Request:       req,
+	}
+}
+
+// forgetMetadata drops what the remote has cached of files and
+// folders e.g once the changes feed reports that they changed.
+func (r *Remote) forgetMetadata() {
+	r.forgetFolderIds()
+	if mt, ok := r.client.Transport.(*metaCacheTransport); ok {
+		mt.cache.forget()
+	}
+}
+
+// setMetaCache routes all of the remote's requests through mc.
+func (r *Remote) setMetaCache(mc *metaCache) {
+	base := r.client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	r.client.Transport = &metaCacheTransport{base: base, cache: mc}
+}
@@ -0,0 +1,153 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type countingTransport struct {
+	calls int
+}
+
+func (ct *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ct.calls++
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       ioutil.NopCloser(bytes.NewBufferString(`{"items":[]}`)),
+		Request:    req,
+	}, nil
+}
+
+func TestCacheableRequest(t *testing.T) {
+	testCases := []struct {
+		method string
+		url    string
+		want   bool
+	}{
+		{method: "GET", url: "https://www.googleapis.com/drive/v2/files?q=x", want: true},
+		{method: "GET", url: "https://www.googleapis.com/drive/v2/files/abc", want: true},
+		{method: "GET", url: "https://www.googleapis.com/drive/v2/files/abc?alt=media", want: false},
+		{method: "GET", url: "https://www.googleapis.com/drive/v2/about", want: false},
+		{method: "GET", url: "https://www.googleapis.com/drive/v2/changes", want: false},
+		{method: "PUT", url: "https://www.googleapis.com/drive/v2/files/abc", want: false},
+	}
+
+	for _, tc := range testCases {
+		req, _ := http.NewRequest(tc.method, tc.url, nil)
+		if got := cacheableRequest(req); got != tc.want {
+			t.Errorf("%s %s: got %v want %v", tc.method, tc.url, got, tc.want)
+		}
+	}
+}
+
+func TestMetaCacheTransport(t *testing.T) {
+	now := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	base := &countingTransport{}
+	mt := &metaCacheTransport{base: base, cache: newMetaCache(time.Minute, "", clock)}
+	client := &http.Client{Transport: mt}
+
+	get := func() string {
+		res, err := client.Get("https://www.googleapis.com/drive/v2/files?q=parent")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		body, _ := ioutil.ReadAll(res.Body)
+		return string(body)
+	}
+
+	if body := get(); body != `{"items":[]}` {
+		t.Fatalf("got body %q", body)
+	}
+	if body := get(); body != `{"items":[]}` || base.calls != 1 {
+		t.Fatalf("expected the second lookup to be cached, got %q after %d calls", body, base.calls)
+	}
+
+	now = now.Add(2 * time.Minute)
+	get()
+	if base.calls != 2 {
+		t.Errorf("expected an expired entry to be looked up again, got %d calls", base.calls)
+	}
+
+	req, _ := http.NewRequest("PUT", "https://www.googleapis.com/drive/v2/files/abc", nil)
+	if _, err := client.Do(req); err != nil {
+		t.Fatal(err)
+	}
+	get()
+	if base.calls != 4 {
+		t.Errorf("expected a change to drop the cache, got %d calls", base.calls)
+	}
+}
+
+func TestMetaCacheOnDisk(t *testing.T) {
+	dir, err := ioutil.TempDir("", "metacache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	p := filepath.Join(dir, MetadataCacheJSON)
+	now := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	mc := newMetaCache(time.Minute, p, clock)
+	mc.put("fresh", "application/json", []byte("{}"))
+	if err := mc.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	now = now.Add(30 * time.Second)
+	if _, ok := newMetaCache(time.Minute, p, clock).get("fresh"); !ok {
+		t.Errorf("expected the saved entry to be reused by the next run")
+	}
+
+	now = now.Add(time.Minute)
+	if _, ok := newMetaCache(time.Minute, p, clock).get("fresh"); ok {
+		t.Errorf("expected the saved entry to have expired")
+	}
+
+	mc.forget()
+	if _, err := os.Stat(p); !os.IsNotExist(err) {
+		t.Errorf("expected forget to remove the saved cache, got %v", err)
+	}
+}
+
+func TestUseMetadataCache(t *testing.T) {
+	defer func() {
+		metadataTTL, metadataOnDisk = 0, false
+	}()
+
+	if err := UseMetadataCache("", true); err != nil || metadataTTL != 0 {
+		t.Errorf("expected no cache without a ttl, got %v %v", metadataTTL, err)
+	}
+	if err := UseMetadataCache("2m", true); err != nil || metadataTTL != 2*time.Minute || !metadataOnDisk {
+		t.Errorf("got %v %v %v", metadataTTL, metadataOnDisk, err)
+	}
+	for _, ttl := range []string{"soon", "-1m"} {
+		if err := UseMetadataCache(ttl, false); err == nil {
+			t.Errorf("%q: expected an error", ttl)
+		}
+	}
+}
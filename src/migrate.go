@@ -0,0 +1,392 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/odeke-em/drive/config"
+)
+
+// migrateState records which source files were copied to which files of
+// the destination account, so that an interrupted migration resumes
+// where it stopped instead of copying everything again.
+type migrateState struct {
+	// Copied maps the ids of the source files to those of their copies.
+	Copied map[string]string `json:"copied"`
+
+	path string
+}
+
+// migrateStateName names the file that keeps the state of the
+// migration of a context from one of its accounts to another.
+func migrateStateName(from, to string) string {
+	return fmt.Sprintf("migrate-%s-%s.json", accountOrDefault(from), accountOrDefault(to))
+}
+
+func accountOrDefault(account string) string {
+	if account == "" {
+		return "default"
+	}
+	return account
+}
+
+// loadMigrateState reads the state of a previous migration,
+// starting off afresh if there was none.
+func loadMigrateState(p string) (*migrateState, error) {
+	ms := &migrateState{Copied: make(map[string]string), path: p}
+	data, err := ioutil.ReadFile(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return ms, err
+	}
+
+	if err := json.Unmarshal(data, ms); err != nil {
+		return nil, fmt.Errorf("%s: %v", p, err)
+	}
+	if ms.Copied == nil {
+		ms.Copied = make(map[string]string)
+	}
+	return ms, nil
+}
+
+func (ms *migrateState) save() error {
+	data, err := json.MarshalIndent(ms, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmpPath := ms.path + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, ms.path)
+}
+
+// migrationOrder sorts relative paths so that every
+// folder comes before the content that it holds.
+type migrationOrder []string
+
+func (mo migrationOrder) Len() int      { return len(mo) }
+func (mo migrationOrder) Swap(i, j int) { mo[i], mo[j] = mo[j], mo[i] }
+func (mo migrationOrder) Less(i, j int) bool {
+	di, dj := strings.Count(mo[i], "/"), strings.Count(mo[j], "/")
+	if di != dj {
+		return di < dj
+	}
+	return mo[i] < mo[j]
+}
+
+func migrationPaths(tree map[string]*File) []string {
+	paths := make([]string, 0, len(tree))
+	for relPath := range tree {
+		paths = append(paths, relPath)
+	}
+	sort.Sort(migrationOrder(paths))
+	return paths
+}
+
+// migratedIntact reports whether copied is a faithful copy of src.
+func migratedIntact(src, copied *File) bool {
+	if copied == nil || copied.IsDir != src.IsDir || copied.Name != src.Name {
+		return false
+	}
+	if src.IsDir || hasExportLinks(src) {
+		// Docs have neither checksums nor sizes to compare.
+		return true
+	}
+	if src.Md5Checksum != "" && copied.Md5Checksum != "" {
+		return src.Md5Checksum == copied.Md5Checksum
+	}
+	return src.Size == copied.Size
+}
+
+// migration is a migration of a remote tree between two accounts.
+type migration struct {
+	src, dest *Commands
+	state     *migrateState
+
+	// tree holds the source files by their path relative to srcPath.
+	tree    map[string]*File
+	srcPath string
+	// destPath is where the copy of the tree goes in the destination's Drive.
+	destPath string
+
+	destEmail string
+	// sharedRoot is set once the whole tree is shared with destEmail.
+	sharedRoot bool
+}
+
+// Migrate copies the remote tree at g.opts.Path to the Drive of the
+// context's account named by destContext, g being that of the source
+// account. Files are copied server side after sharing them with the
+// destination account for the copy's duration, and otherwise streamed
+// through this machine. Progress is recorded in the context's .gd
+// directory so that an interrupted migration resumes on the next run,
+// and every copy is then verified against its source, those that
+// differ being trashed so that the next run copies them afresh.
+func (g *Commands) Migrate(destContext *config.Context) error {
+	if err := g.requireWholeDrive("migrate"); err != nil {
+		return err
+	}
+
+	from, to := g.context.Account, destContext.Account
+	if from == to {
+		return invalidArgumentsErr(fmt.Errorf("migrate: the source and destination accounts are both %q", accountOrDefault(from)))
+	}
+
+	// The remote folder that the context may be bound to belongs to the
+	// source account, so the copy is made from the root of the
+	// destination's Drive instead, in a folder of the same name.
+	destContext.RemoteRootId, destContext.RemoteRootPath = "", ""
+	dest := New(destContext, &Options{Quiet: g.opts.Quiet, Path: "/"})
+
+	srcAbout, err := g.rem.About()
+	if err != nil {
+		return err
+	}
+	destAbout, err := dest.rem.About()
+	if err != nil {
+		return err
+	}
+	if srcAbout.User != nil && destAbout.User != nil && srcAbout.User.EmailAddress == destAbout.User.EmailAddress {
+		return invalidArgumentsErr(fmt.Errorf("migrate: accounts %q and %q are both %s",
+			accountOrDefault(from), accountOrDefault(to), srcAbout.User.EmailAddress))
+	}
+
+	m := &migration{
+		src:      g,
+		dest:     dest,
+		srcPath:  remotePathJoin(g.opts.Path),
+		destPath: remotePathJoin(g.opts.Path),
+	}
+	if destAbout.User != nil {
+		m.destEmail = destAbout.User.EmailAddress
+	}
+
+	srcRoot, err := g.rem.FindByPath(m.srcPath)
+	if err != nil && err != ErrPathNotExists {
+		return err
+	}
+	if srcRoot == nil {
+		return illogicalStateErr(fmt.Errorf("migrate: %s doesnot exist", customQuote(m.srcPath)))
+	}
+
+	boundRoot := g.context.RemoteRootId != ""
+	if boundRoot {
+		remoteRoot, err := g.rem.FindByPath("/")
+		if err != nil {
+			return err
+		}
+		m.destPath = remotePathJoin(remoteRoot.Name, m.srcPath)
+	}
+
+	m.tree = map[string]*File{"": srcRoot}
+	if srcRoot.IsDir {
+		if err := g.remoteTree(srcRoot, "", m.tree); err != nil {
+			return err
+		}
+	}
+
+	if m.state, err = loadMigrateState(filepath.Join(g.context.GDPath(), migrateStateName(from, to))); err != nil {
+		return err
+	}
+
+	// Sharing the root shares everything under it in a single call,
+	// which isn't possible for the root of the Drive itself, whose
+	// files are then shared one by one.
+	if m.destEmail != "" && (boundRoot || m.srcPath != "/") {
+		unshare, shareErr := m.share(srcRoot)
+		if shareErr == nil {
+			m.sharedRoot = true
+			defer unshare()
+		} else {
+			g.log.LogErrf("migrate: sharing %s with %s: %v\n", customQuote(m.srcPath), m.destEmail, shareErr)
+		}
+	}
+
+	order := migrationPaths(m.tree)
+	g.taskStart(int64(len(order)))
+
+	var failed error
+	failures := 0
+	for _, relPath := range order {
+		src := m.tree[relPath]
+		if _, done := m.state.Copied[src.Id]; done {
+			g.taskAdd(1)
+			continue
+		}
+
+		copied, err := m.copyOne(relPath)
+		g.taskAdd(1)
+		if err != nil {
+			failures++
+			failed = combineErrors(failed, fmt.Errorf("%s: %v", remotePathJoin(m.srcPath, relPath), err))
+			continue
+		}
+
+		m.state.Copied[src.Id] = copied.Id
+		if err := m.state.save(); err != nil {
+			return err
+		}
+	}
+	g.taskFinish()
+
+	mismatches := m.verify()
+	if err := m.state.save(); err != nil {
+		return err
+	}
+
+	if failures > 0 || mismatches > 0 {
+		g.log.LogErrf("migrate: %d failed and %d didn't verify, run migrate again to retry them\n", failures, mismatches)
+		if failed == nil {
+			failed = fmt.Errorf("migrate: %d copies didn't verify", mismatches)
+		}
+		return failed
+	}
+
+	g.log.Logf("migrate: %d files copied from %q to %q and verified\n", len(order), accountOrDefault(from), accountOrDefault(to))
+	return os.Remove(m.state.path)
+}
+
+// copyOne copies the file at relPath of the tree into the copy of its folder.
+func (m *migration) copyOne(relPath string) (*File, error) {
+	src := m.tree[relPath]
+
+	var parentId string
+	if relPath == "" {
+		if src.IsDir {
+			return m.dest.remoteMkdirAll(m.destPath)
+		}
+		parent, err := m.dest.remoteMkdirAll(path.Dir(m.destPath))
+		if err != nil {
+			return nil, err
+		}
+		parentId = parent.Id
+	} else {
+		parentRelPath := path.Dir(relPath)
+		if parentRelPath == "/" {
+			parentRelPath = ""
+		}
+		parentId = m.state.Copied[m.tree[parentRelPath].Id]
+		if parentId == "" {
+			return nil, fmt.Errorf("its folder wasn't copied")
+		}
+	}
+
+	if src.IsDir {
+		dir := &File{Name: src.Name, IsDir: true, ModTime: src.ModTime}
+		copied, _, err := m.dest.rem.upsertByComparison(nil, &upsertOpt{src: dir, parentId: parentId})
+		return copied, err
+	}
+
+	if src.Copyable && m.destEmail != "" {
+		copied, err := m.serverSideCopy(src, parentId)
+		if err == nil {
+			return copied, nil
+		}
+		DebugPrintf("migrate: copying %s server side: %v, streaming it instead", customQuote(relPath), err)
+	}
+
+	if hasExportLinks(src) {
+		return nil, fmt.Errorf("cannot be copied server side and Docs cannot be streamed")
+	}
+
+	body, err := m.src.rem.Download(src.Id, "")
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	file := &File{Name: src.Name, MimeType: src.MimeType, ModTime: src.ModTime}
+	copied, _, err := m.dest.rem.upsertByComparison(body, &upsertOpt{src: file, parentId: parentId, createdTime: src.CreatedTime})
+	return copied, err
+}
+
+func (m *migration) serverSideCopy(src *File, parentId string) (*File, error) {
+	if !m.sharedRoot {
+		unshare, err := m.share(src)
+		if err != nil {
+			return nil, err
+		}
+		defer unshare()
+	}
+	return m.dest.rem.copy(src.Name, parentId, src)
+}
+
+// share lets the destination account read f, returning the function that revokes that access.
+func (m *migration) share(f *File) (unshare func(), err error) {
+	perm, err := m.src.rem.insertPermissions(&permission{
+		fileId:      f.Id,
+		value:       m.destEmail,
+		role:        Reader,
+		accountType: User,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	unshare = func() {
+		if dErr := m.src.rem.deletePermission(f.Id, perm.Id); dErr != nil {
+			m.src.log.LogErrf("migrate: unsharing %s from %s: %v\n", customQuote(f.Name), m.destEmail, dErr)
+		}
+	}
+	return unshare, nil
+}
+
+// verify compares every copy against its source, trashing the copies
+// that differ and forgetting them so that they are copied again.
+func (m *migration) verify() (mismatches int) {
+	for _, relPath := range migrationPaths(m.tree) {
+		src := m.tree[relPath]
+		copiedId, ok := m.state.Copied[src.Id]
+		if !ok {
+			continue
+		}
+
+		copied, err := m.dest.rem.FindById(copiedId)
+		if err != nil && err != ErrPathNotExists {
+			m.src.log.LogErrf("migrate: verifying %s: %v\n", customQuote(relPath), err)
+			mismatches++
+			continue
+		}
+
+		if relPath == "" && src.IsDir && copied != nil && copied.IsDir {
+			// The copy of the root folder may be one that already existed.
+			continue
+		}
+		if migratedIntact(src, copied) {
+			continue
+		}
+
+		mismatches++
+		m.src.log.LogErrf("migrate: %s differs from its copy\n", customQuote(remotePathJoin(m.srcPath, relPath)))
+		delete(m.state.Copied, src.Id)
+		if copied != nil {
+			if err := m.dest.rem.Trash(copied.Id); err != nil {
+				m.src.log.LogErrf("migrate: trashing the copy of %s: %v\n", customQuote(relPath), err)
+			}
+		}
+	}
+	return mismatches
+}
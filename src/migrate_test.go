@@ -0,0 +1,110 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMigrationPaths(t *testing.T) {
+	tree := map[string]*File{
+		"":              {IsDir: true},
+		"/b":            {IsDir: true},
+		"/b/c/d.txt":    {},
+		"/a.txt":        {},
+		"/b/c":          {IsDir: true},
+		"/b/a.txt":      {},
+		"/a b/deep.txt": {},
+		"/a b":          {IsDir: true},
+	}
+
+	want := []string{
+		"",
+		"/a b", "/a.txt", "/b",
+		"/a b/deep.txt", "/b/a.txt", "/b/c",
+		"/b/c/d.txt",
+	}
+	if got := migrationPaths(tree); !reflect.DeepEqual(got, want) {
+		t.Errorf("have %q want %q", got, want)
+	}
+}
+
+func TestMigratedIntact(t *testing.T) {
+	doc := &File{Name: "notes", ExportLinks: map[string]string{"text/plain": "https://example.com/export"}}
+
+	tests := [...]struct {
+		src, copied *File
+		want        bool
+	}{
+		0: {src: &File{Name: "a", Md5Checksum: "abc", Size: 3}, copied: &File{Name: "a", Md5Checksum: "abc", Size: 3}, want: true},
+		1: {src: &File{Name: "a", Md5Checksum: "abc", Size: 3}, copied: &File{Name: "a", Md5Checksum: "abd", Size: 3}, want: false},
+		2: {src: &File{Name: "a", Size: 3}, copied: &File{Name: "a", Size: 2}, want: false},
+		3: {src: &File{Name: "a", Size: 3}, copied: &File{Name: "a", Size: 3}, want: true},
+		4: {src: &File{Name: "a"}, copied: &File{Name: "b"}, want: false},
+		5: {src: &File{Name: "a", IsDir: true}, copied: &File{Name: "a"}, want: false},
+		6: {src: &File{Name: "a", IsDir: true}, copied: &File{Name: "a", IsDir: true}, want: true},
+		7: {src: doc, copied: &File{Name: "notes"}, want: true},
+		8: {src: &File{Name: "a"}, copied: nil, want: false},
+	}
+
+	for i, tt := range tests {
+		if got := migratedIntact(tt.src, tt.copied); got != tt.want {
+			t.Errorf("#%d: have %v want %v", i, got, tt.want)
+		}
+	}
+}
+
+func TestMigrateStateResumes(t *testing.T) {
+	gdPath, err := ioutil.TempDir("", "migrate-state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(gdPath)
+
+	p := filepath.Join(gdPath, migrateStateName("", "work"))
+	state, err := loadMigrateState(p)
+	if err != nil {
+		t.Fatalf("fresh state: %v", err)
+	}
+	if len(state.Copied) != 0 {
+		t.Fatalf("fresh state: have %v copies", state.Copied)
+	}
+
+	state.Copied["src-id"] = "dest-id"
+	if err := state.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	resumed, err := loadMigrateState(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resumed.Copied["src-id"]; got != "dest-id" {
+		t.Errorf("resumed state: have %q want %q", got, "dest-id")
+	}
+
+	// Each pair of accounts has its own state.
+	other, err := loadMigrateState(filepath.Join(gdPath, migrateStateName("work", "")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(other.Copied) != 0 {
+		t.Errorf("reverse migration: have %v copies", other.Copied)
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/odeke-em/drive/config"
+)
+
+// legacyCacheFiles are the cache files that versions of drive which
+// predate CachePath kept in the context's metadata directory.
+var legacyCacheFiles = []string{config.DriveDb, MetadataCacheJSON, StatusCacheJSON}
+
+// legacyCredentialFields describes what data, the JSON of a credentials
+// file, lacks of the current format: the credential types that predate
+// CredentialType and the root that predates LastKnownRoot.
+func legacyCredentialFields(data []byte) ([]string, error) {
+	type typed struct {
+		CredentialType string `json:"credential_type"`
+	}
+	var stored struct {
+		typed
+		LastKnownRoot string            `json:"last_known_root"`
+		Accounts      map[string]*typed `json:"accounts"`
+	}
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, err
+	}
+
+	var fields []string
+	if stored.CredentialType == "" {
+		fields = append(fields, "the credential type of the default credentials")
+	}
+	var names []string
+	for name, account := range stored.Accounts {
+		if account != nil && account.CredentialType == "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fields = append(fields, fmt.Sprintf("the credential type of account %q", name))
+	}
+	if stored.LastKnownRoot == "" {
+		fields = append(fields, "the root of the context")
+	}
+	return fields, nil
+}
+
+// MigrateLayout upgrades a context written by an earlier version of drive
+// to the current layout in place. Its cache files are moved out of its
+// metadata directory, the indices that it kept in files of their own are
+// moved into the index and its credentials are rewritten in the current
+// format, refresh tokens included, so that it needn't be initialized and
+// pulled afresh.
+func (g *Commands) MigrateLayout() error {
+	data, err := g.context.CredentialsData()
+	if os.IsNotExist(err) {
+		return config.ErrNoDriveContext
+	}
+	if err != nil {
+		return err
+	}
+	fields, err := legacyCredentialFields(data)
+	if err != nil {
+		return illogicalStateErr(fmt.Errorf("credentials: %v", err))
+	}
+
+	upgraded := false
+
+	gdPath := g.context.GDPath()
+	for _, name := range legacyCacheFiles {
+		legacyPath := filepath.Join(gdPath, name)
+		if _, err := os.Stat(legacyPath); err != nil {
+			continue
+		}
+		upgraded = true
+
+		if err := g.context.EnsureCachePath(name); err != nil {
+			return err
+		}
+		p := g.context.CacheFilePath(name)
+		switch _, err := os.Stat(legacyPath); {
+		case p == legacyPath:
+			cachePath, _ := g.context.CachePath()
+			g.log.LogErrf("%s couldn't be moved into %s so it stays in use where it is\n", legacyPath, cachePath)
+		case err == nil:
+			g.log.LogErrf("%s is stale, %s is used in its place\n", legacyPath, p)
+		default:
+			g.log.Logf("Moved %s to %s\n", legacyPath, p)
+		}
+	}
+
+	imported, skipped, err := g.context.ImportLegacyIndices()
+	if imported >= 1 {
+		upgraded = true
+		g.log.Logf("Moved %d indices from %s into the index\n", imported, g.context.LegacyIndicesDir())
+	}
+	for _, p := range skipped {
+		g.log.LogErrf("%s isn't an index, leaving it in place\n", p)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(fields) >= 1 {
+		if g.context.LastKnownRoot == "" {
+			g.context.LastKnownRoot = g.context.AbsPath
+		}
+		if err := g.context.Write(); err != nil {
+			return err
+		}
+		upgraded = true
+		for _, field := range fields {
+			g.log.Logf("Recorded %s\n", field)
+		}
+	}
+
+	if !upgraded {
+		g.log.Logln("The context is already in the current layout")
+	}
+	return nil
+}
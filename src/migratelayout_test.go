@@ -0,0 +1,62 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLegacyCredentialFields(t *testing.T) {
+	tests := []struct {
+		data    string
+		want    []string
+		wantErr bool
+	}{
+		{
+			data: `{"credential_type":"oauth2","refresh_token":"t","last_known_root":"/home/a/gdrive"}`,
+		},
+		{
+			data: `{"client_id":"id","client_secret":"s","refresh_token":"t"}`,
+			want: []string{
+				"the credential type of the default credentials",
+				"the root of the context",
+			},
+		},
+		{
+			data: `{"credential_type":"oauth2","last_known_root":"/r","accounts":{` +
+				`"work":{"refresh_token":"t"},"ci":{"credential_type":"service_account"},"home":{"refresh_token":"u"},"gone":null}}`,
+			want: []string{
+				`the credential type of account "home"`,
+				`the credential type of account "work"`,
+			},
+		},
+		{
+			data:    `{"credential_type":`,
+			wantErr: true,
+		},
+	}
+
+	for i, tt := range tests {
+		got, err := legacyCredentialFields([]byte(tt.data))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("#%d: got err %v, want an error %v", i, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("#%d: got %q want %q", i, got, tt.want)
+		}
+	}
+}
@@ -53,6 +53,7 @@ const (
 	MsgErrFileNotMutable    = "File not mutable"
 
 	DriveIgnoreSuffix                 = ".driveignore"
+	DriveIncludeSuffix                = ".driveinclude"
 	DriveIgnoreNegativeLookAheadToken = "!"
 )
 
@@ -139,6 +140,11 @@ func retryableErrorCheck(v interface{}) (ok, retryable bool) {
 		return
 	}
 
+	// Retrying can't re-read a consumed body.
+	if lastErr, isErr := pr.last.(error); isErr && (isModifiedDuringUpload(lastErr) || lastErr == ErrCiphertextNotVerified) {
+		return
+	}
+
 	err, assertOk := pr.last.(*googleapi.Error)
 	// In relation to https://github.com/google/google-api-go-client/issues/93
 	// where not every error is of googleapi.Error instance e.g io timeout errors
@@ -200,6 +206,9 @@ func internalIgnores() (ignores []string) {
 	if runtime.GOOS == OSLinuxKey {
 		ignores = append(ignores, "\\.\\s*desktop$")
 	}
+	ignores = append(ignores, placeholderIgnore())
+	// The copy of the crypt keyring is kept remotely only.
+	ignores = append(ignores, "(^|/)"+regexp.QuoteMeta(cryptKeyringRemoteName)+"$")
 	return ignores
 }
 
@@ -526,6 +535,36 @@ func NonEmptyTrimmedStrings(v ...string) (splits []string) {
 	return nonEmptyStrings(strings.TrimSpace, v...)
 }
 
+// ReadFilesFrom reads a list of paths, one per line, from the
+// file at p or from stdin if p is "-". Blank lines are skipped.
+func ReadFilesFrom(p string) ([]string, error) {
+	if p == "-" {
+		return readPathList(os.Stdin)
+	}
+
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return readPathList(f)
+}
+
+func readPathList(r io.Reader) (paths []string, err error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		// Only trim line endings since paths may legitimately
+		// start or end with spaces.
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		paths = append(paths, line)
+	}
+	return paths, scanner.Err()
+}
+
 var regExtStrMap = map[string]string{
 	"csv":   "text/csv",
 	"html?": "text/html",
@@ -694,17 +733,75 @@ func ignorerByClause(clauses ...string) (ignorer func(string) bool, err error) {
 	return ignorer, nil
 }
 
-func combineIgnores(ignoresPath string) (ignorer func(string) bool, err error) {
-	clauses, err := readCommentedFile(ignoresPath, "#")
+// combineIgnores builds the ignorer of the context at root from its
+// ignore profiles, its .driveignore files and the internal ignores.
+func combineIgnores(root string, profileClauses ...string) (ignorer func(string) bool, err error) {
+	ignoresPath := filepath.Join(root, DriveIgnoreSuffix)
+	custom, err := readCommentedFile(ignoresPath, "#")
 	if err != nil && !os.IsNotExist(err) {
 		return nil, err
 	}
 
+	// The root .driveignore is made of regular expressions unless a
+	// `syntax: gitignore` line switches to gitignore patterns.
+	custom, patterns, err := splitIgnoreSyntax(custom, ignoreSyntaxRegexp)
+	if err != nil {
+		return nil, err
+	}
+	rootRules, err := parseGitignoreRules(patterns)
+	if err != nil {
+		return nil, err
+	}
+
 	// TODO: Should internalIgnores only be added only
 	// after all the exclusion and exclusion steps.
-	clauses = append(clauses, internalIgnores()...)
+	clauses := append(custom, internalIgnores()...)
 
-	return ignorerByClause(clauses...)
+	customIgnorer, err := ignorerByClause(clauses...)
+	if err != nil {
+		return nil, err
+	}
+	customIncluder, err := includerByClause(clauses...)
+	if err != nil {
+		return nil, err
+	}
+	profileIgnorer, err := ignorerByClause(profileClauses...)
+	if err != nil {
+		return nil, err
+	}
+
+	di := newDriveIgnore(root, rootRules)
+	ignorer = func(p string) bool {
+		// A trailing "/" only hints at a directory for gitignore patterns.
+		trimmed := strings.TrimRight(p, "/")
+		if customIgnorer != nil && customIgnorer(trimmed) {
+			return true
+		}
+		// The custom clauses are layered over the profiles: a custom
+		// exception keeps what a profile ignores, while the exceptions
+		// of a profile never keep what a custom clause ignores.
+		included := customIncluder != nil && customIncluder(trimmed)
+		if !included && profileIgnorer != nil && profileIgnorer(trimmed) {
+			return true
+		}
+		return di.ignored(p)
+	}
+	return ignorer, nil
+}
+
+// includerByClause reports whether a path is explicitly kept by one of
+// the "!" exceptions among clauses.
+func includerByClause(clauses ...string) (includer func(string) bool, err error) {
+	_, includes := siftExcludes(clauses)
+	if len(includes) < 1 {
+		return nil, nil
+	}
+
+	incRegComp, incRegErr := regexp.Compile(strings.Join(includes, "|"))
+	if incRegErr != nil {
+		return nil, makeErrorWithStatus("includeIgnoreRegErr", incRegErr, StatusIllogicalState)
+	}
+	return func(s string) bool { return incRegComp.MatchString(s) }, nil
 }
 
 var mimeTypeFromQuery = cacher(regMapper(regExtStrMap, map[string]string{
@@ -953,6 +1050,18 @@ type fsListingArg struct {
 	hidden  bool
 	ignore  func(string) bool
 	depth   int
+
+	// skip if set is told of each file left out for
+	// a reason other than being hidden or ignored.
+	skip func(p, reason string)
+}
+
+func (flArg *fsListingArg) skipped(p, reason string) {
+	if flArg.skip != nil {
+		flArg.skip(p, reason)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "%s %s\n", p, reason)
 }
 
 func list(flArg *fsListingArg) (fileChan chan *File, err error) {
@@ -994,8 +1103,8 @@ func list(flArg *fsListingArg) (fileChan chan *File, err error) {
 			}
 
 			// TODO: (@odeke-em) decide on how to deal with isFifo
-			if namedPipe(file.Mode()) {
-				fmt.Fprintf(os.Stderr, "%s (%s) is a named pipe, not reading from it\n", p, resPath)
+			if reason := unsupportedFileType(file.Mode()); reason != "" {
+				flArg.skipped(resPath, reason)
 				continue
 			}
 
@@ -1005,6 +1114,7 @@ func list(flArg *fsListingArg) (fileChan chan *File, err error) {
 				var symResolvPath string
 				symResolvPath, err = filepath.EvalSymlinks(resPath)
 				if err != nil {
+					flArg.skipped(resPath, fmt.Sprintf("unresolvable symlink: %v", err))
 					continue
 				}
 
@@ -1015,6 +1125,12 @@ func list(flArg *fsListingArg) (fileChan chan *File, err error) {
 				var symInfo os.FileInfo
 				symInfo, err = os.Stat(symResolvPath)
 				if err != nil {
+					flArg.skipped(resPath, err.Error())
+					continue
+				}
+
+				if reason := unsupportedFileType(symInfo.Mode()); reason != "" {
+					flArg.skipped(resPath, reason)
 					continue
 				}
 
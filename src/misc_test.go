@@ -230,6 +230,14 @@ func TestRetryableErrorCheck(t *testing.T) {
 			success: false, retryable: false,
 			comment: "issue #472 FileNotMutable is unretryable, casefold held",
 		},
+		{
+			value: &tuple{
+				first: nil,
+				last:  ErrCiphertextNotVerified,
+			},
+			success: false, retryable: false,
+			comment: "encrypted content that doesn't verify isn't resent",
+		},
 	}
 
 	for _, tc := range cases {
@@ -461,3 +469,27 @@ func TestCrudToAtoi(t *testing.T) {
 		}
 	}
 }
+
+func TestReadPathList(t *testing.T) {
+	testCases := []struct {
+		specimen string
+		want     []string
+	}{
+		{specimen: "", want: nil},
+		{specimen: "\n\n\n", want: nil},
+		{specimen: "a.txt\nb/c.txt\n", want: []string{"a.txt", "b/c.txt"}},
+		{specimen: "a.txt\r\n\r\nb/c.txt", want: []string{"a.txt", "b/c.txt"}},
+		{specimen: " spaced name .txt\n", want: []string{" spaced name .txt"}},
+	}
+
+	for i, tc := range testCases {
+		got, err := readPathList(strings.NewReader(tc.specimen))
+		if err != nil {
+			t.Errorf("#%d: unexpected err %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("#%d: got %q want %q", i, got, tc.want)
+		}
+	}
+}
@@ -73,8 +73,11 @@ func (g *Commands) Move(byId, keepParent bool) error {
 		}
 
 		if err := g.move(&opt); err != nil {
-			message := fmt.Sprintf("move: %s: %v", src, err)
-			composedError = reComposeError(composedError, message)
+			fileId := ""
+			if byId {
+				fileId = src
+			}
+			composedError = combineErrors(composedError, pathErr("move", OpNone, src, fileId, err))
 		}
 	}
 
@@ -133,7 +136,9 @@ func (g *Commands) move(opt *moveOpt) (err error) {
 			return illogicalStateErr(fmt.Errorf("move: trying to move fileId:%s to self fileId:%s", customQuote(dupCheck.Id), customQuote(remSrc.Id)))
 		}
 		if !g.opts.Force {
-			return overwriteAttemptedErr(fmt.Errorf("%s already exists. Use `%s` flag to override this behaviour", newFullPath, ForceKey))
+			if err = g.resolveMoveConflict(remSrc, dupCheck, opt.dest); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -157,6 +162,63 @@ func (g *Commands) move(opt *moveOpt) (err error) {
 	return err
 }
 
+// resolveMoveConflict clears the way for src to be moved into the folder at
+// destDir, where existing has the same name: existing is renamed with the
+// backup suffix appended or trashed if overwriting, else the move aborts.
+func (g *Commands) resolveMoveConflict(src, existing *File, destDir string) error {
+	existingPath := sepJoin(RemoteSeparator, destDir, existing.Name)
+
+	switch {
+	case g.opts.BackupSuffix != "":
+		exists := func(name string) (bool, error) {
+			f, err := g.rem.FindByPath(sepJoin(RemoteSeparator, destDir, name))
+			if err != nil && err != ErrPathNotExists {
+				return false, err
+			}
+			return f != nil, nil
+		}
+		backup, err := backupName(existing.Name, g.opts.BackupSuffix, exists)
+		if err != nil {
+			return err
+		}
+		if _, err := g.rem.rename(existing.Id, urlToPath(backup, false)); err != nil {
+			return fmt.Errorf("backing up %s: %v", customQuote(existingPath), err)
+		}
+		g.log.Logf("%s -> %s\n", customQuote(existingPath), customQuote(sepJoin(RemoteSeparator, destDir, backup)))
+		return nil
+
+	case g.opts.Overwrite:
+		if src.IsDir != existing.IsDir {
+			return overwriteAttemptedErr(fmt.Errorf("%s is a %s, it can't be overwritten by a %s",
+				existingPath, existing.dirTypeNomenclature(), src.dirTypeNomenclature()))
+		}
+		if err := g.rem.Trash(existing.Id); err != nil {
+			return fmt.Errorf("overwriting %s: %v", customQuote(existingPath), err)
+		}
+		g.log.Logf("Trashed %s to overwrite it\n", customQuote(existingPath))
+		return nil
+	}
+
+	return overwriteAttemptedErr(fmt.Errorf("%s already exists. Use `-%s` or `-%s` to move it out of the way",
+		existingPath, CLIOptionBackupSuffix, CLIOptionOverwrite))
+}
+
+// backupName returns name with suffix appended, numbered e.g "a.txt.bak.2"
+// if need be to find a name that doesn't exist yet.
+func backupName(name, suffix string, exists func(string) (bool, error)) (string, error) {
+	backup := name + suffix
+	for i := 1; ; i++ {
+		taken, err := exists(backup)
+		if err != nil {
+			return "", err
+		}
+		if !taken {
+			return backup, nil
+		}
+		backup = fmt.Sprintf("%s%s.%d", name, suffix, i)
+	}
+}
+
 func (g *Commands) removeParent(fileId, relToRootPath string) error {
 	parentPath := g.parentPather(relToRootPath)
 	parent, pErr := g.rem.FindByPath(parentPath)
@@ -211,12 +273,21 @@ func (g *Commands) Rename(byId bool) error {
 	}
 	remSrc, err := resolver(src)
 	if err != nil {
-		return fmt.Errorf("%s: %v", src, err)
+		return pathErr("rename", OpNone, src, "", err)
 	}
 	if remSrc == nil {
 		return illogicalStateErr(fmt.Errorf("%s does not exist", src))
 	}
 
+	if err := g.rename(remSrc, src, byId); err != nil {
+		return pathErr("rename", OpNone, src, remSrc.Id, err)
+	}
+	return nil
+}
+
+// rename renames remSrc found at src, remotely and or locally by the rename mode.
+func (g *Commands) rename(remSrc *File, src string, byId bool) (err error) {
+
 	var parentPath string
 	if !byId {
 		parentPath = g.parentPather(src)
@@ -0,0 +1,66 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestBackupName(t *testing.T) {
+	cases := []struct {
+		name    string
+		suffix  string
+		taken   []string
+		want    string
+		wantErr bool
+	}{
+		{name: "report.pdf", suffix: ".bak", want: "report.pdf.bak"},
+		{name: "report.pdf", suffix: "~", want: "report.pdf~"},
+		{
+			name: "report.pdf", suffix: ".bak",
+			taken: []string{"report.pdf.bak", "report.pdf.bak.1"},
+			want:  "report.pdf.bak.2",
+		},
+		{name: "photos", suffix: ".old", taken: []string{"photos.old.1"}, want: "photos.old"},
+		{name: "report.pdf", suffix: ".bak", taken: []string{"error"}, wantErr: true},
+	}
+
+	for i, tc := range cases {
+		taken := map[string]bool{}
+		failing := false
+		for _, name := range tc.taken {
+			if name == "error" {
+				failing = true
+			}
+			taken[name] = true
+		}
+		exists := func(name string) (bool, error) {
+			if failing {
+				return false, errors.New("lookup failed")
+			}
+			return taken[name], nil
+		}
+
+		got, err := backupName(tc.name, tc.suffix, exists)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("#%d: err: got %v wantErr %v", i, err, tc.wantErr)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("#%d: got %q want %q", i, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/odeke-em/drive/config"
+)
+
+// The OAuth2 client that drive authenticates as unless another is configured.
+const (
+	defaultClientId     = "354790962074-7rrlnuanmamgg1i4feed12dpuq871bvd.apps.googleusercontent.com"
+	defaultClientSecret = "RHjKdah8RrHFwu6fcc0uEVCw"
+)
+
+// oauthClient is an OAuth2 client id and secret along with where they came from.
+type oauthClient struct {
+	id     string
+	secret string
+	source string
+}
+
+// effectiveClient returns the OAuth2 client that context authenticates as.
+// A client set in DRIVE_CLIENT_ID and DRIVE_CLIENT_SECRET, or else by the
+// client-id and client-secret keys of a .driverc or of the global config,
+// takes precedence over that of the credentials file, so that an
+// organization can use the quotas of its own API project without running
+// init again.
+func effectiveClient(context *config.Context, getenv func(string) string) *oauthClient {
+	if id, secret := getenv(DriveClientIdEnvKey), getenv(DriveClientSecretEnvKey); id != "" && secret != "" {
+		return &oauthClient{id: id, secret: secret, source: fmt.Sprintf("%s and %s", DriveClientIdEnvKey, DriveClientSecretEnvKey)}
+	}
+	absPath := ""
+	if context != nil {
+		absPath = context.AbsPath
+	}
+	if client := configuredClient(absPath); client != nil {
+		return client
+	}
+	if context != nil && context.ClientId != "" && context.ClientSecret != "" {
+		return &oauthClient{id: context.ClientId, secret: context.ClientSecret, source: "credentials file"}
+	}
+	return &oauthClient{id: defaultClientId, secret: defaultClientSecret, source: "built-in"}
+}
+
+// configuredClient returns the client set by the client-id and client-secret
+// keys of the .driverc files that apply to absPath, or else of the global
+// config, if any. It is a variable so that tests don't read the real files.
+var configuredClient = func(absPath string) *oauthClient {
+	if absPath != "" {
+		if rcMappings, err := ResourceMappings(absPath); err == nil {
+			if client := clientFromSettings(rcMappings, DriveResourceConfiguration); client != nil {
+				return client
+			}
+		}
+	}
+	if globalMappings, err := GlobalConfigMappings(globalConfigPath()); err == nil {
+		return clientFromSettings(globalMappings, GlobalConfigJSON)
+	}
+	return nil
+}
+
+// clientFromSettings returns the client set in the global section of
+// mappings read from source, if both its id and secret are set.
+func clientFromSettings(mappings map[string]map[string]interface{}, source string) *oauthClient {
+	settings := mergeNamespaces(mappings)
+	id, _ := settings[CLIOptionClientId].(string)
+	secret, _ := settings[CLIOptionClientSecret].(string)
+	if id == "" || secret == "" {
+		return nil
+	}
+	return &oauthClient{id: id, secret: secret, source: fmt.Sprintf("%s and %s in %s", CLIOptionClientId, CLIOptionClientSecret, source)}
+}
+
+// initClient returns the OAuth2 client that init stores in the credentials,
+// which may also be set in the older GOOGLE_API_CLIENT_ID and
+// GOOGLE_API_CLIENT_SECRET.
+func initClient(absPath string, getenv func(string) string) *oauthClient {
+	if id, secret := getenv(GoogleApiClientIdEnvKey), getenv(GoogleApiClientSecretEnvKey); id != "" && secret != "" {
+		if getenv(DriveClientIdEnvKey) == "" || getenv(DriveClientSecretEnvKey) == "" {
+			return &oauthClient{id: id, secret: secret, source: fmt.Sprintf("%s and %s", GoogleApiClientIdEnvKey, GoogleApiClientSecretEnvKey)}
+		}
+	}
+	// The client stored by an earlier init isn't carried over.
+	return effectiveClient(&config.Context{AbsPath: absPath}, getenv)
+}
+
+// AboutAuth prints how the context authenticates: the type of its
+// credentials and, for OAuth2, the client in use and where it was set.
+func (g *Commands) AboutAuth() error {
+	g.log.Logf("Credentials: %s\n", credentialTypeName(g.context))
+	if g.context.Remote != "" {
+		g.log.Logf("Remote: %s\n", g.context.Remote)
+	}
+	if g.context.Account != "" {
+		g.log.Logf("Account: %s\n", g.context.Account)
+	}
+
+	switch g.context.CredentialType {
+	case config.CredentialTypeServiceAccount:
+		if g.context.GSAJWTConfig != nil {
+			g.log.Logf("Service account: %s\n", g.context.GSAJWTConfig.Email)
+		}
+		if subject := g.context.ImpersonatedSubject(); subject != "" {
+			g.log.Logf("Impersonating: %s\n", subject)
+		}
+	case config.CredentialTypeApplicationDefault:
+	default:
+		client := effectiveClient(g.context, os.Getenv)
+		g.log.Logf("Client ID: %s\nClient from: %s\n", client.id, client.source)
+	}
+
+	g.log.Logf("Scope: %s\n", scopeFor(g.context))
+	return nil
+}
+
+func credentialTypeName(context *config.Context) string {
+	switch context.CredentialType {
+	case config.CredentialTypeServiceAccount:
+		return "service account"
+	case config.CredentialTypeApplicationDefault:
+		return "Application Default Credentials"
+	}
+	return "OAuth2 refresh token"
+}
@@ -0,0 +1,145 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"testing"
+
+	"github.com/odeke-em/drive/config"
+)
+
+func envOf(m map[string]string) func(string) string {
+	return func(key string) string {
+		return m[key]
+	}
+}
+
+// withConfiguredClient makes the .driverc and global config set client,
+// which may be nil, for the duration of a test.
+func withConfiguredClient(client *oauthClient) func() {
+	prev := configuredClient
+	configuredClient = func(string) *oauthClient {
+		return client
+	}
+	return func() {
+		configuredClient = prev
+	}
+}
+
+func TestEffectiveClient(t *testing.T) {
+	defer withConfiguredClient(nil)()
+
+	stored := &config.Context{}
+	stored.ClientId, stored.ClientSecret = "stored-id", "stored-secret"
+
+	testCases := []struct {
+		desc       string
+		context    *config.Context
+		env        map[string]string
+		wantId     string
+		wantSource string
+	}{
+		{
+			desc: "built-in", context: &config.Context{},
+			wantId: defaultClientId, wantSource: "built-in",
+		},
+		{
+			desc: "credentials file", context: stored,
+			wantId: "stored-id", wantSource: "credentials file",
+		},
+		{
+			desc: "environment overrides the credentials file", context: stored,
+			env:    map[string]string{DriveClientIdEnvKey: "env-id", DriveClientSecretEnvKey: "env-secret"},
+			wantId: "env-id", wantSource: DriveClientIdEnvKey + " and " + DriveClientSecretEnvKey,
+		},
+		{
+			desc: "an id without a secret is ignored", context: stored,
+			env:    map[string]string{DriveClientIdEnvKey: "env-id"},
+			wantId: "stored-id", wantSource: "credentials file",
+		},
+		{
+			desc: "the init-only keys are ignored at runtime", context: stored,
+			env:    map[string]string{GoogleApiClientIdEnvKey: "old-id", GoogleApiClientSecretEnvKey: "old-secret"},
+			wantId: "stored-id", wantSource: "credentials file",
+		},
+	}
+
+	for _, tc := range testCases {
+		got := effectiveClient(tc.context, envOf(tc.env))
+		if got.id != tc.wantId || got.source != tc.wantSource {
+			t.Errorf("%s: got %q from %q want %q from %q", tc.desc, got.id, got.source, tc.wantId, tc.wantSource)
+		}
+	}
+}
+
+func TestEffectiveClientFromSettings(t *testing.T) {
+	mappings := map[string]map[string]interface{}{
+		"global": {CLIOptionClientId: "rc-id", CLIOptionClientSecret: "rc-secret"},
+	}
+	configured := clientFromSettings(mappings, DriveResourceConfiguration)
+	if configured == nil {
+		t.Fatalf("no client from %v", mappings)
+	}
+	defer withConfiguredClient(configured)()
+
+	stored := &config.Context{}
+	stored.ClientId, stored.ClientSecret = "stored-id", "stored-secret"
+
+	if got := effectiveClient(stored, envOf(nil)); got.id != "rc-id" || got.source != configured.source {
+		t.Errorf("settings over the credentials file: got %q from %q", got.id, got.source)
+	}
+	env := envOf(map[string]string{DriveClientIdEnvKey: "env-id", DriveClientSecretEnvKey: "env-secret"})
+	if got := effectiveClient(stored, env); got.id != "env-id" {
+		t.Errorf("environment over settings: got %q from %q", got.id, got.source)
+	}
+	if got := initClient("", envOf(nil)); got.id != "rc-id" {
+		t.Errorf("init: got %q from %q want the configured client", got.id, got.source)
+	}
+
+	halves := map[string]map[string]interface{}{
+		"global": {CLIOptionClientId: "rc-id"},
+	}
+	if client := clientFromSettings(halves, DriveResourceConfiguration); client != nil {
+		t.Errorf("an id without a secret: got %q", client.id)
+	}
+}
+
+func TestInitClient(t *testing.T) {
+	defer withConfiguredClient(nil)()
+
+	testCases := []struct {
+		env    map[string]string
+		wantId string
+	}{
+		{wantId: defaultClientId},
+		{
+			env:    map[string]string{GoogleApiClientIdEnvKey: "old-id", GoogleApiClientSecretEnvKey: "old-secret"},
+			wantId: "old-id",
+		},
+		{
+			env: map[string]string{
+				GoogleApiClientIdEnvKey: "old-id", GoogleApiClientSecretEnvKey: "old-secret",
+				DriveClientIdEnvKey: "env-id", DriveClientSecretEnvKey: "env-secret",
+			},
+			wantId: "env-id",
+		},
+	}
+
+	for i, tc := range testCases {
+		if got := initClient("", envOf(tc.env)); got.id != tc.wantId {
+			t.Errorf("#%d: got %q want %q", i, got.id, tc.wantId)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"hash"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultObjectCacheSize is how large the object cache grows by default
+// before the least recently used objects are evicted.
+const DefaultObjectCacheSize = "10G"
+
+// objects is the object cache, nil unless UseObjectCache enabled it.
+var objects *objectCache
+
+// UseObjectCache keeps the content of downloaded files in dir, keyed by
+// md5 checksum, so that pulling the same content again e.g into another
+// context or after it was moved remotely copies it from the cache instead
+// of downloading it. The least recently used objects are evicted once the
+// cache grows past maxSize e.g 10G. An empty dir leaves it disabled.
+func UseObjectCache(dir, maxSize string) error {
+	dir = strings.TrimSpace(dir)
+	if dir == "" {
+		return nil
+	}
+	if strings.TrimSpace(maxSize) == "" {
+		maxSize = DefaultObjectCacheSize
+	}
+	size, err := ParseByteSize(maxSize)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	objects = &objectCache{dir: dir, maxSize: size, now: time.Now}
+	return nil
+}
+
+type objectCache struct {
+	dir     string
+	maxSize int64
+	now     func() time.Time
+
+	// mu serializes the evictions.
+	mu sync.Mutex
+}
+
+func validMd5Checksum(md5Checksum string) bool {
+	if len(md5Checksum) != md5.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(md5Checksum)
+	return err == nil
+}
+
+// objectPath fans the objects out by the first two digits of their checksum.
+func (oc *objectCache) objectPath(md5Checksum string) string {
+	md5Checksum = strings.ToLower(md5Checksum)
+	return filepath.Join(oc.dir, md5Checksum[:2], md5Checksum)
+}
+
+// open returns the cached content with md5Checksum, nil if there is none.
+func (oc *objectCache) open(md5Checksum string) *os.File {
+	if !validMd5Checksum(md5Checksum) {
+		return nil
+	}
+	p := oc.objectPath(md5Checksum)
+	f, err := os.Open(p)
+	if err != nil {
+		return nil
+	}
+	// The modification time is when it was last used, for the evictions.
+	now := oc.now()
+	os.Chtimes(p, now, now)
+	return f
+}
+
+// objectWriter copies what is written to it into a temporary file that is
+// only added to the cache once its checksum turns out as expected.
+type objectWriter struct {
+	oc          *objectCache
+	md5Checksum string
+	tmp         *os.File
+	hash        hash.Hash
+	failed      bool
+}
+
+// writer returns a writer whose content, once committed, is cached
+// under md5Checksum, or nil if it can't be.
+func (oc *objectCache) writer(md5Checksum string) *objectWriter {
+	if !validMd5Checksum(md5Checksum) {
+		return nil
+	}
+	tmp, err := ioutil.TempFile(oc.dir, "partial-")
+	if err != nil {
+		DebugPrintf("object cache: %v", err)
+		return nil
+	}
+	return &objectWriter{oc: oc, md5Checksum: strings.ToLower(md5Checksum), tmp: tmp, hash: md5.New()}
+}
+
+// Write never fails so that a problem with the cache doesn't fail the download.
+func (ow *objectWriter) Write(b []byte) (int, error) {
+	if !ow.failed {
+		if _, err := ow.tmp.Write(b); err != nil {
+			DebugPrintf("object cache: %v", err)
+			ow.failed = true
+		}
+		ow.hash.Write(b)
+	}
+	return len(b), nil
+}
+
+// commit adds the content to the cache if it was completely written.
+func (ow *objectWriter) commit(complete bool) {
+	tmpPath := ow.tmp.Name()
+	closeErr := ow.tmp.Close()
+
+	if ow.failed || !complete || closeErr != nil || hex.EncodeToString(ow.hash.Sum(nil)) != ow.md5Checksum {
+		os.Remove(tmpPath)
+		return
+	}
+
+	p := ow.oc.objectPath(ow.md5Checksum)
+	if err := os.MkdirAll(filepath.Dir(p), 0700); err == nil {
+		err = os.Rename(tmpPath, p)
+	}
+	if _, err := os.Stat(p); err != nil {
+		os.Remove(tmpPath)
+		return
+	}
+	ow.oc.evict()
+}
+
+type cachedObject struct {
+	path    string
+	size    int64
+	lastUse time.Time
+}
+
+type cachedObjectsByLastUse []cachedObject
+
+func (co cachedObjectsByLastUse) Len() int           { return len(co) }
+func (co cachedObjectsByLastUse) Swap(i, j int)      { co[i], co[j] = co[j], co[i] }
+func (co cachedObjectsByLastUse) Less(i, j int) bool { return co[i].lastUse.Before(co[j].lastUse) }
+
+// evict removes the least recently used objects until the cache fits in maxSize.
+func (oc *objectCache) evict() {
+	oc.mu.Lock()
+	defer oc.mu.Unlock()
+
+	var cached []cachedObject
+	total := int64(0)
+	filepath.Walk(oc.dir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() || !validMd5Checksum(fi.Name()) {
+			return nil
+		}
+		cached = append(cached, cachedObject{path: p, size: fi.Size(), lastUse: fi.ModTime()})
+		total += fi.Size()
+		return nil
+	})
+	if total <= oc.maxSize {
+		return
+	}
+
+	sort.Sort(cachedObjectsByLastUse(cached))
+	for _, obj := range cached {
+		if total <= oc.maxSize {
+			break
+		}
+		if err := os.Remove(obj.path); err != nil {
+			DebugPrintf("object cache: evicting %s: %v", obj.path, err)
+			continue
+		}
+		total -= obj.size
+	}
+}
+
+// objectCacheable reports whether the content downloaded by dlArg can be
+// cached. Exports have no checksum and decrypted content mustn't end up
+// at rest in plain text outside of the context.
+func (g *Commands) objectCacheable(dlArg *downloadArg) bool {
+	return objects != nil && dlArg.exportURL == "" && validMd5Checksum(dlArg.md5Checksum) && g.rem.decrypter == nil
+}
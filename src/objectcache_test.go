@@ -0,0 +1,147 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func md5Of(content string) string {
+	sum := md5.Sum([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func cacheObject(oc *objectCache, md5Checksum, content string, complete bool) {
+	ow := oc.writer(md5Checksum)
+	if ow == nil {
+		return
+	}
+	ow.Write([]byte(content))
+	ow.commit(complete)
+}
+
+func cachedContent(t *testing.T, oc *objectCache, md5Checksum string) (string, bool) {
+	f := oc.open(md5Checksum)
+	if f == nil {
+		return "", false
+	}
+	defer f.Close()
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b), true
+}
+
+func TestObjectCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "object-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	oc := &objectCache{dir: dir, maxSize: 1 << 20, now: time.Now}
+
+	content := "the same large file, pulled into two contexts"
+	cacheObject(oc, md5Of(content), content, true)
+	if got, ok := cachedContent(t, oc, md5Of(content)); !ok || got != content {
+		t.Errorf("got %q, %v; want %q", got, ok, content)
+	}
+
+	// Incomplete downloads and content that doesn't match its checksum aren't cached.
+	partial := "interrupted"
+	cacheObject(oc, md5Of(partial), partial[:4], true)
+	cacheObject(oc, md5Of(partial), partial, false)
+	if _, ok := cachedContent(t, oc, md5Of(partial)); ok {
+		t.Errorf("%q mustn't be cached", partial)
+	}
+
+	for _, md5Checksum := range []string{"", "not-a-checksum", "../" + md5Of(content)[3:]} {
+		if oc.writer(md5Checksum) != nil || oc.open(md5Checksum) != nil {
+			t.Errorf("%q must be rejected", md5Checksum)
+		}
+	}
+
+	leftovers, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fi := range leftovers {
+		if !fi.IsDir() {
+			t.Errorf("temporary file %q left behind", fi.Name())
+		}
+	}
+}
+
+func TestObjectCacheEviction(t *testing.T) {
+	dir, err := ioutil.TempDir("", "object-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	now := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	oc := &objectCache{dir: dir, maxSize: 25, now: func() time.Time { return now }}
+
+	contents := []string{"0123456789", "abcdefghij", "ABCDEFGHIJ"}
+	for i, content := range contents[:2] {
+		cacheObject(oc, md5Of(content), content, true)
+		at := now.Add(time.Duration(i) * time.Minute)
+		os.Chtimes(oc.objectPath(md5Of(content)), at, at)
+	}
+
+	// Using the oldest one makes the second one the least recently used.
+	now = now.Add(time.Hour)
+	if _, ok := cachedContent(t, oc, md5Of(contents[0])); !ok {
+		t.Fatalf("%q must be cached", contents[0])
+	}
+
+	cacheObject(oc, md5Of(contents[2]), contents[2], true)
+
+	for i, want := range []bool{true, false, true} {
+		if _, ok := cachedContent(t, oc, md5Of(contents[i])); ok != want {
+			t.Errorf("%q: cached %v want %v", contents[i], ok, want)
+		}
+	}
+}
+
+func TestUseObjectCache(t *testing.T) {
+	defer func() { objects = nil }()
+
+	if err := UseObjectCache("", ""); err != nil || objects != nil {
+		t.Errorf("an empty dir must leave the cache disabled, got %v, %v", objects, err)
+	}
+
+	dir, err := ioutil.TempDir("", "object-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := UseObjectCache(dir, "not a size"); err == nil {
+		t.Errorf("expected an error for an invalid size")
+	}
+	if err := UseObjectCache(dir, ""); err != nil {
+		t.Fatal(err)
+	}
+	if objects == nil || objects.maxSize != 10<<30 {
+		t.Errorf("got %+v, want a cache of %s", objects, DefaultObjectCacheSize)
+	}
+}
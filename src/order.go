@@ -0,0 +1,102 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Transfer orders that changes can be played in, within each
+// kind of operation, in place of the order they were found in.
+const (
+	OrderSmallestFirst = "smallest-first"
+	OrderLargestFirst  = "largest-first"
+	OrderNewestFirst   = "newest-first"
+)
+
+var transferOrders = []string{OrderSmallestFirst, OrderLargestFirst, OrderNewestFirst}
+
+// ValidateTransferOrder reports whether order is a known transfer order.
+func ValidateTransferOrder(order string) error {
+	if order == "" {
+		return nil
+	}
+	for _, known := range transferOrders {
+		if order == known {
+			return nil
+		}
+	}
+	return invalidArgumentsErr(fmt.Errorf("unknown order %q, expecting one of %s", order, strings.Join(transferOrders, ", ")))
+}
+
+// byTransferOrder orders changes by the precedence of their operations
+// and then, amongst changes of the same operation, folders before files
+// and files in the requested transfer order.
+type byTransferOrder struct {
+	cl    []*Change
+	order string
+}
+
+func (bo byTransferOrder) Less(i, j int) bool {
+	ci, cj := bo.cl[i], bo.cl[j]
+	if ci == nil {
+		return false
+	}
+	if cj == nil {
+		return true
+	}
+
+	pi, pj := opPrecedence[ci.Op()], opPrecedence[cj.Op()]
+	if pi != pj {
+		return pi < pj
+	}
+
+	fi, fj := ci.Src, cj.Src
+	if fi == nil || fj == nil {
+		return false
+	}
+	if fi.IsDir != fj.IsDir {
+		return fi.IsDir
+	}
+
+	switch bo.order {
+	case OrderSmallestFirst:
+		return fi.Size < fj.Size
+	case OrderLargestFirst:
+		return fi.Size > fj.Size
+	case OrderNewestFirst:
+		return fi.ModTime.After(fj.ModTime)
+	}
+	return false
+}
+
+func (bo byTransferOrder) Len() int {
+	return len(bo.cl)
+}
+
+func (bo byTransferOrder) Swap(i, j int) {
+	bo.cl[i], bo.cl[j] = bo.cl[j], bo.cl[i]
+}
+
+// sortForTransfer orders cl by precedence and then by order, if set.
+func sortForTransfer(cl []*Change, order string) {
+	if order == "" {
+		sort.Sort(ByPrecedence(cl))
+		return
+	}
+	sort.Stable(byTransferOrder{cl: cl, order: order})
+}
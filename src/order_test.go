@@ -0,0 +1,80 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSortForTransfer(t *testing.T) {
+	now := time.Now()
+	file := func(name string, size int64, age time.Duration) *File {
+		return &File{Name: name, Size: size, ModTime: now.Add(-age)}
+	}
+
+	changes := func() []*Change {
+		return []*Change{
+			{Path: "/video.mp4", Src: file("video.mp4", 1<<30, 48*time.Hour)},
+			{Path: "/old.txt", Dest: file("old.txt", 10, time.Hour)},
+			{Path: "/notes.txt", Src: file("notes.txt", 512, time.Hour)},
+			{Path: "/docs", Src: &File{Name: "docs", IsDir: true}},
+			{Path: "/song.mp3", Src: file("song.mp3", 4<<20, time.Minute)},
+		}
+	}
+
+	tests := []struct {
+		order string
+		want  []string
+	}{
+		{
+			order: OrderSmallestFirst,
+			want:  []string{"/old.txt", "/docs", "/notes.txt", "/song.mp3", "/video.mp4"},
+		},
+		{
+			order: OrderLargestFirst,
+			want:  []string{"/old.txt", "/docs", "/video.mp4", "/song.mp3", "/notes.txt"},
+		},
+		{
+			order: OrderNewestFirst,
+			want:  []string{"/old.txt", "/docs", "/song.mp3", "/notes.txt", "/video.mp4"},
+		},
+	}
+
+	for _, tt := range tests {
+		cl := changes()
+		sortForTransfer(cl, tt.order)
+
+		var got []string
+		for _, c := range cl {
+			got = append(got, c.Path)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v want %v", tt.order, got, tt.want)
+		}
+	}
+}
+
+func TestValidateTransferOrder(t *testing.T) {
+	for _, order := range []string{"", OrderSmallestFirst, OrderLargestFirst, OrderNewestFirst} {
+		if err := ValidateTransferOrder(order); err != nil {
+			t.Errorf("%q: unexpected error %v", order, err)
+		}
+	}
+	if err := ValidateTransferOrder("oldest-first"); err == nil {
+		t.Errorf("expected an error for an unknown order")
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+)
+
+const googleAppsMimePrefix = "application/vnd.google-apps."
+
+// placeholderExtensions are the extensions of the placeholder files
+// of Google Docs by kind, as written by Google's own sync clients.
+var placeholderExtensions = map[string]string{
+	"document":     "gdoc",
+	"drawing":      "gdraw",
+	"form":         "gform",
+	"fusiontable":  "gtable",
+	"jam":          "gjam",
+	"map":          "gmap",
+	"presentation": "gslides",
+	"site":         "gsite",
+	"spreadsheet":  "gsheet",
+}
+
+// placeholder is the content of a placeholder file, that stands in
+// for a Google Doc that has no local content unless exported.
+type placeholder struct {
+	URL        string `json:"url"`
+	DocId      string `json:"doc_id"`
+	ResourceId string `json:"resource_id"`
+	Email      string `json:"email,omitempty"`
+}
+
+// placeholderFor returns the extension and content of the
+// placeholder file of f, with ok unset if f isn't a Google Doc.
+func placeholderFor(f *File) (ext string, ph *placeholder, ok bool) {
+	if f == nil || f.Id == "" || !strings.HasPrefix(f.MimeType, googleAppsMimePrefix) {
+		return "", nil, false
+	}
+	kind := strings.TrimPrefix(f.MimeType, googleAppsMimePrefix)
+	if ext, ok = placeholderExtensions[kind]; !ok {
+		return "", nil, false
+	}
+
+	ph = &placeholder{
+		URL:        f.Url(),
+		DocId:      f.Id,
+		ResourceId: kind + ":" + f.Id,
+	}
+	return ext, ph, true
+}
+
+// writePlaceholder writes the placeholder file of the Google Doc f next
+// to destAbsPath, returning its path or "" if f isn't a Google Doc.
+func writePlaceholder(f *File, destAbsPath string) (string, error) {
+	ext, ph, ok := placeholderFor(f)
+	if !ok {
+		return "", nil
+	}
+
+	data, err := json.Marshal(ph)
+	if err != nil {
+		return "", err
+	}
+
+	placeholderPath := sepJoin(".", destAbsPath, ext)
+	if err := ioutil.WriteFile(placeholderPath, data, 0644); err != nil {
+		return "", err
+	}
+	if !f.ModTime.IsZero() {
+		if err := os.Chtimes(placeholderPath, f.ModTime, f.ModTime); err != nil {
+			return placeholderPath, err
+		}
+	}
+	return placeholderPath, nil
+}
+
+// removePlaceholders removes the placeholder files next to
+// localAbsPath, once the Google Doc it stood for is gone.
+func removePlaceholders(localAbsPath string) error {
+	for _, ext := range placeholderExtensions {
+		err := os.Remove(sepJoin(".", localAbsPath, ext))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// placeholderIgnore matches placeholder files, which have nothing to push.
+func placeholderIgnore() string {
+	var exts []string
+	for _, ext := range placeholderExtensions {
+		exts = append(exts, ext)
+	}
+	sort.Strings(exts)
+	return "\\.(" + strings.Join(exts, "|") + ")$"
+}
@@ -0,0 +1,147 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestPlaceholderFor(t *testing.T) {
+	cases := []struct {
+		file    *File
+		wantExt string
+		wantRId string
+		wantOk  bool
+	}{
+		{file: nil},
+		{file: &File{MimeType: "application/vnd.google-apps.document"}},
+		{file: &File{Id: "abc", MimeType: "text/plain"}},
+		{file: &File{Id: "abc", MimeType: "application/vnd.google-apps.folder"}},
+		{
+			file:    &File{Id: "abc", MimeType: "application/vnd.google-apps.document"},
+			wantExt: "gdoc", wantRId: "document:abc", wantOk: true,
+		},
+		{
+			file:    &File{Id: "xyz", MimeType: "application/vnd.google-apps.spreadsheet"},
+			wantExt: "gsheet", wantRId: "spreadsheet:xyz", wantOk: true,
+		},
+	}
+
+	for i, tc := range cases {
+		ext, ph, ok := placeholderFor(tc.file)
+		if ok != tc.wantOk {
+			t.Errorf("#%d: ok: got %v want %v", i, ok, tc.wantOk)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if ext != tc.wantExt {
+			t.Errorf("#%d: ext: got %q want %q", i, ext, tc.wantExt)
+		}
+		if ph.ResourceId != tc.wantRId {
+			t.Errorf("#%d: resource_id: got %q want %q", i, ph.ResourceId, tc.wantRId)
+		}
+		if ph.DocId != tc.file.Id || ph.URL != tc.file.Url() {
+			t.Errorf("#%d: got %+v for %+v", i, ph, tc.file)
+		}
+	}
+}
+
+func TestWriteAndRemovePlaceholders(t *testing.T) {
+	dir, err := ioutil.TempDir("", "placeholder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	mtime := time.Date(2016, 3, 1, 10, 0, 0, 0, time.UTC)
+	f := &File{
+		Id:       "abc",
+		MimeType: "application/vnd.google-apps.document",
+		ModTime:  mtime,
+	}
+	dest := filepath.Join(dir, "notes")
+
+	phPath, err := writePlaceholder(f, dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := dest + ".gdoc"; phPath != want {
+		t.Fatalf("path: got %q want %q", phPath, want)
+	}
+
+	data, err := ioutil.ReadFile(phPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ph placeholder
+	if err := json.Unmarshal(data, &ph); err != nil {
+		t.Fatalf("%s: %v", data, err)
+	}
+	if ph.DocId != "abc" || ph.ResourceId != "document:abc" || ph.URL == "" {
+		t.Errorf("unexpected content %s", data)
+	}
+
+	fi, err := os.Stat(phPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fi.ModTime().Equal(mtime) {
+		t.Errorf("mtime: got %v want %v", fi.ModTime(), mtime)
+	}
+
+	if err := removePlaceholders(dest); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(phPath); !os.IsNotExist(err) {
+		t.Errorf("%s: expected it to be removed, got %v", phPath, err)
+	}
+	if err := removePlaceholders(dest); err != nil {
+		t.Errorf("removing absent placeholders: %v", err)
+	}
+
+	phPath, err = writePlaceholder(&File{Id: "abc", MimeType: "text/plain"}, dest)
+	if err != nil || phPath != "" {
+		t.Errorf("non Google Doc: got (%q, %v)", phPath, err)
+	}
+}
+
+func TestPlaceholderIgnore(t *testing.T) {
+	re := regexp.MustCompile(placeholderIgnore())
+	cases := []struct {
+		path string
+		want bool
+	}{
+		{path: "a.gdoc", want: true},
+		{path: "dir/budget.gsheet", want: true},
+		{path: "slides.gslides", want: true},
+		{path: "a.doc"},
+		{path: "a.gdoc.txt"},
+		{path: "gdoc"},
+	}
+
+	for _, tc := range cases {
+		if got := re.MatchString(tc.path); got != tc.want {
+			t.Errorf("%q: got %v want %v", tc.path, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"time"
+)
+
+// provenanceOf returns the created time and description that a push of
+// change should keep on the remote file, so that a new revision doesn't
+// reset them. Those of the remote file as resolved are preferred, else
+// those recorded in its index on the last pull.
+func (g *Commands) provenanceOf(change *Change) (createdTime time.Time, description string) {
+	dest := change.Dest
+	if dest == nil || dest.Id == "" || dest.IsDir || dest.Split {
+		return
+	}
+
+	createdTime, description = dest.CreatedTime, dest.Description
+	if !createdTime.IsZero() && description != "" {
+		return
+	}
+
+	index := g.deserializeIndex(dest.Id)
+	if index == nil {
+		return
+	}
+	if createdTime.IsZero() && index.CreatedTime != 0 {
+		createdTime = time.Unix(index.CreatedTime, 0).UTC()
+	}
+	// An empty description is only taken to be missing if the remote
+	// file is unchanged since, and wasn't deliberately cleared.
+	if description == "" && index.Etag == dest.Etag {
+		description = index.Description
+	}
+	return
+}
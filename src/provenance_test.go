@@ -0,0 +1,145 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/odeke-em/drive/config"
+)
+
+func TestToIndexProvenance(t *testing.T) {
+	created := time.Date(2015, 6, 1, 8, 0, 0, 0, time.UTC)
+
+	index := (&File{Id: "a", CreatedTime: created, Description: "minutes"}).ToIndex()
+	if index.CreatedTime != created.Unix() || index.Description != "minutes" {
+		t.Errorf("got %+v", index)
+	}
+
+	index = (&File{Id: "a", Description: "drive-split: 10 abc", Split: true}).ToIndex()
+	if index.CreatedTime != 0 || index.Description != "" {
+		t.Errorf("split manifest: got %+v", index)
+	}
+}
+
+func TestProvenanceOf(t *testing.T) {
+	dir, err := ioutil.TempDir("", "provenance")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	g := &Commands{context: &config.Context{AbsPath: dir, GDDir: dir}}
+
+	created := time.Date(2015, 6, 1, 8, 0, 0, 0, time.UTC)
+	later := created.Add(time.Hour)
+	indices := []*config.Index{
+		{FileId: "pulled", Etag: "e1", CreatedTime: created.Unix(), Description: "minutes"},
+		{FileId: "changed", Etag: "e1", CreatedTime: created.Unix(), Description: "minutes"},
+	}
+	for _, index := range indices {
+		if err := g.context.SerializeIndex(index); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		dest            *File
+		wantCreated     time.Time
+		wantDescription string
+	}{
+		{dest: nil},
+		{dest: &File{Id: "pulled", IsDir: true, Etag: "e1"}},
+		{dest: &File{Id: "unindexed", Etag: "e1"}},
+		{
+			// Those of the remote file win over the index.
+			dest:        &File{Id: "pulled", Etag: "e1", CreatedTime: later, Description: "notes"},
+			wantCreated: later, wantDescription: "notes",
+		},
+		{
+			dest:        &File{Id: "pulled", Etag: "e1"},
+			wantCreated: created, wantDescription: "minutes",
+		},
+		{
+			// The description was cleared remotely since the pull.
+			dest:        &File{Id: "changed", Etag: "e2"},
+			wantCreated: created,
+		},
+	}
+
+	for i, tc := range cases {
+		gotCreated, gotDescription := g.provenanceOf(&Change{Dest: tc.dest})
+		if !gotCreated.Equal(tc.wantCreated) {
+			t.Errorf("#%d: createdTime: got %v want %v", i, gotCreated, tc.wantCreated)
+		}
+		if gotDescription != tc.wantDescription {
+			t.Errorf("#%d: description: got %q want %q", i, gotDescription, tc.wantDescription)
+		}
+	}
+}
+
+func TestUpsertCreatedDateOnlyOnInsert(t *testing.T) {
+	created := time.Date(2015, 6, 1, 8, 0, 0, 0, time.UTC)
+	sent := make(map[string]map[string]interface{})
+	transport := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		var body map[string]interface{}
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			t.Fatalf("%s %s: %v", req.Method, req.URL.Path, err)
+		}
+		sent[req.Method] = body
+		data, err := json.Marshal(map[string]interface{}{"id": "folder", "title": "minutes", "mimeType": DriveFolderMimeType})
+		if err != nil {
+			return nil, err
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       ioutil.NopCloser(bytes.NewReader(data)),
+			Request:    req,
+		}, nil
+	})
+	rem, err := remoteFromClient(&http.Client{Transport: transport})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{"", "folder"} {
+		src := &File{Id: id, Name: "minutes", IsDir: true, ModTime: created}
+		args := &upsertOpt{src: src, parentId: "root", createdTime: created, description: "notes"}
+		if _, _, err := rem.upsertContent(nil, args); err != nil {
+			t.Fatalf("id %q: %v", id, err)
+		}
+	}
+
+	if got := sent["POST"]["createdDate"]; got != toUTCString(created) {
+		t.Errorf("insert: createdDate: got %v want %v", got, toUTCString(created))
+	}
+	update, ok := sent["PUT"]
+	if !ok {
+		t.Fatalf("no update was sent, got %v", sent)
+	}
+	if got, ok := update["createdDate"]; ok {
+		t.Errorf("update: createdDate should not be sent, got %v", got)
+	}
+	if got := update["description"]; got != "notes" {
+		t.Errorf("update: description: got %v want notes", got)
+	}
+}
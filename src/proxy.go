@@ -0,0 +1,52 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// proxySchemes are the schemes that a proxy URL may have, socks5h
+// resolving host names through the proxy rather than locally.
+var proxySchemes = map[string]bool{
+	"http":    true,
+	"https":   true,
+	"socks5":  true,
+	"socks5h": true,
+}
+
+// ParseProxyURL parses the URL of a proxy e.g `http://proxy:3128` or
+// `socks5://127.0.0.1:1080`, with a bare `host:port` taken to be that
+// of an HTTP proxy.
+func ParseProxyURL(raw string) (*url.URL, error) {
+	raw = strings.TrimSpace(raw)
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, invalidArgumentsErr(fmt.Errorf("proxy: %v", err))
+	}
+	if !proxySchemes[strings.ToLower(u.Scheme)] {
+		return nil, invalidArgumentsErr(fmt.Errorf("proxy: unsupported scheme %q, expected one of http, https, socks5 or socks5h", u.Scheme))
+	}
+	if u.Host == "" {
+		return nil, invalidArgumentsErr(fmt.Errorf("proxy: %q has no host", raw))
+	}
+	return u, nil
+}
@@ -22,7 +22,6 @@ import (
 	"path"
 	"path/filepath"
 	"runtime"
-	"sort"
 	"time"
 
 	"github.com/odeke-em/drive/config"
@@ -56,6 +55,8 @@ type downloadArg struct {
 	path            string
 	exportURL       string
 	ackByteProgress bool
+	// md5Checksum when set is looked up in the object cache, see UseObjectCache.
+	md5Checksum string
 }
 
 type renameOp struct {
@@ -90,10 +91,18 @@ func (g *Commands) PullMatchLike() error {
 	return pull(g, pt)
 }
 
-func pull(g *Commands, pt pullType) error {
+func pull(g *Commands, pt pullType) (err error) {
 	g.rem.encrypter = g.opts.Encrypter
 	g.rem.decrypter = g.opts.Decrypter
 
+	defer func() {
+		err = g.strictCheck(err)
+	}()
+
+	if err := g.guardSyncOverlap(); err != nil {
+		return err
+	}
+
 	cl, clashes, err := pullLikeResolve(g, pt)
 
 	if len(clashes) >= 1 {
@@ -122,6 +131,10 @@ func pull(g *Commands, pt pullType) error {
 
 	nonConflicts := *nonConflictsPtr
 
+	if err := g.guardLargePull(nonConflicts); err != nil {
+		return err
+	}
+
 	clArg := &changeListArg{
 		logy:       g.log,
 		changes:    nonConflicts,
@@ -135,7 +148,12 @@ func pull(g *Commands, pt pullType) error {
 		return status.Error()
 	}
 
-	return g.playPullChanges(nonConflicts, g.opts.Exports, opMap)
+	if err := g.playPullChanges(nonConflicts, g.opts.Exports, opMap); err != nil {
+		return err
+	}
+
+	g.markSynced()
+	return nil
 }
 
 func typeById(pt pullType) bool {
@@ -164,6 +182,9 @@ func pullLikeResolve(g *Commands, pt pullType) (cl, clashes []*Change, err error
 		resolver = g.pullById
 	} else if typeByAllStarred(pt) {
 		resolver = g.pullAllStarred
+		if g.opts.VirtualStarred {
+			resolver = g.pullStarredIntoFolder
+		}
 	} else if typeByMatchLike(pt) {
 		resolver = func() (cl, cll []*Change, err error) {
 			return g.pullLikeMatchesResolver(pt)
@@ -400,7 +421,7 @@ func (g *Commands) pullByPath() (cl, clashes []*Change, err error) {
 			cl = append(cl, ccl...)
 		}
 		if cErr != nil && cErr != ErrClashesDetected {
-			err = combineErrors(err, cErr)
+			err = combineErrors(err, pathErr("pull", OpNone, relToRootPath, "", cErr))
 		}
 	}
 
@@ -448,6 +469,7 @@ func (g *Commands) playPullChanges(cl []*Change, exports []string, opMap *map[Op
 	}
 
 	g.taskStart(totalSize)
+	defer g.startHeartbeat(totalSize)()
 
 	defer close(g.rem.progressChan)
 
@@ -458,9 +480,10 @@ func (g *Commands) playPullChanges(cl []*Change, exports []string, opMap *map[Op
 	}()
 
 	// TODO: Only provide precedence ordering if all the other options are allowed
-	sort.Sort(ByPrecedence(cl))
+	sortForTransfer(cl, g.opts.TransferOrder)
 
-	n := maxProcs()
+	n := g.concurrency()
+	failures := &changeFailures{}
 	jobsChan := make(chan semalim.Job)
 
 	go func() {
@@ -473,6 +496,10 @@ func (g *Commands) playPullChanges(cl []*Change, exports []string, opMap *map[Op
 				continue
 			}
 
+			if g.rem.budget.exhausted() {
+				break
+			}
+
 			fn := localOpToChangerTranslator(g, c)
 			conformingFn := func(c *Change) error {
 				return fn(c, exports)
@@ -485,7 +512,7 @@ func (g *Commands) playPullChanges(cl []*Change, exports []string, opMap *map[Op
 
 			cjs := changeJobSt{
 				change:   c,
-				fn:       conformingFn,
+				fn:       failures.recording(g.summarizing(g.itemizing(false, conformingFn))),
 				verb:     "Pull",
 				throttle: throttle,
 			}
@@ -495,17 +522,16 @@ func (g *Commands) playPullChanges(cl []*Change, exports []string, opMap *map[Op
 		}
 	}()
 
+	// Failures are recorded to be retried, so the results are only drained.
 	results := semalim.Run(jobsChan, uint64(n))
-	for result := range results {
-		res, rErr := result.Value(), result.Err()
-		if rErr != nil {
-			msg := fmt.Sprintf("%v err: %v\n", res, rErr)
-			err = reComposeError(err, msg)
-		}
+	for range results {
 	}
 
+	err = g.retryFailures("pull", failures)
+	g.reportStalls("pull")
+
 	g.taskFinish()
-	return err
+	return g.budgetCheck(err)
 }
 
 func (g *Commands) localAddIndex(change *Change, conform []string) (err error) {
@@ -627,6 +653,8 @@ func (g *Commands) localDelete(change *Change, conform []string) (err error) {
 	err = os.RemoveAll(change.Dest.BlobAt)
 	if err != nil {
 		g.log.LogErrf("localDelete: \"%s\" %v\n", change.Dest.BlobAt, err)
+	} else if phErr := removePlaceholders(change.Dest.BlobAt); phErr != nil {
+		g.log.LogErrf("localDelete: placeholder of \"%s\" %v\n", change.Dest.BlobAt, phErr)
 	}
 
 	return
@@ -749,11 +777,16 @@ func (g *Commands) download(change *Change, exports []string) error {
 	}
 
 	destAbsPath := g.context.AbsPathOf(change.Path)
+	if change.Src.Split {
+		return g.splitDownload(change.Src, destAbsPath)
+	}
+
 	if change.Src.BlobAt != "" {
 		dlArg := downloadArg{
 			path:            destAbsPath,
 			id:              change.Src.Id,
 			ackByteProgress: true,
+			md5Checksum:     change.Src.Md5Checksum,
 		}
 
 		return g.singleDownload(&dlArg)
@@ -785,6 +818,11 @@ func (g *Commands) download(change *Change, exports []string) error {
 
 	canExport := len(exports) >= 1 && hasExportLinks(change.Src)
 	if !canExport {
+		if g.opts.Placeholders {
+			if _, phErr := writePlaceholder(change.Src, destAbsPath); phErr != nil {
+				g.log.LogErrf("placeholder: %s %v\n", destAbsPath, phErr)
+			}
+		}
 		return nil
 	}
 
@@ -832,12 +870,30 @@ func (g *Commands) singleDownload(dlArg *downloadArg) (err error) {
 		}
 	}()
 
-	blob, err = g.rem.Download(dlArg.id, dlArg.exportURL)
-	if err != nil {
-		return err
+	var cacheWriter *objectWriter
+	if g.objectCacheable(dlArg) {
+		if cached := objects.open(dlArg.md5Checksum); cached != nil {
+			blob = cached
+		} else {
+			cacheWriter = objects.writer(dlArg.md5Checksum)
+		}
 	}
 
-	ws := statos.NewWriter(fo)
+	if blob == nil {
+		blob, err = g.rem.Download(dlArg.id, dlArg.exportURL)
+		if err != nil {
+			return err
+		}
+	}
+
+	var dst io.Writer = fo
+	var sw *sparseWriter
+	if g.opts.Sparse {
+		sw = newSparseWriter(fo)
+		dst = sw
+	}
+
+	ws := statos.NewWriter(dst)
 
 	go func() {
 		commChan := ws.ProgressChan()
@@ -852,7 +908,21 @@ func (g *Commands) singleDownload(dlArg *downloadArg) (err error) {
 		}
 	}()
 
-	_, err = io.Copy(ws, blob)
+	var src io.Reader = blob
+	if g.opts.Background {
+		src = newPacedReader(blob)
+	}
+	if cacheWriter != nil {
+		src = io.TeeReader(src, cacheWriter)
+	}
+
+	_, err = io.Copy(ws, src)
+	if err == nil && sw != nil {
+		err = sw.finish()
+	}
+	if cacheWriter != nil {
+		cacheWriter.commit(err == nil)
+	}
 
 	return
 }
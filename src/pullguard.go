@@ -0,0 +1,53 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+)
+
+// largePullTally counts the files that a pull of cl would download
+// and the total number of bytes that they take up.
+func largePullTally(cl []*Change) (files int, bytes int64) {
+	for _, c := range cl {
+		if c == nil || c.Src == nil || c.Src.IsDir {
+			continue
+		}
+		switch c.Op() {
+		case OpAdd, OpMod, OpModConflict:
+			files += 1
+			bytes += c.Src.Size
+		}
+	}
+	return
+}
+
+// guardLargePull stops pulls that would download more than the
+// configured number of files or bytes unless ConfirmLarge is set,
+// for example after accidentally initializing at the Drive root.
+func (g *Commands) guardLargePull(cl []*Change) error {
+	if g.opts.ConfirmLarge {
+		return nil
+	}
+
+	files, bytes := largePullTally(cl)
+	maxFiles, maxBytes := g.opts.LargePullFiles, g.opts.LargePullBytes
+	if (maxFiles <= 0 || files <= maxFiles) && (maxBytes <= 0 || bytes <= maxBytes) {
+		return nil
+	}
+
+	return contentTooLargeErr(fmt.Errorf("pull would download %d files totalling %s, over the -%s %d or -%s %s limits; rerun with -%s to proceed",
+		files, prettyBytes(bytes), CLIOptionLargeFiles, maxFiles, CLIOptionLargeSize, prettyBytes(maxBytes), CLIOptionConfirmLarge))
+}
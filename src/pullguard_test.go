@@ -0,0 +1,63 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"testing"
+)
+
+func TestLargePullTally(t *testing.T) {
+	remote := func(name string, size int64) *File {
+		return &File{Name: name, Size: size}
+	}
+
+	tests := []struct {
+		cl        []*Change
+		wantFiles int
+		wantBytes int64
+	}{
+		{cl: nil},
+		{
+			cl: []*Change{
+				{Path: "/a.txt", Src: remote("a.txt", 10)},
+				{Path: "/b.txt", Src: remote("b.txt", 32)},
+			},
+			wantFiles: 2, wantBytes: 42,
+		},
+		{
+			// Folders and deletions don't download anything.
+			cl: []*Change{
+				nil,
+				{Path: "/photos", Src: &File{Name: "photos", IsDir: true}},
+				{Path: "/gone.txt", Dest: remote("gone.txt", 100)},
+				{Path: "/c.txt", Src: remote("c.txt", 7)},
+			},
+			wantFiles: 1, wantBytes: 7,
+		},
+		{
+			cl: []*Change{
+				{Path: "/d.txt", Src: remote("d.txt", 5), Dest: remote("d.txt", 5), Force: true},
+			},
+			wantFiles: 1, wantBytes: 5,
+		},
+	}
+
+	for i, tt := range tests {
+		files, bytes := largePullTally(tt.cl)
+		if files != tt.wantFiles || bytes != tt.wantBytes {
+			t.Errorf("#%d: got (%d, %d) want (%d, %d)", i, files, bytes, tt.wantFiles, tt.wantBytes)
+		}
+	}
+}
@@ -16,15 +16,16 @@ package drive
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
 	gopath "path"
 	"path/filepath"
-	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	expirableCache "github.com/odeke-em/cache"
 	"github.com/odeke-em/drive/config"
 	"github.com/odeke-em/semalim"
 )
@@ -34,11 +35,32 @@ var mkdirAllMu = sync.Mutex{}
 // Pushes to remote if local path exists and in a gd context. If path is a
 // directory, it recursively pushes to the remote if there are local changes.
 // It doesn't check if there are local changes if isForce is set.
-func (g *Commands) Push() error {
+func (g *Commands) Push() (err error) {
+	if err := g.refuseReadOnly("push"); err != nil {
+		return err
+	}
+
 	g.rem.encrypter = g.opts.Encrypter
 	g.rem.decrypter = g.opts.Decrypter
 
+	if g.opts.Against != "" {
+		return g.pushDryRunAgainst(g.opts.Against)
+	}
+
+	if err := g.guardSyncOverlap(); err != nil {
+		return err
+	}
+
 	defer g.clearMountPoints()
+	defer func() {
+		g.reportLocked()
+		err = g.strictCheck(err)
+	}()
+	defer func() {
+		if err == nil && g.opts.Snapshot != "" && !g.opts.DryRun {
+			err = g.saveLocalSnapshot(g.opts.Snapshot)
+		}
+	}()
 
 	var cl []*Change
 
@@ -74,7 +96,7 @@ func (g *Commands) Push() error {
 		clashes = append(clashes, cclashes...)
 		if cErr != nil && cErr != ErrClashesDetected {
 			spin.stop()
-			return cErr
+			return pathErr("push", OpNone, relToDestPath, "", cErr)
 		}
 		if len(ccl) > 0 {
 			cl = append(cl, ccl...)
@@ -114,7 +136,15 @@ func (g *Commands) Push() error {
 		return unresolvedConflictsErr(fmt.Errorf("conflicts have prevented a push operation"))
 	}
 
-	nonConflicts := *nonConflictsPtr
+	nonConflicts, err := g.skipRemotelyLocked(*nonConflictsPtr)
+	if err != nil {
+		return err
+	}
+
+	if g.opts.DryRun {
+		g.previewDryRun(nonConflicts)
+		return nil
+	}
 
 	pushSize, modSize := reduceToSize(cl, SelectDest|SelectSrc)
 
@@ -161,82 +191,124 @@ func (g *Commands) Push() error {
 		return status.Error()
 	}
 
-	return g.playPushChanges(nonConflicts, opMap)
+	if err := g.playPushChanges(nonConflicts, opMap); err != nil {
+		return err
+	}
+
+	g.markSynced()
+	return nil
 }
 
 func (g *Commands) PushPiped() error {
+	if err := g.refuseReadOnly("push"); err != nil {
+		return err
+	}
+
 	g.rem.encrypter = g.opts.Encrypter
 	g.rem.decrypter = g.opts.Decrypter
 
 	// Cannot push asynchronously because the push order must be maintained
 	for _, relToRootPath := range g.opts.Sources {
-		rem, resErr := g.rem.FindByPath(relToRootPath)
-		if resErr != nil && resErr != ErrPathNotExists {
-			return resErr
-		}
-		if rem != nil && !g.opts.Force {
-			return overwriteAttemptedErr(fmt.Errorf("%s already exists remotely, use `%s` to override this behaviour.\n", relToRootPath, ForceKey))
+		if err := g.pushFromReader(relToRootPath, os.Stdin); err != nil {
+			return err
 		}
+	}
 
-		if hasExportLinks(rem) {
-			return googleDocNonExportErr(fmt.Errorf("'%s' is a GoogleDoc/Sheet document cannot be pushed to raw.\n", relToRootPath))
-		}
+	return nil
+}
 
-		base := filepath.Base(relToRootPath)
-		local := fauxLocalFile(base)
-		if rem == nil {
-			rem = local
-		}
+// PushAs uploads the local file at fsPath to exactly the remote path
+// relToRootPath, which unlike for an ordinary push needn't mirror its
+// local path nor name.
+func (g *Commands) PushAs(fsPath, relToRootPath string) error {
+	if err := g.refuseReadOnly("push"); err != nil {
+		return err
+	}
 
-		parentPath := g.parentPather(relToRootPath)
-		parent, pErr := g.rem.FindByPath(parentPath)
-		if pErr != nil {
-			spin := g.playabler()
-			spin.play()
-			parent, pErr = g.remoteMkdirAll(parentPath)
-			spin.stop()
-			if pErr != nil || parent == nil {
-				g.log.LogErrf("%s: %v\n", relToRootPath, pErr)
-				return pErr
-			}
-		}
+	g.rem.encrypter = g.opts.Encrypter
+	g.rem.decrypter = g.opts.Decrypter
 
-		fauxSrc := DupFile(rem)
-		if fauxSrc != nil {
-			fauxSrc.ModTime = time.Now()
-		}
+	f, err := os.Open(fsPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
 
-		args := &upsertOpt{
-			uploadChunkSize: g.opts.UploadChunkSize,
-			parentId:        parent.Id,
-			fsAbsPath:       relToRootPath,
-			src:             fauxSrc,
-			dest:            rem,
-			mask:            g.opts.TypeMask,
-			nonStatable:     true,
-			ignoreChecksum:  g.opts.IgnoreChecksum,
-			retryCount:      g.opts.ExponentialBackoffRetryCount,
-		}
+	return g.pushFromReader(relToRootPath, f)
+}
 
-		rem, _, rErr := g.rem.upsertByComparison(os.Stdin, args)
-		if rErr != nil {
-			g.log.LogErrf("%s: %v\n", relToRootPath, rErr)
-			return rErr
-		}
+func (g *Commands) pushFromReader(relToRootPath string, r io.Reader) error {
+	return g.upsertFromReader(relToRootPath, r, g.opts.Force)
+}
 
-		if rem == nil {
-			continue
-		}
+// upsertFromReader uploads the content of r to relToRootPath, replacing
+// the file that is already there only if overwrite is set.
+func (g *Commands) upsertFromReader(relToRootPath string, r io.Reader, overwrite bool) error {
+	rem, resErr := g.rem.FindByPath(relToRootPath)
+	if resErr != nil && resErr != ErrPathNotExists {
+		return resErr
+	}
+	if rem != nil && !overwrite {
+		return overwriteAttemptedErr(fmt.Errorf("%s already exists remotely, use `%s` to override this behaviour.\n", relToRootPath, ForceKey))
+	}
 
-		index := rem.ToIndex()
-		wErr := g.context.SerializeIndex(index)
+	if hasExportLinks(rem) {
+		return googleDocNonExportErr(fmt.Errorf("'%s' is a GoogleDoc/Sheet document cannot be pushed to raw.\n", relToRootPath))
+	}
 
-		// TODO: Should indexing errors be reported?
-		if wErr != nil {
-			g.log.LogErrf("serializeIndex %s: %v\n", rem.Name, wErr)
+	base := filepath.Base(relToRootPath)
+	local := fauxLocalFile(base)
+	if rem == nil {
+		rem = local
+	}
+
+	parentPath := g.parentPather(relToRootPath)
+	parent, pErr := g.rem.FindByPath(parentPath)
+	if pErr != nil {
+		spin := g.playabler()
+		spin.play()
+		parent, pErr = g.remoteMkdirAll(parentPath)
+		spin.stop()
+		if pErr != nil || parent == nil {
+			g.log.LogErrf("%s: %v\n", relToRootPath, pErr)
+			return pErr
 		}
 	}
 
+	fauxSrc := DupFile(rem)
+	if fauxSrc != nil {
+		fauxSrc.ModTime = time.Now()
+	}
+
+	args := &upsertOpt{
+		uploadChunkSize: g.opts.UploadChunkSize,
+		parentId:        parent.Id,
+		fsAbsPath:       relToRootPath,
+		src:             fauxSrc,
+		dest:            rem,
+		mask:            g.opts.TypeMask,
+		nonStatable:     true,
+		ignoreChecksum:  g.opts.IgnoreChecksum,
+		retryCount:      g.opts.ExponentialBackoffRetryCount,
+	}
+
+	rem, _, rErr := g.rem.upsertByComparison(r, args)
+	if rErr != nil {
+		g.log.LogErrf("%s: %v\n", relToRootPath, rErr)
+		return rErr
+	}
+
+	if rem == nil {
+		return nil
+	}
+
+	index := rem.ToIndex()
+	wErr := g.context.SerializeIndex(index)
+
+	// TODO: Should indexing errors be reported?
+	if wErr != nil {
+		g.log.LogErrf("serializeIndex %s: %v\n", rem.Name, wErr)
+	}
 	return nil
 }
 
@@ -261,6 +333,13 @@ func (g *Commands) playPushChanges(cl []*Change, opMap *map[Operation]sizeCounte
 	}
 
 	g.taskStart(totalSize)
+	defer g.startHeartbeat(totalSize)()
+	defer g.saveCryptKeyRecords()
+
+	if g.opts.ShadowCopy {
+		g.shadows = newShadowCopies()
+		defer g.shadows.release(g.log)
+	}
 
 	defer close(g.rem.progressChan)
 
@@ -275,9 +354,16 @@ func (g *Commands) playPushChanges(cl []*Change, opMap *map[Operation]sizeCounte
 		arg *Change
 	}
 
-	n := maxProcs()
+	n := g.concurrency()
 
-	sort.Sort(ByPrecedence(cl))
+	sortForTransfer(cl, g.opts.TransferOrder)
+
+	dedup := g.newUploadDedup(cl)
+	if dupCount := len(dedup.duplicates); dupCount > 0 {
+		g.log.Logf("%d files duplicate content being uploaded and will be copied server side\n", dupCount)
+	}
+	deps := dedup.dependencies(changeDependencies(cl))
+	failures := &changeFailures{}
 
 	jobsChan := make(chan semalim.Job)
 
@@ -285,41 +371,52 @@ func (g *Commands) playPushChanges(cl []*Change, opMap *map[Operation]sizeCounte
 		defer close(jobsChan)
 		throttle := time.Tick(time.Duration(1e9 / n))
 
-		for i, c := range cl {
+		// Folders are created before their contents and deletions
+		// wait for the uploads, whatever the concurrency.
+		scheduleDependencies(deps, func(i int, done func()) bool {
+			c := cl[i]
 			if c == nil {
 				g.log.LogErrf("BUGON:: push: nil change found for change index %d\n", i)
-				continue
+				return false
+			}
+
+			if g.rem.budget.exhausted() {
+				return false
 			}
 
 			fn := remoteOpToChangerTranslator(g, c)
 
 			if fn == nil {
 				g.log.LogErrf("push: cannot find operator for %v", c.Op())
-				continue
+				return false
 			}
 
 			cjs := changeJobSt{
 				change:   c,
-				fn:       fn,
+				fn:       failures.recording(g.summarizing(g.itemizing(true, dedup.changer(g, i, fn)))),
 				verb:     "Push",
 				throttle: throttle,
 			}
 
 			dofner := cjs.changeJober(g)
-			jobsChan <- jobSt{id: uint64(i), do: dofner}
-		}
+			jobsChan <- jobSt{id: uint64(i), do: func() (interface{}, error) {
+				defer done()
+				return dofner()
+			}}
+			return true
+		})
 	}()
 
+	// Failures are recorded to be retried, so the results are only drained.
 	results := semalim.Run(jobsChan, uint64(n))
-	for result := range results {
-		res, resErr := result.Value(), result.Err()
-		if resErr != nil {
-			err = reComposeError(err, fmt.Sprintf("push: %s err: %v\n", res, resErr))
-		}
+	for range results {
 	}
 
+	err = g.retryFailures("push", failures)
+	g.reportStalls("push")
+
 	g.taskFinish()
-	return err
+	return g.budgetCheck(err)
 }
 
 func (g *Commands) pathSplitter(absPath string) (dir, base string) {
@@ -336,11 +433,17 @@ func (g *Commands) parentPather(absPath string) string {
 	return dir
 }
 
-func (g *Commands) remoteMod(change *Change) (err error) {
+func (g *Commands) remoteMod(change *Change) error {
+	_, err := g.remoteUpsert(change)
+	return err
+}
+
+// remoteUpsert plays an addition or modification, returning the remote file.
+func (g *Commands) remoteUpsert(change *Change) (rem *File, err error) {
 	if change.Dest == nil && change.Src == nil {
 		err = illogicalStateErr(fmt.Errorf("bug on: both dest and src cannot be nil"))
 		g.log.LogErrln(err)
-		return err
+		return nil, err
 	}
 
 	absPath := g.context.AbsPathOf(change.Path)
@@ -360,11 +463,15 @@ func (g *Commands) remoteMod(change *Change) (err error) {
 
 	var parent *File
 	parentPath := g.parentPather(change.Path)
-	parent, err = g.remoteMkdirAll(parentPath)
+	if change.Src != nil && !change.Src.IsDir {
+		parent, parentPath, err = g.remoteMkdirParent(change.Path)
+	} else {
+		parent, err = g.remoteMkdirAll(parentPath)
+	}
 
 	if err != nil {
 		g.log.LogErrf("remoteMod/remoteMkdirAll: `%s` got %v\n", parentPath, err)
-		return err
+		return nil, err
 	}
 
 	if parent == nil {
@@ -374,17 +481,27 @@ func (g *Commands) remoteMod(change *Change) (err error) {
 	}
 
 	args := &upsertOpt{
-		uploadChunkSize: g.opts.UploadChunkSize,
-		uploadRateLimit: g.opts.UploadRateLimit,
-		parentId:        parent.Id,
-		fsAbsPath:       absPath,
-		src:             change.Src,
-		dest:            change.Dest,
-		mask:            g.opts.TypeMask,
-		ignoreChecksum:  g.opts.IgnoreChecksum,
-		debug:           g.opts.Verbose && g.opts.canPreview(),
-		retryCount:      g.opts.ExponentialBackoffRetryCount,
-	}
+		uploadChunkSize:    g.opts.UploadChunkSize,
+		uploadRateLimit:    g.opts.UploadRateLimit,
+		uploadRateSchedule: g.opts.UploadRateSchedule,
+		background:         g.opts.Background,
+		parentId:           parent.Id,
+		fsAbsPath:          absPath,
+		src:                change.Src,
+		dest:               change.Dest,
+		mask:               g.opts.TypeMask,
+		ignoreChecksum:     g.opts.IgnoreChecksum,
+		debug:              g.opts.Verbose && g.opts.canPreview(),
+		retryCount:         g.opts.ExponentialBackoffRetryCount,
+	}
+	if g.opts.SnapshotToTemp {
+		// Copies in the metadata directory are likely on the same
+		// filesystem as the originals, with room enough for them.
+		args.snapshotDir = g.context.GDPath()
+	}
+	args.lockedRetries = g.opts.LockedRetries
+	args.shadows = g.shadows
+	args.createdTime, args.description = g.provenanceOf(change)
 
 	coercedMimeKey, ok := g.coercedMimeKey()
 	if ok {
@@ -393,7 +510,15 @@ func (g *Commands) remoteMod(change *Change) (err error) {
 		args.mimeKey = filepath.Ext(args.src.Name)
 	}
 
-	rem, err := g.rem.UpsertByComparison(args)
+	if g.shouldSplit(change) {
+		rem, err = g.remoteSplitUpsert(change, parent)
+	} else {
+		rem, err = g.rem.UpsertByComparison(args)
+	}
+	if lockedErr, locked := err.(*lockedFileErr); locked {
+		g.skipLocked(change.Path, lockedErr.reason())
+		return nil, nil
+	}
 	if err != nil {
 		g.log.LogErrf("%s: %v\n", change.Path, err)
 		return
@@ -408,9 +533,25 @@ func (g *Commands) remoteMod(change *Change) (err error) {
 	if wErr != nil {
 		g.log.LogErrf("serializeIndex %s: %v\n", rem.Name, wErr)
 	}
+
+	g.notePushed(change, rem)
+
+	if change.uploaded != nil {
+		change.uploaded(rem)
+	}
 	return
 }
 
+// notePushed records what is known of the content pushed to rem: its
+// checksum or, if it was encrypted, the key version it was encrypted under.
+func (g *Commands) notePushed(change *Change, rem *File) {
+	if g.opts.Encrypter == nil {
+		g.cacheChecksum(change.Src, rem.Md5Checksum)
+	} else if !rem.IsDir {
+		g.recordCryptKey(change.Path)
+	}
+}
+
 func (g *Commands) remoteAdd(change *Change) error {
 	return g.remoteMod(change)
 }
@@ -440,13 +581,18 @@ func remoteRemover(g *Commands, change *Change, fn func(string) error) error {
 		g.taskAdd(change.Dest.Size)
 	}()
 
+	if change.Dest.Split {
+		g.removeSplitParts(change.Dest, fn)
+	}
+
 	if err := fn(change.Dest.Id); err != nil {
 		return err
 	}
 
+	// The folders created under a removed one are gone with it.
 	if change.Dest.IsDir {
 		mkdirAllMu.Lock()
-		g.mkdirAllCache.Remove(change.Path)
+		g.mkdirAllCache = expirableCache.New()
 		mkdirAllMu.Unlock()
 	}
 
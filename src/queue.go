@@ -0,0 +1,215 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/odeke-em/drive/config"
+)
+
+const (
+	QueueJSON = "queue.json"
+)
+
+// queuedPush records a push made with `push -queue`, with just
+// the options needed to replay it once `flush` is run online.
+type queuedPush struct {
+	Path        string              `json:"path"`
+	Sources     []string            `json:"sources"`
+	Destination string              `json:"destination,omitempty"`
+	Meta        map[string][]string `json:"meta,omitempty"`
+	Depth       int                 `json:"depth"`
+	TypeMask    int                 `json:"type_mask,omitempty"`
+
+	Force             bool `json:"force,omitempty"`
+	Hidden            bool `json:"hidden,omitempty"`
+	NoClobber         bool `json:"no_clobber,omitempty"`
+	Recursive         bool `json:"recursive,omitempty"`
+	FixClashes        bool `json:"fix_clashes,omitempty"`
+	IgnoreChecksum    bool `json:"ignore_checksum,omitempty"`
+	IgnoreConflict    bool `json:"ignore_conflict,omitempty"`
+	IgnoreNameClashes bool `json:"ignore_name_clashes,omitempty"`
+
+	ExcludeCrudMask CrudValue      `json:"exclude_crud_mask,omitempty"`
+	FixClashesMode  FixClashesMode `json:"fix_clashes_mode,omitempty"`
+
+	QueuedAt time.Time `json:"queued_at"`
+}
+
+func queuedPushFromOptions(opts *Options) *queuedPush {
+	qp := &queuedPush{
+		Path:              opts.Path,
+		Sources:           opts.Sources,
+		Destination:       opts.Destination,
+		Depth:             opts.Depth,
+		TypeMask:          opts.TypeMask,
+		Force:             opts.Force,
+		Hidden:            opts.Hidden,
+		NoClobber:         opts.NoClobber,
+		Recursive:         opts.Recursive,
+		FixClashes:        opts.FixClashes,
+		IgnoreChecksum:    opts.IgnoreChecksum,
+		IgnoreConflict:    opts.IgnoreConflict,
+		IgnoreNameClashes: opts.IgnoreNameClashes,
+		ExcludeCrudMask:   opts.ExcludeCrudMask,
+		FixClashesMode:    opts.FixClashesMode,
+		QueuedAt:          time.Now(),
+	}
+	if opts.Meta != nil {
+		qp.Meta = *opts.Meta
+	}
+	return qp
+}
+
+// options overlays the queued push onto base, which carries the
+// settings of the flush itself such as prompting and verbosity.
+func (qp *queuedPush) options(base *Options) *Options {
+	opts := *base
+	opts.Path = qp.Path
+	opts.Sources = qp.Sources
+	opts.Destination = qp.Destination
+	opts.Depth = qp.Depth
+	opts.TypeMask = qp.TypeMask
+	opts.Force = qp.Force
+	opts.Hidden = qp.Hidden
+	opts.NoClobber = qp.NoClobber
+	opts.Recursive = qp.Recursive
+	opts.FixClashes = qp.FixClashes
+	opts.IgnoreChecksum = qp.IgnoreChecksum
+	opts.IgnoreConflict = qp.IgnoreConflict
+	opts.IgnoreNameClashes = qp.IgnoreNameClashes
+	opts.ExcludeCrudMask = qp.ExcludeCrudMask
+	opts.FixClashesMode = qp.FixClashesMode
+	// New recomputes the ignores unless forced.
+	opts.Ignorer = nil
+
+	meta := qp.Meta
+	if meta == nil {
+		meta = map[string][]string{}
+	}
+	opts.Meta = &meta
+	return &opts
+}
+
+func (qp *queuedPush) String() string {
+	return fmt.Sprintf("push %s (queued %s)", strings.Join(qp.Sources, " "), qp.QueuedAt.Format(time.RFC3339))
+}
+
+func queuePath(context *config.Context) string {
+	return filepath.Join(context.GDPath(), QueueJSON)
+}
+
+// readQueue returns the queued pushes in the order they were queued.
+// A missing queue is empty.
+func readQueue(p string) ([]*queuedPush, error) {
+	data, err := ioutil.ReadFile(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var queue []*queuedPush
+	if err := json.Unmarshal(data, &queue); err != nil {
+		return nil, err
+	}
+	return queue, nil
+}
+
+func writeQueue(p string, queue []*queuedPush) error {
+	if len(queue) < 1 {
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	data, err := json.MarshalIndent(queue, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write then rename so that an interrupted flush never truncates the queue.
+	tmpPath := p + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, p)
+}
+
+// QueuePush records the push described by the options instead of playing
+// it, e.g while offline, so that a later Flush plays it.
+func (g *Commands) QueuePush() error {
+	p := queuePath(g.context)
+	queue, err := readQueue(p)
+	if err != nil {
+		return err
+	}
+
+	qp := queuedPushFromOptions(g.opts)
+	if err := writeQueue(p, append(queue, qp)); err != nil {
+		return err
+	}
+
+	g.log.Logf("Queued %v\nRun `drive %s` once online to play it\n", qp, FlushKey)
+	return nil
+}
+
+// Flush plays the queued pushes in order. Each is dequeued once it
+// succeeds, so after a failure the rest remain for the next flush.
+func (g *Commands) Flush(list bool) error {
+	p := queuePath(g.context)
+	queue, err := readQueue(p)
+	if err != nil {
+		return err
+	}
+
+	if len(queue) < 1 {
+		g.log.Logln("Nothing is queued")
+		return nil
+	}
+
+	if list {
+		for _, qp := range queue {
+			g.log.Logln(qp)
+		}
+		return nil
+	}
+
+	for len(queue) > 0 {
+		qp := queue[0]
+		g.log.Logf("Flushing %v\n", qp)
+
+		if err := New(g.context, qp.options(g.opts)).Push(); err != nil {
+			g.log.LogErrf("flush: %d pushes remain queued\n", len(queue))
+			return err
+		}
+
+		queue = queue[1:]
+		if err := writeQueue(p, queue); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,73 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestQueueRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "drive-queue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	p := filepath.Join(dir, QueueJSON)
+	if queue, err := readQueue(p); err != nil || len(queue) != 0 {
+		t.Fatalf("a missing queue should be empty, got %v err %v", queue, err)
+	}
+
+	meta := map[string][]string{SkipMimeKeyKey: {"video"}}
+	queued := []*queuedPush{
+		queuedPushFromOptions(&Options{Path: "/notes", Sources: []string{"/notes"}, Recursive: true, Meta: &meta}),
+		queuedPushFromOptions(&Options{Path: "/", Sources: []string{"/a", "/b"}, Hidden: true, Depth: 2}),
+	}
+	if err := writeQueue(p, queued); err != nil {
+		t.Fatal(err)
+	}
+
+	queue, err := readQueue(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queue) != len(queued) {
+		t.Fatalf("got %d queued pushes want %d", len(queue), len(queued))
+	}
+
+	base := &Options{NoPrompt: true, Verbose: true}
+	for i, qp := range queue {
+		got, want := qp.options(base), queued[i].options(base)
+		if got.Path != want.Path || !reflect.DeepEqual(got.Sources, want.Sources) ||
+			got.Recursive != want.Recursive || got.Hidden != want.Hidden || got.Depth != want.Depth ||
+			!reflect.DeepEqual(*got.Meta, *want.Meta) {
+			t.Errorf("#%d: got %+v want %+v", i, got, want)
+		}
+		if !got.NoPrompt || !got.Verbose {
+			t.Errorf("#%d: the flush's own options should be kept", i)
+		}
+	}
+
+	if err := writeQueue(p, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(p); !os.IsNotExist(err) {
+		t.Errorf("an emptied queue should be removed, stat err %v", err)
+	}
+}
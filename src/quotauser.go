@@ -0,0 +1,156 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/odeke-em/drive/config"
+)
+
+// quotaUserFor returns the quotaUser that the Drive API attributes a
+// context's requests to, so that per-user rate limits are applied to
+// each context separately rather than to the shared API key.
+func quotaUserFor(context *config.Context) string {
+	if quotaUser := os.Getenv(QuotaUserEnvKey); quotaUser != "" {
+		return quotaUser
+	}
+	sum := sha1.Sum([]byte(context.AbsPath))
+	return hex.EncodeToString(sum[:8])
+}
+
+type quotaUserTransport struct {
+	base      http.RoundTripper
+	quotaUser string
+}
+
+func (qt *quotaUserTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// RoundTrippers mustn't modify the request, so tag a copy of its URL.
+	tagged := *req
+	u := *req.URL
+	query := u.Query()
+	query.Set("quotaUser", qt.quotaUser)
+	u.RawQuery = query.Encode()
+	tagged.URL = &u
+	return qt.base.RoundTrip(&tagged)
+}
+
+// setQuotaUser tags all of the remote's requests with quotaUser.
+func (r *Remote) setQuotaUser(quotaUser string) {
+	base := r.client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	r.client.Transport = &quotaUserTransport{base: base, quotaUser: quotaUser}
+}
+
+// tokenBucket paces requests to rate per second, allowing bursts of up
+// to burst requests. Requests wait their turn in the order they came in.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+
+	now   func() time.Time
+	sleep func(time.Duration)
+}
+
+func newTokenBucket(rate int) *tokenBucket {
+	tb := &tokenBucket{now: time.Now, sleep: time.Sleep}
+	tb.setRate(rate)
+	tb.tokens = tb.burst
+	return tb
+}
+
+func (tb *tokenBucket) setRate(rate int) {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	tb.rate = float64(rate)
+	tb.burst = float64(rate)
+	if tb.tokens > tb.burst {
+		tb.tokens = tb.burst
+	}
+}
+
+// wait blocks until a request may be made.
+func (tb *tokenBucket) wait() {
+	tb.mu.Lock()
+	now := tb.now()
+	if !tb.last.IsZero() {
+		tb.tokens += now.Sub(tb.last).Seconds() * tb.rate
+		if tb.tokens > tb.burst {
+			tb.tokens = tb.burst
+		}
+	}
+	tb.last = now
+	// Taking the token ahead of time reserves the request's turn.
+	tb.tokens--
+	deficit := -tb.tokens
+	rate := tb.rate
+	tb.mu.Unlock()
+
+	if deficit > 0 {
+		tb.sleep(time.Duration(deficit / rate * float64(time.Second)))
+	}
+}
+
+// contextBuckets are the token buckets of the contexts served by this
+// process, so that every remote of a context draws from the same bucket
+// while the other contexts are paced independently of it.
+var contextBuckets = struct {
+	sync.Mutex
+	byContext map[string]*tokenBucket
+}{byContext: make(map[string]*tokenBucket)}
+
+// tokenBucketFor returns the token bucket of the context at absPath, paced to rate.
+func tokenBucketFor(absPath string, rate int) *tokenBucket {
+	contextBuckets.Lock()
+	defer contextBuckets.Unlock()
+
+	tb, ok := contextBuckets.byContext[absPath]
+	if !ok {
+		tb = newTokenBucket(rate)
+		contextBuckets.byContext[absPath] = tb
+	} else {
+		tb.setRate(rate)
+	}
+	return tb
+}
+
+type rateTransport struct {
+	base   http.RoundTripper
+	bucket *tokenBucket
+}
+
+func (rt *rateTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rt.bucket.wait()
+	return rt.base.RoundTrip(req)
+}
+
+// setRateLimit paces the remote's requests by bucket.
+func (r *Remote) setRateLimit(bucket *tokenBucket) {
+	base := r.client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	r.client.Transport = &rateTransport{base: base, bucket: bucket}
+}
@@ -0,0 +1,116 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/odeke-em/drive/config"
+)
+
+type recordingTransport struct {
+	req *http.Request
+}
+
+func (rt *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rt.req = req
+	return &http.Response{StatusCode: http.StatusOK, Request: req}, nil
+}
+
+func TestQuotaUserTransport(t *testing.T) {
+	rt := &recordingTransport{}
+	qt := &quotaUserTransport{base: rt, quotaUser: "ctx1"}
+
+	req, err := http.NewRequest("GET", "https://www.googleapis.com/drive/v2/files?maxResults=10", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := qt.RoundTrip(req); err != nil {
+		t.Fatal(err)
+	}
+
+	query := rt.req.URL.Query()
+	if got := query.Get("quotaUser"); got != "ctx1" {
+		t.Errorf("quotaUser: got %q want %q", got, "ctx1")
+	}
+	if got := query.Get("maxResults"); got != "10" {
+		t.Errorf("maxResults: got %q want %q", got, "10")
+	}
+	if req.URL.Query().Get("quotaUser") != "" {
+		t.Errorf("the original request was modified: %v", req.URL)
+	}
+}
+
+func TestQuotaUserFor(t *testing.T) {
+	defer os.Setenv(QuotaUserEnvKey, os.Getenv(QuotaUserEnvKey))
+	os.Setenv(QuotaUserEnvKey, "")
+
+	a := quotaUserFor(&config.Context{AbsPath: "/home/a/docs"})
+	b := quotaUserFor(&config.Context{AbsPath: "/home/a/backups"})
+	if a == "" || a == b {
+		t.Errorf("contexts should have distinct quotaUsers: %q and %q", a, b)
+	}
+	if again := quotaUserFor(&config.Context{AbsPath: "/home/a/docs"}); again != a {
+		t.Errorf("quotaUser should be stable: got %q want %q", again, a)
+	}
+
+	os.Setenv(QuotaUserEnvKey, "shared")
+	if got := quotaUserFor(&config.Context{AbsPath: "/home/a/docs"}); got != "shared" {
+		t.Errorf("env override: got %q want %q", got, "shared")
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Unix(1000, 0)
+	var slept []time.Duration
+	tb := newTokenBucket(2)
+	tb.now = func() time.Time { return now }
+	tb.sleep = func(d time.Duration) { slept = append(slept, d) }
+
+	// A burst of the rate goes through at once, the rest is paced.
+	for i := 0; i < 4; i++ {
+		tb.wait()
+	}
+	want := []time.Duration{500 * time.Millisecond, time.Second}
+	if len(slept) != len(want) || slept[0] != want[0] || slept[1] != want[1] {
+		t.Errorf("slept: got %v want %v", slept, want)
+	}
+
+	// Once the waits are over and the bucket refilled, a burst goes through again.
+	now = now.Add(3 * time.Second)
+	slept = nil
+	tb.wait()
+	tb.wait()
+	if len(slept) != 0 {
+		t.Errorf("expected a refilled bucket not to wait, slept %v", slept)
+	}
+}
+
+func TestTokenBucketFor(t *testing.T) {
+	docs := tokenBucketFor("/home/a/docs", 5)
+	backups := tokenBucketFor("/home/a/backups", 1)
+	if docs == backups {
+		t.Errorf("contexts should be paced independently")
+	}
+	if again := tokenBucketFor("/home/a/docs", 10); again != docs {
+		t.Errorf("runs of a context should share its bucket")
+	}
+	if docs.rate != 10 {
+		t.Errorf("rate: got %v want %v", docs.rate, 10)
+	}
+}
@@ -80,15 +80,41 @@ func kvifyCommentedFile(p, comment string) (map[string]map[string]string, error)
 	return gkvMap, nil
 }
 
+// ResourceMappings returns the settings from every .driverc that applies to
+// rcPath, merged section by section so that a .driverc in a subdirectory
+// overrides the keys set by those in its parent directories.
 func ResourceMappings(rcPath string) (map[string]map[string]interface{}, error) {
 	beginOpts := Options{Path: rcPath}
-	rcPath, rcErr := beginOpts.rcPath()
+	rcPaths, rcErr := beginOpts.rcPaths()
 
 	if rcErr != nil {
 		DebugPrintf("tried to read from rcPath: %s got err: %v", rcPath, rcErr)
 		return nil, rcErr
 	}
 
+	grouped := make(map[string]map[string]interface{})
+
+	// Apply the outermost .driverc first so that nearer ones overwrite it.
+	for i := len(rcPaths) - 1; i >= 0; i-- {
+		rcMappings, err := singleResourceMappings(rcPaths[i])
+		if err != nil {
+			return nil, err
+		}
+
+		for key, parsed := range rcMappings {
+			ns, ok := grouped[key]
+			if !ok {
+				ns = make(map[string]interface{})
+				grouped[key] = ns
+			}
+			copyAndOverWriteNs(parsed, ns)
+		}
+	}
+
+	return grouped, nil
+}
+
+func singleResourceMappings(rcPath string) (map[string]map[string]interface{}, error) {
 	DebugPrintf("RCPath: %s", rcPath)
 	nsRCMap, rErr := kvifyCommentedFile(rcPath, CommentStr)
 	if rErr != nil {
@@ -113,50 +139,76 @@ func ResourceMappings(rcPath string) (map[string]map[string]interface{}, error)
 	return grouped, nil
 }
 
-func parseRCValues(rcMap map[string]string) (valueMappings map[string]interface{}, err error) {
-	valueMappings = make(map[string]interface{})
-
-	targetKeys := []struct {
-		resolver resolverEmitter
-		keys     []string
-	}{
-		{
-			resolver: _boolfer, keys: []string{
-				OcrKey, ConvertKey, CLIOptionFileBrowser, CLIOptionWebBrowser,
-				CLIOptionVerboseKey, RecursiveKey, CLIOptionFiles, CLIOptionLongFmt,
-				ForceKey, QuietKey, HiddenKey, NoPromptKey, NoClobberKey, IgnoreConflictKey,
-				CLIOptionIgnoreNameClashes, CLIOptionIgnoreChecksum, CLIOptionFixClashesKey,
-				CLIOptionDesktopLinks, CLIOptionExportsDumpToSameDirectory, CLIOptionTrashed,
-				CLIOptionStarred, CLIOptionPiped, CLIOptionExplicitlyExport,
-				CLIOptionDirectories, CLIOptionAllStarred,
-			},
+// rcTargetKeys are the keys that a .driverc may set, by how their values are resolved.
+var rcTargetKeys = []struct {
+	resolver resolverEmitter
+	keys     []string
+}{
+	{
+		resolver: _boolfer, keys: []string{
+			OcrKey, ConvertKey, CLIOptionFileBrowser, CLIOptionWebBrowser,
+			CLIOptionVerboseKey, RecursiveKey, CLIOptionFiles, CLIOptionLongFmt,
+			ForceKey, QuietKey, HiddenKey, NoPromptKey, NoClobberKey, IgnoreConflictKey,
+			CLIOptionIgnoreNameClashes, CLIOptionIgnoreChecksum, CLIOptionFixClashesKey,
+			CLIOptionDesktopLinks, CLIOptionPlaceholders, CLIOptionExportsDumpToSameDirectory, CLIOptionTrashed,
+			CLIOptionStarred, CLIOptionPiped, CLIOptionExplicitlyExport,
+			CLIOptionDirectories, CLIOptionAllStarred, CLIOptionBackground,
+			CLIOptionStrict, CLIOptionSparse, CLIOptionSnapshotToTemp, CLIOptionShadowCopy,
+			CLIOptionRollback,
 		},
-		{
-			resolver: _intfer, keys: []string{
-				PageSizeKey,
-				DepthKey,
-				CLIOptionRetryCount,
-			},
+	},
+	{
+		resolver: _intfer, keys: []string{
+			PageSizeKey,
+			DepthKey,
+			CLIOptionRetryCount,
+			CLIOptionUploadRateLimit,
+			CLIOptionMaxAPICalls,
+			CLIOptionAPIRate,
+			CLIOptionLargeFiles, CLIOptionLockedRetries,
 		},
-		{
-			resolver: _stringfer, keys: []string{
-				CLIOptionUnified, CLIOptionDiffBaseLocal,
-				ExportsKey, ExcludeOpsKey, CLIOptionUnifiedShortKey,
-				CLIEncryptionPassword, CLIDecryptionPassword, SortKey,
-				CLIOptionNotOwner, ExportsDirKey, CLIOptionExactTitle, AddressKey,
-				CLIOptionPushDestination, CLIOptionSkipMime, CLIOptionMatchMime,
-				ExportsKey,
-			},
+	},
+	{
+		resolver: _stringfer, keys: []string{
+			CLIOptionUnified, CLIOptionDiffBaseLocal,
+			ExportsKey, ExcludeOpsKey, CLIOptionUnifiedShortKey,
+			CLIEncryptionPassword, CLIDecryptionPassword, SortKey,
+			CLIOptionNotOwner, ExportsDirKey, CLIOptionExactTitle, AddressKey,
+			CLIOptionPushDestination, CLIOptionSkipMime, CLIOptionMatchMime,
+			ExportsKey, CLIOptionUploadRateSchedule, CLIOptionMaxBytes,
+			CLIOptionSplitSize, CLIOptionIgnoreProfiles, CLIOptionNamePattern,
+			CLIOptionModifiedAfter, CLIOptionModifiedBefore, CLIOptionLargeSize,
+			CLIOptionCollate, CLIOptionOrder, CLIOptionSample,
+			CLIOptionHeartbeat, CLIOptionHeartbeatFile, CLIOptionShard,
+			CLIOptionStallTimeout, CLIOptionClientId, CLIOptionClientSecret,
 		},
-		{
-			resolver: _stringArrayfer, keys: []string{
-			// Add items that might need string array parsing and conversion here
-			},
+	},
+	{
+		resolver: _stringArrayfer, keys: []string{
+		// Add items that might need string array parsing and conversion here
 		},
+	},
+}
+
+// rcKeyResolver returns the resolver of the values of the .driverc key,
+// reporting whether the key is one that a .driverc may set at all.
+func rcKeyResolver(key string) (resolverEmitter, bool) {
+	lowerKey := strings.ToLower(key)
+	for _, item := range rcTargetKeys {
+		for _, targetKey := range item.keys {
+			if strings.ToLower(targetKey) == lowerKey {
+				return item.resolver, true
+			}
+		}
 	}
+	return nil, false
+}
+
+func parseRCValues(rcMap map[string]string) (valueMappings map[string]interface{}, err error) {
+	valueMappings = make(map[string]interface{})
 
 	accepted := make(map[string]typeResolver)
-	for _, item := range targetKeys {
+	for _, item := range rcTargetKeys {
 		resolver := item.resolver
 		keys := item.keys
 		for _, key := range keys {
@@ -243,9 +295,19 @@ func JSONStringifySiftedCLITags(from interface{}, rcSourcePath string, defined m
 		return "", err
 	}
 
+	globalMappings, err := GlobalConfigMappings(globalConfigPath())
+	if err != nil && !NotExist(err) {
+		return "", err
+	}
+
+	// The command line takes precedence over the .driverc which in turn
+	// takes precedence over the global configuration.
+	defaults := mergeNamespaces(globalMappings, relevantNamespaces...)
+	copyAndOverWriteNs(mergeNamespaces(rcMappings, relevantNamespaces...), defaults)
+
 	cs := CliSifter{
 		From:           from,
-		Defaults:       mergeNamespaces(rcMappings, relevantNamespaces...),
+		Defaults:       defaults,
 		AlreadyDefined: defined,
 	}
 
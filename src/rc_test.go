@@ -37,6 +37,24 @@ func TestStructuredRC(t *testing.T) {
 				"starred": {"all": true},
 			},
 		},
+
+		// A .driverc in a subdirectory is merged with
+		// those of its parents, the nearest one winning.
+		2: {
+			rcDir: "./testdata/nested/reports",
+			want: map[string]map[string]interface{}{
+				"global": {"depth": -1, "hidden": true},
+				"pull":   {"export": "csv", "no-prompt": true},
+			},
+		},
+
+		3: {
+			rcDir: "./testdata/nested",
+			want: map[string]map[string]interface{}{
+				"global": {"depth": -1, "hidden": false},
+				"pull":   {"export": "doc,pdf", "no-prompt": true},
+			},
+		},
 	}
 
 	blobify := func(v interface{}) []byte {
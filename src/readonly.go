@@ -0,0 +1,127 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/odeke-em/drive/config"
+)
+
+const (
+	// scopePrefix is shared by the URLs of the Drive scopes.
+	scopePrefix = "https://www.googleapis.com/auth/"
+
+	// AppDataFolderId is the alias of the application data folder,
+	// which contexts with the drive.appdata scope are bound to.
+	AppDataFolderId = "appDataFolder"
+)
+
+// Scopes maps the names that `init -scope` accepts to the OAuth 2.0 scopes that they request.
+var Scopes = map[string]string{
+	"drive":          DriveScope,
+	"drive.file":     DriveFileScope,
+	"drive.readonly": DriveReadOnlyScope,
+	"drive.appdata":  DriveAppDataScope,
+}
+
+// ResolveScope returns the OAuth 2.0 scope named name e.g drive.file.
+func ResolveScope(name string) (string, error) {
+	if scope, ok := Scopes[strings.TrimPrefix(strings.TrimSpace(name), scopePrefix)]; ok {
+		return scope, nil
+	}
+
+	var names []string
+	for known := range Scopes {
+		names = append(names, known)
+	}
+	sort.Strings(names)
+	return "", invalidArgumentsErr(fmt.Errorf("unknown scope %q, expecting one of %s", name, strings.Join(names, ", ")))
+}
+
+// DeviceFlowScope returns the scope that the device flow requests for
+// the requested scope, which is empty if none was asked for. Google only
+// allows the drive.file and drive.appdata scopes in the device flow, so
+// it defaults to drive.file and any other scope is refused up front.
+func DeviceFlowScope(requested string) (string, error) {
+	switch requested {
+	case "":
+		return DriveFileScope, nil
+	case DriveFileScope, DriveAppDataScope:
+		return requested, nil
+	}
+	return "", invalidArgumentsErr(fmt.Errorf("the device flow only allows the %s and %s scopes, not %s",
+		scopeName(DriveFileScope), scopeName(DriveAppDataScope), scopeName(requested)))
+}
+
+// scopeName returns the short name of scope e.g drive.file.
+func scopeName(scope string) string {
+	return strings.TrimPrefix(scope, scopePrefix)
+}
+
+// checkGrantedScope fails if granted, the space separated scopes that
+// access was granted for, doesn't include scope e.g because it was
+// unticked on the consent screen. An empty granted isn't checked
+// since not every token response reports its scopes.
+func checkGrantedScope(scope, granted string) error {
+	if granted == "" {
+		return nil
+	}
+	for _, g := range strings.Fields(granted) {
+		if g == scope {
+			return nil
+		}
+	}
+	return insufficientScopeErr(fmt.Errorf("access was granted for %q instead of the %s scope that was requested",
+		granted, scopeName(scope)))
+}
+
+// scopeFor returns the OAuth 2.0 scope of the context's credentials.
+func scopeFor(context *config.Context) string {
+	if context.Scope == "" {
+		return DriveScope
+	}
+	return context.Scope
+}
+
+// isReadOnly reports whether the context's credentials were only
+// granted the read-only scope, with which nothing can be modified.
+func isReadOnly(context *config.Context) bool {
+	return scopeFor(context) == DriveReadOnlyScope
+}
+
+// refuseReadOnly fails the command named verb, which would modify the
+// Drive, if the context was initialized with the drive.readonly scope.
+func (g *Commands) refuseReadOnly(verb string) error {
+	if !isReadOnly(g.context) {
+		return nil
+	}
+	return immutableAttemptErr(fmt.Errorf("%s: insufficient scope, %s is a read-only context, run `drive init -%s drive` to modify its Drive",
+		verb, customQuote(g.context.AbsPath), CLIOptionScope))
+}
+
+// requireWholeDrive fails the command named verb, which needs to see
+// files that drive didn't create, if the context's scope is limited
+// to those files or to the application data folder.
+func (g *Commands) requireWholeDrive(verb string) error {
+	switch scope := scopeFor(g.context); scope {
+	case DriveFileScope, DriveAppDataScope:
+		return insufficientScopeErr(fmt.Errorf("%s: insufficient scope, %s was initialized with the %s scope, run `drive init -%s drive` to use the whole Drive",
+			verb, customQuote(g.context.AbsPath), scopeName(scope), CLIOptionScope))
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/odeke-em/drive/config"
+)
+
+func TestRefuseReadOnly(t *testing.T) {
+	cases := []struct {
+		scope     string
+		wantScope string
+		wantErr   bool
+	}{
+		{scope: "", wantScope: DriveScope},
+		{scope: DriveScope, wantScope: DriveScope},
+		{scope: DriveReadOnlyScope, wantScope: DriveReadOnlyScope, wantErr: true},
+	}
+
+	for i, tc := range cases {
+		context := &config.Context{AbsPath: "/tmp/gdrive"}
+		context.Scope = tc.scope
+		if got := scopeFor(context); got != tc.wantScope {
+			t.Errorf("#%d: scope: got %q want %q", i, got, tc.wantScope)
+		}
+
+		g := &Commands{context: context}
+		err := g.refuseReadOnly("push")
+		if (err != nil) != tc.wantErr {
+			t.Errorf("#%d: err: got %v wantErr %v", i, err, tc.wantErr)
+			continue
+		}
+		if err == nil {
+			continue
+		}
+		if e, ok := err.(*Error); !ok || e.code != StatusImmutableOperationAttempted {
+			t.Errorf("#%d: got %#v want an immutable operation error", i, err)
+		}
+	}
+}
+
+func TestResolveScope(t *testing.T) {
+	cases := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{name: "drive", want: DriveScope},
+		{name: "drive.file", want: DriveFileScope},
+		{name: " drive.readonly ", want: DriveReadOnlyScope},
+		{name: "drive.appdata", want: DriveAppDataScope},
+		{name: DriveFileScope, want: DriveFileScope},
+		{name: "drive.metadata", wantErr: true},
+		{name: "", wantErr: true},
+	}
+
+	for i, tc := range cases {
+		got, err := ResolveScope(tc.name)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("#%d: err: got %v wantErr %v", i, err, tc.wantErr)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("#%d: got %q want %q", i, got, tc.want)
+		}
+	}
+}
+
+func TestCheckGrantedScope(t *testing.T) {
+	cases := []struct {
+		scope, granted string
+		wantErr        bool
+	}{
+		{scope: DriveFileScope, granted: ""},
+		{scope: DriveFileScope, granted: DriveFileScope},
+		{scope: DriveScope, granted: "openid " + DriveScope},
+		{scope: DriveScope, granted: DriveFileScope, wantErr: true},
+		{scope: DriveAppDataScope, granted: DriveReadOnlyScope, wantErr: true},
+	}
+
+	for i, tc := range cases {
+		err := checkGrantedScope(tc.scope, tc.granted)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("#%d: err: got %v wantErr %v", i, err, tc.wantErr)
+			continue
+		}
+		if err == nil {
+			continue
+		}
+		if e, ok := err.(*Error); !ok || e.code != StatusInsufficientScope {
+			t.Errorf("#%d: got %#v want an insufficient scope error", i, err)
+		}
+	}
+}
+
+func TestRequireWholeDrive(t *testing.T) {
+	cases := []struct {
+		scope   string
+		wantErr bool
+	}{
+		{scope: ""},
+		{scope: DriveScope},
+		{scope: DriveReadOnlyScope},
+		{scope: DriveFileScope, wantErr: true},
+		{scope: DriveAppDataScope, wantErr: true},
+	}
+
+	for i, tc := range cases {
+		context := &config.Context{AbsPath: "/tmp/gdrive"}
+		context.Scope = tc.scope
+
+		g := &Commands{context: context}
+		err := g.requireWholeDrive("transfer")
+		if (err != nil) != tc.wantErr {
+			t.Errorf("#%d: err: got %v wantErr %v", i, err, tc.wantErr)
+			continue
+		}
+		if err == nil {
+			continue
+		}
+		if e, ok := err.(*Error); !ok || e.code != StatusInsufficientScope {
+			t.Errorf("#%d: got %#v want an insufficient scope error", i, err)
+		}
+	}
+}
+
+func TestTrashOptVerb(t *testing.T) {
+	cases := []struct {
+		opt  trashOpt
+		want string
+	}{
+		{opt: trashOpt{toTrash: true}, want: "trash"},
+		{opt: trashOpt{toTrash: true, permanent: true}, want: "delete"},
+		{opt: trashOpt{permanent: true}, want: "delete"},
+		{opt: trashOpt{}, want: "untrash"},
+	}
+
+	for _, tc := range cases {
+		if got := tc.opt.verb(); got != tc.want {
+			t.Errorf("%+v: got %q want %q", tc.opt, got, tc.want)
+		}
+	}
+}
+
+func TestDeviceFlowScope(t *testing.T) {
+	testCases := []struct {
+		requested string
+		want      string
+		wantErr   bool
+	}{
+		{requested: "", want: DriveFileScope},
+		{requested: DriveFileScope, want: DriveFileScope},
+		{requested: DriveAppDataScope, want: DriveAppDataScope},
+		{requested: DriveScope, wantErr: true},
+		{requested: DriveReadOnlyScope, wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		got, err := DeviceFlowScope(tc.requested)
+		if tc.wantErr {
+			if err == nil || !strings.Contains(err.Error(), scopeName(tc.requested)) {
+				t.Errorf("%q: expected an error naming the scope, got %v", tc.requested, err)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("%q: got %q, %v want %q", tc.requested, got, err, tc.want)
+		}
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"net/url"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"golang.org/x/net/context"
@@ -45,6 +46,16 @@ const (
 	// OAuth 2.0 full Drive scope used for authorization.
 	DriveScope = "https://www.googleapis.com/auth/drive"
 
+	// OAuth 2.0 read-only Drive scope, for contexts that only pull.
+	DriveReadOnlyScope = "https://www.googleapis.com/auth/drive.readonly"
+
+	// OAuth 2.0 Drive scope limited to the files that drive
+	// itself created or that the user opened with it.
+	DriveFileScope = "https://www.googleapis.com/auth/drive.file"
+
+	// OAuth 2.0 Drive scope limited to the hidden application data folder.
+	DriveAppDataScope = "https://www.googleapis.com/auth/drive.appdata"
+
 	// OAuth 2.0 access type for offline/refresh access.
 	AccessType = "offline"
 
@@ -93,6 +104,99 @@ type Remote struct {
 	encrypter    func(io.Reader) (io.Reader, error)
 	decrypter    func(io.Reader) (io.ReadCloser, error)
 	progressChan chan int
+
+	// folderIds caches the ids of the folders resolved while walking
+	// down paths, keyed by their parent's id and their title, so that
+	// several paths that share ancestors only resolve them once. It is
+	// dropped by folderIdsTransport whenever the remote could change.
+	folderIdsMu sync.Mutex
+	folderIds   map[string][]string
+
+	// budget when set caps the requests made by this remote.
+	budget *budget
+
+	// stallTransport when set cancels the requests that stall.
+	stallTransport *stallTransport
+
+	// rootId when set is the id of the folder that paths resolve from.
+	rootId string
+}
+
+func (r *Remote) root() string {
+	if r.rootId == "" {
+		return "root"
+	}
+	return r.rootId
+}
+
+func folderIdsKey(parentId, title string) string {
+	return parentId + "/" + title
+}
+
+func (r *Remote) cachedFolderIds(parentId, title string) ([]string, bool) {
+	r.folderIdsMu.Lock()
+	defer r.folderIdsMu.Unlock()
+
+	ids, ok := r.folderIds[folderIdsKey(parentId, title)]
+	return ids, ok
+}
+
+func (r *Remote) cacheFolderIds(parentId, title string, ids []string) {
+	r.folderIdsMu.Lock()
+	defer r.folderIdsMu.Unlock()
+
+	// Folders that don't exist yet might be created later on.
+	if len(ids) < 1 {
+		return
+	}
+	if r.folderIds == nil {
+		r.folderIds = map[string][]string{}
+	}
+	r.folderIds[folderIdsKey(parentId, title)] = ids
+}
+
+// forgetFolderIds drops the cache once folders could have moved or gone.
+func (r *Remote) forgetFolderIds() {
+	r.folderIdsMu.Lock()
+	defer r.folderIdsMu.Unlock()
+
+	r.folderIds = nil
+}
+
+// folderIdsTransport drops the remote's cached folder ids around each
+// request that could change the remote, so that no mutation e.g a move,
+// rename or trash of an ancestor folder leaves a stale id behind. They
+// are dropped again once the request is done since a lookup running
+// alongside it could have cached what it is changing.
+type folderIdsTransport struct {
+	base http.RoundTripper
+	rem  *Remote
+}
+
+func (ft *folderIdsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !mutatingRequest(req) {
+		return ft.base.RoundTrip(req)
+	}
+	ft.rem.forgetFolderIds()
+	res, err := ft.base.RoundTrip(req)
+	ft.rem.forgetFolderIds()
+	return res, err
+}
+
+// findByTitleQuery is the query for the children of parentId titled head.
+// Only folders can have descendants, so intermediate path segments
+// ie those that aren't the last, need not match any files.
+func findByTitleQuery(parentId, head string, trashed, intermediate bool) string {
+	if trashed {
+		return fmt.Sprintf("title = %s and trashed=true", customQuote(head))
+	}
+
+	expr := fmt.Sprintf("%s in parents and title = %s and trashed=false",
+		customQuote(parentId), customQuote(head))
+	if intermediate {
+		expr = fmt.Sprintf("%s and mimeType = %s", expr, customQuote(DriveFolderMimeType))
+	}
+	return expr
 }
 
 // NewRemoteContextFromServiceAccount returns a remote initialized
@@ -107,6 +211,37 @@ func NewRemoteContextFromServiceAccount(jwtConfig *jwt.Config) (*Remote, error)
 	return remoteFromClient(client)
 }
 
+// impersonating returns a copy of jwtConfig that acts as the user subject
+// by domain-wide delegation, or jwtConfig itself if subject is empty.
+func impersonating(jwtConfig *jwt.Config, subject string) *jwt.Config {
+	if jwtConfig == nil || subject == "" {
+		return jwtConfig
+	}
+	impersonated := *jwtConfig
+	impersonated.Subject = subject
+	return &impersonated
+}
+
+// NewRemoteContextFromDefaultCredentials authenticates with the Application
+// Default Credentials e.g the key file that GOOGLE_APPLICATION_CREDENTIALS
+// points to, the credentials of `gcloud auth application-default login`
+// or those of the Compute Engine instance.
+func NewRemoteContextFromDefaultCredentials() (*Remote, error) {
+	ctx := context.Background()
+	creds, err := google.FindDefaultCredentials(ctx, DriveScope)
+	if err != nil {
+		return nil, err
+	}
+	return remoteFromClient(oauth2.NewClient(ctx, creds.TokenSource))
+}
+
+// HasDefaultCredentials reports whether Application Default Credentials
+// are available to authenticate with.
+func HasDefaultCredentials() bool {
+	_, err := google.FindDefaultCredentials(context.Background(), DriveScope)
+	return err == nil
+}
+
 func NewRemoteContext(context *config.Context) (*Remote, error) {
 	client := newOAuthClient(context)
 	return remoteFromClient(client)
@@ -124,6 +259,12 @@ func remoteFromClient(client *http.Client) (*Remote, error) {
 		service:      service,
 		client:       client,
 	}
+
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	client.Transport = &folderIdsTransport{base: base, rem: rem}
 	return rem, nil
 }
 
@@ -181,7 +322,7 @@ func (r *Remote) change(changeId string) (*drive.Change, error) {
 	return r.service.Changes.Get(changeId).Do()
 }
 
-func RetrieveRefreshToken(ctx context.Context, context *config.Context) (string, error) {
+func RetrieveRefreshToken(ctx context.Context, context *config.Context) (*oauth2.Token, error) {
 	config := newAuthConfig(context)
 
 	randState := fmt.Sprintf("%s%v", time.Now(), rand.Uint32())
@@ -192,9 +333,52 @@ func RetrieveRefreshToken(ctx context.Context, context *config.Context) (string,
 
 	token, err := config.Exchange(ctx, code)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	return token, nil
+}
+
+// RetrieveRefreshTokenByDevice retrieves a refresh token by the OAuth 2.0
+// device flow, for machines without a browser: the user enters the
+// printed code at the verification URL from any other device while
+// the token endpoint is polled until access is granted or denied.
+func RetrieveRefreshTokenByDevice(ctx context.Context, context *config.Context) (*oauth2.Token, error) {
+	config := newAuthConfig(context)
+
+	deviceAuth, err := config.DeviceAuth(ctx)
+	if err != nil {
+		return nil, deviceFlowErr(err, scopeFor(context))
+	}
+
+	fmt.Printf("On any device with a browser, visit\n%s\nand enter the code: %s\n", deviceAuth.VerificationURI, deviceAuth.UserCode)
+	fmt.Println("Waiting for access to be granted...")
+
+	token, err := config.DeviceAccessToken(ctx, deviceAuth)
+	if err != nil {
+		return nil, deviceFlowErr(err, scopeFor(context))
+	}
+	return token, nil
+}
+
+func deviceFlowErr(err error, scope string) error {
+	rErr, ok := err.(*oauth2.RetrieveError)
+	if !ok {
+		return err
+	}
+
+	switch rErr.ErrorCode {
+	case "invalid_scope":
+		return invalidArgumentsErr(fmt.Errorf("device flow: %v\nthe %s scope was refused, the device flow only allows the %s and %s scopes",
+			err, scopeName(scope), scopeName(DriveFileScope), scopeName(DriveAppDataScope)))
+	case "invalid_client", "unauthorized_client":
+		return invalidArgumentsErr(fmt.Errorf("device flow: %v\nGoogle only allows the device flow for OAuth clients of type \"TVs and Limited Input devices\", "+
+			"set the credentials of one in %s and %s", err, DriveClientIdEnvKey, DriveClientSecretEnvKey))
+	case "access_denied":
+		return invalidArgumentsErr(fmt.Errorf("device flow: access was denied"))
+	case "expired_token":
+		return invalidArgumentsErr(fmt.Errorf("device flow: the code expired before access was granted, run init again"))
 	}
-	return token.RefreshToken, nil
+	return err
 }
 
 func (r *Remote) FindBackPaths(id string) (backPaths []string, err error) {
@@ -204,6 +388,11 @@ func (r *Remote) FindBackPaths(id string) (backPaths []string, err error) {
 		return
 	}
 
+	if r.rootId != "" && f.Id == r.rootId {
+		backPaths = append(backPaths, DriveRemoteSep)
+		return
+	}
+
 	relPath := sepJoin(DriveRemoteSep, f.Name)
 	if rootLike(relPath) {
 		relPath = DriveRemoteSep
@@ -219,7 +408,16 @@ func (r *Remote) FindBackPaths(id string) (backPaths []string, err error) {
 			continue
 		}
 
-		if p.IsRoot {
+		if r.rootId != "" {
+			if p.Id == r.rootId {
+				backPaths = append(backPaths, sepJoin(DriveRemoteSep, relPath))
+				continue
+			}
+			if p.IsRoot {
+				// Outside of the folder that the context is bound to.
+				continue
+			}
+		} else if p.IsRoot {
 			backPaths = append(backPaths, sepJoin(DriveRemoteSep, relPath))
 			continue
 		}
@@ -283,7 +481,7 @@ func retryableChangeOp(fn func() (interface{}, error), debug bool, retryCount in
 
 func (r *Remote) findByPathM(p string, trashed bool) *paginationPair {
 	if rootLike(p) {
-		return r.FindByIdM("root")
+		return r.FindByIdM(r.root())
 	}
 
 	parts := strings.Split(p, RemoteSeparator)
@@ -292,19 +490,19 @@ func (r *Remote) findByPathM(p string, trashed bool) *paginationPair {
 		finder = r.findByPathTrashedM
 	}
 
-	return finder("root", parts[1:])
+	return finder(r.root(), parts[1:])
 }
 
 func (r *Remote) findByPath(p string, trashed bool) (*File, error) {
 	if rootLike(p) {
-		return r.FindById("root")
+		return r.FindById(r.root())
 	}
 	parts := strings.Split(p, "/")
 	finder := r.findByPathRecv
 	if trashed {
 		finder = r.findByPathTrashed
 	}
-	return finder("root", parts[1:])
+	return finder(r.root(), parts[1:])
 }
 
 func (r *Remote) FindByPath(p string) (*File, error) {
@@ -457,13 +655,18 @@ func (r *Remote) insertPermissions(permInfo *permission) (*drive.Permission, err
 	return req.Do()
 }
 
-func (r *Remote) revokePermissions(p *permission) (err error) {
+func (r *Remote) deletePermission(fileId, permId string) error {
+	return r.service.Permissions.Delete(fileId, permId).Do()
+}
+
+// revokePermissions deletes the permissions matching p, returning
+// those that were deleted, none if no permission matched.
+func (r *Remote) revokePermissions(p *permission) (deleted []*drive.Permission, err error) {
 	foundPermissionsChan, fErr := r.findPermissions(p)
 	if fErr != nil {
-		return fErr
+		return nil, fErr
 	}
 
-	successes := 0
 	for perm := range foundPermissionsChan {
 		if perm == nil {
 			continue
@@ -473,18 +676,31 @@ func (r *Remote) revokePermissions(p *permission) (err error) {
 		if delErr := req.Do(); delErr != nil {
 			err = reComposeError(err, fmt.Sprintf("err: %v fileId: %s permissionId %s", delErr, p.fileId, perm.Id))
 		} else {
-			successes += 1
+			deleted = append(deleted, perm)
 		}
 	}
 
-	if err != nil {
-		return err
+	return deleted, err
+}
+
+// restorePermission inserts anew a permission that was deleted,
+// without notifying its grantee.
+func (r *Remote) restorePermission(fileId string, perm *drive.Permission) error {
+	restored := &drive.Permission{
+		Role:            perm.Role,
+		Type:            perm.Type,
+		AdditionalRoles: perm.AdditionalRoles,
+		WithLink:        perm.WithLink,
 	}
 
-	if successes < 1 {
-		err = noMatchesFoundErr(fmt.Errorf("no matches found!"))
+	switch perm.Type {
+	case "user", "group":
+		restored.Value = perm.EmailAddress
+	case "domain":
+		restored.Value = perm.Domain
 	}
 
+	_, err := r.service.Permissions.Insert(fileId, restored).SendNotificationEmails(false).Do()
 	return err
 }
 
@@ -697,6 +913,21 @@ type upsertOpt struct {
 	retryCount      int
 	uploadChunkSize int
 	uploadRateLimit int
+	// uploadRateSchedule when set overrides
+	// uploadRateLimit by the time of day.
+	uploadRateSchedule *RateSchedule
+	// background when set paces the reads of local content.
+	background bool
+	// description when set is given to the uploaded file.
+	description string
+	// createdTime when set is kept as that of the inserted file.
+	createdTime time.Time
+	// snapshotDir when set is where local content is copied to
+	// before its upload, see snapshotToTemp.
+	snapshotDir string
+	// lockedRetries and shadows are as for openLocal.
+	lockedRetries int
+	shadows       *shadowCopies
 }
 
 func togglePropertiesInsertCall(req *drive.FilesInsertCall, mask int) *drive.FilesInsertCall {
@@ -740,6 +971,22 @@ func togglePropertiesUpdateCall(req *drive.FilesUpdateCall, mask int) *drive.Fil
 // and also if there are checksum differences.
 // For other changes such as modTime only varying, we can
 // just change the modTime on the cloud as an operation of its own.
+func (args *upsertOpt) openLocal(p string) (*os.File, error) {
+	return openLocal(p, args.lockedRetries, args.shadows)
+}
+
+// localPath is where the local content to upload is read from.
+func (args *upsertOpt) localPath() string {
+	// In relation to issue #612, since we are not only resolving
+	// relative to the current working directory, we should try reading
+	// first from the source's original local fsAbsPath aka `BlobAt`
+	// because the resolved path might be different from the original path.
+	if args.src.BlobAt != "" {
+		return args.src.BlobAt
+	}
+	return args.fsAbsPath
+}
+
 func (args *upsertOpt) shouldUploadBody() bool {
 	if args.src.IsDir {
 		return false
@@ -752,6 +999,20 @@ func (args *upsertOpt) shouldUploadBody() bool {
 }
 
 func (r *Remote) upsertByComparison(body io.Reader, args *upsertOpt) (f *File, mediaInserted bool, err error) {
+	if r.encrypter != nil && body != nil {
+		encR, encErr := r.encrypter(body)
+		if encErr != nil {
+			err = encErr
+			return
+		}
+		body = encR
+	}
+	return r.upsertContent(body, args)
+}
+
+// upsertContent is upsertByComparison with a body that is
+// already encrypted if content is to be encrypted.
+func (r *Remote) upsertContent(body io.Reader, args *upsertOpt) (f *File, mediaInserted bool, err error) {
 	uploaded := &drive.File{
 		// Must ensure that the path is prepared for a URL upload
 		Title:   urlToPath(args.src.Name, false),
@@ -762,18 +1023,18 @@ func (r *Remote) upsertByComparison(body io.Reader, args *upsertOpt) (f *File, m
 		uploaded.MimeType = DriveFolderMimeType
 	}
 
-	if r.encrypter != nil && body != nil {
-		encR, encErr := r.encrypter(body)
-		if encErr != nil {
-			err = encErr
-			return
-		}
-		body = encR
+	if args.description != "" {
+		uploaded.Description = args.description
 	}
 
 	// throttled reader: implement upload bandwidth limit
 	// uploadRateLimit is in KiB/s
-	reader := flowrate.NewReader(body, int64(args.uploadRateLimit*1024))
+	throttled := flowrate.NewReader(body, int64(args.uploadRateLimit*1024))
+
+	var reader io.Reader = throttled
+	if args.uploadRateSchedule != nil {
+		reader = &scheduledReader{Reader: throttled, schedule: args.uploadRateSchedule}
+	}
 
 	if args.src.MimeType != "" {
 		uploaded.MimeType = args.src.MimeType
@@ -792,6 +1053,11 @@ func (r *Remote) upsertByComparison(body io.Reader, args *upsertOpt) (f *File, m
 	}
 
 	if args.src.Id == "" {
+		// The created time can only be set on an insert.
+		if !args.createdTime.IsZero() {
+			uploaded.CreatedDate = toUTCString(args.createdTime)
+		}
+
 		req := r.service.Files.Insert(uploaded)
 
 		if !args.src.IsDir && body != nil {
@@ -910,25 +1176,39 @@ func (r *Remote) UpsertByComparison(args *upsertOpt) (f *File, err error) {
 	var cleanUp func() error
 
 	if !args.src.IsDir {
-		// In relation to issue #612, since we are not only resolving
-		// relative to the current working directory, we should try reading
-		// first from the source's original local fsAbsPath aka `BlobAt`
-		// because the resolved path might be different from the original path.
-		fsAbsPath := args.src.BlobAt
-		if fsAbsPath == "" {
-			fsAbsPath = args.fsAbsPath
-		}
-
+		fsAbsPath := args.localPath()
 		if args.shouldUploadBody() {
-			file, err := os.Open(fsAbsPath)
-			if err != nil {
-				return nil, err
-			}
+			var file *os.File
+			if args.snapshotDir != "" {
+				if file, err = snapshotToTemp(fsAbsPath, args.snapshotDir, args.openLocal); err != nil {
+					if isModifiedDuringUpload(err) {
+						refreshAfterModification(args.src, fsAbsPath)
+					}
+					return nil, err
+				}
+				cleanUp = func() error {
+					defer os.Remove(file.Name())
+					return file.Close()
+				}
+				body = file
+			} else {
+				if file, err = args.openLocal(fsAbsPath); err != nil {
+					return nil, err
+				}
+				fi, statErr := file.Stat()
+				if statErr != nil {
+					file.Close()
+					return nil, statErr
+				}
 
-			// We need to make sure that we close all open handles.
-			// See Issue https://github.com/odeke-em/drive/issues/711.
-			cleanUp = file.Close
-			body = file
+				// We need to make sure that we close all open handles.
+				// See Issue https://github.com/odeke-em/drive/issues/711.
+				cleanUp = file.Close
+				body = newStableReader(file, file.Name(), fi)
+			}
+			if args.background {
+				body = newPacedReader(body)
+			}
 		}
 	}
 
@@ -941,15 +1221,41 @@ func (r *Remote) UpsertByComparison(args *upsertOpt) (f *File, err error) {
 		}
 	}()
 
+	// Encrypted content is encrypted once for all attempts, see ciphertextSpool.
+	var spool *ciphertextSpool
+	if r.encrypter != nil && body != nil {
+		encR, encErr := r.encrypter(bd)
+		if encErr == nil {
+			spool, encErr = newCiphertextSpool(encR, args.snapshotDir)
+		}
+		if encErr != nil {
+			if cleanUp != nil {
+				cleanUp()
+			}
+			return nil, encErr
+		}
+	}
+
 	resultLoad := make(chan *tuple)
 
 	go func() {
 		if cleanUp != nil {
 			defer cleanUp()
 		}
+		if spool != nil {
+			defer spool.close()
+		}
 
 		emitter := func() (interface{}, error) {
-			f, mediaInserted, err := r.upsertByComparison(bd, args)
+			if spool == nil {
+				f, mediaInserted, err := r.upsertByComparison(bd, args)
+				return &tuple{first: f, second: mediaInserted, last: err}, err
+			}
+			content, err := spool.reader()
+			if err != nil {
+				return &tuple{last: err}, err
+			}
+			f, mediaInserted, err := r.upsertContent(content, args)
 			return &tuple{first: f, second: mediaInserted, last: err}, err
 		}
 
@@ -987,6 +1293,9 @@ func (r *Remote) UpsertByComparison(args *upsertOpt) (f *File, err error) {
 		}
 	}
 
+	if isModifiedDuringUpload(err) {
+		refreshAfterModification(args.src, args.localPath())
+	}
 	return f, err
 }
 
@@ -1049,6 +1358,19 @@ func (r *Remote) findChildren(parentId string, trashed bool) *paginationPair {
 	return reqDoPage(req, true, false)
 }
 
+// hasChildren reports whether the folder with parentId has any
+// files that aren't in the trash, without listing them all.
+func (r *Remote) hasChildren(parentId string) (bool, error) {
+	req := r.service.Files.List()
+	req.Q(fmt.Sprintf("%s in parents and trashed=false", customQuote(parentId)))
+	req.MaxResults(1)
+	files, err := req.Do()
+	if err != nil {
+		return false, err
+	}
+	return len(files.Items) >= 1, nil
+}
+
 func (r *Remote) About() (*drive.About, error) {
 	return r.service.About.Get().Do()
 }
@@ -1067,22 +1389,25 @@ func (r *Remote) findByPathRecvRawM(parentId string, p []string, trashed bool) *
 		}
 
 		first, rest := p[0], p[1:]
-		// find the file or directory under parentId and titled with p[0]
-		req := r.service.Files.List()
-		// TODO: use field selectors
-		var expr string
 		head := urlToPath(first, false)
-		if trashed {
-			expr = fmt.Sprintf("title = %s and trashed=true", customQuote(head))
-		} else {
-			expr = fmt.Sprintf("%s in parents and title = %s and trashed=false",
-				customQuote(parentId), customQuote(head))
+		intermediate := len(rest) >= 1
+
+		if intermediate && !trashed {
+			if ids, ok := r.cachedFolderIds(parentId, head); ok {
+				for _, id := range ids {
+					chanOChan <- r.findByPathRecvRawM(id, rest, trashed)
+				}
+				return
+			}
 		}
 
-		req.Q(expr)
+		// find the file or directory under parentId and titled with p[0]
+		req := r.service.Files.List()
+		// TODO: use field selectors
+		req.Q(findByTitleQuery(parentId, head, trashed, intermediate))
 		pager := _reqDoPage(req, true, false, true)
 
-		if len(rest) < 1 {
+		if !intermediate {
 			chanOChan <- pager
 			return
 		}
@@ -1090,11 +1415,15 @@ func (r *Remote) findByPathRecvRawM(parentId string, p []string, trashed bool) *
 		resultsChan := pager.filesChan
 		errsChan := pager.errsChan
 
+		var folderIds []string
+		cacheable := !trashed
+
 		working := true
 		for working {
 			select {
 			case err := <-errsChan:
 				if err != nil {
+					cacheable = false
 					chanOChan <- wrapInPaginationPair(nil, err)
 				}
 			case f, stillHasContent := <-resultsChan:
@@ -1104,6 +1433,7 @@ func (r *Remote) findByPathRecvRawM(parentId string, p []string, trashed bool) *
 				}
 
 				if f != nil {
+					folderIds = append(folderIds, f.Id)
 					chanOChan <- r.findByPathRecvRawM(f.Id, rest, trashed)
 				} else {
 					// Ensure that we properly send
@@ -1113,6 +1443,10 @@ func (r *Remote) findByPathRecvRawM(parentId string, p []string, trashed bool) *
 				}
 			}
 		}
+
+		if cacheable {
+			r.cacheFolderIds(parentId, head, folderIds)
+		}
 	}()
 
 	go func() {
@@ -1151,17 +1485,19 @@ func (r *Remote) findByPathRecvRawM(parentId string, p []string, trashed bool) *
 }
 
 func (r *Remote) findByPathRecvRaw(parentId string, p []string, trashed bool) (*File, error) {
+	head := urlToPath(p[0], false)
+	intermediate := len(p) > 1
+
+	if intermediate && !trashed {
+		if ids, ok := r.cachedFolderIds(parentId, head); ok {
+			return r.findByPathRecvRaw(ids[0], p[1:], trashed)
+		}
+	}
+
 	// find the file or directory under parentId and titled with p[0]
 	req := r.service.Files.List()
 	// TODO: use field selectors
-	var expr string
-	head := urlToPath(p[0], false)
-	if trashed {
-		expr = fmt.Sprintf("title = %s and trashed=true", customQuote(head))
-	} else {
-		expr = fmt.Sprintf("%s in parents and title = %s and trashed=false",
-			customQuote(parentId), customQuote(head))
-	}
+	expr := findByTitleQuery(parentId, head, trashed, intermediate)
 	req.Q(expr)
 
 	// We only need the head file since we expect only one File to be created
@@ -1204,22 +1540,17 @@ func (r *Remote) findByPathTrashed(parentId string, p []string) (*File, error) {
 }
 
 func newAuthConfig(context *config.Context) *oauth2.Config {
+	client := effectiveClient(context, os.Getenv)
 	return &oauth2.Config{
-		ClientID:     context.ClientId,
-		ClientSecret: context.ClientSecret,
+		ClientID:     client.id,
+		ClientSecret: client.secret,
 		RedirectURL:  RedirectURL,
 		Endpoint:     google.Endpoint,
-		Scopes:       []string{DriveScope},
+		Scopes:       []string{scopeFor(context)},
 	}
 }
 
 func newOAuthClient(configContext *config.Context) *http.Client {
 	config := newAuthConfig(configContext)
-
-	token := oauth2.Token{
-		RefreshToken: configContext.RefreshToken,
-		Expiry:       time.Now().Add(1 * time.Hour),
-	}
-
-	return config.Client(context.Background(), &token)
+	return oauth2.NewClient(context.Background(), newPersistingTokenSource(config, configContext))
 }
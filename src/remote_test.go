@@ -0,0 +1,96 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/jwt"
+)
+
+func TestFolderIdsDroppedOnMutation(t *testing.T) {
+	var rem *Remote
+	base := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		// A lookup running alongside a mutation caches what it changes.
+		if mutatingRequest(req) {
+			rem.cacheFolderIds("root", "during", []string{"stale"})
+		}
+		return (&countingTransport{}).RoundTrip(req)
+	})
+
+	rem, err := remoteFromClient(&http.Client{Transport: base})
+	if err != nil {
+		t.Fatalf("remoteFromClient: %v", err)
+	}
+
+	do := func(method string) {
+		req, _ := http.NewRequest(method, "https://www.googleapis.com/drive/v2/files/abc", strings.NewReader("{}"))
+		res, err := rem.client.Do(req)
+		if err != nil {
+			t.Fatalf("%s: %v", method, err)
+		}
+		res.Body.Close()
+	}
+
+	rem.cacheFolderIds("root", "photos", []string{"photosId"})
+	do("GET")
+	if _, ok := rem.cachedFolderIds("root", "photos"); !ok {
+		t.Errorf("expected a lookup to keep the cached folder ids")
+	}
+
+	for _, method := range []string{"PUT", "PATCH", "POST", "DELETE"} {
+		rem.cacheFolderIds("root", "photos", []string{"photosId"})
+		do(method)
+		for _, title := range []string{"photos", "during"} {
+			if ids, ok := rem.cachedFolderIds("root", title); ok {
+				t.Errorf("%s: expected %q to be dropped, got %q", method, title, ids)
+			}
+		}
+	}
+}
+
+func TestDeviceFlowErrNamesScope(t *testing.T) {
+	err := deviceFlowErr(&oauth2.RetrieveError{ErrorCode: "invalid_scope"}, DriveScope)
+	if err == nil || !strings.Contains(err.Error(), "the drive scope was refused") {
+		t.Errorf("expected the error to name the refused scope, got %v", err)
+	}
+
+	err = deviceFlowErr(&oauth2.RetrieveError{ErrorCode: "invalid_client"}, DriveFileScope)
+	if err == nil || !strings.Contains(err.Error(), DriveClientIdEnvKey) {
+		t.Errorf("expected the error to point to the client credentials, got %v", err)
+	}
+}
+
+func TestImpersonating(t *testing.T) {
+	if got := impersonating(nil, "user@example.com"); got != nil {
+		t.Errorf("nil config: got %+v", got)
+	}
+
+	conf := &jwt.Config{Email: "sa@example.iam.gserviceaccount.com", Subject: "owner@example.com"}
+	if got := impersonating(conf, ""); got != conf {
+		t.Errorf("expected the config itself without a subject, got %+v", got)
+	}
+
+	got := impersonating(conf, "user@example.com")
+	if got == conf || got.Subject != "user@example.com" || got.Email != conf.Email {
+		t.Errorf("expected a copy acting as user@example.com, got %+v", got)
+	}
+	if conf.Subject != "owner@example.com" {
+		t.Errorf("expected the original config left alone, got subject %q", conf.Subject)
+	}
+}
@@ -0,0 +1,196 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	drive "google.golang.org/api/drive/v2"
+	"google.golang.org/api/googleapi"
+)
+
+const (
+	// The properties of an advisory lock on a remote file. They are
+	// public so that teammates using other client ids still see them.
+	lockOwnerPropertyKey = "driveLockOwner"
+	lockedAtPropertyKey  = "driveLockedAt"
+	lockVisibility       = "PUBLIC"
+)
+
+// RemoteLock is an advisory lock on a remote file, taken with `drive lock`
+// to warn others off overwriting it e.g while it is being edited.
+type RemoteLock struct {
+	Owner    string
+	LockedAt time.Time
+}
+
+func (rl *RemoteLock) String() string {
+	if rl.LockedAt.IsZero() {
+		return fmt.Sprintf("locked by %s", rl.Owner)
+	}
+	return fmt.Sprintf("locked by %s since %s", rl.Owner, rl.LockedAt.Local().Format(time.RFC1123))
+}
+
+// remoteLockOf returns the lock recorded in the properties of a
+// remote file, or nil if it isn't locked.
+func remoteLockOf(properties []*drive.Property) *RemoteLock {
+	var rl RemoteLock
+	for _, property := range properties {
+		if property == nil || property.Visibility != lockVisibility {
+			continue
+		}
+		switch property.Key {
+		case lockOwnerPropertyKey:
+			rl.Owner = property.Value
+		case lockedAtPropertyKey:
+			rl.LockedAt, _ = time.Parse(time.RFC3339, property.Value)
+		}
+	}
+	if rl.Owner == "" {
+		return nil
+	}
+	return &rl
+}
+
+// lockOwner returns the email address of the user that locks files.
+func (r *Remote) lockOwner() (string, error) {
+	about, err := r.About()
+	if err != nil {
+		return "", err
+	}
+	if about.User == nil || about.User.EmailAddress == "" {
+		return "", illogicalStateErr(fmt.Errorf("the email address of the user is unknown"))
+	}
+	return about.User.EmailAddress, nil
+}
+
+func (r *Remote) setRemoteLock(fileId string, rl *RemoteLock) error {
+	properties := []*drive.Property{
+		{Key: lockOwnerPropertyKey, Value: rl.Owner, Visibility: lockVisibility},
+		{Key: lockedAtPropertyKey, Value: rl.LockedAt.UTC().Format(time.RFC3339), Visibility: lockVisibility},
+	}
+	for _, property := range properties {
+		if _, err := r.service.Properties.Insert(fileId, property).Do(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Remote) clearRemoteLock(fileId string) error {
+	// The owner goes last so that a lock is never left without one.
+	for _, key := range []string{lockedAtPropertyKey, lockOwnerPropertyKey} {
+		err := r.service.Properties.Delete(fileId, key).Visibility(lockVisibility).Do()
+		if gErr, ok := err.(*googleapi.Error); ok && gErr.Code == http.StatusNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Lock takes advisory locks on the sources, which push then refuses
+// to overwrite or delete for anyone else. Locks of others are only
+// taken over with Force.
+func (g *Commands) Lock(byId bool) error {
+	return remoteLocking(g, true, byId)
+}
+
+// Unlock releases the advisory locks on the sources. Locks of others
+// are only released with Force.
+func (g *Commands) Unlock(byId bool) error {
+	return remoteLocking(g, false, byId)
+}
+
+func remoteLocking(g *Commands, lock, byId bool) (composedErr error) {
+	owner, err := g.rem.lockOwner()
+	if err != nil {
+		return err
+	}
+
+	verb := "Locked"
+	if !lock {
+		verb = "Unlocked"
+	}
+
+	kvChan := resolver(g, byId, g.opts.Sources, noopOnFile)
+	for kv := range kvChan {
+		file, ok := kv.value.(*File)
+		if !ok {
+			g.log.LogErrf("%s: %s\n", kv.key, kv.value)
+			continue
+		}
+
+		if file == nil {
+			continue
+		}
+
+		if rl := file.RemoteLock; rl != nil && rl.Owner != owner && !g.opts.Force {
+			composedErr = reComposeError(composedErr, fmt.Sprintf("%q is %v, use -%s to override", kv.key, rl, ForceKey))
+			continue
+		}
+
+		if lock {
+			err = g.rem.setRemoteLock(file.Id, &RemoteLock{Owner: owner, LockedAt: time.Now()})
+		} else {
+			err = g.rem.clearRemoteLock(file.Id)
+		}
+
+		if err != nil {
+			composedErr = reComposeError(composedErr, fmt.Sprintf("%q %v", kv.key, err))
+		} else {
+			g.log.LogErrf("%s %q\n", verb, kv.key)
+		}
+	}
+
+	return composedErr
+}
+
+// skipRemotelyLocked leaves out of a push the changes that would overwrite
+// or delete files locked by someone else, unless forced to push them.
+func (g *Commands) skipRemotelyLocked(cl []*Change) ([]*Change, error) {
+	var owner string
+	kept := make([]*Change, 0, len(cl))
+	for _, c := range cl {
+		op := c.Op()
+		if c.Dest == nil || c.Dest.RemoteLock == nil || (op != OpMod && op != OpModConflict && op != OpDelete) {
+			kept = append(kept, c)
+			continue
+		}
+
+		if owner == "" {
+			var err error
+			if owner, err = g.rem.lockOwner(); err != nil {
+				return nil, err
+			}
+		}
+
+		rl := c.Dest.RemoteLock
+		switch {
+		case rl.Owner == owner:
+			kept = append(kept, c)
+		case g.opts.Force:
+			g.log.LogErrf("warning: %s is %v, pushing anyway\n", c.Path, rl)
+			kept = append(kept, c)
+		default:
+			g.skip(c.Path, fmt.Sprintf("%v, use -%s to push it anyway", rl, ForceKey))
+		}
+	}
+	return kept, nil
+}
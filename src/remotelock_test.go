@@ -0,0 +1,75 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	drive "google.golang.org/api/drive/v2"
+)
+
+func TestRemoteLockOf(t *testing.T) {
+	lockedAt := time.Date(2016, 5, 4, 3, 2, 1, 0, time.UTC)
+
+	tests := []struct {
+		properties []*drive.Property
+		want       *RemoteLock
+	}{
+		{properties: nil, want: nil},
+		{
+			properties: []*drive.Property{
+				{Key: "color", Value: "red", Visibility: "PUBLIC"},
+			},
+			want: nil,
+		},
+		{
+			properties: []*drive.Property{
+				{Key: lockOwnerPropertyKey, Value: "ada@example.com", Visibility: lockVisibility},
+				{Key: lockedAtPropertyKey, Value: "2016-05-04T03:02:01Z", Visibility: lockVisibility},
+			},
+			want: &RemoteLock{Owner: "ada@example.com", LockedAt: lockedAt},
+		},
+		{
+			// A lock whose time is garbled is still a lock.
+			properties: []*drive.Property{
+				{Key: lockedAtPropertyKey, Value: "yesterday", Visibility: lockVisibility},
+				{Key: lockOwnerPropertyKey, Value: "ada@example.com", Visibility: lockVisibility},
+			},
+			want: &RemoteLock{Owner: "ada@example.com"},
+		},
+		{
+			// Private properties are those of other apps.
+			properties: []*drive.Property{
+				{Key: lockOwnerPropertyKey, Value: "ada@example.com", Visibility: "PRIVATE"},
+			},
+			want: nil,
+		},
+		{
+			// A time alone isn't a lock.
+			properties: []*drive.Property{
+				{Key: lockedAtPropertyKey, Value: "2016-05-04T03:02:01Z", Visibility: lockVisibility},
+			},
+			want: nil,
+		},
+	}
+
+	for i, tt := range tests {
+		if got := remoteLockOf(tt.properties); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("#%d: got %+v want %+v", i, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"sort"
+	"sync"
+)
+
+type changeFailure struct {
+	change *Change
+	fn     func(*Change) error
+	err    error
+}
+
+// changeFailures collects the changes that fail during a run so
+// that they are retried once everything else has been played.
+type changeFailures struct {
+	mu       sync.Mutex
+	failures []*changeFailure
+}
+
+// recording wraps fn to record the changes that it fails to play.
+func (cf *changeFailures) recording(fn func(*Change) error) func(*Change) error {
+	return func(c *Change) error {
+		err := fn(c)
+		if err != nil {
+			cf.mu.Lock()
+			cf.failures = append(cf.failures, &changeFailure{change: c, fn: fn, err: err})
+			cf.mu.Unlock()
+		}
+		return err
+	}
+}
+
+type failuresByPlayOrder []*changeFailure
+
+func (fo failuresByPlayOrder) Len() int      { return len(fo) }
+func (fo failuresByPlayOrder) Swap(i, j int) { fo[i], fo[j] = fo[j], fo[i] }
+
+// Less orders deletions last and otherwise parents before their children.
+func (fo failuresByPlayOrder) Less(i, j int) bool {
+	iDel, jDel := fo[i].change.Op() == OpDelete, fo[j].change.Op() == OpDelete
+	if iDel != jDel {
+		return jDel
+	}
+	return fo[i].change.Path < fo[j].change.Path
+}
+
+// retryFailures plays the failed changes once more, one at a time and with
+// fresh backoffs, now that transient conditions such as rate limiting have
+// had time to pass. It returns an error listing each change that still
+// fails with its operation, path, file id and reason.
+func (g *Commands) retryFailures(verb string, cf *changeFailures) (err error) {
+	failures := cf.failures
+	if len(failures) < 1 {
+		return nil
+	}
+
+	sort.Sort(failuresByPlayOrder(failures))
+	g.log.LogErrf("%s: retrying %d failed changes\n", verb, len(failures))
+
+	var persistent []*changeFailure
+	for _, failure := range failures {
+		if g.rem.budget.exhausted() {
+			persistent = append(persistent, failure)
+			continue
+		}
+		if failure.err = failure.fn(failure.change); failure.err != nil {
+			persistent = append(persistent, failure)
+		}
+	}
+
+	if len(persistent) < 1 {
+		g.log.LogErrf("%s: all %d retries succeeded\n", verb, len(failures))
+		return nil
+	}
+
+	g.log.LogErrf("%s: %d changes failed:\n", verb, len(persistent))
+	for _, failure := range persistent {
+		failErr := changeErr(verb, failure.change, failure.err)
+		g.log.LogErrf("  %v\n", failErr)
+		err = combineErrors(err, failErr)
+	}
+	return err
+}
@@ -0,0 +1,64 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"errors"
+	"sort"
+	"testing"
+)
+
+func TestChangeFailuresRecording(t *testing.T) {
+	errFlaky := errors.New("flaky")
+	fn := func(c *Change) error {
+		switch c.Path {
+		case "/flaky":
+			return errFlaky
+		}
+		return nil
+	}
+
+	cf := &changeFailures{}
+	recording := cf.recording(fn)
+	for _, p := range []string{"/ok", "/flaky"} {
+		recording(&Change{Path: p})
+	}
+
+	if len(cf.failures) != 1 {
+		t.Fatalf("got %d failures want 1", len(cf.failures))
+	}
+	if failure := cf.failures[0]; failure.change.Path != "/flaky" || failure.err != errFlaky {
+		t.Errorf("got failure %s: %v", failure.change.Path, failure.err)
+	}
+}
+
+func TestFailuresByPlayOrder(t *testing.T) {
+	folder := &File{Name: "a", IsDir: true}
+	failures := []*changeFailure{
+		{change: &Change{Path: "/old", Dest: &File{Name: "old"}}},
+		{change: &Change{Path: "/a/b.txt", Src: &File{Name: "b.txt"}}},
+		{change: &Change{Path: "/a", Src: folder}},
+		{change: &Change{Path: "/c.txt", Src: &File{Name: "c.txt"}}},
+	}
+
+	sort.Sort(failuresByPlayOrder(failures))
+
+	want := []string{"/a", "/a/b.txt", "/c.txt", "/old"}
+	for i, failure := range failures {
+		if failure.change.Path != want[i] {
+			t.Errorf("#%d: got %s want %s", i, failure.change.Path, want[i])
+		}
+	}
+}
@@ -0,0 +1,177 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+
+	drive "google.golang.org/api/drive/v2"
+)
+
+// revisionTextMimeTypes are the textual export formats of the Google
+// document types in order of preference, e.g text for Docs and Slides
+// and CSV for Sheets, of which only the first sheet is exported.
+var revisionTextMimeTypes = []string{
+	"text/plain",
+	"text/csv",
+}
+
+func (r *Remote) revisions(fileId string) ([]*drive.Revision, error) {
+	res, err := r.service.Revisions.List(fileId).Do()
+	if err != nil {
+		return nil, err
+	}
+	return res.Items, nil
+}
+
+func (r *Remote) revision(fileId, revisionId string) (*drive.Revision, error) {
+	return r.service.Revisions.Get(fileId, revisionId).Do()
+}
+
+// revisionContentURL returns the url from which a textual rendition of
+// rev can be downloaded: a text export for Docs and Sheets, otherwise
+// the revision's own content.
+func revisionContentURL(rev *drive.Revision) (string, error) {
+	for _, mimeType := range revisionTextMimeTypes {
+		if exportURL, ok := rev.ExportLinks[mimeType]; ok {
+			return exportURL, nil
+		}
+	}
+	if rev.DownloadUrl != "" {
+		return rev.DownloadUrl, nil
+	}
+	return "", googleDocNonExportErr(fmt.Errorf("revision %s has no textual export", rev.Id))
+}
+
+// DiffRevisions shows the textual diff between two revisions of the file at
+// the source. Docs and Sheets are exported to text and CSV respectively.
+// Without any revisions, the file's revisions are listed instead.
+func (g *Commands) DiffRevisions(revisionIds []string) error {
+	if len(g.opts.Sources) != 1 {
+		return invalidArgumentsErr(fmt.Errorf("diff: expecting one path whose revisions to compare"))
+	}
+
+	relToRootPath := g.opts.Sources[0]
+	f, err := g.rem.FindByPath(relToRootPath)
+	if err != nil && err != ErrPathNotExists {
+		return err
+	}
+	if f == nil {
+		return nonExistantRemoteErr(fmt.Errorf("%s doesnot exist", customQuote(relToRootPath)))
+	}
+	if f.IsDir {
+		return illogicalStateErr(fmt.Errorf("%s: folders have no revisions", customQuote(relToRootPath)))
+	}
+
+	if len(revisionIds) < 1 {
+		return g.listRevisions(f)
+	}
+	if len(revisionIds) != 2 {
+		return invalidArgumentsErr(fmt.Errorf("diff: expecting two revisions to compare"))
+	}
+
+	diffProgPath, err := exec.LookPath("diff")
+	if err != nil {
+		return err
+	}
+
+	var tmpPaths []string
+	defer func() {
+		for _, tmpPath := range tmpPaths {
+			os.Remove(tmpPath)
+		}
+	}()
+
+	for _, revisionId := range revisionIds {
+		tmpPath, dErr := g.downloadRevision(f, revisionId)
+		if dErr != nil {
+			return dErr
+		}
+		tmpPaths = append(tmpPaths, tmpPath)
+	}
+
+	diffArgs := []string{"-u",
+		"--label", fmt.Sprintf("%s@%s", relToRootPath, revisionIds[0]),
+		"--label", fmt.Sprintf("%s@%s", relToRootPath, revisionIds[1]),
+		tmpPaths[0], tmpPaths[1],
+	}
+
+	diffCmd := exec.Command(diffProgPath, diffArgs...)
+	diffCmd.Stdout = os.Stdout
+	diffCmd.Stderr = os.Stderr
+
+	// Normally when elements differ diff returns a non-zero code
+	_ = diffCmd.Run()
+	return nil
+}
+
+func (g *Commands) listRevisions(f *File) error {
+	revs, err := g.rem.revisions(f.Id)
+	if err != nil {
+		return err
+	}
+
+	g.log.Logf("%-30s %-30s %s\n", "Revision", "Modified", "Modified by")
+	for _, rev := range revs {
+		g.log.Logf("%-30s %-30s %s\n", rev.Id, rev.ModifiedDate, rev.LastModifyingUserName)
+	}
+	return nil
+}
+
+// downloadRevision saves a textual rendition of the revision
+// to a temporary file, whose path it returns.
+func (g *Commands) downloadRevision(f *File, revisionId string) (string, error) {
+	rev, err := g.rem.revision(f.Id, revisionId)
+	if err != nil {
+		return "", err
+	}
+
+	contentURL, err := revisionContentURL(rev)
+	if err != nil {
+		return "", err
+	}
+
+	if rev.FileSize > MaxFileSize {
+		return "", contentTooLargeErr(fmt.Errorf("revision %s too large for display [%v bytes]", revisionId, rev.FileSize))
+	}
+
+	blob, err := g.rem.Download(f.Id, contentURL)
+	if err != nil {
+		return "", err
+	}
+	defer blob.Close()
+
+	tmpFile, err := ioutil.TempFile("", "drive-revision")
+	if err != nil {
+		return "", err
+	}
+	defer tmpFile.Close()
+
+	// Exports are unbounded, so cap them at the same limit as content.
+	n, err := io.Copy(tmpFile, io.LimitReader(blob, MaxFileSize+1))
+	if err == nil && n > MaxFileSize {
+		err = contentTooLargeErr(fmt.Errorf("revision %s too large for display", revisionId))
+	}
+	if err != nil {
+		os.Remove(tmpFile.Name())
+		return "", err
+	}
+
+	return tmpFile.Name(), nil
+}
@@ -0,0 +1,128 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	drive "google.golang.org/api/drive/v2"
+)
+
+func TestRevisionContentURL(t *testing.T) {
+	testCases := []struct {
+		desc string
+		rev  *drive.Revision
+		want string
+		code ErrorStatus
+	}{
+		{
+			desc: "a Doc is exported as text",
+			rev: &drive.Revision{ExportLinks: map[string]string{
+				"application/pdf": "pdf",
+				"text/plain":      "txt",
+			}},
+			want: "txt",
+		},
+		{
+			desc: "a Sheet is exported as CSV",
+			rev: &drive.Revision{ExportLinks: map[string]string{
+				"application/pdf": "pdf",
+				"text/csv":        "csv",
+			}},
+			want: "csv",
+		},
+		{
+			desc: "content is downloaded as is",
+			rev:  &drive.Revision{DownloadUrl: "content"},
+			want: "content",
+		},
+		{
+			desc: "a Drawing has no textual export",
+			rev: &drive.Revision{Id: "1", ExportLinks: map[string]string{
+				"image/png": "png",
+			}},
+			code: StatusGoogleDocNonExportAttempted,
+		},
+	}
+
+	for _, tc := range testCases {
+		got, err := revisionContentURL(tc.rev)
+		if tc.code != 0 {
+			if dErr, ok := err.(*Error); !ok || dErr.Code() != int(tc.code) {
+				t.Errorf("%s: got err %v want code %v", tc.desc, err, tc.code)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("%s: got %q, %v want %q", tc.desc, got, err, tc.want)
+		}
+	}
+}
+
+func TestDownloadRevision(t *testing.T) {
+	revisions := map[string]*drive.Revision{
+		"1": {Id: "1", ExportLinks: map[string]string{"text/plain": "https://export.example/1"}},
+		"2": {Id: "2", FileSize: MaxFileSize + 1, DownloadUrl: "https://export.example/2"},
+	}
+	transport := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		var body []byte
+		if req.URL.Host == "export.example" {
+			body = []byte("revision " + strings.TrimPrefix(req.URL.Path, "/"))
+		} else {
+			id := strings.TrimPrefix(req.URL.Path, "/drive/v2/files/doc/revisions/")
+			rev, ok := revisions[id]
+			if !ok {
+				t.Fatalf("unexpected request %s", req.URL)
+			}
+			var err error
+			if body, err = json.Marshal(rev); err != nil {
+				return nil, err
+			}
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       ioutil.NopCloser(bytes.NewReader(body)),
+			Request:    req,
+		}, nil
+	})
+	rem, err := remoteFromClient(&http.Client{Transport: transport})
+	if err != nil {
+		t.Fatalf("remoteFromClient: %v", err)
+	}
+	g := &Commands{rem: rem, opts: &Options{}}
+	f := &File{Id: "doc", Name: "doc"}
+
+	tmpPath, err := g.downloadRevision(f, "1")
+	if err != nil {
+		t.Fatalf("download: %v", err)
+	}
+	defer os.Remove(tmpPath)
+	if data, err := ioutil.ReadFile(tmpPath); err != nil || string(data) != "revision 1" {
+		t.Errorf("got %q, %v want the text export", data, err)
+	}
+
+	if _, err := g.downloadRevision(f, "2"); err == nil {
+		t.Errorf("expected a revision too large for display to be refused")
+	} else if dErr, ok := err.(*Error); !ok || dErr.Code() != int(StatusContentTooLarge) {
+		t.Errorf("got %v want a content too large error", err)
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mxk/go-flowrate/flowrate"
+)
+
+const (
+	rateWindowClockLayout = "15:04"
+
+	// rateScheduleRecheckInterval is how often an ongoing
+	// upload re-applies the limit for the current time.
+	rateScheduleRecheckInterval = time.Minute
+)
+
+type rateWindow struct {
+	// start and end are offsets from midnight.
+	start time.Duration
+	end   time.Duration
+	// limit is in KiB/s, 0 means unlimited.
+	limit int
+}
+
+func (rw *rateWindow) contains(offset time.Duration) bool {
+	if rw.start <= rw.end {
+		return offset >= rw.start && offset < rw.end
+	}
+	// The window wraps around midnight e.g 22:00-02:00
+	return offset >= rw.start || offset < rw.end
+}
+
+// RateSchedule maps times of the day to upload bandwidth limits.
+type RateSchedule struct {
+	windows []*rateWindow
+	// fallback is the limit in KiB/s outside of all the windows.
+	fallback int
+}
+
+// ParseRateSchedule parses a comma separated list of windows of the form
+// "HH:MM-HH:MM=limit" where limit is in KiB/s and 0 means unlimited
+// e.g "01:00-07:00=0,12:00-13:00=4096".
+// Outside of the listed windows, the fallback limit applies.
+// The first matching window wins.
+func ParseRateSchedule(spec string, fallback int) (*RateSchedule, error) {
+	rs := &RateSchedule{fallback: fallback}
+
+	for _, entry := range NonEmptyTrimmedStrings(strings.Split(spec, ",")...) {
+		splits := strings.SplitN(entry, "=", 2)
+		if len(splits) != 2 {
+			return nil, invalidArgumentsErr(fmt.Errorf("rate schedule: %q expecting HH:MM-HH:MM=limit", entry))
+		}
+
+		bounds := strings.SplitN(strings.TrimSpace(splits[0]), "-", 2)
+		if len(bounds) != 2 {
+			return nil, invalidArgumentsErr(fmt.Errorf("rate schedule: %q expecting a start and end time", entry))
+		}
+
+		start, err := clockOffset(bounds[0])
+		if err != nil {
+			return nil, err
+		}
+		end, err := clockOffset(bounds[1])
+		if err != nil {
+			return nil, err
+		}
+
+		limit, err := strconv.Atoi(strings.TrimSpace(splits[1]))
+		if err != nil || limit < 0 {
+			return nil, invalidArgumentsErr(fmt.Errorf("rate schedule: %q expecting a non-negative limit in KiB/s", entry))
+		}
+
+		rs.windows = append(rs.windows, &rateWindow{start: start, end: end, limit: limit})
+	}
+
+	return rs, nil
+}
+
+func clockOffset(clock string) (time.Duration, error) {
+	t, err := time.Parse(rateWindowClockLayout, strings.TrimSpace(clock))
+	if err != nil {
+		return 0, invalidArgumentsErr(fmt.Errorf("rate schedule: %q is not of the form HH:MM", clock))
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// LimitAt returns the upload limit in KiB/s that applies at t.
+func (rs *RateSchedule) LimitAt(t time.Time) int {
+	if rs == nil {
+		return 0
+	}
+
+	offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	for _, rw := range rs.windows {
+		if rw.contains(offset) {
+			return rw.limit
+		}
+	}
+	return rs.fallback
+}
+
+// scheduledReader re-applies the schedule's limit to the
+// throttled reader as the day progresses, so that long
+// uploads speed up or slow down as windows open and close.
+type scheduledReader struct {
+	*flowrate.Reader
+	schedule  *RateSchedule
+	nextCheck time.Time
+}
+
+func (sr *scheduledReader) Read(p []byte) (int, error) {
+	if now := time.Now(); !now.Before(sr.nextCheck) {
+		sr.SetLimit(int64(sr.schedule.LimitAt(now) * 1024))
+		sr.nextCheck = now.Add(rateScheduleRecheckInterval)
+	}
+	return sr.Reader.Read(p)
+}
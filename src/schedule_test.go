@@ -0,0 +1,63 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateScheduleLimitAt(t *testing.T) {
+	rs, err := ParseRateSchedule("01:00-07:00=0, 22:30-00:30=512", 1024)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	cases := []struct {
+		clock string
+		want  int
+	}{
+		{clock: "00:59", want: 1024},
+		{clock: "01:00", want: 0},
+		{clock: "06:59", want: 0},
+		{clock: "07:00", want: 1024},
+		{clock: "22:30", want: 512},
+		{clock: "00:15", want: 512},
+		{clock: "00:30", want: 1024},
+	}
+
+	for _, tc := range cases {
+		at, _ := time.Parse(rateWindowClockLayout, tc.clock)
+		if got := rs.LimitAt(at); got != tc.want {
+			t.Errorf("%s: got %d want %d", tc.clock, got, tc.want)
+		}
+	}
+}
+
+func TestParseRateScheduleErrors(t *testing.T) {
+	invalid := []string{
+		"01:00-07:00",
+		"01:00=0",
+		"1am-7am=0",
+		"01:00-07:00=-1",
+		"01:00-07:00=fast",
+	}
+
+	for _, spec := range invalid {
+		if _, err := ParseRateSchedule(spec, 0); err == nil {
+			t.Errorf("%q: expected an error", spec)
+		}
+	}
+}
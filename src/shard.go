@@ -0,0 +1,271 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	gopath "path"
+
+	drive "google.golang.org/api/drive/v2"
+)
+
+// shardPrefixLength is the number of hex digits of the SHA-1 of a file's
+// name that name its shard folder, fanning a folder out into 256 shards.
+const shardPrefixLength = 2
+
+// shardFor returns the name of the shard folder that a file named name
+// is kept in, inside of a sharded folder.
+func shardFor(name string) string {
+	sum := sha1.Sum([]byte(name))
+	return hex.EncodeToString(sum[:])[:shardPrefixLength]
+}
+
+// shardFolderPropertyKey marks the folders that push created as shards,
+// so that they are told apart from ordinary subfolders of a sharded folder.
+const shardFolderPropertyKey = "driveShardFolder"
+
+func isShardFolder(properties []*drive.Property) bool {
+	for _, property := range properties {
+		if property != nil && property.Key == shardFolderPropertyKey && property.Visibility == lockVisibility {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Remote) markShardFolder(fileId string) error {
+	property := &drive.Property{Key: shardFolderPropertyKey, Value: "true", Visibility: lockVisibility}
+	_, err := r.service.Properties.Insert(fileId, property).Do()
+	return err
+}
+
+// remoteMkdirShard is remoteMkdirAll for the shard folder at shardPath,
+// marking it as a shard folder if it isn't yet.
+func (g *Commands) remoteMkdirShard(shardPath string) (*File, error) {
+	mkdirAllMu.Lock()
+	defer mkdirAllMu.Unlock()
+
+	f, err := g.remoteMkdirAllLocked(shardPath)
+	if err != nil || f == nil || f.ShardFolder {
+		return f, err
+	}
+	if err := g.rem.markShardFolder(f.Id); err != nil {
+		return nil, err
+	}
+	f.ShardFolder = true
+	return f, nil
+}
+
+// isSharded reports whether the remote folder at relToRoot was set to
+// be sharded through Options.Shards, or was found to hold shard folders.
+func (g *Commands) isSharded(relToRoot string) bool {
+	p := gopath.Clean("/" + relToRoot)
+
+	g.shardsSeenMu.Lock()
+	seen := g.shardsSeen[p]
+	g.shardsSeenMu.Unlock()
+	if seen {
+		return true
+	}
+
+	if g.opts == nil {
+		return false
+	}
+	for _, shard := range g.opts.Shards {
+		if gopath.Clean("/"+shard) == p {
+			return true
+		}
+	}
+	return false
+}
+
+// sawShards records that the remote folder at relToRoot holds shard folders.
+func (g *Commands) sawShards(relToRoot string) {
+	g.shardsSeenMu.Lock()
+	defer g.shardsSeenMu.Unlock()
+
+	if g.shardsSeen == nil {
+		g.shardsSeen = make(map[string]bool)
+	}
+	g.shardsSeen[gopath.Clean("/"+relToRoot)] = true
+}
+
+// shardedPath maps the logical path of a file to the path that it is
+// kept at remotely, which differs only for files in sharded folders.
+func (g *Commands) shardedPath(relToRoot string) string {
+	dir, base := g.pathSplitter(relToRoot)
+	if base == "" || !g.isSharded(dir) {
+		return relToRoot
+	}
+	return gopath.Join(dir, shardFor(base), base)
+}
+
+// remoteMkdirParent creates the remote parent of the file at relToRoot,
+// which is its shard folder if the file is in a sharded folder.
+func (g *Commands) remoteMkdirParent(relToRoot string) (parent *File, parentPath string, err error) {
+	sharded := g.shardedPath(relToRoot)
+	parentPath = g.parentPather(sharded)
+	if sharded == relToRoot {
+		parent, err = g.remoteMkdirAll(parentPath)
+	} else {
+		parent, err = g.remoteMkdirShard(parentPath)
+	}
+	return
+}
+
+// findByPathM is FindByPathM that also looks for files in the shard
+// folder that they'd be kept in if their parent is sharded. Without
+// Options.Shards, that is only where a file isn't found in place and
+// the folder it would be kept in is marked as a shard folder.
+func (g *Commands) findByPathM(relToRoot string) *paginationPair {
+	if sharded := g.shardedPath(relToRoot); sharded != relToRoot {
+		if f, err := g.rem.FindByPath(sharded); err == nil && f != nil {
+			return singleFilePage(f)
+		}
+		return g.rem.FindByPathM(relToRoot)
+	}
+
+	pair := g.rem.FindByPathM(relToRoot)
+	errsChan := make(chan error)
+	filesChan := make(chan *File)
+
+	go func() {
+		defer close(filesChan)
+		defer close(errsChan)
+
+		var files []*File
+		var errs []error
+		for pair.filesChan != nil || pair.errsChan != nil {
+			select {
+			case err, ok := <-pair.errsChan:
+				if !ok {
+					pair.errsChan = nil
+				} else if err != nil {
+					errs = append(errs, err)
+				}
+			case f, ok := <-pair.filesChan:
+				if !ok {
+					pair.filesChan = nil
+				} else if f != nil {
+					files = append(files, f)
+				}
+			}
+		}
+
+		if len(files) < 1 {
+			if f := g.findInShard(relToRoot); f != nil {
+				files, errs = []*File{f}, nil
+			}
+		}
+		for _, f := range files {
+			filesChan <- f
+		}
+		for _, err := range errs {
+			errsChan <- err
+		}
+	}()
+
+	return &paginationPair{errsChan: errsChan, filesChan: filesChan}
+}
+
+// findInShard returns the file at relToRoot from the shard folder that it
+// is kept in, if its parent holds one, noting that the parent is sharded.
+func (g *Commands) findInShard(relToRoot string) *File {
+	dir, base := g.pathSplitter(relToRoot)
+	if base == "" {
+		return nil
+	}
+	shardPath := gopath.Join(dir, shardFor(base))
+	shard, err := g.rem.FindByPath(shardPath)
+	if err != nil || shard == nil || !shard.ShardFolder {
+		return nil
+	}
+	g.sawShards(dir)
+	f, err := g.rem.FindByPath(gopath.Join(shardPath, base))
+	if err != nil {
+		return nil
+	}
+	return f
+}
+
+// shardedChildren lists the children of the folder dirId at relToRoot as
+// if it were flat: the contents of its shard folders, if any, are listed
+// in place of the shard folders themselves. Folders found to hold shard
+// folders are then sharded, see isSharded.
+func (g *Commands) shardedChildren(dirId, relToRoot string) *paginationPair {
+	errsChan := make(chan error)
+	filesChan := make(chan *File)
+
+	go func() {
+		defer close(filesChan)
+		defer close(errsChan)
+
+		var shards []*File
+		err := drainPage(g.rem.FindByParentId(dirId, g.opts.Hidden), func(f *File) {
+			if f.IsDir && f.ShardFolder {
+				shards = append(shards, f)
+			} else {
+				filesChan <- f
+			}
+		})
+
+		if len(shards) > 0 {
+			g.sawShards(relToRoot)
+		}
+		for _, shard := range shards {
+			if err != nil {
+				break
+			}
+			err = drainPage(g.rem.FindByParentId(shard.Id, g.opts.Hidden), func(f *File) {
+				filesChan <- f
+			})
+		}
+
+		if err != nil {
+			errsChan <- err
+		}
+	}()
+
+	return &paginationPair{errsChan: errsChan, filesChan: filesChan}
+}
+
+func singleFilePage(f *File) *paginationPair {
+	errsChan := make(chan error)
+	filesChan := make(chan *File, 1)
+	filesChan <- f
+	close(filesChan)
+	close(errsChan)
+	return &paginationPair{errsChan: errsChan, filesChan: filesChan}
+}
+
+// drainPage invokes fn on every file of pair, stopping at the first error.
+func drainPage(pair *paginationPair, fn func(*File)) error {
+	for {
+		select {
+		case err := <-pair.errsChan:
+			if err != nil {
+				return err
+			}
+		case f, ok := <-pair.filesChan:
+			if !ok {
+				return nil
+			}
+			if f != nil {
+				fn(f)
+			}
+		}
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"net/http"
+	"sort"
+	"testing"
+
+	drive "google.golang.org/api/drive/v2"
+)
+
+func TestShardFor(t *testing.T) {
+	seen := map[string]bool{}
+	for _, name := range []string{"a.jpg", "b.jpg", "IMG_0001.CR2", "notes.txt", ""} {
+		shard := shardFor(name)
+		if len(shard) != shardPrefixLength {
+			t.Errorf("shardFor(%q) = %q is not a shard folder name", name, shard)
+		}
+		if again := shardFor(name); again != shard {
+			t.Errorf("shardFor(%q) is unstable: %q then %q", name, shard, again)
+		}
+		seen[shard] = true
+	}
+
+	if len(seen) < 2 {
+		t.Errorf("expected names to spread across shards, got %v", seen)
+	}
+}
+
+func TestIsShardFolder(t *testing.T) {
+	testCases := []struct {
+		properties []*drive.Property
+		want       bool
+	}{
+		{properties: nil, want: false},
+		{properties: []*drive.Property{{Key: shardFolderPropertyKey, Value: "true", Visibility: lockVisibility}}, want: true},
+		{properties: []*drive.Property{{Key: shardFolderPropertyKey, Value: "true", Visibility: "PRIVATE"}}, want: false},
+		{properties: []*drive.Property{nil, {Key: lockOwnerPropertyKey, Value: "a@example.com", Visibility: lockVisibility}}, want: false},
+	}
+
+	for i, tc := range testCases {
+		if got := isShardFolder(tc.properties); got != tc.want {
+			t.Errorf("#%d: isShardFolder = %v want %v", i, got, tc.want)
+		}
+	}
+}
+
+func TestShardedPath(t *testing.T) {
+	g := &Commands{opts: &Options{Shards: []string{"photos/raw", "/scans/"}}}
+
+	testCases := []struct {
+		path string
+		want string
+	}{
+		{path: "/photos/raw/a.jpg", want: "/photos/raw/" + shardFor("a.jpg") + "/a.jpg"},
+		{path: "/scans/page1.pdf", want: "/scans/" + shardFor("page1.pdf") + "/page1.pdf"},
+		{path: "/photos/a.jpg", want: "/photos/a.jpg"},
+		{path: "/photos/raw/2016/a.jpg", want: "/photos/raw/2016/a.jpg"},
+		{path: "/photos/raw", want: "/photos/raw"},
+	}
+
+	for _, tc := range testCases {
+		if got := g.shardedPath(tc.path); got != tc.want {
+			t.Errorf("shardedPath(%q) = %q want %q", tc.path, got, tc.want)
+		}
+	}
+
+	if g := (&Commands{opts: &Options{}}); g.shardedPath("/photos/raw/a.jpg") != "/photos/raw/a.jpg" {
+		t.Errorf("expected paths to be left alone without shards")
+	}
+}
+
+func TestShardsDetectedFromMarker(t *testing.T) {
+	shard := shardFor("x.jpg")
+	marker := []*drive.Property{{Key: shardFolderPropertyKey, Value: "true", Visibility: lockVisibility}}
+	files := map[string]*drive.File{
+		"photos": {Id: "photos", Title: "photos", MimeType: DriveFolderMimeType},
+		"shard":  {Id: "shard", Title: shard, MimeType: DriveFolderMimeType, Properties: marker},
+		"raw":    {Id: "raw", Title: "raw", MimeType: DriveFolderMimeType},
+		"c":      {Id: "c", Title: "c.txt", MimeType: "text/plain"},
+		"x":      {Id: "x", Title: "x.jpg", MimeType: "image/jpeg"},
+	}
+	children := map[string][]string{
+		"root":   {"photos"},
+		"photos": {"shard", "raw", "c"},
+		"shard":  {"x"},
+	}
+
+	rem, err := remoteFromClient(&http.Client{Transport: fakeTreeTransport(t, files, children)})
+	if err != nil {
+		t.Fatalf("remoteFromClient: %v", err)
+	}
+
+	// Listing a folder flattens its shard folders, without -shard.
+	g := &Commands{rem: rem, opts: &Options{}}
+	var names []string
+	if err := drainPage(g.shardedChildren("photos", "/photos"), func(f *File) { names = append(names, f.Name) }); err != nil {
+		t.Fatalf("shardedChildren: %v", err)
+	}
+	sort.Strings(names)
+	if want := []string{"c.txt", "raw", "x.jpg"}; len(names) != len(want) || names[0] != want[0] || names[1] != want[1] || names[2] != want[2] {
+		t.Errorf("children: got %v want %v", names, want)
+	}
+	if !g.isSharded("/photos") {
+		t.Errorf("expected /photos to be found sharded")
+	}
+	if g.isSharded("/photos/raw") {
+		t.Errorf("expected an ordinary subfolder not to be sharded")
+	}
+	if got := g.shardedPath("/photos/new.jpg"); got != "/photos/"+shardFor("new.jpg")+"/new.jpg" {
+		t.Errorf("expected new files to be pushed into their shard, got %q", got)
+	}
+
+	// A file named by its logical path is found in its shard.
+	g = &Commands{rem: rem, opts: &Options{}}
+	var found []string
+	if err := drainPage(g.findByPathM("/photos/x.jpg"), func(f *File) { found = append(found, f.Id) }); err != nil {
+		t.Fatalf("findByPathM: %v", err)
+	}
+	if len(found) != 1 || found[0] != "x" {
+		t.Errorf("findByPathM: got %v want [x]", found)
+	}
+	if !g.isSharded("/photos") {
+		t.Errorf("expected /photos to be found sharded by the lookup")
+	}
+
+	found = nil
+	err = drainPage(g.findByPathM("/photos/missing.jpg"), func(f *File) { found = append(found, f.Id) })
+	if len(found) != 0 {
+		t.Errorf("missing file: got %v, %v", found, err)
+	}
+}
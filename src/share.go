@@ -16,6 +16,7 @@ package drive
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 	"sync"
 
@@ -276,18 +277,26 @@ func (c *Commands) playShareChanges(change *shareChange) (err error) {
 	}
 
 	fnName := "unshare"
-	fn := c.rem.revokePermissions
+	apply := func(ac *aclChange) (*appliedACL, error) {
+		deleted, err := c.rem.revokePermissions(ac.perm)
+		if len(deleted) < 1 {
+			return nil, err
+		}
+		return &appliedACL{fileId: ac.perm.fileId, name: ac.name, deleted: deleted}, err
+	}
 
 	if !change.revoke {
 		fnName = "share"
-		fn = func(perm *permission) error {
-			_, err := c.rem.insertPermissions(perm)
-			return err
+		apply = func(ac *aclChange) (*appliedACL, error) {
+			inserted, err := c.rem.insertPermissions(ac.perm)
+			if err != nil {
+				return nil, err
+			}
+			return &appliedACL{fileId: ac.perm.fileId, name: ac.name, inserted: inserted}, nil
 		}
 	}
 
-	successes := 0
-
+	var changes []*aclChange
 	for _, file := range change.files {
 		for _, accountType := range change.accountTypes {
 			for _, email := range change.emails {
@@ -308,34 +317,52 @@ func (c *Commands) playShareChanges(change *shareChange) (err error) {
 						message:  change.emailMessage,
 						withLink: change.withLink,
 					}
-
-					if ferr := fn(&perm); ferr != nil {
-						err = reComposeError(err, fmt.Sprintf("%s err %s: %v\n", fnName, file.Name, ferr))
-					} else {
-						successes += 1
-						if c.opts.Verbose {
-							c.log.Logf("successful %s for %s with email %q, role %q accountType %q\n",
-								fnName, file.Name, email, role.String(), accountType.String())
-						}
-					}
+					changes = append(changes, &aclChange{name: file.Name, perm: &perm})
 				}
 			}
 		}
 	}
 
-	if err != nil {
-		return err
-	}
+	return c.playACLBatch(fnName, changes, apply)
+}
 
-	if successes < 1 {
-		return noMatchesFoundErr(fmt.Errorf("no matches found!"))
-	}
+// withDescendants returns files along with everything under those of
+// them that are folders, for permissions to be applied to whole trees.
+func (c *Commands) withDescendants(files []*File) ([]*File, error) {
+	var expanded []*File
+	for _, file := range files {
+		if file == nil {
+			continue
+		}
+		expanded = append(expanded, file)
+		if !file.IsDir {
+			continue
+		}
 
-	return nil
+		tree := map[string]*File{}
+		if err := c.remoteTree(file, "", tree); err != nil {
+			return nil, err
+		}
+
+		var relPaths []string
+		for relPath := range tree {
+			relPaths = append(relPaths, relPath)
+		}
+		sort.Strings(relPaths)
+		for _, relPath := range relPaths {
+			expanded = append(expanded, tree[relPath])
+		}
+	}
+	return expanded, nil
 }
 
 func (c *Commands) share(revoke, byId bool) (err error) {
 	files := c.resolveRemotePaths(c.opts.Sources, byId)
+	if c.opts.Recursive {
+		if files, err = c.withDescendants(files); err != nil {
+			return err
+		}
+	}
 
 	var emails []string
 	var emailMessage string
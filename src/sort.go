@@ -224,6 +224,9 @@ func (g *Commands) sort(fl []*File, attrStrValues ...string) []*File {
 			g.log.LogErrf("%s is an unknown sort attribute\n", attrStr)
 			continue
 		}
+		if attrEnum == AttrName && localeCollated(g.opts) {
+			sortInterface = newLocaleNameFlist(fl)
+		}
 
 		if reverse {
 			sortInterface = sort.Reverse(sortInterface)
@@ -0,0 +1,96 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"io"
+)
+
+// sparseBlockSize is the granularity at which runs of zeros are
+// skipped rather than written, that of most filesystems' blocks.
+const sparseBlockSize = 4096
+
+// writeSeekTruncater is satisfied by *os.File.
+type writeSeekTruncater interface {
+	io.WriteSeeker
+	Truncate(size int64) error
+}
+
+// sparseWriter seeks over blocks of zeros instead of writing them so
+// that, on filesystems that support it, they are left as holes. Its
+// finish must be called once all content is written so that a file
+// ending in zeros gets its full size.
+type sparseWriter struct {
+	w      writeSeekTruncater
+	offset int64
+}
+
+func newSparseWriter(w writeSeekTruncater) *sparseWriter {
+	return &sparseWriter{w: w}
+}
+
+func allZeros(p []byte) bool {
+	for _, b := range p {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (sw *sparseWriter) Write(p []byte) (n int, err error) {
+	// Blocks are aligned to the offset in the file, so a
+	// buffer straddling a block boundary is split on it.
+	for len(p) > 0 {
+		blockLen := sparseBlockSize - int(sw.offset%sparseBlockSize)
+		if blockLen > len(p) {
+			blockLen = len(p)
+		}
+
+		// Coalesce consecutive blocks that are alike into one call.
+		zeros := allZeros(p[:blockLen])
+		runLen := blockLen
+		for runLen < len(p) {
+			next := runLen + sparseBlockSize
+			if next > len(p) {
+				next = len(p)
+			}
+			if allZeros(p[runLen:next]) != zeros {
+				break
+			}
+			runLen = next
+		}
+
+		if zeros {
+			if _, err = sw.w.Seek(int64(runLen), io.SeekCurrent); err != nil {
+				return n, err
+			}
+		} else if runLen, err = sw.w.Write(p[:runLen]); err != nil {
+			sw.offset += int64(runLen)
+			return n + runLen, err
+		}
+
+		sw.offset += int64(runLen)
+		n += runLen
+		p = p[runLen:]
+	}
+	return n, nil
+}
+
+// finish sets the size of the file to that of all the content
+// written, including any trailing zeros that were skipped.
+func (sw *sparseWriter) finish() error {
+	return sw.w.Truncate(sw.offset)
+}
@@ -0,0 +1,97 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+// countingFile records how many bytes were actually written to it.
+type countingFile struct {
+	*os.File
+	written int64
+}
+
+func (cf *countingFile) Write(p []byte) (int, error) {
+	n, err := cf.File.Write(p)
+	cf.written += int64(n)
+	return n, err
+}
+
+func TestSparseWriter(t *testing.T) {
+	block := func(b byte, n int) []byte { return bytes.Repeat([]byte{b}, n) }
+	join := func(parts ...[]byte) []byte { return bytes.Join(parts, nil) }
+
+	testCases := []struct {
+		content     []byte
+		bufSize     int
+		wantWritten int64
+	}{
+		{content: nil, bufSize: 1024, wantWritten: 0},
+		{content: block(0, 3*sparseBlockSize), bufSize: 1000, wantWritten: 0},
+		{content: block('a', sparseBlockSize+10), bufSize: 1000, wantWritten: sparseBlockSize + 10},
+		{
+			content:     join(block('a', 100), block(0, 3*sparseBlockSize), block('b', 50)),
+			bufSize:     sparseBlockSize,
+			wantWritten: sparseBlockSize + 150,
+		},
+		{
+			content:     join(block('a', sparseBlockSize), block(0, 2*sparseBlockSize)),
+			bufSize:     3 * sparseBlockSize,
+			wantWritten: sparseBlockSize,
+		},
+		{
+			// Zeros within a block that isn't all zeros are written.
+			content:     join(block('a', 10), block(0, sparseBlockSize-20), block('b', 10)),
+			bufSize:     2 * sparseBlockSize,
+			wantWritten: sparseBlockSize,
+		},
+	}
+
+	for i, tc := range testCases {
+		f, err := ioutil.TempFile("", "sparse")
+		if err != nil {
+			t.Fatal(err)
+		}
+		cf := &countingFile{File: f}
+
+		sw := newSparseWriter(cf)
+		_, err = io.CopyBuffer(struct{ io.Writer }{sw}, bytes.NewReader(tc.content), make([]byte, tc.bufSize))
+		if err == nil {
+			err = sw.finish()
+		}
+		f.Close()
+		if err != nil {
+			os.Remove(f.Name())
+			t.Fatalf("#%d: %v", i, err)
+		}
+
+		got, err := ioutil.ReadFile(f.Name())
+		os.Remove(f.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, tc.content) {
+			t.Errorf("#%d: content differs: got %d bytes want %d", i, len(got), len(tc.content))
+		}
+		if cf.written != tc.wantWritten {
+			t.Errorf("#%d: wrote %d bytes, want %d", i, cf.written, tc.wantWritten)
+		}
+	}
+}
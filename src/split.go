@@ -0,0 +1,317 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/odeke-em/statos"
+)
+
+const (
+	// SplitPartsFolder at the root of the remote holds the parts of
+	// files pushed with -split-size. Push and pull leave it alone.
+	SplitPartsFolder     = ".drive-split-parts"
+	SplitPartsFolderPath = "/" + SplitPartsFolder
+
+	SplitManifestMimeType = "application/json"
+
+	// splitDescriptionPrefix marks a remote file as the manifest of a
+	// split file, and is followed by the original size and checksum so
+	// that comparisons needn't download the manifest.
+	splitDescriptionPrefix = "drive-split:"
+)
+
+var errSplitWithEncryption = errors.New("split: files can't be both split and encrypted")
+
+type splitPart struct {
+	Id          string `json:"id"`
+	Size        int64  `json:"size"`
+	Md5Checksum string `json:"md5Checksum"`
+}
+
+// splitManifest is the content of the remote file standing in for a
+// file that was pushed in parts.
+type splitManifest struct {
+	Name          string       `json:"name"`
+	Size          int64        `json:"size"`
+	Md5Checksum   string       `json:"md5Checksum"`
+	ModTime       time.Time    `json:"modTime"`
+	PartsFolderId string       `json:"partsFolderId"`
+	Parts         []*splitPart `json:"parts"`
+}
+
+func splitDescription(size int64, md5Checksum string) string {
+	return fmt.Sprintf("%ssize=%d md5=%s", splitDescriptionPrefix, size, md5Checksum)
+}
+
+// parseSplitDescription returns the original size and checksum of a
+// split file from its manifest's description.
+func parseSplitDescription(description string) (size int64, md5Checksum string, ok bool) {
+	if !strings.HasPrefix(description, splitDescriptionPrefix) {
+		return 0, "", false
+	}
+
+	for _, field := range strings.Fields(strings.TrimPrefix(description, splitDescriptionPrefix)) {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			return 0, "", false
+		}
+		switch kv[0] {
+		case "size":
+			n, err := strconv.ParseInt(kv[1], 10, 64)
+			if err != nil || n < 0 {
+				return 0, "", false
+			}
+			size = n
+		case "md5":
+			md5Checksum = kv[1]
+		}
+	}
+	return size, md5Checksum, md5Checksum != ""
+}
+
+// splitSizes returns the sizes of the parts that a file of the given
+// size is split into, each at most partSize.
+func splitSizes(size, partSize int64) []int64 {
+	if partSize < 1 {
+		return []int64{size}
+	}
+	var sizes []int64
+	for size > partSize {
+		sizes = append(sizes, partSize)
+		size -= partSize
+	}
+	return append(sizes, size)
+}
+
+func (g *Commands) shouldSplit(change *Change) bool {
+	src := change.Src
+	return g.opts.SplitSize > 0 && src != nil && !src.IsDir && src.Size > g.opts.SplitSize
+}
+
+func (g *Commands) splitUpsertOpt(parentId string, src, dest *File) *upsertOpt {
+	return &upsertOpt{
+		uploadChunkSize:    g.opts.UploadChunkSize,
+		uploadRateLimit:    g.opts.UploadRateLimit,
+		uploadRateSchedule: g.opts.UploadRateSchedule,
+		background:         g.opts.Background,
+		parentId:           parentId,
+		src:                src,
+		dest:               dest,
+		mask:               g.opts.TypeMask,
+		nonStatable:        true,
+		debug:              g.opts.Verbose && g.opts.canPreview(),
+		retryCount:         g.opts.ExponentialBackoffRetryCount,
+	}
+}
+
+// remoteSplitUpsert pushes change.Src in parts of at most SplitSize,
+// then the manifest listing them under the file's own name. The parts
+// of any earlier version are removed once the new manifest is in place.
+func (g *Commands) remoteSplitUpsert(change *Change, parent *File) (*File, error) {
+	if g.opts.CryptoEnabled() {
+		return nil, errSplitWithEncryption
+	}
+
+	src := change.Src
+	fsAbsPath := src.BlobAt
+	if fsAbsPath == "" {
+		fsAbsPath = g.context.AbsPathOf(change.Path)
+	}
+
+	f, err := os.Open(fsAbsPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	partsFolderPath := remotePathJoin(SplitPartsFolderPath, fmt.Sprintf("%s-%d", src.Name, time.Now().UnixNano()))
+	partsFolder, err := g.remoteMkdirAll(partsFolderPath)
+	if err != nil {
+		return nil, err
+	}
+	if partsFolder == nil {
+		return nil, errCannotMkdirAll(partsFolderPath)
+	}
+
+	manifest := &splitManifest{
+		Name:          src.Name,
+		Size:          src.Size,
+		ModTime:       src.ModTime,
+		PartsFolderId: partsFolder.Id,
+	}
+
+	whole := md5.New()
+	var body io.Reader = io.TeeReader(f, whole)
+	if g.opts.Background {
+		body = newPacedReader(body)
+	}
+
+	for i, partSize := range splitSizes(src.Size, g.opts.SplitSize) {
+		partSrc := fauxLocalFile(fmt.Sprintf("part-%05d", i))
+		partSrc.Size = partSize
+
+		bd := statos.NewReader(io.LimitReader(body, partSize))
+		go func() {
+			for n := range bd.ProgressChan() {
+				g.rem.progressChan <- n
+			}
+		}()
+
+		part, _, pErr := g.rem.upsertByComparison(bd, g.splitUpsertOpt(partsFolder.Id, partSrc, nil))
+		if pErr != nil {
+			g.rem.Trash(partsFolder.Id)
+			return nil, pErr
+		}
+		manifest.Parts = append(manifest.Parts, &splitPart{Id: part.Id, Size: part.Size, Md5Checksum: part.Md5Checksum})
+	}
+
+	manifest.Md5Checksum = hex.EncodeToString(whole.Sum(nil))
+	blob, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	// The previous manifest, if any, names the parts to replace.
+	var stale *splitManifest
+	if change.Dest != nil && change.Dest.Split {
+		if stale, err = g.readSplitManifest(change.Dest.Id); err != nil {
+			g.log.LogErrf("split: %s: reading the previous manifest %v\n", change.Path, err)
+		}
+	}
+
+	manifestSrc := DupFile(src)
+	manifestSrc.Id = ""
+	if change.Dest != nil {
+		manifestSrc.Id = change.Dest.Id
+	}
+	manifestSrc.MimeType = SplitManifestMimeType
+
+	args := g.splitUpsertOpt(parent.Id, manifestSrc, change.Dest)
+	args.description = splitDescription(manifest.Size, manifest.Md5Checksum)
+
+	rem, _, err := g.rem.upsertByComparison(bytes.NewReader(blob), args)
+	if err != nil {
+		g.rem.Trash(partsFolder.Id)
+		return nil, err
+	}
+
+	if stale != nil && stale.PartsFolderId != "" {
+		if err := g.rem.Trash(stale.PartsFolderId); err != nil {
+			g.log.LogErrf("split: %s: trashing the previous parts %v\n", change.Path, err)
+		}
+	}
+	return rem, nil
+}
+
+func (g *Commands) readSplitManifest(id string) (*splitManifest, error) {
+	blob, err := g.rem.Download(id, "")
+	if err != nil {
+		return nil, err
+	}
+	defer blob.Close()
+
+	manifest := &splitManifest{}
+	if err := json.NewDecoder(blob).Decode(manifest); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// splitDownload reassembles the split file that src is the manifest of
+// into destAbsPath, verifying it against the original checksum.
+func (g *Commands) splitDownload(src *File, destAbsPath string) (err error) {
+	manifest, err := g.readSplitManifest(src.Id)
+	if err != nil {
+		return err
+	}
+
+	tmpPath := destAbsPath + ".drive-split"
+	fo, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cErr := fo.Close(); err == nil {
+			err = cErr
+		}
+		if err != nil {
+			os.Remove(tmpPath)
+			return
+		}
+		err = os.Rename(tmpPath, destAbsPath)
+	}()
+
+	var dst io.Writer = fo
+	var sw *sparseWriter
+	if g.opts.Sparse {
+		sw = newSparseWriter(fo)
+		dst = sw
+	}
+
+	whole := md5.New()
+	ws := statos.NewWriter(io.MultiWriter(dst, whole))
+	go func() {
+		for n := range ws.ProgressChan() {
+			g.rem.progressChan <- n
+		}
+	}()
+
+	for i, part := range manifest.Parts {
+		blob, dErr := g.rem.Download(part.Id, "")
+		if dErr != nil {
+			return fmt.Errorf("split: part %d of %s: %v", i, src.Name, dErr)
+		}
+		n, cErr := io.Copy(ws, blob)
+		blob.Close()
+		if cErr != nil {
+			return cErr
+		}
+		if n != part.Size {
+			return fmt.Errorf("split: part %d of %s: got %d bytes, expected %d", i, src.Name, n, part.Size)
+		}
+	}
+
+	if got := hex.EncodeToString(whole.Sum(nil)); got != manifest.Md5Checksum {
+		return fmt.Errorf("split: %s: reassembled checksum %s doesn't match %s", src.Name, got, manifest.Md5Checksum)
+	}
+	if sw != nil {
+		return sw.finish()
+	}
+	return nil
+}
+
+// removeSplitParts removes the parts of a split file whose manifest
+// was removed with remove, e.g Trash or Delete.
+func (g *Commands) removeSplitParts(manifestFile *File, remove func(string) error) {
+	manifest, err := g.readSplitManifest(manifestFile.Id)
+	if err == nil && manifest.PartsFolderId != "" {
+		err = remove(manifest.PartsFolderId)
+	}
+	if err != nil {
+		g.log.LogErrf("split: %s: removing its parts %v\n", manifestFile.Name, err)
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitDescription(t *testing.T) {
+	testCases := []struct {
+		description string
+		size        int64
+		md5Checksum string
+		ok          bool
+	}{
+		{description: splitDescription(1<<40, "d41d8cd98f00b204e9800998ecf8427e"), size: 1 << 40, md5Checksum: "d41d8cd98f00b204e9800998ecf8427e", ok: true},
+		{description: splitDescription(0, "abc"), size: 0, md5Checksum: "abc", ok: true},
+		{description: "", ok: false},
+		{description: "holiday photos", ok: false},
+		{description: "drive-split:size=10", ok: false},
+		{description: "drive-split:size=ten md5=abc", ok: false},
+		{description: "drive-split:size=-1 md5=abc", ok: false},
+		{description: "drive-split:size=10 md5", ok: false},
+	}
+
+	for i, tc := range testCases {
+		size, md5Checksum, ok := parseSplitDescription(tc.description)
+		if ok != tc.ok {
+			t.Errorf("#%d: %q ok: got %v want %v", i, tc.description, ok, tc.ok)
+			continue
+		}
+		if ok && (size != tc.size || md5Checksum != tc.md5Checksum) {
+			t.Errorf("#%d: %q: got (%d, %q) want (%d, %q)", i, tc.description, size, md5Checksum, tc.size, tc.md5Checksum)
+		}
+	}
+}
+
+func TestSplitSizes(t *testing.T) {
+	testCases := []struct {
+		size, partSize int64
+		want           []int64
+	}{
+		{size: 10, partSize: 4, want: []int64{4, 4, 2}},
+		{size: 12, partSize: 4, want: []int64{4, 4, 4}},
+		{size: 3, partSize: 4, want: []int64{3}},
+		{size: 10, partSize: 0, want: []int64{10}},
+	}
+
+	for i, tc := range testCases {
+		got := splitSizes(tc.size, tc.partSize)
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("#%d: splitSizes(%d, %d) = %v want %v", i, tc.size, tc.partSize, got, tc.want)
+		}
+	}
+}
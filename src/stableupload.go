@@ -0,0 +1,126 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"time"
+)
+
+var ErrModifiedDuringUpload = errors.New("modified while it was being uploaded, requeued")
+
+func isModifiedDuringUpload(err error) bool {
+	for err != nil && err != ErrModifiedDuringUpload {
+		switch e := err.(type) {
+		case *url.Error:
+			err = e.Err
+		case *Error:
+			err = e.err
+		default:
+			return false
+		}
+	}
+	return err == ErrModifiedDuringUpload
+}
+
+// stableReader reads a local file for an upload and fails with
+// ErrModifiedDuringUpload, instead of reaching io.EOF, if the file
+// changed since it was opened. Failing before io.EOF keeps the upload
+// from being finalized with what could be a corrupt mix of contents.
+type stableReader struct {
+	io.Reader
+	path    string
+	size    int64
+	modTime time.Time
+	read    int64
+}
+
+func newStableReader(r io.Reader, path string, fi os.FileInfo) *stableReader {
+	return &stableReader{Reader: r, path: path, size: fi.Size(), modTime: fi.ModTime()}
+}
+
+func (sr *stableReader) Read(p []byte) (int, error) {
+	n, err := sr.Reader.Read(p)
+	sr.read += int64(n)
+	if sr.read > sr.size {
+		return n, ErrModifiedDuringUpload
+	}
+	if err == io.EOF && !sr.unchanged() {
+		return n, ErrModifiedDuringUpload
+	}
+	return n, err
+}
+
+func (sr *stableReader) unchanged() bool {
+	fi, err := os.Stat(sr.path)
+	if err != nil {
+		return false
+	}
+	return sr.read == sr.size && fi.Size() == sr.size && fi.ModTime().Equal(sr.modTime)
+}
+
+// snapshotToTemp copies the local file at fsAbsPath, as opened by open,
+// into a temporary file in dir, for its upload to be consistent even if
+// the file keeps changing. The copy fails with ErrModifiedDuringUpload if
+// the file changed while it was being copied. Removing the copy is up to
+// the caller.
+func snapshotToTemp(fsAbsPath, dir string, open func(string) (*os.File, error)) (*os.File, error) {
+	src, err := open(fsAbsPath)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	fi, err := src.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot, err := ioutil.TempFile(dir, "upload-snapshot")
+	if err != nil {
+		return nil, err
+	}
+
+	discard := func(err error) (*os.File, error) {
+		snapshot.Close()
+		os.Remove(snapshot.Name())
+		return nil, err
+	}
+
+	if _, err := io.Copy(snapshot, newStableReader(src, src.Name(), fi)); err != nil {
+		return discard(err)
+	}
+	if _, err := snapshot.Seek(0, 0); err != nil {
+		return discard(err)
+	}
+	return snapshot, nil
+}
+
+// refreshAfterModification updates the metadata of src, modified during
+// its upload, from its local file so that its requeued upload goes out
+// with the size and modification time of what it then reads.
+func refreshAfterModification(src *File, fsAbsPath string) {
+	fi, err := os.Stat(fsAbsPath)
+	if err != nil {
+		return
+	}
+	src.Size = fi.Size()
+	src.ModTime = fi.ModTime()
+	src.Md5Checksum = ""
+}
@@ -0,0 +1,176 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestIsModifiedDuringUpload(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{err: nil, want: false},
+		{err: ErrModifiedDuringUpload, want: true},
+		{err: &url.Error{Op: "Post", URL: "https://www.googleapis.com/upload", Err: ErrModifiedDuringUpload}, want: true},
+		{err: ErrBudgetExhausted, want: false},
+		{err: &url.Error{Op: "Post", URL: "https://www.googleapis.com/upload", Err: ErrBudgetExhausted}, want: false},
+	}
+
+	for i, tc := range cases {
+		if got := isModifiedDuringUpload(tc.err); got != tc.want {
+			t.Errorf("#%d: %v: got %v want %v", i, tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestStableReader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stableupload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cases := []struct {
+		desc   string
+		modify func(p string) error
+		want   error
+	}{
+		{
+			desc:   "unchanged",
+			modify: func(p string) error { return nil },
+		},
+		{
+			desc: "appended to",
+			modify: func(p string) error {
+				f, err := os.OpenFile(p, os.O_APPEND|os.O_WRONLY, 0644)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				_, err = f.WriteString("more")
+				return err
+			},
+			want: ErrModifiedDuringUpload,
+		},
+		{
+			desc: "rewritten in place",
+			modify: func(p string) error {
+				if err := ioutil.WriteFile(p, []byte("0123456789"), 0644); err != nil {
+					return err
+				}
+				later := time.Now().Add(time.Hour)
+				return os.Chtimes(p, later, later)
+			},
+			want: ErrModifiedDuringUpload,
+		},
+		{
+			desc:   "removed",
+			modify: os.Remove,
+			want:   ErrModifiedDuringUpload,
+		},
+	}
+
+	for _, tc := range cases {
+		p := filepath.Join(dir, "file")
+		if err := ioutil.WriteFile(p, []byte("abcdefghij"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fi, err := f.Stat()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sr := newStableReader(f, p, fi)
+		head := make([]byte, 4)
+		if _, err := sr.Read(head); err != nil {
+			t.Fatalf("%s: %v", tc.desc, err)
+		}
+		if err := tc.modify(p); err != nil {
+			t.Fatalf("%s: %v", tc.desc, err)
+		}
+		_, err = ioutil.ReadAll(sr)
+		f.Close()
+		if err != tc.want {
+			t.Errorf("%s: got %v want %v", tc.desc, err, tc.want)
+		}
+	}
+}
+
+func TestSnapshotToTemp(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stableupload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	content := bytes.Repeat([]byte("drive"), 1000)
+	p := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(p, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot, err := snapshotToTemp(p, dir, os.Open)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(snapshot.Name())
+	defer snapshot.Close()
+
+	if filepath.Dir(snapshot.Name()) != dir {
+		t.Errorf("snapshot %s is not in %s", snapshot.Name(), dir)
+	}
+	copied, err := ioutil.ReadAll(snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(copied, content) {
+		t.Errorf("snapshot has %d bytes that differ from the original's %d", len(copied), len(content))
+	}
+}
+
+func TestRefreshAfterModification(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stableupload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	p := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(p, []byte("grown since"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Unix(1500000000, 0)
+	if err := os.Chtimes(p, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	src := &File{Name: "file", Size: 5, ModTime: time.Unix(1400000000, 0), Md5Checksum: "stale"}
+	refreshAfterModification(src, p)
+	if src.Size != 11 || !src.ModTime.Equal(mtime) || src.Md5Checksum != "" {
+		t.Errorf("got size %d, mtime %v, md5 %q", src.Size, src.ModTime, src.Md5Checksum)
+	}
+}
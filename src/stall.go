@@ -0,0 +1,188 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ParseStallTimeout parses how long a transfer may go without progress
+// e.g "60s" or "2m" before it is cancelled, where "" means never.
+func ParseStallTimeout(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, invalidArgumentsErr(fmt.Errorf("stall timeout %q: expecting a positive duration e.g 60s or 2m", s))
+	}
+	return d, nil
+}
+
+// stallErr is the error of a request that was cancelled after
+// making no progress for timeout.
+func stallErr(timeout time.Duration) error {
+	return fmt.Errorf("transfer stalled: no progress for %v", timeout)
+}
+
+// stallWatch cancels a request once it goes timeout without progress.
+type stallWatch struct {
+	mu      sync.Mutex
+	timer   *time.Timer
+	timeout time.Duration
+	stalled bool
+	done    bool
+}
+
+func newStallWatch(timeout time.Duration, onStall func()) *stallWatch {
+	sw := &stallWatch{timeout: timeout}
+	sw.timer = time.AfterFunc(timeout, func() {
+		sw.mu.Lock()
+		if sw.done {
+			sw.mu.Unlock()
+			return
+		}
+		sw.stalled = true
+		sw.mu.Unlock()
+		onStall()
+	})
+	return sw
+}
+
+// progressed restarts the countdown.
+func (sw *stallWatch) progressed() {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	if !sw.done && !sw.stalled {
+		sw.timer.Reset(sw.timeout)
+	}
+}
+
+// stop ends the watch, reporting whether the request had stalled.
+func (sw *stallWatch) stop() bool {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	sw.done = true
+	sw.timer.Stop()
+	return sw.stalled
+}
+
+func (sw *stallWatch) hasStalled() bool {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	return sw.stalled
+}
+
+// stallTransport cancels the requests, uploads and downloads alike, that
+// make no progress for timeout. Cancelling closes their connection, so
+// that the retry of the change goes out on a fresh one.
+type stallTransport struct {
+	// stalls is first for the 64-bit alignment of its atomic ops.
+	stalls  int64
+	base    http.RoundTripper
+	timeout time.Duration
+}
+
+func (st *stallTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithCancel(req.Context())
+	sw := newStallWatch(st.timeout, func() {
+		atomic.AddInt64(&st.stalls, 1)
+		cancel()
+	})
+
+	// RoundTrippers mustn't modify the request, so watch a copy's body.
+	watched := req.WithContext(ctx)
+	if req.Body != nil {
+		watched.Body = &stallReadCloser{ReadCloser: req.Body, watch: sw, timeout: st.timeout}
+	}
+
+	res, err := st.base.RoundTrip(watched)
+	if err != nil {
+		if sw.stop() {
+			err = stallErr(st.timeout)
+		}
+		cancel()
+		return nil, err
+	}
+
+	sw.progressed()
+	res.Body = &stallReadCloser{ReadCloser: res.Body, watch: sw, timeout: st.timeout, cancel: cancel}
+	return res, nil
+}
+
+type stallReadCloser struct {
+	io.ReadCloser
+	watch   *stallWatch
+	timeout time.Duration
+	// cancel when set releases the request once its response is closed.
+	cancel func()
+}
+
+func (src *stallReadCloser) Read(p []byte) (int, error) {
+	n, err := src.ReadCloser.Read(p)
+	if n > 0 {
+		src.watch.progressed()
+	}
+	if err == io.EOF && src.cancel != nil {
+		// The response was read in full, whether or not it gets closed.
+		src.watch.stop()
+	} else if err != nil && src.watch.hasStalled() {
+		err = stallErr(src.timeout)
+	}
+	return n, err
+}
+
+func (src *stallReadCloser) Close() error {
+	err := src.ReadCloser.Close()
+	if src.cancel != nil {
+		src.watch.stop()
+		src.cancel()
+	}
+	return err
+}
+
+// setStallTimeout cancels the remote's requests that make no progress for timeout.
+func (r *Remote) setStallTimeout(timeout time.Duration) {
+	base := r.client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	st := &stallTransport{base: base, timeout: timeout}
+	r.client.Transport = st
+	r.stallTransport = st
+}
+
+// stallCount is the number of requests that were cancelled for stalling.
+func (r *Remote) stallCount() int64 {
+	if r.stallTransport == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&r.stallTransport.stalls)
+}
+
+// reportStalls tells how many transfers stalled and were cancelled to be retried.
+func (g *Commands) reportStalls(verb string) {
+	if stalls := g.rem.stallCount(); stalls > 0 {
+		g.log.LogErrf("%s: %d stalled transfers were cancelled and retried on a fresh connection\n", verb, stalls)
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseStallTimeout(t *testing.T) {
+	testCases := []struct {
+		s       string
+		want    time.Duration
+		wantErr bool
+	}{
+		{s: "", want: 0},
+		{s: "60s", want: time.Minute},
+		{s: " 2m ", want: 2 * time.Minute},
+		{s: "0s", wantErr: true},
+		{s: "-1s", wantErr: true},
+		{s: "soon", wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		got, err := ParseStallTimeout(tc.s)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("%q: expected an error", tc.s)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("%q: got %v, %v want %v", tc.s, got, err, tc.want)
+		}
+	}
+}
+
+// hangingBody produces its first chunk and then blocks until its request is cancelled.
+type hangingBody struct {
+	first []byte
+	req   *http.Request
+}
+
+func (hb *hangingBody) Read(p []byte) (int, error) {
+	if len(hb.first) > 0 {
+		n := copy(p, hb.first)
+		hb.first = hb.first[n:]
+		return n, nil
+	}
+	<-hb.req.Context().Done()
+	return 0, hb.req.Context().Err()
+}
+
+func (hb *hangingBody) Close() error { return nil }
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (fn roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return fn(req)
+}
+
+func TestStallTransportCancelsStalledDownloads(t *testing.T) {
+	base := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: &hangingBody{first: []byte("partial"), req: req}}, nil
+	})
+	st := &stallTransport{base: base, timeout: 20 * time.Millisecond}
+
+	req, _ := http.NewRequest("GET", "https://www.googleapis.com/drive/v2/files/abc?alt=media", nil)
+	res, err := st.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if string(body) != "partial" {
+		t.Errorf("got body %q", body)
+	}
+	if err == nil || !strings.Contains(err.Error(), "stalled") {
+		t.Errorf("expected a stall error, got %v", err)
+	}
+	if st.stalls != 1 {
+		t.Errorf("got %d stalls want 1", st.stalls)
+	}
+}
+
+func TestStallTransportCancelsStalledRequests(t *testing.T) {
+	base := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+	})
+	st := &stallTransport{base: base, timeout: 20 * time.Millisecond}
+
+	req, _ := http.NewRequest("POST", "https://www.googleapis.com/upload/drive/v2/files", bytes.NewBufferString("content"))
+	if _, err := st.RoundTrip(req); err == nil || !strings.Contains(err.Error(), "stalled") {
+		t.Errorf("expected a stall error, got %v", err)
+	}
+	if st.stalls != 1 {
+		t.Errorf("got %d stalls want 1", st.stalls)
+	}
+}
+
+// slowBody produces a byte at every tick, slower in total than the
+// stall timeout but never without progress for as long.
+type slowBody struct {
+	left int
+	tick time.Duration
+}
+
+func (sb *slowBody) Read(p []byte) (int, error) {
+	if sb.left < 1 {
+		return 0, io.EOF
+	}
+	time.Sleep(sb.tick)
+	sb.left--
+	p[0] = 'x'
+	return 1, nil
+}
+
+func (sb *slowBody) Close() error { return nil }
+
+func TestStallTransportAllowsSlowProgress(t *testing.T) {
+	base := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: &slowBody{left: 8, tick: 10 * time.Millisecond}}, nil
+	})
+	st := &stallTransport{base: base, timeout: 40 * time.Millisecond}
+
+	req, _ := http.NewRequest("GET", "https://www.googleapis.com/drive/v2/files/abc?alt=media", nil)
+	res, err := st.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil || len(body) != 8 {
+		t.Errorf("got %q, %v", body, err)
+	}
+
+	// The response was read in full, so leaving it open mustn't count as a stall.
+	time.Sleep(80 * time.Millisecond)
+	if st.stalls != 0 {
+		t.Errorf("got %d stalls want 0", st.stalls)
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// StarredFolder is the local folder that `pull -starred -all -virtual`
+	// gathers every starred file into, wherever it lives remotely.
+	StarredFolder     = "starred"
+	StarredFolderPath = "/" + StarredFolder
+
+	// starredMarker within the .gd directory records that StarredFolder
+	// is virtual, so that push and pull otherwise leave it alone.
+	starredMarker = "starred"
+)
+
+func (g *Commands) starredMarkerPath() string {
+	return filepath.Join(g.context.GDPath(), starredMarker)
+}
+
+// isVirtualStarred reports if localBase is the virtual starred folder,
+// which has no remote counterpart to be pushed to or pulled from.
+func (g *Commands) isVirtualStarred(localBase string) bool {
+	if localBase != StarredFolderPath {
+		return false
+	}
+	_, err := os.Stat(g.starredMarkerPath())
+	return err == nil
+}
+
+// markVirtualStarred makes StarredFolder virtual unless it already is.
+// It refuses to if StarredFolder is a real folder, locally or remotely,
+// since its contents would otherwise be removed as unstarred.
+func (g *Commands) markVirtualStarred() error {
+	if g.isVirtualStarred(StarredFolderPath) {
+		return nil
+	}
+
+	if _, err := os.Lstat(g.context.AbsPathOf(StarredFolderPath)); err == nil {
+		return overwriteAttemptedErr(fmt.Errorf("%s exists locally and isn't the virtual starred folder, move it aside first", StarredFolderPath))
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	f, err := g.rem.FindByPath(StarredFolderPath)
+	if err != nil && err != ErrPathNotExists {
+		return remoteLookupErr(fmt.Errorf("looking up %s: %v", StarredFolderPath, err))
+	}
+	if f != nil {
+		return overwriteAttemptedErr(fmt.Errorf("%s exists remotely so it can't be made the virtual starred folder", StarredFolderPath))
+	}
+
+	return ioutil.WriteFile(g.starredMarkerPath(), []byte(StarredFolderPath+"\n"), 0600)
+}
+
+// pullStarredIntoFolder resolves every starred file into StarredFolder
+// by its name, and removes the local copies of files since unstarred.
+func (g *Commands) pullStarredIntoFolder() (cl, clashes []*Change, err error) {
+	if err = g.markVirtualStarred(); err != nil {
+		return
+	}
+
+	pagePair := g.rem.FindStarred(g.opts.InTrash, g.opts.Hidden)
+	starredFilesChan := pagePair.filesChan
+	errsChan := pagePair.errsChan
+
+	starredNames := make(map[string]bool)
+
+	working := true
+	for working {
+		select {
+		case err := <-errsChan:
+			if err != nil {
+				return cl, clashes, err
+			}
+		case stF, stillHasContent := <-starredFilesChan:
+			if !stillHasContent {
+				working = false
+				break
+			}
+			if stF == nil {
+				continue
+			}
+
+			relToRoot := remotePathJoin(StarredFolderPath, stF.Name)
+			if reason := unrepresentableLocally(stF.Name); reason != "" {
+				g.skip(relToRoot, reason)
+				continue
+			}
+			if starredNames[stF.Name] {
+				g.skip(relToRoot, "another starred file has the same name")
+				continue
+			}
+			starredNames[stF.Name] = true
+
+			fsPath := g.context.AbsPathOf(relToRoot)
+			ccl, cclashes, cErr := g.byRemoteResolve(relToRoot, fsPath, stF, false)
+			if cErr != nil {
+				if cErr != ErrClashesDetected {
+					return cl, clashes, cErr
+				}
+				clashes = append(clashes, cclashes...)
+			}
+			cl = append(cl, ccl...)
+		}
+	}
+
+	unstarred, err := g.unstarredLocals(starredNames)
+	cl = append(cl, unstarred...)
+	return cl, clashes, err
+}
+
+// unstarredLocals returns deletions for the local copies in
+// StarredFolder of files that are no longer starred.
+func (g *Commands) unstarredLocals(starredNames map[string]bool) (cl []*Change, err error) {
+	infos, err := ioutil.ReadDir(g.context.AbsPathOf(StarredFolderPath))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	for _, info := range infos {
+		name := info.Name()
+		if starredNames[name] || isHidden(name, g.opts.Hidden) {
+			continue
+		}
+
+		relToRoot := remotePathJoin(StarredFolderPath, name)
+		local := NewLocalFile(g.context.AbsPathOf(relToRoot), info)
+		cl = append(cl, &Change{Path: relToRoot, Dest: local, Parent: StarredFolderPath, g: g})
+	}
+	return cl, nil
+}
@@ -0,0 +1,183 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"sort"
+	"time"
+
+	"github.com/odeke-em/drive/config"
+)
+
+// syncStatus buckets the paths at which a context and its remote diverge.
+type syncStatus struct {
+	localOnly   []string
+	remoteOnly  []string
+	modified    []string
+	conflicting []string
+}
+
+func (ss *syncStatus) clean() bool {
+	return len(ss.localOnly) < 1 && len(ss.remoteOnly) < 1 && len(ss.modified) < 1 && len(ss.conflicting) < 1
+}
+
+// classifyStatus sorts push-direction changes by how local and remote
+// diverge. An edit is only a conflict if both sides changed since the
+// last indexed sync, otherwise it is a plain modification.
+func classifyStatus(cl []*Change, indexFiler func(string) *config.Index) *syncStatus {
+	ss := &syncStatus{}
+	nonConflicts, conflicts := sift(cl)
+	resolved, unresolved := resolveConflicts(conflicts, true, indexFiler)
+
+	for _, c := range append(nonConflicts, resolved...) {
+		switch c.Op() {
+		case OpAdd:
+			ss.localOnly = append(ss.localOnly, c.Path)
+		case OpDelete:
+			ss.remoteOnly = append(ss.remoteOnly, c.Path)
+		case OpMod, OpModConflict:
+			ss.modified = append(ss.modified, c.Path)
+		}
+	}
+	for _, c := range unresolved {
+		ss.conflicting = append(ss.conflicting, c.Path)
+	}
+
+	for _, paths := range [][]string{ss.localOnly, ss.remoteOnly, ss.modified, ss.conflicting} {
+		sort.Strings(paths)
+	}
+	return ss
+}
+
+// Status reports how the sources diverge from their remotes, without
+// transferring anything.
+func (g *Commands) Status() error {
+	ss, err := g.cachedStatus()
+	if err != nil {
+		return err
+	}
+
+	sections := []struct {
+		title string
+		paths []string
+	}{
+		{title: "Only local", paths: ss.localOnly},
+		{title: "Only remote", paths: ss.remoteOnly},
+		{title: "Modified", paths: ss.modified},
+		{title: "Conflicting", paths: ss.conflicting},
+	}
+
+	for _, section := range sections {
+		if len(section.paths) < 1 {
+			continue
+		}
+		g.log.Logf("%s:\n", section.title)
+		for _, p := range section.paths {
+			g.log.Logf("\t%s\n", p)
+		}
+		g.log.Logln()
+	}
+
+	if ss.clean() {
+		g.log.Logln("Everything is up to date")
+	} else {
+		g.log.Logf("%d only local, %d only remote, %d modified, %d conflicting\n",
+			len(ss.localOnly), len(ss.remoteOnly), len(ss.modified), len(ss.conflicting))
+	}
+
+	g.reportQueuedPushes()
+	return nil
+}
+
+// reportQueuedPushes lists the pushes queued with `push -queue`
+// that are still pending upload until `flush` is run.
+func (g *Commands) reportQueuedPushes() {
+	queue, err := readQueue(queuePath(g.context))
+	if err != nil {
+		g.log.LogErrf("queue: %v\n", err)
+		return
+	}
+	if len(queue) < 1 {
+		return
+	}
+
+	g.log.Logf("\n%d queued push(es) pending upload, run `drive flush` to play them:\n", len(queue))
+	for _, qp := range queue {
+		g.log.Logf("\t%s\n", qp)
+	}
+}
+
+// cachedStatus returns the status cached for the sources if neither
+// the remote nor the local tree changed since, otherwise it computes
+// the status afresh and caches it.
+func (g *Commands) cachedStatus() (*syncStatus, error) {
+	if g.opts.NoCache {
+		return g.computeStatus()
+	}
+
+	largestChangeId, err := g.largestChangeId()
+	if err != nil {
+		return nil, err
+	}
+	localStamp, err := g.localStamp()
+	if err != nil {
+		return nil, err
+	}
+
+	p := statusCachePath(g.context)
+	cache := readStatusCache(p)
+	key := statusCacheKey(g.opts)
+
+	if entry, ok := cache[key]; ok && entry.LargestChangeId == largestChangeId && entry.LocalStamp == localStamp {
+		g.log.Logf("Unchanged since %s\n\n", entry.ComputedAt.Format(time.RFC3339))
+		return entry.status(), nil
+	}
+
+	ss, err := g.computeStatus()
+	if err != nil {
+		return nil, err
+	}
+
+	cache[key] = newStatusCacheEntry(ss, largestChangeId, localStamp)
+	if err := writeStatusCache(p, cache); err != nil {
+		g.log.LogErrf("status cache: %v\n", err)
+	}
+	return ss, nil
+}
+
+func (g *Commands) computeStatus() (*syncStatus, error) {
+	var cl, clashes []*Change
+
+	spin := g.playabler()
+	spin.play()
+	for _, relToRootPath := range g.opts.Sources {
+		fsPath := g.context.AbsPathOf(relToRootPath)
+		ccl, cclashes, err := g.changeListResolve(relToRootPath, fsPath, true)
+		if err != nil && err != ErrClashesDetected {
+			spin.stop()
+			return nil, err
+		}
+		cl = append(cl, ccl...)
+		clashes = append(clashes, cclashes...)
+	}
+	spin.stop()
+
+	if !g.opts.IgnoreNameClashes && len(clashes) >= 1 {
+		warnClashesPersist(g.log, clashes)
+		return nil, ErrClashesDetected
+	}
+
+	return classifyStatus(cl, g.deserializeIndex), nil
+}
@@ -0,0 +1,71 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/odeke-em/drive/config"
+)
+
+func TestClassifyStatus(t *testing.T) {
+	synced := time.Date(2016, time.March, 1, 10, 0, 0, 0, time.UTC)
+	later := synced.Add(3 * time.Hour)
+
+	file := func(id string, size int64, modTime time.Time) *File {
+		return &File{Id: id, Name: id, Size: size, ModTime: modTime, Md5Checksum: id}
+	}
+
+	indices := map[string]*config.Index{
+		// Only the local copy of "ours" changed since the last sync.
+		"ours": {FileId: "ours", ModTime: synced.Unix(), Md5Checksum: "before"},
+		// Both copies of "both" changed since the last sync.
+		"both": {FileId: "both", ModTime: synced.Add(-time.Hour).Unix(), Md5Checksum: "before"},
+	}
+	indexFiler := func(id string) *config.Index { return indices[id] }
+
+	cl := []*Change{
+		{Path: "/z-new", Src: file("z-new", 10, synced)},
+		{Path: "/a-new", Src: file("a-new", 10, synced)},
+		{Path: "/gone", Dest: file("gone", 10, synced)},
+		{Path: "/touched", Src: file("touched", 10, later), Dest: file("touched", 10, synced)},
+		{Path: "/ours", Src: file("ours", 20, later), Dest: file("ours", 10, synced)},
+		{Path: "/unindexed", Src: file("unindexed", 20, later), Dest: file("unindexed", 10, synced)},
+		{Path: "/both", Src: file("both", 20, later), Dest: file("both", 10, later.Add(time.Hour))},
+	}
+	for _, c := range cl {
+		c.IgnoreChecksum = true
+	}
+
+	got := classifyStatus(cl, indexFiler)
+	want := &syncStatus{
+		localOnly:   []string{"/a-new", "/z-new"},
+		remoteOnly:  []string{"/gone"},
+		modified:    []string{"/ours", "/touched", "/unindexed"},
+		conflicting: []string{"/both"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+	if got.clean() {
+		t.Errorf("a diverged status should not be clean")
+	}
+
+	if clean := classifyStatus(nil, indexFiler); !clean.clean() {
+		t.Errorf("no changes should be clean, got %+v", clean)
+	}
+}
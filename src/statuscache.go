@@ -0,0 +1,172 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/odeke-em/drive/config"
+)
+
+const (
+	StatusCacheJSON = "status.json"
+)
+
+// statusCacheEntry is the last status computed for a set of sources.
+// It stays valid while neither the remote's largest change id nor the
+// stamp of the local tree has moved.
+type statusCacheEntry struct {
+	LargestChangeId int64     `json:"largest_change_id"`
+	LocalStamp      string    `json:"local_stamp"`
+	ComputedAt      time.Time `json:"computed_at"`
+
+	LocalOnly   []string `json:"local_only,omitempty"`
+	RemoteOnly  []string `json:"remote_only,omitempty"`
+	Modified    []string `json:"modified,omitempty"`
+	Conflicting []string `json:"conflicting,omitempty"`
+}
+
+func (sce *statusCacheEntry) status() *syncStatus {
+	return &syncStatus{
+		localOnly:   sce.LocalOnly,
+		remoteOnly:  sce.RemoteOnly,
+		modified:    sce.Modified,
+		conflicting: sce.Conflicting,
+	}
+}
+
+func newStatusCacheEntry(ss *syncStatus, largestChangeId int64, localStamp string) *statusCacheEntry {
+	return &statusCacheEntry{
+		LargestChangeId: largestChangeId,
+		LocalStamp:      localStamp,
+		ComputedAt:      time.Now(),
+		LocalOnly:       ss.localOnly,
+		RemoteOnly:      ss.remoteOnly,
+		Modified:        ss.modified,
+		Conflicting:     ss.conflicting,
+	}
+}
+
+// statusCacheKey scopes cached statuses by subtree and by the
+// options that change how a status is computed.
+func statusCacheKey(opts *Options) string {
+	sources := append([]string{}, opts.Sources...)
+	sort.Strings(sources)
+	return fmt.Sprintf("%s depth=%d hidden=%v ignore-checksum=%v",
+		strings.Join(sources, ","), opts.Depth, opts.Hidden, opts.IgnoreChecksum)
+}
+
+// statusCachePath returns where the statuses are cached, apart
+// for each named remote since each has a status of its own.
+func statusCachePath(context *config.Context) string {
+	if context.Remote != "" {
+		return context.CacheFilePath(fmt.Sprintf("status-%s.json", context.Remote))
+	}
+	return context.CacheFilePath(StatusCacheJSON)
+}
+
+// readStatusCache returns the cached statuses by key. A missing
+// or unreadable cache is empty since it can always be recomputed.
+func readStatusCache(p string) map[string]*statusCacheEntry {
+	cache := make(map[string]*statusCacheEntry)
+	data, err := ioutil.ReadFile(p)
+	if err != nil {
+		return cache
+	}
+	if err := json.Unmarshal(data, &cache); err != nil || cache == nil {
+		return make(map[string]*statusCacheEntry)
+	}
+	return cache
+}
+
+func writeStatusCache(p string, cache map[string]*statusCacheEntry) error {
+	data, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmpPath := p + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, p)
+}
+
+func stampFileInfo(w io.Writer, relPath string, fi os.FileInfo) {
+	fmt.Fprintf(w, "%s\x00%d\x00%d\x00%v\n", relPath, fi.Size(), fi.ModTime().UnixNano(), fi.Mode())
+}
+
+// localStamp fingerprints the sizes, modes and modification times of
+// everything under the sources, along with the ignores and the index,
+// so that any local change since a status was cached invalidates it.
+func (g *Commands) localStamp() (string, error) {
+	h := sha1.New()
+
+	root := g.context.AbsPathOf("")
+	extras := []string{
+		filepath.Join(root, DriveIgnoreSuffix),
+		rcPath(root),
+		g.context.CacheFilePath(config.DriveDb),
+	}
+	for _, p := range extras {
+		if fi, err := os.Stat(p); err == nil {
+			stampFileInfo(h, p, fi)
+		}
+	}
+
+	for _, relToRootPath := range g.opts.Sources {
+		fsPath := g.context.AbsPathOf(relToRootPath)
+		err := filepath.Walk(fsPath, func(p string, fi os.FileInfo, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			if fi.IsDir() && fi.Name() == config.GDDirSuffix {
+				return filepath.SkipDir
+			}
+			relPath, rErr := filepath.Rel(root, p)
+			if rErr != nil {
+				return rErr
+			}
+			stampFileInfo(h, relPath, fi)
+			return nil
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (g *Commands) largestChangeId() (int64, error) {
+	about, err := g.rem.About()
+	if err != nil {
+		return 0, err
+	}
+	return about.LargestChangeId, nil
+}
@@ -0,0 +1,88 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestStatusCacheKey(t *testing.T) {
+	a := statusCacheKey(&Options{Sources: []string{"/b", "/a"}, Depth: -1})
+	b := statusCacheKey(&Options{Sources: []string{"/a", "/b"}, Depth: -1})
+	if a != b {
+		t.Errorf("the order of sources shouldn't matter: %q vs %q", a, b)
+	}
+
+	others := []*Options{
+		{Sources: []string{"/a"}, Depth: -1},
+		{Sources: []string{"/a", "/b"}, Depth: 1},
+		{Sources: []string{"/a", "/b"}, Depth: -1, Hidden: true},
+		{Sources: []string{"/a", "/b"}, Depth: -1, IgnoreChecksum: true},
+	}
+	for i, opts := range others {
+		if key := statusCacheKey(opts); key == a {
+			t.Errorf("#%d: %+v should have its own key, got %q", i, opts, key)
+		}
+	}
+}
+
+func TestStatusCacheRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "status-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	p := filepath.Join(dir, StatusCacheJSON)
+	if cache := readStatusCache(p); len(cache) != 0 {
+		t.Fatalf("a missing cache should be empty, got %v", cache)
+	}
+
+	ss := &syncStatus{
+		localOnly:   []string{"/a"},
+		remoteOnly:  []string{"/b"},
+		modified:    []string{"/c", "/d"},
+		conflicting: []string{"/e"},
+	}
+	cache := map[string]*statusCacheEntry{
+		"/docs": newStatusCacheEntry(ss, 1024, "stamp"),
+	}
+	if err := writeStatusCache(p, cache); err != nil {
+		t.Fatal(err)
+	}
+
+	got := readStatusCache(p)
+	entry, ok := got["/docs"]
+	if !ok {
+		t.Fatalf("entry not found in %v", got)
+	}
+	if entry.LargestChangeId != 1024 || entry.LocalStamp != "stamp" {
+		t.Errorf("got change id %d, stamp %q", entry.LargestChangeId, entry.LocalStamp)
+	}
+	if !reflect.DeepEqual(entry.status(), ss) {
+		t.Errorf("got %+v want %+v", entry.status(), ss)
+	}
+
+	if err := ioutil.WriteFile(p, []byte("{corrupt"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if cache := readStatusCache(p); len(cache) != 0 {
+		t.Errorf("a corrupt cache should be empty, got %v", cache)
+	}
+}
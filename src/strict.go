@@ -0,0 +1,103 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+type skippedPath struct {
+	path   string
+	reason string
+	// locked is set if another process held the path locked.
+	locked bool
+}
+
+// skip records that p was left out of the run and why. Once the run is
+// over, strictCheck fails it in strict mode.
+func (g *Commands) skip(p, reason string) {
+	g.log.LogErrf("skipping %s: %s\n", p, reason)
+
+	g.skippedMu.Lock()
+	g.skipped = append(g.skipped, &skippedPath{path: p, reason: reason})
+	g.skippedMu.Unlock()
+}
+
+// skipLocked records that p was left out of the run because
+// another process held it locked, for reportLocked to list.
+func (g *Commands) skipLocked(p, reason string) {
+	g.log.LogErrf("skipping %s: %s\n", p, reason)
+
+	g.skippedMu.Lock()
+	g.skipped = append(g.skipped, &skippedPath{path: p, reason: reason, locked: true})
+	g.skippedMu.Unlock()
+}
+
+// strictCheck fails the run in strict mode if any path was skipped,
+// listing each with its reason.
+func (g *Commands) strictCheck(err error) error {
+	if !g.opts.Strict {
+		return err
+	}
+
+	g.skippedMu.Lock()
+	skipped := g.skipped
+	g.skippedMu.Unlock()
+
+	if len(skipped) < 1 {
+		return err
+	}
+
+	g.log.LogErrf("strict: %d paths were skipped:\n", len(skipped))
+	for _, sp := range skipped {
+		g.log.LogErrf("  %s: %s\n", sp.path, sp.reason)
+	}
+
+	msg := fmt.Sprintf("strict: %d paths were skipped", len(skipped))
+	if err != nil {
+		return reComposeError(err, msg)
+	}
+	return pathsSkippedErr(errors.New(msg))
+}
+
+// unsupportedFileType returns why a local file of the given mode
+// can't be pushed, or "" if it can.
+func unsupportedFileType(mode os.FileMode) string {
+	switch {
+	case namedPipe(mode):
+		return "is a named pipe, not reading from it"
+	case mode&os.ModeSocket != 0:
+		return "is a socket"
+	case mode&os.ModeDevice != 0:
+		return "is a device"
+	}
+	return ""
+}
+
+// unrepresentableLocally returns why a remote file can't be pulled
+// under its name, or "" if it can. Separators in names are already
+// escaped by NewRemoteFile.
+func unrepresentableLocally(name string) string {
+	switch {
+	case name == "" || name == "." || name == "..":
+		return fmt.Sprintf("%q is not a valid local name", name)
+	case strings.ContainsRune(name, 0):
+		return "its name contains a NUL byte"
+	}
+	return ""
+}
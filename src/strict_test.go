@@ -0,0 +1,63 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"os"
+	"testing"
+)
+
+func TestUnsupportedFileType(t *testing.T) {
+	testCases := []struct {
+		mode      os.FileMode
+		supported bool
+	}{
+		{mode: 0644, supported: true},
+		{mode: os.ModeDir | 0755, supported: true},
+		{mode: os.ModeNamedPipe | 0644, supported: false},
+		{mode: os.ModeSocket | 0755, supported: false},
+		{mode: os.ModeDevice | 0600, supported: false},
+		{mode: os.ModeDevice | os.ModeCharDevice | 0600, supported: false},
+	}
+
+	for i, tc := range testCases {
+		reason := unsupportedFileType(tc.mode)
+		if got := reason == ""; got != tc.supported {
+			t.Errorf("#%d: mode %v supported: got %v want %v (%q)", i, tc.mode, got, tc.supported, reason)
+		}
+	}
+}
+
+func TestUnrepresentableLocally(t *testing.T) {
+	testCases := []struct {
+		name string
+		ok   bool
+	}{
+		{name: "report.txt", ok: true},
+		{name: ".hidden", ok: true},
+		{name: "...", ok: true},
+		{name: "", ok: false},
+		{name: ".", ok: false},
+		{name: "..", ok: false},
+		{name: "a\x00b", ok: false},
+	}
+
+	for i, tc := range testCases {
+		reason := unrepresentableLocally(tc.name)
+		if got := reason == ""; got != tc.ok {
+			t.Errorf("#%d: %q representable: got %v want %v (%q)", i, tc.name, got, tc.ok, reason)
+		}
+	}
+}
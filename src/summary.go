@@ -0,0 +1,169 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OutputJSONSummary is the --output that silences all interim output of
+// a run and only prints a single line JSON summary of it when it exits.
+const OutputJSONSummary = "json-summary"
+
+type summaryChange struct {
+	op   Operation
+	size int64
+	err  error
+}
+
+// runSummary tallies the changes that a run plays, by path so that
+// only the final outcome of a retried change is counted.
+type runSummary struct {
+	mu      sync.Mutex
+	command string
+	start   time.Time
+	out     io.Writer
+	changes map[string]*summaryChange
+}
+
+// summary is set when a JSON summary of the run was asked for.
+var summary *runSummary
+
+type summaryError struct {
+	Path  string `json:"path,omitempty"`
+	Op    string `json:"op,omitempty"`
+	Error string `json:"error"`
+}
+
+type summaryReport struct {
+	Command         string         `json:"command"`
+	OK              bool           `json:"ok"`
+	ExitCode        int            `json:"exit_code"`
+	DurationSeconds float64        `json:"duration_seconds"`
+	Changes         map[string]int `json:"changes"`
+	Bytes           int64          `json:"bytes"`
+	Errors          []summaryError `json:"errors"`
+}
+
+// UseOutput sets how the run of command reports, output being either
+// "" for the usual interim output or OutputJSONSummary. The summary is
+// printed on the current stdout while stdout and stderr are discarded.
+func UseOutput(output, command string) error {
+	switch output {
+	case "":
+		return nil
+	case OutputJSONSummary:
+	default:
+		return invalidArgumentsErr(fmt.Errorf("unknown output %q, expecting %q", output, OutputJSONSummary))
+	}
+
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+
+	summary = &runSummary{
+		command: command,
+		start:   time.Now(),
+		out:     os.Stdout,
+		changes: make(map[string]*summaryChange),
+	}
+	os.Stdout, os.Stderr = devNull, devNull
+	return nil
+}
+
+// opName is the name of op in a summary e.g "index_addition".
+func opName(op Operation) string {
+	_, info := op.description()
+	return strings.Replace(strings.ToLower(info), " ", "_", -1)
+}
+
+// summarizing wraps fn to tally the changes that it plays, if a
+// summary of the run was asked for.
+func (g *Commands) summarizing(fn func(*Change) error) func(*Change) error {
+	rs := summary
+	if rs == nil {
+		return fn
+	}
+	return func(change *Change) error {
+		// The outcome is worked out before fn updates what change refers to.
+		sc := &summaryChange{op: change.Op()}
+		if change.Src != nil && !change.Src.IsDir && sc.op != OpDelete {
+			sc.size = change.Src.Size
+		}
+		sc.err = fn(change)
+
+		rs.mu.Lock()
+		rs.changes[change.Path] = sc
+		rs.mu.Unlock()
+		return sc.err
+	}
+}
+
+func (rs *runSummary) report(err error, exitCode int) *summaryReport {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	report := &summaryReport{
+		Command:         rs.command,
+		OK:              err == nil,
+		ExitCode:        exitCode,
+		DurationSeconds: time.Since(rs.start).Seconds(),
+		Changes:         make(map[string]int),
+		Errors:          []summaryError{},
+	}
+
+	paths := make([]string, 0, len(rs.changes))
+	for p := range rs.changes {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	for _, p := range paths {
+		sc := rs.changes[p]
+		if sc.err != nil {
+			report.Errors = append(report.Errors, summaryError{Path: p, Op: opName(sc.op), Error: sc.err.Error()})
+			continue
+		}
+		report.Changes[opName(sc.op)]++
+		report.Bytes += sc.size
+	}
+
+	// The error of the run repeats those of its changes, which are already listed.
+	if err != nil && len(report.Errors) < 1 {
+		report.Errors = append(report.Errors, summaryError{Error: err.Error()})
+	}
+	return report
+}
+
+// PrintSummary prints the JSON summary of a run that ends with err and
+// exitCode, if one was asked for, and reports whether it did.
+func PrintSummary(err error, exitCode int) bool {
+	rs := summary
+	if rs == nil {
+		return false
+	}
+
+	data, _ := json.Marshal(rs.report(err, exitCode))
+	fmt.Fprintf(rs.out, "%s\n", data)
+	return true
+}
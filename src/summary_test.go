@@ -0,0 +1,108 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestSummarizing(t *testing.T) {
+	var out bytes.Buffer
+	rs := &runSummary{command: "push", out: &out, changes: make(map[string]*summaryChange)}
+	summary = rs
+	defer func() { summary = nil }()
+
+	g := &Commands{}
+	failOnce := map[string]bool{"/b.txt": true, "/c.txt": true}
+	fn := g.summarizing(func(c *Change) error {
+		if failOnce[c.Path] {
+			if c.Path == "/b.txt" {
+				delete(failOnce, c.Path)
+			}
+			return fmt.Errorf("rate limited")
+		}
+		return nil
+	})
+
+	changes := []*Change{
+		{Path: "/a.txt", Src: &File{Name: "a.txt", Size: 10}},
+		{Path: "/b.txt", Src: &File{Name: "b.txt", Size: 20}},
+		{Path: "/c.txt", Src: &File{Name: "c.txt", Size: 40}},
+		{Path: "/d", Src: &File{Name: "d", IsDir: true, Size: 4096}},
+		{Path: "/e.txt", Dest: &File{Name: "e.txt", Size: 80}},
+	}
+	for _, c := range changes {
+		fn(c)
+	}
+	// Retries replace the outcome of the first attempt.
+	fn(changes[1])
+
+	if !PrintSummary(fmt.Errorf("push: 1 changes failed"), 1) {
+		t.Fatalf("expected the summary to be printed")
+	}
+
+	if n := bytes.Count(out.Bytes(), []byte("\n")); n != 1 {
+		t.Fatalf("expected a single line, got %d in %q", n, out.String())
+	}
+
+	var got summaryReport
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("unmarshal %q: %v", out.String(), err)
+	}
+	got.DurationSeconds = 0
+
+	want := summaryReport{
+		Command:  "push",
+		ExitCode: 1,
+		Changes:  map[string]int{"addition": 3, "deletion": 1},
+		Bytes:    30,
+		Errors:   []summaryError{{Path: "/c.txt", Op: "addition", Error: "rate limited"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v want %+v", got, want)
+	}
+}
+
+func TestPrintSummaryUnset(t *testing.T) {
+	if PrintSummary(nil, 0) {
+		t.Errorf("no summary was asked for")
+	}
+}
+
+func TestRunSummaryRunError(t *testing.T) {
+	rs := &runSummary{command: "pull", changes: make(map[string]*summaryChange)}
+	report := rs.report(fmt.Errorf("no context"), 9)
+	want := []summaryError{{Error: "no context"}}
+	if report.OK || !reflect.DeepEqual(report.Errors, want) {
+		t.Errorf("got ok %v errors %+v want errors %+v", report.OK, report.Errors, want)
+	}
+
+	if report = rs.report(nil, 0); !report.OK || len(report.Errors) != 0 {
+		t.Errorf("got ok %v errors %+v for a successful run", report.OK, report.Errors)
+	}
+}
+
+func TestUseOutputUnknown(t *testing.T) {
+	if err := UseOutput("xml", "push"); err == nil {
+		t.Errorf("expected an error for an unknown output")
+	}
+	if summary != nil {
+		t.Errorf("an unknown output shouldn't ask for a summary")
+	}
+}
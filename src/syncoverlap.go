@@ -0,0 +1,126 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// syncMarker is a file or directory that another sync tool keeps at
+// the root of the tree that it syncs, matched as a glob.
+type syncMarker struct {
+	tool    string
+	pattern string
+}
+
+var syncMarkers = []syncMarker{
+	{tool: "Dropbox", pattern: ".dropbox"},
+	{tool: "Dropbox", pattern: ".dropbox.cache"},
+	{tool: "OneDrive", pattern: ".849C9593-D756-4E56-8D6E-42412F2A707B"},
+	{tool: "Google Drive", pattern: ".tmp.drivedownload"},
+	{tool: "Google Drive", pattern: ".tmp.driveupload"},
+	{tool: "Syncthing", pattern: ".stfolder"},
+	{tool: "Resilio Sync", pattern: filepath.Join(".sync", "ID")},
+	{tool: "Nextcloud", pattern: ".sync_*.db"},
+	{tool: "ownCloud", pattern: "._sync_*.db"},
+	{tool: "ownCloud", pattern: ".owncloudsync.log"},
+}
+
+// globEscape escapes the glob metacharacters of p.
+func globEscape(p string) string {
+	if filepath.Separator == '\\' {
+		// Backslashes separate paths there, so only brackets can quote.
+		return strings.NewReplacer("[", "[[]", "*", "[*]", "?", "[?]").Replace(p)
+	}
+	return strings.NewReplacer(`\`, `\\`, "[", `\[`, "*", `\*`, "?", `\?`).Replace(p)
+}
+
+// syncToolAt returns the name of the sync tool whose tree is rooted
+// at dir, or "" if there is none.
+func syncToolAt(dir string) string {
+	escaped := globEscape(dir)
+	for _, marker := range syncMarkers {
+		if matches, _ := filepath.Glob(filepath.Join(escaped, marker.pattern)); len(matches) >= 1 {
+			return marker.tool
+		}
+	}
+	return ""
+}
+
+// enclosingSyncTree returns the root of the tree of another sync tool
+// that dir is in or at, and the tool, or "" if dir isn't in one.
+func enclosingSyncTree(dir string) (root, tool string) {
+	for p := filepath.Clean(dir); ; {
+		if tool := syncToolAt(p); tool != "" {
+			return p, tool
+		}
+		parent := filepath.Dir(p)
+		if parent == p {
+			return "", ""
+		}
+		p = parent
+	}
+}
+
+// guardSyncOverlap stops a run in a context that is within the tree of
+// another sync tool, since both syncing the same files can clobber them,
+// unless it is confirmed or AllowSyncOverlap is set.
+func (g *Commands) guardSyncOverlap() error {
+	if g.syncOverlapChecked {
+		return nil
+	}
+	g.syncOverlapChecked = true
+
+	root, tool := enclosingSyncTree(g.context.AbsPath)
+	if tool == "" {
+		return nil
+	}
+
+	msg := fmt.Sprintf("%s is synced by %s too, from %s. Two tools syncing the same files can clobber them",
+		g.context.AbsPath, tool, root)
+	if g.opts.AllowSyncOverlap {
+		g.log.LogErrf("warning: %s\n", msg)
+		return nil
+	}
+	if !g.opts.canPrompt() {
+		return cannotPromptErr(fmt.Errorf("%s; rerun with -%s to proceed", msg, CLIOptionAllowSyncOverlap))
+	}
+
+	g.log.LogErrf("warning: %s\n", msg)
+	if status := promptForChanges("Sync anyway? [Y/n]: "); !accepted(status) {
+		return status.Error()
+	}
+	return nil
+}
+
+// skipSyncTree reports whether relToRoot, a directory within the context,
+// is the root of the tree of another sync tool, skipping it if so unless
+// AllowSyncOverlap is set. The context's own root is left to guardSyncOverlap.
+func (g *Commands) skipSyncTree(relToRoot string) bool {
+	if g.opts.AllowSyncOverlap {
+		return false
+	}
+	absPath := g.context.AbsPathOf(relToRoot)
+	if absPath == g.context.AbsPathOf("") {
+		return false
+	}
+	if tool := syncToolAt(absPath); tool != "" {
+		g.skip(relToRoot, fmt.Sprintf("synced by %s, use -%s to sync it too", tool, CLIOptionAllowSyncOverlap))
+		return true
+	}
+	return false
+}
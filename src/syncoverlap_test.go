@@ -0,0 +1,108 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSyncToolAt(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syncoverlap")
+	if err != nil {
+		t.Fatalf("tempdir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	markers := map[string]string{
+		"dropbox":   ".dropbox",
+		"syncthing": ".stfolder",
+		"nextcloud": ".sync_4b2e.db",
+		"resilio":   filepath.Join(".sync", "ID"),
+		"star*[x]":  ".dropbox",
+	}
+	for sub, marker := range markers {
+		p := filepath.Join(dir, sub, marker)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := ioutil.WriteFile(p, nil, 0644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	// An empty .sync directory isn't a Resilio Sync root.
+	if err := os.MkdirAll(filepath.Join(dir, "plain", ".sync"), 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+
+	tests := []struct {
+		sub  string
+		want string
+	}{
+		{sub: "dropbox", want: "Dropbox"},
+		{sub: "syncthing", want: "Syncthing"},
+		{sub: "nextcloud", want: "Nextcloud"},
+		{sub: "resilio", want: "Resilio Sync"},
+		{sub: "star*[x]", want: "Dropbox"},
+		{sub: "plain", want: ""},
+		{sub: "missing", want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := syncToolAt(filepath.Join(dir, tt.sub)); got != tt.want {
+			t.Errorf("%q: got %q want %q", tt.sub, got, tt.want)
+		}
+	}
+}
+
+func TestEnclosingSyncTree(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syncoverlap")
+	if err != nil {
+		t.Fatalf("tempdir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	synced := filepath.Join(dir, "Dropbox")
+	deep := filepath.Join(synced, "work", "drive")
+	plain := filepath.Join(dir, "plain", "drive")
+	for _, p := range []string{deep, plain} {
+		if err := os.MkdirAll(p, 0755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(synced, ".dropbox"), nil, 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	tests := []struct {
+		dir      string
+		wantRoot string
+		wantTool string
+	}{
+		{dir: synced, wantRoot: synced, wantTool: "Dropbox"},
+		{dir: deep, wantRoot: synced, wantTool: "Dropbox"},
+		{dir: deep + string(filepath.Separator), wantRoot: synced, wantTool: "Dropbox"},
+		{dir: plain},
+	}
+
+	for _, tt := range tests {
+		root, tool := enclosingSyncTree(tt.dir)
+		if root != tt.wantRoot || tool != tt.wantTool {
+			t.Errorf("%q: got (%q, %q) want (%q, %q)", tt.dir, root, tool, tt.wantRoot, tt.wantTool)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	DefaultTemplateDateLayout = "2006-01-02"
+)
+
+// ExpandRemotePath expands the templates in a remote path such as
+// `logs/{{hostname}}/{{date "2006/01/02"}}/syslog.gz`, using now for every
+// date in it. Besides hostname, date takes an optional Go time layout that
+// defaults to 2006-01-02, and env looks up an environment variable.
+func ExpandRemotePath(p string, now time.Time) (string, error) {
+	return expandRemotePath(p, now, nil)
+}
+
+// expandRemotePath is ExpandRemotePath with the further functions extra.
+func expandRemotePath(p string, now time.Time, extra template.FuncMap) (string, error) {
+	if !strings.Contains(p, "{{") {
+		return p, nil
+	}
+
+	funcs := template.FuncMap{
+		"hostname": os.Hostname,
+		"date": func(layouts ...string) (string, error) {
+			switch len(layouts) {
+			case 0:
+				return now.Format(DefaultTemplateDateLayout), nil
+			case 1:
+				return now.Format(layouts[0]), nil
+			default:
+				return "", fmt.Errorf("date takes at most one layout, got %d", len(layouts))
+			}
+		},
+		"env": os.Getenv,
+	}
+	for name, fn := range extra {
+		funcs[name] = fn
+	}
+
+	tmpl, err := template.New("path").Funcs(funcs).Parse(p)
+	if err != nil {
+		return "", invalidArgumentsErr(err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, nil); err != nil {
+		return "", invalidArgumentsErr(err)
+	}
+
+	expanded := buf.String()
+	if strings.TrimSpace(expanded) == "" {
+		return "", invalidArgumentsErr(fmt.Errorf("%q expands to an empty path", p))
+	}
+	return path.Clean(expanded), nil
+}
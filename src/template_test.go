@@ -0,0 +1,65 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestExpandRemotePath(t *testing.T) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		t.Skipf("hostname: %v", err)
+	}
+	os.Setenv("DRIVE_TEST_TEMPLATE_ENV", "staging")
+	defer os.Unsetenv("DRIVE_TEST_TEMPLATE_ENV")
+
+	now := time.Date(2016, time.March, 9, 17, 4, 5, 0, time.UTC)
+
+	testCases := []struct {
+		specimen string
+		want     string
+		wantErr  bool
+	}{
+		{specimen: "logs/syslog.gz", want: "logs/syslog.gz"},
+		{specimen: `logs/{{hostname}}/{{date "2006/01/02"}}/syslog.gz`, want: "logs/" + hostname + "/2016/03/09/syslog.gz"},
+		{specimen: "backups/{{date}}", want: "backups/2016-03-09"},
+		{specimen: `{{env "DRIVE_TEST_TEMPLATE_ENV"}}/{{date "15h04"}}.tar`, want: "staging/17h04.tar"},
+		{specimen: "a//{{date}}/../b/", want: "a/b"},
+		{specimen: "{{date", wantErr: true},
+		{specimen: "{{user}}/x", wantErr: true},
+		{specimen: `{{date "2006" "01"}}`, wantErr: true},
+		{specimen: `{{env "DRIVE_TEST_TEMPLATE_UNSET"}}`, wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		got, err := ExpandRemotePath(tc.specimen, now)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("%q: expected an error, got %q", tc.specimen, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected err %v", tc.specimen, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("%q: got %q want %q", tc.specimen, got, tc.want)
+		}
+	}
+}
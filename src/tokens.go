@@ -0,0 +1,180 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"sync"
+	"time"
+
+	"github.com/odeke-em/drive/config"
+	"golang.org/x/net/context"
+	"golang.org/x/oauth2"
+)
+
+// tokenRefreshMargin is how long before it expires that an access token
+// is refreshed, so that requests started just before then, e.g those of
+// long transfers, don't find it lapsed partway.
+const tokenRefreshMargin = 5 * time.Minute
+
+// persistingTokenSource hands out the context's access token, refreshing
+// it tokenRefreshMargin before it expires, and records each refreshed
+// token in the context so that the runs that follow can reuse it.
+type persistingTokenSource struct {
+	mu      sync.Mutex
+	conf    *oauth2.Config
+	context *config.Context
+	token   *oauth2.Token
+	now     func() time.Time
+	// refreshes is shared with the other token sources of the run.
+	refreshes *refreshGroup
+	// persist records a refreshed token, by default in the credentials file.
+	persist func(*oauth2.Token) error
+}
+
+func newPersistingTokenSource(conf *oauth2.Config, cc *config.Context) *persistingTokenSource {
+	pts := &persistingTokenSource{
+		conf:      conf,
+		context:   cc,
+		token:     persistedToken(cc),
+		now:       time.Now,
+		refreshes: tokenRefreshes,
+	}
+	pts.persist = pts.writeContext
+	return pts
+}
+
+// persistedToken is the access token recorded in the context, if any.
+func persistedToken(cc *config.Context) *oauth2.Token {
+	token := &oauth2.Token{RefreshToken: cc.RefreshToken}
+	if cc.AccessToken != "" && cc.TokenExpiry > 0 {
+		token.AccessToken = cc.AccessToken
+		token.TokenType = "Bearer"
+		token.Expiry = time.Unix(cc.TokenExpiry, 0)
+	}
+	return token
+}
+
+// fresh reports whether token stays valid for longer than the refresh margin.
+func (pts *persistingTokenSource) fresh(token *oauth2.Token) bool {
+	if token == nil || token.AccessToken == "" {
+		return false
+	}
+	if token.Expiry.IsZero() {
+		return true
+	}
+	return token.Expiry.Sub(pts.now()) > tokenRefreshMargin
+}
+
+func (pts *persistingTokenSource) Token() (*oauth2.Token, error) {
+	pts.mu.Lock()
+	defer pts.mu.Unlock()
+
+	if pts.fresh(pts.token) {
+		return pts.token, nil
+	}
+
+	token, err := pts.refreshes.do(pts.token.RefreshToken, pts.fresh, pts.refresh)
+	if err != nil {
+		return nil, err
+	}
+	pts.token = token
+	return token, nil
+}
+
+// refresh exchanges the refresh token for a new access token and persists it.
+func (pts *persistingTokenSource) refresh() (*oauth2.Token, error) {
+	// A token without an access token is refreshed straight away.
+	refreshed, err := pts.conf.TokenSource(context.Background(), &oauth2.Token{RefreshToken: pts.token.RefreshToken}).Token()
+	if err != nil {
+		return nil, err
+	}
+	if refreshed.RefreshToken == "" {
+		refreshed.RefreshToken = pts.token.RefreshToken
+	}
+
+	if err := pts.persist(refreshed); err != nil {
+		// The token still works for this run, only the next ones refresh it again.
+		DebugPrintf("persisting the refreshed access token: %v", err)
+	}
+	return refreshed, nil
+}
+
+// tokenRefresh is a refresh in flight, done is closed once it completes.
+type tokenRefresh struct {
+	done  chan struct{}
+	token *oauth2.Token
+	err   error
+}
+
+// refreshGroup lets a single refresh per refresh token be in flight, so
+// that when an access token expires under many workers, each with its own
+// token source e.g those of the contexts that a run opens, only one of
+// them asks for a new one and the others wait for and share its result.
+// Repeated concurrent refreshes can get Google to revoke the refresh token.
+type refreshGroup struct {
+	mu       sync.Mutex
+	inFlight map[string]*tokenRefresh
+	// latest is the last token that each refresh token was exchanged for,
+	// handed to the sources that only find theirs stale after it arrived.
+	latest map[string]*oauth2.Token
+}
+
+var tokenRefreshes = newRefreshGroup()
+
+func newRefreshGroup() *refreshGroup {
+	return &refreshGroup{
+		inFlight: make(map[string]*tokenRefresh),
+		latest:   make(map[string]*oauth2.Token),
+	}
+}
+
+// do returns the latest token for refreshToken if it is still fresh,
+// otherwise that of the refresh in flight for it, starting it if none is.
+func (rg *refreshGroup) do(refreshToken string, fresh func(*oauth2.Token) bool, refresh func() (*oauth2.Token, error)) (*oauth2.Token, error) {
+	rg.mu.Lock()
+	if token := rg.latest[refreshToken]; fresh(token) {
+		rg.mu.Unlock()
+		return token, nil
+	}
+	if tr, ok := rg.inFlight[refreshToken]; ok {
+		rg.mu.Unlock()
+		<-tr.done
+		return tr.token, tr.err
+	}
+	tr := &tokenRefresh{done: make(chan struct{})}
+	rg.inFlight[refreshToken] = tr
+	rg.mu.Unlock()
+
+	tr.token, tr.err = refresh()
+
+	rg.mu.Lock()
+	delete(rg.inFlight, refreshToken)
+	if tr.err == nil {
+		rg.latest[refreshToken] = tr.token
+	}
+	rg.mu.Unlock()
+	close(tr.done)
+
+	return tr.token, tr.err
+}
+
+func (pts *persistingTokenSource) writeContext(token *oauth2.Token) error {
+	pts.context.AccessToken = token.AccessToken
+	pts.context.TokenExpiry = token.Expiry.Unix()
+	if token.RefreshToken != "" {
+		pts.context.RefreshToken = token.RefreshToken
+	}
+	return pts.context.Write()
+}
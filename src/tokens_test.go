@@ -0,0 +1,154 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/odeke-em/drive/config"
+	"golang.org/x/oauth2"
+)
+
+func TestPersistingTokenSource(t *testing.T) {
+	refreshes := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		refreshes++
+		if got := r.FormValue("refresh_token"); got != "refresh" {
+			t.Errorf("refreshed with %q", got)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"access-%d","token_type":"Bearer","expires_in":3600}`, refreshes)
+	}))
+	defer server.Close()
+
+	now := time.Now()
+	cc := &config.Context{}
+	cc.RefreshToken = "refresh"
+	cc.AccessToken = "persisted"
+	cc.TokenExpiry = now.Add(30 * time.Minute).Unix()
+
+	conf := &oauth2.Config{ClientID: "id", ClientSecret: "secret", Endpoint: oauth2.Endpoint{TokenURL: server.URL}}
+	pts := newPersistingTokenSource(conf, cc)
+	pts.now = func() time.Time { return now }
+	pts.refreshes = newRefreshGroup()
+	var persisted []*oauth2.Token
+	pts.persist = func(token *oauth2.Token) error {
+		persisted = append(persisted, token)
+		return nil
+	}
+
+	token, err := pts.Token()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.AccessToken != "persisted" || refreshes != 0 {
+		t.Errorf("expected the persisted token to be reused, got %q after %d refreshes", token.AccessToken, refreshes)
+	}
+
+	// Within the margin of its expiry, the token is refreshed ahead of time.
+	now = now.Add(30*time.Minute - tokenRefreshMargin + time.Second)
+	if token, err = pts.Token(); err != nil {
+		t.Fatal(err)
+	}
+	if token.AccessToken != "access-1" || token.RefreshToken != "refresh" {
+		t.Errorf("got %#v", token)
+	}
+	if len(persisted) != 1 || persisted[0].AccessToken != "access-1" {
+		t.Errorf("expected the refreshed token to be persisted, got %v", persisted)
+	}
+
+	if token, _ = pts.Token(); token.AccessToken != "access-1" || refreshes != 1 {
+		t.Errorf("expected the refreshed token to be reused, got %q after %d refreshes", token.AccessToken, refreshes)
+	}
+}
+
+func TestTokenRefreshSingleFlight(t *testing.T) {
+	var mu sync.Mutex
+	refreshes := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		refreshes++
+		n := refreshes
+		mu.Unlock()
+		// Keep the refresh in flight long enough for the other workers to pile up.
+		time.Sleep(50 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"access-%d","token_type":"Bearer","expires_in":3600}`, n)
+	}))
+	defer server.Close()
+
+	conf := &oauth2.Config{ClientID: "id", ClientSecret: "secret", Endpoint: oauth2.Endpoint{TokenURL: server.URL}}
+	group := newRefreshGroup()
+	persisted := 0
+	var sources []*persistingTokenSource
+	for i := 0; i < 3; i++ {
+		cc := &config.Context{}
+		cc.RefreshToken = "single-flight"
+		pts := newPersistingTokenSource(conf, cc)
+		pts.refreshes = group
+		pts.persist = func(*oauth2.Token) error {
+			mu.Lock()
+			persisted++
+			mu.Unlock()
+			return nil
+		}
+		sources = append(sources, pts)
+	}
+
+	var wg sync.WaitGroup
+	tokens := make(chan string, 30)
+	for i := 0; i < cap(tokens); i++ {
+		wg.Add(1)
+		go func(pts *persistingTokenSource) {
+			defer wg.Done()
+			token, err := pts.Token()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			tokens <- token.AccessToken
+		}(sources[i%len(sources)])
+	}
+	wg.Wait()
+	close(tokens)
+
+	for token := range tokens {
+		if token != "access-1" {
+			t.Errorf("got %q, expected every worker to share the first refresh", token)
+		}
+	}
+	if refreshes != 1 || persisted != 1 {
+		t.Errorf("expected a single refresh and write, got %d refreshes and %d writes", refreshes, persisted)
+	}
+}
+
+func TestPersistedToken(t *testing.T) {
+	cc := &config.Context{}
+	cc.RefreshToken = "refresh"
+	if token := persistedToken(cc); token.AccessToken != "" || token.RefreshToken != "refresh" {
+		t.Errorf("got %#v", token)
+	}
+
+	cc.AccessToken, cc.TokenExpiry = "access", 1700000000
+	token := persistedToken(cc)
+	if token.AccessToken != "access" || !token.Expiry.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("got %#v", token)
+	}
+}
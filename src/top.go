@@ -0,0 +1,141 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"container/heap"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/odeke-em/drive/config"
+	"github.com/odeke-em/log"
+)
+
+type topEntry struct {
+	path string
+	id   string
+	size int64
+}
+
+func remoteTopEntry(parent string, f *File) *topEntry {
+	if f == nil || f.IsDir {
+		return nil
+	}
+	return &topEntry{path: sepJoin("/", parent, f.Name), id: f.Id, size: f.Size}
+}
+
+// smallerTop orders entries by size, breaking ties by path so
+// that reports are stable.
+func smallerTop(a, b *topEntry) bool {
+	if a.size != b.size {
+		return a.size < b.size
+	}
+	return a.path > b.path
+}
+
+// topHeap is a min-heap by size so that the smallest of the kept
+// entries is the first to be evicted.
+type topHeap []*topEntry
+
+func (th topHeap) Len() int            { return len(th) }
+func (th topHeap) Less(i, j int) bool  { return smallerTop(th[i], th[j]) }
+func (th topHeap) Swap(i, j int)       { th[i], th[j] = th[j], th[i] }
+func (th *topHeap) Push(x interface{}) { *th = append(*th, x.(*topEntry)) }
+
+func (th *topHeap) Pop() interface{} {
+	old := *th
+	last := old[len(old)-1]
+	*th = old[:len(old)-1]
+	return last
+}
+
+// topFiles keeps the n largest entries added to it.
+type topFiles struct {
+	n       int
+	entries topHeap
+}
+
+func newTopFiles(n int) *topFiles {
+	return &topFiles{n: n}
+}
+
+func (tf *topFiles) add(e *topEntry) {
+	if e == nil || tf.n < 1 {
+		return
+	}
+	if len(tf.entries) < tf.n {
+		heap.Push(&tf.entries, e)
+		return
+	}
+	if smallerTop(tf.entries[0], e) {
+		tf.entries[0] = e
+		heap.Fix(&tf.entries, 0)
+	}
+}
+
+// sorted returns the kept entries largest first.
+func (tf *topFiles) sorted() []*topEntry {
+	sorted := make(topHeap, len(tf.entries))
+	copy(sorted, tf.entries)
+	sort.Sort(sort.Reverse(sorted))
+	return sorted
+}
+
+func (tf *topFiles) report(logy *log.Logger, where string) {
+	entries := tf.sorted()
+	logy.Logf("%d largest %s files:\n", len(entries), where)
+	for _, e := range entries {
+		if e.id != "" {
+			logy.Logf("%-12s %-45s %s\n", prettyBytes(e.size), e.id, e.path)
+		} else {
+			logy.Logf("%-12s %s\n", prettyBytes(e.size), e.path)
+		}
+	}
+}
+
+// topLocal reports the largest local files under each source.
+func (g *Commands) topLocal() error {
+	top := newTopFiles(g.opts.Top)
+	for _, relPath := range g.opts.Sources {
+		root := g.context.AbsPathOf(relPath)
+		err := filepath.Walk(root, func(p string, fi os.FileInfo, err error) error {
+			if err != nil {
+				g.log.LogErrf("%s: %v\n", p, err)
+				return nil
+			}
+			if p != root && (fi.Name() == config.GDDirSuffix || isHidden(fi.Name(), g.opts.Hidden)) {
+				if fi.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !fi.Mode().IsRegular() {
+				return nil
+			}
+			rel, rErr := filepath.Rel(g.context.AbsPath, p)
+			if rErr != nil {
+				return rErr
+			}
+			top.add(&topEntry{path: "/" + filepath.ToSlash(rel), size: fi.Size()})
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	top.report(g.log, "local")
+	return nil
+}
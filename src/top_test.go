@@ -0,0 +1,181 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/odeke-em/log"
+	drive "google.golang.org/api/drive/v2"
+)
+
+func TestTopFiles(t *testing.T) {
+	testCases := []struct {
+		n       int
+		entries []*topEntry
+		want    []string
+	}{
+		{n: 0, entries: []*topEntry{{path: "/a", size: 1}}, want: nil},
+		{
+			n: 3,
+			entries: []*topEntry{
+				{path: "/a", size: 10},
+				{path: "/b", size: 50},
+				nil,
+				{path: "/c", size: 5},
+				{path: "/d", size: 70},
+				{path: "/e", size: 20},
+			},
+			want: []string{"/d", "/b", "/e"},
+		},
+		{
+			n: 2,
+			entries: []*topEntry{
+				{path: "/z", size: 8},
+				{path: "/y", size: 8},
+				{path: "/x", size: 8},
+			},
+			want: []string{"/x", "/y"},
+		},
+		{
+			n:       5,
+			entries: []*topEntry{{path: "/small", size: 1}, {path: "/big", size: 2}},
+			want:    []string{"/big", "/small"},
+		},
+	}
+
+	for i, tc := range testCases {
+		top := newTopFiles(tc.n)
+		for _, e := range tc.entries {
+			top.add(e)
+		}
+
+		var got []string
+		for _, e := range top.sorted() {
+			got = append(got, e.path)
+		}
+		if len(got) != len(tc.want) {
+			t.Errorf("#%d: got %v want %v", i, got, tc.want)
+			continue
+		}
+		for j := range got {
+			if got[j] != tc.want[j] {
+				t.Errorf("#%d: got %v want %v", i, got, tc.want)
+				break
+			}
+		}
+	}
+}
+
+// fakeTreeTransport serves the gets and listings of a remote tree,
+// given as the children of each folder id.
+func fakeTreeTransport(t *testing.T, files map[string]*drive.File, children map[string][]string) http.RoundTripper {
+	inParents := regexp.MustCompile(`['"]([^'"]+)['"] in parents`)
+	titled := regexp.MustCompile(`title = "([^"]+)"`)
+	return roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		var body interface{}
+		if id := strings.TrimPrefix(req.URL.Path, "/drive/v2/files/"); id != req.URL.Path {
+			body = files[id]
+		} else {
+			m := inParents.FindStringSubmatch(req.URL.Query().Get("q"))
+			if m == nil {
+				t.Fatalf("unexpected request %s", req.URL)
+			}
+			title := titled.FindStringSubmatch(req.URL.Query().Get("q"))
+			list := &drive.FileList{}
+			for _, id := range children[m[1]] {
+				if title == nil || files[id].Title == title[1] {
+					list.Items = append(list.Items, files[id])
+				}
+			}
+			body = list
+		}
+
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       ioutil.NopCloser(bytes.NewReader(data)),
+			Request:    req,
+		}, nil
+	})
+}
+
+func TestTopRemoteNested(t *testing.T) {
+	folder := func(id string) *drive.File {
+		return &drive.File{Id: id, Title: id, MimeType: DriveFolderMimeType}
+	}
+	file := func(id string, size int64) *drive.File {
+		return &drive.File{Id: id, Title: id + ".bin", MimeType: "application/octet-stream", FileSize: size}
+	}
+	files := map[string]*drive.File{
+		"top": folder("top"), "a": folder("a"), "b": folder("b"),
+		"small": file("small", 10), "mid": file("mid", 500), "large": file("large", 9000),
+	}
+	children := map[string][]string{
+		"top": {"a", "small"},
+		"a":   {"b", "mid"},
+		"b":   {"large"},
+	}
+
+	rem, err := remoteFromClient(&http.Client{Transport: fakeTreeTransport(t, files, children)})
+	if err != nil {
+		t.Fatalf("remoteFromClient: %v", err)
+	}
+
+	testCases := []struct {
+		depth int
+		want  []string
+	}{
+		{depth: InfiniteDepth, want: []string{"large", "mid"}},
+		{depth: 1, want: []string{"small"}},
+	}
+
+	for i, tc := range testCases {
+		var stdout bytes.Buffer
+		g := &Commands{
+			rem: rem,
+			log: log.New(nil, &stdout, ioutil.Discard),
+			opts: &Options{
+				Sources:  []string{"top"},
+				Depth:    tc.depth,
+				Top:      2,
+				NoPrompt: true,
+				PageSize: 100,
+				TypeMask: DiskUsageOnly | Minimal,
+			},
+		}
+		if err := g.List(true); err != nil {
+			t.Fatalf("#%d: list: %v", i, err)
+		}
+
+		var got []string
+		for _, id := range regexp.MustCompile(`\s(small|mid|large)\s`).FindAllStringSubmatch(stdout.String(), -1) {
+			got = append(got, id[1])
+		}
+		if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+			t.Errorf("#%d: depth %d: got %v want %v in\n%s", i, tc.depth, got, tc.want, stdout.String())
+		}
+	}
+}
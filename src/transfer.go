@@ -0,0 +1,131 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+
+	"github.com/odeke-em/drive/config"
+)
+
+// Transfer copies the sources into destPath of the context destContext,
+// which may belong to a different account. Copies are made server side:
+// when the accounts differ, each source is temporarily shared with the
+// destination's account for the copy's duration. If move is set, the
+// sources are trashed once copied. Both contexts' indices are updated.
+func (g *Commands) Transfer(destContext *config.Context, destPath string, move bool) error {
+	if err := g.requireWholeDrive("transfer"); err != nil {
+		return err
+	}
+
+	dest := New(destContext, &Options{Quiet: g.opts.Quiet, Path: "/"})
+	destPath = remotePathJoin(destPath)
+
+	srcAbout, err := g.rem.About()
+	if err != nil {
+		return err
+	}
+	destAbout, err := dest.rem.About()
+	if err != nil {
+		return err
+	}
+
+	destEmail := ""
+	if destAbout.User != nil {
+		destEmail = destAbout.User.EmailAddress
+	}
+	sameAccount := srcAbout.User != nil && destAbout.User != nil && srcAbout.User.EmailAddress == destEmail
+	if !sameAccount && destEmail == "" {
+		return illogicalStateErr(fmt.Errorf("transfer: cannot determine the destination's account to share with"))
+	}
+
+	for _, relToRootPath := range g.opts.Sources {
+		src, err := g.rem.FindByPath(relToRootPath)
+		if err != nil && err != ErrPathNotExists {
+			return err
+		}
+		if src == nil {
+			return illogicalStateErr(fmt.Errorf("transfer: %s doesnot exist", customQuote(relToRootPath)))
+		}
+
+		if err := g.transferOne(dest, src, destPath, destEmail, sameAccount, move); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (g *Commands) transferOne(dest *Commands, src *File, destPath, destEmail string, sameAccount, move bool) error {
+	if !sameAccount {
+		perm, err := g.rem.insertPermissions(&permission{
+			fileId:      src.Id,
+			value:       destEmail,
+			role:        Reader,
+			accountType: User,
+		})
+		if err != nil {
+			return fmt.Errorf("transfer: sharing %s with %s: %v", customQuote(src.Name), destEmail, err)
+		}
+
+		defer func() {
+			if dErr := g.rem.deletePermission(src.Id, perm.Id); dErr != nil {
+				g.log.LogErrf("transfer: unsharing %s from %s: %v\n", customQuote(src.Name), destEmail, dErr)
+			}
+		}()
+	}
+
+	copied, err := dest.copy(src, destPath)
+	if err != nil {
+		return err
+	}
+	g.log.Logf("%s -> %s:%s\n", customQuote(src.Name), dest.context.AbsPath, customQuote(destPath))
+
+	copiedTree := map[string]*File{"": copied}
+	if copied.IsDir {
+		if err := dest.remoteTree(copied, "", copiedTree); err != nil {
+			return err
+		}
+	}
+	for _, f := range copiedTree {
+		if err := dest.createIndex(f); err != nil {
+			dest.log.LogErrf("transfer: index %s: %v\n", f.Name, err)
+		}
+	}
+
+	if !move {
+		return nil
+	}
+
+	srcTree := map[string]*File{"": src}
+	if src.IsDir {
+		if err := g.remoteTree(src, "", srcTree); err != nil {
+			return err
+		}
+	}
+
+	if err := g.rem.Trash(src.Id); err != nil {
+		return fmt.Errorf("transfer: copied yet failed to trash %s: %v", customQuote(src.Name), err)
+	}
+
+	rootAbsPath := g.context.AbsPathOf("")
+	for _, f := range srcTree {
+		if err := g.context.RemoveIndex(f.ToIndex(), rootAbsPath); err != nil && err != config.ErrNoSuchDbKey {
+			g.log.LogErrf("transfer: index %s: %v\n", f.Name, err)
+		}
+	}
+
+	return nil
+}
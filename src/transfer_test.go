@@ -0,0 +1,126 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	expirableCache "github.com/odeke-em/cache"
+	"github.com/odeke-em/drive/config"
+	"github.com/odeke-em/log"
+	drive "google.golang.org/api/drive/v2"
+)
+
+// replyingTransport serves gets and listings of the tree and answers
+// other requests with reply, recording them as they are made.
+func replyingTransport(t *testing.T, tree http.RoundTripper, reply map[string]interface{}, requests *[]string) http.RoundTripper {
+	return roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if req.Method == "GET" {
+			return tree.RoundTrip(req)
+		}
+		key := req.Method + " " + req.URL.Path
+		*requests = append(*requests, key)
+		body, ok := reply[key]
+		if !ok {
+			t.Fatalf("unexpected request %s", key)
+		}
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       ioutil.NopCloser(bytes.NewReader(data)),
+			Request:    req,
+		}, nil
+	})
+}
+
+func TestTransferOneAcrossAccounts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "transfer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	prevCacheHome := os.Getenv("XDG_CACHE_HOME")
+	os.Setenv("XDG_CACHE_HOME", filepath.Join(dir, "cache"))
+	defer os.Setenv("XDG_CACHE_HOME", prevCacheHome)
+
+	commands := func(name string, transport http.RoundTripper) *Commands {
+		rem, err := remoteFromClient(&http.Client{Transport: transport})
+		if err != nil {
+			t.Fatalf("remoteFromClient: %v", err)
+		}
+		context := &config.Context{AbsPath: filepath.Join(dir, name), CacheId: name}
+		if err := os.MkdirAll(context.GDPath(), 0700); err != nil {
+			t.Fatal(err)
+		}
+		return &Commands{
+			rem:           rem,
+			context:       context,
+			opts:          &Options{},
+			log:           log.New(nil, ioutil.Discard, ioutil.Discard),
+			mkdirAllCache: expirableCache.New(),
+		}
+	}
+
+	root := &drive.File{Id: "root", Title: "root", MimeType: DriveFolderMimeType}
+	var srcRequests, destRequests []string
+	src := commands("src", replyingTransport(t, fakeTreeTransport(t, nil, nil), map[string]interface{}{
+		"POST /drive/v2/files/doc/permissions":        &drive.Permission{Id: "perm"},
+		"DELETE /drive/v2/files/doc/permissions/perm": nil,
+		"POST /drive/v2/files/doc/trash":              &drive.File{Id: "doc"},
+	}, &srcRequests))
+	dest := commands("dest", replyingTransport(t, fakeTreeTransport(t, map[string]*drive.File{"root": root}, nil), map[string]interface{}{
+		"POST /drive/v2/files/doc/copy": &drive.File{Id: "copy", Title: "doc.txt", Md5Checksum: "md5"},
+	}, &destRequests))
+
+	doc := &File{Id: "doc", Name: "doc.txt", Copyable: true}
+	if err := src.context.SerializeIndex(doc.ToIndex()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := src.transferOne(dest, doc, "/doc.txt", "dest@example.com", false, true); err != nil {
+		t.Fatalf("transfer: %v", err)
+	}
+
+	// The source is unshared from the destination's account once it is copied and trashed.
+	wantSrc := []string{
+		"POST /drive/v2/files/doc/permissions",
+		"POST /drive/v2/files/doc/trash",
+		"DELETE /drive/v2/files/doc/permissions/perm",
+	}
+	if !reflect.DeepEqual(srcRequests, wantSrc) {
+		t.Errorf("source requests: got %q want %q", srcRequests, wantSrc)
+	}
+	if want := []string{"POST /drive/v2/files/doc/copy"}; !reflect.DeepEqual(destRequests, want) {
+		t.Errorf("destination requests: got %q want %q", destRequests, want)
+	}
+
+	if index, err := dest.context.DeserializeIndex("copy"); err != nil || index == nil {
+		t.Errorf("expected the copy to be indexed in the destination, got %v, %v", index, err)
+	}
+	if index, _ := src.context.DeserializeIndex("doc"); index != nil {
+		t.Errorf("expected the moved source's index to be removed, got %v", index)
+	}
+}
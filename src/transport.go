@@ -0,0 +1,240 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// TransportOptions change how all HTTP traffic, that of the Drive API as
+// well as the OAuth2 exchanges, is carried e.g through TLS-intercepting
+// proxies. The zero value keeps Go's defaults.
+type TransportOptions struct {
+	// Proxy when set is the URL of the proxy to go through, see
+	// ParseProxyURL, otherwise HTTP_PROXY, HTTPS_PROXY and NO_PROXY apply.
+	Proxy string
+
+	// CABundle when set is a PEM file of further certificate
+	// authorities to trust, besides those of the system.
+	CABundle string
+
+	// TLSMinVersion when set is the oldest TLS version e.g "1.2" to accept.
+	TLSMinVersion string
+
+	// InsecureSkipVerify when set accepts any certificate, for debugging only.
+	InsecureSkipVerify bool
+
+	// BindAddress when set is the local IP address, or the network
+	// interface e.g "eth0", that connections are made from.
+	BindAddress string
+
+	// PreferIPv4 when set connects over IPv4 first, for hosts
+	// whose IPv6 connectivity is broken.
+	PreferIPv4 bool
+}
+
+func (opts *TransportOptions) isZero() bool {
+	return opts.Proxy == "" && opts.CABundle == "" && opts.TLSMinVersion == "" && !opts.InsecureSkipVerify &&
+		opts.BindAddress == "" && !opts.PreferIPv4
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// parseTLSVersion parses a TLS version e.g "1.2", with an optional "TLS" prefix.
+func parseTLSVersion(v string) (uint16, error) {
+	trimmed := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(v)), "tls")
+	version, ok := tlsVersions[strings.TrimSpace(trimmed)]
+	if !ok {
+		return 0, invalidArgumentsErr(fmt.Errorf("tls min version: unknown version %q, expected one of 1.0, 1.1, 1.2 or 1.3", v))
+	}
+	return version, nil
+}
+
+// newTransport returns a transport that otherwise matches http.DefaultTransport.
+func newTransport(opts *TransportOptions) (*http.Transport, error) {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	var bindIP net.IP
+	if strings.TrimSpace(opts.BindAddress) != "" {
+		var err error
+		if bindIP, err = resolveBindAddress(strings.TrimSpace(opts.BindAddress), opts.PreferIPv4); err != nil {
+			return nil, err
+		}
+		dialer.LocalAddr = &net.TCPAddr{IP: bindIP}
+	}
+
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialFor(dialer.DialContext, bindIP, opts.PreferIPv4),
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+
+	if strings.TrimSpace(opts.Proxy) != "" {
+		u, err := ParseProxyURL(opts.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		transport.Proxy = http.ProxyURL(u)
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: opts.InsecureSkipVerify}
+	if opts.TLSMinVersion != "" {
+		version, err := parseTLSVersion(opts.TLSMinVersion)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.MinVersion = version
+	}
+	if opts.CABundle != "" {
+		pool, err := caBundlePool(opts.CABundle)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = pool
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	return transport, nil
+}
+
+type dialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// dialFor returns a dial that connects over the IP family of bindIP if
+// set, and otherwise over IPv4 first if preferIPv4 is set.
+func dialFor(dial dialFunc, bindIP net.IP, preferIPv4 bool) dialFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if network != "tcp" {
+			return dial(ctx, network, addr)
+		}
+		if bindIP != nil {
+			if bindIP.To4() != nil {
+				return dial(ctx, "tcp4", addr)
+			}
+			return dial(ctx, "tcp6", addr)
+		}
+		if preferIPv4 {
+			if conn, err := dial(ctx, "tcp4", addr); err == nil {
+				return conn, nil
+			}
+		}
+		return dial(ctx, network, addr)
+	}
+}
+
+// resolveBindAddress returns the IP address of addr, which is either
+// an IP address or the name of a network interface.
+func resolveBindAddress(addr string, preferIPv4 bool) (net.IP, error) {
+	if ip := net.ParseIP(addr); ip != nil {
+		return ip, nil
+	}
+
+	iface, err := net.InterfaceByName(addr)
+	if err != nil {
+		return nil, invalidArgumentsErr(fmt.Errorf("bind address %q is neither an IP address nor a network interface", addr))
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, err
+	}
+	ip := pickInterfaceIP(addrs, preferIPv4)
+	if ip == nil {
+		return nil, invalidArgumentsErr(fmt.Errorf("bind address: network interface %q has no usable IP address", addr))
+	}
+	return ip, nil
+}
+
+// pickInterfaceIP picks the address of an interface to bind to,
+// preferring global addresses to link-local ones, and IPv4 addresses
+// to IPv6 ones if preferIPv4 is set.
+func pickInterfaceIP(addrs []net.Addr, preferIPv4 bool) net.IP {
+	var best net.IP
+	bestRank := -1
+	for _, addr := range addrs {
+		var ip net.IP
+		switch a := addr.(type) {
+		case *net.IPNet:
+			ip = a.IP
+		case *net.IPAddr:
+			ip = a.IP
+		}
+		if ip == nil || ip.IsUnspecified() {
+			continue
+		}
+
+		rank := 0
+		if !ip.IsLinkLocalUnicast() {
+			rank += 2
+		}
+		if preferIPv4 && ip.To4() != nil {
+			rank += 4
+		}
+		if rank > bestRank {
+			best, bestRank = ip, rank
+		}
+	}
+	return best
+}
+
+// caBundlePool returns the system's certificate authorities along with
+// those of the PEM file at p.
+func caBundlePool(p string) (*x509.CertPool, error) {
+	pem, err := ioutil.ReadFile(p)
+	if err != nil {
+		return nil, invalidArgumentsErr(fmt.Errorf("ca bundle: %v", err))
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, invalidArgumentsErr(fmt.Errorf("ca bundle: no PEM certificates found in %q", p))
+	}
+	return pool, nil
+}
+
+// UseTransport routes all HTTP traffic through a transport built from
+// opts, by replacing http.DefaultTransport which every client that isn't
+// given a transport of its own uses. The zero value leaves it alone.
+func UseTransport(opts TransportOptions) error {
+	if opts.isZero() {
+		return nil
+	}
+
+	transport, err := newTransport(&opts)
+	if err != nil {
+		return err
+	}
+	http.DefaultTransport = transport
+	return nil
+}
@@ -0,0 +1,214 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTLSVersion(t *testing.T) {
+	testCases := []struct {
+		v       string
+		want    uint16
+		wantErr bool
+	}{
+		{v: "1.2", want: tls.VersionTLS12},
+		{v: "TLS1.3", want: tls.VersionTLS13},
+		{v: " tls 1.0 ", want: tls.VersionTLS10},
+		{v: "1.4", wantErr: true},
+		{v: "ssl3", wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		got, err := parseTLSVersion(tc.v)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("%q: expected an error", tc.v)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("%q: got %x, %v want %x", tc.v, got, err, tc.want)
+		}
+	}
+}
+
+func writeCABundle(t *testing.T, p string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Interception CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	if err := ioutil.WriteFile(p, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewTransport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "transport")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	bundle := filepath.Join(dir, "ca.pem")
+	writeCABundle(t, bundle)
+	notPEM := filepath.Join(dir, "ca.txt")
+	if err := ioutil.WriteFile(notPEM, []byte("not a certificate"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	transport, err := newTransport(&TransportOptions{
+		Proxy:         "socks5://127.0.0.1:1080",
+		CABundle:      bundle,
+		TLSMinVersion: "1.2",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transport.TLSClientConfig.RootCAs == nil || transport.TLSClientConfig.MinVersion != tls.VersionTLS12 {
+		t.Errorf("got TLS config %#v", transport.TLSClientConfig)
+	}
+	if transport.TLSClientConfig.InsecureSkipVerify {
+		t.Errorf("expected certificates to be verified by default")
+	}
+	req, _ := http.NewRequest("GET", "https://www.googleapis.com/drive/v2/about", nil)
+	if proxyURL, err := transport.Proxy(req); err != nil || proxyURL == nil || proxyURL.String() != "socks5://127.0.0.1:1080" {
+		t.Errorf("got proxy %v, %v", proxyURL, err)
+	}
+
+	for _, opts := range []*TransportOptions{
+		{CABundle: filepath.Join(dir, "missing.pem")},
+		{CABundle: notPEM},
+		{TLSMinVersion: "2.0"},
+		{Proxy: "ftp://proxy:21"},
+	} {
+		if _, err := newTransport(opts); err == nil {
+			t.Errorf("%#v: expected an error", opts)
+		}
+	}
+}
+
+func TestUseTransport(t *testing.T) {
+	original := http.DefaultTransport
+	defer func() {
+		http.DefaultTransport = original
+	}()
+
+	if err := UseTransport(TransportOptions{}); err != nil || http.DefaultTransport != original {
+		t.Fatalf("expected the default transport to be kept, got err %v", err)
+	}
+
+	if err := UseTransport(TransportOptions{InsecureSkipVerify: true}); err != nil {
+		t.Fatal(err)
+	}
+	transport, ok := http.DefaultTransport.(*http.Transport)
+	if !ok || !transport.TLSClientConfig.InsecureSkipVerify {
+		t.Errorf("got transport %#v", http.DefaultTransport)
+	}
+}
+
+func TestDialFor(t *testing.T) {
+	var networks []string
+	failIPv4 := false
+	dial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		networks = append(networks, network)
+		if network == "tcp4" && failIPv4 {
+			return nil, errors.New("no IPv4 route")
+		}
+		return nil, nil
+	}
+
+	testCases := []struct {
+		desc       string
+		bindIP     net.IP
+		preferIPv4 bool
+		failIPv4   bool
+		want       []string
+	}{
+		{desc: "default", want: []string{"tcp"}},
+		{desc: "prefer IPv4", preferIPv4: true, want: []string{"tcp4"}},
+		{desc: "fall back from IPv4", preferIPv4: true, failIPv4: true, want: []string{"tcp4", "tcp"}},
+		{desc: "bound to IPv4", bindIP: net.ParseIP("192.168.1.20"), want: []string{"tcp4"}},
+		{desc: "bound to IPv6", bindIP: net.ParseIP("2001:db8::1"), preferIPv4: true, want: []string{"tcp6"}},
+	}
+
+	for _, tc := range testCases {
+		networks, failIPv4 = nil, tc.failIPv4
+		dialFor(dial, tc.bindIP, tc.preferIPv4)(context.Background(), "tcp", "www.googleapis.com:443")
+		if strings.Join(networks, ",") != strings.Join(tc.want, ",") {
+			t.Errorf("%s: dialed %v want %v", tc.desc, networks, tc.want)
+		}
+	}
+}
+
+func TestPickInterfaceIP(t *testing.T) {
+	ipNet := func(s string) net.Addr {
+		return &net.IPNet{IP: net.ParseIP(s), Mask: net.CIDRMask(64, 128)}
+	}
+	addrs := []net.Addr{ipNet("fe80::1"), ipNet("2001:db8::1"), ipNet("169.254.0.9"), ipNet("192.168.1.20")}
+
+	if got := pickInterfaceIP(addrs, false); !got.Equal(net.ParseIP("2001:db8::1")) {
+		t.Errorf("got %v want the first global address", got)
+	}
+	if got := pickInterfaceIP(addrs, true); !got.Equal(net.ParseIP("192.168.1.20")) {
+		t.Errorf("got %v want the global IPv4 address", got)
+	}
+	if got := pickInterfaceIP(addrs[:1], true); !got.Equal(net.ParseIP("fe80::1")) {
+		t.Errorf("got %v want the only address", got)
+	}
+	if got := pickInterfaceIP(nil, false); got != nil {
+		t.Errorf("got %v want none", got)
+	}
+}
+
+func TestResolveBindAddress(t *testing.T) {
+	if ip, err := resolveBindAddress("10.0.0.2", false); err != nil || !ip.Equal(net.ParseIP("10.0.0.2")) {
+		t.Errorf("got %v, %v", ip, err)
+	}
+	if _, err := resolveBindAddress("no-such-interface0", false); err == nil {
+		t.Errorf("expected an unknown interface to be rejected")
+	}
+}
@@ -25,6 +25,17 @@ type trashOpt struct {
 	byId      bool
 }
 
+func (opt *trashOpt) verb() string {
+	switch {
+	case opt.permanent:
+		return "delete"
+	case opt.toTrash:
+		return "trash"
+	default:
+		return "untrash"
+	}
+}
+
 func (g *Commands) Trash(byId bool) (err error) {
 	opt := trashOpt{
 		toTrash:   true,
@@ -53,6 +64,10 @@ func (g *Commands) Untrash(byId bool) (err error) {
 }
 
 func (g *Commands) EmptyTrash() error {
+	if err := g.refuseReadOnly("emptytrash"); err != nil {
+		return err
+	}
+
 	rootFile, err := g.rem.FindByPath("/")
 	if err != nil {
 		return err
@@ -127,6 +142,14 @@ func (g *Commands) trasher(relToRoot string, opt *trashOpt) (*Change, error) {
 }
 
 func (g *Commands) trashByMatch(inTrash, permanent bool) error {
+	opt := trashOpt{
+		toTrash:   !inTrash,
+		permanent: permanent,
+	}
+	if err := g.refuseReadOnly(opt.verb()); err != nil {
+		return err
+	}
+
 	mq := matchQuery{
 		dirPath: g.opts.Path,
 		inTrash: false,
@@ -187,12 +210,6 @@ func (g *Commands) trashByMatch(inTrash, permanent bool) error {
 		return status.Error()
 	}
 
-	toTrash := !inTrash
-	opt := trashOpt{
-		toTrash:   toTrash,
-		permanent: permanent,
-	}
-
 	return g.playTrashChangeList(cl, &opt)
 }
 
@@ -209,12 +226,16 @@ func (g *Commands) DeleteByMatch() error {
 }
 
 func (g *Commands) reduceForTrash(args []string, opt *trashOpt) error {
+	if err := g.refuseReadOnly(opt.verb()); err != nil {
+		return err
+	}
+
 	var cl []*Change
 	for i, relToRoot := range args {
 		c, cErr := g.trasher(relToRoot, opt)
 		g.DebugPrintf("[reduceForTrash] #%d: relToRoot: %s\n", i, relToRoot)
 		if cErr != nil {
-			g.log.LogErrf("\033[91m'%s': %v\033[00m\n", relToRoot, cErr)
+			g.log.LogErrf("\033[91m%v\033[00m\n", pathErr(opt.verb(), OpNone, relToRoot, "", cErr))
 		} else if c != nil {
 			cl = append(cl, c)
 		}
@@ -265,9 +286,9 @@ func (g *Commands) playTrashChangeList(cl []*Change, opt *trashOpt) (err error)
 			continue
 		}
 
-		cErr := fn(c)
-		if cErr != nil {
+		if cErr := changeErr(opt.verb(), c, fn(c)); cErr != nil {
 			g.log.LogErrln(cErr)
+			err = combineErrors(err, cErr)
 		}
 	}
 
@@ -95,6 +95,7 @@ type File struct {
 	Md5Checksum        string
 	MimeType           string
 	ModTime            time.Time
+	CreatedTime        time.Time
 	LastViewedByMeTime time.Time
 	Name               string
 	Size               int64
@@ -116,6 +117,14 @@ type File struct {
 	Description           string
 	Parents               []*ParentFile
 	QuotaBytesUsed        int64
+	// Split is set for the manifest of a file pushed in parts, whose
+	// Size and Md5Checksum are then those of the original file.
+	Split bool
+	// RemoteLock is the advisory lock taken on the file, if any.
+	RemoteLock *RemoteLock
+	// ShardFolder is set for the folders that push fans sharded
+	// folders out into.
+	ShardFolder bool
 }
 
 func newParentFile(p *drive.ParentReference) *ParentFile {
@@ -142,7 +151,7 @@ func NewRemoteFile(f *drive.File) *File {
 		return pfl
 	}(f.Parents)
 
-	return &File{
+	rf := &File{
 		AlternateLink:      f.AlternateLink,
 		BlobAt:             f.DownloadUrl,
 		Copyable:           f.Copyable,
@@ -153,6 +162,7 @@ func NewRemoteFile(f *drive.File) *File {
 		Md5Checksum:        f.Md5Checksum,
 		MimeType:           f.MimeType,
 		ModTime:            parseTimeAndRound(f.ModifiedDate),
+		CreatedTime:        parseTimeAndRound(f.CreatedDate),
 		LastViewedByMeTime: parseTimeAndRound(f.LastViewedByMeDate),
 		// We must convert each title to match that on the FS.
 		Name:                  urlToPath(f.Title, true),
@@ -168,7 +178,16 @@ func NewRemoteFile(f *drive.File) *File {
 		Description:           f.Description,
 		Parents:               parents,
 		QuotaBytesUsed:        f.QuotaBytesUsed,
+		RemoteLock:            remoteLockOf(f.Properties),
+		ShardFolder:           isShardFolder(f.Properties),
+	}
+
+	if size, md5Checksum, ok := parseSplitDescription(f.Description); ok {
+		rf.Size = size
+		rf.Md5Checksum = md5Checksum
+		rf.Split = true
 	}
+	return rf
 }
 
 func DupFile(f *File) *File {
@@ -185,6 +204,7 @@ func DupFile(f *File) *File {
 		Md5Checksum: f.Md5Checksum,
 		MimeType:    f.MimeType,
 		ModTime:     f.ModTime,
+		CreatedTime: f.CreatedTime,
 		Copyable:    f.Copyable,
 		// We must convert each title to match that on the FS.
 		Name:               f.Name,
@@ -201,6 +221,7 @@ func DupFile(f *File) *File {
 		Description:        f.Description,
 		Parents:            f.Parents,
 		QuotaBytesUsed:     f.QuotaBytesUsed,
+		Split:              f.Split,
 	}
 }
 
@@ -282,6 +303,10 @@ type Change struct {
 	IgnoreConflict bool
 	IgnoreChecksum bool
 	g              *Commands
+
+	// uploaded, if set, is called with the remote file once the change
+	// has been pushed.
+	uploaded func(*File)
 }
 
 type ByPrecedence []*Change
@@ -549,7 +574,7 @@ func (c *Change) Op() Operation {
 }
 
 func (f *File) ToIndex() *config.Index {
-	return &config.Index{
+	index := &config.Index{
 		FileId:      f.Id,
 		Etag:        f.Etag,
 		Md5Checksum: f.Md5Checksum,
@@ -557,6 +582,14 @@ func (f *File) ToIndex() *config.Index {
 		ModTime:     f.ModTime.Unix(),
 		Version:     f.Version,
 	}
+	if !f.CreatedTime.IsZero() {
+		index.CreatedTime = f.CreatedTime.Unix()
+	}
+	// The description of a split file is its manifest, not the user's.
+	if !f.Split {
+		index.Description = f.Description
+	}
+	return index
 }
 
 type fuzzyStringsValuePair struct {
@@ -575,6 +608,9 @@ type matchQuery struct {
 	mimeQuerySearches []fuzzyStringsValuePair
 	titleSearches     []fuzzyStringsValuePair
 	ownerSearches     []fuzzyStringsValuePair
+	namePatterns      []string
+	modifiedAfter     *time.Time
+	modifiedBefore    *time.Time
 }
 
 type fuzziness int
@@ -726,6 +762,16 @@ func (mq *matchQuery) Stringer() string {
 		starredTranslations = []string{"(starred=true)"}
 	}
 
+	nameTranslations := []string{}
+	if nameQuery := namePatternsQuery(mq.namePatterns); nameQuery != "" {
+		nameTranslations = append(nameTranslations, nameQuery)
+	}
+
+	modifiedTranslations := []string{}
+	if modifiedQuery := modifiedRangeQuery(mq.modifiedAfter, mq.modifiedBefore); modifiedQuery != "" {
+		modifiedTranslations = append(modifiedTranslations, modifiedQuery)
+	}
+
 	exprPairs := []struct {
 		joiner   string
 		elements []string
@@ -734,6 +780,8 @@ func (mq *matchQuery) Stringer() string {
 		{" and ", titleTranslations},
 		{" and ", ownerTranslations},
 		{" and ", starredTranslations},
+		{" and ", nameTranslations},
+		{" and ", modifiedTranslations},
 	}
 
 	for _, exprPair := range exprPairs {
@@ -0,0 +1,154 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sampleSize returns how many of n files the sample spec e.g "5%" or
+// "200" asks to verify. An empty spec asks for all of them and
+// a non-empty sample of a non-empty set holds at least one file.
+func sampleSize(spec string, n int) (int, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return n, nil
+	}
+
+	var k int
+	if strings.HasSuffix(spec, "%") {
+		percent, err := strconv.ParseFloat(strings.TrimSuffix(spec, "%"), 64)
+		if err != nil || percent <= 0 || percent > 100 {
+			return 0, invalidArgumentsErr(fmt.Errorf("sample %q: expecting a percentage in (0%%, 100%%]", spec))
+		}
+		k = int(math.Ceil(float64(n) * percent / 100))
+	} else {
+		count, err := strconv.Atoi(spec)
+		if err != nil || count < 1 {
+			return 0, invalidArgumentsErr(fmt.Errorf("sample %q: expecting a percentage e.g 5%% or a number of files", spec))
+		}
+		k = count
+	}
+
+	if k > n {
+		k = n
+	}
+	return k, nil
+}
+
+// samplePaths picks k of paths at random, returned in sorted order.
+func samplePaths(paths []string, k int, rng *rand.Rand) []string {
+	if k >= len(paths) {
+		sample := append([]string{}, paths...)
+		sort.Strings(sample)
+		return sample
+	}
+
+	sample := make([]string, 0, k)
+	for _, i := range rng.Perm(len(paths))[:k] {
+		sample = append(sample, paths[i])
+	}
+	sort.Strings(sample)
+	return sample
+}
+
+// Verify hashes the content of local files afresh, bypassing the checksum
+// cache, and compares it against the md5 checksums of their remote
+// counterparts to catch corruption e.g bit rot. With a sample spec e.g
+// "5%", only a random sample of the files is verified on each run so that
+// successive runs cover everything over time without the cost of a full
+// verification. Files whose sizes differ are out of sync rather than
+// corrupt, and are only counted.
+func (g *Commands) Verify(sample string) (err error) {
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	verified, mismatched, outOfSync := 0, 0, 0
+	for _, relToRootPath := range g.opts.Sources {
+		root, rErr := g.rem.FindByPath(relToRootPath)
+		if rErr != nil && rErr != ErrPathNotExists {
+			return rErr
+		}
+		if root == nil {
+			return nonExistantRemoteErr(fmt.Errorf("verify: %s doesnot exist", customQuote(relToRootPath)))
+		}
+
+		tree, tErr := g.remoteChecksumTree(root)
+		if tErr != nil {
+			return tErr
+		}
+
+		var candidates []string
+		for relPath, f := range tree {
+			if f.Md5Checksum != "" {
+				candidates = append(candidates, relPath)
+			}
+		}
+
+		k, sErr := sampleSize(sample, len(candidates))
+		if sErr != nil {
+			return sErr
+		}
+
+		for _, relPath := range samplePaths(candidates, k, rng) {
+			remote := tree[relPath]
+
+			relToRoot := path.Join(relToRootPath, relPath)
+			if !root.IsDir {
+				relToRoot = relToRootPath
+			}
+			localPath := g.context.AbsPathOf(relToRoot)
+
+			fi, statErr := os.Stat(localPath)
+			if statErr != nil {
+				if os.IsNotExist(statErr) {
+					outOfSync += 1
+					continue
+				}
+				err = reComposeError(err, fmt.Sprintf("verify: %s: %v", relToRoot, statErr))
+				continue
+			}
+			if fi.IsDir() || fi.Size() != remote.Size {
+				outOfSync += 1
+				continue
+			}
+
+			checksum := md5Checksum(NewLocalFile(localPath, fi))
+			if checksum == "" {
+				err = reComposeError(err, fmt.Sprintf("verify: %s: could not be checksummed", customQuote(relToRoot)))
+				continue
+			}
+
+			verified += 1
+			if checksum != remote.Md5Checksum {
+				mismatched += 1
+				g.log.Logf("mismatch: %s local %s remote %s\n", relToRoot, checksum, remote.Md5Checksum)
+			}
+		}
+	}
+
+	g.log.Logf("verified %d files, %d mismatched, %d out of sync\n", verified, mismatched, outOfSync)
+	if mismatched > 0 {
+		err = combineErrors(err, treesDifferErr(fmt.Errorf("verify: %d files do not match their remote checksums", mismatched)))
+	}
+	return err
+}
@@ -0,0 +1,104 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestSampleSize(t *testing.T) {
+	cases := []struct {
+		spec    string
+		n       int
+		want    int
+		wantErr bool
+	}{
+		{spec: "", n: 40, want: 40},
+		{spec: "5%", n: 40, want: 2},
+		{spec: "5%", n: 10, want: 1},
+		{spec: "0.5%", n: 1000, want: 5},
+		{spec: "100%", n: 7, want: 7},
+		{spec: "5%", n: 0, want: 0},
+		{spec: "200", n: 1000, want: 200},
+		{spec: "200", n: 30, want: 30},
+		{spec: " 10 ", n: 30, want: 10},
+		{spec: "0%", n: 30, wantErr: true},
+		{spec: "101%", n: 30, wantErr: true},
+		{spec: "0", n: 30, wantErr: true},
+		{spec: "-3", n: 30, wantErr: true},
+		{spec: "five", n: 30, wantErr: true},
+		{spec: "%", n: 30, wantErr: true},
+	}
+
+	for _, tc := range cases {
+		got, err := sampleSize(tc.spec, tc.n)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("sampleSize(%q, %d): expected an error", tc.spec, tc.n)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("sampleSize(%q, %d): unexpected err %v", tc.spec, tc.n, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("sampleSize(%q, %d): got %d want %d", tc.spec, tc.n, got, tc.want)
+		}
+	}
+}
+
+func TestSamplePaths(t *testing.T) {
+	paths := []string{"/e", "/a", "/d", "/c", "/b", "/f"}
+	rng := rand.New(rand.NewSource(1))
+
+	cases := []struct {
+		k    int
+		want int
+	}{
+		{k: 0, want: 0},
+		{k: 3, want: 3},
+		{k: 6, want: 6},
+		{k: 10, want: 6},
+	}
+
+	known := map[string]bool{}
+	for _, p := range paths {
+		known[p] = true
+	}
+
+	for _, tc := range cases {
+		sample := samplePaths(paths, tc.k, rng)
+		if len(sample) != tc.want {
+			t.Errorf("k=%d: got %d paths want %d", tc.k, len(sample), tc.want)
+		}
+		if !sort.StringsAreSorted(sample) {
+			t.Errorf("k=%d: sample %v is not sorted", tc.k, sample)
+		}
+		seen := map[string]bool{}
+		for _, p := range sample {
+			if !known[p] || seen[p] {
+				t.Errorf("k=%d: unexpected or repeated path %q in %v", tc.k, p, sample)
+			}
+			seen[p] = true
+		}
+	}
+
+	if paths[0] != "/e" {
+		t.Errorf("samplePaths reordered its input: %v", paths)
+	}
+}
@@ -0,0 +1,207 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ParseWatchInterval parses how often a watching pull polls
+// for remote changes e.g "1m", where "" means not to watch.
+func ParseWatchInterval(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, invalidArgumentsErr(fmt.Errorf("watch interval %q: expecting a positive duration e.g 30s or 5m", s))
+	}
+	return d, nil
+}
+
+// underPath reports whether p is parent itself or lies within it.
+func underPath(p, parent string) bool {
+	return p == parent || parent == DriveRemoteSep || strings.HasPrefix(p, parent+DriveRemoteSep)
+}
+
+// changedFolders returns the fewest folders to pull again, so that the
+// sources take in the changes to the files at changedPaths, which for a
+// moved file are both where it was and where it is. A change only
+// invalidates the folder that holds it, or the source itself if the
+// source is what changed. When some changes couldn't be placed e.g
+// those of files deleted for good that weren't seen before, every
+// source is invalidated.
+func changedFolders(changedPaths []string, unplaced bool, sources []string) []string {
+	var folders []string
+	if unplaced {
+		folders = append(folders, sources...)
+	}
+	for _, p := range changedPaths {
+		p = path.Clean(p)
+		for _, source := range sources {
+			if !underPath(p, source) {
+				continue
+			}
+			folder := path.Dir(p)
+			if !underPath(folder, source) {
+				folder = source
+			}
+			folders = append(folders, folder)
+		}
+	}
+
+	// Folders sort after the ones that they are within.
+	sort.Strings(folders)
+	var fewest []string
+	for _, folder := range folders {
+		covered := false
+		for _, kept := range fewest {
+			if underPath(folder, kept) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			fewest = append(fewest, folder)
+		}
+	}
+	return fewest
+}
+
+// noteRemotePath records that r was seen at remotePath, if watching.
+func (g *Commands) noteRemotePath(r *File, remotePath string) {
+	if r == nil || r.Id == "" {
+		return
+	}
+	g.remotePathsMu.Lock()
+	defer g.remotePathsMu.Unlock()
+
+	if g.remotePaths == nil {
+		return
+	}
+	for _, p := range g.remotePaths[r.Id] {
+		if p == remotePath {
+			return
+		}
+	}
+	g.remotePaths[r.Id] = append(g.remotePaths[r.Id], remotePath)
+}
+
+// movedRemotePaths replaces the paths that the file with id was last
+// seen at with current, returning those it was seen at before.
+func (g *Commands) movedRemotePaths(id string, current []string) (previous []string) {
+	g.remotePathsMu.Lock()
+	defer g.remotePathsMu.Unlock()
+
+	if g.remotePaths == nil {
+		return nil
+	}
+	previous = g.remotePaths[id]
+	if len(current) < 1 {
+		delete(g.remotePaths, id)
+	} else {
+		g.remotePaths[id] = current
+	}
+	return previous
+}
+
+// changedPaths returns the remote paths of the files that changed
+// after the change since, along with the largest change id seen.
+// The paths that a file was last seen at are included along with the
+// current ones, since a file moved or deleted changes those folders too.
+func (g *Commands) changedPaths(since int64) (paths []string, unplaced bool, largest int64, err error) {
+	changes, err := g.rem.changes(since + 1)
+	if err != nil {
+		return nil, false, since, err
+	}
+
+	largest = since
+	for change := range changes {
+		if change.Id > largest {
+			largest = change.Id
+		}
+		if change.Deleted || change.File == nil {
+			previous := g.movedRemotePaths(change.FileId, nil)
+			if len(previous) < 1 {
+				unplaced = true
+			}
+			paths = append(paths, previous...)
+			continue
+		}
+		backPaths, bErr := g.rem.FindBackPaths(change.FileId)
+		if bErr != nil {
+			unplaced = true
+			continue
+		}
+		// Back paths are relative to the root, unlike the sources.
+		for i, p := range backPaths {
+			backPaths[i] = path.Join(DriveRemoteSep, p)
+		}
+		paths = append(paths, g.movedRemotePaths(change.FileId, backPaths)...)
+		paths = append(paths, backPaths...)
+	}
+	return paths, unplaced, largest, nil
+}
+
+// WatchPull pulls the sources and then keeps them fresh, polling the
+// changes feed every interval and pulling again only the folders that
+// changes were reported in, rather than all of the sources.
+func (g *Commands) WatchPull(interval time.Duration) error {
+	since, err := g.largestChangeId()
+	if err != nil {
+		return err
+	}
+	g.remotePathsMu.Lock()
+	g.remotePaths = make(map[string][]string)
+	g.remotePathsMu.Unlock()
+	if err := g.Pull(); err != nil {
+		return err
+	}
+
+	sources := g.opts.Sources
+	defer func() {
+		g.opts.Sources = sources
+	}()
+
+	for {
+		time.Sleep(interval)
+
+		paths, unplaced, largest, err := g.changedPaths(since)
+		if err != nil {
+			g.log.LogErrf("watch: %v\n", err)
+			continue
+		}
+		since = largest
+
+		folders := changedFolders(paths, unplaced, sources)
+		if len(folders) < 1 {
+			continue
+		}
+
+		// Listings cached before the changes would hide them from the pull.
+		g.rem.forgetMetadata()
+
+		g.log.Logf("watch: pulling %s\n", strings.Join(folders, ", "))
+		g.opts.Sources = folders
+		if err := g.Pull(); err != nil {
+			g.log.LogErrf("watch: pull: %v\n", err)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+// Copyright 2016 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drive
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	drive "google.golang.org/api/drive/v2"
+)
+
+func TestParseWatchInterval(t *testing.T) {
+	testCases := []struct {
+		s       string
+		want    time.Duration
+		wantErr bool
+	}{
+		{s: "", want: 0},
+		{s: "30s", want: 30 * time.Second},
+		{s: " 5m ", want: 5 * time.Minute},
+		{s: "0s", wantErr: true},
+		{s: "-1m", wantErr: true},
+		{s: "often", wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		got, err := ParseWatchInterval(tc.s)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("%q: expected an error", tc.s)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("%q: got %v, %v want %v", tc.s, got, err, tc.want)
+		}
+	}
+}
+
+func TestChangedFolders(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		changed  []string
+		unplaced bool
+		sources  []string
+		want     []string
+	}{
+		{
+			desc:    "only the folder holding a change",
+			changed: []string{"/photos/2016/a.jpg"},
+			sources: []string{"/"},
+			want:    []string{"/photos/2016"},
+		},
+		{
+			desc:    "changes outside of the sources are left alone",
+			changed: []string{"/music/a.mp3", "/photography/b.jpg"},
+			sources: []string{"/photos"},
+		},
+		{
+			desc:    "a changed source is pulled as a whole",
+			changed: []string{"/photos"},
+			sources: []string{"/photos", "/docs"},
+			want:    []string{"/photos"},
+		},
+		{
+			desc:    "nested folders collapse into the outermost",
+			changed: []string{"/photos/2016/summer/a.jpg", "/photos/b.jpg", "/photos/2016/c.jpg", "/docs/d.txt"},
+			sources: []string{"/"},
+			want:    []string{"/docs", "/photos"},
+		},
+		{
+			desc:    "siblings are pulled separately",
+			changed: []string{"/photos/2016/a.jpg", "/photos/2017/b.jpg", "/photos/2016/c.jpg"},
+			sources: []string{"/photos"},
+			want:    []string{"/photos/2016", "/photos/2017"},
+		},
+		{
+			desc:    "a moved file invalidates the folder it left",
+			changed: []string{"/photos/2016/a.jpg", "/photos/2017/a.jpg"},
+			sources: []string{"/photos"},
+			want:    []string{"/photos/2016", "/photos/2017"},
+		},
+		{
+			desc:     "unplaced changes invalidate every source",
+			changed:  []string{"/photos/2016/a.jpg"},
+			unplaced: true,
+			sources:  []string{"/photos", "/docs"},
+			want:     []string{"/docs", "/photos"},
+		},
+	}
+
+	for _, tc := range testCases {
+		got := changedFolders(tc.changed, tc.unplaced, tc.sources)
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %q want %q", tc.desc, got, tc.want)
+		}
+	}
+}
+
+func TestChangedPathsIncludePreviousPaths(t *testing.T) {
+	root := []*drive.ParentReference{{Id: "root", IsRoot: true}}
+	files := map[string]*drive.File{
+		"2016":  {Id: "2016", Title: "2016", MimeType: DriveFolderMimeType, Parents: root},
+		"2017":  {Id: "2017", Title: "2017", MimeType: DriveFolderMimeType, Parents: root},
+		"moved": {Id: "moved", Title: "a.jpg", Parents: []*drive.ParentReference{{Id: "2017"}}},
+		"fresh": {Id: "fresh", Title: "b.jpg", Parents: []*drive.ParentReference{{Id: "2016"}}},
+	}
+	changes := &drive.ChangeList{Items: []*drive.Change{
+		{Id: 5, FileId: "moved", File: files["moved"]},
+		{Id: 6, FileId: "fresh", File: files["fresh"]},
+		{Id: 7, FileId: "gone", Deleted: true},
+	}}
+
+	transport := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		var body interface{} = changes
+		if id := strings.TrimPrefix(req.URL.Path, "/drive/v2/files/"); id != req.URL.Path {
+			body = files[id]
+		}
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       ioutil.NopCloser(bytes.NewReader(data)),
+			Request:    req,
+		}, nil
+	})
+	rem, err := remoteFromClient(&http.Client{Transport: transport})
+	if err != nil {
+		t.Fatalf("remoteFromClient: %v", err)
+	}
+
+	// As seen by the pull before the changes.
+	g := &Commands{rem: rem, remotePaths: map[string][]string{}}
+	g.noteRemotePath(&File{Id: "moved"}, "/2016/a.jpg")
+	g.noteRemotePath(&File{Id: "gone"}, "/2017/c.jpg")
+
+	paths, unplaced, largest, err := g.changedPaths(4)
+	if err != nil {
+		t.Fatalf("changedPaths: %v", err)
+	}
+	sort.Strings(paths)
+	want := []string{"/2016/a.jpg", "/2016/b.jpg", "/2017/a.jpg", "/2017/c.jpg"}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("paths: got %q want %q", paths, want)
+	}
+	if unplaced {
+		t.Errorf("a deleted file that was seen before should be placed")
+	}
+	if largest != 7 {
+		t.Errorf("largest: got %d want 7", largest)
+	}
+	if got := g.remotePaths["moved"]; !reflect.DeepEqual(got, []string{"/2017/a.jpg"}) {
+		t.Errorf("expected the moved file to be remembered where it now is, got %q", got)
+	}
+
+	// A deleted file that wasn't seen can't be placed.
+	g = &Commands{rem: rem, remotePaths: map[string][]string{}}
+	if _, unplaced, _, err = g.changedPaths(4); err != nil || !unplaced {
+		t.Errorf("got unplaced %v, %v want an unplaced change", unplaced, err)
+	}
+}